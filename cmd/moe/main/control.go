@@ -41,6 +41,7 @@ import (
 	"mosn.io/mosn/pkg/protocol/xprotocol/dubbo"
 	"mosn.io/mosn/pkg/protocol/xprotocol/dubbothrift"
 	"mosn.io/mosn/pkg/protocol/xprotocol/tars"
+	"mosn.io/mosn/pkg/protocol/xprotocol/thrift"
 	"mosn.io/mosn/pkg/server"
 	"mosn.io/mosn/pkg/stagemanager"
 	xstream "mosn.io/mosn/pkg/stream/xprotocol"
@@ -250,6 +251,7 @@ func ExtensionsRegister(c *cli.Context) {
 	_ = xprotocol.RegisterXProtocolCodec(&dubbo.XCodec{})
 	_ = xprotocol.RegisterXProtocolCodec(&dubbothrift.XCodec{})
 	_ = xprotocol.RegisterXProtocolCodec(&tars.XCodec{})
+	_ = xprotocol.RegisterXProtocolCodec(&thrift.XCodec{})
 	// trace register
 	xtrace.RegisterDelegate(bolt.ProtocolName, tracebolt.Boltv1Delegate)
 	xtrace.RegisterDelegate(boltv2.ProtocolName, tracebolt.Boltv2Delegate)
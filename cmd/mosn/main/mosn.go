@@ -34,9 +34,15 @@ import (
 	_ "mosn.io/mosn/istio/istio1106/xds"
 	_ "mosn.io/mosn/pkg/admin/debug"
 	_ "mosn.io/mosn/pkg/filter/listener/originaldst"
+	_ "mosn.io/mosn/pkg/filter/listener/tlsinspector"
 	_ "mosn.io/mosn/pkg/filter/network/connectionmanager"
 	_ "mosn.io/mosn/pkg/filter/network/grpc"
+	_ "mosn.io/mosn/pkg/filter/network/kafkaproxy"
+	_ "mosn.io/mosn/pkg/filter/network/mqttproxy"
+	_ "mosn.io/mosn/pkg/filter/network/mysqlproxy"
 	_ "mosn.io/mosn/pkg/filter/network/proxy"
+	_ "mosn.io/mosn/pkg/filter/network/rbac"
+	_ "mosn.io/mosn/pkg/filter/network/redisproxy"
 	_ "mosn.io/mosn/pkg/filter/network/streamproxy"
 	_ "mosn.io/mosn/pkg/filter/network/tunnel"
 	_ "mosn.io/mosn/pkg/filter/stream/dsl"
@@ -51,11 +57,21 @@ import (
 	_ "mosn.io/mosn/pkg/filter/stream/mirror"
 	_ "mosn.io/mosn/pkg/filter/stream/payloadlimit"
 	_ "mosn.io/mosn/pkg/filter/stream/proxywasm"
+	_ "mosn.io/mosn/pkg/filter/stream/rbac"
 	_ "mosn.io/mosn/pkg/filter/stream/seata"
+	_ "mosn.io/mosn/pkg/filter/stream/transcoder/bolt2dubbo"
+	_ "mosn.io/mosn/pkg/filter/stream/transcoder/dubbo2bolt"
+	_ "mosn.io/mosn/pkg/filter/stream/transcoder/dubbo2triple"
+	_ "mosn.io/mosn/pkg/filter/stream/transcoder/grpcjson"
+	_ "mosn.io/mosn/pkg/filter/stream/transcoder/grpcweb"
 	_ "mosn.io/mosn/pkg/filter/stream/transcoder/http2bolt"
+	_ "mosn.io/mosn/pkg/filter/stream/transcoder/http2dubbo"
 	_ "mosn.io/mosn/pkg/filter/stream/transcoder/httpconv"
+	_ "mosn.io/mosn/pkg/log/sink/als"
+	_ "mosn.io/mosn/pkg/log/sink/otlp"
 	_ "mosn.io/mosn/pkg/metrics/sink"
 	_ "mosn.io/mosn/pkg/metrics/sink/prometheus"
+	_ "mosn.io/mosn/pkg/metrics/sink/statsd"
 	_ "mosn.io/mosn/pkg/network"
 	_ "mosn.io/mosn/pkg/protocol"
 	_ "mosn.io/mosn/pkg/protocol/xprotocol"
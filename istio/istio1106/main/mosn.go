@@ -34,6 +34,7 @@ import (
 	_ "mosn.io/mosn/istio/istio1106/xds"
 	_ "mosn.io/mosn/pkg/admin/debug"
 	_ "mosn.io/mosn/pkg/filter/listener/originaldst"
+	_ "mosn.io/mosn/pkg/filter/listener/tlsinspector"
 	_ "mosn.io/mosn/pkg/filter/network/connectionmanager"
 	_ "mosn.io/mosn/pkg/filter/network/grpc"
 	_ "mosn.io/mosn/pkg/filter/network/proxy"
@@ -51,10 +52,19 @@ import (
 	_ "mosn.io/mosn/pkg/filter/stream/payloadlimit"
 	_ "mosn.io/mosn/pkg/filter/stream/proxywasm"
 	_ "mosn.io/mosn/pkg/filter/stream/seata"
+	_ "mosn.io/mosn/pkg/filter/stream/transcoder/bolt2dubbo"
+	_ "mosn.io/mosn/pkg/filter/stream/transcoder/dubbo2bolt"
+	_ "mosn.io/mosn/pkg/filter/stream/transcoder/dubbo2triple"
+	_ "mosn.io/mosn/pkg/filter/stream/transcoder/grpcjson"
+	_ "mosn.io/mosn/pkg/filter/stream/transcoder/grpcweb"
 	_ "mosn.io/mosn/pkg/filter/stream/transcoder/http2bolt"
+	_ "mosn.io/mosn/pkg/filter/stream/transcoder/http2dubbo"
 	_ "mosn.io/mosn/pkg/filter/stream/transcoder/httpconv"
+	_ "mosn.io/mosn/pkg/log/sink/als"
+	_ "mosn.io/mosn/pkg/log/sink/otlp"
 	_ "mosn.io/mosn/pkg/metrics/sink"
 	_ "mosn.io/mosn/pkg/metrics/sink/prometheus"
+	_ "mosn.io/mosn/pkg/metrics/sink/statsd"
 	_ "mosn.io/mosn/pkg/network"
 	_ "mosn.io/mosn/pkg/protocol"
 	_ "mosn.io/mosn/pkg/protocol/xprotocol"
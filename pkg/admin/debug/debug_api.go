@@ -49,6 +49,9 @@ func init() {
 	admin.RegisterAdminHandleFunc("/debug/update_config", DebugUpdateMosnConfig)
 	admin.RegisterAdminHandleFunc("/debug/disable_tls", DebugUpdateTLSDisable)
 	admin.RegisterAdminHandleFunc("/debug/update_route", DebugUdpateRoute)
+	admin.RegisterMutatingAdminAPI("/debug/update_config")
+	admin.RegisterMutatingAdminAPI("/debug/disable_tls")
+	admin.RegisterMutatingAdminAPI("/debug/update_route")
 }
 
 // The config types support to be updated
@@ -18,17 +18,27 @@
 package server
 
 import (
+	"bufio"
 	"bytes"
 	rawjson "encoding/json"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	v2 "mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/configmanager"
+	"mosn.io/mosn/pkg/log"
 	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/router"
+	"mosn.io/mosn/pkg/tap"
+	"mosn.io/mosn/pkg/upstream/cluster"
 )
 
 func TestKnownFeatures(t *testing.T) {
@@ -245,3 +255,376 @@ func TestVersion(t *testing.T) {
 		t.Fatalf("expectation failure: %v", err)
 	}
 }
+
+func TestClustersStatus(t *testing.T) {
+	cluster.NewClusterManagerSingleton([]v2.Cluster{
+		{
+			Name:        "clusters_api",
+			ClusterType: v2.SIMPLE_CLUSTER,
+			LbType:      v2.LB_ROUNDROBIN,
+		},
+	}, map[string][]v2.Host{
+		"clusters_api": {
+			{HostConfig: v2.HostConfig{Address: "127.0.0.1:10000", Weight: 10}},
+		},
+	}, nil)
+	defer func() {
+		cluster.GetClusterMngAdapterInstance().Destroy()
+		metrics.ResetAll()
+	}()
+
+	r := httptest.NewRequest("GET", "http://127.0.0.1/api/v1/clusters?cluster=clusters_api", nil)
+	w := httptest.NewRecorder()
+	ClustersStatus(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("response status got %d", w.Code)
+	}
+	out := &clustersResult{}
+	if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+		t.Fatalf("response is not json: %v", err)
+	}
+	if len(out.Clusters) != 1 || out.Clusters[0].Name != "clusters_api" ||
+		len(out.Clusters[0].Hosts) != 1 || out.Clusters[0].Hosts[0].Weight != 10 ||
+		!out.Clusters[0].Hosts[0].Healthy {
+		t.Fatalf("clusters status got %s", w.Body.String())
+	}
+
+	r = httptest.NewRequest("GET", "http://127.0.0.1/api/v1/clusters?format=text", nil)
+	w = httptest.NewRecorder()
+	ClustersStatus(w, r)
+	text := w.Body.String()
+	for _, line := range []string{
+		"clusters_api::lb_type::LB_ROUNDROBIN\n",
+		"clusters_api::127.0.0.1:10000::weight::10\n",
+		"clusters_api::127.0.0.1:10000::healthy::true\n",
+	} {
+		if !strings.Contains(text, line) {
+			t.Fatalf("text status %q does not contain %q", text, line)
+		}
+	}
+
+	r = httptest.NewRequest("GET", "http://127.0.0.1/api/v1/clusters?format=xml", nil)
+	w = httptest.NewRecorder()
+	ClustersStatus(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("response status got %d", w.Code)
+	}
+}
+
+func postMutation(t *testing.T, handler func(http.ResponseWriter, *http.Request), body string, expectedCode int) {
+	r := httptest.NewRequest("POST", "http://127.0.0.1/api/v1/mutation", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handler(w, r)
+	if w.Code != expectedCode {
+		t.Fatalf("post %s, expected status %d, but got %d: %s", body, expectedCode, w.Code, w.Body.String())
+	}
+}
+
+func dumpConfig(t *testing.T, query string, v interface{}) {
+	r := httptest.NewRequest("GET", "http://127.0.0.1/api/v1/config_dump?"+query, nil)
+	w := httptest.NewRecorder()
+	ConfigDump(w, r)
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("config dump %s is not json: %v, %s", query, err, w.Body.String())
+	}
+}
+
+func TestMutateHosts(t *testing.T) {
+	configmanager.Reset()
+	defer configmanager.Reset()
+	clusterConfig := v2.Cluster{
+		Name:        "mutation_api",
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_ROUNDROBIN,
+	}
+	configmanager.SetClusterConfig(clusterConfig)
+	cluster.NewClusterManagerSingleton([]v2.Cluster{clusterConfig}, map[string][]v2.Host{
+		"mutation_api": {
+			{HostConfig: v2.HostConfig{Address: "127.0.0.1:10000", Weight: 10}},
+			{HostConfig: v2.HostConfig{Address: "127.0.0.1:10001", Weight: 10}},
+		},
+	}, nil)
+	defer func() {
+		cluster.GetClusterMngAdapterInstance().Destroy()
+		metrics.ResetAll()
+	}()
+	hostsWeight := func() map[string]uint32 {
+		c := v2.Cluster{}
+		dumpConfig(t, "cluster=mutation_api", &c)
+		weights := map[string]uint32{}
+		for _, h := range c.Hosts {
+			weights[h.Address] = h.Weight
+		}
+		return weights
+	}
+
+	// method not allowed
+	r := httptest.NewRequest("GET", "http://127.0.0.1/api/v1/update_hosts", nil)
+	w := httptest.NewRecorder()
+	UpdateHosts(w, r)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("response status got %d", w.Code)
+	}
+	// shift the weight and add a host
+	postMutation(t, UpdateHosts, `{"cluster_name":"mutation_api","append":true,"hosts":[
+		{"address":"127.0.0.1:10001","weight":20},
+		{"address":"127.0.0.1:10002","weight":30}
+	]}`, http.StatusOK)
+	if weights := hostsWeight(); !reflect.DeepEqual(weights, map[string]uint32{
+		"127.0.0.1:10000": 10,
+		"127.0.0.1:10001": 20,
+		"127.0.0.1:10002": 30,
+	}) {
+		t.Fatalf("hosts weight got %v", weights)
+	}
+	// remove a host
+	postMutation(t, RemoveHosts, `{"cluster_name":"mutation_api","addresses":["127.0.0.1:10002"]}`, http.StatusOK)
+	if weights := hostsWeight(); len(weights) != 2 || weights["127.0.0.1:10002"] != 0 {
+		t.Fatalf("hosts weight got %v", weights)
+	}
+	// replace the hosts
+	postMutation(t, UpdateHosts, `{"cluster_name":"mutation_api","hosts":[{"address":"127.0.0.1:10003","weight":5}]}`, http.StatusOK)
+	if weights := hostsWeight(); !reflect.DeepEqual(weights, map[string]uint32{"127.0.0.1:10003": 5}) {
+		t.Fatalf("hosts weight got %v", weights)
+	}
+	// drain the host
+	postMutation(t, UpdateHostHealth, `{"cluster_name":"mutation_api","addresses":["127.0.0.1:10003"],"status":"draining"}`, http.StatusOK)
+	status := cluster.GetClustersStatus("mutation_api")
+	if status[0].HealthyHosts != 0 || !reflect.DeepEqual(status[0].Hosts[0].HealthFlags, []string{"draining"}) {
+		t.Fatalf("host is not drained: %+v", status[0].Hosts)
+	}
+	postMutation(t, UpdateHostHealth, `{"cluster_name":"mutation_api","addresses":["127.0.0.1:10003"],"status":"admin_unhealthy"}`, http.StatusOK)
+	postMutation(t, UpdateHostHealth, `{"cluster_name":"mutation_api","addresses":["127.0.0.1:10003"],"status":"healthy"}`, http.StatusOK)
+	if status := cluster.GetClustersStatus("mutation_api"); status[0].HealthyHosts != 1 {
+		t.Fatalf("host is not healthy: %+v", status[0].Hosts)
+	}
+	// bad requests
+	for _, tc := range []struct {
+		handler func(http.ResponseWriter, *http.Request)
+		body    string
+	}{
+		{UpdateHosts, `{"cluster_name":"not_exists","hosts":[]}`},
+		{UpdateHosts, `{"hosts":[]}`},
+		{UpdateHosts, `{"cluster_name":"mutation_api","append":true}`},
+		{UpdateHosts, `not json`},
+		{RemoveHosts, `{"cluster_name":"mutation_api"}`},
+		{UpdateHostHealth, `{"cluster_name":"mutation_api","addresses":["127.0.0.1:10003"],"status":"unknown"}`},
+		{UpdateHostHealth, `{"cluster_name":"mutation_api","addresses":["127.0.0.1:10000"],"status":"draining"}`},
+	} {
+		postMutation(t, tc.handler, tc.body, http.StatusBadRequest)
+	}
+}
+
+func TestUpdateAccessLogSampling(t *testing.T) {
+	postMutation(t, UpdateAccessLogSampling, `{"runtime_key":"admin_api","percent":0}`, http.StatusOK)
+	if p := log.GetSamplingPercents()["admin_api"]; p != 0 {
+		t.Fatalf("sampling percent got %v", p)
+	}
+	postMutation(t, UpdateAccessLogSampling, `{"runtime_key":"admin_api","percent":12.5}`, http.StatusOK)
+	if p := log.GetSamplingPercents()["admin_api"]; p != 12.5 {
+		t.Fatalf("sampling percent got %v", p)
+	}
+	for _, body := range []string{
+		`{"runtime_key":"admin_api"}`,
+		`{"runtime_key":"admin_api","percent":101}`,
+		`{"percent":10}`,
+		`not json`,
+	} {
+		postMutation(t, UpdateAccessLogSampling, body, http.StatusBadRequest)
+	}
+}
+
+func TestMutateRoutes(t *testing.T) {
+	configmanager.Reset()
+	defer configmanager.Reset()
+	if err := router.GetRoutersMangerInstance().AddOrUpdateRouters(&v2.RouterConfiguration{
+		RouterConfigurationConfig: v2.RouterConfigurationConfig{
+			RouterConfigName: "mutation_api",
+		},
+		VirtualHosts: []v2.VirtualHost{
+			{
+				Name:    "default",
+				Domains: []string{"*"},
+			},
+		},
+	}); err != nil {
+		t.Fatalf("add routers failed: %v", err)
+	}
+	virtualHosts := func() map[string][]string {
+		cfg := v2.RouterConfiguration{}
+		dumpConfig(t, "router=mutation_api", &cfg)
+		vhs := map[string][]string{}
+		for _, vh := range cfg.VirtualHosts {
+			routes := []string{}
+			for _, r := range vh.Routers {
+				routes = append(routes, r.Name)
+			}
+			vhs[vh.Name] = routes
+		}
+		return vhs
+	}
+
+	postMutation(t, UpdateVirtualHost, `{"router_config_name":"mutation_api","virtual_host":{
+		"name":"test","domains":["www.test.com"],
+		"routers":[{"name":"r1","match":{"prefix":"/"},"route":{"cluster_name":"c1"}}]
+	}}`, http.StatusOK)
+	postMutation(t, AddRoute, `{"router_config_name":"mutation_api","domain":"www.test.com",
+		"route":{"name":"r2","match":{"prefix":"/test"},"route":{"cluster_name":"c2"}}}`, http.StatusOK)
+	if vhs := virtualHosts(); !reflect.DeepEqual(vhs, map[string][]string{
+		"default": {},
+		"test":    {"r1", "r2"},
+	}) {
+		t.Fatalf("virtual hosts got %v", vhs)
+	}
+	postMutation(t, UpdateRoute, `{"router_config_name":"mutation_api","virtual_host_name":"test",
+		"route":{"name":"r2","match":{"prefix":"/update"},"route":{"cluster_name":"c3"}}}`, http.StatusOK)
+	cfg := v2.RouterConfiguration{}
+	dumpConfig(t, "router=mutation_api", &cfg)
+	for _, vh := range cfg.VirtualHosts {
+		if vh.Name == "test" {
+			if len(vh.Routers) != 2 || vh.Routers[1].Match.Prefix != "/update" || vh.Routers[1].Route.ClusterName != "c3" {
+				t.Fatalf("route is not updated: %+v", vh.Routers)
+			}
+		}
+	}
+	postMutation(t, RemoveRoute, `{"router_config_name":"mutation_api","virtual_host_name":"test","route_name":"r1"}`, http.StatusOK)
+	if vhs := virtualHosts(); !reflect.DeepEqual(vhs["test"], []string{"r2"}) {
+		t.Fatalf("virtual hosts got %v", vhs)
+	}
+	postMutation(t, RemoveVirtualHost, `{"router_config_name":"mutation_api","virtual_host_name":"test"}`, http.StatusOK)
+	if vhs := virtualHosts(); !reflect.DeepEqual(vhs, map[string][]string{"default": {}}) {
+		t.Fatalf("virtual hosts got %v", vhs)
+	}
+	// bad requests
+	for _, tc := range []struct {
+		handler func(http.ResponseWriter, *http.Request)
+		body    string
+	}{
+		{UpdateVirtualHost, `{"router_config_name":"not_exists","virtual_host":{"name":"test"}}`},
+		{UpdateVirtualHost, `{"router_config_name":"mutation_api"}`},
+		{RemoveVirtualHost, `{"router_config_name":"mutation_api","virtual_host_name":"test"}`},
+		{RemoveRoute, `{"router_config_name":"mutation_api","virtual_host_name":"default","route_name":"r1"}`},
+		{AddRoute, `{"router_config_name":"mutation_api"}`},
+		{UpdateRoute, `{"router_config_name":"mutation_api","virtual_host_name":"default","route":{"name":"r1"}}`},
+		{UpdateRoute, `{"router_config_name":"mutation_api","virtual_host_name":"default","route":{}}`},
+	} {
+		postMutation(t, tc.handler, tc.body, http.StatusBadRequest)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	if err := setAuditLog(path); err != nil {
+		t.Fatalf("set audit log failed: %v", err)
+	}
+	defer setAuditLog("")
+	postMutation(t, RemoveRoute, `{"router_config_name":"audit_not_exists","virtual_host_name":"vh","route_name":"r"}`, http.StatusBadRequest)
+	for i := 0; i < 100; i++ {
+		if b, _ := ioutil.ReadFile(path); bytes.Contains(b, []byte("api: remove route")) {
+			if !bytes.Contains(b, []byte("result: failed")) {
+				t.Fatalf("audit log without result: %s", string(b))
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("audit log is not written")
+}
+
+// waitTapActive waits the tap session started by the handler
+func waitTapActive(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		if tap.Active() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("tap session is not started")
+}
+
+func TestTap(t *testing.T) {
+	// invalid requests
+	for _, body := range []string{
+		`invalid json`,
+		`{"timeout":"1h"}`,
+		`{"match":{"remote_ip":"invalid"}}`,
+		`{"output":{"path":"tap","format":"unknown"}}`,
+		`{"output":{"path":"tap.json"}}`,
+	} {
+		postMutation(t, Tap, body, http.StatusBadRequest)
+	}
+	r := httptest.NewRequest("GET", "http://127.0.0.1/api/v1/tap", nil)
+	w := httptest.NewRecorder()
+	Tap(w, r)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("get tap expected status 405, but got %d", w.Code)
+	}
+	if tap.Active() {
+		t.Fatal("tap session is started by invalid requests")
+	}
+
+	// streaming
+	server := httptest.NewServer(http.HandlerFunc(Tap))
+	defer server.Close()
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader(`{"match":{"listener":"test_listener"},"max_body_bytes":16,"max_traces":2,"timeout":"10s"}`))
+	if err != nil {
+		t.Fatalf("post tap failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("post tap expected status 200, but got %d", resp.StatusCode)
+	}
+	waitTapActive(t)
+	for _, listener := range []string{"other_listener", "test_listener", "test_listener"} {
+		if c := tap.NewCapture(listener, nil, protocol.CommonHeader{"service": "test"}, []byte("request")); c != nil {
+			c.Finish("test_route", nil)
+		}
+	}
+	var traces []*tap.Trace
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		trace := &tap.Trace{}
+		if err := json.Unmarshal(scanner.Bytes(), trace); err != nil {
+			t.Fatalf("tap trace is not json: %v, %s", err, scanner.Text())
+		}
+		traces = append(traces, trace)
+	}
+	if len(traces) != 2 {
+		t.Fatalf("expected 2 traces, but got %d", len(traces))
+	}
+	for _, trace := range traces {
+		if trace.Listener != "test_listener" || trace.Route != "test_route" || trace.RequestBody != "request" || trace.RequestHeaders["service"] != "test" {
+			t.Fatalf("unexpected trace: %+v", trace)
+		}
+	}
+
+	// file output
+	dir := t.TempDir()
+	tap.SetOutputDir(dir)
+	defer tap.SetOutputDir("")
+	existed := filepath.Join(dir, "existed.json")
+	if err := ioutil.WriteFile(existed, []byte("existed"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{existed, "existed.json", "../tap.json", "sub/../../tap.json"} {
+		postMutation(t, Tap, `{"output":{"path":"`+path+`"}}`, http.StatusBadRequest)
+	}
+	if b, _ := ioutil.ReadFile(existed); string(b) != "existed" {
+		t.Fatalf("existed file is overwritten: %s", string(b))
+	}
+	path := filepath.Join(dir, "tap.json")
+	postMutation(t, Tap, `{"max_traces":1,"output":{"path":"tap.json"}}`, http.StatusOK)
+	waitTapActive(t)
+	tap.NewCapture("test_listener", nil, nil, nil).Finish("", nil)
+	for i := 0; i < 100; i++ {
+		if b, _ := ioutil.ReadFile(path); len(b) > 0 {
+			trace := &tap.Trace{}
+			if err := json.Unmarshal(b, trace); err != nil || trace.Listener != "test_listener" {
+				t.Fatalf("unexpected tap file: %v, %s", err, string(b))
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("tap file is not written")
+}
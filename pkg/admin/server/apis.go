@@ -21,10 +21,14 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	gometrics "github.com/rcrowley/go-metrics"
 	v2 "mosn.io/mosn/pkg/config/v2"
@@ -34,8 +38,13 @@ import (
 	"mosn.io/mosn/pkg/metrics"
 	"mosn.io/mosn/pkg/metrics/sink/console"
 	"mosn.io/mosn/pkg/plugin"
+	"mosn.io/mosn/pkg/router"
 	"mosn.io/mosn/pkg/stagemanager"
+	"mosn.io/mosn/pkg/tap"
 	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/upstream/cluster"
+	mlog "mosn.io/pkg/log"
+	"mosn.io/pkg/utils"
 )
 
 var levelMap = map[string]log.Level{
@@ -383,3 +392,529 @@ func GetEnv(w http.ResponseWriter, r *http.Request) {
 	data, _ := json.MarshalIndent(results, "", " ")
 	w.Write(data)
 }
+
+type clustersResult struct {
+	Clusters []*cluster.ClusterStatus `json:"clusters"`
+}
+
+// ClustersStatus outputs the runtime status of the clusters and hosts
+// http://ip:port/api/v1/clusters
+// http://ip:port/api/v1/clusters?cluster=name1&cluster=name2
+// http://ip:port/api/v1/clusters?format=text
+func ClustersStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "clusters status", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	r.ParseForm()
+	status := cluster.GetClustersStatus(r.Form["cluster"]...)
+	switch r.FormValue("format") {
+	case "", "json":
+		if status == nil {
+			status = []*cluster.ClusterStatus{}
+		}
+		data, _ := json.MarshalIndent(&clustersResult{Clusters: status}, "", " ")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	case "text":
+		w.Header().Set("Content-Type", "text/plain")
+		writeClustersText(w, status)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		msg := fmt.Sprintf(errMsgFmt, "unknown format: "+r.FormValue("format"))
+		fmt.Fprint(w, msg)
+	}
+}
+
+// writeClustersText writes the status as lines of cluster::[host::]key::value
+func writeClustersText(w io.Writer, status []*cluster.ClusterStatus) {
+	for _, c := range status {
+		fmt.Fprintf(w, "%s::type::%s\n", c.Name, c.Type)
+		fmt.Fprintf(w, "%s::lb_type::%s\n", c.Name, c.LbType)
+		fmt.Fprintf(w, "%s::healthy_hosts::%d\n", c.Name, c.HealthyHosts)
+		for _, res := range []struct {
+			name   string
+			status cluster.ResourceStatus
+		}{
+			{"connections", c.CircuitBreakers.Connections},
+			{"pending_requests", c.CircuitBreakers.PendingRequests},
+			{"requests", c.CircuitBreakers.Requests},
+			{"retries", c.CircuitBreakers.Retries},
+		} {
+			fmt.Fprintf(w, "%s::circuit_breakers::%s::max::%d\n", c.Name, res.name, res.status.Max)
+			fmt.Fprintf(w, "%s::circuit_breakers::%s::current::%d\n", c.Name, res.name, res.status.Current)
+			fmt.Fprintf(w, "%s::circuit_breakers::%s::remaining::%d\n", c.Name, res.name, res.status.Remaining)
+		}
+		for _, h := range c.Hosts {
+			prefix := c.Name + "::" + h.Address
+			fmt.Fprintf(w, "%s::hostname::%s\n", prefix, h.Hostname)
+			fmt.Fprintf(w, "%s::weight::%d\n", prefix, h.Weight)
+			fmt.Fprintf(w, "%s::healthy::%t\n", prefix, h.Healthy)
+			fmt.Fprintf(w, "%s::health_flags::%s\n", prefix, strings.Join(h.HealthFlags, "|"))
+			fmt.Fprintf(w, "%s::active_connections::%d\n", prefix, h.ActiveConnections)
+			fmt.Fprintf(w, "%s::active_requests::%d\n", prefix, h.ActiveRequests)
+			fmt.Fprintf(w, "%s::total_requests::%d\n", prefix, h.TotalRequests)
+			results := make([]string, 0, len(h.HealthCheckResults))
+			for _, res := range h.HealthCheckResults {
+				if res.Healthy {
+					results = append(results, "healthy")
+				} else {
+					results = append(results, "unhealthy")
+				}
+			}
+			fmt.Fprintf(w, "%s::health_check_results::%s\n", prefix, strings.Join(results, "|"))
+		}
+	}
+}
+
+// handleMutation handles the apis that mutate the runtime clusters, hosts and routes.
+// the mutate function parses the body and applies the mutation, an audit log is written
+// for each mutation, the result can be checked by the config dump.
+func handleMutation(w http.ResponseWriter, r *http.Request, api string, mutate func(body []byte) error) {
+	if r.Method != http.MethodPost {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", api, r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: read body failed, %v", api, err)
+		w.WriteHeader(http.StatusBadRequest)
+		msg := fmt.Sprintf(errMsgFmt, "read body error")
+		fmt.Fprint(w, msg)
+		return
+	}
+	if err := mutate(body); err != nil {
+		auditLog(r, api, body, err)
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: %v", api, err)
+		w.WriteHeader(http.StatusBadRequest)
+		msg := fmt.Sprintf(errMsgFmt, api+" failed: "+strings.ReplaceAll(err.Error(), `"`, `'`))
+		fmt.Fprint(w, msg)
+		return
+	}
+	auditLog(r, api, body, nil)
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "%s success\n", api)
+}
+
+// auditLogger writes the audit logs if the audit log path is configured
+var auditLogger atomic.Value // *log.Logger
+
+// setAuditLog sets the file of the audit logs, the audit logs are written to the error log if it is empty
+func setAuditLog(path string) error {
+	if path == "" {
+		auditLogger.Store((*mlog.Logger)(nil))
+		return nil
+	}
+	lg, err := log.GetOrCreateLogger(path, nil)
+	if err != nil {
+		return err
+	}
+	auditLogger.Store(lg)
+	return nil
+}
+
+// auditLog writes a log for the api changes the runtime state,
+// the log is always written regardless of the log level.
+func auditLog(r *http.Request, api string, body []byte, err error) {
+	result := "success"
+	if err != nil {
+		result = "failed, error: " + err.Error()
+	}
+	if lg, _ := auditLogger.Load().(*mlog.Logger); lg != nil {
+		lg.Printf("%s [admin api] [audit] remote: %s, user: %s, api: %s, request: %s, result: %s",
+			time.Now().Format("2006-01-02 15:04:05,000"), r.RemoteAddr, AuthUser(r), api, string(body), result)
+		return
+	}
+	log.DefaultLogger.Alertf(types.ErrorKeyAdminAudit, "[admin api] [audit] remote: %s, user: %s, api: %s, request: %s, result: %s",
+		r.RemoteAddr, AuthUser(r), api, string(body), result)
+}
+
+var (
+	errEmptyClusterName      = errors.New("cluster_name is empty")
+	errEmptyHosts            = errors.New("hosts is empty")
+	errEmptyAddresses        = errors.New("addresses is empty")
+	errEmptyRouterConfigName = errors.New("router_config_name is empty")
+	errEmptyVirtualHostName  = errors.New("virtual_host_name is empty")
+	errEmptyRouteName        = errors.New("route_name is empty")
+	errEmptyPercent          = errors.New("percent is empty")
+)
+
+// UpdateHostsData is the post data of UpdateHosts
+type UpdateHostsData struct {
+	ClusterName string    `json:"cluster_name"`
+	Hosts       []v2.Host `json:"hosts"`
+	// Append adds the hosts or updates the hosts with the same address,
+	// otherwise all the hosts in the cluster are replaced.
+	Append bool `json:"append"`
+}
+
+// UpdateHosts adds, updates or replaces the hosts of a cluster, e.g. shift the weights
+// post data: {"cluster_name":"cluster","hosts":[{"address":"127.0.0.1:8080","weight":10}],"append":true}
+func UpdateHosts(w http.ResponseWriter, r *http.Request) {
+	handleMutation(w, r, "update hosts", func(body []byte) error {
+		data := &UpdateHostsData{}
+		if err := json.Unmarshal(body, data); err != nil {
+			return err
+		}
+		if data.ClusterName == "" {
+			return errEmptyClusterName
+		}
+		adapter := cluster.GetClusterMngAdapterInstance()
+		if data.Append {
+			if len(data.Hosts) == 0 {
+				return errEmptyHosts
+			}
+			return adapter.TriggerHostAppend(data.ClusterName, data.Hosts)
+		}
+		return adapter.TriggerClusterHostUpdate(data.ClusterName, data.Hosts)
+	})
+}
+
+// RemoveHostsData is the post data of RemoveHosts
+type RemoveHostsData struct {
+	ClusterName string   `json:"cluster_name"`
+	Addresses   []string `json:"addresses"`
+}
+
+// RemoveHosts removes the hosts from a cluster by address
+// post data: {"cluster_name":"cluster","addresses":["127.0.0.1:8080"]}
+func RemoveHosts(w http.ResponseWriter, r *http.Request) {
+	handleMutation(w, r, "remove hosts", func(body []byte) error {
+		data := &RemoveHostsData{}
+		if err := json.Unmarshal(body, data); err != nil {
+			return err
+		}
+		if data.ClusterName == "" {
+			return errEmptyClusterName
+		}
+		if len(data.Addresses) == 0 {
+			return errEmptyAddresses
+		}
+		return cluster.GetClusterMngAdapterInstance().TriggerHostDel(data.ClusterName, data.Addresses)
+	})
+}
+
+// the host health status can be set by UpdateHostHealth
+const (
+	HostStatusDraining       = "draining"
+	HostStatusAdminUnhealthy = "admin_unhealthy"
+	HostStatusHealthy        = "healthy"
+)
+
+// UpdateHostHealthData is the post data of UpdateHostHealth
+type UpdateHostHealthData struct {
+	ClusterName string   `json:"cluster_name"`
+	Addresses   []string `json:"addresses"`
+	Status      string   `json:"status"`
+}
+
+// UpdateHostHealth drains the hosts or marks the hosts unhealthy, the load balancers
+// do not choose the hosts until the status is set to healthy.
+// Notice the status is shared by the hosts with the same address in all clusters.
+// post data: {"cluster_name":"cluster","addresses":["127.0.0.1:8080"],"status":"draining"}
+func UpdateHostHealth(w http.ResponseWriter, r *http.Request) {
+	handleMutation(w, r, "update host health", func(body []byte) error {
+		data := &UpdateHostHealthData{}
+		if err := json.Unmarshal(body, data); err != nil {
+			return err
+		}
+		if data.ClusterName == "" {
+			return errEmptyClusterName
+		}
+		if len(data.Addresses) == 0 {
+			return errEmptyAddresses
+		}
+		switch data.Status {
+		case HostStatusDraining:
+			return cluster.SetHostsHealthFlag(data.ClusterName, data.Addresses, cluster.HostDraining, true)
+		case HostStatusAdminUnhealthy:
+			return cluster.SetHostsHealthFlag(data.ClusterName, data.Addresses, cluster.HostAdminUnhealthy, true)
+		case HostStatusHealthy:
+			if err := cluster.SetHostsHealthFlag(data.ClusterName, data.Addresses, cluster.HostDraining, false); err != nil {
+				return err
+			}
+			return cluster.SetHostsHealthFlag(data.ClusterName, data.Addresses, cluster.HostAdminUnhealthy, false)
+		default:
+			return fmt.Errorf("unknown status: %s", data.Status)
+		}
+	})
+}
+
+// UpdateAccessLogSamplingData is the post data of UpdateAccessLogSampling
+type UpdateAccessLogSamplingData struct {
+	RuntimeKey string   `json:"runtime_key"`
+	Percent    *float64 `json:"percent"`
+}
+
+// UpdateAccessLogSampling updates the percent of the access log sampling filters with the runtime key
+// post data: {"runtime_key":"ingress_sampling","percent":10}
+func UpdateAccessLogSampling(w http.ResponseWriter, r *http.Request) {
+	handleMutation(w, r, "update access log sampling", func(body []byte) error {
+		data := &UpdateAccessLogSamplingData{}
+		if err := json.Unmarshal(body, data); err != nil {
+			return err
+		}
+		if data.Percent == nil {
+			return errEmptyPercent
+		}
+		return log.SetSamplingPercent(data.RuntimeKey, *data.Percent)
+	})
+}
+
+// UpdateVirtualHostData is the post data of UpdateVirtualHost
+type UpdateVirtualHostData struct {
+	RouterConfigName string          `json:"router_config_name"`
+	VirtualHost      *v2.VirtualHost `json:"virtual_host"`
+}
+
+// UpdateVirtualHost adds a virtual host, or replaces the virtual host with the same name
+// post data: {"router_config_name":"router","virtual_host":{"name":"vh","domains":["*"],"routers":[]}}
+func UpdateVirtualHost(w http.ResponseWriter, r *http.Request) {
+	handleMutation(w, r, "update virtual host", func(body []byte) error {
+		data := &UpdateVirtualHostData{}
+		if err := json.Unmarshal(body, data); err != nil {
+			return err
+		}
+		if data.RouterConfigName == "" {
+			return errEmptyRouterConfigName
+		}
+		if data.VirtualHost == nil || data.VirtualHost.Name == "" {
+			return errEmptyVirtualHostName
+		}
+		return router.GetRoutersMangerInstance().AddOrUpdateVirtualHost(data.RouterConfigName, data.VirtualHost)
+	})
+}
+
+// RemoveVirtualHostData is the post data of RemoveVirtualHost
+type RemoveVirtualHostData struct {
+	RouterConfigName string `json:"router_config_name"`
+	VirtualHostName  string `json:"virtual_host_name"`
+}
+
+// RemoveVirtualHost removes the virtual host by name
+// post data: {"router_config_name":"router","virtual_host_name":"vh"}
+func RemoveVirtualHost(w http.ResponseWriter, r *http.Request) {
+	handleMutation(w, r, "remove virtual host", func(body []byte) error {
+		data := &RemoveVirtualHostData{}
+		if err := json.Unmarshal(body, data); err != nil {
+			return err
+		}
+		if data.RouterConfigName == "" {
+			return errEmptyRouterConfigName
+		}
+		if data.VirtualHostName == "" {
+			return errEmptyVirtualHostName
+		}
+		return router.GetRoutersMangerInstance().RemoveVirtualHost(data.RouterConfigName, data.VirtualHostName)
+	})
+}
+
+// AddRouteData is the post data of AddRoute
+type AddRouteData struct {
+	RouterConfigName string     `json:"router_config_name"`
+	Domain           string     `json:"domain"`
+	Route            *v2.Router `json:"route"`
+}
+
+// AddRoute adds a route into the virtual host found by domain, the domain is empty means the default virtual host
+// post data: {"router_config_name":"router","domain":"www.example.com","route":{"name":"r","match":{"prefix":"/"},"route":{"cluster_name":"cluster"}}}
+func AddRoute(w http.ResponseWriter, r *http.Request) {
+	handleMutation(w, r, "add route", func(body []byte) error {
+		data := &AddRouteData{}
+		if err := json.Unmarshal(body, data); err != nil {
+			return err
+		}
+		if data.RouterConfigName == "" {
+			return errEmptyRouterConfigName
+		}
+		if data.Route == nil {
+			return errors.New("route is empty")
+		}
+		return router.GetRoutersMangerInstance().AddRoute(data.RouterConfigName, data.Domain, data.Route)
+	})
+}
+
+// UpdateRouteData is the post data of UpdateRoute
+type UpdateRouteData struct {
+	RouterConfigName string     `json:"router_config_name"`
+	VirtualHostName  string     `json:"virtual_host_name"`
+	Route            *v2.Router `json:"route"`
+}
+
+// UpdateRoute replaces the routes with the same name in the virtual host
+// post data: {"router_config_name":"router","virtual_host_name":"vh","route":{"name":"r","match":{"prefix":"/"},"route":{"cluster_name":"cluster"}}}
+func UpdateRoute(w http.ResponseWriter, r *http.Request) {
+	handleMutation(w, r, "update route", func(body []byte) error {
+		data := &UpdateRouteData{}
+		if err := json.Unmarshal(body, data); err != nil {
+			return err
+		}
+		if data.RouterConfigName == "" {
+			return errEmptyRouterConfigName
+		}
+		if data.VirtualHostName == "" {
+			return errEmptyVirtualHostName
+		}
+		if data.Route == nil {
+			return errors.New("route is empty")
+		}
+		if data.Route.Name == "" {
+			return errors.New("route name is empty")
+		}
+		return router.GetRoutersMangerInstance().UpdateRoute(data.RouterConfigName, data.VirtualHostName, data.Route)
+	})
+}
+
+// RemoveRouteData is the post data of RemoveRoute
+type RemoveRouteData struct {
+	RouterConfigName string `json:"router_config_name"`
+	VirtualHostName  string `json:"virtual_host_name"`
+	RouteName        string `json:"route_name"`
+}
+
+// RemoveRoute removes the routes with the name in the virtual host
+// post data: {"router_config_name":"router","virtual_host_name":"vh","route_name":"r"}
+func RemoveRoute(w http.ResponseWriter, r *http.Request) {
+	handleMutation(w, r, "remove route", func(body []byte) error {
+		data := &RemoveRouteData{}
+		if err := json.Unmarshal(body, data); err != nil {
+			return err
+		}
+		if data.RouterConfigName == "" {
+			return errEmptyRouterConfigName
+		}
+		if data.VirtualHostName == "" {
+			return errEmptyVirtualHostName
+		}
+		if data.RouteName == "" {
+			return errEmptyRouteName
+		}
+		return router.GetRoutersMangerInstance().RemoveRoute(data.RouterConfigName, data.VirtualHostName, data.RouteName)
+	})
+}
+
+// TapData is the post data of Tap
+type TapData struct {
+	tap.Config
+	// Output writes the traces into the file instead of the response
+	Output *TapOutput `json:"output,omitempty"`
+}
+
+// TapOutput is the file to write the traces
+type TapOutput struct {
+	// Path is a relative path in the tap output directory of the admin config,
+	// the file should not exist.
+	Path string `json:"path"`
+	// Format is json or pcap, the default is json
+	Format string `json:"format,omitempty"`
+}
+
+type tapFileResult struct {
+	Path    string `json:"path"`
+	Timeout string `json:"timeout"`
+}
+
+// Tap captures the requests and responses matched, the traces are streamed as json lines
+// in the response until the tap is timeout or the client closes the connection.
+// If the output is set, the traces are written into the file and the api returns immediately.
+// post data: {"match":{"listener":"","route":"","headers":{"k":"v"},"remote_ip":"10.0.0.0/8"},
+// "max_body_bytes":1024,"max_traces":100,"timeout":"30s","output":{"path":"tap.pcap","format":"pcap"}}
+func Tap(w http.ResponseWriter, r *http.Request) {
+	const api = "tap"
+	if r.Method != http.MethodPost {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", api, r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: read body failed, %v", api, err)
+		w.WriteHeader(http.StatusBadRequest)
+		msg := fmt.Sprintf(errMsgFmt, "read body error")
+		fmt.Fprint(w, msg)
+		return
+	}
+	badRequest := func(err error) {
+		auditLog(r, api, body, err)
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: %v", api, err)
+		w.WriteHeader(http.StatusBadRequest)
+		msg := fmt.Sprintf(errMsgFmt, api+" failed: "+strings.ReplaceAll(err.Error(), `"`, `'`))
+		fmt.Fprint(w, msg)
+	}
+	data := &TapData{}
+	if err := json.Unmarshal(body, data); err != nil {
+		badRequest(err)
+		return
+	}
+
+	if data.Output != nil && data.Output.Path != "" {
+		path, err := tap.OutputPath(data.Output.Path)
+		if err != nil {
+			badRequest(err)
+			return
+		}
+		writer, err := tap.NewFileWriter(data.Output.Path, data.Output.Format)
+		if err != nil {
+			badRequest(err)
+			return
+		}
+		session, err := tap.Start(data.Config)
+		if err != nil {
+			writer.Close()
+			badRequest(err)
+			return
+		}
+		utils.GoWithRecover(func() {
+			defer writer.Close()
+			if err := session.Consume(writer.Write); err != nil {
+				log.DefaultLogger.Errorf("[admin api] [tap] write tap file %s failed: %v", path, err)
+				session.Stop()
+			}
+		}, nil)
+		auditLog(r, api, body, nil)
+		result, _ := json.Marshal(&tapFileResult{
+			Path:    path,
+			Timeout: session.Config().Timeout.Duration.String(),
+		})
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(result)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		badRequest(errors.New("streaming is not supported"))
+		return
+	}
+	writer, _ := tap.NewWriter(w, tap.FormatJSON)
+	session, err := tap.Start(data.Config)
+	if err != nil {
+		badRequest(err)
+		return
+	}
+	defer session.Stop()
+	auditLog(r, api, body, nil)
+	// stop the tap when the client closes the connection
+	utils.GoWithRecover(func() {
+		select {
+		case <-r.Context().Done():
+			session.Stop()
+		case <-session.Done():
+		}
+	}, nil)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	session.Consume(func(trace *tap.Trace) error {
+		if err := writer.Write(trace); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	})
+	if dropped := session.Dropped(); dropped > 0 {
+		log.DefaultLogger.Warnf("[admin api] [tap] %d traces are dropped because the client is slow", dropped)
+	}
+}
@@ -0,0 +1,300 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+)
+
+// the roles of the built-in admin auth
+const (
+	// RoleReadOnly can call the apis that do not change anything and do not expose the secrets
+	RoleReadOnly = "read_only"
+	// RoleAdmin can call all the apis
+	RoleAdmin = "admin"
+)
+
+var (
+	mutatingAPIsMutex sync.RWMutex
+	// mutatingAPIs need the admin role when the built-in auth is enabled
+	mutatingAPIs = map[string]bool{
+		"/api/v1/update_loglevel":           true,
+		"/api/v1/enable_log":                true,
+		"/api/v1/disable_log":               true,
+		"/api/v1/plugin":                    true,
+		"/api/v1/update_hosts":              true,
+		"/api/v1/remove_hosts":              true,
+		"/api/v1/update_host_health":        true,
+		"/api/v1/update_accesslog_sampling": true,
+		"/api/v1/update_virtual_host":       true,
+		"/api/v1/remove_virtual_host":       true,
+		"/api/v1/add_route":                 true,
+		"/api/v1/update_route":              true,
+		"/api/v1/remove_route":              true,
+		"/api/v1/tap":                       true,
+	}
+	// sensitiveAPIs do not change anything, but need the admin role because the secrets are exposed,
+	// e.g. the tls private keys and credentials in config dump, and the environment variables.
+	sensitiveAPIs = map[string]bool{
+		"/api/v1/config_dump": true,
+		"/api/v1/env":         true,
+	}
+)
+
+// RegisterMutatingAdminAPI marks the api needs the admin role when the built-in auth is enabled.
+// Notice the apis called by methods other than GET and HEAD always need the admin role.
+func RegisterMutatingAdminAPI(pattern string) {
+	mutatingAPIsMutex.Lock()
+	defer mutatingAPIsMutex.Unlock()
+	mutatingAPIs[pattern] = true
+}
+
+func requiredRole(pattern string, r *http.Request) string {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return RoleAdmin
+	}
+	mutatingAPIsMutex.RLock()
+	defer mutatingAPIsMutex.RUnlock()
+	if mutatingAPIs[pattern] || sensitiveAPIs[pattern] {
+		return RoleAdmin
+	}
+	return RoleReadOnly
+}
+
+type authUserKey struct{}
+
+// AuthUser returns the user authenticated by the built-in auth, returns empty if the auth is not enabled
+func AuthUser(r *http.Request) string {
+	user, _ := r.Context().Value(authUserKey{}).(string)
+	return user
+}
+
+type basicUser struct {
+	hash []byte
+	role string
+}
+
+// authenticator implements the built-in admin auth, a request can be authenticated
+// by the client certificate, bearer token or basic auth, the highest role is used
+// if more than one credentials are matched.
+type authenticator struct {
+	tokens      []v2.AdminToken
+	users       map[string]*basicUser
+	clientCerts []v2.AdminClientCert
+}
+
+func newAuthenticator(cfg *v2.AdminAuth) (*authenticator, error) {
+	a := &authenticator{}
+	for _, token := range cfg.Tokens {
+		if token.Token == "" {
+			return nil, errors.New("empty admin token")
+		}
+		role, err := parseRole(token.Role)
+		if err != nil {
+			return nil, err
+		}
+		a.tokens = append(a.tokens, v2.AdminToken{Token: token.Token, Role: role})
+	}
+	if cfg.BasicAuthFile != "" {
+		users, err := loadBasicAuthFile(cfg.BasicAuthFile)
+		if err != nil {
+			return nil, err
+		}
+		a.users = users
+	}
+	if len(cfg.ClientCerts) > 0 && (cfg.TLS == nil || cfg.TLS.CACert == "") {
+		return nil, errors.New("admin client certs need the tls ca cert")
+	}
+	for _, cert := range cfg.ClientCerts {
+		role, err := parseRole(cert.Role)
+		if err != nil {
+			return nil, err
+		}
+		a.clientCerts = append(a.clientCerts, v2.AdminClientCert{SANs: cert.SANs, Role: role})
+	}
+	if len(a.tokens) == 0 && len(a.users) == 0 && len(a.clientCerts) == 0 {
+		return nil, errors.New("no admin credentials configured")
+	}
+	return a, nil
+}
+
+func parseRole(role string) (string, error) {
+	switch role {
+	case "":
+		return RoleReadOnly, nil
+	case RoleReadOnly, RoleAdmin:
+		return role, nil
+	default:
+		return "", fmt.Errorf("unknown admin role: %s", role)
+	}
+}
+
+// loadBasicAuthFile loads the htpasswd file, only the bcrypt passwords are supported.
+func loadBasicAuthFile(path string) (map[string]*basicUser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	users := map[string]*basicUser{}
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.Split(line, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid line %d in basic auth file %s", lineNum, path)
+		}
+		if _, err := bcrypt.Cost([]byte(parts[1])); err != nil {
+			return nil, fmt.Errorf("invalid password in line %d in basic auth file %s, only bcrypt is supported", lineNum, path)
+		}
+		user := &basicUser{hash: []byte(parts[1]), role: RoleReadOnly}
+		if len(parts) == 3 {
+			if user.role, err = parseRole(parts[2]); err != nil {
+				return nil, err
+			}
+		}
+		users[parts[0]] = user
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// authenticate returns the user name and role of the request
+func (a *authenticator) authenticate(r *http.Request) (user string, role string, ok bool) {
+	match := func(u, rl string) {
+		if !ok || (role != RoleAdmin && rl == RoleAdmin) {
+			user, role, ok = u, rl, true
+		}
+	}
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		sans := certSANs(r.TLS.VerifiedChains[0][0])
+		for _, cert := range a.clientCerts {
+			if san, found := matchSANs(sans, cert.SANs); found {
+				match(san, cert.Role)
+			}
+		}
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token := []byte(strings.TrimPrefix(auth, "Bearer "))
+		for i, t := range a.tokens {
+			if subtle.ConstantTimeCompare(token, []byte(t.Token)) == 1 {
+				match(fmt.Sprintf("token#%d", i), t.Role)
+			}
+		}
+	} else if name, password, found := r.BasicAuth(); found && a.users != nil {
+		if u, exists := a.users[name]; exists && bcrypt.CompareHashAndPassword(u.hash, []byte(password)) == nil {
+			match(name, u.role)
+		}
+	}
+	return
+}
+
+func certSANs(cert *x509.Certificate) []string {
+	sans := make([]string, 0, len(cert.DNSNames)+len(cert.URIs)+len(cert.EmailAddresses)+len(cert.IPAddresses))
+	sans = append(sans, cert.DNSNames...)
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	return sans
+}
+
+func matchSANs(sans []string, allowed []string) (string, bool) {
+	for _, san := range sans {
+		for _, a := range allowed {
+			if san == a {
+				return san, true
+			}
+		}
+	}
+	return "", false
+}
+
+// wrap returns a handler checks the request before calling the api
+func (a *authenticator) wrap(pattern string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, role, ok := a.authenticate(r)
+		if !ok {
+			log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: unauthenticated request from %s", pattern, r.RemoteAddr)
+			if a.users != nil {
+				w.Header().Set("WWW-Authenticate", `Basic realm="mosn admin"`)
+			}
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, errMsgFmt, "unauthorized")
+			return
+		}
+		if required := requiredRole(pattern, r); required == RoleAdmin && role != RoleAdmin {
+			log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: user %s with role %s is not allowed, remote: %s", pattern, user, role, r.RemoteAddr)
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprintf(w, errMsgFmt, "forbidden")
+			return
+		}
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authUserKey{}, user)))
+	})
+}
+
+// newAdminTLSConfig creates the tls config of the admin server, the client certificates
+// are verified if the ca cert is configured, and the requests without client certificates
+// can be authenticated by the other credentials.
+func newAdminTLSConfig(cfg *v2.AdminTLS) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.CertChain, cfg.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if cfg.CACert != "" {
+		ca, err := ioutil.ReadFile(cfg.CACert)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("invalid ca cert: %s", cfg.CACert)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return config, nil
+}
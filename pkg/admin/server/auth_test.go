@@ -0,0 +1,391 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"mosn.io/mosn/pkg/admin/store"
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
+
+type mockAdminConfig struct {
+	admin *v2.Admin
+}
+
+func (m *mockAdminConfig) GetAdmin() *v2.Admin {
+	return m.admin
+}
+
+func writeBasicAuthFile(t *testing.T, lines ...string) string {
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	if err := ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func bcryptPassword(t *testing.T, password string) string {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(hash)
+}
+
+func TestNewAuthenticator(t *testing.T) {
+	for _, cfg := range []*v2.AdminAuth{
+		{},
+		{Tokens: []v2.AdminToken{{Token: ""}}},
+		{Tokens: []v2.AdminToken{{Token: "token", Role: "unknown"}}},
+		{ClientCerts: []v2.AdminClientCert{{SANs: []string{"client"}}}},
+		{BasicAuthFile: "/not/exists"},
+		{BasicAuthFile: writeBasicAuthFile(t, "user:plain_password")},
+		{BasicAuthFile: writeBasicAuthFile(t, "user:"+bcryptPassword(t, "password")+":unknown")},
+	} {
+		if _, err := newAuthenticator(cfg); err == nil {
+			t.Fatalf("config %+v should be invalid", cfg)
+		}
+	}
+}
+
+func TestAuthenticatorWrap(t *testing.T) {
+	auth, err := newAuthenticator(&v2.AdminAuth{
+		Tokens: []v2.AdminToken{
+			{Token: "read_token"},
+			{Token: "admin_token", Role: RoleAdmin},
+		},
+		BasicAuthFile: writeBasicAuthFile(t,
+			"# comment",
+			"reader:"+bcryptPassword(t, "reader_password"),
+			"admin:"+bcryptPassword(t, "admin_password")+":admin",
+		),
+	})
+	if err != nil {
+		t.Fatalf("create authenticator failed: %v", err)
+	}
+	var user string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user = AuthUser(r)
+	})
+	for _, tc := range []struct {
+		name         string
+		pattern      string
+		method       string
+		setAuth      func(r *http.Request)
+		expectedCode int
+		expectedUser string
+	}{
+		{"no credential", "/api/v1/stats", http.MethodGet, func(r *http.Request) {}, http.StatusUnauthorized, ""},
+		{"wrong token", "/api/v1/stats", http.MethodGet, func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer wrong")
+		}, http.StatusUnauthorized, ""},
+		{"wrong password", "/api/v1/stats", http.MethodGet, func(r *http.Request) {
+			r.SetBasicAuth("reader", "wrong")
+		}, http.StatusUnauthorized, ""},
+		{"read only token", "/api/v1/stats", http.MethodGet, func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer read_token")
+		}, http.StatusOK, "token#0"},
+		{"read only token post", "/api/v1/update_loglevel", http.MethodPost, func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer read_token")
+		}, http.StatusForbidden, ""},
+		{"read only token mutating get", "/api/v1/plugin", http.MethodGet, func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer read_token")
+		}, http.StatusForbidden, ""},
+		{"admin token post", "/api/v1/update_loglevel", http.MethodPost, func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer admin_token")
+		}, http.StatusOK, "token#1"},
+		{"read only user", "/api/v1/stats", http.MethodGet, func(r *http.Request) {
+			r.SetBasicAuth("reader", "reader_password")
+		}, http.StatusOK, "reader"},
+		{"read only user sensitive get", "/api/v1/config_dump", http.MethodGet, func(r *http.Request) {
+			r.SetBasicAuth("reader", "reader_password")
+		}, http.StatusForbidden, ""},
+		{"read only user post", "/api/v1/update_hosts", http.MethodPost, func(r *http.Request) {
+			r.SetBasicAuth("reader", "reader_password")
+		}, http.StatusForbidden, ""},
+		{"admin user post", "/api/v1/update_hosts", http.MethodPost, func(r *http.Request) {
+			r.SetBasicAuth("admin", "admin_password")
+		}, http.StatusOK, "admin"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			user = ""
+			r := httptest.NewRequest(tc.method, "http://127.0.0.1"+tc.pattern, nil)
+			tc.setAuth(r)
+			w := httptest.NewRecorder()
+			auth.wrap(tc.pattern, handler).ServeHTTP(w, r)
+			if w.Code != tc.expectedCode || user != tc.expectedUser {
+				t.Fatalf("expected %d and user %q, but got %d and user %q", tc.expectedCode, tc.expectedUser, w.Code, user)
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Fatal("basic auth challenge is not set")
+			}
+		})
+	}
+}
+
+func TestRegisterMutatingAdminAPI(t *testing.T) {
+	pattern := "/api/test/mutating"
+	r := httptest.NewRequest(http.MethodGet, "http://127.0.0.1"+pattern, nil)
+	if role := requiredRole(pattern, r); role != RoleReadOnly {
+		t.Fatalf("unexpected role: %s", role)
+	}
+	RegisterMutatingAdminAPI(pattern)
+	defer func() {
+		mutatingAPIsMutex.Lock()
+		delete(mutatingAPIs, pattern)
+		mutatingAPIsMutex.Unlock()
+	}()
+	if role := requiredRole(pattern, r); role != RoleAdmin {
+		t.Fatalf("unexpected role: %s", role)
+	}
+}
+
+func TestRequiredRole(t *testing.T) {
+	for pattern, expected := range map[string]string{
+		"/api/v1/stats":        RoleReadOnly,
+		"/api/v1/clusters":     RoleReadOnly,
+		"/api/v1/config_dump":  RoleAdmin,
+		"/api/v1/env":          RoleAdmin,
+		"/api/v1/update_route": RoleAdmin,
+		"/api/v1/tap":          RoleAdmin,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "http://127.0.0.1"+pattern, nil)
+		if role := requiredRole(pattern, r); role != expected {
+			t.Fatalf("%s expected role %s, but got %s", pattern, expected, role)
+		}
+	}
+}
+
+func TestAdminUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "admin.sock")
+	server := Server{}
+	server.Start(&mockAdminConfig{admin: &v2.Admin{
+		UnixSocket: &v2.AdminUnixSocket{
+			Path: path,
+			Mode: "0660",
+		},
+		Auth: &v2.AdminAuth{
+			Tokens: []v2.AdminToken{{Token: "token"}},
+		},
+	}})
+	if err := store.StartService(nil); err != nil {
+		t.Fatalf("start service failed: %v", err)
+	}
+	defer store.CloseService()
+
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0660 {
+		t.Fatalf("unexpected socket file: %v, %v", info, err)
+	}
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", path)
+			},
+		},
+	}
+	for token, expectedCode := range map[string]int{
+		"":      http.StatusUnauthorized,
+		"token": http.StatusOK,
+	} {
+		r, _ := http.NewRequest(http.MethodGet, "http://unix/api/v1/states", nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := client.Do(r)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != expectedCode {
+			t.Fatalf("expected status %d, but got %d", expectedCode, resp.StatusCode)
+		}
+	}
+}
+
+type testCerts struct {
+	caFile, certFile, keyFile string
+	caPool                    *x509.CertPool
+	client                    tls.Certificate
+	unknownClient             tls.Certificate
+}
+
+func newTestCerts(t *testing.T) *testCerts {
+	dir := t.TempDir()
+	newKey := func() *ecdsa.PrivateKey {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	caKey := newKey()
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+	issue := func(serial int64, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, template *x509.Certificate) ([]byte, *ecdsa.PrivateKey) {
+		key := newKey()
+		template.SerialNumber = big.NewInt(serial)
+		template.NotBefore = time.Now().Add(-time.Hour)
+		template.NotAfter = time.Now().Add(time.Hour)
+		template.KeyUsage = x509.KeyUsageDigitalSignature
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der, key
+	}
+	toTLSCert := func(der []byte, key *ecdsa.PrivateKey) tls.Certificate {
+		return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	}
+	writePEM := func(name, typ string, der []byte) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	certs := &testCerts{caPool: x509.NewCertPool()}
+	certs.caPool.AddCert(ca)
+	certs.caFile = writePEM("ca.pem", "CERTIFICATE", caDER)
+
+	serverDER, serverKey := issue(2, ca, caKey, &x509.Certificate{
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	keyDER, _ := x509.MarshalECPrivateKey(serverKey)
+	certs.certFile = writePEM("cert.pem", "CERTIFICATE", serverDER)
+	certs.keyFile = writePEM("key.pem", "EC PRIVATE KEY", keyDER)
+
+	spiffe, _ := url.Parse("spiffe://cluster.local/ns/default/sa/admin")
+	clientDER, clientKey := issue(3, ca, caKey, &x509.Certificate{
+		URIs:        []*url.URL{spiffe},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	certs.client = toTLSCert(clientDER, clientKey)
+	unknownDER, unknownKey := issue(4, ca, caKey, &x509.Certificate{
+		DNSNames:    []string{"unknown.client"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	certs.unknownClient = toTLSCert(unknownDER, unknownKey)
+	return certs
+}
+
+func TestAdminMutualTLS(t *testing.T) {
+	certs := newTestCerts(t)
+	server := Server{}
+	server.Start(&mockAdminConfig{admin: &v2.Admin{
+		Address: &v2.AddressInfo{
+			SocketAddress: v2.SocketAddress{
+				Address:   "127.0.0.1",
+				PortValue: 8890,
+			},
+		},
+		Auth: &v2.AdminAuth{
+			Tokens: []v2.AdminToken{{Token: "token"}},
+			TLS: &v2.AdminTLS{
+				CertChain:  certs.certFile,
+				PrivateKey: certs.keyFile,
+				CACert:     certs.caFile,
+			},
+			ClientCerts: []v2.AdminClientCert{
+				{
+					SANs: []string{"spiffe://cluster.local/ns/default/sa/admin"},
+					Role: RoleAdmin,
+				},
+			},
+		},
+	}})
+	if err := store.StartService(nil); err != nil {
+		t.Fatalf("start service failed: %v", err)
+	}
+	defer store.CloseService()
+	time.Sleep(100 * time.Millisecond)
+
+	newClient := func(certificates ...tls.Certificate) *http.Client {
+		return &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					RootCAs:      certs.caPool,
+					Certificates: certificates,
+				},
+			},
+		}
+	}
+	for _, tc := range []struct {
+		name         string
+		client       *http.Client
+		token        string
+		expectedCode int
+	}{
+		{"no client cert", newClient(), "", http.StatusUnauthorized},
+		{"token without client cert", newClient(), "token", http.StatusForbidden},
+		{"unknown client cert", newClient(certs.unknownClient), "", http.StatusUnauthorized},
+		{"admin client cert", newClient(certs.client), "", http.StatusOK},
+		// the highest role is used
+		{"admin client cert with token", newClient(certs.client), "token", http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, "https://127.0.0.1:8890/api/v1/plugin?status=all", nil)
+			if tc.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			resp, err := tc.client.Do(r)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tc.expectedCode {
+				t.Fatalf("expected status %d, but got %d", tc.expectedCode, resp.StatusCode)
+			}
+		})
+	}
+}
@@ -18,12 +18,17 @@
 package server
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net/http"
+	"os"
+	"strconv"
 
 	jsoniter "github.com/json-iterator/go"
 	"mosn.io/mosn/pkg/admin/store"
+	v2 "mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/tap"
 )
 
 var json = jsoniter.ConfigCompatibleWithStandardLibrary
@@ -53,19 +58,30 @@ func DeleteRegisteredAdminHandler(pattern string) {
 func init() {
 	// default admin api
 	apiHandlerStore = map[string]*APIHandler{
-		"/api/v1/version":         NewAPIHandler(OutputVersion),
-		"/api/v1/config_dump":     NewAPIHandler(ConfigDump),
-		"/api/v1/stats":           NewAPIHandler(StatsDump),
-		"/api/v1/stats_glob":      NewAPIHandler(StatsDumpProxyTotal),
-		"/api/v1/update_loglevel": NewAPIHandler(UpdateLogLevel),
-		"/api/v1/get_loglevel":    NewAPIHandler(GetLoggerInfo),
-		"/api/v1/enable_log":      NewAPIHandler(EnableLogger),
-		"/api/v1/disable_log":     NewAPIHandler(DisableLogger),
-		"/api/v1/states":          NewAPIHandler(GetState),
-		"/api/v1/plugin":          NewAPIHandler(PluginApi),
-		"/api/v1/features":        NewAPIHandler(KnownFeatures),
-		"/api/v1/env":             NewAPIHandler(GetEnv),
-		"/":                       NewAPIHandler(Help),
+		"/api/v1/version":                   NewAPIHandler(OutputVersion),
+		"/api/v1/config_dump":               NewAPIHandler(ConfigDump),
+		"/api/v1/stats":                     NewAPIHandler(StatsDump),
+		"/api/v1/stats_glob":                NewAPIHandler(StatsDumpProxyTotal),
+		"/api/v1/update_loglevel":           NewAPIHandler(UpdateLogLevel),
+		"/api/v1/get_loglevel":              NewAPIHandler(GetLoggerInfo),
+		"/api/v1/enable_log":                NewAPIHandler(EnableLogger),
+		"/api/v1/disable_log":               NewAPIHandler(DisableLogger),
+		"/api/v1/states":                    NewAPIHandler(GetState),
+		"/api/v1/plugin":                    NewAPIHandler(PluginApi),
+		"/api/v1/features":                  NewAPIHandler(KnownFeatures),
+		"/api/v1/env":                       NewAPIHandler(GetEnv),
+		"/api/v1/clusters":                  NewAPIHandler(ClustersStatus),
+		"/api/v1/update_hosts":              NewAPIHandler(UpdateHosts),
+		"/api/v1/remove_hosts":              NewAPIHandler(RemoveHosts),
+		"/api/v1/update_host_health":        NewAPIHandler(UpdateHostHealth),
+		"/api/v1/update_accesslog_sampling": NewAPIHandler(UpdateAccessLogSampling),
+		"/api/v1/update_virtual_host":       NewAPIHandler(UpdateVirtualHost),
+		"/api/v1/remove_virtual_host":       NewAPIHandler(RemoveVirtualHost),
+		"/api/v1/add_route":                 NewAPIHandler(AddRoute),
+		"/api/v1/update_route":              NewAPIHandler(UpdateRoute),
+		"/api/v1/remove_route":              NewAPIHandler(RemoveRoute),
+		"/api/v1/tap":                       NewAPIHandler(Tap),
+		"/":                                 NewAPIHandler(Help),
 	}
 }
 
@@ -75,23 +91,71 @@ type Server struct {
 
 func (s *Server) Start(config Config) {
 	var addr string
+	var adminConfig *v2.Admin
 	if config != nil {
 		// get admin config
-		adminConfig := config.GetAdmin()
+		adminConfig = config.GetAdmin()
 		if adminConfig == nil {
 			// no admin config, no admin start
 			log.DefaultLogger.Warnf("no admin config, no admin api served")
 			return
 		}
 		addr = fmt.Sprintf("%s:%d", adminConfig.GetAddress(), adminConfig.GetPortValue())
+		tap.SetOutputDir(adminConfig.TapDir)
+		if err := setAuditLog(adminConfig.AuditLogPath); err != nil {
+			log.DefaultLogger.Errorf("[admin server] invalid audit log path, no admin api served: %v", err)
+			return
+		}
+	}
+
+	var auth *authenticator
+	var tlsConfig *tls.Config
+	if adminConfig != nil && adminConfig.Auth != nil {
+		var err error
+		if auth, err = newAuthenticator(adminConfig.Auth); err != nil {
+			log.DefaultLogger.Errorf("[admin server] invalid admin auth config, no admin api served: %v", err)
+			return
+		}
+		if adminConfig.Auth.TLS != nil {
+			if tlsConfig, err = newAdminTLSConfig(adminConfig.Auth.TLS); err != nil {
+				log.DefaultLogger.Errorf("[admin server] invalid admin tls config, no admin api served: %v", err)
+				return
+			}
+		}
 	}
 
 	mux := http.NewServeMux()
 	for pattern, handler := range apiHandlerStore {
-		mux.Handle(pattern, handler)
+		if auth != nil {
+			mux.Handle(pattern, auth.wrap(pattern, handler))
+		} else {
+			mux.Handle(pattern, handler)
+		}
 	}
 
-	srv := &http.Server{Addr: addr, Handler: mux}
-	store.AddService(srv, "Mosn Admin Server", nil, nil)
+	srv := &http.Server{Addr: addr, Handler: mux, TLSConfig: tlsConfig}
+	if adminConfig != nil && adminConfig.UnixSocket != nil {
+		mode, err := parseSocketMode(adminConfig.UnixSocket.Mode)
+		if err != nil {
+			log.DefaultLogger.Errorf("[admin server] invalid admin unix socket mode, no admin api served: %v", err)
+			return
+		}
+		srv.Addr = adminConfig.UnixSocket.Path
+		store.AddUnixService(srv, "Mosn Admin Server", mode, nil, nil)
+	} else {
+		store.AddService(srv, "Mosn Admin Server", nil, nil)
+	}
 	s.Server = srv
 }
+
+// parseSocketMode parses the octal file permissions, the default is 0600
+func parseSocketMode(mode string) (os.FileMode, error) {
+	if mode == "" {
+		return 0600, nil
+	}
+	m, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || m > 0777 {
+		return 0, fmt.Errorf("invalid mode: %s", mode)
+	}
+	return os.FileMode(m), nil
+}
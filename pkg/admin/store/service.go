@@ -24,6 +24,7 @@ import (
 	"net/http"
 	"os"
 	"sync"
+	"syscall"
 
 	"mosn.io/mosn/pkg/log"
 	"mosn.io/mosn/pkg/metrics"
@@ -38,6 +39,9 @@ type service struct {
 	name string
 	init func()
 	exit func()
+	// unix means the Addr is a unix domain socket path, which is created with the mode
+	unix bool
+	mode os.FileMode
 }
 
 var services []*service
@@ -51,14 +55,16 @@ func ListServiceListenersFile() ([]*os.File, error) {
 	files := make([]*os.File, len(listeners))
 
 	for i, l := range listeners {
-		var ok bool
-		var tl *net.TCPListener
-		if tl, ok = l.(*net.TCPListener); !ok {
+		// both the tcp and unix listeners are supported
+		tl, ok := l.(interface {
+			File() (*os.File, error)
+		})
+		if !ok {
 			return nil, errors.New("listener type is error")
 		}
 		file, err := tl.File()
 		if err != nil {
-			log.DefaultLogger.Errorf("[admin store] [list listener files] fail to get listener %s file descriptor: %v", tl.Addr().String(), err)
+			log.DefaultLogger.Errorf("[admin store] [list listener files] fail to get listener %s file descriptor: %v", l.Addr().String(), err)
 			return nil, errors.New("fail to get listener fd") //stop reconfigure
 		}
 		files[i] = file
@@ -67,17 +73,27 @@ func ListServiceListenersFile() ([]*os.File, error) {
 }
 
 func AddService(s *http.Server, name string, init func(), exit func()) {
+	addService(&service{Server: s, name: name, init: init, exit: exit})
+}
+
+// AddUnixService adds a service listening on the unix domain socket, the Addr of
+// the server is the socket path, and the socket file is created with the mode.
+func AddUnixService(s *http.Server, name string, mode os.FileMode, init func(), exit func()) {
+	addService(&service{Server: s, name: name, init: init, exit: exit, unix: true, mode: mode})
+}
+
+func addService(s *service) {
 	lock.Lock()
 	defer lock.Unlock()
 	for i, srv := range services {
 		if srv.Addr == s.Addr {
-			services[i] = &service{false, s, name, init, exit}
-			log.DefaultLogger.Infof("[admin store] [add service] update server %s", name)
+			services[i] = s
+			log.DefaultLogger.Infof("[admin store] [add service] update server %s", s.name)
 			return
 		}
 	}
-	services = append(services, &service{false, s, name, init, exit})
-	log.DefaultLogger.Infof("[admin store] [add service] add server %s", name)
+	services = append(services, s)
+	log.DefaultLogger.Infof("[admin store] [add service] add server %s", s.name)
 }
 
 func StartService(inheritListeners []net.Listener) error {
@@ -90,13 +106,23 @@ func StartService(inheritListeners []net.Listener) error {
 		var saddr *net.TCPAddr
 
 		s := srv
-		saddr, err = net.ResolveTCPAddr("tcp", s.Addr)
-		if err != nil {
-			log.StartLogger.Fatalf("[admin store] [start service] [inheritListener] not valid: %v", s.Addr)
+		if s.unix {
+			ln, err = listenUnix(s, inheritListeners)
+			if err != nil {
+				return err
+			}
+		} else {
+			saddr, err = net.ResolveTCPAddr("tcp", s.Addr)
+			if err != nil {
+				log.StartLogger.Fatalf("[admin store] [start service] [inheritListener] not valid: %v", s.Addr)
+			}
 		}
 
 		for i, l := range inheritListeners {
-			if l == nil {
+			if l == nil || ln != nil {
+				continue
+			}
+			if _, ok := l.Addr().(*net.TCPAddr); !ok {
 				continue
 			}
 			addr, err := net.ResolveTCPAddr("tcp", l.Addr().String())
@@ -129,7 +155,13 @@ func StartService(inheritListeners []net.Listener) error {
 			metrics.AddListenerAddr(s.Addr)
 			log.StartLogger.Infof("[admin store] [start service] start service %s on %s", s.name, ln.Addr().String())
 
-			err := s.Serve(ln)
+			var err error
+			if s.TLSConfig != nil {
+				// the certificates are set in the tls config
+				err = s.ServeTLS(ln, "", "")
+			} else {
+				err = s.Serve(ln)
+			}
 			if err != nil {
 				log.StartLogger.Warnf("[admin store] [start service] start serve failed : %s %s %s", s.name, ln.Addr().String(), err.Error())
 			}
@@ -139,6 +171,44 @@ func StartService(inheritListeners []net.Listener) error {
 	return nil
 }
 
+// listenUnix inherits the listener with the same socket path, or creates a new unix domain socket.
+func listenUnix(s *service, inheritListeners []net.Listener) (net.Listener, error) {
+	for i, l := range inheritListeners {
+		if l == nil {
+			continue
+		}
+		if addr, ok := l.Addr().(*net.UnixAddr); ok && addr.Name == s.Addr {
+			inheritListeners[i] = nil
+			log.StartLogger.Infof("[admin store] [start service] [inheritListener] inherit listener addr: %s", s.Addr)
+			return l, nil
+		}
+	}
+	// remove the socket file left by the last process
+	if err := os.Remove(s.Addr); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	ln, err := listenUnixWithMode(s.Addr, s.mode)
+	if err != nil {
+		return nil, err
+	}
+	// keep the socket file for the inheritance when the listener is closed by the old process
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	return ln, nil
+}
+
+// umaskMutex serializes the umask changes, the umask is shared by the process
+var umaskMutex sync.Mutex
+
+// listenUnixWithMode creates the socket file with the mode directly by the umask,
+// chmod after listen leaves a window that the socket can be connected with the default mode.
+func listenUnixWithMode(path string, mode os.FileMode) (net.Listener, error) {
+	umaskMutex.Lock()
+	defer umaskMutex.Unlock()
+	old := syscall.Umask(int(^mode.Perm() & os.ModePerm))
+	defer syscall.Umask(old)
+	return net.Listen("unix", path)
+}
+
 func StopService() {
 	cleanSerivce(func(s *service) {
 		utils.GoWithRecover(func() {
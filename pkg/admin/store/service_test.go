@@ -21,6 +21,9 @@ import (
 	"context"
 	"net"
 	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -132,6 +135,71 @@ func TestServiceInherit(t *testing.T) {
 
 }
 
+func unixClient(path string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", path)
+			},
+		},
+	}
+}
+
+func TestUnixService(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "admin.sock")
+	// the stale socket file is removed
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := syscall.Umask(0022)
+	defer syscall.Umask(old)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", handler1)
+	AddUnixService(&http.Server{Addr: path, Handler: mux}, "unix", 0640, nil, nil)
+	if err := StartService(nil); err != nil {
+		t.Fatalf("TestUnixService StartService error: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0640 {
+		t.Fatalf("TestUnixService unexpected socket file: %v, %v", info, err)
+	}
+	// the umask is restored after the socket is created
+	if umask := syscall.Umask(0022); umask != 0022 {
+		t.Fatalf("TestUnixService umask is not restored: %o", umask)
+	}
+	time.Sleep(100 * time.Millisecond)
+	rsp, err := unixClient(path).Get("http://unix/")
+	if err != nil || rsp.StatusCode != 200 {
+		t.Fatalf("TestUnixService http.Get error: %v", err)
+	}
+	rsp.Body.Close()
+	files, err := ListServiceListenersFile()
+	if len(files) != 1 {
+		t.Fatalf("TestUnixService ListServiceListenersFile() error: %v", err)
+	}
+	ll, err := net.FileListener(files[0])
+	files[0].Close()
+	if err != nil {
+		t.Fatalf("TestUnixService net.FileListener error: %v", err)
+	}
+	CloseService()
+
+	// inherit the unix listener
+	mux2 := http.NewServeMux()
+	mux2.HandleFunc("/", handler2)
+	AddUnixService(&http.Server{Addr: path, Handler: mux2}, "unix", 0640, nil, nil)
+	if err := StartService([]net.Listener{ll}); err != nil {
+		t.Fatalf("TestUnixService StartService error: %v", err)
+	}
+	defer CloseService()
+	time.Sleep(100 * time.Millisecond)
+	rsp, err = unixClient(path).Get("http://unix/")
+	if err != nil || rsp.StatusCode != 201 {
+		t.Fatalf("TestUnixService http.Get inherited error: %v", err)
+	}
+	rsp.Body.Close()
+}
+
 func handler1(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(200)
 }
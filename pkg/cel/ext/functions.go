@@ -70,6 +70,8 @@ func init() {
 	check(RegisterSimpleFunctionBoth("add_response_header", addResponseheader))
 	// del response header
 	check(RegisterSimpleFunctionBoth("del_response_header", delResponseheader))
+	// get variable
+	check(RegisterSimpleFunctionBoth("get_variable", getVariable))
 
 }
 
@@ -59,3 +59,13 @@ func delRequestheader(ctx context.Context, key string) bool {
 	headers.Del(key)
 	return true
 }
+
+// getVariable returns the value of the variable, such as the arguments of the RPC requests,
+// an empty string is returned if the variable is not found.
+func getVariable(ctx context.Context, name string) string {
+	value, err := variable.GetString(ctx, name)
+	if err != nil {
+		return ""
+	}
+	return value
+}
@@ -29,6 +29,7 @@ import (
 	"mosn.io/mosn/pkg/cel/attribute"
 	"mosn.io/mosn/pkg/cel/ext"
 	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/pkg/variable"
 )
 
 func TestMosnCtx(t *testing.T) {
@@ -51,6 +52,36 @@ func TestMosnCtx(t *testing.T) {
 	t.Log(out)
 }
 
+func TestMosnCtxVariable(t *testing.T) {
+	variable.Register(variable.NewStringVariable("cel_test_variable", nil, nil, variable.DefaultStringSetter, 0))
+	compiler := NewExpressionBuilder(map[string]attribute.Kind{
+		"ctx": attribute.MOSN_CTX,
+	}, CompatCEXL)
+	expression, _, err := compiler.Compile(`ctx.get_variable("cel_test_variable") == "value"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := variable.NewVariableContext(context.Background())
+	bag := attribute.NewMutableBag(nil)
+	bag.Set("ctx", ctx)
+	out, err := expression.Evaluate(bag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != false {
+		t.Fatalf("the variable is not set, but got %v", out)
+	}
+
+	_ = variable.SetString(ctx, "cel_test_variable", "value")
+	out, err = expression.Evaluate(bag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != true {
+		t.Fatalf("the variable is set, but got %v", out)
+	}
+}
+
 func TestSample(t *testing.T) {
 	compiler := NewExpressionBuilder(map[string]attribute.Kind{
 		"source.header": attribute.STRING_MAP,
@@ -74,6 +74,14 @@ type MetricsConfig struct {
 	LazyFlush    bool              `json:"lazy_flush"`
 	SampleConfig SampleConfig      `json:"sample"`
 	EWMAConfig   *EWMAConfig       `json:"ewma,omitempty"`
+	Histogram    *HistogramConfig  `json:"histogram,omitempty"`
+}
+
+// HistogramConfig for metrics histogram fixed buckets,
+// the bucket bounds use the same unit as the histogram values, e.g. nanoseconds for request time.
+type HistogramConfig struct {
+	Buckets    []float64            `json:"buckets,omitempty"`     // default buckets for all histograms
+	KeyBuckets map[string][]float64 `json:"key_buckets,omitempty"` // buckets for the specified metrics key
 }
 
 // SampleConfig for metrics histogram
@@ -162,6 +170,55 @@ func (c *MOSNConfig) Mode() Mode {
 
 type Admin struct {
 	Address *AddressInfo `json:"address,omitempty"`
+	// UnixSocket binds the admin server to a unix domain socket instead of the address
+	UnixSocket *AdminUnixSocket `json:"unix_socket,omitempty"`
+	// Auth enables the built-in authentication of the admin api
+	Auth *AdminAuth `json:"auth,omitempty"`
+	// TapDir is the directory of the tap output files, the tap api can not write files if it is empty
+	TapDir string `json:"tap_dir,omitempty"`
+	// AuditLogPath is the file of the audit logs written by the apis that change the runtime state,
+	// the audit logs are written to the error log with an alert key if it is empty
+	AuditLogPath string `json:"audit_log_path,omitempty"`
+}
+
+type AdminUnixSocket struct {
+	Path string `json:"path,omitempty"`
+	// Mode is the file permissions of the socket in octal, such as "0660", default is "0600"
+	Mode string `json:"mode,omitempty"`
+}
+
+// AdminAuth contains the credentials can access the admin api, a request is allowed
+// if any credential is matched and the role of the credential can access the api.
+type AdminAuth struct {
+	Tokens []AdminToken `json:"tokens,omitempty"`
+	// BasicAuthFile is a htpasswd file with bcrypt passwords, the role can be appended to
+	// each line, such as "user:$2y$05$...:admin", the default role is read_only.
+	BasicAuthFile string `json:"basic_auth_file,omitempty"`
+	// TLS serves the admin api by https, the client certificate is verified
+	// if the ca cert is configured.
+	TLS *AdminTLS `json:"tls,omitempty"`
+	// ClientCerts maps the SANs of the verified client certificates to roles
+	ClientCerts []AdminClientCert `json:"client_certs,omitempty"`
+}
+
+// AdminToken is a static bearer token
+type AdminToken struct {
+	Token string `json:"token,omitempty"`
+	Role  string `json:"role,omitempty"`
+}
+
+// AdminTLS contains the file paths of the certificates
+type AdminTLS struct {
+	CertChain  string `json:"cert_chain,omitempty"`
+	PrivateKey string `json:"private_key,omitempty"`
+	CACert     string `json:"ca_cert,omitempty"`
+}
+
+// AdminClientCert matches the client certificate that contains any of the SANs,
+// the SANs can be dns names, uris, email addresses and ip addresses.
+type AdminClientCert struct {
+	SANs []string `json:"sans,omitempty"`
+	Role string   `json:"role,omitempty"`
 }
 
 func (admin *Admin) GetAddress() string {
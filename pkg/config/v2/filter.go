@@ -59,7 +59,8 @@ type StreamDSL struct {
 
 // Listener Filter's Type
 const (
-	ORIGINALDST_LISTENER_FILTER = "original_dst"
+	ORIGINALDST_LISTENER_FILTER   = "original_dst"
+	TLS_INSPECTOR_LISTENER_FILTER = "tls_inspector"
 )
 
 type FaultToleranceFilterConfig struct {
@@ -86,6 +87,11 @@ const (
 	Transcoder                  = "transcoder"
 	GRPC_NETWORK_FILTER         = "grpc"
 	TUNNEL                      = "tunnel"
+	REDIS_PROXY                 = "redis_proxy"
+	MQTT_PROXY                  = "mqtt_proxy"
+	MYSQL_PROXY                 = "mysql_proxy"
+	KAFKA_PROXY                 = "kafka_proxy"
+	RBAC_NETWORK_FILTER         = "rbac"
 )
 
 // Stream Filter's Type
@@ -100,6 +106,7 @@ const (
 	GoPluginStreamFilterSuffix = "so_plugin"
 	GrpcMetricFilter           = "grpc_metric"
 	IPAccess                   = "ip_access"
+	RBACStream                 = "rbac"
 )
 
 // HealthCheckFilter
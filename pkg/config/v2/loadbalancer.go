@@ -43,6 +43,7 @@ type HashPolicy struct {
 	Header   *HeaderHashPolicy   `json:"header,omitempty"`
 	Cookie   *CookieHashPolicy   `json:"cookie,omitempty"`
 	SourceIP *SourceIPHashPolicy `json:"source_ip,omitempty"`
+	Variable *VariableHashPolicy `json:"variable,omitempty"`
 }
 
 type HeaderHashPolicy struct {
@@ -57,3 +58,8 @@ type CookieHashPolicy struct {
 
 type SourceIPHashPolicy struct {
 }
+
+// VariableHashPolicy generates the hash by the value of the variable, such as the RPC arguments
+type VariableHashPolicy struct {
+	Name string `json:"name,omitempty"`
+}
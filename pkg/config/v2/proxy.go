@@ -17,7 +17,11 @@
 
 package v2
 
-import "time"
+import (
+	"time"
+
+	"mosn.io/api"
+)
 
 // StreamProxy
 type StreamProxy struct {
@@ -50,4 +54,115 @@ type Proxy struct {
 	// concurrency num = worker num in worker pool per connection
 	// if concurrency num == 0, use global worker pool
 	ConcurrencyNum int `json:"concurrency_num,omitempty"`
+
+	// proxy level stats config, the virtual host and route level
+	// metrics are disabled by default to avoid high label cardinality
+	StatsConfig *ProxyStatsConfig `json:"stats_config,omitempty"`
+}
+
+// ProxyStatsConfig enables the virtual host and route level metrics, the route label is
+// the route name, the routes without name are counted as "unnamed"
+type ProxyStatsConfig struct {
+	VirtualHost bool `json:"virtual_host,omitempty"`
+	Route       bool `json:"route,omitempty"`
+}
+
+// RedisProxy is the config of the redis proxy network filter
+type RedisProxy struct {
+	StatPrefix string `json:"stat_prefix,omitempty"`
+	// Cluster is the default cluster of the keys not matched by any prefix route
+	Cluster      string              `json:"cluster,omitempty"`
+	PrefixRoutes []*RedisPrefixRoute `json:"prefix_routes,omitempty"`
+	// CaseInsensitive matches the key prefixes case insensitive
+	CaseInsensitive bool `json:"case_insensitive,omitempty"`
+}
+
+// RedisPrefixRoute routes the keys with the prefix to the cluster, the longest prefix wins
+type RedisPrefixRoute struct {
+	Prefix  string `json:"prefix,omitempty"`
+	Cluster string `json:"cluster,omitempty"`
+	// RemovePrefix removes the prefix from the keys sent to the cluster
+	RemovePrefix bool `json:"remove_prefix,omitempty"`
+}
+
+// MqttProxy is the config of the mqtt proxy network filter
+type MqttProxy struct {
+	StatPrefix string `json:"stat_prefix,omitempty"`
+	// Cluster is the default cluster of the connections not matched by any route
+	Cluster string       `json:"cluster,omitempty"`
+	Routes  []*MqttRoute `json:"routes,omitempty"`
+	// ACLs are the topic access rules, the first rule matches the connection and the topic decides
+	ACLs []*MqttTopicACL `json:"acls,omitempty"`
+	// DenyByDefault denies the topics not matched by any ACL, the topics are allowed by default
+	DenyByDefault bool `json:"deny_by_default,omitempty"`
+	// TopicStatsDepth limits the topic levels in the topic metrics, such as a/b/# for 2,
+	// the default is 2, and the full topic is used if it is negative
+	TopicStatsDepth int `json:"topic_stats_depth,omitempty"`
+	// MaxTopicStats limits the distinct topics in the topic metrics of the stat prefix, the default is 100,
+	// the topics exceeding the limit are counted in the "#other" topic, which is not a valid topic name
+	MaxTopicStats int `json:"max_topic_stats,omitempty"`
+	// MaxPacketSize is the max size of the packets, the default is the max size allowed by the protocol
+	MaxPacketSize int `json:"max_packet_size,omitempty"`
+}
+
+// MqttRoute routes the connections by the client id and the username of the CONNECT packet,
+// the first matched route wins.
+type MqttRoute struct {
+	// ClientID is a regular expression matching the whole client id, it matches any client id if it is empty
+	ClientID string `json:"client_id,omitempty"`
+	// Username is a regular expression matching the whole username, it matches any username if it is empty
+	Username string `json:"username,omitempty"`
+	Cluster  string `json:"cluster,omitempty"`
+}
+
+// MqttTopicACL allows or denies the connections matched by the client id and the username to
+// publish or subscribe the topics.
+type MqttTopicACL struct {
+	// ClientID is a regular expression matching the whole client id, it matches any client id if it is empty
+	ClientID string `json:"client_id,omitempty"`
+	// Username is a regular expression matching the whole username, it matches any username if it is empty
+	Username string `json:"username,omitempty"`
+	// Action is publish or subscribe, the rule is applied to both if it is empty
+	Action string `json:"action,omitempty"`
+	// Topics are the topic filters, %c and %u are replaced with the client id and the username
+	Topics []string `json:"topics,omitempty"`
+	Deny   bool     `json:"deny,omitempty"`
+}
+
+// MysqlProxy is the config of the mysql proxy network filter
+type MysqlProxy struct {
+	StatPrefix string `json:"stat_prefix,omitempty"`
+	// Cluster is the cluster of the primary servers, all the statements are sent to it by default
+	Cluster string `json:"cluster,omitempty"`
+	// ReadCluster enables the read write splitting, the read only statements out of transactions
+	// are sent to the servers of the cluster
+	ReadCluster string `json:"read_cluster,omitempty"`
+	// ReadCredentialsFile is a json file of the client usernames and their passwords, such as {"app": "secret"},
+	// the connections to the ReadCluster are authenticated as the clients, so the grants of the users are kept.
+	// The credentials of the clients can not be reused because of the challenge response authentication,
+	// the statements of the users not in the file are sent to the Cluster. The passwords are not kept in the
+	// config, the file is read when the filter is created.
+	ReadCredentialsFile string `json:"read_credentials_file,omitempty"`
+	// SlowQueryThreshold logs the statements slower than it, the slow query log is disabled if it is zero
+	SlowQueryThreshold api.DurationConfig `json:"slow_query_threshold,omitempty"`
+	// TableStats enables the table level metrics, which are disabled by default to avoid high label cardinality
+	TableStats bool `json:"table_stats,omitempty"`
+}
+
+// KafkaProxy is the config of the kafka proxy network filter
+type KafkaProxy struct {
+	StatPrefix string `json:"stat_prefix,omitempty"`
+	Cluster    string `json:"cluster,omitempty"`
+	// BrokerAddressRewrites rewrites the broker addresses in the Metadata and FindCoordinator responses,
+	// so the clients connect to the brokers through the listeners of MOSN
+	BrokerAddressRewrites []*KafkaBrokerAddressRewrite `json:"broker_address_rewrites,omitempty"`
+	// TopicStats enables the topic level metrics of the Produce and Fetch requests
+	TopicStats bool `json:"topic_stats,omitempty"`
+}
+
+// KafkaBrokerAddressRewrite rewrites the address of the broker with the node id
+type KafkaBrokerAddressRewrite struct {
+	ID   int32  `json:"id"`
+	Host string `json:"host,omitempty"`
+	Port int32  `json:"port,omitempty"`
 }
@@ -0,0 +1,96 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v2
+
+// RBAC actions
+const (
+	// RBACActionAllow allows the request if any of the policies is matched, otherwise denies it
+	RBACActionAllow = "ALLOW"
+	// RBACActionDeny denies the request if any of the policies is matched, otherwise allows it
+	RBACActionDeny = "DENY"
+	// RBACActionLog allows all the requests, the request matched any of the policies is logged
+	RBACActionLog = "LOG"
+)
+
+// RBAC is the config of rbac network filter and stream filter.
+type RBAC struct {
+	StatPrefix string `json:"stat_prefix,omitempty"`
+	// Rules are enforced, all the requests are allowed if it is nil
+	Rules *RBACRules `json:"rules,omitempty"`
+	// ShadowRules are not enforced, only the metrics are emitted
+	ShadowRules *RBACRules `json:"shadow_rules,omitempty"`
+}
+
+// RBACRules is a list of policies with an action, the default action is ALLOW.
+type RBACRules struct {
+	Action   string       `json:"action,omitempty"`
+	Policies []RBACPolicy `json:"policies,omitempty"`
+}
+
+// RBACPolicy is matched if any of the permissions and any of the principals are matched.
+type RBACPolicy struct {
+	Name        string           `json:"name,omitempty"`
+	Permissions []RBACPermission `json:"permissions,omitempty"`
+	Principals  []RBACPrincipal  `json:"principals,omitempty"`
+}
+
+// RBACPermission describes the actions of the request, only one of the rules should be set.
+// The header, path, method, rpc service and rpc method are matched in stream filter only.
+type RBACPermission struct {
+	Any             bool              `json:"any,omitempty"`
+	AndRules        []RBACPermission  `json:"and_rules,omitempty"`
+	OrRules         []RBACPermission  `json:"or_rules,omitempty"`
+	NotRule         *RBACPermission   `json:"not_rule,omitempty"`
+	Header          *RBACValueMatcher `json:"header,omitempty"`
+	Path            *StringMatcher    `json:"path,omitempty"`
+	Method          *StringMatcher    `json:"method,omitempty"`
+	RPCService      *StringMatcher    `json:"rpc_service,omitempty"`
+	RPCMethod       *StringMatcher    `json:"rpc_method,omitempty"`
+	DestinationPort uint32            `json:"destination_port,omitempty"`
+}
+
+// RBACPrincipal describes the downstream of the request, only one of the ids should be set.
+// Authenticated matches the identity of the verified peer certificate, an empty one matches any
+// verified peer. Variable matches the variables set by other filters, such as the claims of a JWT.
+type RBACPrincipal struct {
+	Any           bool              `json:"any,omitempty"`
+	AndIDs        []RBACPrincipal   `json:"and_ids,omitempty"`
+	OrIDs         []RBACPrincipal   `json:"or_ids,omitempty"`
+	NotID         *RBACPrincipal    `json:"not_id,omitempty"`
+	Authenticated *StringMatcher    `json:"authenticated,omitempty"`
+	SourceIP      string            `json:"source_ip,omitempty"`
+	Header        *RBACValueMatcher `json:"header,omitempty"`
+	Variable      *RBACValueMatcher `json:"variable,omitempty"`
+}
+
+// StringMatcher matches a string, only one of exact, prefix, suffix and regex should be set.
+type StringMatcher struct {
+	Exact      string `json:"exact,omitempty"`
+	Prefix     string `json:"prefix,omitempty"`
+	Suffix     string `json:"suffix,omitempty"`
+	Regex      string `json:"regex,omitempty"`
+	IgnoreCase bool   `json:"ignore_case,omitempty"`
+}
+
+// RBACValueMatcher matches the value of a header or a variable by the name.
+// If Present is set, it matches whether the value exists only.
+type RBACValueMatcher struct {
+	Name    string `json:"name,omitempty"`
+	Present bool   `json:"present,omitempty"`
+	StringMatcher
+}
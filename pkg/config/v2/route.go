@@ -42,6 +42,7 @@ type RouterConfigurationConfig struct {
 }
 
 type RouterConfig struct {
+	Name                  string                 `json:"name,omitempty"`
 	Match                 RouterMatch            `json:"match,omitempty"`
 	Route                 RouteAction            `json:"route,omitempty"`
 	Redirect              *RedirectAction        `json:"redirect,omitempty"`
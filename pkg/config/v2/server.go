@@ -69,7 +69,7 @@ type ListenerConfig struct {
 	OriginalDst           OriginalDstType     `json:"use_original_dst,omitempty"`
 	AccessLogs            []AccessLog         `json:"access_logs,omitempty"`
 	ListenerFilters       []Filter            `json:"listener_filters,omitempty"`
-	FilterChains          []FilterChain       `json:"filter_chains,omitempty"` // only one filterchains at this time
+	FilterChains          []FilterChain       `json:"filter_chains,omitempty"`
 	StreamFilters         []Filter            `json:"stream_filters,omitempty"`
 	Inspector             bool                `json:"inspector,omitempty"`
 	ConnectionIdleTimeout *api.DurationConfig `json:"connection_idle_timeout,omitempty"`
@@ -144,6 +144,55 @@ func (l *Listener) IsOriginalDst() bool {
 type AccessLog struct {
 	Path   string `json:"log_path,omitempty"`
 	Format string `json:"log_format,omitempty"`
+	// JSONFormat maps the json keys to variables, takes precedence over Format if configured.
+	JSONFormat map[string]interface{} `json:"log_format_json,omitempty"`
+	// Filter decides whether a request should be logged, all requests are logged if not configured.
+	Filter *AccessLogFilter `json:"filter,omitempty"`
+	// Sink sends the access log entries to a remote collector instead of Path if configured.
+	Sink *AccessLogSink `json:"sink,omitempty"`
+}
+
+// AccessLogSink describes a remote access log sink, the config is parsed by the sink registered with the type.
+type AccessLogSink struct {
+	Type   string                 `json:"type,omitempty"`
+	Config map[string]interface{} `json:"config,omitempty"`
+}
+
+// AccessLogFilter matches the requests that should be logged.
+// All the configured conditions must be matched, And/Or are used for composition.
+type AccessLogFilter struct {
+	StatusCode   *StatusCodeFilter   `json:"status_code,omitempty"`
+	Duration     *DurationFilter     `json:"duration,omitempty"`
+	Header       *HeaderMatcher      `json:"header,omitempty"` // matches header presence if value is empty
+	ResponseFlag *ResponseFlagFilter `json:"response_flag,omitempty"`
+	Sampling     *SamplingFilter     `json:"sampling,omitempty"`
+	And          []AccessLogFilter   `json:"and,omitempty"`
+	Or           []AccessLogFilter   `json:"or,omitempty"`
+}
+
+// StatusCodeFilter matches the response code in [Min, Max], zero means unlimited
+type StatusCodeFilter struct {
+	Min int `json:"min,omitempty"`
+	Max int `json:"max,omitempty"`
+}
+
+// DurationFilter matches the request duration in [Min, Max], zero means unlimited
+type DurationFilter struct {
+	Min api.DurationConfig `json:"min,omitempty"`
+	Max api.DurationConfig `json:"max,omitempty"`
+}
+
+// ResponseFlagFilter matches if any of the flags is set, any flag is matched if Flags is empty
+type ResponseFlagFilter struct {
+	Flags []string `json:"flags,omitempty"`
+}
+
+// SamplingFilter matches the requests randomly by percent, in [0, 100]
+type SamplingFilter struct {
+	Percent float64 `json:"percent,omitempty"`
+	// RuntimeKey makes the percent updatable at runtime, the filters with the same key share the percent.
+	// Percent is the initial value, which is ignored if the key's percent is set already.
+	RuntimeKey string `json:"runtime_key,omitempty"`
 }
 
 // FilterChain wraps a set of match criteria, an option TLS context,
@@ -194,8 +243,28 @@ type Filter struct {
 }
 
 type FilterChainConfig struct {
-	FilterChainMatch string      `json:"match,omitempty"`
-	TLSConfig        *TLSConfig  `json:"tls_context,omitempty"`
-	TLSConfigs       []TLSConfig `json:"tls_context_set,omitempty"`
-	Filters          []Filter    `json:"filters,omitempty"`
+	FilterChainMatch string                  `json:"match,omitempty"`
+	Match            *FilterChainMatchConfig `json:"filter_chain_match,omitempty"`
+	TLSConfig        *TLSConfig              `json:"tls_context,omitempty"`
+	TLSConfigs       []TLSConfig             `json:"tls_context_set,omitempty"`
+	Filters          []Filter                `json:"filters,omitempty"`
+}
+
+// Transport protocols detected by the listener filters
+const (
+	TransportProtocolTLS = "tls"
+	TransportProtocolRaw = "raw_buffer"
+)
+
+// FilterChainMatchConfig describes the criteria for selecting a filter chain of a listener.
+// An empty field matches any connection. The server names, transport protocol and application
+// protocols are detected by the tls inspector listener filter. The most specific filter chain is
+// selected if more than one match, and the filter chains with the same criteria are rejected.
+type FilterChainMatchConfig struct {
+	// DestinationPort matches the original destination port if the connection is redirected
+	DestinationPort      uint32   `json:"destination_port,omitempty"`
+	SourcePrefixRanges   []string `json:"source_prefix_ranges,omitempty"`
+	ServerNames          []string `json:"server_names,omitempty"`
+	TransportProtocol    string   `json:"transport_protocol,omitempty"`
+	ApplicationProtocols []string `json:"application_protocols,omitempty"`
 }
@@ -46,6 +46,65 @@ type TLSConfig struct {
 	ExtendVerify      map[string]interface{} `json:"extend_verify,omitempty"`
 	Callbacks         []string               `json:"callbacks,omitempty"`
 	SdsConfig         *SdsConfig             `json:"sds_source,omitempty"`
+	// MatchSubjectAltNames verifies the subject alt names of the peer certificate,
+	// the peer certificate is accepted if any of the matchers is matched.
+	// In client side, the server certificate is verified by the matchers instead of the server name.
+	// In server side, the client certificate requested by RequireClientCert is verified too.
+	MatchSubjectAltNames []SubjectAltNameMatcher `json:"match_subject_alt_names,omitempty"`
+	// TrustDomains verifies the peer certificate by the SPIFFE trust domain bundles,
+	// the peer certificate should be issued by the roots of the trust domain in its SPIFFE ID.
+	TrustDomains []TrustDomain `json:"trust_domains,omitempty"`
+	// CRLFiles are the certificate revocation lists in PEM or DER format,
+	// the peer certificate chain is rejected if any of the certificates is revoked,
+	// or the list of its issuer is expired after the next update time.
+	// The files are reloaded when they are changed.
+	CRLFiles []string `json:"crl_files,omitempty"`
+	// OCSPStaple staples the OCSP response of the certificate in server side.
+	OCSPStaple *OCSPStapleConfig `json:"ocsp_staple,omitempty"`
+}
+
+// Subject alt name types
+const (
+	SubjectAltNameURI = "URI"
+	SubjectAltNameDNS = "DNS"
+	SubjectAltNameIP  = "IP"
+)
+
+// SubjectAltNameMatcher matches the subject alt names of a certificate.
+// An empty type matches all types of subject alt names, only one of exact, prefix, suffix and regex should be set.
+type SubjectAltNameMatcher struct {
+	Type       string `json:"type,omitempty"`
+	Exact      string `json:"exact,omitempty"`
+	Prefix     string `json:"prefix,omitempty"`
+	Suffix     string `json:"suffix,omitempty"`
+	Regex      string `json:"regex,omitempty"`
+	IgnoreCase bool   `json:"ignore_case,omitempty"`
+}
+
+// TrustDomain is a SPIFFE trust domain and its root certificates.
+// The CACert can be a file path or a pem string, same as TLSConfig.CACert.
+type TrustDomain struct {
+	Name   string `json:"name,omitempty"`
+	CACert string `json:"ca_cert,omitempty"`
+}
+
+// OCSP staple policies
+const (
+	// OCSPStaplePolicyLenient staples the response if it is valid, otherwise the handshake goes on without a staple.
+	OCSPStaplePolicyLenient = "lenient_stapling"
+	// OCSPStaplePolicyStrict is same as lenient, except the certificate with the must-staple extension requires a valid response.
+	OCSPStaplePolicyStrict = "strict_stapling"
+	// OCSPStaplePolicyMust requires a valid response, the handshake is failed without it.
+	OCSPStaplePolicyMust = "must_staple"
+)
+
+// OCSPStapleConfig is the OCSP stapling config.
+// The response is read from the StapleFile if it is set, otherwise is fetched from the
+// OCSP responder in the certificate and cached until it needs a refresh.
+type OCSPStapleConfig struct {
+	StapleFile string `json:"staple_file,omitempty"`
+	Fetch      bool   `json:"fetch,omitempty"`
+	Policy     string `json:"policy,omitempty"`
 }
 
 type SdsConfig struct {
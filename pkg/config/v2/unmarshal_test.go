@@ -255,6 +255,34 @@ func TestListenerUnmarshal(t *testing.T) {
 	}
 }
 
+func TestFilterChainMatchUnmarshal(t *testing.T) {
+	fcStr := `{
+		"filter_chain_match": {
+			"destination_port": 443,
+			"source_prefix_ranges": ["10.0.0.0/8"],
+			"server_names": ["*.example.com"],
+			"transport_protocol": "tls",
+			"application_protocols": ["h2", "http/1.1"]
+		},
+		"filters": [
+			{
+				"type": "proxy"
+			}
+		]
+	}`
+	fc := &FilterChain{}
+	if err := json.Unmarshal([]byte(fcStr), fc); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, &FilterChainMatchConfig{
+		DestinationPort:      443,
+		SourcePrefixRanges:   []string{"10.0.0.0/8"},
+		ServerNames:          []string{"*.example.com"},
+		TransportProtocol:    TransportProtocolTLS,
+		ApplicationProtocols: []string{"h2", "http/1.1"},
+	}, fc.Match)
+}
+
 func TestProxyUnmarshal(t *testing.T) {
 	proxy := `{
 		"name": "proxy",
@@ -51,6 +51,8 @@ type HostConfig struct {
 	Weight         uint32          `json:"weight,omitempty"`
 	MetaDataConfig *MetadataConfig `json:"metadata,omitempty"`
 	TLSDisable     bool            `json:"tls_disable,omitempty"`
+	// HealthFlags are the health flags set by the admin api, e.g. draining
+	HealthFlags []string `json:"health_flags,omitempty"`
 }
 
 // ClusterType
@@ -0,0 +1,658 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http3
+
+import (
+	"crypto/tls"
+	"errors"
+	"strings"
+	"sync"
+
+	"mosn.io/mosn/pkg/experimental/module/quic"
+	"mosn.io/mosn/pkg/module/http2/hpack"
+)
+
+// maxControlBuffer limits the data buffered on the control stream
+const maxControlBuffer = 64 << 10
+
+var (
+	ErrGoAway = errors.New("http3: connection is going away")
+	ErrClosed = errors.New("http3: connection closed")
+)
+
+// Handler handles the messages of the HTTP/3 connection, the data passed to the handler
+// must be copied to be retained
+type Handler interface {
+	// HandleHeaders is called with the headers of a request on the server, or a response on the client
+	HandleHeaders(id uint64, fields []hpack.HeaderField, endStream bool)
+	// HandleData is called with the body, the data may be empty when the stream ends
+	HandleData(id uint64, data []byte, endStream bool)
+	// HandleTrailers is called with the trailers, which always end the stream
+	HandleTrailers(id uint64, fields []hpack.HeaderField)
+	// HandleReset is called when the stream is reset by the peer or aborted by an error, it may be
+	// called twice if the peer resets both directions of the stream
+	HandleReset(id uint64, code uint64)
+	// HandleGoAway is called when the server sends GOAWAY, the requests with the stream id
+	// not less than the id are not processed
+	HandleGoAway(id uint64)
+	// HandleClose is called once when the connection is closed
+	HandleClose(err error)
+}
+
+const (
+	messageHeaders = iota
+	messageData
+	messageTrailers
+	messageReset
+	messageGoAway
+)
+
+type message struct {
+	kind      int
+	id        uint64
+	fields    []hpack.HeaderField
+	data      []byte
+	endStream bool
+	code      uint64
+}
+
+// streamError aborts the request stream
+type streamError struct {
+	code uint64
+}
+
+func (e *streamError) Error() string {
+	return "http3: stream error"
+}
+
+// requestStream is the parsing state of a request stream
+type requestStream struct {
+	buf           []byte
+	headers       bool
+	trailers      []hpack.HeaderField
+	hasTrailers   bool
+	dataRemaining uint64
+	skipRemaining uint64
+}
+
+// uniStream is the parsing state of a unidirectional stream opened by the peer
+type uniStream struct {
+	typ    uint64
+	typed  bool
+	ignore bool
+	buf    []byte
+}
+
+// Conn is an HTTP/3 connection over QUIC, the QPACK dynamic table and the server push are not supported
+type Conn struct {
+	server              bool
+	handler             Handler
+	transport           *quic.Conn
+	ready               chan struct{}
+	maxFieldSectionSize uint64
+
+	mutex          sync.Mutex
+	requests       map[uint64]*requestStream
+	uniStreams     map[uint64]*uniStream
+	uniTypes       map[uint64]bool
+	peerSettings   *settings
+	controlStream  uint64
+	nextRequestID  uint64
+	goAwaySent     bool
+	goAwayReceived bool
+	goAwayID       uint64
+}
+
+func newConn(server bool, handler Handler) *Conn {
+	return &Conn{
+		server:              server,
+		handler:             handler,
+		ready:               make(chan struct{}),
+		maxFieldSectionSize: DefaultMaxFieldSectionSize,
+		requests:            map[uint64]*requestStream{},
+		uniStreams:          map[uint64]*uniStream{},
+		uniTypes:            map[uint64]bool{},
+	}
+}
+
+// ConfigureTLS returns a copy of the config with the HTTP/3 ALPN
+func ConfigureTLS(config *tls.Config) *tls.Config {
+	config = config.Clone()
+	config.NextProtos = []string{NextProtoH3}
+	return config
+}
+
+// NewServerConn creates a server connection, the datagrams received are fed by Receive
+func NewServerConn(config *quic.Config, send func([]byte) error, handler Handler) (*Conn, error) {
+	c := newConn(true, handler)
+	transport, err := quic.NewServerConn(config, send, transportHandler{c})
+	if err != nil {
+		return nil, err
+	}
+	c.transport = transport
+	close(c.ready)
+	return c, nil
+}
+
+// NewClientConn creates a client connection over the datagrams sent by the send function,
+// the datagrams received are fed by Receive and the handshake is started by Start
+func NewClientConn(config *quic.Config, send func([]byte) error, handler Handler) (*Conn, error) {
+	c := newConn(false, handler)
+	transport, err := quic.NewClientConn(config, send, transportHandler{c})
+	if err != nil {
+		return nil, err
+	}
+	c.transport = transport
+	close(c.ready)
+	return c, nil
+}
+
+// Start starts the handshake of the client connection
+func (c *Conn) Start() error {
+	return c.transport.Start()
+}
+
+// Dial creates a client connection with its own UDP socket, and waits for the handshake
+func Dial(addr string, config *quic.Config, handler Handler) (*Conn, error) {
+	c := newConn(false, handler)
+	transport, err := quic.Dial(addr, config, transportHandler{c})
+	if err != nil {
+		// the events of the failed connection are dropped
+		close(c.ready)
+		return nil, err
+	}
+	c.transport = transport
+	close(c.ready)
+	return c, nil
+}
+
+// HandshakeComplete returns a channel closed when the handshake completes or the connection is closed
+func (c *Conn) HandshakeComplete() <-chan struct{} {
+	return c.transport.HandshakeComplete()
+}
+
+// Err returns the error of the connection closed
+func (c *Conn) Err() error {
+	return c.transport.Err()
+}
+
+// Receive handles a datagram of the server connection
+func (c *Conn) Receive(datagram []byte) {
+	c.transport.Receive(datagram)
+}
+
+// ConnectionState returns the state of the TLS handshake
+func (c *Conn) ConnectionState() tls.ConnectionState {
+	return c.transport.ConnectionState()
+}
+
+func (c *Conn) openControlStream() error {
+	id, err := c.transport.OpenStream(false)
+	if err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	c.controlStream = id
+	c.mutex.Unlock()
+	b := quic.AppendVarint(nil, streamTypeControl)
+	b = appendSettingsFrame(b, c.maxFieldSectionSize)
+	return c.transport.WriteStream(id, b, false)
+}
+
+// OpenRequest opens a request stream on the client
+func (c *Conn) OpenRequest() (uint64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.goAwayReceived {
+		return 0, ErrGoAway
+	}
+	id, err := c.transport.OpenStream(true)
+	if err != nil {
+		return 0, err
+	}
+	c.requests[id] = &requestStream{}
+	return id, nil
+}
+
+// WriteHeaders writes the HEADERS frame
+func (c *Conn) WriteHeaders(id uint64, fields []hpack.HeaderField, endStream bool) error {
+	block := appendFieldSection(nil, fields)
+	b := appendFrameHeader(make([]byte, 0, len(block)+16), frameHeaders, uint64(len(block)))
+	b = append(b, block...)
+	return c.transport.WriteStream(id, b, endStream)
+}
+
+// WriteData writes the DATA frame, the empty data only ends the stream
+func (c *Conn) WriteData(id uint64, data []byte, endStream bool) error {
+	if len(data) == 0 {
+		return c.transport.WriteStream(id, nil, endStream)
+	}
+	b := appendFrameHeader(make([]byte, 0, len(data)+16), frameData, uint64(len(data)))
+	b = append(b, data...)
+	return c.transport.WriteStream(id, b, endStream)
+}
+
+// WriteTrailers writes the trailers and ends the stream
+func (c *Conn) WriteTrailers(id uint64, fields []hpack.HeaderField) error {
+	return c.WriteHeaders(id, fields, true)
+}
+
+// ResetStream aborts the request stream in both directions
+func (c *Conn) ResetStream(id uint64, code uint64) error {
+	c.mutex.Lock()
+	delete(c.requests, id)
+	c.mutex.Unlock()
+	c.transport.StopSending(id, code)
+	return c.transport.ResetStream(id, code)
+}
+
+// GoAway starts the graceful shutdown, the server stops accepting the new requests
+func (c *Conn) GoAway() error {
+	c.mutex.Lock()
+	if c.goAwaySent {
+		c.mutex.Unlock()
+		return nil
+	}
+	c.goAwaySent = true
+	// the client sends the push id, which is always zero without the server push
+	var id uint64
+	if c.server {
+		id = c.nextRequestID
+		c.goAwayID = id
+	}
+	control := c.controlStream
+	c.mutex.Unlock()
+	return c.transport.WriteStream(control, appendGoAwayFrame(nil, id), false)
+}
+
+// Close closes the connection with the error code
+func (c *Conn) Close(code uint64) error {
+	return c.transport.Close(code, "")
+}
+
+func (c *Conn) closeWithError(err error) {
+	if e, ok := err.(*connError); ok {
+		c.transport.Close(e.code, e.reason)
+		return
+	}
+	c.transport.Close(ErrCodeInternalError, err.Error())
+}
+
+func (c *Conn) deliver(msgs []message) {
+	for _, m := range msgs {
+		switch m.kind {
+		case messageHeaders:
+			c.handler.HandleHeaders(m.id, m.fields, m.endStream)
+		case messageData:
+			c.handler.HandleData(m.id, m.data, m.endStream)
+		case messageTrailers:
+			c.handler.HandleTrailers(m.id, m.fields)
+		case messageReset:
+			c.handler.HandleReset(m.id, m.code)
+		case messageGoAway:
+			c.handler.HandleGoAway(m.id)
+		}
+	}
+}
+
+func (c *Conn) handleRequestStream(id uint64, data []byte, fin bool) {
+	c.mutex.Lock()
+	rs := c.requests[id]
+	if rs == nil {
+		if !c.server || !quic.StreamIsClientInitiated(id) {
+			c.mutex.Unlock()
+			if !quic.StreamIsClientInitiated(id) {
+				c.closeWithError(&connError{ErrCodeStreamCreationError, "server initiated bidirectional stream"})
+			}
+			return
+		}
+		if id < c.nextRequestID {
+			// the request stream is aborted
+			c.mutex.Unlock()
+			return
+		}
+		c.nextRequestID = id + 4
+		if c.goAwaySent && id >= c.goAwayID {
+			c.mutex.Unlock()
+			c.transport.StopSending(id, ErrCodeRequestRejected)
+			c.transport.ResetStream(id, ErrCodeRequestRejected)
+			return
+		}
+		rs = &requestStream{}
+		c.requests[id] = rs
+	}
+	msgs, err := c.parseRequestStream(id, rs, data, fin)
+	if fin || err != nil {
+		delete(c.requests, id)
+	}
+	c.mutex.Unlock()
+	c.deliver(msgs)
+	if err != nil {
+		if e, ok := err.(*streamError); ok {
+			c.transport.StopSending(id, e.code)
+			c.transport.ResetStream(id, e.code)
+			c.handler.HandleReset(id, e.code)
+			return
+		}
+		c.closeWithError(err)
+	}
+}
+
+// informational reports whether the response is an interim response
+func informational(fields []hpack.HeaderField) bool {
+	for _, f := range fields {
+		if f.Name == ":status" {
+			return strings.HasPrefix(f.Value, "1") && f.Value != "101"
+		}
+	}
+	return false
+}
+
+func (c *Conn) parseRequestStream(id uint64, rs *requestStream, data []byte, fin bool) ([]message, error) {
+	b := data
+	if len(rs.buf) > 0 {
+		b = append(rs.buf, data...)
+		rs.buf = nil
+	}
+	var msgs []message
+parse:
+	for len(b) > 0 {
+		if rs.dataRemaining > 0 {
+			n := uint64(len(b))
+			if n > rs.dataRemaining {
+				n = rs.dataRemaining
+			}
+			msgs = append(msgs, message{kind: messageData, id: id, data: b[:n]})
+			rs.dataRemaining -= n
+			b = b[n:]
+			continue
+		}
+		if rs.skipRemaining > 0 {
+			n := uint64(len(b))
+			if n > rs.skipRemaining {
+				n = rs.skipRemaining
+			}
+			rs.skipRemaining -= n
+			b = b[n:]
+			continue
+		}
+		typ, length, n := readFrameHeader(b)
+		if n == 0 {
+			break
+		}
+		switch typ {
+		case frameData:
+			if !rs.headers || rs.hasTrailers {
+				return msgs, &connError{ErrCodeFrameUnexpected, "unexpected DATA frame"}
+			}
+			rs.dataRemaining = length
+			b = b[n:]
+		case frameHeaders:
+			if length > c.maxFieldSectionSize {
+				return msgs, &streamError{ErrCodeExcessiveLoad}
+			}
+			if uint64(len(b)-n) < length {
+				break parse
+			}
+			fields, err := decodeFieldSection(b[n:n+int(length)], c.maxFieldSectionSize)
+			b = b[n+int(length):]
+			if err == errHeaderTooLarge {
+				return msgs, &streamError{ErrCodeExcessiveLoad}
+			}
+			if err != nil {
+				return msgs, &connError{ErrCodeQPACKDecompressionFailed, err.Error()}
+			}
+			switch {
+			case !rs.headers:
+				if !c.server && informational(fields) {
+					continue
+				}
+				rs.headers = true
+				msgs = append(msgs, message{kind: messageHeaders, id: id, fields: fields})
+			case !rs.hasTrailers:
+				rs.hasTrailers = true
+				rs.trailers = fields
+			default:
+				return msgs, &connError{ErrCodeFrameUnexpected, "unexpected HEADERS frame"}
+			}
+		case framePushPromise:
+			if c.server {
+				return msgs, &connError{ErrCodeFrameUnexpected, "unexpected PUSH_PROMISE frame"}
+			}
+			return msgs, &connError{ErrCodeIDError, "server push is not allowed"}
+		case frameCancelPush, frameSettings, frameGoAway, frameMaxPushID, 0x2, 0x6, 0x8, 0x9:
+			return msgs, &connError{ErrCodeFrameUnexpected, "unexpected frame on the request stream"}
+		default:
+			// the unknown frames are ignored
+			rs.skipRemaining = length
+			b = b[n:]
+		}
+	}
+	if len(b) > 0 {
+		rs.buf = append([]byte(nil), b...)
+	}
+	if !fin {
+		return msgs, nil
+	}
+	if len(rs.buf) > 0 || rs.dataRemaining > 0 || rs.skipRemaining > 0 {
+		return msgs, &streamError{ErrCodeFrameError}
+	}
+	if !rs.headers {
+		return msgs, &streamError{ErrCodeRequestIncomplete}
+	}
+	switch {
+	case rs.hasTrailers:
+		msgs = append(msgs, message{kind: messageTrailers, id: id, fields: rs.trailers})
+	case len(msgs) > 0:
+		msgs[len(msgs)-1].endStream = true
+	default:
+		msgs = append(msgs, message{kind: messageData, id: id, endStream: true})
+	}
+	return msgs, nil
+}
+
+func (c *Conn) handleUniStream(id uint64, data []byte, fin bool) {
+	c.mutex.Lock()
+	msgs, err := c.parseUniStream(id, data, fin)
+	c.mutex.Unlock()
+	c.deliver(msgs)
+	if err != nil {
+		c.closeWithError(err)
+	}
+}
+
+func (c *Conn) parseUniStream(id uint64, data []byte, fin bool) ([]message, error) {
+	us := c.uniStreams[id]
+	if us == nil {
+		us = &uniStream{}
+		c.uniStreams[id] = us
+	}
+	if us.ignore {
+		if fin {
+			return nil, &connError{ErrCodeClosedCriticalStream, "critical stream closed"}
+		}
+		return nil, nil
+	}
+	us.buf = append(us.buf, data...)
+	if !us.typed {
+		typ, n := quic.ReadVarint(us.buf)
+		if n == 0 {
+			return nil, nil
+		}
+		us.typ, us.typed = typ, true
+		us.buf = us.buf[n:]
+		switch typ {
+		case streamTypeControl, streamTypeQPACKEncoder, streamTypeQPACKDecoder:
+			if c.uniTypes[typ] {
+				return nil, &connError{ErrCodeStreamCreationError, "duplicate critical stream"}
+			}
+			c.uniTypes[typ] = true
+			// the QPACK instructions are not used without the dynamic table
+			us.ignore = typ != streamTypeControl
+		case streamTypePush:
+			if c.server {
+				return nil, &connError{ErrCodeStreamCreationError, "push stream from the client"}
+			}
+			return nil, &connError{ErrCodeIDError, "server push is not allowed"}
+		default:
+			// the unknown stream types are discarded
+			delete(c.uniStreams, id)
+			c.transport.StopSending(id, ErrCodeStreamCreationError)
+			return nil, nil
+		}
+		if us.ignore {
+			us.buf = nil
+			if fin {
+				return nil, &connError{ErrCodeClosedCriticalStream, "critical stream closed"}
+			}
+			return nil, nil
+		}
+	}
+	msgs, err := c.parseControlStream(us)
+	if err == nil && fin {
+		err = &connError{ErrCodeClosedCriticalStream, "control stream closed"}
+	}
+	return msgs, err
+}
+
+func (c *Conn) parseControlStream(us *uniStream) ([]message, error) {
+	var msgs []message
+	b := us.buf
+	for {
+		typ, length, n := readFrameHeader(b)
+		if n == 0 || uint64(len(b)-n) < length {
+			break
+		}
+		payload := b[n : n+int(length)]
+		b = b[n+int(length):]
+		if c.peerSettings == nil {
+			if typ != frameSettings {
+				return msgs, &connError{ErrCodeMissingSettings, "the first frame is not SETTINGS"}
+			}
+			s, err := parseSettings(payload)
+			if err != nil {
+				return msgs, err
+			}
+			c.peerSettings = s
+			continue
+		}
+		switch typ {
+		case frameGoAway:
+			id, m := quic.ReadVarint(payload)
+			if m == 0 || m != len(payload) {
+				return msgs, &connError{ErrCodeFrameError, "malformed GOAWAY frame"}
+			}
+			if c.server {
+				// the push id is ignored without the server push
+				continue
+			}
+			if !quic.StreamIsClientInitiated(id) || !quic.StreamIsBidi(id) {
+				return msgs, &connError{ErrCodeIDError, "invalid GOAWAY stream id"}
+			}
+			if c.goAwayReceived && id > c.goAwayID {
+				return msgs, &connError{ErrCodeIDError, "GOAWAY stream id increased"}
+			}
+			c.goAwayReceived = true
+			c.goAwayID = id
+			msgs = append(msgs, message{kind: messageGoAway, id: id})
+		case frameMaxPushID:
+			if !c.server {
+				return msgs, &connError{ErrCodeFrameUnexpected, "MAX_PUSH_ID frame from the server"}
+			}
+		case frameCancelPush:
+		case frameSettings, frameData, frameHeaders, framePushPromise, 0x2, 0x6, 0x8, 0x9:
+			return msgs, &connError{ErrCodeFrameUnexpected, "unexpected frame on the control stream"}
+		}
+	}
+	if len(b) > maxControlBuffer {
+		return msgs, &connError{ErrCodeExcessiveLoad, "control frame too large"}
+	}
+	us.buf = append([]byte(nil), b...)
+	return msgs, nil
+}
+
+// transportHandler handles the events of the QUIC connection
+type transportHandler struct {
+	c *Conn
+}
+
+// wait waits until the connection is set up, and reports whether the events should be handled
+func (h transportHandler) wait() bool {
+	<-h.c.ready
+	return h.c.transport != nil
+}
+
+func (h transportHandler) HandleHandshakeComplete() {
+	c := h.c
+	if !h.wait() {
+		return
+	}
+	if err := c.openControlStream(); err != nil {
+		c.closeWithError(err)
+	}
+}
+
+func (h transportHandler) HandleStreamData(id uint64, data []byte, fin bool) {
+	if !h.wait() {
+		return
+	}
+	if quic.StreamIsBidi(id) {
+		h.c.handleRequestStream(id, data, fin)
+	} else {
+		h.c.handleUniStream(id, data, fin)
+	}
+}
+
+func (h transportHandler) HandleStreamReset(id uint64, code uint64) {
+	c := h.c
+	if !h.wait() {
+		return
+	}
+	if !quic.StreamIsBidi(id) {
+		c.mutex.Lock()
+		us := c.uniStreams[id]
+		c.mutex.Unlock()
+		if us != nil && us.typed {
+			c.closeWithError(&connError{ErrCodeClosedCriticalStream, "critical stream reset"})
+		}
+		return
+	}
+	c.mutex.Lock()
+	delete(c.requests, id)
+	c.mutex.Unlock()
+	c.handler.HandleReset(id, code)
+}
+
+func (h transportHandler) HandleStopSending(id uint64, code uint64) {
+	c := h.c
+	if !h.wait() {
+		return
+	}
+	if !quic.StreamIsBidi(id) {
+		c.closeWithError(&connError{ErrCodeClosedCriticalStream, "critical stream stopped"})
+		return
+	}
+	c.handler.HandleReset(id, code)
+}
+
+func (h transportHandler) HandleClose(err error) {
+	if !h.wait() {
+		return
+	}
+	h.c.handler.HandleClose(err)
+}
@@ -0,0 +1,288 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http3
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"mosn.io/mosn/pkg/experimental/module/quic"
+	"mosn.io/mosn/pkg/module/http2/hpack"
+	"mosn.io/mosn/pkg/mtls/certtool"
+)
+
+func testTLSConfigs(t *testing.T) (server, client *tls.Config) {
+	priv, err := certtool.GeneratePrivateKey("P256")
+	require.Nil(t, err)
+	tmpl, err := certtool.CreateTemplate("h3.test", false, []string{"h3.test"})
+	require.Nil(t, err)
+	info, err := certtool.SignCertificate(tmpl, priv)
+	require.Nil(t, err)
+	cert, err := tls.X509KeyPair([]byte(info.CertPem), []byte(info.KeyPem))
+	require.Nil(t, err)
+	pool := x509.NewCertPool()
+	require.True(t, pool.AppendCertsFromPEM([]byte(certtool.GetRootCA().CertPem)))
+	server = ConfigureTLS(&tls.Config{Certificates: []tls.Certificate{cert}})
+	client = ConfigureTLS(&tls.Config{RootCAs: pool, ServerName: "h3.test"})
+	return server, client
+}
+
+type testMessage struct {
+	headers  []hpack.HeaderField
+	body     []byte
+	trailers []hpack.HeaderField
+}
+
+// testHandler collects the messages, and the server handler replies the request
+type testHandler struct {
+	conn     *Conn
+	mutex    sync.Mutex
+	messages map[uint64]*testMessage
+	done     map[uint64]chan struct{}
+	resets   map[uint64]uint64
+	goAway   chan uint64
+}
+
+func newTestHandler() *testHandler {
+	return &testHandler{
+		messages: map[uint64]*testMessage{},
+		done:     map[uint64]chan struct{}{},
+		resets:   map[uint64]uint64{},
+		goAway:   make(chan uint64, 1),
+	}
+}
+
+func (h *testHandler) wait(id uint64) chan struct{} {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.done[id] == nil {
+		h.done[id] = make(chan struct{})
+	}
+	return h.done[id]
+}
+
+func (h *testHandler) message(id uint64) *testMessage {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.messages[id] == nil {
+		h.messages[id] = &testMessage{}
+	}
+	return h.messages[id]
+}
+
+func (h *testHandler) finish(id uint64) {
+	close(h.wait(id))
+	if h.conn == nil || !h.conn.server {
+		return
+	}
+	// the server echoes the request
+	m := h.message(id)
+	var path string
+	for _, f := range m.headers {
+		if f.Name == ":path" {
+			path = f.Value
+		}
+	}
+	h.conn.WriteHeaders(id, []hpack.HeaderField{{Name: ":status", Value: "100"}}, false)
+	h.conn.WriteHeaders(id, []hpack.HeaderField{{Name: ":status", Value: "200"}, {Name: "x-path", Value: path}}, false)
+	h.conn.WriteData(id, m.body, false)
+	h.conn.WriteTrailers(id, m.trailers)
+}
+
+func (h *testHandler) HandleHeaders(id uint64, fields []hpack.HeaderField, endStream bool) {
+	h.message(id).headers = fields
+	if endStream {
+		h.finish(id)
+	}
+}
+
+func (h *testHandler) HandleData(id uint64, data []byte, endStream bool) {
+	m := h.message(id)
+	m.body = append(m.body, data...)
+	if endStream {
+		h.finish(id)
+	}
+}
+
+func (h *testHandler) HandleTrailers(id uint64, fields []hpack.HeaderField) {
+	h.message(id).trailers = fields
+	h.finish(id)
+}
+
+func (h *testHandler) HandleReset(id uint64, code uint64) {
+	h.mutex.Lock()
+	_, ok := h.resets[id]
+	h.resets[id] = code
+	h.mutex.Unlock()
+	if !ok {
+		close(h.wait(id))
+	}
+}
+
+func (h *testHandler) HandleGoAway(id uint64) {
+	h.goAway <- id
+}
+
+func (h *testHandler) HandleClose(err error) {}
+
+type testServer struct {
+	udp     *net.UDPConn
+	mutex   sync.Mutex
+	conns   map[string]*Conn
+	handler *testHandler
+}
+
+func newTestServer(t *testing.T) *testServer {
+	serverTLS, _ := testTLSConfigs(t)
+	udp, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.Nil(t, err)
+	s := &testServer{udp: udp, conns: map[string]*Conn{}}
+	go func() {
+		buf := make([]byte, 65536)
+		for {
+			n, addr, err := udp.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			s.mutex.Lock()
+			c := s.conns[addr.String()]
+			if c == nil {
+				h := newTestHandler()
+				c, err = NewServerConn(&quic.Config{TLSConfig: serverTLS}, func(b []byte) error {
+					_, err := udp.WriteToUDP(b, addr)
+					return err
+				}, h)
+				require.Nil(t, err)
+				h.conn = c
+				s.conns[addr.String()] = c
+				s.handler = h
+			}
+			s.mutex.Unlock()
+			c.Receive(buf[:n])
+		}
+	}()
+	return s
+}
+
+func TestRequestResponse(t *testing.T) {
+	server := newTestServer(t)
+	defer server.udp.Close()
+	_, clientTLS := testTLSConfigs(t)
+
+	h := newTestHandler()
+	c, err := Dial(server.udp.LocalAddr().String(), &quic.Config{TLSConfig: clientTLS}, h)
+	require.Nil(t, err)
+	defer c.Close(ErrCodeNoError)
+	require.Equal(t, NextProtoH3, c.ConnectionState().NegotiatedProtocol)
+
+	id, err := c.OpenRequest()
+	require.Nil(t, err)
+	require.Nil(t, c.WriteHeaders(id, []hpack.HeaderField{
+		{Name: ":method", Value: "POST"},
+		{Name: ":scheme", Value: "https"},
+		{Name: ":authority", Value: "h3.test"},
+		{Name: ":path", Value: "/echo"},
+	}, false))
+	body := make([]byte, 100<<10)
+	for i := range body {
+		body[i] = byte(i)
+	}
+	require.Nil(t, c.WriteData(id, body[:10], false))
+	require.Nil(t, c.WriteData(id, body[10:], false))
+	require.Nil(t, c.WriteTrailers(id, []hpack.HeaderField{{Name: "x-trailer", Value: "done"}}))
+
+	select {
+	case <-h.wait(id):
+	case <-time.After(10 * time.Second):
+		t.Fatal("response timeout")
+	}
+	m := h.message(id)
+	require.Equal(t, []hpack.HeaderField{{Name: ":status", Value: "200"}, {Name: "x-path", Value: "/echo"}}, m.headers)
+	require.Equal(t, body, m.body)
+	require.Equal(t, []hpack.HeaderField{{Name: "x-trailer", Value: "done"}}, m.trailers)
+
+	// the request without the body
+	id, err = c.OpenRequest()
+	require.Nil(t, err)
+	require.Nil(t, c.WriteHeaders(id, []hpack.HeaderField{{Name: ":method", Value: "GET"}, {Name: ":path", Value: "/get"}}, true))
+	<-h.wait(id)
+	require.Equal(t, "/get", h.message(id).headers[1].Value)
+	require.Empty(t, h.message(id).body)
+}
+
+func TestGoAway(t *testing.T) {
+	server := newTestServer(t)
+	defer server.udp.Close()
+	_, clientTLS := testTLSConfigs(t)
+
+	h := newTestHandler()
+	c, err := Dial(server.udp.LocalAddr().String(), &quic.Config{TLSConfig: clientTLS}, h)
+	require.Nil(t, err)
+	defer c.Close(ErrCodeNoError)
+
+	id, err := c.OpenRequest()
+	require.Nil(t, err)
+	require.Nil(t, c.WriteHeaders(id, []hpack.HeaderField{{Name: ":method", Value: "GET"}, {Name: ":path", Value: "/"}}, true))
+	<-h.wait(id)
+
+	server.mutex.Lock()
+	sc := server.handler.conn
+	server.mutex.Unlock()
+	require.Nil(t, sc.GoAway())
+	select {
+	case last := <-h.goAway:
+		require.Equal(t, id+4, last)
+	case <-time.After(5 * time.Second):
+		t.Fatal("goaway timeout")
+	}
+	_, err = c.OpenRequest()
+	require.Equal(t, ErrGoAway, err)
+}
+
+func TestResetStream(t *testing.T) {
+	server := newTestServer(t)
+	defer server.udp.Close()
+	_, clientTLS := testTLSConfigs(t)
+
+	h := newTestHandler()
+	c, err := Dial(server.udp.LocalAddr().String(), &quic.Config{TLSConfig: clientTLS}, h)
+	require.Nil(t, err)
+	defer c.Close(ErrCodeNoError)
+
+	id, err := c.OpenRequest()
+	require.Nil(t, err)
+	require.Nil(t, c.WriteHeaders(id, []hpack.HeaderField{{Name: ":method", Value: "POST"}, {Name: ":path", Value: "/"}}, false))
+	require.Nil(t, c.ResetStream(id, ErrCodeRequestCancelled))
+
+	require.Eventually(t, func() bool {
+		server.mutex.Lock()
+		sh := server.handler
+		server.mutex.Unlock()
+		if sh == nil {
+			return false
+		}
+		sh.mutex.Lock()
+		defer sh.mutex.Unlock()
+		return sh.resets[id] == ErrCodeRequestCancelled
+	}, 5*time.Second, 10*time.Millisecond)
+}
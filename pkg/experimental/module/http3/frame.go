@@ -0,0 +1,158 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http3
+
+import (
+	"errors"
+	"fmt"
+
+	"mosn.io/mosn/pkg/experimental/module/quic"
+)
+
+// NextProtoH3 is the ALPN protocol id of HTTP/3
+const NextProtoH3 = "h3"
+
+// frame types, see RFC 9114 section 7.2
+const (
+	frameData        = 0x0
+	frameHeaders     = 0x1
+	frameCancelPush  = 0x3
+	frameSettings    = 0x4
+	framePushPromise = 0x5
+	frameGoAway      = 0x7
+	frameMaxPushID   = 0xd
+)
+
+// unidirectional stream types, see RFC 9114 section 6.2 and RFC 9204 section 4.2
+const (
+	streamTypeControl      = 0x0
+	streamTypePush         = 0x1
+	streamTypeQPACKEncoder = 0x2
+	streamTypeQPACKDecoder = 0x3
+)
+
+// settings, see RFC 9114 section 7.2.4.1 and RFC 9204 section 5
+const (
+	settingQPACKMaxTableCapacity = 0x1
+	settingMaxFieldSectionSize   = 0x6
+	settingQPACKBlockedStreams   = 0x7
+)
+
+// error codes, see RFC 9114 section 8.1 and RFC 9204 section 6
+const (
+	ErrCodeNoError              = 0x100
+	ErrCodeGeneralProtocolError = 0x101
+	ErrCodeInternalError        = 0x102
+	ErrCodeStreamCreationError  = 0x103
+	ErrCodeClosedCriticalStream = 0x104
+	ErrCodeFrameUnexpected      = 0x105
+	ErrCodeFrameError           = 0x106
+	ErrCodeExcessiveLoad        = 0x107
+	ErrCodeIDError              = 0x108
+	ErrCodeSettingsError        = 0x109
+	ErrCodeMissingSettings      = 0x10a
+	ErrCodeRequestRejected      = 0x10b
+	ErrCodeRequestCancelled     = 0x10c
+	ErrCodeRequestIncomplete    = 0x10d
+	ErrCodeMessageError         = 0x10e
+	ErrCodeConnectError         = 0x10f
+	ErrCodeVersionFallback      = 0x110
+
+	ErrCodeQPACKDecompressionFailed = 0x200
+	ErrCodeQPACKEncoderStreamError  = 0x201
+	ErrCodeQPACKDecoderStreamError  = 0x202
+)
+
+// DefaultMaxFieldSectionSize is the default limit of the header size received
+const DefaultMaxFieldSectionSize = 1 << 20
+
+var errHeaderTooLarge = errors.New("http3: header too large")
+
+// connError is the error closing the connection
+type connError struct {
+	code   uint64
+	reason string
+}
+
+func (e *connError) Error() string {
+	return fmt.Sprintf("http3: connection error 0x%x: %s", e.code, e.reason)
+}
+
+func appendFrameHeader(b []byte, typ, length uint64) []byte {
+	b = quic.AppendVarint(b, typ)
+	return quic.AppendVarint(b, length)
+}
+
+// readFrameHeader reads the frame header, the size is 0 if the header is incomplete
+func readFrameHeader(b []byte) (typ, length uint64, n int) {
+	typ, n1 := quic.ReadVarint(b)
+	if n1 == 0 {
+		return 0, 0, 0
+	}
+	length, n2 := quic.ReadVarint(b[n1:])
+	if n2 == 0 {
+		return 0, 0, 0
+	}
+	return typ, length, n1 + n2
+}
+
+// appendSettingsFrame appends the SETTINGS frame, the QPACK dynamic table is disabled by the default values
+func appendSettingsFrame(b []byte, maxFieldSectionSize uint64) []byte {
+	var payload []byte
+	payload = quic.AppendVarint(payload, settingMaxFieldSectionSize)
+	payload = quic.AppendVarint(payload, maxFieldSectionSize)
+	b = appendFrameHeader(b, frameSettings, uint64(len(payload)))
+	return append(b, payload...)
+}
+
+type settings struct {
+	maxFieldSectionSize uint64
+}
+
+func parseSettings(b []byte) (*settings, error) {
+	s := &settings{maxFieldSectionSize: quic.MaxVarint}
+	seen := map[uint64]bool{}
+	for len(b) > 0 {
+		id, n1 := quic.ReadVarint(b)
+		if n1 == 0 {
+			return nil, &connError{ErrCodeFrameError, "malformed SETTINGS frame"}
+		}
+		v, n2 := quic.ReadVarint(b[n1:])
+		if n2 == 0 {
+			return nil, &connError{ErrCodeFrameError, "malformed SETTINGS frame"}
+		}
+		b = b[n1+n2:]
+		if seen[id] {
+			return nil, &connError{ErrCodeSettingsError, "duplicate setting"}
+		}
+		seen[id] = true
+		switch id {
+		case 0x2, 0x3, 0x4, 0x5:
+			// the HTTP/2 settings are not allowed
+			return nil, &connError{ErrCodeSettingsError, "HTTP/2 setting"}
+		case settingMaxFieldSectionSize:
+			s.maxFieldSectionSize = v
+		}
+	}
+	return s, nil
+}
+
+func appendGoAwayFrame(b []byte, id uint64) []byte {
+	b = appendFrameHeader(b, frameGoAway, uint64(quic.VarintLen(id)))
+	return quic.AppendVarint(b, id)
+}
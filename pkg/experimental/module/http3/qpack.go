@@ -0,0 +1,328 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http3
+
+import (
+	"errors"
+	"strings"
+
+	"mosn.io/mosn/pkg/module/http2/hpack"
+)
+
+// The QPACK encoder and decoder only use the static table, the dynamic table capacity
+// is always zero, see RFC 9204
+
+var (
+	errQPACKDecompression = errors.New("http3: qpack decompression failed")
+	errQPACKDynamicTable  = errors.New("http3: qpack dynamic table is not supported")
+)
+
+type staticEntry struct {
+	name, value string
+}
+
+// staticTable is the static table in RFC 9204 appendix A
+var staticTable = [...]staticEntry{
+	{":authority", ""},
+	{":path", "/"},
+	{"age", "0"},
+	{"content-disposition", ""},
+	{"content-length", "0"},
+	{"cookie", ""},
+	{"date", ""},
+	{"etag", ""},
+	{"if-modified-since", ""},
+	{"if-none-match", ""},
+	{"last-modified", ""},
+	{"link", ""},
+	{"location", ""},
+	{"referer", ""},
+	{"set-cookie", ""},
+	{":method", "CONNECT"},
+	{":method", "DELETE"},
+	{":method", "GET"},
+	{":method", "HEAD"},
+	{":method", "OPTIONS"},
+	{":method", "POST"},
+	{":method", "PUT"},
+	{":scheme", "http"},
+	{":scheme", "https"},
+	{":status", "103"},
+	{":status", "200"},
+	{":status", "304"},
+	{":status", "404"},
+	{":status", "503"},
+	{"accept", "*/*"},
+	{"accept", "application/dns-message"},
+	{"accept-encoding", "gzip, deflate, br"},
+	{"accept-ranges", "bytes"},
+	{"access-control-allow-headers", "cache-control"},
+	{"access-control-allow-headers", "content-type"},
+	{"access-control-allow-origin", "*"},
+	{"cache-control", "max-age=0"},
+	{"cache-control", "max-age=2592000"},
+	{"cache-control", "max-age=604800"},
+	{"cache-control", "no-cache"},
+	{"cache-control", "no-store"},
+	{"cache-control", "public, max-age=31536000"},
+	{"content-encoding", "br"},
+	{"content-encoding", "gzip"},
+	{"content-type", "application/dns-message"},
+	{"content-type", "application/javascript"},
+	{"content-type", "application/json"},
+	{"content-type", "application/x-www-form-urlencoded"},
+	{"content-type", "image/gif"},
+	{"content-type", "image/jpeg"},
+	{"content-type", "image/png"},
+	{"content-type", "text/css"},
+	{"content-type", "text/html; charset=utf-8"},
+	{"content-type", "text/plain"},
+	{"content-type", "text/plain;charset=utf-8"},
+	{"range", "bytes=0-"},
+	{"strict-transport-security", "max-age=31536000"},
+	{"strict-transport-security", "max-age=31536000; includesubdomains"},
+	{"strict-transport-security", "max-age=31536000; includesubdomains; preload"},
+	{"vary", "accept-encoding"},
+	{"vary", "origin"},
+	{"x-content-type-options", "nosniff"},
+	{"x-xss-protection", "1; mode=block"},
+	{":status", "100"},
+	{":status", "204"},
+	{":status", "206"},
+	{":status", "302"},
+	{":status", "400"},
+	{":status", "403"},
+	{":status", "421"},
+	{":status", "425"},
+	{":status", "500"},
+	{"accept-language", ""},
+	{"access-control-allow-credentials", "FALSE"},
+	{"access-control-allow-credentials", "TRUE"},
+	{"access-control-allow-headers", "*"},
+	{"access-control-allow-methods", "get"},
+	{"access-control-allow-methods", "get, post, options"},
+	{"access-control-allow-methods", "options"},
+	{"access-control-expose-headers", "content-length"},
+	{"access-control-request-headers", "content-type"},
+	{"access-control-request-method", "get"},
+	{"access-control-request-method", "post"},
+	{"alt-svc", "clear"},
+	{"authorization", ""},
+	{"content-security-policy", "script-src 'none'; object-src 'none'; base-uri 'none'"},
+	{"early-data", "1"},
+	{"expect-ct", ""},
+	{"forwarded", ""},
+	{"if-range", ""},
+	{"origin", ""},
+	{"purpose", "prefetch"},
+	{"server", ""},
+	{"timing-allow-origin", "*"},
+	{"upgrade-insecure-requests", "1"},
+	{"user-agent", ""},
+	{"x-forwarded-for", ""},
+	{"x-frame-options", "deny"},
+	{"x-frame-options", "sameorigin"},
+}
+
+var (
+	staticFieldIndex = map[staticEntry]int{}
+	staticNameIndex  = map[string]int{}
+)
+
+func init() {
+	for i, e := range staticTable {
+		staticFieldIndex[e] = i
+		if _, ok := staticNameIndex[e.name]; !ok {
+			staticNameIndex[e.name] = i
+		}
+	}
+}
+
+// appendPrefixInt appends the integer with the n-bit prefix, the first byte has the flags set
+func appendPrefixInt(b []byte, flags byte, n uint8, v uint64) []byte {
+	max := uint64(1)<<n - 1
+	if v < max {
+		return append(b, flags|byte(v))
+	}
+	b = append(b, flags|byte(max))
+	v -= max
+	for v >= 128 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+// readPrefixInt reads the integer with the n-bit prefix, the size is 0 if failed
+func readPrefixInt(b []byte, n uint8) (uint64, int) {
+	if len(b) == 0 {
+		return 0, 0
+	}
+	max := uint64(1)<<n - 1
+	v := uint64(b[0]) & max
+	if v < max {
+		return v, 1
+	}
+	var m uint8
+	for i := 1; i < len(b); i++ {
+		if m > 56 {
+			return 0, 0
+		}
+		v += uint64(b[i]&0x7f) << m
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+		m += 7
+	}
+	return 0, 0
+}
+
+// appendString appends the string literal with the n-bit prefix, the huffman flag is
+// the bit just before the prefix
+func appendString(b []byte, flags byte, n uint8, s string) []byte {
+	if l := hpack.HuffmanEncodeLength(s); l < uint64(len(s)) {
+		b = appendPrefixInt(b, flags|1<<n, n, l)
+		return hpack.AppendHuffmanString(b, s)
+	}
+	b = appendPrefixInt(b, flags, n, uint64(len(s)))
+	return append(b, s...)
+}
+
+func readString(b []byte, n uint8) (string, int, error) {
+	if len(b) == 0 {
+		return "", 0, errQPACKDecompression
+	}
+	huffman := b[0]&(1<<n) != 0
+	l, m := readPrefixInt(b, n)
+	if m == 0 || uint64(len(b)-m) < l {
+		return "", 0, errQPACKDecompression
+	}
+	v := b[m : m+int(l)]
+	if !huffman {
+		return string(v), m + int(l), nil
+	}
+	s, err := hpack.HuffmanDecodeToString(v)
+	if err != nil {
+		return "", 0, errQPACKDecompression
+	}
+	return s, m + int(l), nil
+}
+
+// appendFieldSection appends the encoded field section, the names are lower cased
+func appendFieldSection(b []byte, fields []hpack.HeaderField) []byte {
+	// the required insert count and the delta base are zero without the dynamic table
+	b = append(b, 0, 0)
+	for _, f := range fields {
+		name := strings.ToLower(f.Name)
+		if i, ok := staticFieldIndex[staticEntry{name, f.Value}]; ok && !f.Sensitive {
+			// indexed field line with the static table
+			b = appendPrefixInt(b, 0xc0, 6, uint64(i))
+			continue
+		}
+		var flags byte
+		if f.Sensitive {
+			flags = 0x20
+		}
+		if i, ok := staticNameIndex[name]; ok {
+			// literal field line with the static name reference
+			b = appendPrefixInt(b, 0x50|flags, 4, uint64(i))
+		} else {
+			// literal field line with the literal name
+			b = appendString(b, 0x20|flags>>1, 3, name)
+		}
+		b = appendString(b, 0, 7, f.Value)
+	}
+	return b
+}
+
+// decodeFieldSection decodes the field section, the size of the fields is limited by maxSize
+func decodeFieldSection(b []byte, maxSize uint64) ([]hpack.HeaderField, error) {
+	ric, n := readPrefixInt(b, 8)
+	if n == 0 {
+		return nil, errQPACKDecompression
+	}
+	if ric != 0 {
+		return nil, errQPACKDynamicTable
+	}
+	b = b[n:]
+	if _, n = readPrefixInt(b, 7); n == 0 {
+		return nil, errQPACKDecompression
+	}
+	b = b[n:]
+	var fields []hpack.HeaderField
+	var size uint64
+	for len(b) > 0 {
+		var f hpack.HeaderField
+		switch {
+		case b[0]&0x80 != 0:
+			// indexed field line
+			if b[0]&0x40 == 0 {
+				return nil, errQPACKDynamicTable
+			}
+			i, n := readPrefixInt(b, 6)
+			if n == 0 || i >= uint64(len(staticTable)) {
+				return nil, errQPACKDecompression
+			}
+			f = hpack.HeaderField{Name: staticTable[i].name, Value: staticTable[i].value}
+			b = b[n:]
+		case b[0]&0x40 != 0:
+			// literal field line with the name reference
+			if b[0]&0x10 == 0 {
+				return nil, errQPACKDynamicTable
+			}
+			f.Sensitive = b[0]&0x20 != 0
+			i, n := readPrefixInt(b, 4)
+			if n == 0 || i >= uint64(len(staticTable)) {
+				return nil, errQPACKDecompression
+			}
+			f.Name = staticTable[i].name
+			b = b[n:]
+			v, n, err := readString(b, 7)
+			if err != nil {
+				return nil, err
+			}
+			f.Value = v
+			b = b[n:]
+		case b[0]&0x20 != 0:
+			// literal field line with the literal name
+			f.Sensitive = b[0]&0x10 != 0
+			name, n, err := readString(b, 3)
+			if err != nil {
+				return nil, err
+			}
+			f.Name = name
+			b = b[n:]
+			v, n, err := readString(b, 7)
+			if err != nil {
+				return nil, err
+			}
+			f.Value = v
+			b = b[n:]
+		default:
+			// the post-base references only refer to the dynamic table
+			return nil, errQPACKDynamicTable
+		}
+		// the size of a field is computed as RFC 9114 section 4.2.2
+		size += uint64(len(f.Name) + len(f.Value) + 32)
+		if size > maxSize {
+			return nil, errHeaderTooLarge
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
@@ -0,0 +1,76 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http3
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"mosn.io/mosn/pkg/module/http2/hpack"
+)
+
+func TestPrefixInt(t *testing.T) {
+	// RFC 7541 appendix C.1
+	require.Equal(t, []byte{0x0a}, appendPrefixInt(nil, 0, 5, 10))
+	require.Equal(t, []byte{0x1f, 0x9a, 0x0a}, appendPrefixInt(nil, 0, 5, 1337))
+	require.Equal(t, []byte{0x2a}, appendPrefixInt(nil, 0, 8, 42))
+	v, n := readPrefixInt([]byte{0x1f, 0x9a, 0x0a}, 5)
+	require.Equal(t, uint64(1337), v)
+	require.Equal(t, 3, n)
+	_, n = readPrefixInt([]byte{0x1f, 0x9a}, 5)
+	require.Equal(t, 0, n)
+}
+
+// TestDecodeFieldSection uses the example in RFC 9204 appendix B.1
+func TestDecodeFieldSection(t *testing.T) {
+	b, err := hex.DecodeString("0000510b2f696e6465782e68746d6c")
+	require.Nil(t, err)
+	fields, err := decodeFieldSection(b, DefaultMaxFieldSectionSize)
+	require.Nil(t, err)
+	require.Equal(t, []hpack.HeaderField{{Name: ":path", Value: "/index.html"}}, fields)
+
+	// the dynamic table references are rejected
+	_, err = decodeFieldSection([]byte{0x02, 0x00, 0x80}, DefaultMaxFieldSectionSize)
+	require.Equal(t, errQPACKDynamicTable, err)
+	_, err = decodeFieldSection([]byte{0x00, 0x00, 0x10}, DefaultMaxFieldSectionSize)
+	require.Equal(t, errQPACKDynamicTable, err)
+	_, err = decodeFieldSection(b, 40)
+	require.Equal(t, errHeaderTooLarge, err)
+}
+
+func TestFieldSectionRoundTrip(t *testing.T) {
+	fields := []hpack.HeaderField{
+		{Name: ":method", Value: "GET"},
+		{Name: ":scheme", Value: "https"},
+		{Name: ":authority", Value: "example.com"},
+		{Name: ":path", Value: "/"},
+		{Name: "user-agent", Value: "mosn"},
+		{Name: "X-Custom", Value: "some value"},
+		{Name: "authorization", Value: "secret", Sensitive: true},
+		{Name: "x-secret", Value: "token", Sensitive: true},
+		{Name: "empty", Value: ""},
+	}
+	b := appendFieldSection(nil, fields)
+	decoded, err := decodeFieldSection(b, DefaultMaxFieldSectionSize)
+	require.Nil(t, err)
+	fields[5].Name = "x-custom"
+	require.Equal(t, fields, decoded)
+	// the static table entries are indexed
+	require.Equal(t, []byte{0x00, 0x00, 0xd1}, appendFieldSection(nil, fields[:1]))
+}
@@ -0,0 +1,211 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quic
+
+import (
+	"errors"
+	"sort"
+)
+
+var errFinalSize = errors.New("quic: final size changed")
+
+// sendBuffer keeps the data of a stream or the crypto data until acked
+type sendBuffer struct {
+	// data is the unacked data from the base offset
+	data []byte
+	base uint64
+	// sent is the offset of the data not sent yet
+	sent     uint64
+	acked    rangeSet
+	lost     rangeSet
+	fin      bool
+	finSent  bool
+	finAcked bool
+}
+
+func (b *sendBuffer) end() uint64 {
+	return b.base + uint64(len(b.data))
+}
+
+func (b *sendBuffer) write(p []byte) {
+	b.data = append(b.data, p...)
+}
+
+// pending reports whether the buffer has data to send, the new data is limited by the offset limit
+func (b *sendBuffer) pending(limit uint64) bool {
+	if len(b.lost) > 0 {
+		return true
+	}
+	if b.sent < b.end() {
+		return b.sent < limit
+	}
+	return b.fin && !b.finSent
+}
+
+// next returns the data to send with the max size n, the lost data is sent first
+func (b *sendBuffer) next(n int, limit uint64) (offset uint64, data []byte, fin bool) {
+	var end uint64
+	if len(b.lost) > 0 {
+		offset, end = b.lost[0].start, b.lost[0].end
+		if end-offset > uint64(n) {
+			end = offset + uint64(n)
+		}
+		b.lost.remove(offset, end)
+	} else {
+		offset, end = b.sent, b.end()
+		if end > limit {
+			end = limit
+		}
+		if end-offset > uint64(n) {
+			end = offset + uint64(n)
+		}
+		b.sent = end
+	}
+	data = b.data[offset-b.base : end-b.base]
+	if b.fin && !b.finSent && end == b.end() {
+		fin = true
+		b.finSent = true
+	}
+	return offset, data, fin
+}
+
+func (b *sendBuffer) ack(offset, length uint64, fin bool) {
+	if fin {
+		b.finAcked = true
+	}
+	end := offset + length
+	if offset < b.base {
+		// the data retransmitted may be acked more than once
+		offset = b.base
+	}
+	if offset >= end {
+		return
+	}
+	b.acked.add(offset, end)
+	b.lost.remove(offset, end)
+	for len(b.acked) > 0 && b.acked[0].start <= b.base {
+		n := b.acked[0].end - b.base
+		b.acked = b.acked[1:]
+		b.base += n
+		if n >= uint64(len(b.data)) {
+			b.data = nil
+		} else if n > uint64(cap(b.data)/2) {
+			b.data = append([]byte(nil), b.data[n:]...)
+		} else {
+			b.data = b.data[n:]
+		}
+	}
+}
+
+func (b *sendBuffer) lose(offset, length uint64, fin bool) {
+	if fin && !b.finAcked {
+		b.finSent = false
+	}
+	end := offset + length
+	if offset < b.base {
+		offset = b.base
+	}
+	if offset >= end {
+		return
+	}
+	b.lost.add(offset, end)
+	for _, r := range b.acked {
+		b.lost.remove(r.start, r.end)
+	}
+}
+
+// done reports whether all the data and the fin are acked
+func (b *sendBuffer) done() bool {
+	return b.fin && b.finAcked && len(b.data) == 0
+}
+
+type recvChunk struct {
+	offset uint64
+	data   []byte
+}
+
+// recvBuffer reorders the received data of a stream or the crypto data
+type recvBuffer struct {
+	// offset is the offset of the data not delivered yet
+	offset    uint64
+	chunks    []recvChunk
+	highest   uint64
+	finalSize uint64
+	hasFinal  bool
+	// finDelivered reports whether the fin is delivered
+	finDelivered bool
+}
+
+// push copies the received data into the buffer
+func (b *recvBuffer) push(offset uint64, data []byte, fin bool) error {
+	end := offset + uint64(len(data))
+	if b.hasFinal && (end > b.finalSize || fin && end != b.finalSize) {
+		return errFinalSize
+	}
+	if fin {
+		if end < b.highest {
+			return errFinalSize
+		}
+		b.finalSize = end
+		b.hasFinal = true
+	}
+	if end > b.highest {
+		b.highest = end
+	}
+	if end <= b.offset {
+		return nil
+	}
+	if offset < b.offset {
+		data = data[b.offset-offset:]
+		offset = b.offset
+	}
+	i := sort.Search(len(b.chunks), func(i int) bool {
+		return b.chunks[i].offset > offset
+	})
+	b.chunks = append(b.chunks, recvChunk{})
+	copy(b.chunks[i+1:], b.chunks[i:])
+	b.chunks[i] = recvChunk{offset: offset, data: append([]byte(nil), data...)}
+	return nil
+}
+
+// pop returns the contiguous data not delivered
+func (b *recvBuffer) pop() []byte {
+	var out []byte
+	for len(b.chunks) > 0 && b.chunks[0].offset <= b.offset {
+		c := b.chunks[0]
+		b.chunks = b.chunks[1:]
+		end := c.offset + uint64(len(c.data))
+		if end > b.offset {
+			if out == nil && c.offset == b.offset {
+				out = c.data
+			} else {
+				out = append(out, c.data[b.offset-c.offset:]...)
+			}
+			b.offset = end
+		}
+	}
+	if len(b.chunks) == 0 {
+		b.chunks = nil
+	}
+	return out
+}
+
+// finished reports whether all the data is delivered
+func (b *recvBuffer) finished() bool {
+	return b.hasFinal && b.offset == b.finalSize
+}
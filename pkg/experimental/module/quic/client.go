@@ -0,0 +1,105 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quic
+
+import (
+	"net"
+	"time"
+)
+
+// maxReceiveDatagramSize is the size of the buffer to read the datagrams
+const maxReceiveDatagramSize = 65536
+
+// NewClientConn creates a client connection, the datagrams of the connection are sent by the send
+// function and the datagrams received are fed by Receive. The handshake is started by Start
+func NewClientConn(config *Config, send func([]byte) error, handler Handler) (*Conn, error) {
+	cfg := config.withDefaults()
+	hs, err := newHandshaker(cfg.TLSConfig, false)
+	if err != nil {
+		return nil, err
+	}
+	return newConn(cfg, false, hs, send, handler), nil
+}
+
+// Start sends the first initial packet of the client connection, the connection is closed
+// if the handshake does not complete in the handshake timeout
+func (c *Conn) Start() error {
+	if err := c.startClient(); err != nil {
+		return err
+	}
+	time.AfterFunc(c.config.HandshakeTimeout, func() {
+		c.mu.Lock()
+		if c.handshakeComplete || c.closed {
+			c.mu.Unlock()
+			return
+		}
+		c.closeWithError(ErrHandshakeTimeout)
+		c.unlockAndFlush()
+	})
+	return nil
+}
+
+// Dial creates a client connection with its own UDP socket, and waits for the handshake
+func Dial(addr string, config *Config, handler Handler) (*Conn, error) {
+	raddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	udp, err := net.DialUDP("udp", nil, raddr)
+	if err != nil {
+		return nil, err
+	}
+	c, err := NewClientConn(config, func(b []byte) error {
+		_, err := udp.Write(b)
+		return err
+	}, handler)
+	if err != nil {
+		udp.Close()
+		return nil, err
+	}
+	c.onClosed = func() {
+		udp.Close()
+	}
+	go c.readLoop(udp)
+	if err := c.Start(); err != nil {
+		udp.Close()
+		return nil, err
+	}
+	<-c.handshakeDone
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Conn) readLoop(udp *net.UDPConn) {
+	buf := make([]byte, maxReceiveDatagramSize)
+	for {
+		n, err := udp.Read(buf)
+		if err != nil {
+			c.mu.Lock()
+			if !c.closed {
+				c.terminate(err)
+			}
+			c.mu.Unlock()
+			c.dispatch()
+			return
+		}
+		c.Receive(buf[:n])
+	}
+}
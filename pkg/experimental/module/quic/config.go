@@ -0,0 +1,78 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quic
+
+import (
+	"crypto/tls"
+	"time"
+)
+
+const (
+	DefaultMaxIdleTimeout          = 30 * time.Second
+	DefaultHandshakeTimeout        = 10 * time.Second
+	DefaultMaxIncomingStreams      = 100
+	DefaultMaxIncomingUniStreams   = 16
+	DefaultStreamReceiveWindow     = 1 << 20
+	DefaultConnectionReceiveWindow = 4 << 20
+)
+
+// Config is the config of the QUIC connections
+type Config struct {
+	// TLSConfig is required, the TLS 1.3 is always used
+	TLSConfig *tls.Config
+	// MaxIdleTimeout closes the connection without any activity
+	MaxIdleTimeout time.Duration
+	// HandshakeTimeout closes the client connection if the handshake does not complete
+	HandshakeTimeout time.Duration
+	// KeepAlivePeriod sends a PING if nothing is sent in the period, zero means no keepalive
+	KeepAlivePeriod time.Duration
+	// MaxIncomingStreams is the max number of the concurrent bidirectional streams opened by the peer
+	MaxIncomingStreams int
+	// MaxIncomingUniStreams is the max number of the concurrent unidirectional streams opened by the peer
+	MaxIncomingUniStreams int
+	// StreamReceiveWindow is the flow control window of each stream
+	StreamReceiveWindow uint64
+	// ConnectionReceiveWindow is the flow control window of the connection
+	ConnectionReceiveWindow uint64
+}
+
+func (c *Config) withDefaults() *Config {
+	cfg := &Config{}
+	if c != nil {
+		*cfg = *c
+	}
+	if cfg.MaxIdleTimeout <= 0 {
+		cfg.MaxIdleTimeout = DefaultMaxIdleTimeout
+	}
+	if cfg.HandshakeTimeout <= 0 {
+		cfg.HandshakeTimeout = DefaultHandshakeTimeout
+	}
+	if cfg.MaxIncomingStreams <= 0 {
+		cfg.MaxIncomingStreams = DefaultMaxIncomingStreams
+	}
+	if cfg.MaxIncomingUniStreams <= 0 {
+		cfg.MaxIncomingUniStreams = DefaultMaxIncomingUniStreams
+	}
+	if cfg.StreamReceiveWindow == 0 {
+		cfg.StreamReceiveWindow = DefaultStreamReceiveWindow
+	}
+	if cfg.ConnectionReceiveWindow == 0 {
+		cfg.ConnectionReceiveWindow = DefaultConnectionReceiveWindow
+	}
+	return cfg
+}
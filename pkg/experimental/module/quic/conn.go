@@ -0,0 +1,771 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quic
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"sync"
+	"time"
+)
+
+// Handler handles the events of a connection, the events are delivered in order
+// without the lock of the connection, so the handler can call the methods of the connection
+type Handler interface {
+	// HandleHandshakeComplete is called when the TLS handshake completes
+	HandleHandshakeComplete()
+	// HandleStreamData is called with the data received in order, a new stream opened by the peer
+	// is reported by its first data
+	HandleStreamData(id uint64, data []byte, fin bool)
+	// HandleStreamReset is called when the peer resets the sending part of the stream
+	HandleStreamReset(id uint64, code uint64)
+	// HandleStopSending is called when the peer asks to stop sending, the stream is reset with the code
+	HandleStopSending(id uint64, code uint64)
+	// HandleClose is called once when the connection is closed
+	HandleClose(err error)
+}
+
+const (
+	eventHandshakeComplete = iota
+	eventStreamData
+	eventStreamReset
+	eventStopSending
+	eventClose
+)
+
+type event struct {
+	kind int
+	id   uint64
+	data []byte
+	fin  bool
+	code uint64
+	err  error
+}
+
+// space is the state of a packet number space
+type space struct {
+	readKeys  *packetKeys
+	writeKeys *packetKeys
+	discarded bool
+
+	nextPN      uint64
+	largestRecv int64
+	// largestRecvTime is the receive time of the largest packet number, for the ack delay
+	largestRecvTime time.Time
+	recvd           rangeSet
+	// ackNeeded reports whether any packet is received after the last ACK sent
+	ackNeeded bool
+	// ackEliciting is the number of the ack-eliciting packets not acked
+	ackEliciting int
+	ackDeadline  time.Time
+
+	crypto     sendBuffer
+	cryptoRecv recvBuffer
+
+	sent         []*sentPacket
+	largestAcked int64
+	lossTime     time.Time
+	// lastAckElicitingTime is the send time of the last ack-eliciting packet
+	lastAckElicitingTime time.Time
+	// probe is the number of the probe packets to send after the probe timeout
+	probe int
+}
+
+func (s *space) ackElicitingInFlight() bool {
+	for _, p := range s.sent {
+		if p.ackEliciting {
+			return true
+		}
+	}
+	return false
+}
+
+// Conn is a QUIC connection, the server connections are fed with the datagrams by Receive
+// and the client connections are created by Dial with their own sockets
+type Conn struct {
+	mu      sync.Mutex
+	config  *Config
+	server  bool
+	handler Handler
+	send    func([]byte) error
+	hs      handshaker
+	started bool
+
+	srcID         []byte
+	dstID         []byte
+	originalDstID []byte
+	// peerIDKnown reports whether the client uses the connection id chosen by the server
+	peerIDKnown bool
+
+	spaces       [numLevels]*space
+	nextReadKeys *packetKeys
+	keyPhase     bool
+
+	peerParams           *transportParams
+	handshakeComplete    bool
+	handshakeConfirmed   bool
+	handshakeDonePending bool
+	handshakeDone        chan struct{}
+	// addressValidated reports whether the server can send without the amplification limit
+	addressValidated bool
+	bytesRecv        uint64
+	bytesSent        uint64
+
+	rtt      rttStats
+	cc       newReno
+	ptoCount int
+
+	streams   map[uint64]*stream
+	sendQueue []*stream
+	// the numbers of the streams opened locally and the limits of the peer
+	nextBidi    uint64
+	nextUni     uint64
+	peerMaxBidi uint64
+	peerMaxUni  uint64
+	// the numbers of the streams opened and closed by the peer, and the limits sent
+	peerOpenedBidi        uint64
+	peerOpenedUni         uint64
+	peerClosedBidi        uint64
+	peerClosedUni         uint64
+	maxIncomingBidi       uint64
+	maxIncomingUni        uint64
+	maxStreamsBidiPending bool
+	maxStreamsUniPending  bool
+
+	// the connection flow control
+	sendMaxData    uint64
+	sentData       uint64
+	recvMaxData    uint64
+	recvData       uint64
+	recvConsumed   uint64
+	maxDataPending bool
+
+	pingPending  bool
+	pathResponse []byte
+
+	idleTimeout  time.Duration
+	lastActivity time.Time
+	lastSendTime time.Time
+	timer        *time.Timer
+
+	closePending bool
+	closeErr     error
+	closed       bool
+	onClosed     func()
+
+	events      []event
+	dispatching bool
+}
+
+func newConn(config *Config, server bool, hs handshaker, send func([]byte) error, handler Handler) *Conn {
+	c := &Conn{
+		config:          config,
+		server:          server,
+		handler:         handler,
+		send:            send,
+		hs:              hs,
+		srcID:           randomID(),
+		handshakeDone:   make(chan struct{}),
+		rtt:             newRTTStats(),
+		cc:              newNewReno(),
+		streams:         make(map[uint64]*stream),
+		maxIncomingBidi: uint64(config.MaxIncomingStreams),
+		maxIncomingUni:  uint64(config.MaxIncomingUniStreams),
+		recvMaxData:     config.ConnectionReceiveWindow,
+		idleTimeout:     config.MaxIdleTimeout,
+		lastActivity:    time.Now(),
+	}
+	for i := range c.spaces {
+		c.spaces[i] = &space{largestRecv: -1, largestAcked: -1}
+	}
+	c.timer = time.AfterFunc(time.Hour, c.onTimer)
+	c.timer.Stop()
+	return c
+}
+
+func randomID() []byte {
+	id := make([]byte, connIDLen)
+	rand.Read(id)
+	return id
+}
+
+// NewServerConn creates a server connection, the datagrams of the connection are sent by the send function
+func NewServerConn(config *Config, send func([]byte) error, handler Handler) (*Conn, error) {
+	cfg := config.withDefaults()
+	hs, err := newHandshaker(cfg.TLSConfig, true)
+	if err != nil {
+		return nil, err
+	}
+	return newConn(cfg, true, hs, send, handler), nil
+}
+
+func (c *Conn) localParams() *transportParams {
+	return &transportParams{
+		originalDstID:           c.originalDstID,
+		initialSrcID:            c.srcID,
+		maxIdleTimeout:          c.config.MaxIdleTimeout,
+		initialMaxData:          c.config.ConnectionReceiveWindow,
+		maxStreamDataBidiLocal:  c.config.StreamReceiveWindow,
+		maxStreamDataBidiRemote: c.config.StreamReceiveWindow,
+		maxStreamDataUni:        c.config.StreamReceiveWindow,
+		maxStreamsBidi:          uint64(c.config.MaxIncomingStreams),
+		maxStreamsUni:           uint64(c.config.MaxIncomingUniStreams),
+	}
+}
+
+// startClient sends the first initial packet
+func (c *Conn) startClient() error {
+	c.mu.Lock()
+	c.started = true
+	c.dstID = randomID()
+	c.originalDstID = c.dstID
+	read, write, err := initialKeys(c.dstID, false)
+	if err != nil {
+		c.mu.Unlock()
+		return err
+	}
+	c.spaces[levelInitial].readKeys, c.spaces[levelInitial].writeKeys = read, write
+	events, err := c.hs.start(c.localParams().marshal(false))
+	if err == nil {
+		err = c.handleTLSEvents(events)
+	}
+	if err != nil {
+		c.mu.Unlock()
+		return err
+	}
+	c.unlockAndFlush()
+	return nil
+}
+
+// startServer starts the handshake with the first initial packet of the client
+func (c *Conn) startServer(h *header) error {
+	c.started = true
+	c.originalDstID = append([]byte(nil), h.dstID...)
+	c.dstID = append([]byte(nil), h.srcID...)
+	c.peerIDKnown = true
+	read, write, err := initialKeys(h.dstID, true)
+	if err != nil {
+		return err
+	}
+	c.spaces[levelInitial].readKeys, c.spaces[levelInitial].writeKeys = read, write
+	events, err := c.hs.start(c.localParams().marshal(true))
+	if err != nil {
+		return err
+	}
+	return c.handleTLSEvents(events)
+}
+
+// Receive handles a datagram received, the datagram is decrypted in place
+func (c *Conn) Receive(datagram []byte) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	if c.server && !c.started && len(datagram) < minInitialDatagramSize {
+		c.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	c.bytesRecv += uint64(len(datagram))
+	for len(datagram) > 0 {
+		n, err := c.handlePacket(datagram, now)
+		if err != nil {
+			c.closeWithError(err)
+			break
+		}
+		if n == 0 {
+			break
+		}
+		datagram = datagram[n:]
+	}
+	c.unlockAndFlush()
+}
+
+// OpenStream opens a stream, ErrStreamLimit is returned if the peer does not allow more streams
+func (c *Conn) OpenStream(bidi bool) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || c.closePending {
+		return 0, ErrClosed
+	}
+	var id uint64
+	if bidi {
+		if c.nextBidi >= c.peerMaxBidi {
+			return 0, ErrStreamLimit
+		}
+		id = c.nextBidi << 2
+		c.nextBidi++
+	} else {
+		if c.nextUni >= c.peerMaxUni {
+			return 0, ErrStreamLimit
+		}
+		id = c.nextUni<<2 | 0x2
+		c.nextUni++
+	}
+	if c.server {
+		id |= 0x1
+	}
+	c.streams[id] = c.newStream(id)
+	return id, nil
+}
+
+// WriteStream writes the data into the stream, the fin closes the sending part
+func (c *Conn) WriteStream(id uint64, data []byte, fin bool) error {
+	c.mu.Lock()
+	if c.closed || c.closePending {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	st := c.streams[id]
+	if st == nil {
+		c.mu.Unlock()
+		return ErrStreamNotFound
+	}
+	if !st.writable() {
+		c.mu.Unlock()
+		return ErrStreamClosed
+	}
+	st.send.write(data)
+	if fin {
+		st.send.fin = true
+	}
+	c.queueStream(st)
+	c.unlockAndFlush()
+	return nil
+}
+
+// ResetStream resets the sending part of the stream
+func (c *Conn) ResetStream(id uint64, code uint64) error {
+	c.mu.Lock()
+	if c.closed || c.closePending {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	st := c.streams[id]
+	if st == nil {
+		c.mu.Unlock()
+		return ErrStreamNotFound
+	}
+	c.resetStream(st, code)
+	c.unlockAndFlush()
+	return nil
+}
+
+// StopSending asks the peer to stop sending on the stream, the data received later is discarded
+func (c *Conn) StopSending(id uint64, code uint64) error {
+	c.mu.Lock()
+	if c.closed || c.closePending {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	st := c.streams[id]
+	if st == nil {
+		c.mu.Unlock()
+		return ErrStreamNotFound
+	}
+	if !st.recvClosed && !st.stopSent {
+		st.stopSent = true
+		st.stopPending = true
+		st.stopCode = code
+		c.queueStream(st)
+	}
+	c.unlockAndFlush()
+	return nil
+}
+
+// Close closes the connection with the application error code
+func (c *Conn) Close(code uint64, reason string) error {
+	c.mu.Lock()
+	if c.closed || c.closePending {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closeWithError(&ApplicationError{Code: code, Reason: reason})
+	c.unlockAndFlush()
+	return nil
+}
+
+// ConnectionState returns the state of the TLS handshake
+func (c *Conn) ConnectionState() tls.ConnectionState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hs.connectionState()
+}
+
+// HandshakeComplete returns a channel closed when the handshake completes or the connection is closed
+func (c *Conn) HandshakeComplete() <-chan struct{} {
+	return c.handshakeDone
+}
+
+// Err returns the error of the connection closed
+func (c *Conn) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closeErr
+}
+
+func (c *Conn) newStream(id uint64) *stream {
+	st := &stream{id: id, recvMax: c.config.StreamReceiveWindow}
+	local := StreamIsClientInitiated(id) != c.server
+	p := c.peerParams
+	if p == nil {
+		p = &transportParams{}
+	}
+	switch {
+	case StreamIsBidi(id) && local:
+		st.sendMax = p.maxStreamDataBidiRemote
+	case StreamIsBidi(id):
+		st.sendMax = p.maxStreamDataBidiLocal
+	case local:
+		st.sendMax = p.maxStreamDataUni
+		st.recvClosed = true
+	default:
+		st.send.fin = true
+		st.send.finAcked = true
+	}
+	return st
+}
+
+func (c *Conn) queueStream(st *stream) {
+	if !st.queued {
+		st.queued = true
+		c.sendQueue = append(c.sendQueue, st)
+	}
+}
+
+func (c *Conn) resetStream(st *stream, code uint64) {
+	if st.resetSent || st.send.done() {
+		return
+	}
+	st.resetSent = true
+	st.resetPending = true
+	st.resetCode = code
+	st.send.data = nil
+	st.send.lost = nil
+	c.queueStream(st)
+}
+
+// maybeRemoveStream removes the stream finished in both directions
+func (c *Conn) maybeRemoveStream(st *stream) {
+	if !st.recvClosed || !st.sendDone() || st.resetPending {
+		return
+	}
+	if _, ok := c.streams[st.id]; !ok {
+		return
+	}
+	delete(c.streams, st.id)
+	if StreamIsClientInitiated(st.id) != c.server {
+		return
+	}
+	// allow the peer to open more streams
+	if StreamIsBidi(st.id) {
+		c.peerClosedBidi++
+		if max := c.peerClosedBidi + uint64(c.config.MaxIncomingStreams); max > c.maxIncomingBidi {
+			c.maxIncomingBidi = max
+			c.maxStreamsBidiPending = true
+		}
+	} else {
+		c.peerClosedUni++
+		if max := c.peerClosedUni + uint64(c.config.MaxIncomingUniStreams); max > c.maxIncomingUni {
+			c.maxIncomingUni = max
+			c.maxStreamsUniPending = true
+		}
+	}
+}
+
+func (c *Conn) handleTLSEvents(events []tlsEvent) error {
+	for _, e := range events {
+		switch e.kind {
+		case tlsWriteData:
+			c.spaces[e.level].crypto.write(e.data)
+		case tlsSetReadSecret, tlsSetWriteSecret:
+			suite := cipherSuites[e.suite]
+			if suite == nil {
+				return transportError(ErrCodeInternal, "unsupported cipher suite")
+			}
+			keys, err := newPacketKeys(suite, e.data)
+			if err != nil {
+				return transportError(ErrCodeInternal, err.Error())
+			}
+			if e.kind == tlsSetWriteSecret {
+				c.spaces[e.level].writeKeys = keys
+				continue
+			}
+			c.spaces[e.level].readKeys = keys
+			if e.level == levelApplication {
+				if c.nextReadKeys, err = keys.next(); err != nil {
+					return transportError(ErrCodeInternal, err.Error())
+				}
+			}
+		case tlsTransportParams:
+			if err := c.applyPeerParams(e.data); err != nil {
+				return err
+			}
+		case tlsHandshakeDone:
+			c.handshakeComplete = true
+			if c.server {
+				c.handshakeDonePending = true
+				c.confirmHandshake()
+			}
+			close(c.handshakeDone)
+			c.events = append(c.events, event{kind: eventHandshakeComplete})
+		}
+	}
+	return nil
+}
+
+func (c *Conn) applyPeerParams(b []byte) error {
+	p, err := unmarshalTransportParams(b, !c.server)
+	if err != nil {
+		return err
+	}
+	if err := p.validateConnectionIDs(c.dstID, c.originalDstID, !c.server); err != nil {
+		return err
+	}
+	c.peerParams = p
+	if p.maxIdleTimeout > 0 && p.maxIdleTimeout < c.idleTimeout {
+		c.idleTimeout = p.maxIdleTimeout
+	}
+	c.sendMaxData = p.initialMaxData
+	c.peerMaxBidi = p.maxStreamsBidi
+	c.peerMaxUni = p.maxStreamsUni
+	return nil
+}
+
+// confirmHandshake discards the handshake keys, see RFC 9001 section 4.9.2
+func (c *Conn) confirmHandshake() {
+	if c.handshakeConfirmed {
+		return
+	}
+	c.handshakeConfirmed = true
+	c.discardSpace(levelHandshake)
+}
+
+func (c *Conn) discardSpace(level encLevel) {
+	s := c.spaces[level]
+	if s.discarded {
+		return
+	}
+	for _, p := range s.sent {
+		c.cc.onRemoved(p)
+	}
+	*s = space{discarded: true, largestRecv: -1, largestAcked: -1}
+	c.ptoCount = 0
+}
+
+// closeWithError starts to close the connection with the local error, the CONNECTION_CLOSE
+// frame is sent by the next flush
+func (c *Conn) closeWithError(err error) {
+	if c.closed || c.closePending {
+		return
+	}
+	c.closeErr = err
+	c.closePending = true
+}
+
+// terminate closes the connection without sending anything
+func (c *Conn) terminate(err error) {
+	if c.closed {
+		return
+	}
+	c.closed = true
+	c.closePending = false
+	if c.closeErr == nil {
+		c.closeErr = err
+	}
+	c.timer.Stop()
+	c.hs.close()
+	if !c.handshakeComplete {
+		close(c.handshakeDone)
+	}
+	c.streams = nil
+	c.sendQueue = nil
+	c.events = append(c.events, event{kind: eventClose, err: c.closeErr})
+	if c.onClosed != nil {
+		c.onClosed()
+	}
+}
+
+// unlockAndFlush sends the pending packets, arms the timer and dispatches the events
+func (c *Conn) unlockAndFlush() {
+	now := time.Now()
+	c.flush(now)
+	c.armTimer(now)
+	c.mu.Unlock()
+	c.dispatch()
+}
+
+func (c *Conn) dispatch() {
+	for {
+		c.mu.Lock()
+		if c.dispatching || len(c.events) == 0 {
+			c.mu.Unlock()
+			return
+		}
+		c.dispatching = true
+		events := c.events
+		c.events = nil
+		c.mu.Unlock()
+		for _, e := range events {
+			switch e.kind {
+			case eventHandshakeComplete:
+				c.handler.HandleHandshakeComplete()
+			case eventStreamData:
+				c.handler.HandleStreamData(e.id, e.data, e.fin)
+			case eventStreamReset:
+				c.handler.HandleStreamReset(e.id, e.code)
+			case eventStopSending:
+				c.handler.HandleStopSending(e.id, e.code)
+			case eventClose:
+				c.handler.HandleClose(e.err)
+			}
+		}
+		c.mu.Lock()
+		c.dispatching = false
+		c.mu.Unlock()
+	}
+}
+
+func (c *Conn) ptoDuration(level encLevel) time.Duration {
+	d := c.rtt.pto(0)
+	if level == levelApplication && c.peerParams != nil {
+		d += c.peerParams.maxAckDelay
+	}
+	backoff := c.ptoCount
+	if backoff > maxPTOBackoff {
+		backoff = maxPTOBackoff
+	}
+	return d << backoff
+}
+
+// lossDetectionTimer returns the time of the loss detection or the probe timeout,
+// see RFC 9002 appendix A.8
+func (c *Conn) lossDetectionTimer(now time.Time) (time.Time, encLevel, bool) {
+	var deadline time.Time
+	var level encLevel
+	for l, s := range c.spaces {
+		if !s.lossTime.IsZero() && (deadline.IsZero() || s.lossTime.Before(deadline)) {
+			deadline, level = s.lossTime, encLevel(l)
+		}
+	}
+	if !deadline.IsZero() {
+		return deadline, level, true
+	}
+	if c.server && !c.addressValidated && c.bytesSent >= 3*c.bytesRecv {
+		return time.Time{}, 0, false
+	}
+	inFlight := false
+	for l, s := range c.spaces {
+		if s.discarded || !s.ackElicitingInFlight() {
+			continue
+		}
+		inFlight = true
+		if encLevel(l) == levelApplication && !c.handshakeConfirmed {
+			continue
+		}
+		t := s.lastAckElicitingTime.Add(c.ptoDuration(encLevel(l)))
+		if deadline.IsZero() || t.Before(deadline) {
+			deadline, level = t, encLevel(l)
+		}
+	}
+	if !inFlight {
+		if c.server || c.handshakeConfirmed || !c.started {
+			return time.Time{}, 0, false
+		}
+		// the client keeps probing until the handshake is confirmed, see RFC 9002 section 6.2.2.1
+		level = levelInitial
+		if c.spaces[levelHandshake].writeKeys != nil {
+			level = levelHandshake
+		}
+		return now.Add(c.ptoDuration(level)), level, true
+	}
+	return deadline, level, !deadline.IsZero()
+}
+
+func (c *Conn) idleDeadline() time.Time {
+	timeout := c.idleTimeout
+	if pto := 3 * c.ptoDuration(levelApplication); timeout < pto {
+		timeout = pto
+	}
+	return c.lastActivity.Add(timeout)
+}
+
+func (c *Conn) armTimer(now time.Time) {
+	if c.closed {
+		return
+	}
+	deadline := c.idleDeadline()
+	if t, _, ok := c.lossDetectionTimer(now); ok && t.Before(deadline) {
+		deadline = t
+	}
+	if s := c.spaces[levelApplication]; s.ackEliciting > 0 && !s.ackDeadline.IsZero() && s.ackDeadline.Before(deadline) {
+		deadline = s.ackDeadline
+	}
+	if c.config.KeepAlivePeriod > 0 && c.handshakeComplete {
+		if t := c.lastSendTime.Add(c.config.KeepAlivePeriod); t.Before(deadline) {
+			deadline = t
+		}
+	}
+	d := deadline.Sub(now)
+	if d < 0 {
+		d = 0
+	}
+	c.timer.Reset(d)
+}
+
+func (c *Conn) onTimer() {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	if !now.Before(c.idleDeadline()) {
+		c.terminate(ErrIdleTimeout)
+		c.mu.Unlock()
+		c.dispatch()
+		return
+	}
+	if t, level, ok := c.lossDetectionTimer(now); ok && !now.Before(t) {
+		c.onLossDetectionTimeout(level, now)
+	}
+	if c.config.KeepAlivePeriod > 0 && c.handshakeComplete && !now.Before(c.lastSendTime.Add(c.config.KeepAlivePeriod)) {
+		c.pingPending = true
+	}
+	c.unlockAndFlush()
+}
+
+// onLossDetectionTimeout handles the loss detection timer, see RFC 9002 appendix A.9
+func (c *Conn) onLossDetectionTimeout(level encLevel, now time.Time) {
+	s := c.spaces[level]
+	if !s.lossTime.IsZero() {
+		c.detectLoss(level, now)
+		return
+	}
+	c.ptoCount++
+	if !s.ackElicitingInFlight() {
+		s.probe = 1
+		return
+	}
+	// the data in flight is sent again by the probe packets
+	for _, p := range s.sent {
+		c.onFramesLost(level, p)
+	}
+	s.probe = 2
+}
@@ -0,0 +1,291 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quic
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"mosn.io/mosn/pkg/mtls/certtool"
+)
+
+func testTLSConfigs(t *testing.T) (server, client *tls.Config) {
+	priv, err := certtool.GeneratePrivateKey("P256")
+	require.Nil(t, err)
+	tmpl, err := certtool.CreateTemplate("quic.test", false, []string{"quic.test"})
+	require.Nil(t, err)
+	info, err := certtool.SignCertificate(tmpl, priv)
+	require.Nil(t, err)
+	cert, err := tls.X509KeyPair([]byte(info.CertPem), []byte(info.KeyPem))
+	require.Nil(t, err)
+	pool := x509.NewCertPool()
+	require.True(t, pool.AppendCertsFromPEM([]byte(certtool.GetRootCA().CertPem)))
+	server = &tls.Config{Certificates: []tls.Certificate{cert}, NextProtos: []string{"test"}}
+	client = &tls.Config{RootCAs: pool, ServerName: "quic.test", NextProtos: []string{"test"}}
+	return server, client
+}
+
+// echoHandler echoes the data of the streams opened by the client
+type echoHandler struct {
+	conn *Conn
+}
+
+func (h *echoHandler) HandleHandshakeComplete() {}
+
+func (h *echoHandler) HandleStreamData(id uint64, data []byte, fin bool) {
+	h.conn.WriteStream(id, data, fin)
+}
+
+func (h *echoHandler) HandleStreamReset(id uint64, code uint64) {
+	h.conn.ResetStream(id, code)
+}
+
+func (h *echoHandler) HandleStopSending(id uint64, code uint64) {}
+
+func (h *echoHandler) HandleClose(err error) {}
+
+// testServer dispatches the datagrams to the server connections by the addresses,
+// the datagrams are dropped by the loss rate in both directions
+type testServer struct {
+	t       *testing.T
+	udp     *net.UDPConn
+	config  *Config
+	mutex   sync.Mutex
+	conns   map[string]*Conn
+	lossy   bool
+	counter uint32
+}
+
+func newTestServer(t *testing.T, config *Config, lossy bool) *testServer {
+	udp, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.Nil(t, err)
+	s := &testServer{t: t, udp: udp, config: config, conns: map[string]*Conn{}, lossy: lossy}
+	go s.serve()
+	return s
+}
+
+func (s *testServer) drop() bool {
+	return s.lossy && atomic.AddUint32(&s.counter, 1)%7 == 0
+}
+
+func (s *testServer) serve() {
+	buf := make([]byte, maxReceiveDatagramSize)
+	for {
+		n, addr, err := s.udp.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		if s.drop() {
+			continue
+		}
+		s.mutex.Lock()
+		c := s.conns[addr.String()]
+		if c == nil {
+			h := &echoHandler{}
+			c, err = NewServerConn(s.config, func(b []byte) error {
+				if s.drop() {
+					return nil
+				}
+				_, err := s.udp.WriteToUDP(b, addr)
+				return err
+			}, h)
+			require.Nil(s.t, err)
+			h.conn = c
+			s.conns[addr.String()] = c
+		}
+		s.mutex.Unlock()
+		c.Receive(buf[:n])
+	}
+}
+
+func (s *testServer) close() {
+	s.udp.Close()
+}
+
+// clientHandler collects the data of the streams
+type clientHandler struct {
+	mutex  sync.Mutex
+	data   map[uint64][]byte
+	done   map[uint64]chan struct{}
+	resets map[uint64]uint64
+	closed chan error
+}
+
+func newClientHandler() *clientHandler {
+	return &clientHandler{
+		data:   map[uint64][]byte{},
+		done:   map[uint64]chan struct{}{},
+		resets: map[uint64]uint64{},
+		closed: make(chan error, 1),
+	}
+}
+
+func (h *clientHandler) wait(id uint64) chan struct{} {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.done[id] == nil {
+		h.done[id] = make(chan struct{})
+	}
+	return h.done[id]
+}
+
+func (h *clientHandler) HandleHandshakeComplete() {}
+
+func (h *clientHandler) HandleStreamData(id uint64, data []byte, fin bool) {
+	h.mutex.Lock()
+	h.data[id] = append(h.data[id], data...)
+	h.mutex.Unlock()
+	if fin {
+		close(h.wait(id))
+	}
+}
+
+func (h *clientHandler) HandleStreamReset(id uint64, code uint64) {
+	h.mutex.Lock()
+	h.resets[id] = code
+	h.mutex.Unlock()
+	close(h.wait(id))
+}
+
+func (h *clientHandler) HandleStopSending(id uint64, code uint64) {}
+
+func (h *clientHandler) HandleClose(err error) {
+	h.closed <- err
+}
+
+func testEcho(t *testing.T, lossy bool) {
+	serverTLS, clientTLS := testTLSConfigs(t)
+	server := newTestServer(t, &Config{TLSConfig: serverTLS, StreamReceiveWindow: 64 << 10}, lossy)
+	defer server.close()
+
+	h := newClientHandler()
+	c, err := Dial(server.udp.LocalAddr().String(), &Config{TLSConfig: clientTLS, StreamReceiveWindow: 64 << 10}, h)
+	require.Nil(t, err)
+	require.Equal(t, "test", c.ConnectionState().NegotiatedProtocol)
+
+	payloads := map[uint64][]byte{}
+	for i := 0; i < 4; i++ {
+		data := bytes.Repeat([]byte{byte('a' + i)}, 100<<10*(i+1))
+		id, err := c.OpenStream(true)
+		require.Nil(t, err)
+		require.Nil(t, c.WriteStream(id, data[:1000], false))
+		require.Nil(t, c.WriteStream(id, data[1000:], true))
+		payloads[id] = data
+	}
+	for id, data := range payloads {
+		select {
+		case <-h.wait(id):
+		case <-time.After(10 * time.Second):
+			t.Fatalf("stream %d timeout", id)
+		}
+		h.mutex.Lock()
+		require.Equal(t, data, h.data[id])
+		h.mutex.Unlock()
+	}
+
+	// the reset is echoed by the server
+	id, err := c.OpenStream(true)
+	require.Nil(t, err)
+	require.Nil(t, c.WriteStream(id, []byte("reset"), false))
+	require.Nil(t, c.ResetStream(id, 0x42))
+	select {
+	case <-h.wait(id):
+	case <-time.After(10 * time.Second):
+		t.Fatal("reset timeout")
+	}
+	require.Equal(t, uint64(0x42), h.resets[id])
+	require.NotNil(t, c.WriteStream(id, []byte("closed"), true))
+
+	require.Nil(t, c.Close(0x100, "bye"))
+	err = <-h.closed
+	require.Equal(t, &ApplicationError{Code: 0x100, Reason: "bye"}, err)
+	_, err = c.OpenStream(true)
+	require.Equal(t, ErrClosed, err)
+}
+
+func TestConnEcho(t *testing.T) {
+	testEcho(t, false)
+}
+
+func TestConnEchoLossy(t *testing.T) {
+	testEcho(t, true)
+}
+
+func TestConnStreamLimit(t *testing.T) {
+	serverTLS, clientTLS := testTLSConfigs(t)
+	server := newTestServer(t, &Config{TLSConfig: serverTLS, MaxIncomingStreams: 2}, false)
+	defer server.close()
+
+	h := newClientHandler()
+	c, err := Dial(server.udp.LocalAddr().String(), &Config{TLSConfig: clientTLS}, h)
+	require.Nil(t, err)
+	defer c.Close(0, "")
+	var ids []uint64
+	for i := 0; i < 2; i++ {
+		id, err := c.OpenStream(true)
+		require.Nil(t, err)
+		ids = append(ids, id)
+	}
+	_, err = c.OpenStream(true)
+	require.Equal(t, ErrStreamLimit, err)
+	for _, id := range ids {
+		require.Nil(t, c.WriteStream(id, []byte("ping"), true))
+		<-h.wait(id)
+	}
+	// the limit is raised after the streams closed
+	require.Eventually(t, func() bool {
+		_, err := c.OpenStream(true)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestDialHandshakeFailure(t *testing.T) {
+	serverTLS, clientTLS := testTLSConfigs(t)
+	server := newTestServer(t, &Config{TLSConfig: serverTLS}, false)
+	defer server.close()
+
+	clientTLS.ServerName = "other.test"
+	_, err := Dial(server.udp.LocalAddr().String(), &Config{TLSConfig: clientTLS}, newClientHandler())
+	require.NotNil(t, err)
+	terr, ok := err.(*TransportError)
+	require.True(t, ok)
+	require.True(t, terr.Code > ErrCodeCrypto)
+}
+
+func TestIdleTimeout(t *testing.T) {
+	serverTLS, clientTLS := testTLSConfigs(t)
+	server := newTestServer(t, &Config{TLSConfig: serverTLS}, false)
+	defer server.close()
+
+	h := newClientHandler()
+	_, err := Dial(server.udp.LocalAddr().String(), &Config{TLSConfig: clientTLS, MaxIdleTimeout: 200 * time.Millisecond}, h)
+	require.Nil(t, err)
+	select {
+	case err := <-h.closed:
+		require.Equal(t, ErrIdleTimeout, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("idle timeout not triggered")
+	}
+}
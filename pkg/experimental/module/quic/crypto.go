@@ -0,0 +1,241 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quic
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+// initialSalt is the salt of the QUIC version 1 initial secrets, see RFC 9001 section 5.2
+var initialSalt = []byte{
+	0x38, 0x76, 0x2c, 0xf7, 0xf5, 0x59, 0x34, 0xb3, 0x4d, 0x17,
+	0x9a, 0xe6, 0xa4, 0xc8, 0x0c, 0xad, 0xcc, 0xbb, 0x7f, 0x0a,
+}
+
+var errDecrypt = errors.New("quic: packet decryption failed")
+
+// hkdfExpandLabel is the HKDF-Expand-Label function of TLS 1.3 with an empty context
+func hkdfExpandLabel(h func() hash.Hash, secret []byte, label string, length int) ([]byte, error) {
+	info := make([]byte, 0, 4+6+len(label))
+	info = append(info, byte(length>>8), byte(length), byte(6+len(label)))
+	info = append(info, "tls13 "...)
+	info = append(info, label...)
+	info = append(info, 0)
+	out := make([]byte, length)
+	if _, err := io.ReadFull(hkdf.Expand(h, secret, info), out); err != nil {
+		return nil, fmt.Errorf("quic: hkdf expand failed: %v", err)
+	}
+	return out, nil
+}
+
+// cipherSuite is a TLS 1.3 cipher suite used by the packet protection
+type cipherSuite struct {
+	hash   func() hash.Hash
+	keyLen int
+	aead   func(key []byte) (cipher.AEAD, error)
+	hp     func(key []byte) (headerProtector, error)
+}
+
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+var cipherSuites = map[uint16]*cipherSuite{
+	tls.TLS_AES_128_GCM_SHA256: {
+		hash:   sha256.New,
+		keyLen: 16,
+		aead:   newAESGCM,
+		hp:     newAESHeaderProtector,
+	},
+	tls.TLS_AES_256_GCM_SHA384: {
+		hash:   sha512.New384,
+		keyLen: 32,
+		aead:   newAESGCM,
+		hp:     newAESHeaderProtector,
+	},
+	tls.TLS_CHACHA20_POLY1305_SHA256: {
+		hash:   sha256.New,
+		keyLen: 32,
+		aead:   chacha20poly1305.New,
+		hp:     newChaChaHeaderProtector,
+	},
+}
+
+// headerProtector computes the header protection mask with a sample of the ciphertext
+type headerProtector interface {
+	mask(sample []byte) [5]byte
+}
+
+type aesHeaderProtector struct {
+	block cipher.Block
+}
+
+func newAESHeaderProtector(key []byte) (headerProtector, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return &aesHeaderProtector{block: block}, nil
+}
+
+func (p *aesHeaderProtector) mask(sample []byte) [5]byte {
+	var out [aes.BlockSize]byte
+	p.block.Encrypt(out[:], sample)
+	var m [5]byte
+	copy(m[:], out[:])
+	return m
+}
+
+type chachaHeaderProtector struct {
+	key []byte
+}
+
+func newChaChaHeaderProtector(key []byte) (headerProtector, error) {
+	return &chachaHeaderProtector{key: key}, nil
+}
+
+func (p *chachaHeaderProtector) mask(sample []byte) [5]byte {
+	var m [5]byte
+	c, err := chacha20.NewUnauthenticatedCipher(p.key, sample[4:16])
+	if err != nil {
+		return m
+	}
+	c.SetCounter(binary.LittleEndian.Uint32(sample[:4]))
+	c.XORKeyStream(m[:], m[:])
+	return m
+}
+
+// packetKeys protects the packets in one direction
+type packetKeys struct {
+	suite  *cipherSuite
+	secret []byte
+	aead   cipher.AEAD
+	iv     []byte
+	hp     headerProtector
+	nonce  [12]byte
+}
+
+func newPacketKeys(suite *cipherSuite, secret []byte) (*packetKeys, error) {
+	hpKey, err := hkdfExpandLabel(suite.hash, secret, "quic hp", suite.keyLen)
+	if err != nil {
+		return nil, err
+	}
+	hp, err := suite.hp(hpKey)
+	if err != nil {
+		return nil, err
+	}
+	return newPacketKeysWithHP(suite, secret, hp)
+}
+
+func newPacketKeysWithHP(suite *cipherSuite, secret []byte, hp headerProtector) (*packetKeys, error) {
+	key, err := hkdfExpandLabel(suite.hash, secret, "quic key", suite.keyLen)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := suite.aead(key)
+	if err != nil {
+		return nil, err
+	}
+	iv, err := hkdfExpandLabel(suite.hash, secret, "quic iv", 12)
+	if err != nil {
+		return nil, err
+	}
+	return &packetKeys{
+		suite:  suite,
+		secret: secret,
+		aead:   aead,
+		iv:     iv,
+		hp:     hp,
+	}, nil
+}
+
+// next returns the keys of the next key phase, the header protection key is not updated
+func (k *packetKeys) next() (*packetKeys, error) {
+	secret, err := hkdfExpandLabel(k.suite.hash, k.secret, "quic ku", k.suite.hash().Size())
+	if err != nil {
+		return nil, err
+	}
+	return newPacketKeysWithHP(k.suite, secret, k.hp)
+}
+
+func (k *packetKeys) makeNonce(pn uint64) []byte {
+	copy(k.nonce[:], k.iv)
+	for i := 0; i < 8; i++ {
+		k.nonce[11-i] ^= byte(pn >> (8 * i))
+	}
+	return k.nonce[:]
+}
+
+// seal encrypts the payload in place, the header ends with the packet number
+// and the payload must have the capacity of the tag
+func (k *packetKeys) seal(header, payload []byte, pn uint64) []byte {
+	return k.aead.Seal(payload[:0], k.makeNonce(pn), payload, header)
+}
+
+// open decrypts the payload in place
+func (k *packetKeys) open(header, payload []byte, pn uint64) ([]byte, error) {
+	out, err := k.aead.Open(payload[:0], k.makeNonce(pn), payload, header)
+	if err != nil {
+		return nil, errDecrypt
+	}
+	return out, nil
+}
+
+// initialKeys derives the keys of the initial packets from the destination connection id
+// of the first initial packet of the client
+func initialKeys(dstID []byte, server bool) (read, write *packetKeys, err error) {
+	suite := cipherSuites[tls.TLS_AES_128_GCM_SHA256]
+	secret := hkdf.Extract(sha256.New, dstID, initialSalt)
+	client, err := newInitialKeys(suite, secret, "client in")
+	if err != nil {
+		return nil, nil, err
+	}
+	srv, err := newInitialKeys(suite, secret, "server in")
+	if err != nil {
+		return nil, nil, err
+	}
+	if server {
+		return client, srv, nil
+	}
+	return srv, client, nil
+}
+
+func newInitialKeys(suite *cipherSuite, initialSecret []byte, label string) (*packetKeys, error) {
+	secret, err := hkdfExpandLabel(sha256.New, initialSecret, label, sha256.Size)
+	if err != nil {
+		return nil, err
+	}
+	return newPacketKeys(suite, secret)
+}
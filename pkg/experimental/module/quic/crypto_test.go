@@ -0,0 +1,92 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quic
+
+import (
+	"crypto/tls"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	require.Nil(t, err)
+	return b
+}
+
+// TestInitialKeys uses the test vectors in RFC 9001 appendix A.1
+func TestInitialKeys(t *testing.T) {
+	dstID := mustHex(t, "8394c8f03e515708")
+	read, write, err := initialKeys(dstID, true)
+	require.Nil(t, err)
+	cases := []struct {
+		keys *packetKeys
+		key  string
+		iv   string
+		hp   string
+	}{
+		{read, "1f369613dd76d5467730efcbe3b1a22d", "fa044b2f42a3fd3b46fb255c", "9f50449e04a0e810283a1e9933adedd2"},
+		{write, "cf3a5331653c364c88f0f379b6067e37", "0ac1493ca1905853b0bba03e", "c206b8d9b9f0f37644430b490eeaa314"},
+	}
+	for _, c := range cases {
+		suite := c.keys.suite
+		key, err := hkdfExpandLabel(suite.hash, c.keys.secret, "quic key", suite.keyLen)
+		require.Nil(t, err)
+		require.Equal(t, c.key, hex.EncodeToString(key))
+		require.Equal(t, c.iv, hex.EncodeToString(c.keys.iv))
+		hp, err := hkdfExpandLabel(suite.hash, c.keys.secret, "quic hp", suite.keyLen)
+		require.Nil(t, err)
+		require.Equal(t, c.hp, hex.EncodeToString(hp))
+	}
+	clientRead, clientWrite, err := initialKeys(dstID, false)
+	require.Nil(t, err)
+	require.Equal(t, read.secret, clientWrite.secret)
+	require.Equal(t, write.secret, clientRead.secret)
+}
+
+// TestChaCha20Packet uses the test vector in RFC 9001 appendix A.5
+func TestChaCha20Packet(t *testing.T) {
+	secret := mustHex(t, "9ac312a7f877468ebe69422748ad00a15443f18203a07d6060f688f30f21632b")
+	keys, err := newPacketKeys(cipherSuites[tls.TLS_CHACHA20_POLY1305_SHA256], secret)
+	require.Nil(t, err)
+	mask := keys.hp.mask(mustHex(t, "5e5cd55c41f69080575d7999c25a5bfb"))
+	require.Equal(t, "aefefe7d03", hex.EncodeToString(mask[:]))
+
+	pn := uint64(654360564)
+	pkt := mustHex(t, "4200bff4")
+	pkt = append(pkt, 0x01)
+	pkt = append(pkt, make([]byte, aeadOverhead)...)
+	keys.seal(pkt[:4], pkt[4:5], pn)
+	protectHeader(keys.hp, pkt, 1)
+	require.Equal(t, "4cfe4189655e5cd55c41f69080575d7999c25a5bfb", hex.EncodeToString(pkt))
+
+	truncated, pnLen, ok := unprotectHeader(keys.hp, pkt, 1)
+	require.True(t, ok)
+	require.Equal(t, 3, pnLen)
+	require.Equal(t, pn, decodePacketNumber(int64(pn-1), truncated, pnLen))
+	payload, err := keys.open(pkt[:4], pkt[4:], pn)
+	require.Nil(t, err)
+	require.Equal(t, []byte{0x01}, payload)
+
+	next, err := keys.next()
+	require.Nil(t, err)
+	require.Equal(t, "1223504755036d556342ee9361d253421a826c9ecdf3c7148684b36b714881f9", hex.EncodeToString(next.secret))
+	require.Equal(t, keys.hp, next.hp)
+}
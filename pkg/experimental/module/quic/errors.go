@@ -0,0 +1,98 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quic
+
+import (
+	"errors"
+	"fmt"
+)
+
+// transport error codes, see RFC 9000 section 20.1
+const (
+	ErrCodeNoError              = 0x0
+	ErrCodeInternal             = 0x1
+	ErrCodeConnectionRefused    = 0x2
+	ErrCodeFlowControl          = 0x3
+	ErrCodeStreamLimit          = 0x4
+	ErrCodeStreamState          = 0x5
+	ErrCodeFinalSize            = 0x6
+	ErrCodeFrameEncoding        = 0x7
+	ErrCodeTransportParameter   = 0x8
+	ErrCodeConnectionIDLimit    = 0x9
+	ErrCodeProtocolViolation    = 0xa
+	ErrCodeInvalidToken         = 0xb
+	ErrCodeApplication          = 0xc
+	ErrCodeCryptoBufferExceeded = 0xd
+	ErrCodeKeyUpdate            = 0xe
+	ErrCodeAEADLimitReached     = 0xf
+	ErrCodeNoViablePath         = 0x10
+	// ErrCodeCrypto is the base of the errors of the TLS alerts
+	ErrCodeCrypto = 0x100
+)
+
+var (
+	ErrIdleTimeout      = errors.New("quic: connection idle timeout")
+	ErrHandshakeTimeout = errors.New("quic: handshake timeout")
+	ErrClosed           = errors.New("quic: connection closed")
+	ErrStreamLimit      = errors.New("quic: stream limit reached")
+	ErrStreamNotFound   = errors.New("quic: stream not found")
+	ErrStreamClosed     = errors.New("quic: stream closed for writing")
+)
+
+// TransportError is the connection error of the transport
+type TransportError struct {
+	Code      uint64
+	FrameType uint64
+	Reason    string
+	// Remote reports whether the error is sent by the peer
+	Remote bool
+}
+
+func (e *TransportError) Error() string {
+	side := "local"
+	if e.Remote {
+		side = "remote"
+	}
+	if e.Reason == "" {
+		return fmt.Sprintf("quic: %s transport error 0x%x", side, e.Code)
+	}
+	return fmt.Sprintf("quic: %s transport error 0x%x: %s", side, e.Code, e.Reason)
+}
+
+// ApplicationError is the connection error of the application protocol
+type ApplicationError struct {
+	Code   uint64
+	Reason string
+	// Remote reports whether the error is sent by the peer
+	Remote bool
+}
+
+func (e *ApplicationError) Error() string {
+	side := "local"
+	if e.Remote {
+		side = "remote"
+	}
+	if e.Reason == "" {
+		return fmt.Sprintf("quic: %s application error 0x%x", side, e.Code)
+	}
+	return fmt.Sprintf("quic: %s application error 0x%x: %s", side, e.Code, e.Reason)
+}
+
+func transportError(code uint64, reason string) *TransportError {
+	return &TransportError{Code: code, Reason: reason}
+}
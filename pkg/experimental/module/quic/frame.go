@@ -0,0 +1,224 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quic
+
+// frame types, see RFC 9000 section 19
+const (
+	framePadding            = 0x00
+	framePing               = 0x01
+	frameAck                = 0x02
+	frameAckECN             = 0x03
+	frameResetStream        = 0x04
+	frameStopSending        = 0x05
+	frameCrypto             = 0x06
+	frameNewToken           = 0x07
+	frameStream             = 0x08
+	frameStreamMax          = 0x0f
+	frameMaxData            = 0x10
+	frameMaxStreamData      = 0x11
+	frameMaxStreamsBidi     = 0x12
+	frameMaxStreamsUni      = 0x13
+	frameDataBlocked        = 0x14
+	frameStreamDataBlocked  = 0x15
+	frameStreamsBlockedBidi = 0x16
+	frameStreamsBlockedUni  = 0x17
+	frameNewConnectionID    = 0x18
+	frameRetireConnectionID = 0x19
+	framePathChallenge      = 0x1a
+	framePathResponse       = 0x1b
+	frameConnectionClose    = 0x1c
+	frameConnectionCloseApp = 0x1d
+	frameHandshakeDone      = 0x1e
+)
+
+// maxAckRanges is the max number of the ranges sent in an ACK frame
+const maxAckRanges = 32
+
+// numRange is the range of the numbers [start, end)
+type numRange struct {
+	start, end uint64
+}
+
+// rangeSet is the sorted and disjoint ranges
+type rangeSet []numRange
+
+func (s rangeSet) contains(v uint64) bool {
+	for _, r := range s {
+		if v < r.start {
+			return false
+		}
+		if v < r.end {
+			return true
+		}
+	}
+	return false
+}
+
+func (s rangeSet) max() uint64 {
+	return s[len(s)-1].end - 1
+}
+
+func (s *rangeSet) add(start, end uint64) {
+	if start >= end {
+		return
+	}
+	rs := *s
+	i := 0
+	for i < len(rs) && rs[i].end < start {
+		i++
+	}
+	j := i
+	for j < len(rs) && rs[j].start <= end {
+		if rs[j].start < start {
+			start = rs[j].start
+		}
+		if rs[j].end > end {
+			end = rs[j].end
+		}
+		j++
+	}
+	if i == j {
+		rs = append(rs, numRange{})
+		copy(rs[i+1:], rs[i:])
+		rs[i] = numRange{start, end}
+	} else {
+		rs[i] = numRange{start, end}
+		rs = append(rs[:i+1], rs[j:]...)
+	}
+	*s = rs
+}
+
+func (s *rangeSet) remove(start, end uint64) {
+	if start >= end {
+		return
+	}
+	var out rangeSet
+	for _, r := range *s {
+		if r.end <= start || r.start >= end {
+			out = append(out, r)
+			continue
+		}
+		if r.start < start {
+			out = append(out, numRange{r.start, start})
+		}
+		if r.end > end {
+			out = append(out, numRange{end, r.end})
+		}
+	}
+	*s = out
+}
+
+// appendAckFrame appends the ACK frame of the ranges with the ack delay encoded,
+// only the latest maxAckRanges ranges are sent
+func appendAckFrame(b []byte, s rangeSet, delay uint64) []byte {
+	last := len(s) - 1
+	first := 0
+	if len(s) > maxAckRanges {
+		first = len(s) - maxAckRanges
+	}
+	b = append(b, frameAck)
+	b = AppendVarint(b, s[last].end-1)
+	b = AppendVarint(b, delay)
+	b = AppendVarint(b, uint64(last-first))
+	b = AppendVarint(b, s[last].end-1-s[last].start)
+	for i := last - 1; i >= first; i-- {
+		b = AppendVarint(b, s[i+1].start-s[i].end-1)
+		b = AppendVarint(b, s[i].end-1-s[i].start)
+	}
+	return b
+}
+
+// parseAckFrame parses the ACK frame after the frame type, and returns the acked ranges
+func parseAckFrame(r *reader, ecn bool) (rangeSet, uint64, bool) {
+	largest := r.varint()
+	delay := r.varint()
+	count := r.varint()
+	firstRange := r.varint()
+	if r.bad || firstRange > largest || count > 1<<16 {
+		return nil, 0, false
+	}
+	s := make(rangeSet, count+1)
+	smallest := largest - firstRange
+	s[count] = numRange{smallest, largest + 1}
+	for i := int(count) - 1; i >= 0; i-- {
+		gap := r.varint()
+		length := r.varint()
+		if r.bad || gap+2 > smallest || length > smallest-gap-2 {
+			return nil, 0, false
+		}
+		largest = smallest - gap - 2
+		smallest = largest - length
+		s[i] = numRange{smallest, largest + 1}
+	}
+	if ecn {
+		r.varint()
+		r.varint()
+		r.varint()
+	}
+	return s, delay, !r.bad
+}
+
+func appendCryptoFrame(b []byte, offset uint64, data []byte) []byte {
+	b = append(b, frameCrypto)
+	b = AppendVarint(b, offset)
+	b = AppendVarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+func cryptoFrameOverhead(offset uint64, length int) int {
+	return 1 + VarintLen(offset) + VarintLen(uint64(length))
+}
+
+func appendStreamFrame(b []byte, id, offset uint64, data []byte, fin bool) []byte {
+	typ := byte(frameStream | 0x02)
+	if offset > 0 {
+		typ |= 0x04
+	}
+	if fin {
+		typ |= 0x01
+	}
+	b = append(b, typ)
+	b = AppendVarint(b, id)
+	if offset > 0 {
+		b = AppendVarint(b, offset)
+	}
+	b = AppendVarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+func streamFrameOverhead(id, offset uint64, length int) int {
+	n := 1 + VarintLen(id) + VarintLen(uint64(length))
+	if offset > 0 {
+		n += VarintLen(offset)
+	}
+	return n
+}
+
+func appendConnectionCloseFrame(b []byte, app bool, code, frameType uint64, reason string) []byte {
+	if app {
+		b = append(b, frameConnectionCloseApp)
+	} else {
+		b = append(b, frameConnectionClose)
+	}
+	b = AppendVarint(b, code)
+	if !app {
+		b = AppendVarint(b, frameType)
+	}
+	b = AppendVarint(b, uint64(len(reason)))
+	return append(b, reason...)
+}
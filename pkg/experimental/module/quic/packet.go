@@ -0,0 +1,202 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quic
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	// Version1 is the QUIC version 1, see RFC 9000
+	Version1 = 0x00000001
+
+	// maxDatagramSize is the size limit of the datagrams sent, the path MTU discovery is not supported
+	maxDatagramSize = 1200
+	// minInitialDatagramSize is the minimum size of the datagrams with initial packets
+	minInitialDatagramSize = 1200
+	// connIDLen is the length of the connection ids chosen by the endpoint
+	connIDLen    = 8
+	maxConnIDLen = 20
+	// packetNumberLen is the length of the packet numbers sent
+	packetNumberLen = 4
+	// aeadOverhead is the size of the AEAD tag
+	aeadOverhead = 16
+)
+
+// long header packet types
+const (
+	packetInitial   = 0x0
+	packet0RTT      = 0x1
+	packetHandshake = 0x2
+	packetRetry     = 0x3
+)
+
+var errMalformedHeader = errors.New("quic: malformed packet header")
+
+// IsInitialPacket reports whether the datagram starts with a QUIC version 1 initial packet,
+// which starts the connection from a client
+func IsInitialPacket(b []byte) bool {
+	return len(b) >= 5 && b[0]&0xf0 == 0xc0 && binary.BigEndian.Uint32(b[1:5]) == Version1
+}
+
+// header is the header of a received packet
+type header struct {
+	long    bool
+	typ     uint8
+	version uint32
+	dstID   []byte
+	srcID   []byte
+	token   []byte
+	// pnOffset is the offset of the packet number
+	pnOffset int
+	// end is the end of the packet in the datagram
+	end int
+}
+
+// parseHeader parses the header before the packet number, the connection ids of the short
+// header packets have the length idLen
+func parseHeader(b []byte, idLen int) (*header, error) {
+	if len(b) == 0 {
+		return nil, errMalformedHeader
+	}
+	h := &header{}
+	if b[0]&0x80 == 0 {
+		if len(b) < 1+idLen {
+			return nil, errMalformedHeader
+		}
+		h.dstID = b[1 : 1+idLen]
+		h.pnOffset = 1 + idLen
+		h.end = len(b)
+		return h, nil
+	}
+	h.long = true
+	if len(b) < 7 {
+		return nil, errMalformedHeader
+	}
+	h.typ = (b[0] >> 4) & 0x3
+	h.version = binary.BigEndian.Uint32(b[1:5])
+	r := &reader{b: b[5:]}
+	h.dstID = r.bytes(uint64(r.uint8()))
+	h.srcID = r.bytes(uint64(r.uint8()))
+	if r.bad || len(h.dstID) > maxConnIDLen || len(h.srcID) > maxConnIDLen {
+		return nil, errMalformedHeader
+	}
+	// the version negotiation and the retry packets have no length
+	if h.version == 0 || h.version != Version1 || h.typ == packetRetry {
+		h.end = len(b)
+		return h, nil
+	}
+	if h.typ == packetInitial {
+		h.token = r.bytes(r.varint())
+	}
+	length := r.varint()
+	if r.bad || length > uint64(len(r.b)) {
+		return nil, errMalformedHeader
+	}
+	h.pnOffset = len(b) - len(r.b)
+	h.end = h.pnOffset + int(length)
+	return h, nil
+}
+
+// appendLongHeader appends the long header before the packet number with the length of the
+// packet number and the payload, the length is always encoded in 2 bytes
+func appendLongHeader(b []byte, typ uint8, dstID, srcID []byte, length int) []byte {
+	b = append(b, 0xc0|typ<<4|(packetNumberLen-1))
+	b = append(b, 0, 0, 0, Version1)
+	b = append(b, byte(len(dstID)))
+	b = append(b, dstID...)
+	b = append(b, byte(len(srcID)))
+	b = append(b, srcID...)
+	if typ == packetInitial {
+		// no token
+		b = append(b, 0)
+	}
+	return append(b, byte(length>>8)|0x40, byte(length))
+}
+
+func longHeaderLen(typ uint8, dstID, srcID []byte) int {
+	n := 1 + 4 + 1 + len(dstID) + 1 + len(srcID) + 2
+	if typ == packetInitial {
+		n++
+	}
+	return n
+}
+
+func appendShortHeader(b []byte, dstID []byte, keyPhase bool) []byte {
+	first := byte(0x40 | (packetNumberLen - 1))
+	if keyPhase {
+		first |= 0x04
+	}
+	b = append(b, first)
+	return append(b, dstID...)
+}
+
+func appendPacketNumber(b []byte, pn uint64) []byte {
+	return append(b, byte(pn>>24), byte(pn>>16), byte(pn>>8), byte(pn))
+}
+
+// protectHeader applies the header protection, see RFC 9001 section 5.4
+func protectHeader(hp headerProtector, pkt []byte, pnOffset int) {
+	mask := hp.mask(pkt[pnOffset+4 : pnOffset+20])
+	if pkt[0]&0x80 != 0 {
+		pkt[0] ^= mask[0] & 0x0f
+	} else {
+		pkt[0] ^= mask[0] & 0x1f
+	}
+	pnLen := int(pkt[0]^(mask[0]&0x03))&0x03 + 1
+	for i := 0; i < pnLen; i++ {
+		pkt[pnOffset+i] ^= mask[1+i]
+	}
+}
+
+// unprotectHeader removes the header protection, and returns the truncated packet number and its length
+func unprotectHeader(hp headerProtector, pkt []byte, pnOffset int) (uint64, int, bool) {
+	if len(pkt) < pnOffset+20 {
+		return 0, 0, false
+	}
+	mask := hp.mask(pkt[pnOffset+4 : pnOffset+20])
+	if pkt[0]&0x80 != 0 {
+		pkt[0] ^= mask[0] & 0x0f
+	} else {
+		pkt[0] ^= mask[0] & 0x1f
+	}
+	pnLen := int(pkt[0]&0x03) + 1
+	var pn uint64
+	for i := 0; i < pnLen; i++ {
+		pkt[pnOffset+i] ^= mask[1+i]
+		pn = pn<<8 | uint64(pkt[pnOffset+i])
+	}
+	return pn, pnLen, true
+}
+
+// decodePacketNumber decodes the truncated packet number with the largest packet number
+// received, see RFC 9000 appendix A.3
+func decodePacketNumber(largest int64, truncated uint64, pnLen int) uint64 {
+	expected := uint64(largest + 1)
+	win := uint64(1) << (8 * pnLen)
+	hwin := win / 2
+	candidate := (expected &^ (win - 1)) | truncated
+	if candidate+hwin <= expected && candidate < (1<<62)-win {
+		return candidate + win
+	}
+	if candidate > expected+hwin && candidate >= win {
+		return candidate - win
+	}
+	return candidate
+}
@@ -0,0 +1,151 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVarint(t *testing.T) {
+	cases := []struct {
+		v       uint64
+		encoded []byte
+	}{
+		{37, []byte{0x25}},
+		{15293, []byte{0x7b, 0xbd}},
+		{494878333, []byte{0x9d, 0x7f, 0x3e, 0x7d}},
+		{151288809941952652, []byte{0xc2, 0x19, 0x7c, 0x5e, 0xff, 0x14, 0xe8, 0x8c}},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.encoded, AppendVarint(nil, c.v))
+		v, n := ReadVarint(c.encoded)
+		require.Equal(t, c.v, v)
+		require.Equal(t, len(c.encoded), n)
+		_, n = ReadVarint(c.encoded[:len(c.encoded)-1])
+		require.Equal(t, 0, n)
+	}
+}
+
+func TestDecodePacketNumber(t *testing.T) {
+	// RFC 9000 appendix A.3
+	require.Equal(t, uint64(0xa82f9b32), decodePacketNumber(0xa82f30ea, 0x9b32, 2))
+	require.Equal(t, uint64(0), decodePacketNumber(-1, 0, 4))
+	require.Equal(t, uint64(0x100), decodePacketNumber(0xff, 0x00, 1))
+	require.Equal(t, uint64(0xff), decodePacketNumber(0x100, 0xff, 1))
+}
+
+func TestAckFrame(t *testing.T) {
+	var s rangeSet
+	for _, pn := range []uint64{0, 1, 2, 5, 7, 8, 9, 3} {
+		s.add(pn, pn+1)
+	}
+	require.Equal(t, rangeSet{{0, 4}, {5, 6}, {7, 10}}, s)
+	require.True(t, s.contains(5))
+	require.False(t, s.contains(6))
+
+	b := appendAckFrame(nil, s, 10)
+	r := &reader{b: b[1:]}
+	parsed, delay, ok := parseAckFrame(r, false)
+	require.True(t, ok)
+	require.True(t, r.empty())
+	require.Equal(t, uint64(10), delay)
+	require.Equal(t, s, parsed)
+
+	s.remove(2, 8)
+	require.Equal(t, rangeSet{{0, 2}, {8, 10}}, s)
+}
+
+func TestParseHeader(t *testing.T) {
+	dstID := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	srcID := []byte{9, 10, 11, 12}
+	b := appendLongHeader(nil, packetInitial, dstID, srcID, 20)
+	require.Equal(t, longHeaderLen(packetInitial, dstID, srcID), len(b))
+	b = append(b, make([]byte, 30)...)
+	require.True(t, IsInitialPacket(b))
+	h, err := parseHeader(b, connIDLen)
+	require.Nil(t, err)
+	require.True(t, h.long)
+	require.Equal(t, uint8(packetInitial), h.typ)
+	require.Equal(t, dstID, h.dstID)
+	require.Equal(t, srcID, h.srcID)
+	require.Equal(t, len(b)-30, h.pnOffset)
+	require.Equal(t, h.pnOffset+20, h.end)
+
+	b = appendShortHeader(nil, dstID, true)
+	require.False(t, IsInitialPacket(b))
+	h, err = parseHeader(b, connIDLen)
+	require.Nil(t, err)
+	require.False(t, h.long)
+	require.Equal(t, dstID, h.dstID)
+}
+
+func TestTransportParams(t *testing.T) {
+	p := &transportParams{
+		originalDstID:    []byte{1, 2, 3},
+		initialSrcID:     []byte{4, 5, 6},
+		initialMaxData:   1 << 20,
+		maxStreamsBidi:   100,
+		maxStreamDataUni: 1000,
+	}
+	parsed, err := unmarshalTransportParams(p.marshal(true), true)
+	require.Nil(t, err)
+	require.Nil(t, parsed.validateConnectionIDs([]byte{4, 5, 6}, []byte{1, 2, 3}, true))
+	require.Equal(t, p.initialMaxData, parsed.initialMaxData)
+	require.Equal(t, p.maxStreamsBidi, parsed.maxStreamsBidi)
+	require.Equal(t, p.maxStreamDataUni, parsed.maxStreamDataUni)
+	require.Equal(t, defaultMaxAckDelay, parsed.maxAckDelay)
+
+	// the server only parameters are not allowed from the client
+	_, err = unmarshalTransportParams(p.marshal(true), false)
+	require.NotNil(t, err)
+	require.NotNil(t, parsed.validateConnectionIDs([]byte{4, 5, 7}, []byte{1, 2, 3}, true))
+}
+
+func TestStreamBuffers(t *testing.T) {
+	var sb sendBuffer
+	sb.write([]byte("hello world"))
+	sb.fin = true
+	off, data, fin := sb.next(5, MaxVarint)
+	require.Equal(t, uint64(0), off)
+	require.Equal(t, "hello", string(data))
+	require.False(t, fin)
+	off, data, fin = sb.next(100, MaxVarint)
+	require.Equal(t, uint64(5), off)
+	require.Equal(t, " world", string(data))
+	require.True(t, fin)
+	require.False(t, sb.pending(MaxVarint))
+	sb.lose(0, 5, false)
+	sb.ack(5, 6, true)
+	require.True(t, sb.pending(MaxVarint))
+	off, data, _ = sb.next(100, MaxVarint)
+	require.Equal(t, uint64(0), off)
+	require.Equal(t, "hello", string(data))
+	sb.ack(0, 5, false)
+	require.True(t, sb.done())
+
+	var rb recvBuffer
+	require.Nil(t, rb.push(6, []byte("world"), true))
+	require.Nil(t, rb.pop())
+	require.Nil(t, rb.push(0, []byte("hello "), false))
+	require.Nil(t, rb.push(3, []byte("lo w"), false))
+	require.Equal(t, "hello world", string(rb.pop()))
+	require.True(t, rb.finished())
+	require.Equal(t, errFinalSize, rb.push(11, []byte("!"), false))
+}
@@ -0,0 +1,216 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quic
+
+import (
+	"bytes"
+	"time"
+)
+
+// transport parameter ids, see RFC 9000 section 18.2
+const (
+	paramOriginalDestinationConnectionID = 0x00
+	paramMaxIdleTimeout                  = 0x01
+	paramStatelessResetToken             = 0x02
+	paramMaxUDPPayloadSize               = 0x03
+	paramInitialMaxData                  = 0x04
+	paramInitialMaxStreamDataBidiLocal   = 0x05
+	paramInitialMaxStreamDataBidiRemote  = 0x06
+	paramInitialMaxStreamDataUni         = 0x07
+	paramInitialMaxStreamsBidi           = 0x08
+	paramInitialMaxStreamsUni            = 0x09
+	paramAckDelayExponent                = 0x0a
+	paramMaxAckDelay                     = 0x0b
+	paramDisableActiveMigration          = 0x0c
+	paramPreferredAddress                = 0x0d
+	paramActiveConnectionIDLimit         = 0x0e
+	paramInitialSourceConnectionID       = 0x0f
+	paramRetrySourceConnectionID         = 0x10
+)
+
+const (
+	defaultAckDelayExponent = 3
+	defaultMaxAckDelay      = 25 * time.Millisecond
+)
+
+type transportParams struct {
+	originalDstID           []byte
+	initialSrcID            []byte
+	hasInitialSrcID         bool
+	maxIdleTimeout          time.Duration
+	maxUDPPayloadSize       uint64
+	initialMaxData          uint64
+	maxStreamDataBidiLocal  uint64
+	maxStreamDataBidiRemote uint64
+	maxStreamDataUni        uint64
+	maxStreamsBidi          uint64
+	maxStreamsUni           uint64
+	ackDelayExponent        uint64
+	maxAckDelay             time.Duration
+}
+
+func defaultTransportParams() transportParams {
+	return transportParams{
+		maxUDPPayloadSize: 65527,
+		ackDelayExponent:  defaultAckDelayExponent,
+		maxAckDelay:       defaultMaxAckDelay,
+	}
+}
+
+func appendParam(b []byte, id uint64, v []byte) []byte {
+	b = AppendVarint(b, id)
+	b = AppendVarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func appendIntParam(b []byte, id, v uint64) []byte {
+	b = AppendVarint(b, id)
+	b = AppendVarint(b, uint64(VarintLen(v)))
+	return AppendVarint(b, v)
+}
+
+// marshal encodes the parameters sent by the endpoint, the default values are omitted
+func (p *transportParams) marshal(server bool) []byte {
+	var b []byte
+	if server {
+		b = appendParam(b, paramOriginalDestinationConnectionID, p.originalDstID)
+	}
+	if p.maxIdleTimeout > 0 {
+		b = appendIntParam(b, paramMaxIdleTimeout, uint64(p.maxIdleTimeout/time.Millisecond))
+	}
+	b = appendIntParam(b, paramInitialMaxData, p.initialMaxData)
+	b = appendIntParam(b, paramInitialMaxStreamDataBidiLocal, p.maxStreamDataBidiLocal)
+	b = appendIntParam(b, paramInitialMaxStreamDataBidiRemote, p.maxStreamDataBidiRemote)
+	b = appendIntParam(b, paramInitialMaxStreamDataUni, p.maxStreamDataUni)
+	b = appendIntParam(b, paramInitialMaxStreamsBidi, p.maxStreamsBidi)
+	b = appendIntParam(b, paramInitialMaxStreamsUni, p.maxStreamsUni)
+	b = appendParam(b, paramDisableActiveMigration, nil)
+	b = appendParam(b, paramInitialSourceConnectionID, p.initialSrcID)
+	return b
+}
+
+// unmarshalTransportParams decodes the parameters sent by the peer
+func unmarshalTransportParams(b []byte, fromServer bool) (*transportParams, error) {
+	p := defaultTransportParams()
+	seen := map[uint64]bool{}
+	r := &reader{b: b}
+	for !r.empty() {
+		id := r.varint()
+		v := r.bytes(r.varint())
+		if r.bad {
+			return nil, transportError(ErrCodeTransportParameter, "malformed transport parameters")
+		}
+		if seen[id] {
+			return nil, transportError(ErrCodeTransportParameter, "duplicate transport parameter")
+		}
+		seen[id] = true
+		vr := &reader{b: v}
+		switch id {
+		case paramOriginalDestinationConnectionID, paramStatelessResetToken,
+			paramPreferredAddress, paramRetrySourceConnectionID:
+			if !fromServer {
+				return nil, transportError(ErrCodeTransportParameter, "server only transport parameter")
+			}
+			if id == paramRetrySourceConnectionID {
+				return nil, transportError(ErrCodeTransportParameter, "unexpected retry source connection id")
+			}
+			if id == paramOriginalDestinationConnectionID {
+				p.originalDstID = append([]byte(nil), v...)
+			}
+			continue
+		case paramInitialSourceConnectionID:
+			p.initialSrcID = append([]byte(nil), v...)
+			p.hasInitialSrcID = true
+			continue
+		case paramDisableActiveMigration:
+			if len(v) != 0 {
+				return nil, transportError(ErrCodeTransportParameter, "invalid disable_active_migration")
+			}
+			continue
+		case paramMaxIdleTimeout, paramMaxUDPPayloadSize, paramInitialMaxData,
+			paramInitialMaxStreamDataBidiLocal, paramInitialMaxStreamDataBidiRemote,
+			paramInitialMaxStreamDataUni, paramInitialMaxStreamsBidi, paramInitialMaxStreamsUni,
+			paramAckDelayExponent, paramMaxAckDelay, paramActiveConnectionIDLimit:
+		default:
+			// unknown parameters are ignored
+			continue
+		}
+		n := vr.varint()
+		if vr.bad || !vr.empty() {
+			return nil, transportError(ErrCodeTransportParameter, "malformed integer transport parameter")
+		}
+		switch id {
+		case paramMaxIdleTimeout:
+			p.maxIdleTimeout = time.Duration(n) * time.Millisecond
+		case paramMaxUDPPayloadSize:
+			if n < 1200 {
+				return nil, transportError(ErrCodeTransportParameter, "invalid max_udp_payload_size")
+			}
+			p.maxUDPPayloadSize = n
+		case paramInitialMaxData:
+			p.initialMaxData = n
+		case paramInitialMaxStreamDataBidiLocal:
+			p.maxStreamDataBidiLocal = n
+		case paramInitialMaxStreamDataBidiRemote:
+			p.maxStreamDataBidiRemote = n
+		case paramInitialMaxStreamDataUni:
+			p.maxStreamDataUni = n
+		case paramInitialMaxStreamsBidi, paramInitialMaxStreamsUni:
+			if n > 1<<60 {
+				return nil, transportError(ErrCodeTransportParameter, "invalid initial_max_streams")
+			}
+			if id == paramInitialMaxStreamsBidi {
+				p.maxStreamsBidi = n
+			} else {
+				p.maxStreamsUni = n
+			}
+		case paramAckDelayExponent:
+			if n > 20 {
+				return nil, transportError(ErrCodeTransportParameter, "invalid ack_delay_exponent")
+			}
+			p.ackDelayExponent = n
+		case paramMaxAckDelay:
+			if n >= 1<<14 {
+				return nil, transportError(ErrCodeTransportParameter, "invalid max_ack_delay")
+			}
+			p.maxAckDelay = time.Duration(n) * time.Millisecond
+		case paramActiveConnectionIDLimit:
+			if n < 2 {
+				return nil, transportError(ErrCodeTransportParameter, "invalid active_connection_id_limit")
+			}
+		}
+	}
+	if !p.hasInitialSrcID {
+		return nil, transportError(ErrCodeTransportParameter, "missing initial_source_connection_id")
+	}
+	if fromServer && !seen[paramOriginalDestinationConnectionID] {
+		return nil, transportError(ErrCodeTransportParameter, "missing original_destination_connection_id")
+	}
+	return &p, nil
+}
+
+// validateConnectionIDs checks the connection ids authenticated by the handshake, see RFC 9000 section 7.3
+func (p *transportParams) validateConnectionIDs(peerSrcID, originalDstID []byte, fromServer bool) error {
+	if !bytes.Equal(p.initialSrcID, peerSrcID) {
+		return transportError(ErrCodeTransportParameter, "mismatched initial_source_connection_id")
+	}
+	if fromServer && !bytes.Equal(p.originalDstID, originalDstID) {
+		return transportError(ErrCodeTransportParameter, "mismatched original_destination_connection_id")
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quic
+
+import (
+	"time"
+)
+
+// the loss detection constants, see RFC 9002 section 6
+const (
+	packetThreshold   = 3
+	timerGranularity  = time.Millisecond
+	initialRTT        = 333 * time.Millisecond
+	initialWindow     = 10 * maxDatagramSize
+	minimumWindow     = 2 * maxDatagramSize
+	maxPTOBackoff     = 16
+	lossReductionBits = 1
+)
+
+// the kinds of the frames that may be retransmitted
+const (
+	recordCrypto = iota
+	recordStream
+	recordResetStream
+	recordStopSending
+	recordMaxData
+	recordMaxStreamData
+	recordMaxStreamsBidi
+	recordMaxStreamsUni
+	recordHandshakeDone
+)
+
+// frameRecord records a frame sent, the lost frames are sent again with the latest state
+type frameRecord struct {
+	kind   uint8
+	fin    bool
+	id     uint64
+	offset uint64
+	length uint64
+}
+
+type sentPacket struct {
+	pn           uint64
+	time         time.Time
+	size         int
+	ackEliciting bool
+	frames       []frameRecord
+}
+
+// rttStats estimates the round-trip time, see RFC 9002 section 5
+type rttStats struct {
+	latest   time.Duration
+	smoothed time.Duration
+	variance time.Duration
+	min      time.Duration
+	sampled  bool
+}
+
+func newRTTStats() rttStats {
+	return rttStats{
+		smoothed: initialRTT,
+		variance: initialRTT / 2,
+	}
+}
+
+func (r *rttStats) update(sample, ackDelay time.Duration, confirmed bool, maxAckDelay time.Duration) {
+	r.latest = sample
+	if !r.sampled {
+		r.sampled = true
+		r.min = sample
+		r.smoothed = sample
+		r.variance = sample / 2
+		return
+	}
+	if sample < r.min {
+		r.min = sample
+	}
+	if confirmed && ackDelay > maxAckDelay {
+		ackDelay = maxAckDelay
+	}
+	adjusted := sample
+	if sample >= r.min+ackDelay {
+		adjusted = sample - ackDelay
+	}
+	diff := r.smoothed - adjusted
+	if diff < 0 {
+		diff = -diff
+	}
+	r.variance = (3*r.variance + diff) / 4
+	r.smoothed = (7*r.smoothed + adjusted) / 8
+}
+
+// pto returns the probe timeout without the backoff
+func (r *rttStats) pto(maxAckDelay time.Duration) time.Duration {
+	v := 4 * r.variance
+	if v < timerGranularity {
+		v = timerGranularity
+	}
+	return r.smoothed + v + maxAckDelay
+}
+
+// lossDelay returns the time threshold of the loss detection
+func (r *rttStats) lossDelay() time.Duration {
+	d := r.smoothed
+	if r.latest > d {
+		d = r.latest
+	}
+	d = d * 9 / 8
+	if d < timerGranularity {
+		d = timerGranularity
+	}
+	return d
+}
+
+// newReno is the congestion controller, see RFC 9002 section 7
+type newReno struct {
+	window        int
+	ssthresh      int
+	bytesInFlight int
+	recoveryStart time.Time
+}
+
+func newNewReno() newReno {
+	return newReno{
+		window:   initialWindow,
+		ssthresh: int(^uint(0) >> 1),
+	}
+}
+
+func (c *newReno) canSend() bool {
+	return c.bytesInFlight < c.window
+}
+
+func (c *newReno) onSent(p *sentPacket) {
+	if p.ackEliciting {
+		c.bytesInFlight += p.size
+	}
+}
+
+// onRemoved removes the packet from the bytes in flight without the congestion signal
+func (c *newReno) onRemoved(p *sentPacket) {
+	if p.ackEliciting {
+		c.bytesInFlight -= p.size
+	}
+}
+
+func (c *newReno) onAcked(p *sentPacket) {
+	if !p.ackEliciting {
+		return
+	}
+	c.bytesInFlight -= p.size
+	if !p.time.After(c.recoveryStart) {
+		return
+	}
+	if c.window < c.ssthresh {
+		c.window += p.size
+	} else {
+		c.window += maxDatagramSize * p.size / c.window
+	}
+}
+
+func (c *newReno) onLost(p *sentPacket, now time.Time) {
+	if !p.ackEliciting {
+		return
+	}
+	c.bytesInFlight -= p.size
+	if !p.time.After(c.recoveryStart) {
+		return
+	}
+	c.recoveryStart = now
+	c.ssthresh = c.window >> lossReductionBits
+	if c.ssthresh < minimumWindow {
+		c.ssthresh = minimumWindow
+	}
+	c.window = c.ssthresh
+}
@@ -0,0 +1,641 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quic
+
+import (
+	"time"
+)
+
+// maxCryptoBuffer is the limit of the crypto data buffered out of order
+const maxCryptoBuffer = 64 << 10
+
+// maxRecvRanges is the max number of the ranges of the received packet numbers kept
+const maxRecvRanges = 2 * maxAckRanges
+
+// handlePacket handles the first packet of the datagram, and returns the size of the packet,
+// the rest of the datagram is dropped if zero is returned
+func (c *Conn) handlePacket(b []byte, now time.Time) (int, error) {
+	h, err := parseHeader(b, len(c.srcID))
+	if err != nil {
+		return 0, nil
+	}
+	var level encLevel
+	if h.long {
+		if h.version != Version1 {
+			return 0, nil
+		}
+		switch h.typ {
+		case packetInitial:
+			level = levelInitial
+		case packetHandshake:
+			level = levelHandshake
+		case packet0RTT:
+			// the early data is not supported
+			return h.end, nil
+		default:
+			return 0, nil
+		}
+	} else {
+		level = levelApplication
+	}
+	if c.server && !c.started {
+		if level != levelInitial {
+			return 0, nil
+		}
+		if err := c.startServer(h); err != nil {
+			return 0, err
+		}
+	}
+	s := c.spaces[level]
+	if s.discarded || s.readKeys == nil {
+		return h.end, nil
+	}
+	pkt := b[:h.end]
+	keys := s.readKeys
+	truncated, pnLen, ok := unprotectHeader(keys.hp, pkt, h.pnOffset)
+	if !ok {
+		return h.end, nil
+	}
+	pn := decodePacketNumber(s.largestRecv, truncated, pnLen)
+	keyPhase := false
+	if level == levelApplication {
+		keyPhase = pkt[0]&0x04 != 0
+		if keyPhase != c.keyPhase {
+			keys = c.nextReadKeys
+		}
+	}
+	hdrEnd := h.pnOffset + pnLen
+	payload, err := keys.open(pkt[:hdrEnd], pkt[hdrEnd:], pn)
+	if err != nil {
+		// the corrupted and the undecryptable packets are dropped
+		return h.end, nil
+	}
+	if (h.long && pkt[0]&0x0c != 0) || (!h.long && pkt[0]&0x18 != 0) {
+		return 0, transportError(ErrCodeProtocolViolation, "reserved bits set")
+	}
+	if level == levelApplication && keyPhase != c.keyPhase {
+		if err := c.updateKeys(); err != nil {
+			return 0, err
+		}
+	}
+	if s.recvd.contains(pn) {
+		return h.end, nil
+	}
+	if !c.server && h.long && !c.peerIDKnown {
+		c.dstID = append([]byte(nil), h.srcID...)
+		c.peerIDKnown = true
+	}
+	if c.server && level == levelHandshake {
+		// the handshake packets validate the address of the client
+		c.addressValidated = true
+		c.discardSpace(levelInitial)
+	}
+	ackEliciting, err := c.handleFrames(level, payload, now)
+	if err != nil || c.closed {
+		return 0, err
+	}
+	if s.discarded {
+		return h.end, nil
+	}
+	s.recvd.add(pn, pn+1)
+	if len(s.recvd) > maxRecvRanges {
+		s.recvd = s.recvd[len(s.recvd)-maxRecvRanges:]
+	}
+	if int64(pn) > s.largestRecv {
+		s.largestRecv = int64(pn)
+		s.largestRecvTime = now
+	}
+	s.ackNeeded = true
+	if ackEliciting {
+		s.ackEliciting++
+		if s.ackDeadline.IsZero() {
+			s.ackDeadline = now.Add(defaultMaxAckDelay)
+		}
+	}
+	c.lastActivity = now
+	return h.end, nil
+}
+
+// updateKeys updates the keys after the peer starts a key update, see RFC 9001 section 6
+func (c *Conn) updateKeys() error {
+	s := c.spaces[levelApplication]
+	if !c.handshakeConfirmed {
+		return transportError(ErrCodeKeyUpdate, "key update before the handshake confirmed")
+	}
+	write, err := s.writeKeys.next()
+	if err != nil {
+		return transportError(ErrCodeInternal, err.Error())
+	}
+	next, err := c.nextReadKeys.next()
+	if err != nil {
+		return transportError(ErrCodeInternal, err.Error())
+	}
+	s.readKeys, s.writeKeys, c.nextReadKeys = c.nextReadKeys, write, next
+	c.keyPhase = !c.keyPhase
+	return nil
+}
+
+// frameAllowed reports whether the frame type is allowed in the initial and the handshake packets
+func frameAllowed(level encLevel, typ uint64) bool {
+	if level == levelApplication {
+		return true
+	}
+	switch typ {
+	case framePadding, framePing, frameAck, frameAckECN, frameCrypto, frameConnectionClose:
+		return true
+	}
+	return false
+}
+
+func (c *Conn) handleFrames(level encLevel, payload []byte, now time.Time) (bool, error) {
+	if len(payload) == 0 {
+		return false, transportError(ErrCodeProtocolViolation, "empty packet")
+	}
+	ackEliciting := false
+	r := &reader{b: payload}
+	for !r.empty() {
+		typ := r.varint()
+		if !frameAllowed(level, typ) {
+			return false, &TransportError{Code: ErrCodeProtocolViolation, FrameType: typ, Reason: "unexpected frame"}
+		}
+		switch typ {
+		case framePadding, frameAck, frameAckECN, frameConnectionClose, frameConnectionCloseApp:
+		default:
+			ackEliciting = true
+		}
+		var err error
+		switch {
+		case typ == framePadding, typ == framePing:
+		case typ == frameAck || typ == frameAckECN:
+			ranges, delay, ok := parseAckFrame(r, typ == frameAckECN)
+			if !ok {
+				return false, &TransportError{Code: ErrCodeFrameEncoding, FrameType: typ, Reason: "malformed ACK frame"}
+			}
+			err = c.handleAck(level, ranges, delay, now)
+		case typ == frameResetStream:
+			id, code, finalSize := r.varint(), r.varint(), r.varint()
+			if !r.bad {
+				err = c.handleResetStream(id, code, finalSize)
+			}
+		case typ == frameStopSending:
+			id, code := r.varint(), r.varint()
+			if !r.bad {
+				err = c.handleStopSending(id, code)
+			}
+		case typ == frameCrypto:
+			offset := r.varint()
+			data := r.bytes(r.varint())
+			if !r.bad {
+				err = c.handleCrypto(level, offset, data)
+			}
+		case typ == frameNewToken:
+			token := r.bytes(r.varint())
+			if c.server || (!r.bad && len(token) == 0) {
+				err = &TransportError{Code: ErrCodeProtocolViolation, FrameType: typ, Reason: "unexpected NEW_TOKEN frame"}
+			}
+		case typ >= frameStream && typ <= frameStreamMax:
+			id := r.varint()
+			var offset uint64
+			if typ&0x04 != 0 {
+				offset = r.varint()
+			}
+			var data []byte
+			if typ&0x02 != 0 {
+				data = r.bytes(r.varint())
+			} else {
+				data = r.bytes(uint64(len(r.b)))
+			}
+			if !r.bad {
+				if offset+uint64(len(data)) > MaxVarint {
+					return false, &TransportError{Code: ErrCodeFrameEncoding, FrameType: typ, Reason: "stream data exceeds the max offset"}
+				}
+				err = c.handleStream(id, offset, data, typ&0x01 != 0)
+			}
+		case typ == frameMaxData:
+			if v := r.varint(); v > c.sendMaxData {
+				c.sendMaxData = v
+				for _, st := range c.streams {
+					if st.hasPending(c.sendMaxData - c.sentData) {
+						c.queueStream(st)
+					}
+				}
+			}
+		case typ == frameMaxStreamData:
+			id, v := r.varint(), r.varint()
+			if !r.bad {
+				err = c.handleMaxStreamData(id, v)
+			}
+		case typ == frameMaxStreamsBidi || typ == frameMaxStreamsUni:
+			v := r.varint()
+			if v > 1<<60 {
+				return false, &TransportError{Code: ErrCodeFrameEncoding, FrameType: typ, Reason: "invalid MAX_STREAMS frame"}
+			}
+			if typ == frameMaxStreamsBidi && v > c.peerMaxBidi {
+				c.peerMaxBidi = v
+			} else if typ == frameMaxStreamsUni && v > c.peerMaxUni {
+				c.peerMaxUni = v
+			}
+		case typ == frameDataBlocked, typ == frameStreamsBlockedBidi, typ == frameStreamsBlockedUni,
+			typ == frameRetireConnectionID:
+			r.varint()
+		case typ == frameStreamDataBlocked:
+			r.varint()
+			r.varint()
+		case typ == frameNewConnectionID:
+			// the connection migration is not supported, so the new connection ids are not used
+			r.varint()
+			r.varint()
+			id := r.bytes(uint64(r.uint8()))
+			r.bytes(16)
+			if !r.bad && (len(id) == 0 || len(id) > maxConnIDLen) {
+				err = &TransportError{Code: ErrCodeFrameEncoding, FrameType: typ, Reason: "invalid connection id"}
+			}
+		case typ == framePathChallenge:
+			if data := r.bytes(8); !r.bad {
+				c.pathResponse = append([]byte(nil), data...)
+			}
+		case typ == framePathResponse:
+			r.bytes(8)
+		case typ == frameConnectionClose || typ == frameConnectionCloseApp:
+			code := r.varint()
+			if typ == frameConnectionClose {
+				r.varint()
+			}
+			reason := r.bytes(r.varint())
+			if r.bad {
+				break
+			}
+			if typ == frameConnectionClose {
+				c.terminate(&TransportError{Code: code, Reason: string(reason), Remote: true})
+			} else {
+				c.terminate(&ApplicationError{Code: code, Reason: string(reason), Remote: true})
+			}
+			return false, nil
+		case typ == frameHandshakeDone:
+			if c.server {
+				return false, &TransportError{Code: ErrCodeProtocolViolation, FrameType: typ, Reason: "unexpected HANDSHAKE_DONE frame"}
+			}
+			c.confirmHandshake()
+		default:
+			return false, &TransportError{Code: ErrCodeFrameEncoding, FrameType: typ, Reason: "unknown frame type"}
+		}
+		if r.bad {
+			return false, &TransportError{Code: ErrCodeFrameEncoding, FrameType: typ, Reason: "malformed frame"}
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return ackEliciting, nil
+}
+
+func (c *Conn) handleCrypto(level encLevel, offset uint64, data []byte) error {
+	s := c.spaces[level]
+	if err := s.cryptoRecv.push(offset, data, false); err != nil {
+		return transportError(ErrCodeProtocolViolation, "invalid crypto data")
+	}
+	if s.cryptoRecv.highest-s.cryptoRecv.offset > maxCryptoBuffer {
+		return transportError(ErrCodeCryptoBufferExceeded, "crypto data buffer exceeded")
+	}
+	data = s.cryptoRecv.pop()
+	if len(data) == 0 {
+		return nil
+	}
+	events, err := c.hs.handleData(level, data)
+	if err != nil {
+		return err
+	}
+	return c.handleTLSEvents(events)
+}
+
+func (c *Conn) handleAck(level encLevel, ranges rangeSet, delay uint64, now time.Time) error {
+	s := c.spaces[level]
+	largest := ranges.max()
+	if largest >= s.nextPN {
+		return transportError(ErrCodeProtocolViolation, "ack of the packet not sent")
+	}
+	var acked []*sentPacket
+	kept := s.sent[:0]
+	for _, p := range s.sent {
+		if ranges.contains(p.pn) {
+			acked = append(acked, p)
+		} else {
+			kept = append(kept, p)
+		}
+	}
+	for i := len(kept); i < len(s.sent); i++ {
+		s.sent[i] = nil
+	}
+	s.sent = kept
+	if len(acked) == 0 {
+		return nil
+	}
+	if int64(largest) > s.largestAcked {
+		s.largestAcked = int64(largest)
+	}
+	last := acked[len(acked)-1]
+	if last.pn == largest {
+		for _, p := range acked {
+			if p.ackEliciting {
+				var ackDelay time.Duration
+				maxAckDelay := defaultMaxAckDelay
+				if level == levelApplication && c.peerParams != nil {
+					ackDelay = time.Duration(delay<<c.peerParams.ackDelayExponent) * time.Microsecond
+					maxAckDelay = c.peerParams.maxAckDelay
+				}
+				c.rtt.update(now.Sub(last.time), ackDelay, c.handshakeConfirmed, maxAckDelay)
+				break
+			}
+		}
+	}
+	for _, p := range acked {
+		c.cc.onAcked(p)
+		c.onFramesAcked(level, p)
+	}
+	c.detectLoss(level, now)
+	c.ptoCount = 0
+	return nil
+}
+
+// detectLoss declares the packets lost, see RFC 9002 section 6.1
+func (c *Conn) detectLoss(level encLevel, now time.Time) {
+	s := c.spaces[level]
+	s.lossTime = time.Time{}
+	lossDelay := c.rtt.lossDelay()
+	var lost []*sentPacket
+	kept := s.sent[:0]
+	for _, p := range s.sent {
+		if int64(p.pn) > s.largestAcked {
+			kept = append(kept, p)
+			continue
+		}
+		if !p.time.After(now.Add(-lossDelay)) || s.largestAcked >= int64(p.pn)+packetThreshold {
+			lost = append(lost, p)
+			continue
+		}
+		kept = append(kept, p)
+		if t := p.time.Add(lossDelay); s.lossTime.IsZero() || t.Before(s.lossTime) {
+			s.lossTime = t
+		}
+	}
+	for i := len(kept); i < len(s.sent); i++ {
+		s.sent[i] = nil
+	}
+	s.sent = kept
+	for _, p := range lost {
+		c.cc.onLost(p, now)
+		c.onFramesLost(level, p)
+	}
+}
+
+func (c *Conn) onFramesAcked(level encLevel, p *sentPacket) {
+	for _, f := range p.frames {
+		switch f.kind {
+		case recordCrypto:
+			c.spaces[level].crypto.ack(f.offset, f.length, false)
+		case recordStream:
+			if st := c.streams[f.id]; st != nil {
+				st.send.ack(f.offset, f.length, f.fin)
+				c.maybeRemoveStream(st)
+			}
+		case recordResetStream:
+			if st := c.streams[f.id]; st != nil {
+				st.resetAcked = true
+				c.maybeRemoveStream(st)
+			}
+		}
+	}
+}
+
+// onFramesLost sends the lost frames again with the latest state
+func (c *Conn) onFramesLost(level encLevel, p *sentPacket) {
+	for _, f := range p.frames {
+		switch f.kind {
+		case recordCrypto:
+			c.spaces[level].crypto.lose(f.offset, f.length, false)
+		case recordHandshakeDone:
+			c.handshakeDonePending = true
+		case recordMaxData:
+			c.maxDataPending = true
+		case recordMaxStreamsBidi:
+			c.maxStreamsBidiPending = true
+		case recordMaxStreamsUni:
+			c.maxStreamsUniPending = true
+		default:
+			st := c.streams[f.id]
+			if st == nil {
+				continue
+			}
+			switch f.kind {
+			case recordStream:
+				if st.resetSent {
+					continue
+				}
+				st.send.lose(f.offset, f.length, f.fin)
+			case recordResetStream:
+				if st.resetAcked {
+					continue
+				}
+				st.resetPending = true
+			case recordStopSending:
+				if st.recvClosed {
+					continue
+				}
+				st.stopPending = true
+			case recordMaxStreamData:
+				if st.recv.hasFinal {
+					continue
+				}
+				st.maxStreamDataPending = true
+			}
+			c.queueStream(st)
+		}
+	}
+}
+
+// getStream returns the stream of the frame received, the peer streams are opened implicitly,
+// nil is returned if the stream is closed
+func (c *Conn) getStream(id uint64) (*stream, error) {
+	if st := c.streams[id]; st != nil {
+		return st, nil
+	}
+	idx := id >> 2
+	if StreamIsClientInitiated(id) != c.server {
+		next := c.nextUni
+		if StreamIsBidi(id) {
+			next = c.nextBidi
+		}
+		if idx >= next {
+			return nil, transportError(ErrCodeStreamState, "stream not opened")
+		}
+		return nil, nil
+	}
+	opened, max := &c.peerOpenedUni, c.maxIncomingUni
+	if StreamIsBidi(id) {
+		opened, max = &c.peerOpenedBidi, c.maxIncomingBidi
+	}
+	if idx < *opened {
+		return nil, nil
+	}
+	if idx >= max {
+		return nil, transportError(ErrCodeStreamLimit, "stream limit exceeded")
+	}
+	for i := *opened; i <= idx; i++ {
+		sid := i<<2 | id&0x3
+		c.streams[sid] = c.newStream(sid)
+	}
+	*opened = idx + 1
+	return c.streams[id], nil
+}
+
+// isSendOnly reports whether the stream is a unidirectional stream opened locally
+func (c *Conn) isSendOnly(id uint64) bool {
+	return !StreamIsBidi(id) && StreamIsClientInitiated(id) != c.server
+}
+
+// isRecvOnly reports whether the stream is a unidirectional stream opened by the peer
+func (c *Conn) isRecvOnly(id uint64) bool {
+	return !StreamIsBidi(id) && StreamIsClientInitiated(id) == c.server
+}
+
+func (c *Conn) handleStream(id, offset uint64, data []byte, fin bool) error {
+	if c.isSendOnly(id) {
+		return transportError(ErrCodeStreamState, "STREAM frame on the send only stream")
+	}
+	st, err := c.getStream(id)
+	if st == nil || err != nil {
+		return err
+	}
+	if st.recvClosed {
+		return nil
+	}
+	highest := st.recv.highest
+	if err := st.recv.push(offset, data, fin); err != nil {
+		return transportError(ErrCodeFinalSize, err.Error())
+	}
+	if err := c.onRecvHighest(st, highest); err != nil {
+		return err
+	}
+	c.deliver(st)
+	return nil
+}
+
+// onRecvHighest checks the flow control limits after the highest offset received changed
+func (c *Conn) onRecvHighest(st *stream, prev uint64) error {
+	if st.recv.highest > st.recvMax {
+		return transportError(ErrCodeFlowControl, "stream flow control limit exceeded")
+	}
+	c.recvData += st.recv.highest - prev
+	if c.recvData > c.recvMaxData {
+		return transportError(ErrCodeFlowControl, "connection flow control limit exceeded")
+	}
+	return nil
+}
+
+// deliver delivers the data received in order and updates the flow control limits
+func (c *Conn) deliver(st *stream) {
+	data := st.recv.pop()
+	c.recvConsumed += uint64(len(data))
+	fin := st.recv.finished() && !st.recv.finDelivered
+	if fin {
+		st.recv.finDelivered = true
+		st.recvClosed = true
+	}
+	if (len(data) > 0 || fin) && !st.stopSent {
+		c.events = append(c.events, event{kind: eventStreamData, id: st.id, data: data, fin: fin})
+	}
+	c.updateRecvLimits(st)
+	c.maybeRemoveStream(st)
+}
+
+func (c *Conn) updateRecvLimits(st *stream) {
+	window := c.config.StreamReceiveWindow
+	if !st.recvClosed && !st.recv.hasFinal && st.recvMax-st.recv.offset < window/2 {
+		st.recvMax = st.recv.offset + window
+		st.maxStreamDataPending = true
+		c.queueStream(st)
+	}
+	window = c.config.ConnectionReceiveWindow
+	if c.recvMaxData-c.recvConsumed < window/2 {
+		c.recvMaxData = c.recvConsumed + window
+		c.maxDataPending = true
+	}
+}
+
+func (c *Conn) handleResetStream(id, code, finalSize uint64) error {
+	if c.isSendOnly(id) {
+		return transportError(ErrCodeStreamState, "RESET_STREAM frame on the send only stream")
+	}
+	st, err := c.getStream(id)
+	if st == nil || err != nil {
+		return err
+	}
+	if st.recvClosed {
+		return nil
+	}
+	highest := st.recv.highest
+	if err := st.recv.push(finalSize, nil, true); err != nil {
+		return transportError(ErrCodeFinalSize, err.Error())
+	}
+	if err := c.onRecvHighest(st, highest); err != nil {
+		return err
+	}
+	// the data not delivered is discarded
+	c.recvConsumed += finalSize - st.recv.offset
+	st.recv.offset = finalSize
+	st.recv.chunks = nil
+	st.recvClosed = true
+	st.stopPending = false
+	c.events = append(c.events, event{kind: eventStreamReset, id: id, code: code})
+	c.updateRecvLimits(st)
+	c.maybeRemoveStream(st)
+	return nil
+}
+
+func (c *Conn) handleStopSending(id, code uint64) error {
+	if c.isRecvOnly(id) {
+		return transportError(ErrCodeStreamState, "STOP_SENDING frame on the receive only stream")
+	}
+	st, err := c.getStream(id)
+	if st == nil || err != nil {
+		return err
+	}
+	if st.resetSent || st.send.done() {
+		return nil
+	}
+	c.resetStream(st, code)
+	c.events = append(c.events, event{kind: eventStopSending, id: id, code: code})
+	return nil
+}
+
+func (c *Conn) handleMaxStreamData(id, v uint64) error {
+	if c.isRecvOnly(id) {
+		return transportError(ErrCodeStreamState, "MAX_STREAM_DATA frame on the receive only stream")
+	}
+	st, err := c.getStream(id)
+	if st == nil || err != nil {
+		return err
+	}
+	if v > st.sendMax {
+		st.sendMax = v
+		if st.hasPending(c.sendMaxData - c.sentData) {
+			c.queueStream(st)
+		}
+	}
+	return nil
+}
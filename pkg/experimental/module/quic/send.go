@@ -0,0 +1,355 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quic
+
+import (
+	"time"
+)
+
+// minPacketRoom is the min room of the payload to build a packet
+const minPacketRoom = 32
+
+// maxCloseReasonLen limits the reason phrase of the CONNECTION_CLOSE frame
+const maxCloseReasonLen = 128
+
+type packet struct {
+	level   encLevel
+	payload []byte
+	sent    *sentPacket
+}
+
+// flush sends the pending frames until nothing to send or blocked by the limits
+func (c *Conn) flush(now time.Time) {
+	for !c.closed {
+		if c.closePending {
+			c.sendClose(now)
+			return
+		}
+		datagram := c.buildDatagram(now)
+		if datagram == nil {
+			return
+		}
+		c.sendDatagram(datagram)
+	}
+}
+
+func (c *Conn) sendDatagram(datagram []byte) {
+	c.bytesSent += uint64(len(datagram))
+	c.send(datagram)
+}
+
+// sendLimit returns the max size of the next datagram, see RFC 9000 section 8.1
+func (c *Conn) sendLimit() int {
+	if !c.server || c.addressValidated {
+		return maxDatagramSize
+	}
+	if c.bytesSent >= 3*c.bytesRecv {
+		return 0
+	}
+	if n := 3*c.bytesRecv - c.bytesSent; n < maxDatagramSize {
+		return int(n)
+	}
+	return maxDatagramSize
+}
+
+func (c *Conn) headerLen(level encLevel) int {
+	switch level {
+	case levelInitial:
+		return longHeaderLen(packetInitial, c.dstID, c.srcID)
+	case levelHandshake:
+		return longHeaderLen(packetHandshake, c.dstID, c.srcID)
+	default:
+		return 1 + len(c.dstID)
+	}
+}
+
+// buildDatagram builds a datagram with the packets of all the levels coalesced
+func (c *Conn) buildDatagram(now time.Time) []byte {
+	limit := c.sendLimit()
+	canSend := c.cc.canSend()
+	var packets []packet
+	size := 0
+	padding := false
+	for l := levelInitial; l < numLevels; l++ {
+		s := c.spaces[l]
+		if s.discarded || s.writeKeys == nil {
+			continue
+		}
+		room := limit - size - c.headerLen(l) - packetNumberLen - aeadOverhead
+		if room < minPacketRoom {
+			break
+		}
+		payload, sp := c.buildPayload(l, room, now, canSend || s.probe > 0)
+		if len(payload) == 0 {
+			continue
+		}
+		packets = append(packets, packet{level: l, payload: payload, sent: sp})
+		size += c.headerLen(l) + packetNumberLen + len(payload) + aeadOverhead
+		// the datagrams with the initial packets are padded, see RFC 9000 section 14.1
+		if l == levelInitial && (!c.server || sp.ackEliciting) {
+			padding = true
+		}
+	}
+	if len(packets) == 0 {
+		return nil
+	}
+	if padding && size < minInitialDatagramSize {
+		n := minInitialDatagramSize - size
+		if size+n > limit {
+			n = limit - size
+		}
+		last := &packets[len(packets)-1]
+		last.payload = append(last.payload, make([]byte, n)...)
+		size += n
+	}
+	b := make([]byte, 0, size)
+	for _, p := range packets {
+		b = c.sealPacket(b, p, now)
+	}
+	// the client discards the initial keys after sending the first handshake packet
+	if !c.server {
+		for _, p := range packets {
+			if p.level == levelHandshake {
+				c.discardSpace(levelInitial)
+			}
+		}
+	}
+	return b
+}
+
+// sealPacket appends the protected packet
+func (c *Conn) sealPacket(b []byte, p packet, now time.Time) []byte {
+	s := c.spaces[p.level]
+	pn := s.nextPN
+	s.nextPN++
+	start := len(b)
+	length := packetNumberLen + len(p.payload) + aeadOverhead
+	switch p.level {
+	case levelInitial:
+		b = appendLongHeader(b, packetInitial, c.dstID, c.srcID, length)
+	case levelHandshake:
+		b = appendLongHeader(b, packetHandshake, c.dstID, c.srcID, length)
+	default:
+		b = appendShortHeader(b, c.dstID, c.keyPhase)
+	}
+	pnOffset := len(b) - start
+	b = appendPacketNumber(b, pn)
+	hdrEnd := len(b)
+	b = append(b, p.payload...)
+	b = append(b, make([]byte, aeadOverhead)...)
+	s.writeKeys.seal(b[start:hdrEnd], b[hdrEnd:len(b)-aeadOverhead], pn)
+	protectHeader(s.writeKeys.hp, b[start:], pnOffset)
+
+	sp := p.sent
+	if !sp.ackEliciting {
+		return b
+	}
+	sp.pn = pn
+	sp.time = now
+	sp.size = len(b) - start
+	s.sent = append(s.sent, sp)
+	s.lastAckElicitingTime = now
+	if s.probe > 0 {
+		s.probe--
+	}
+	c.cc.onSent(sp)
+	c.lastSendTime = now
+	c.lastActivity = now
+	return b
+}
+
+// buildPayload builds the frames of a packet, the ack-eliciting frames are not sent if not allowed
+func (c *Conn) buildPayload(level encLevel, room int, now time.Time, allowed bool) ([]byte, *sentPacket) {
+	s := c.spaces[level]
+	sp := &sentPacket{}
+	var ack []byte
+	mustAck := false
+	if s.ackNeeded && len(s.recvd) > 0 {
+		delay := uint64(now.Sub(s.largestRecvTime)/time.Microsecond) >> defaultAckDelayExponent
+		ack = appendAckFrame(nil, s.recvd, delay)
+		mustAck = s.ackEliciting > 0 && (level != levelApplication || s.ackEliciting >= 2 || !now.Before(s.ackDeadline))
+		room -= len(ack)
+	}
+	var b []byte
+	if allowed {
+		b = c.appendFrames(level, b, room, sp)
+		if s.probe > 0 && len(b) == 0 {
+			b = append(b, framePing)
+		}
+	}
+	sp.ackEliciting = len(b) > 0
+	if len(ack) > 0 && (mustAck || len(b) > 0) {
+		b = append(ack, b...)
+		s.ackNeeded = false
+		s.ackEliciting = 0
+		s.ackDeadline = time.Time{}
+	}
+	return b, sp
+}
+
+func (c *Conn) appendFrames(level encLevel, b []byte, room int, sp *sentPacket) []byte {
+	s := c.spaces[level]
+	for s.crypto.pending(MaxVarint) {
+		n := room - len(b) - cryptoFrameOverhead(s.crypto.end(), room)
+		if n <= 0 {
+			return b
+		}
+		offset, data, _ := s.crypto.next(n, MaxVarint)
+		b = appendCryptoFrame(b, offset, data)
+		sp.frames = append(sp.frames, frameRecord{kind: recordCrypto, offset: offset, length: uint64(len(data))})
+	}
+	if level != levelApplication {
+		return b
+	}
+	// the control frames are small enough to skip the room checks before the stream frames
+	if c.handshakeDonePending {
+		c.handshakeDonePending = false
+		b = append(b, frameHandshakeDone)
+		sp.frames = append(sp.frames, frameRecord{kind: recordHandshakeDone})
+	}
+	if c.pathResponse != nil {
+		b = append(b, framePathResponse)
+		b = append(b, c.pathResponse...)
+		c.pathResponse = nil
+	}
+	if c.maxDataPending {
+		c.maxDataPending = false
+		b = append(b, frameMaxData)
+		b = AppendVarint(b, c.recvMaxData)
+		sp.frames = append(sp.frames, frameRecord{kind: recordMaxData})
+	}
+	if c.maxStreamsBidiPending {
+		c.maxStreamsBidiPending = false
+		b = append(b, frameMaxStreamsBidi)
+		b = AppendVarint(b, c.maxIncomingBidi)
+		sp.frames = append(sp.frames, frameRecord{kind: recordMaxStreamsBidi})
+	}
+	if c.maxStreamsUniPending {
+		c.maxStreamsUniPending = false
+		b = append(b, frameMaxStreamsUni)
+		b = AppendVarint(b, c.maxIncomingUni)
+		sp.frames = append(sp.frames, frameRecord{kind: recordMaxStreamsUni})
+	}
+	if c.pingPending {
+		c.pingPending = false
+		b = append(b, framePing)
+	}
+	for len(c.sendQueue) > 0 && room-len(b) >= minPacketRoom {
+		st := c.sendQueue[0]
+		c.sendQueue[0] = nil
+		c.sendQueue = c.sendQueue[1:]
+		st.queued = false
+		if c.streams[st.id] != st {
+			continue
+		}
+		b = c.appendStreamFrames(st, b, room, sp)
+		if st.hasPending(c.sendMaxData - c.sentData) {
+			c.queueStream(st)
+		}
+	}
+	return b
+}
+
+func (c *Conn) appendStreamFrames(st *stream, b []byte, room int, sp *sentPacket) []byte {
+	if st.resetPending {
+		st.resetPending = false
+		b = append(b, frameResetStream)
+		b = AppendVarint(b, st.id)
+		b = AppendVarint(b, st.resetCode)
+		b = AppendVarint(b, st.send.sent)
+		sp.frames = append(sp.frames, frameRecord{kind: recordResetStream, id: st.id})
+	}
+	if st.stopPending {
+		st.stopPending = false
+		b = append(b, frameStopSending)
+		b = AppendVarint(b, st.id)
+		b = AppendVarint(b, st.stopCode)
+		sp.frames = append(sp.frames, frameRecord{kind: recordStopSending, id: st.id})
+	}
+	if st.maxStreamDataPending {
+		st.maxStreamDataPending = false
+		b = append(b, frameMaxStreamData)
+		b = AppendVarint(b, st.id)
+		b = AppendVarint(b, st.recvMax)
+		sp.frames = append(sp.frames, frameRecord{kind: recordMaxStreamData, id: st.id})
+	}
+	if st.resetSent {
+		return b
+	}
+	limit := st.sendMax
+	if connLimit := st.send.sent + c.sendMaxData - c.sentData; connLimit < limit {
+		limit = connLimit
+	}
+	if !st.send.pending(limit) {
+		return b
+	}
+	n := room - len(b) - streamFrameOverhead(st.id, st.send.end(), room)
+	if n <= 0 {
+		return b
+	}
+	sent := st.send.sent
+	offset, data, fin := st.send.next(n, limit)
+	c.sentData += st.send.sent - sent
+	b = appendStreamFrame(b, st.id, offset, data, fin)
+	sp.frames = append(sp.frames, frameRecord{kind: recordStream, id: st.id, offset: offset,
+		length: uint64(len(data)), fin: fin})
+	return b
+}
+
+// sendClose sends the CONNECTION_CLOSE frame at the highest level available and closes
+// the connection immediately, the draining period is not kept
+func (c *Conn) sendClose(now time.Time) {
+	c.closePending = false
+	level := encLevel(-1)
+	for l := levelInitial; l < numLevels; l++ {
+		if s := c.spaces[l]; !s.discarded && s.writeKeys != nil {
+			level = l
+		}
+	}
+	if level >= 0 && c.sendLimit() > 0 {
+		var payload []byte
+		switch err := c.closeErr.(type) {
+		case *ApplicationError:
+			if level == levelApplication {
+				reason := err.Reason
+				if len(reason) > maxCloseReasonLen {
+					reason = reason[:maxCloseReasonLen]
+				}
+				payload = appendConnectionCloseFrame(payload, true, err.Code, 0, reason)
+			} else {
+				// the application error is hidden before the handshake completes, see RFC 9000 section 10.2.3
+				payload = appendConnectionCloseFrame(payload, false, ErrCodeApplication, 0, "")
+			}
+		case *TransportError:
+			reason := err.Reason
+			if len(reason) > maxCloseReasonLen {
+				reason = reason[:maxCloseReasonLen]
+			}
+			payload = appendConnectionCloseFrame(payload, false, err.Code, err.FrameType, reason)
+		default:
+			payload = appendConnectionCloseFrame(payload, false, ErrCodeInternal, 0, "")
+		}
+		size := c.headerLen(level) + packetNumberLen + len(payload) + aeadOverhead
+		if level == levelInitial && !c.server && size < minInitialDatagramSize {
+			payload = append(payload, make([]byte, minInitialDatagramSize-size)...)
+		}
+		b := c.sealPacket(nil, packet{level: level, payload: payload, sent: &sentPacket{}}, now)
+		c.sendDatagram(b)
+	}
+	c.terminate(c.closeErr)
+}
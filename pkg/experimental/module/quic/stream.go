@@ -0,0 +1,83 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quic
+
+// StreamIsBidi reports whether the stream is bidirectional
+func StreamIsBidi(id uint64) bool {
+	return id&0x2 == 0
+}
+
+// StreamIsClientInitiated reports whether the stream is opened by the client
+func StreamIsClientInitiated(id uint64) bool {
+	return id&0x1 == 0
+}
+
+// stream is the state of a stream, the data received is delivered to the handler directly
+type stream struct {
+	id   uint64
+	send sendBuffer
+	recv recvBuffer
+	// sendMax is the send limit of the peer
+	sendMax uint64
+	// recvMax is the receive limit sent to the peer
+	recvMax uint64
+	// queued reports whether the stream is in the send queue
+	queued bool
+
+	maxStreamDataPending bool
+
+	// resetSent reports whether the sending part is reset by the application
+	resetSent    bool
+	resetPending bool
+	resetAcked   bool
+	resetCode    uint64
+
+	stopSent    bool
+	stopPending bool
+	stopCode    uint64
+
+	// recvClosed reports whether all the data or the reset is received
+	recvClosed bool
+}
+
+// writable reports whether the application can write into the stream
+func (s *stream) writable() bool {
+	return !s.send.fin && !s.resetSent
+}
+
+// sendDone reports whether the sending part is finished, the reset must be acked
+func (s *stream) sendDone() bool {
+	if s.resetSent {
+		return s.resetAcked
+	}
+	return s.send.done()
+}
+
+func (s *stream) hasPending(connLimit uint64) bool {
+	if s.resetPending || s.stopPending || s.maxStreamDataPending {
+		return true
+	}
+	if s.resetSent {
+		return false
+	}
+	limit := s.sendMax
+	if s.send.sent+connLimit < limit {
+		limit = s.send.sent + connLimit
+	}
+	return s.send.pending(limit)
+}
@@ -0,0 +1,73 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quic
+
+import (
+	"crypto/tls"
+	"errors"
+)
+
+// ErrUnsupported is returned by the handshake if Supported is false
+var ErrUnsupported = errors.New("quic: the TLS handshake requires go1.21 or later")
+
+// encLevel is the encryption level and the packet number space
+type encLevel int
+
+const (
+	levelInitial encLevel = iota
+	levelHandshake
+	levelApplication
+	numLevels
+)
+
+func (l encLevel) String() string {
+	switch l {
+	case levelInitial:
+		return "initial"
+	case levelHandshake:
+		return "handshake"
+	default:
+		return "application"
+	}
+}
+
+// the kinds of the events of the TLS handshake
+const (
+	tlsWriteData = iota
+	tlsSetReadSecret
+	tlsSetWriteSecret
+	tlsTransportParams
+	tlsHandshakeDone
+)
+
+type tlsEvent struct {
+	kind  int
+	level encLevel
+	suite uint16
+	data  []byte
+}
+
+// handshaker runs the TLS 1.3 handshake over the CRYPTO frames, see RFC 9001
+type handshaker interface {
+	// start starts the handshake with the local transport parameters
+	start(params []byte) ([]tlsEvent, error)
+	// handleData handles the handshake data received
+	handleData(level encLevel, data []byte) ([]tlsEvent, error)
+	connectionState() tls.ConnectionState
+	close()
+}
@@ -0,0 +1,132 @@
+//go:build go1.21
+// +build go1.21
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quic
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+)
+
+// Supported is true if the QUIC API of crypto/tls is provided by the toolchain
+const Supported = true
+
+type tlsHandshaker struct {
+	conn *tls.QUICConn
+}
+
+func newHandshaker(config *tls.Config, server bool) (handshaker, error) {
+	if config == nil {
+		return nil, errors.New("quic: tls config is required")
+	}
+	config = config.Clone()
+	config.MinVersion = tls.VersionTLS13
+	qc := &tls.QUICConfig{TLSConfig: config}
+	if server {
+		return &tlsHandshaker{conn: tls.QUICServer(qc)}, nil
+	}
+	return &tlsHandshaker{conn: tls.QUICClient(qc)}, nil
+}
+
+func (h *tlsHandshaker) start(params []byte) ([]tlsEvent, error) {
+	h.conn.SetTransportParameters(params)
+	if err := h.conn.Start(context.Background()); err != nil {
+		return nil, cryptoError(err)
+	}
+	return h.events()
+}
+
+func (h *tlsHandshaker) handleData(level encLevel, data []byte) ([]tlsEvent, error) {
+	if err := h.conn.HandleData(toTLSLevel(level), data); err != nil {
+		return nil, cryptoError(err)
+	}
+	return h.events()
+}
+
+func (h *tlsHandshaker) events() ([]tlsEvent, error) {
+	var events []tlsEvent
+	for {
+		e := h.conn.NextEvent()
+		switch e.Kind {
+		case tls.QUICNoEvent:
+			return events, nil
+		case tls.QUICSetReadSecret:
+			if e.Level == tls.QUICEncryptionLevelEarly {
+				// the early data is not supported
+				continue
+			}
+			events = append(events, tlsEvent{kind: tlsSetReadSecret, level: fromTLSLevel(e.Level),
+				suite: e.Suite, data: append([]byte(nil), e.Data...)})
+		case tls.QUICSetWriteSecret:
+			if e.Level == tls.QUICEncryptionLevelEarly {
+				continue
+			}
+			events = append(events, tlsEvent{kind: tlsSetWriteSecret, level: fromTLSLevel(e.Level),
+				suite: e.Suite, data: append([]byte(nil), e.Data...)})
+		case tls.QUICWriteData:
+			events = append(events, tlsEvent{kind: tlsWriteData, level: fromTLSLevel(e.Level),
+				data: append([]byte(nil), e.Data...)})
+		case tls.QUICTransportParameters:
+			events = append(events, tlsEvent{kind: tlsTransportParams, data: append([]byte(nil), e.Data...)})
+		case tls.QUICHandshakeDone:
+			events = append(events, tlsEvent{kind: tlsHandshakeDone})
+		}
+	}
+}
+
+func (h *tlsHandshaker) connectionState() tls.ConnectionState {
+	return h.conn.ConnectionState()
+}
+
+func (h *tlsHandshaker) close() {
+	h.conn.Close()
+}
+
+func toTLSLevel(l encLevel) tls.QUICEncryptionLevel {
+	switch l {
+	case levelInitial:
+		return tls.QUICEncryptionLevelInitial
+	case levelHandshake:
+		return tls.QUICEncryptionLevelHandshake
+	default:
+		return tls.QUICEncryptionLevelApplication
+	}
+}
+
+func fromTLSLevel(l tls.QUICEncryptionLevel) encLevel {
+	switch l {
+	case tls.QUICEncryptionLevelInitial:
+		return levelInitial
+	case tls.QUICEncryptionLevelHandshake:
+		return levelHandshake
+	default:
+		return levelApplication
+	}
+}
+
+// cryptoError converts the TLS alert into the transport error, see RFC 9001 section 4.8
+func cryptoError(err error) error {
+	var alert tls.AlertError
+	if errors.As(err, &alert) {
+		return &TransportError{Code: ErrCodeCrypto + uint64(alert), Reason: err.Error()}
+	}
+	return &TransportError{Code: ErrCodeInternal, Reason: err.Error()}
+}
@@ -0,0 +1,33 @@
+//go:build !go1.21
+// +build !go1.21
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quic
+
+import (
+	"crypto/tls"
+)
+
+// Supported is false without the QUIC API of crypto/tls added in go1.21,
+// the users of this package should reject the configs depending on it
+const Supported = false
+
+func newHandshaker(config *tls.Config, server bool) (handshaker, error) {
+	return nil, ErrUnsupported
+}
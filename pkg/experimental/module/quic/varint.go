@@ -0,0 +1,111 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package quic
+
+// MaxVarint is the maximum value of the variable-length integers
+const MaxVarint = 1<<62 - 1
+
+// VarintLen returns the size of the variable-length integer encoding of v
+func VarintLen(v uint64) int {
+	switch {
+	case v <= 63:
+		return 1
+	case v <= 16383:
+		return 2
+	case v <= 1073741823:
+		return 4
+	default:
+		return 8
+	}
+}
+
+// AppendVarint appends the variable-length integer encoding of v
+func AppendVarint(b []byte, v uint64) []byte {
+	switch VarintLen(v) {
+	case 1:
+		return append(b, byte(v))
+	case 2:
+		return append(b, byte(v>>8)|0x40, byte(v))
+	case 4:
+		return append(b, byte(v>>24)|0x80, byte(v>>16), byte(v>>8), byte(v))
+	default:
+		return append(b, byte(v>>56)|0xc0, byte(v>>48), byte(v>>40), byte(v>>32),
+			byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	}
+}
+
+// ReadVarint decodes a variable-length integer, it returns the value and the size,
+// the size is 0 if the data is incomplete
+func ReadVarint(b []byte) (uint64, int) {
+	if len(b) == 0 {
+		return 0, 0
+	}
+	n := 1 << (b[0] >> 6)
+	if len(b) < n {
+		return 0, 0
+	}
+	v := uint64(b[0] & 0x3f)
+	for i := 1; i < n; i++ {
+		v = v<<8 | uint64(b[i])
+	}
+	return v, n
+}
+
+// reader decodes the fields of the frames and the transport parameters,
+// the fields read after an error are zero values
+type reader struct {
+	b   []byte
+	bad bool
+}
+
+func (r *reader) empty() bool {
+	return len(r.b) == 0
+}
+
+func (r *reader) varint() uint64 {
+	v, n := ReadVarint(r.b)
+	if n == 0 {
+		r.bad = true
+		r.b = nil
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *reader) uint8() uint8 {
+	if len(r.b) < 1 {
+		r.bad = true
+		r.b = nil
+		return 0
+	}
+	v := r.b[0]
+	r.b = r.b[1:]
+	return v
+}
+
+func (r *reader) bytes(n uint64) []byte {
+	if uint64(len(r.b)) < n {
+		r.bad = true
+		r.b = nil
+		return nil
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
@@ -0,0 +1,160 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http3
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"mosn.io/mosn/pkg/module/http2/hpack"
+)
+
+var (
+	errMalformedRequest  = errors.New("http3: malformed request header")
+	errMalformedResponse = errors.New("http3: malformed response header")
+)
+
+// hopHeaders are the connection-specific headers, which are not allowed in HTTP/3
+var hopHeaders = map[string]bool{
+	"connection":        true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+	"host":              true,
+}
+
+// appendHeaderFields appends the regular header fields, the connection-specific headers are dropped
+func appendHeaderFields(fields []hpack.HeaderField, h http.Header) []hpack.HeaderField {
+	for k, vv := range h {
+		name := strings.ToLower(k)
+		if hopHeaders[name] {
+			continue
+		}
+		for _, v := range vv {
+			if name == "te" && !strings.EqualFold(v, "trailers") {
+				continue
+			}
+			fields = append(fields, hpack.HeaderField{Name: name, Value: v})
+		}
+	}
+	return fields
+}
+
+// decodeRequest converts the header fields of a request to a http.Request
+func decodeRequest(fields []hpack.HeaderField) (*http.Request, error) {
+	var method, scheme, authority, path string
+	header := make(http.Header, len(fields))
+	for _, f := range fields {
+		switch f.Name {
+		case ":method":
+			method = f.Value
+		case ":scheme":
+			scheme = f.Value
+		case ":authority":
+			authority = f.Value
+		case ":path":
+			path = f.Value
+		default:
+			if strings.HasPrefix(f.Name, ":") {
+				return nil, errMalformedRequest
+			}
+			addHeader(header, f)
+		}
+	}
+	if method == "" || (method != http.MethodConnect && (scheme == "" || path == "")) {
+		return nil, errMalformedRequest
+	}
+	if authority == "" {
+		authority = header.Get("Host")
+	}
+	header.Del("Host")
+	req := &http.Request{
+		Method:     method,
+		Proto:      "HTTP/3.0",
+		ProtoMajor: 3,
+		Header:     header,
+		Host:       authority,
+		RequestURI: path,
+	}
+	var err error
+	if method == http.MethodConnect {
+		req.URL = &url.URL{Host: authority}
+	} else if req.URL, err = url.ParseRequestURI(path); err != nil {
+		return nil, errMalformedRequest
+	}
+	if cl := header.Get("Content-Length"); cl != "" {
+		req.ContentLength, _ = strconv.ParseInt(cl, 10, 64)
+	}
+	return req, nil
+}
+
+// decodeResponse converts the header fields of a response to a http.Response
+func decodeResponse(fields []hpack.HeaderField) (*http.Response, error) {
+	status := -1
+	header := make(http.Header, len(fields))
+	for _, f := range fields {
+		if f.Name == ":status" {
+			code, err := strconv.Atoi(f.Value)
+			if err != nil {
+				return nil, errMalformedResponse
+			}
+			status = code
+			continue
+		}
+		if strings.HasPrefix(f.Name, ":") {
+			return nil, errMalformedResponse
+		}
+		addHeader(header, f)
+	}
+	if status < 100 || status > 999 {
+		return nil, errMalformedResponse
+	}
+	return &http.Response{
+		Status:     strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode: status,
+		Proto:      "HTTP/3.0",
+		ProtoMajor: 3,
+		Header:     header,
+	}, nil
+}
+
+// decodeTrailer converts the trailer fields to a http.Header, the pseudo headers are ignored
+func decodeTrailer(fields []hpack.HeaderField) http.Header {
+	trailer := make(http.Header, len(fields))
+	for _, f := range fields {
+		if !strings.HasPrefix(f.Name, ":") {
+			addHeader(trailer, f)
+		}
+	}
+	return trailer
+}
+
+// addHeader adds the field, the cookie fields are joined as RFC 9114 section 4.2.1
+func addHeader(header http.Header, f hpack.HeaderField) {
+	if f.Name == "cookie" {
+		if v := header.Get("Cookie"); v != "" {
+			header.Set("Cookie", v+"; "+f.Value)
+			return
+		}
+	}
+	header.Add(f.Name, f.Value)
+}
@@ -0,0 +1,1052 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http3
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/experimental/module/http3"
+	"mosn.io/mosn/pkg/experimental/module/quic"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/module/http2/hpack"
+	"mosn.io/mosn/pkg/mtls"
+	"mosn.io/mosn/pkg/protocol"
+	mhttp2 "mosn.io/mosn/pkg/protocol/http2"
+	str "mosn.io/mosn/pkg/stream"
+	"mosn.io/mosn/pkg/trace"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+)
+
+func init() {
+	// the protocol is not registered if the toolchain does not support the QUIC handshake,
+	// so the listeners and the clusters using it are rejected when the config is loaded
+	if !quic.Supported {
+		log.StartLogger.Warnf("[stream] [http3] %v, the protocol %s is not registered", quic.ErrUnsupported, protocol.HTTP3)
+		return
+	}
+	protocol.RegisterProtocolConfigHandler(protocol.HTTP3, streamConfigHandler)
+	protocol.RegisterProtocol(protocol.HTTP3, NewConnPool, &StreamConnFactory{}, protocol.GetStatusCodeMapping{})
+}
+
+var (
+	errNoTLSConfig  = errors.New("http3 requires the tls config of the listener")
+	errNotConnected = errors.New("http3 connection is not established")
+)
+
+type StreamConnFactory struct{}
+
+func (f *StreamConnFactory) CreateClientStream(context context.Context, connection types.ClientConnection,
+	clientCallbacks types.StreamConnectionEventListener, connCallbacks api.ConnectionEventListener) types.ClientStreamConnection {
+	return newClientStreamConnection(context, connection, clientCallbacks)
+}
+
+func (f *StreamConnFactory) CreateServerStream(context context.Context, connection api.Connection,
+	serverCallbacks types.ServerStreamConnectionEventListener) types.ServerStreamConnection {
+	return newServerStreamConnection(context, connection, serverCallbacks)
+}
+
+func (f *StreamConnFactory) CreateBiDirectStream(context context.Context, connection types.ClientConnection,
+	clientCallbacks types.StreamConnectionEventListener,
+	serverCallbacks types.ServerStreamConnectionEventListener) types.ClientStreamConnection {
+	return nil
+}
+
+// ProtocolMatch matches the QUIC initial packet, which is the first datagram of a HTTP/3 connection
+func (f *StreamConnFactory) ProtocolMatch(context context.Context, prot string, magic []byte) error {
+	if quic.IsInitialPacket(magic) {
+		return nil
+	}
+	if len(magic) < 5 && (len(magic) == 0 || magic[0]&0xf0 == 0xc0) {
+		return str.EAGAIN
+	}
+	return str.FAILED
+}
+
+type StreamConfig struct {
+	// MaxConcurrentStreams limits the requests the peer can send concurrently
+	MaxConcurrentStreams int `json:"max_concurrent_streams,omitempty"`
+	// IdleTimeout closes the connection without any activity
+	IdleTimeout api.DurationConfig `json:"idle_timeout,omitempty"`
+	// KeepAlivePeriod sends PING frames to keep the connection alive, zero means no keepalive
+	KeepAlivePeriod api.DurationConfig `json:"keepalive_period,omitempty"`
+}
+
+var defaultStreamConfig = StreamConfig{
+	MaxConcurrentStreams: quic.DefaultMaxIncomingStreams,
+	IdleTimeout:          api.DurationConfig{Duration: quic.DefaultMaxIdleTimeout},
+}
+
+func (c StreamConfig) quicConfig(tlsConfig *tls.Config) *quic.Config {
+	return &quic.Config{
+		TLSConfig:          tlsConfig,
+		MaxIdleTimeout:     c.IdleTimeout.Duration,
+		KeepAlivePeriod:    c.KeepAlivePeriod.Duration,
+		MaxIncomingStreams: c.MaxConcurrentStreams,
+	}
+}
+
+func streamConfigHandler(v interface{}) interface{} {
+	extendConfig, ok := v.(map[string]interface{})
+	if !ok {
+		return defaultStreamConfig
+	}
+
+	config, ok := extendConfig[string(protocol.HTTP3)]
+	if !ok {
+		config = extendConfig
+	}
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return defaultStreamConfig
+	}
+	streamConfig := defaultStreamConfig
+	if err := json.Unmarshal(configBytes, &streamConfig); err != nil {
+		return defaultStreamConfig
+	}
+
+	return streamConfig
+}
+
+func parseStreamConfig(ctx context.Context) StreamConfig {
+	streamConfig := defaultStreamConfig
+	// get extend config from ctx
+	if pgc, err := variable.Get(ctx, types.VariableProxyGeneralConfig); err == nil {
+		if extendConfig, ok := pgc.(map[api.ProtocolName]interface{}); ok {
+			if http3Config, ok := extendConfig[protocol.HTTP3]; ok {
+				if cfg, ok := http3Config.(StreamConfig); ok {
+					streamConfig = cfg
+				}
+			}
+		}
+	}
+	return streamConfig
+}
+
+// serverTLSConfig returns the tls config of the listener, the udp connections
+// are not wrapped by the tls context manager
+func serverTLSConfig(ctx context.Context) (*tls.Config, error) {
+	v, err := variable.Get(ctx, types.VariableListenerTLSManager)
+	if err != nil {
+		return nil, errNoTLSConfig
+	}
+	mng, ok := v.(types.TLSContextManager)
+	if !ok {
+		return nil, errNoTLSConfig
+	}
+	return mtls.QUICServerConfig(mng, []string{http3.NextProtoH3})
+}
+
+// tlsConfigKey is the context key of the client tls config, which is set by the connection pool
+type tlsConfigKey struct{}
+
+// types.StreamConnection
+type streamConnection struct {
+	ctx    context.Context
+	conn   api.Connection
+	cm     *str.ContextManager
+	h3     *http3.Conn
+	config StreamConfig
+}
+
+func (conn *streamConnection) Protocol() types.ProtocolName {
+	return protocol.HTTP3
+}
+
+func (conn *streamConnection) EnableWorkerPool() bool {
+	return true
+}
+
+// send writes a datagram, the data is reused by the transport so it must be copied
+func (conn *streamConnection) send(b []byte) error {
+	return conn.conn.Write(buffer.NewIoBufferBytes(append([]byte(nil), b...)))
+}
+
+// writeMessage writes the headers, the body and the trailers of a message
+func (conn *streamConnection) writeMessage(id uint64, fields []hpack.HeaderField, data types.IoBuffer, trailer http.Header) error {
+	hasData := data != nil && data.Len() > 0
+	if err := conn.h3.WriteHeaders(id, fields, !hasData && trailer == nil); err != nil {
+		return err
+	}
+	if hasData {
+		if err := conn.h3.WriteData(id, data.Bytes(), trailer == nil); err != nil {
+			return err
+		}
+	}
+	if trailer != nil {
+		return conn.h3.WriteTrailers(id, appendHeaderFields(nil, trailer))
+	}
+	return nil
+}
+
+// closeEvent returns the connection event of the closed HTTP/3 connection
+func closeEvent(err error) api.ConnectionEvent {
+	switch e := err.(type) {
+	case *quic.ApplicationError:
+		if e.Remote {
+			return api.RemoteClose
+		}
+	case *quic.TransportError:
+		if e.Remote {
+			return api.RemoteClose
+		}
+	}
+	return api.LocalClose
+}
+
+// types.Stream
+// types.StreamSender
+type stream struct {
+	str.BaseStream
+
+	ctx      context.Context
+	receiver types.StreamReceiveListener
+
+	id      uint64
+	header  types.HeaderMap
+	recData types.IoBuffer
+	trailer *mhttp2.HeaderMap
+	conn    api.Connection
+
+	// the message is sent when the stream ends
+	sendHeader  http.Header
+	sendData    types.IoBuffer
+	sendTrailer http.Header
+}
+
+// ~~ types.Stream
+func (s *stream) ID() uint64 {
+	return s.id
+}
+
+func (s *stream) ReadDisable(disable bool) {
+	s.conn.SetReadDisable(disable)
+}
+
+func (s *stream) BufferLimit() uint32 {
+	return s.conn.BufferLimit()
+}
+
+func (s *stream) GetStream() types.Stream {
+	return s
+}
+
+func (s *stream) appendTrailers(trailers api.HeaderMap) {
+	if trailers == nil {
+		return
+	}
+	switch trailer := trailers.(type) {
+	case *mhttp2.HeaderMap:
+		s.sendTrailer = trailer.H
+	default:
+		s.sendTrailer = mhttp2.EncodeHeader(trailer)
+	}
+}
+
+// receiveData buffers the body received
+func (s *stream) receiveData(data []byte) {
+	if s.recData == nil {
+		s.recData = buffer.GetIoBuffer(len(data))
+	}
+	s.recData.Write(data)
+}
+
+// receiveEnd delivers the message received
+func (s *stream) receiveEnd() {
+	if s.receiver == nil {
+		return
+	}
+	if s.recData == nil {
+		s.recData = buffer.GetIoBuffer(0)
+	}
+	s.receiver.OnReceive(s.ctx, s.header, s.recData, s.trailer)
+}
+
+type serverStreamConnection struct {
+	streamConnection
+	mutex   sync.RWMutex
+	streams map[uint64]*serverStream
+
+	serverCallbacks types.ServerStreamConnectionEventListener
+}
+
+func newServerStreamConnection(ctx context.Context, connection api.Connection, serverCallbacks types.ServerStreamConnectionEventListener) types.ServerStreamConnection {
+	sc := &serverStreamConnection{
+		streamConnection: streamConnection{
+			ctx:    ctx,
+			conn:   connection,
+			cm:     str.NewContextManager(ctx),
+			config: parseStreamConfig(ctx),
+		},
+		streams:         make(map[uint64]*serverStream, 32),
+		serverCallbacks: serverCallbacks,
+	}
+
+	// init first context
+	sc.cm.Next()
+
+	// set not support transfer connection
+	sc.conn.SetTransferEventListener(func() bool {
+		return false
+	})
+
+	tlsConfig, err := serverTLSConfig(ctx)
+	if err == nil {
+		sc.h3, err = http3.NewServerConn(sc.config.quicConfig(tlsConfig), sc.send, sc)
+	}
+	if err != nil {
+		log.Proxy.Errorf(ctx, "new http3 server stream connection failed: %v", err)
+	}
+
+	connection.AddConnectionEventListener(sc)
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(ctx, "new http3 server stream connection, stream config: %v", sc.config)
+	}
+
+	return sc
+}
+
+func (conn *serverStreamConnection) GoAway() {
+	if conn.h3 != nil {
+		conn.h3.GoAway()
+	}
+}
+
+func (conn *serverStreamConnection) OnEvent(event api.ConnectionEvent) {
+	if event.IsClose() || event.ConnectFailure() {
+		if conn.h3 != nil {
+			conn.h3.Close(http3.ErrCodeNoError)
+		}
+		for _, stream := range conn.removeStreams() {
+			stream.stream.ResetStream(types.StreamRemoteReset)
+		}
+	}
+}
+
+// types.StreamConnection
+func (conn *serverStreamConnection) Dispatch(buf types.IoBuffer) {
+	if conn.h3 == nil {
+		buf.Drain(buf.Len())
+		conn.conn.Close(api.NoFlush, api.LocalClose)
+		return
+	}
+	// each buffer is a datagram received
+	conn.h3.Receive(buf.Bytes())
+	buf.Drain(buf.Len())
+}
+
+func (conn *serverStreamConnection) ActiveStreamsNum() int {
+	conn.mutex.RLock()
+	defer conn.mutex.RUnlock()
+
+	return len(conn.streams)
+}
+
+func (conn *serverStreamConnection) CheckReasonError(connected bool, event api.ConnectionEvent) (types.StreamResetReason, bool) {
+	reason := types.StreamConnectionSuccessed
+	if event.IsClose() || event.ConnectFailure() {
+		reason = types.StreamConnectionFailed
+		if connected {
+			reason = types.StreamConnectionTermination
+		}
+		return reason, false
+	}
+
+	return reason, true
+}
+
+func (conn *serverStreamConnection) Reset(reason types.StreamResetReason) {
+	conn.mutex.RLock()
+	streams := make([]*serverStream, 0, len(conn.streams))
+	for _, stream := range conn.streams {
+		streams = append(streams, stream)
+	}
+	conn.mutex.RUnlock()
+
+	for _, stream := range streams {
+		stream.ResetStream(reason)
+	}
+}
+
+func (conn *serverStreamConnection) removeStreams() map[uint64]*serverStream {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+	streams := conn.streams
+	conn.streams = make(map[uint64]*serverStream)
+	return streams
+}
+
+func (conn *serverStreamConnection) getStream(id uint64) *serverStream {
+	conn.mutex.RLock()
+	defer conn.mutex.RUnlock()
+	return conn.streams[id]
+}
+
+func (conn *serverStreamConnection) removeStream(id uint64) *serverStream {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+	stream := conn.streams[id]
+	delete(conn.streams, id)
+	return stream
+}
+
+// http3.Handler
+func (conn *serverStreamConnection) HandleHeaders(id uint64, fields []hpack.HeaderField, endStream bool) {
+	// 1. pre alloc stream-level ctx with bufferCtx
+	ctx := conn.cm.Get()
+
+	req, err := decodeRequest(fields)
+	if err != nil {
+		log.Proxy.Errorf(ctx, "http3 server decode request error, id = %d: %v", id, err)
+		conn.h3.ResetStream(id, http3.ErrCodeMessageError)
+		return
+	}
+	conn.cm.Next()
+
+	req.URL.Scheme = "https"
+	variable.SetString(ctx, types.VarScheme, req.URL.Scheme)
+	variable.SetString(ctx, types.VarMethod, req.Method)
+	variable.SetString(ctx, types.VarHost, req.Host)
+	variable.SetString(ctx, types.VarIstioHeaderHost, req.Host) // be consistent with http1
+	variable.SetString(ctx, types.VarPath, req.URL.Path)
+	variable.SetString(ctx, types.VarPathOriginal, req.URL.EscapedPath())
+
+	if req.URL.RawQuery != "" {
+		variable.SetString(ctx, types.VarQueryString, req.URL.RawQuery)
+	}
+
+	stream := conn.onNewStreamDetect(ctx, id, req)
+	header := mhttp2.NewReqHeader(req)
+
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(stream.ctx, "http3 server header: %d, %+v", id, req.Header)
+	}
+
+	if endStream {
+		stream.receiver.OnReceive(stream.ctx, header, nil, nil)
+		return
+	}
+	stream.header = header
+	stream.trailer = &mhttp2.HeaderMap{}
+}
+
+func (conn *serverStreamConnection) HandleData(id uint64, data []byte, endStream bool) {
+	stream := conn.getStream(id)
+	if stream == nil {
+		return
+	}
+	if len(data) > 0 {
+		if log.Proxy.GetLogLevel() >= log.DEBUG {
+			log.Proxy.Debugf(stream.ctx, "http3 server receive data: %d", id)
+		}
+		stream.receiveData(data)
+	}
+	if endStream {
+		stream.receiveEnd()
+	}
+}
+
+func (conn *serverStreamConnection) HandleTrailers(id uint64, fields []hpack.HeaderField) {
+	stream := conn.getStream(id)
+	if stream == nil {
+		return
+	}
+	stream.trailer.H = decodeTrailer(fields)
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(stream.ctx, "http3 server trailer: %d, %v", id, stream.trailer.H)
+	}
+	stream.receiveEnd()
+}
+
+func (conn *serverStreamConnection) HandleReset(id uint64, code uint64) {
+	stream := conn.removeStream(id)
+	if stream == nil {
+		return
+	}
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(stream.ctx, "http3 server stream reset by peer: %d, code = %d", id, code)
+	}
+	stream.stream.ResetStream(types.StreamRemoteReset)
+}
+
+func (conn *serverStreamConnection) HandleGoAway(id uint64) {
+}
+
+func (conn *serverStreamConnection) HandleClose(err error) {
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(conn.ctx, "http3 server connection closed: %v", err)
+	}
+	conn.conn.Close(api.NoFlush, closeEvent(err))
+}
+
+func (conn *serverStreamConnection) onNewStreamDetect(ctx context.Context, id uint64, req *http.Request) *serverStream {
+	stream := &serverStream{}
+	stream.id = id
+	stream.ctx = ctx
+	_ = variable.Set(stream.ctx, types.VariableStreamID, stream.id)
+	_ = variable.Set(stream.ctx, types.VariableDownStreamProtocol, protocol.HTTP3)
+
+	stream.sc = conn
+	stream.req = req
+	stream.conn = conn.conn
+
+	conn.mutex.Lock()
+	conn.streams[stream.id] = stream
+	conn.mutex.Unlock()
+
+	var span api.Span
+	if trace.IsEnabled() {
+		// try build trace span
+		tracer := trace.Tracer(protocol.HTTP3)
+		if tracer != nil {
+			span = tracer.Start(ctx, req, time.Now())
+		}
+	}
+
+	stream.receiver = conn.serverCallbacks.NewStreamDetect(stream.ctx, stream, span)
+	return stream
+}
+
+type serverStream struct {
+	stream
+	req    *http.Request
+	status int
+	sc     *serverStreamConnection
+}
+
+// types.StreamSender
+func (s *serverStream) AppendHeaders(ctx context.Context, headers api.HeaderMap, endStream bool) error {
+	value, err := variable.GetString(ctx, types.VarHeaderStatus)
+	if err != nil || value == "" {
+		s.status = http.StatusOK
+	} else {
+		s.status, _ = strconv.Atoi(value)
+	}
+
+	switch header := headers.(type) {
+	case *mhttp2.RspHeader:
+		s.status = header.Rsp.StatusCode
+		s.sendHeader = header.H
+	case *mhttp2.ReqHeader:
+		// indicates the invocation is under hijack scene
+		s.sendHeader = s.req.Header
+	default:
+		s.sendHeader = mhttp2.EncodeHeader(headers)
+	}
+
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(s.ctx, "http3 server ApppendHeaders id = %d, headers = %+v", s.id, s.sendHeader)
+	}
+
+	if endStream {
+		s.endStream()
+	}
+
+	return nil
+}
+
+func (s *serverStream) AppendData(context context.Context, data buffer.IoBuffer, endStream bool) error {
+	s.sendData = data
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(s.ctx, "http3 server ApppendData id = %d", s.id)
+	}
+
+	if endStream {
+		s.endStream()
+	}
+
+	return nil
+}
+
+func (s *serverStream) AppendTrailers(context context.Context, trailers api.HeaderMap) error {
+	s.appendTrailers(trailers)
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(s.ctx, "http3 server ApppendTrailers id = %d, trailer = %+v", s.id, s.sendTrailer)
+	}
+	s.endStream()
+
+	return nil
+}
+
+func (s *serverStream) ResetStream(reason types.StreamResetReason) {
+	// on stream reset
+	if log.Proxy.GetLogLevel() >= log.WARN {
+		log.Proxy.Warnf(s.ctx, "http3 server reset stream id = %d, error = %v", s.id, reason)
+	}
+	s.sc.removeStream(s.id)
+	if s.sc.h3 != nil {
+		s.sc.h3.ResetStream(s.id, http3.ErrCodeRequestCancelled)
+	}
+	s.stream.ResetStream(reason)
+}
+
+func (s *serverStream) GetStream() types.Stream {
+	return s
+}
+
+func (s *serverStream) endStream() {
+	if s.sendData != nil {
+		// Need to reset the 'Content-Length' response header when it's a direct response.
+		isDirectResponse, _ := variable.GetString(s.ctx, types.VarProxyIsDirectResponse)
+		if isDirectResponse == types.IsDirectResponse {
+			s.sendHeader.Set("Content-Length", strconv.Itoa(s.sendData.Len()))
+		}
+	}
+
+	if s.sc.removeStream(s.id) == nil {
+		// the stream is reset
+		return
+	}
+
+	fields := []hpack.HeaderField{{Name: ":status", Value: strconv.Itoa(s.status)}}
+	fields = appendHeaderFields(fields, s.sendHeader)
+	if err := s.sc.writeMessage(s.id, fields, s.sendData, s.sendTrailer); err != nil {
+		log.Proxy.Errorf(s.ctx, "http3 server SendResponse error :%v", err)
+		s.ResetStream(types.StreamLocalReset)
+		return
+	}
+
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(s.ctx, "http3 server SendResponse id = %d", s.id)
+	}
+}
+
+type clientStreamConnection struct {
+	streamConnection
+	mutex      sync.RWMutex
+	streams    map[uint64]*clientStream
+	goaway     bool
+	lastStream uint64
+
+	streamConnectionEventListener types.StreamConnectionEventListener
+}
+
+func newClientStreamConnection(ctx context.Context, connection api.Connection,
+	clientCallbacks types.StreamConnectionEventListener) types.ClientStreamConnection {
+
+	sc := &clientStreamConnection{
+		streamConnection: streamConnection{
+			ctx:    ctx,
+			conn:   connection,
+			config: parseStreamConfig(ctx),
+		},
+		streams:                       make(map[uint64]*clientStream, 32),
+		streamConnectionEventListener: clientCallbacks,
+	}
+
+	tlsConfig, _ := ctx.Value(tlsConfigKey{}).(*tls.Config)
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{NextProtos: []string{http3.NextProtoH3}}
+	}
+	var err error
+	if sc.h3, err = http3.NewClientConn(sc.config.quicConfig(tlsConfig), sc.send, sc); err != nil {
+		log.Proxy.Errorf(ctx, "new http3 client stream connection failed: %v", err)
+	}
+
+	connection.AddConnectionEventListener(sc)
+	return sc
+}
+
+func (conn *clientStreamConnection) GoAway() {
+	// todo
+}
+
+func (conn *clientStreamConnection) OnEvent(event api.ConnectionEvent) {
+	if conn.h3 == nil {
+		return
+	}
+	if event == api.Connected {
+		if err := conn.h3.Start(); err != nil {
+			log.Proxy.Errorf(conn.ctx, "http3 client start handshake error: %v", err)
+			conn.conn.Close(api.NoFlush, api.LocalClose)
+		}
+	}
+	if event.IsClose() || event.ConnectFailure() {
+		conn.h3.Close(http3.ErrCodeNoError)
+	}
+}
+
+// types.StreamConnection
+func (conn *clientStreamConnection) Dispatch(buf types.IoBuffer) {
+	if conn.h3 != nil {
+		// each buffer is a datagram received
+		conn.h3.Receive(buf.Bytes())
+	}
+	buf.Drain(buf.Len())
+}
+
+func (conn *clientStreamConnection) ActiveStreamsNum() int {
+	conn.mutex.RLock()
+	defer conn.mutex.RUnlock()
+
+	return len(conn.streams)
+}
+
+// handshakeComplete reports whether the connection is established
+func (conn *clientStreamConnection) handshakeComplete() bool {
+	if conn.h3 == nil {
+		return false
+	}
+	select {
+	case <-conn.h3.HandshakeComplete():
+		return conn.h3.ConnectionState().HandshakeComplete
+	default:
+		return false
+	}
+}
+
+func (conn *clientStreamConnection) CheckReasonError(connected bool, event api.ConnectionEvent) (types.StreamResetReason, bool) {
+	reason := types.StreamConnectionSuccessed
+	if event.IsClose() || event.ConnectFailure() {
+		// the udp connection is always connected, the requests can be retried if the handshake fails
+		reason = types.StreamConnectionFailed
+		if connected && conn.handshakeComplete() {
+			reason = types.StreamConnectionTermination
+		}
+		return reason, false
+	}
+
+	return reason, true
+}
+
+func (conn *clientStreamConnection) Reset(reason types.StreamResetReason) {
+	conn.mutex.Lock()
+	streams := conn.streams
+	conn.streams = make(map[uint64]*clientStream)
+	conn.mutex.Unlock()
+
+	for _, stream := range streams {
+		stream.connReset = true
+		stream.ResetStream(reason)
+	}
+}
+
+func (conn *clientStreamConnection) NewStream(ctx context.Context, receiver types.StreamReceiveListener) types.StreamSender {
+	stream := &clientStream{}
+
+	stream.ctx = ctx
+	stream.sc = conn
+	stream.receiver = receiver
+	stream.conn = conn.conn
+
+	return stream
+}
+
+func (conn *clientStreamConnection) getStream(id uint64) *clientStream {
+	conn.mutex.RLock()
+	defer conn.mutex.RUnlock()
+	return conn.streams[id]
+}
+
+func (conn *clientStreamConnection) removeStream(id uint64) *clientStream {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+	stream := conn.streams[id]
+	delete(conn.streams, id)
+	return stream
+}
+
+// http3.Handler
+func (conn *clientStreamConnection) HandleHeaders(id uint64, fields []hpack.HeaderField, endStream bool) {
+	stream := conn.getStream(id)
+	if stream == nil {
+		log.Proxy.Errorf(conn.ctx, "http3 client invalid steamID :%d", id)
+		return
+	}
+
+	rsp, err := decodeResponse(fields)
+	if err != nil {
+		log.Proxy.Errorf(stream.ctx, "http3 client decode response error, id = %d: %v", id, err)
+		conn.removeStream(id)
+		conn.h3.ResetStream(id, http3.ErrCodeMessageError)
+		stream.stream.ResetStream(types.StreamRemoteReset)
+		return
+	}
+	header := mhttp2.NewRspHeader(rsp)
+
+	// set header-status into stream ctx
+	variable.SetString(stream.ctx, types.VarHeaderStatus, strconv.Itoa(rsp.StatusCode))
+
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(stream.ctx, "http3 client header: id = %d, headers = %+v", id, rsp.Header)
+	}
+
+	if endStream {
+		conn.removeStream(id)
+		if stream.receiver != nil {
+			stream.receiver.OnReceive(stream.ctx, header, nil, nil)
+		}
+		return
+	}
+	stream.header = header
+	stream.trailer = &mhttp2.HeaderMap{}
+}
+
+func (conn *clientStreamConnection) HandleData(id uint64, data []byte, endStream bool) {
+	stream := conn.getStream(id)
+	if stream == nil {
+		return
+	}
+	if len(data) > 0 {
+		if log.Proxy.GetLogLevel() >= log.DEBUG {
+			log.Proxy.Debugf(stream.ctx, "http3 client receive data: %d", id)
+		}
+		stream.receiveData(data)
+	}
+	if endStream {
+		conn.removeStream(id)
+		stream.receiveEnd()
+	}
+}
+
+func (conn *clientStreamConnection) HandleTrailers(id uint64, fields []hpack.HeaderField) {
+	stream := conn.removeStream(id)
+	if stream == nil {
+		return
+	}
+	stream.trailer.H = decodeTrailer(fields)
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(stream.ctx, "http3 client trailer: id = %d, trailer = %+v", id, stream.trailer.H)
+	}
+	stream.receiveEnd()
+}
+
+func (conn *clientStreamConnection) HandleReset(id uint64, code uint64) {
+	stream := conn.removeStream(id)
+	if stream == nil {
+		return
+	}
+	reason := types.StreamRemoteReset
+	// the request is not processed by the server, it can be retried
+	if code == http3.ErrCodeRequestRejected {
+		reason = types.StreamConnectionFailed
+	}
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(stream.ctx, "http3 client stream reset by peer: %d, code = %d", id, code)
+	}
+	stream.stream.ResetStream(reason)
+}
+
+func (conn *clientStreamConnection) HandleGoAway(id uint64) {
+	conn.mutex.Lock()
+	conn.goaway = true
+	conn.lastStream = id
+	var rejected []*clientStream
+	for sid, stream := range conn.streams {
+		if sid >= id {
+			rejected = append(rejected, stream)
+		}
+	}
+	conn.mutex.Unlock()
+
+	conn.streamConnectionEventListener.OnGoAway()
+	if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+		log.DefaultLogger.Debugf("http3 client receive goaway lastStreamID = %d", id)
+	}
+	// the requests not less than the id are not processed, they can be retried
+	for _, stream := range rejected {
+		stream.ResetStream(types.StreamConnectionFailed)
+	}
+}
+
+func (conn *clientStreamConnection) HandleClose(err error) {
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(conn.ctx, "http3 client connection closed: %v", err)
+	}
+	conn.conn.Close(api.NoFlush, closeEvent(err))
+}
+
+type clientStream struct {
+	stream
+	// opened reports whether the request stream is opened
+	opened    bool
+	connReset bool
+
+	fields []hpack.HeaderField
+	sc     *clientStreamConnection
+}
+
+func (s *clientStream) AppendHeaders(ctx context.Context, headersIn api.HeaderMap, endStream bool) error {
+	var req *http.Request
+
+	// clone for retry
+	headersIn = headersIn.Clone()
+	switch header := headersIn.(type) {
+	case *mhttp2.ReqHeader:
+		req = header.Req
+	default:
+		method, err := variable.GetString(ctx, types.VarMethod)
+		if err != nil || method == "" {
+			if endStream {
+				method = http.MethodGet
+			} else {
+				method = http.MethodPost
+			}
+		}
+
+		var host string
+		if h, err := variable.GetString(ctx, types.VarHost); err == nil && h != "" {
+			host = h
+		} else if h, ok := headersIn.Get("Host"); ok {
+			host = h
+		} else {
+			host = s.conn.RemoteAddr().String()
+		}
+
+		path, _ := variable.GetString(ctx, types.VarPath)
+		pathOriginal, _ := variable.GetString(ctx, types.VarPathOriginal)
+		query, _ := variable.GetString(ctx, types.VarQueryString)
+
+		req = &http.Request{
+			Method: method,
+			Host:   host,
+			URL: &url.URL{
+				Path:     path,
+				RawPath:  pathOriginal,
+				RawQuery: query,
+			},
+			Header: mhttp2.EncodeHeader(headersIn),
+		}
+	}
+
+	s.fields = []hpack.HeaderField{
+		{Name: ":method", Value: req.Method},
+		{Name: ":scheme", Value: "https"},
+		{Name: ":authority", Value: req.Host},
+		{Name: ":path", Value: req.URL.RequestURI()},
+	}
+	s.sendHeader = req.Header
+
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(s.ctx, "http3 client AppendHeaders: headers = %+v", req.Header)
+	}
+
+	if endStream {
+		s.endStream()
+	}
+	return nil
+}
+
+func (s *clientStream) AppendData(context context.Context, data buffer.IoBuffer, endStream bool) error {
+	s.sendData = data
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(s.ctx, "http3 client AppendData")
+	}
+	if endStream {
+		s.endStream()
+	}
+
+	return nil
+}
+
+func (s *clientStream) AppendTrailers(context context.Context, trailers api.HeaderMap) error {
+	s.appendTrailers(trailers)
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(s.ctx, "http3 client AppendTrailers: trailer = %+v", s.sendTrailer)
+	}
+	s.endStream()
+
+	return nil
+}
+
+// openRequest opens the request stream, the streams can not be opened before the handshake
+// completes, which carries the stream limits of the server
+func (s *clientStream) openRequest() error {
+	h3 := s.sc.h3
+	if h3 == nil {
+		return errNotConnected
+	}
+	<-h3.HandshakeComplete()
+	if err := h3.Err(); err != nil {
+		return err
+	}
+
+	s.sc.mutex.Lock()
+	defer s.sc.mutex.Unlock()
+	id, err := h3.OpenRequest()
+	if err != nil {
+		return err
+	}
+	s.id = id
+	s.opened = true
+	s.sc.streams[id] = s
+	return nil
+}
+
+func (s *clientStream) endStream() {
+	fields := appendHeaderFields(s.fields, s.sendHeader)
+
+	err := s.openRequest()
+	if err == nil {
+		if log.Proxy.GetLogLevel() >= log.DEBUG {
+			log.Proxy.Debugf(s.ctx, "http3 client SendRequest id = %d", s.id)
+		}
+		err = s.sc.writeMessage(s.id, fields, s.sendData, s.sendTrailer)
+	}
+	if err == nil {
+		return
+	}
+
+	log.Proxy.Errorf(s.ctx, "http3 client endStream error = %v", err)
+	switch err {
+	case quic.ErrStreamLimit:
+		s.ResetStream(types.StreamLocalReset)
+	default:
+		// the request is not sent, it can be retried on another connection
+		if err == http3.ErrGoAway {
+			s.sc.streamConnectionEventListener.OnGoAway()
+		}
+		s.ResetStream(types.StreamConnectionFailed)
+	}
+}
+
+func (s *clientStream) GetStream() types.Stream {
+	return s
+}
+
+func (s *clientStream) ResetStream(reason types.StreamResetReason) {
+	s.sc.mutex.RLock()
+	goaway := s.sc.goaway && s.opened && s.id >= s.sc.lastStream
+	s.sc.mutex.RUnlock()
+	// reset by goaway, support retry.
+	if goaway {
+		if log.DefaultLogger.GetLogLevel() >= log.WARN {
+			log.DefaultLogger.Warnf("http3 client reset by goaway, retry it, lastStream = %d, streamId = %d", s.sc.lastStream, s.id)
+		}
+		reason = types.StreamConnectionFailed
+	}
+
+	if s.opened {
+		if !s.connReset {
+			s.sc.removeStream(s.id)
+		}
+		s.sc.h3.ResetStream(s.id, http3.ErrCodeRequestCancelled)
+	}
+
+	s.stream.ResetStream(reason)
+}
@@ -0,0 +1,265 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http3
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/experimental/module/http3"
+	"mosn.io/mosn/pkg/mock"
+	"mosn.io/mosn/pkg/module/http2/hpack"
+	"mosn.io/mosn/pkg/mtls"
+	"mosn.io/mosn/pkg/mtls/certtool"
+	"mosn.io/mosn/pkg/protocol"
+	mhttp2 "mosn.io/mosn/pkg/protocol/http2"
+	_ "mosn.io/mosn/pkg/proxy"
+	str "mosn.io/mosn/pkg/stream"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+)
+
+func TestProtocolMatch(t *testing.T) {
+	f := &StreamConnFactory{}
+	initial := []byte{0xc3, 0x00, 0x00, 0x00, 0x01, 0x08}
+	assert.Nil(t, f.ProtocolMatch(context.Background(), "", initial))
+	assert.Equal(t, str.EAGAIN, f.ProtocolMatch(context.Background(), "", initial[:3]))
+	assert.Equal(t, str.FAILED, f.ProtocolMatch(context.Background(), "", []byte("GET / HTTP/1.1\r\n")))
+	// the version 2 is not supported
+	assert.Equal(t, str.FAILED, f.ProtocolMatch(context.Background(), "", []byte{0xc3, 0x6b, 0x33, 0x43, 0xcf, 0x08}))
+}
+
+func TestStreamConfigHandler(t *testing.T) {
+	v := map[string]interface{}{
+		string(protocol.HTTP3): map[string]interface{}{
+			"max_concurrent_streams": 10,
+			"idle_timeout":           "5s",
+		},
+	}
+	cfg := streamConfigHandler(v).(StreamConfig)
+	assert.Equal(t, 10, cfg.MaxConcurrentStreams)
+	assert.Equal(t, 5*time.Second, cfg.IdleTimeout.Duration)
+	assert.Equal(t, defaultStreamConfig, streamConfigHandler(nil))
+}
+
+func TestDecodeHeaders(t *testing.T) {
+	req, err := decodeRequest([]hpack.HeaderField{
+		{Name: ":method", Value: "POST"},
+		{Name: ":scheme", Value: "https"},
+		{Name: ":authority", Value: "mosn.io"},
+		{Name: ":path", Value: "/a%20b?x=1"},
+		{Name: "cookie", Value: "a=1"},
+		{Name: "cookie", Value: "b=2"},
+		{Name: "content-length", Value: "3"},
+	})
+	require.Nil(t, err)
+	assert.Equal(t, "mosn.io", req.Host)
+	assert.Equal(t, "/a b", req.URL.Path)
+	assert.Equal(t, "x=1", req.URL.RawQuery)
+	assert.Equal(t, "a=1; b=2", req.Header.Get("Cookie"))
+	assert.Equal(t, int64(3), req.ContentLength)
+
+	_, err = decodeRequest([]hpack.HeaderField{{Name: ":method", Value: "GET"}})
+	assert.Equal(t, errMalformedRequest, err)
+	_, err = decodeResponse([]hpack.HeaderField{{Name: "server", Value: "mosn"}})
+	assert.Equal(t, errMalformedResponse, err)
+
+	fields := appendHeaderFields(nil, http.Header{
+		"Connection":        {"close"},
+		"Transfer-Encoding": {"chunked"},
+		"Te":                {"gzip"},
+		"X-Test":            {"1"},
+	})
+	assert.Equal(t, []hpack.HeaderField{{Name: "x-test", Value: "1"}}, fields)
+}
+
+// testTLSManagers returns the listener and the cluster tls context managers
+func testTLSManagers(t *testing.T) (types.TLSContextManager, types.TLSClientContextManager) {
+	priv, err := certtool.GeneratePrivateKey("P256")
+	require.Nil(t, err)
+	tmpl, err := certtool.CreateTemplate("http3.test", false, []string{"http3.test"})
+	require.Nil(t, err)
+	info, err := certtool.SignCertificate(tmpl, priv)
+	require.Nil(t, err)
+	ca := certtool.GetRootCA().CertPem
+
+	server, err := mtls.NewTLSServerContextManager(&v2.Listener{
+		ListenerConfig: v2.ListenerConfig{
+			Name: "http3",
+			FilterChains: []v2.FilterChain{{
+				TLSContexts: []v2.TLSConfig{{
+					Status:     true,
+					CACert:     ca,
+					CertChain:  info.CertPem,
+					PrivateKey: info.KeyPem,
+				}},
+			}},
+		},
+	})
+	require.Nil(t, err)
+	client, err := mtls.NewTLSClientContextManager("http3", &v2.TLSConfig{
+		Status:     true,
+		CACert:     ca,
+		ServerName: "http3.test",
+	})
+	require.Nil(t, err)
+	return server, client
+}
+
+// pipe delivers the datagrams written to the connection in order
+func pipe(ctrl *gomock.Controller, dst func() types.StreamConnection) (*mock.MockConnection, func()) {
+	ch := make(chan []byte, 1024)
+	done := make(chan struct{})
+	conn := mock.NewMockConnection(ctrl)
+	conn.EXPECT().SetTransferEventListener(gomock.Any()).AnyTimes()
+	conn.EXPECT().AddConnectionEventListener(gomock.Any()).AnyTimes()
+	conn.EXPECT().Close(gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+	conn.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(bufs ...buffer.IoBuffer) error {
+		for _, b := range bufs {
+			select {
+			case ch <- b.Bytes():
+			case <-done:
+			}
+		}
+		return nil
+	})
+	go func() {
+		for {
+			select {
+			case b := <-ch:
+				dst().Dispatch(buffer.NewIoBufferBytes(b))
+			case <-done:
+				return
+			}
+		}
+	}()
+	return conn, func() { close(done) }
+}
+
+func TestRequestResponse(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	serverMng, clientMng := testTLSManagers(t)
+
+	var server, client types.StreamConnection
+	serverConn, closeServer := pipe(ctrl, func() types.StreamConnection { return client })
+	defer closeServer()
+	clientConn, closeClient := pipe(ctrl, func() types.StreamConnection { return server })
+	defer closeClient()
+
+	// the server replies the request body with the trailers
+	serverCallbacks := mock.NewMockServerStreamConnectionEventListener(ctrl)
+	serverCallbacks.EXPECT().NewStreamDetect(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(ctx context.Context, sender types.StreamSender, span api.Span) types.StreamReceiveListener {
+			receiver := mock.NewMockStreamReceiveListener(ctrl)
+			receiver.EXPECT().OnReceive(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+				func(ctx context.Context, headers types.HeaderMap, data types.IoBuffer, trailers types.HeaderMap) {
+					method, _ := variable.GetString(ctx, types.VarMethod)
+					path, _ := variable.GetString(ctx, types.VarPath)
+					query, _ := variable.GetString(ctx, types.VarQueryString)
+					assert.Equal(t, "POST", method)
+					assert.Equal(t, "/echo", path)
+					assert.Equal(t, "k=v", query)
+					v, _ := headers.Get("X-Request")
+					assert.Equal(t, "mosn", v)
+
+					go func() {
+						ctx := variable.NewVariableContext(context.Background())
+						variable.SetString(ctx, types.VarHeaderStatus, "201")
+						sender.AppendHeaders(ctx, mhttp2.NewHeaderMap(http.Header{"Connection": {"close"}, "X-Response": {"mosn"}}), false)
+						sender.AppendData(ctx, buffer.NewIoBufferString(data.String()), false)
+						sender.AppendTrailers(ctx, mhttp2.NewHeaderMap(http.Header{"Grpc-Status": {"0"}}))
+					}()
+				})
+			return receiver
+		})
+
+	sctx := variable.NewVariableContext(context.Background())
+	_ = variable.Set(sctx, types.VariableListenerTLSManager, serverMng)
+	server = newServerStreamConnection(sctx, serverConn, serverCallbacks)
+
+	tlsConfig, err := mtls.QUICClientConfig(clientMng, []string{http3.NextProtoH3})
+	require.Nil(t, err)
+	cctx := context.WithValue(context.Background(), tlsConfigKey{}, tlsConfig)
+	clientCallbacks := mock.NewMockStreamConnectionEventListener(ctrl)
+	client = newClientStreamConnection(cctx, clientConn, clientCallbacks)
+	client.(*clientStreamConnection).OnEvent(api.Connected)
+
+	type response struct {
+		headers  types.HeaderMap
+		data     string
+		trailers types.HeaderMap
+		status   string
+	}
+	done := make(chan response, 1)
+	respReceiver := mock.NewMockStreamReceiveListener(ctrl)
+	respReceiver.EXPECT().OnReceive(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+		func(ctx context.Context, headers types.HeaderMap, data types.IoBuffer, trailers types.HeaderMap) {
+			status, _ := variable.GetString(ctx, types.VarHeaderStatus)
+			done <- response{headers, data.String(), trailers, status}
+		})
+
+	ctx := variable.NewVariableContext(context.Background())
+	variable.SetString(ctx, types.VarMethod, "POST")
+	variable.SetString(ctx, types.VarHost, "http3.test")
+	variable.SetString(ctx, types.VarPath, "/echo")
+	variable.SetString(ctx, types.VarQueryString, "k=v")
+	sender := client.(types.ClientStreamConnection).NewStream(ctx, respReceiver)
+	require.Nil(t, sender.AppendHeaders(ctx, protocol.CommonHeader{"X-Request": "mosn"}, false))
+	require.Nil(t, sender.AppendData(ctx, buffer.NewIoBufferString("hello http3"), true))
+
+	select {
+	case rsp := <-done:
+		assert.Equal(t, "201", rsp.status)
+		assert.Equal(t, "hello http3", rsp.data)
+		v, _ := rsp.headers.Get("X-Response")
+		assert.Equal(t, "mosn", v)
+		_, ok := rsp.headers.Get("Connection")
+		assert.False(t, ok)
+		v, _ = rsp.trailers.Get("Grpc-Status")
+		assert.Equal(t, "0", v)
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait response timeout")
+	}
+	assert.Equal(t, 0, client.ActiveStreamsNum())
+	assert.Equal(t, 0, server.ActiveStreamsNum())
+}
+
+func TestServerWithoutTLS(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	conn := mock.NewMockConnection(ctrl)
+	conn.EXPECT().SetTransferEventListener(gomock.Any()).AnyTimes()
+	conn.EXPECT().AddConnectionEventListener(gomock.Any()).AnyTimes()
+	conn.EXPECT().Close(api.NoFlush, api.LocalClose).Return(nil)
+
+	sc := newServerStreamConnection(variable.NewVariableContext(context.Background()), conn, mock.NewMockServerStreamConnectionEventListener(ctrl))
+	buf := buffer.NewIoBufferBytes([]byte{0xc3, 0x00, 0x00, 0x00, 0x01})
+	sc.Dispatch(buf)
+	assert.Equal(t, 0, buf.Len())
+}
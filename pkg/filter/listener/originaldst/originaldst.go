@@ -24,6 +24,7 @@ import (
 	"syscall"
 
 	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/mtls"
 )
 
 // OriginDST, option for syscall.GetsockoptIPv6Mreq
@@ -32,8 +33,16 @@ const (
 	IP6T_SO_ORIGINAL_DST = 80
 )
 
+// rawConn returns the connection under the one peeked by the listener filters
+func rawConn(conn net.Conn) net.Conn {
+	if c, ok := conn.(*mtls.Conn); ok {
+		return c.Conn
+	}
+	return conn
+}
+
 func getRedirectAddr(conn net.Conn) (string, int, error) {
-	tc, ok := conn.(*net.TCPConn)
+	tc, ok := rawConn(conn).(*net.TCPConn)
 	if !ok {
 		return "", 0, fmt.Errorf("redirect proxy only support tcp")
 	}
@@ -67,7 +76,7 @@ func getRedirectAddr(conn net.Conn) (string, int, error) {
 }
 
 func getTProxyAddr(conn net.Conn) (string, int, error) {
-	tc, ok := conn.(*net.TCPConn)
+	tc, ok := rawConn(conn).(*net.TCPConn)
 	if !ok {
 		return "", 0, fmt.Errorf("transport proxy only support tcp")
 	}
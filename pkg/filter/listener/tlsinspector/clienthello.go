@@ -0,0 +1,149 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tlsinspector
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+const (
+	recordHeaderLen        = 5
+	maxRecordLen           = 16384
+	recordTypeHandshake    = 0x16
+	handshakeTypeHello     = 0x01
+	extensionServerName    = 0
+	extensionALPN          = 16
+	serverNameTypeHostName = 0
+)
+
+var errMalformedClientHello = errors.New("malformed tls client hello")
+
+// clientHello contains the fields in the tls ClientHello message used by the filter chain match
+type clientHello struct {
+	serverName string
+	protocols  []string
+}
+
+// reader reads the tls message in big endian, reports malformed if the data is not enough.
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.data) < n {
+		r.err = errMalformedClientHello
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *reader) uint8() int {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return int(b[0])
+}
+
+func (r *reader) uint16() int {
+	b := r.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return int(binary.BigEndian.Uint16(b))
+}
+
+func (r *reader) uint24() int {
+	b := r.bytes(3)
+	if b == nil {
+		return 0
+	}
+	return int(b[0])<<16 | int(b[1])<<8 | int(b[2])
+}
+
+// parseClientHello parses the handshake message in the first tls record.
+// the extensions out of the record are ignored.
+func parseClientHello(data []byte) (*clientHello, error) {
+	r := &reader{data: data}
+	if r.uint8() != handshakeTypeHello {
+		return nil, errMalformedClientHello
+	}
+	length := r.uint24()
+	if r.err == nil && length < len(r.data) {
+		r.data = r.data[:length]
+	}
+	r.bytes(2)          // client version
+	r.bytes(32)         // random
+	r.bytes(r.uint8())  // session id
+	r.bytes(r.uint16()) // cipher suites
+	r.bytes(r.uint8())  // compression methods
+	if r.err != nil {
+		return nil, r.err
+	}
+	hello := &clientHello{}
+	if len(r.data) == 0 {
+		// no extensions
+		return hello, nil
+	}
+	extensions := &reader{data: r.bytes(r.uint16())}
+	if r.err != nil {
+		return nil, r.err
+	}
+	for len(extensions.data) > 0 {
+		typ := extensions.uint16()
+		ext := &reader{data: extensions.bytes(extensions.uint16())}
+		if extensions.err != nil {
+			return nil, extensions.err
+		}
+		switch typ {
+		case extensionServerName:
+			names := &reader{data: ext.bytes(ext.uint16())}
+			for len(names.data) > 0 {
+				nameType := names.uint8()
+				name := names.bytes(names.uint16())
+				if names.err != nil {
+					return nil, names.err
+				}
+				if nameType == serverNameTypeHostName {
+					hello.serverName = strings.ToLower(string(name))
+				}
+			}
+		case extensionALPN:
+			protos := &reader{data: ext.bytes(ext.uint16())}
+			for len(protos.data) > 0 {
+				proto := protos.bytes(protos.uint8())
+				if protos.err != nil {
+					return nil, protos.err
+				}
+				hello.protocols = append(hello.protocols, string(proto))
+			}
+		}
+		if ext.err != nil {
+			return nil, ext.err
+		}
+	}
+	return hello, nil
+}
@@ -0,0 +1,149 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tlsinspector
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"time"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/mtls"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/variable"
+)
+
+// TLS inspector filter detects whether the connection is tls or plaintext, and extracts the
+// server name and application protocols from the tls ClientHello, so that the filter chains
+// of the listener can be matched on them.
+func init() {
+	api.RegisterListener(v2.TLS_INSPECTOR_LISTENER_FILTER, CreateTLSInspectorFactory)
+}
+
+// defaultTimeout is short, because the clients of the server first protocols such as mysql
+// send nothing until the server speaks, the connections wait the timeout before the filter chain is selected.
+const defaultTimeout = 500 * time.Millisecond
+
+type TLSInspectorConfig struct {
+	// Timeout is the max time waiting for the first bytes of the connection,
+	// a connection without any data before timeout is treated as plaintext,
+	// and falls through to the filter chain of the raw buffer transport protocol.
+	Timeout *api.DurationConfig `json:"timeout,omitempty"`
+}
+
+type tlsInspector struct {
+	timeout time.Duration
+}
+
+// connSetter is implemented by the listener filter chain callbacks that allow
+// the accepted connection to be replaced.
+type connSetter interface {
+	SetConn(c net.Conn)
+}
+
+func CreateTLSInspectorFactory(conf map[string]interface{}) (api.ListenerFilterChainFactory, error) {
+	b, _ := json.Marshal(conf)
+	cfg := TLSInspectorConfig{}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, err
+	}
+	timeout := defaultTimeout
+	if cfg.Timeout != nil && cfg.Timeout.Duration > 0 {
+		timeout = cfg.Timeout.Duration
+	}
+	return &tlsInspector{
+		timeout: timeout,
+	}, nil
+}
+
+// OnAccept called when connection accept
+func (filter *tlsInspector) OnAccept(cb api.ListenerFilterChainFactoryCallbacks) api.FilterStatus {
+	rawc := cb.Conn()
+	if rawc.LocalAddr().Network() == "udp" {
+		return api.Continue
+	}
+	conn, ok := rawc.(*mtls.Conn)
+	if !ok {
+		setter, ok := cb.(connSetter)
+		if !ok {
+			log.DefaultLogger.Errorf("[tls inspector] the listener does not support to inspect the connection")
+			return api.Continue
+		}
+		conn = &mtls.Conn{
+			Conn: rawc,
+		}
+		setter.SetConn(conn)
+	}
+
+	ctx := cb.GetOriContext()
+	hello, isTLS := filter.inspect(conn)
+	if !isTLS {
+		_ = variable.SetString(ctx, types.VarTransportProtocol, v2.TransportProtocolRaw)
+		return api.Continue
+	}
+	_ = variable.SetString(ctx, types.VarTransportProtocol, v2.TransportProtocolTLS)
+	if hello != nil {
+		if hello.serverName != "" {
+			_ = variable.SetString(ctx, types.VarRequestedServerName, hello.serverName)
+		}
+		if len(hello.protocols) > 0 {
+			_ = variable.Set(ctx, types.VariableApplicationProtocols, hello.protocols)
+		}
+		if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+			log.DefaultLogger.Debugf("[tls inspector] remote addr: %s, server name: %s, application protocols: %v",
+				conn.RemoteAddr(), hello.serverName, hello.protocols)
+		}
+	}
+	return api.Continue
+}
+
+// inspect peeks the first tls record of the connection.
+// a nil client hello is returned if the connection is tls but the client hello is malformed.
+func (filter *tlsInspector) inspect(conn *mtls.Conn) (*clientHello, bool) {
+	conn.SetReadDeadline(time.Now().Add(filter.timeout))
+	defer conn.SetReadDeadline(time.Time{}) // clear read deadline
+
+	header, err := conn.PeekN(1)
+	if err != nil || header[0] != recordTypeHandshake {
+		return nil, false
+	}
+	header, err = conn.PeekN(recordHeaderLen)
+	if err != nil || header[1] != 0x03 {
+		// tls major version is 3
+		return nil, false
+	}
+	length := int(binary.BigEndian.Uint16(header[3:recordHeaderLen]))
+	if length > maxRecordLen {
+		return nil, false
+	}
+	record, err := conn.PeekN(recordHeaderLen + length)
+	if err != nil {
+		return nil, true
+	}
+	hello, err := parseClientHello(record[recordHeaderLen:])
+	if err != nil {
+		if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+			log.DefaultLogger.Debugf("[tls inspector] parse client hello from %s failed: %v", conn.RemoteAddr(), err)
+		}
+		return nil, true
+	}
+	return hello, true
+}
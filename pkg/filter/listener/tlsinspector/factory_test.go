@@ -0,0 +1,152 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tlsinspector
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/mtls"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/variable"
+)
+
+type mockCallbacks struct {
+	api.ListenerFilterChainFactoryCallbacks
+	conn net.Conn
+	ctx  context.Context
+}
+
+func (cb *mockCallbacks) Conn() net.Conn {
+	return cb.conn
+}
+
+func (cb *mockCallbacks) SetConn(c net.Conn) {
+	cb.conn = c
+}
+
+func (cb *mockCallbacks) GetOriContext() context.Context {
+	return cb.ctx
+}
+
+func newMockCallbacks(c net.Conn) *mockCallbacks {
+	return &mockCallbacks{
+		conn: c,
+		ctx:  variable.NewVariableContext(context.Background()),
+	}
+}
+
+func TestCreateTLSInspectorFactory(t *testing.T) {
+	f, err := CreateTLSInspectorFactory(map[string]interface{}{})
+	require.Nil(t, err)
+	assert.Equal(t, defaultTimeout, f.(*tlsInspector).timeout)
+
+	f, err = CreateTLSInspectorFactory(map[string]interface{}{
+		"timeout": "1s",
+	})
+	require.Nil(t, err)
+	assert.Equal(t, time.Second, f.(*tlsInspector).timeout)
+}
+
+func TestInspectTLS(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	go func() {
+		tls.Client(client, &tls.Config{
+			ServerName:         "WWW.Example.com",
+			NextProtos:         []string{"h2", "http/1.1"},
+			InsecureSkipVerify: true,
+		}).Handshake()
+	}()
+
+	filter := &tlsInspector{timeout: time.Second}
+	cb := newMockCallbacks(server)
+	assert.Equal(t, api.Continue, filter.OnAccept(cb))
+
+	transport, err := variable.GetString(cb.ctx, types.VarTransportProtocol)
+	require.Nil(t, err)
+	assert.Equal(t, v2.TransportProtocolTLS, transport)
+	serverName, err := variable.GetString(cb.ctx, types.VarRequestedServerName)
+	require.Nil(t, err)
+	assert.Equal(t, "www.example.com", serverName)
+	protos, err := variable.Get(cb.ctx, types.VariableApplicationProtocols)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"h2", "http/1.1"}, protos)
+
+	// the peeked data is not drained
+	conn, ok := cb.conn.(*mtls.Conn)
+	require.True(t, ok)
+	header := make([]byte, recordHeaderLen)
+	_, err = io.ReadFull(conn, header)
+	require.Nil(t, err)
+	assert.Equal(t, byte(recordTypeHandshake), header[0])
+}
+
+func TestInspectPlaintext(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	go client.Write([]byte("GET / HTTP/1.1\r\n\r\n"))
+
+	filter := &tlsInspector{timeout: time.Second}
+	cb := newMockCallbacks(server)
+	assert.Equal(t, api.Continue, filter.OnAccept(cb))
+
+	transport, err := variable.GetString(cb.ctx, types.VarTransportProtocol)
+	require.Nil(t, err)
+	assert.Equal(t, v2.TransportProtocolRaw, transport)
+	_, err = variable.GetString(cb.ctx, types.VarRequestedServerName)
+	assert.NotNil(t, err)
+
+	b := make([]byte, 3)
+	_, err = io.ReadFull(cb.conn, b)
+	require.Nil(t, err)
+	assert.Equal(t, "GET", string(b))
+}
+
+func TestInspectTimeout(t *testing.T) {
+	// the server speaks first, the client sends nothing
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	filter := &tlsInspector{timeout: 10 * time.Millisecond}
+	cb := newMockCallbacks(server)
+	assert.Equal(t, api.Continue, filter.OnAccept(cb))
+	transport, err := variable.GetString(cb.ctx, types.VarTransportProtocol)
+	require.Nil(t, err)
+	assert.Equal(t, v2.TransportProtocolRaw, transport)
+}
+
+func TestParseClientHelloMalformed(t *testing.T) {
+	for _, data := range [][]byte{
+		nil,
+		{0x02, 0x00, 0x00, 0x00},
+		{handshakeTypeHello, 0x00, 0x00, 0x10, 0x03, 0x03},
+	} {
+		_, err := parseClientHello(data)
+		assert.Equal(t, errMalformedClientHello, err)
+	}
+}
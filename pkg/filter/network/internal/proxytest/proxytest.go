@@ -0,0 +1,210 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package proxytest contains the test fixtures of the network filters that proxy the connections
+// themselves: a fake upstream server, a mocked downstream connection and the upstream clusters.
+package proxytest
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/mock"
+	"mosn.io/mosn/pkg/upstream/cluster"
+	"mosn.io/pkg/buffer"
+)
+
+// Server is a fake upstream server, each accepted connection is served by a goroutine
+type Server struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	accepted int
+}
+
+// NewServer listens on a random local port and serves the connections
+func NewServer(t *testing.T, serve func(conn net.Conn)) *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &Server{listener: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mutex.Lock()
+			s.accepted++
+			s.mutex.Unlock()
+			go serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Accepted returns the number of the connections accepted
+func (s *Server) Accepted() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.accepted
+}
+
+func (s *Server) Close() {
+	s.listener.Close()
+}
+
+// Filter is the proxy filter tested
+type Filter interface {
+	api.ReadFilter
+	api.ConnectionEventListener
+}
+
+// Decoder returns the size of the first message in data, or 0 if the message is incomplete
+type Decoder func(data []byte) (int, error)
+
+// Client is a mocked downstream connection of the proxy filter, the data written
+// by the filter is buffered and decoded into messages by Read.
+type Client struct {
+	t       *testing.T
+	filter  Filter
+	decode  Decoder
+	mutex   sync.Mutex
+	replies []byte
+	closed  bool
+}
+
+// NewClient creates a downstream connection of the filter, the filter is initialized with it
+func NewClient(t *testing.T, ctrl *gomock.Controller, filter Filter, decode Decoder) *Client {
+	c := &Client{
+		t:      t,
+		filter: filter,
+		decode: decode,
+	}
+	conn := mock.NewMockConnection(ctrl)
+	conn.EXPECT().AddConnectionEventListener(gomock.Any()).AnyTimes()
+	conn.EXPECT().RawConn().Return(nil).AnyTimes()
+	conn.EXPECT().Write(gomock.Any()).DoAndReturn(func(bufs ...buffer.IoBuffer) error {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		for _, b := range bufs {
+			c.replies = append(c.replies, b.Bytes()...)
+		}
+		return nil
+	}).AnyTimes()
+	conn.EXPECT().Close(gomock.Any(), gomock.Any()).DoAndReturn(func(api.ConnectionCloseType, api.ConnectionEvent) error {
+		c.mutex.Lock()
+		c.closed = true
+		c.mutex.Unlock()
+		filter.OnEvent(api.LocalClose)
+		return nil
+	}).AnyTimes()
+	cb := mock.NewMockReadFilterCallbacks(ctrl)
+	cb.EXPECT().Connection().Return(conn).AnyTimes()
+	cb.EXPECT().SetUpstreamHost(gomock.Any()).AnyTimes()
+	filter.InitializeReadFilterCallbacks(cb)
+	filter.OnNewConnection()
+	return c
+}
+
+// Send sends the data to the filter in pieces to test the decoding, and returns the received messages
+func (c *Client) Send(data []byte, count int) [][]byte {
+	buf := buffer.NewIoBuffer(len(data))
+	for len(data) > 0 {
+		n := 7
+		if n > len(data) {
+			n = len(data)
+		}
+		buf.Write(data[:n])
+		data = data[n:]
+		c.filter.OnData(buf)
+	}
+	return c.Read(count)
+}
+
+// Read waits for count messages written by the filter
+func (c *Client) Read(count int) [][]byte {
+	var messages [][]byte
+	deadline := time.Now().Add(3 * time.Second)
+	for len(messages) < count && time.Now().Before(deadline) {
+		c.mutex.Lock()
+		n, err := c.decode(c.replies)
+		if err == nil && n > 0 {
+			messages = append(messages, c.replies[:n])
+			c.replies = c.replies[n:]
+		}
+		c.mutex.Unlock()
+		require.NoError(c.t, err)
+		if n == 0 {
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	require.Len(c.t, messages, count)
+	return messages
+}
+
+// Received returns the data written by the filter which is not read yet
+func (c *Client) Received() []byte {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]byte(nil), c.replies...)
+}
+
+func (c *Client) IsClosed() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.closed
+}
+
+// SetupClusters creates the cluster manager with the clusters of the server addresses
+func SetupClusters(clusters map[string][]string) {
+	var configs []v2.Cluster
+	hosts := map[string][]v2.Host{}
+	for name, addrs := range clusters {
+		configs = append(configs, v2.Cluster{
+			Name:        name,
+			ClusterType: v2.SIMPLE_CLUSTER,
+			LbType:      v2.LB_ROUNDROBIN,
+		})
+		for _, addr := range addrs {
+			hosts[name] = append(hosts[name], v2.Host{HostConfig: v2.HostConfig{Address: addr}})
+		}
+	}
+	cluster.NewClusterManagerSingleton(configs, hosts, nil)
+}
+
+// Cleanup destroys the cluster manager and resets the metrics
+func Cleanup() {
+	cluster.GetClusterMngAdapterInstance().Destroy()
+	metrics.ResetAll()
+}
+
+// ResetStats resets the stats caches of the filter
+func ResetStats(caches ...*sync.Map) {
+	for _, c := range caches {
+		*c = sync.Map{}
+	}
+}
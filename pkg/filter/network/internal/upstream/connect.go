@@ -0,0 +1,77 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package upstream
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/types"
+)
+
+// Connect chooses a host of the cluster by the load balancer with the downstream connection,
+// and leases a connection of the host from the cluster connection pool of the protocol.
+func Connect(ctx context.Context, cm types.ClusterManager, clusterName string, downstream api.ReadFilterCallbacks, proto api.ProtocolName, handler Handler) (*Conn, error) {
+	snapshot := cm.GetClusterSnapshot(ctx, clusterName)
+	if snapshot == nil || reflect.ValueOf(snapshot).IsNil() {
+		return nil, fmt.Errorf("upstream cluster %s not found", clusterName)
+	}
+	host := snapshot.LoadBalancer().ChooseHost(&lbContext{
+		ctx:     ctx,
+		conn:    downstream,
+		cluster: snapshot.ClusterInfo(),
+	})
+	if host == nil {
+		return nil, fmt.Errorf("no healthy upstream in cluster %s", clusterName)
+	}
+	return Get(ctx, cm, snapshot, host, proto, handler)
+}
+
+// lbContext is the load balancer context of the downstream connection
+type lbContext struct {
+	ctx     context.Context
+	conn    api.ReadFilterCallbacks
+	cluster types.ClusterInfo
+}
+
+func (c *lbContext) MetadataMatchCriteria() api.MetadataMatchCriteria {
+	return nil
+}
+
+func (c *lbContext) DownstreamConnection() net.Conn {
+	return c.conn.Connection().RawConn()
+}
+
+func (c *lbContext) DownstreamHeaders() api.HeaderMap {
+	return nil
+}
+
+func (c *lbContext) DownstreamContext() context.Context {
+	return c.ctx
+}
+
+func (c *lbContext) DownstreamCluster() types.ClusterInfo {
+	return c.cluster
+}
+
+func (c *lbContext) DownstreamRoute() api.Route {
+	return nil
+}
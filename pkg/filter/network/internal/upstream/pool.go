@@ -0,0 +1,393 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package upstream is the cluster connection pool of the network filters that proxy the connections
+// themselves, such as the redis proxy. The connections of a host are leased by the downstream connections
+// exclusively, and the connections released without the state are reused by the others.
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/utils"
+)
+
+const (
+	// maxIdleConns is the max number of the idle connections of a host
+	maxIdleConns = 16
+	// defaultIdleTimeout is used if the cluster has no idle timeout
+	defaultIdleTimeout = 60 * time.Second
+)
+
+var (
+	errPoolShutdown = errors.New("upstream connection pool is shut down")
+	errConnClosed   = errors.New("upstream connection is closed")
+)
+
+// Handler handles the data and the close event of a leased connection
+type Handler interface {
+	// OnData is called with the data received, the handled data is drained by the handler
+	OnData(c *Conn, buf buffer.IoBuffer)
+	// OnClose is called if the connection is closed or fails to connect when it is leased,
+	// it is not called if the connection is closed or released by the holder
+	OnClose(c *Conn, event api.ConnectionEvent)
+}
+
+// Register registers the protocol of the cluster connection pools
+func Register(proto api.ProtocolName) error {
+	return protocol.RegisterConnPool(proto, func(ctx context.Context, host types.Host) types.ConnectionPool {
+		return NewPool(proto, host)
+	})
+}
+
+// Get leases a connection of the host from the cluster connection pool of the protocol
+func Get(ctx context.Context, cm types.ClusterManager, snapshot types.ClusterSnapshot, host types.Host, proto api.ProtocolName, handler Handler) (*Conn, error) {
+	pool, err := cm.ConnPoolForHost(ctx, snapshot, host, proto)
+	if err != nil {
+		return nil, err
+	}
+	p, ok := pool.(*Pool)
+	if !ok {
+		return nil, fmt.Errorf("protocol %s is not registered by the upstream connection pool", proto)
+	}
+	return p.Get(ctx, handler)
+}
+
+// Pool is the connection pool of a host, it implements types.ConnectionPool without the protocol stream
+type Pool struct {
+	protocol api.ProtocolName
+	host     types.Host
+	tlsHash  *types.HashValue
+
+	mutex    sync.Mutex
+	idle     []*Conn
+	shutdown bool
+}
+
+func NewPool(proto api.ProtocolName, host types.Host) *Pool {
+	return &Pool{
+		protocol: proto,
+		host:     host,
+		tlsHash:  host.TLSHashValue(),
+	}
+}
+
+func (p *Pool) Protocol() api.ProtocolName {
+	return p.protocol
+}
+
+// NewStream is not supported, the connections are leased by Get
+func (p *Pool) NewStream(ctx context.Context, receiver types.StreamReceiveListener) (types.Host, types.StreamSender, types.PoolFailureReason) {
+	return p.host, nil, types.ConnectionFailure
+}
+
+// CheckAndInit returns true, the connections are connected asynchronously
+func (p *Pool) CheckAndInit(ctx context.Context) bool {
+	return true
+}
+
+func (p *Pool) TLSHashValue() *types.HashValue {
+	return p.tlsHash
+}
+
+// Shutdown closes the idle connections, the leased connections are closed when they are released
+func (p *Pool) Shutdown() {
+	p.mutex.Lock()
+	p.shutdown = true
+	idle := p.idle
+	p.idle = nil
+	p.mutex.Unlock()
+	for _, c := range idle {
+		c.Close()
+	}
+}
+
+func (p *Pool) Close() {
+	p.Shutdown()
+}
+
+func (p *Pool) Host() types.Host {
+	return p.host
+}
+
+// Get returns an idle connection, or creates a new one which is connected asynchronously.
+// The data written before the connection is connected is buffered.
+func (p *Pool) Get(ctx context.Context, handler Handler) (*Conn, error) {
+	p.mutex.Lock()
+	if p.shutdown {
+		p.mutex.Unlock()
+		return nil, errPoolShutdown
+	}
+	for len(p.idle) > 0 {
+		c := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if c.lease(handler) {
+			p.mutex.Unlock()
+			return c, nil
+		}
+	}
+	p.mutex.Unlock()
+
+	clusterInfo := p.host.ClusterInfo()
+	resource := clusterInfo.ResourceManager().Connections()
+	if !resource.CanCreate() {
+		return nil, fmt.Errorf("upstream cluster %s connections overflow", clusterInfo.Name())
+	}
+	data := p.host.CreateConnection(ctx)
+	if data.Connection == nil {
+		return nil, fmt.Errorf("create upstream connection to %s failed", p.host.AddressString())
+	}
+	resource.Increase()
+	c := &Conn{
+		pool:    p,
+		host:    data.Host,
+		conn:    data.Connection,
+		handler: handler,
+	}
+	c.conn.AddConnectionEventListener(c)
+	c.conn.FilterManager().AddReadFilter(c)
+	utils.GoWithRecover(func() {
+		// the failure is handled by the connection event
+		_ = c.conn.Connect()
+	}, nil)
+	return c, nil
+}
+
+// put adds the released connection to the idle connections, it returns false if the pool is full
+func (p *Pool) put(c *Conn) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.shutdown || len(p.idle) >= maxIdleConns {
+		return false
+	}
+	timeout := p.host.ClusterInfo().IdleTimeout()
+	if timeout <= 0 {
+		timeout = defaultIdleTimeout
+	}
+	c.mutex.Lock()
+	c.idleTimer = utils.NewTimer(timeout, func() {
+		if p.remove(c) {
+			c.Close()
+		}
+	})
+	c.mutex.Unlock()
+	p.idle = append(p.idle, c)
+	return true
+}
+
+// remove removes the connection from the idle connections, it returns false if the connection is not idle
+func (p *Pool) remove(c *Conn) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for i, idle := range p.idle {
+		if idle == c {
+			p.idle = append(p.idle[:i], p.idle[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// the states of the connections
+const (
+	stateConnecting = iota
+	stateConnected
+	stateClosed
+)
+
+// Conn is a connection of the pool
+type Conn struct {
+	pool *Pool
+	host types.Host
+	conn types.ClientConnection
+
+	mutex   sync.Mutex
+	state   int
+	handler Handler
+	// pending is the data written before the connection is connected
+	pending []byte
+	// established is true if the connection is connected, the active stats are counted
+	established bool
+	finished    bool
+	idleTimer   *utils.Timer
+}
+
+func (c *Conn) Host() types.Host {
+	return c.host
+}
+
+// Write writes the data, the data is buffered if the connection is connecting
+func (c *Conn) Write(data []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	switch c.state {
+	case stateConnecting:
+		c.pending = append(c.pending, data...)
+		return nil
+	case stateClosed:
+		return errConnClosed
+	}
+	return c.conn.Write(buffer.NewIoBufferBytes(data))
+}
+
+// Close closes the connection, the handler is not called
+func (c *Conn) Close() {
+	c.mutex.Lock()
+	c.handler = nil
+	state := c.state
+	c.state = stateClosed
+	c.pending = nil
+	c.stopIdleTimer()
+	c.mutex.Unlock()
+	// the connecting connection is closed when it is connected
+	if state == stateConnected {
+		c.conn.Close(api.NoFlush, api.LocalClose)
+	}
+}
+
+// Release returns the connection to the pool to be reused if reuse is true, otherwise the connection is closed.
+// The holder should not reuse the connection with the state, or the requests waiting for the responses.
+func (c *Conn) Release(reuse bool) {
+	if !reuse {
+		c.Close()
+		return
+	}
+	c.mutex.Lock()
+	if c.state == stateClosed {
+		c.mutex.Unlock()
+		return
+	}
+	c.handler = nil
+	c.mutex.Unlock()
+	if !c.pool.put(c) {
+		c.Close()
+	}
+}
+
+// lease sets the handler of the idle connection, it returns false if the connection is closed
+func (c *Conn) lease(handler Handler) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.state == stateClosed {
+		return false
+	}
+	c.stopIdleTimer()
+	c.handler = handler
+	return true
+}
+
+func (c *Conn) stopIdleTimer() {
+	if c.idleTimer != nil {
+		c.idleTimer.Stop()
+		c.idleTimer = nil
+	}
+}
+
+func (c *Conn) OnData(buf buffer.IoBuffer) api.FilterStatus {
+	c.mutex.Lock()
+	handler := c.handler
+	c.mutex.Unlock()
+	if handler == nil {
+		// the idle connection has no requests
+		log.DefaultLogger.Warnf("[upstream] unexpected data from the idle connection to %s", c.host.AddressString())
+		buf.Drain(buf.Len())
+		c.pool.remove(c)
+		c.Close()
+		return api.Stop
+	}
+	handler.OnData(c, buf)
+	return api.Stop
+}
+
+func (c *Conn) OnNewConnection() api.FilterStatus {
+	return api.Continue
+}
+
+func (c *Conn) InitializeReadFilterCallbacks(cb api.ReadFilterCallbacks) {}
+
+func (c *Conn) OnEvent(event api.ConnectionEvent) {
+	switch {
+	case event == api.Connected:
+		c.onConnected()
+	case event.ConnectFailure() || event.IsClose():
+		c.onClose(event)
+	}
+}
+
+func (c *Conn) onConnected() {
+	clusterInfo := c.host.ClusterInfo()
+	clusterInfo.Stats().UpstreamConnectionTotal.Inc(1)
+	clusterInfo.Stats().UpstreamConnectionActive.Inc(1)
+	c.host.HostStats().UpstreamConnectionTotal.Inc(1)
+	c.host.HostStats().UpstreamConnectionActive.Inc(1)
+
+	c.mutex.Lock()
+	c.established = true
+	if c.state == stateClosed {
+		c.mutex.Unlock()
+		c.conn.Close(api.NoFlush, api.LocalClose)
+		return
+	}
+	c.state = stateConnected
+	c.conn.SetNoDelay(true)
+	if len(c.pending) > 0 {
+		if err := c.conn.Write(buffer.NewIoBufferBytes(c.pending)); err != nil {
+			log.DefaultLogger.Errorf("[upstream] write to %s failed: %v", c.host.AddressString(), err)
+		}
+		c.pending = nil
+	}
+	c.mutex.Unlock()
+}
+
+func (c *Conn) onClose(event api.ConnectionEvent) {
+	c.mutex.Lock()
+	if c.finished {
+		c.mutex.Unlock()
+		return
+	}
+	c.finished = true
+	handler := c.handler
+	c.handler = nil
+	c.state = stateClosed
+	c.pending = nil
+	c.stopIdleTimer()
+	established := c.established
+	c.mutex.Unlock()
+
+	clusterInfo := c.host.ClusterInfo()
+	clusterInfo.ResourceManager().Connections().Decrease()
+	if established {
+		clusterInfo.Stats().UpstreamConnectionActive.Dec(1)
+		c.host.HostStats().UpstreamConnectionActive.Dec(1)
+	} else {
+		clusterInfo.Stats().UpstreamConnectionConFail.Inc(1)
+		c.host.HostStats().UpstreamConnectionConFail.Inc(1)
+	}
+	c.pool.remove(c)
+	if handler != nil {
+		handler.OnClose(c, event)
+	}
+}
@@ -0,0 +1,202 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package upstream
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/upstream/cluster"
+	"mosn.io/pkg/buffer"
+)
+
+const testProtocol api.ProtocolName = "upstream_pool_test"
+
+func init() {
+	Register(testProtocol)
+}
+
+// testHandler collects the data and the close events
+type testHandler struct {
+	mutex  sync.Mutex
+	data   []byte
+	events []api.ConnectionEvent
+}
+
+func (h *testHandler) OnData(c *Conn, buf buffer.IoBuffer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.data = append(h.data, buf.Bytes()...)
+	buf.Drain(buf.Len())
+}
+
+func (h *testHandler) OnClose(c *Conn, event api.ConnectionEvent) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.events = append(h.events, event)
+}
+
+func (h *testHandler) received() string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return string(h.data)
+}
+
+func (h *testHandler) closed() []api.ConnectionEvent {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.events
+}
+
+// newEchoServer returns an echo server and the number of the connections accepted
+func newEchoServer(t *testing.T) (net.Listener, func() int) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	var mutex sync.Mutex
+	accepted := 0
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			mutex.Lock()
+			accepted++
+			mutex.Unlock()
+			go func() {
+				defer conn.Close()
+				buf := make([]byte, 1024)
+				for {
+					n, err := conn.Read(buf)
+					if err != nil {
+						return
+					}
+					conn.Write(buf[:n])
+				}
+			}()
+		}
+	}()
+	return ln, func() int {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return accepted
+	}
+}
+
+func setupCluster(t *testing.T, addr string) (types.ClusterManager, types.ClusterSnapshot, types.Host) {
+	cm := cluster.NewClusterManagerSingleton([]v2.Cluster{{
+		Name:        "upstream_pool",
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_ROUNDROBIN,
+	}}, map[string][]v2.Host{
+		"upstream_pool": {{HostConfig: v2.HostConfig{Address: addr}}},
+	}, nil)
+	snapshot := cm.GetClusterSnapshot(context.Background(), "upstream_pool")
+	require.NotNil(t, snapshot)
+	return cm, snapshot, snapshot.HostSet().Get(0)
+}
+
+func TestPool(t *testing.T) {
+	ln, accepted := newEchoServer(t)
+	defer ln.Close()
+	cm, snapshot, host := setupCluster(t, ln.Addr().String())
+	defer cm.Destroy()
+
+	h := &testHandler{}
+	c, err := Get(context.Background(), cm, snapshot, host, testProtocol, h)
+	require.NoError(t, err)
+	// the data written before the connection is connected is buffered
+	require.NoError(t, c.Write([]byte("hello ")))
+	require.NoError(t, c.Write([]byte("world")))
+	require.Eventually(t, func() bool {
+		return h.received() == "hello world"
+	}, 3*time.Second, 10*time.Millisecond)
+	stats := host.ClusterInfo().Stats()
+	require.Equal(t, int64(1), stats.UpstreamConnectionActive.Count())
+
+	// the released connection is reused
+	c.Release(true)
+	h2 := &testHandler{}
+	c2, err := Get(context.Background(), cm, snapshot, host, testProtocol, h2)
+	require.NoError(t, err)
+	require.True(t, c == c2)
+	require.NoError(t, c2.Write([]byte("again")))
+	require.Eventually(t, func() bool {
+		return h2.received() == "again"
+	}, 3*time.Second, 10*time.Millisecond)
+	require.Equal(t, "hello world", h.received())
+	require.Equal(t, 1, accepted())
+
+	// the connection is closed if it is not reused, the handler is not called
+	c2.Release(false)
+	require.Error(t, c2.Write([]byte("closed")))
+	require.Eventually(t, func() bool {
+		return stats.UpstreamConnectionActive.Count() == 0
+	}, 3*time.Second, 10*time.Millisecond)
+	require.Empty(t, h2.closed())
+
+	// the handler is called if the upstream closes the connection
+	h3 := &testHandler{}
+	c3, err := Get(context.Background(), cm, snapshot, host, testProtocol, h3)
+	require.NoError(t, err)
+	require.False(t, c3 == c2)
+	require.NoError(t, c3.Write([]byte("ping")))
+	require.Eventually(t, func() bool {
+		return h3.received() == "ping"
+	}, 3*time.Second, 10*time.Millisecond)
+	ln.Close()
+	c3.conn.RawConn().(*net.TCPConn).CloseRead()
+	require.Eventually(t, func() bool {
+		return len(h3.closed()) == 1
+	}, 3*time.Second, 10*time.Millisecond)
+
+	// the idle connections are closed when the pool is shut down
+	pool, err := cm.ConnPoolForHost(context.Background(), snapshot, host, testProtocol)
+	require.NoError(t, err)
+	pool.Shutdown()
+	_, err = Get(context.Background(), cm, snapshot, host, testProtocol, h3)
+	require.Equal(t, errPoolShutdown, err)
+}
+
+func TestPoolConnectFailed(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+	cm, snapshot, host := setupCluster(t, addr)
+	defer cm.Destroy()
+
+	// the cluster stats are shared by the clusters with the same name
+	conFail := host.ClusterInfo().Stats().UpstreamConnectionConFail.Count()
+	h := &testHandler{}
+	c, err := Get(context.Background(), cm, snapshot, host, testProtocol, h)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return len(h.closed()) == 1
+	}, 3*time.Second, 10*time.Millisecond)
+	require.True(t, h.closed()[0].ConnectFailure())
+	require.Error(t, c.Write([]byte("data")))
+	require.Equal(t, conFail+1, host.ClusterInfo().Stats().UpstreamConnectionConFail.Count())
+}
@@ -0,0 +1,83 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafkaproxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/filter/network/internal/upstream"
+)
+
+func init() {
+	api.RegisterNetwork(v2.KAFKA_PROXY, CreateKafkaProxyFactory)
+	upstream.Register(upstreamProtocol)
+}
+
+var upstreamProtocol = api.ProtocolName(v2.KAFKA_PROXY)
+
+type kafkaProxyFilterConfigFactory struct {
+	Proxy    *v2.KafkaProxy
+	rewriter *rewriter
+}
+
+func (f *kafkaProxyFilterConfigFactory) CreateFilterChain(context context.Context, callbacks api.NetWorkFilterChainFactoryCallbacks) {
+	rf := newProxy(context, f.Proxy, f.rewriter)
+	callbacks.AddReadFilter(rf)
+}
+
+func CreateKafkaProxyFactory(conf map[string]interface{}) (api.NetworkFilterChainFactory, error) {
+	p, err := ParseKafkaProxy(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaProxyFilterConfigFactory{
+		Proxy:    p,
+		rewriter: newRewriter(p),
+	}, nil
+}
+
+// ParseKafkaProxy parses the kafka proxy config
+func ParseKafkaProxy(cfg map[string]interface{}) (*v2.KafkaProxy, error) {
+	proxy := &v2.KafkaProxy{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("[config] config is not a kafka proxy config: %v", err)
+	}
+	if err := json.Unmarshal(data, proxy); err != nil {
+		return nil, fmt.Errorf("[config] config is not a kafka proxy config: %v", err)
+	}
+	if proxy.Cluster == "" {
+		return nil, errors.New("[config] kafka proxy has no cluster")
+	}
+	ids := map[int32]bool{}
+	for _, r := range proxy.BrokerAddressRewrites {
+		if r.Host == "" && r.Port == 0 {
+			return nil, fmt.Errorf("[config] kafka proxy broker %d rewrite has no host or port", r.ID)
+		}
+		if ids[r.ID] {
+			return nil, fmt.Errorf("[config] kafka proxy broker %d rewrite is duplicated", r.ID)
+		}
+		ids[r.ID] = true
+	}
+	return proxy, nil
+}
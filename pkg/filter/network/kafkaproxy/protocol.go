@@ -0,0 +1,271 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafkaproxy
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// the api keys handled by the proxy
+const (
+	apiProduce            = 0
+	apiFetch              = 1
+	apiMetadata           = 3
+	apiControlledShutdown = 7
+	apiFindCoordinator    = 10
+	apiSaslHandshake      = 17
+	apiVersions           = 18
+)
+
+// unknownAPI is used in the metrics of the unknown api keys, to avoid high label cardinality
+const unknownAPI = "unknown"
+
+// apiNames are the names of the api keys used in the metrics
+var apiNames = []string{
+	"produce", "fetch", "list_offsets", "metadata", "leader_and_isr", "stop_replica", "update_metadata",
+	"controlled_shutdown", "offset_commit", "offset_fetch", "find_coordinator", "join_group", "heartbeat",
+	"leave_group", "sync_group", "describe_groups", "list_groups", "sasl_handshake", "api_versions",
+	"create_topics", "delete_topics", "delete_records", "init_producer_id", "offset_for_leader_epoch",
+	"add_partitions_to_txn", "add_offsets_to_txn", "end_txn", "write_txn_markers", "txn_offset_commit",
+	"describe_acls", "create_acls", "delete_acls", "describe_configs", "alter_configs",
+	"alter_replica_log_dirs", "describe_log_dirs", "sasl_authenticate", "create_partitions",
+	"create_delegation_token", "renew_delegation_token", "expire_delegation_token",
+	"describe_delegation_token", "delete_groups", "elect_leaders", "incremental_alter_configs",
+	"alter_partition_reassignments", "list_partition_reassignments", "offset_delete",
+	"describe_client_quotas", "alter_client_quotas", "describe_user_scram_credentials",
+	"alter_user_scram_credentials", "vote", "begin_quorum_epoch", "end_quorum_epoch", "describe_quorum",
+	"alter_partition", "update_features", "envelope", "fetch_snapshot", "describe_cluster",
+	"describe_producers", "broker_registration", "broker_heartbeat", "unregister_broker",
+	"describe_transactions", "list_transactions", "allocate_producer_ids", "consumer_group_heartbeat",
+}
+
+// flexibleVersions are the first flexible versions of the api keys, the flexible versions use the
+// compact types and the tagged fields. -1 means the api key has no flexible version.
+var flexibleVersions = []int16{
+	9, 12, 6, 9, 4, 2, 6, 3, 8, 6, 3, 6, 4, 4, 4, 5, 3, -1, 3, 5, 4, 2, 2, 4, 3, 3, 3, 1, 3, 2, 2, 2, 4, 2,
+	2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 0, 0, -1, 1, 1, 0, 0, 0, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+}
+
+// apiName returns the name of the api key
+func apiName(key int16) string {
+	if key >= 0 && int(key) < len(apiNames) {
+		return apiNames[key]
+	}
+	return unknownAPI
+}
+
+// isFlexible returns true if the version of the api key is flexible
+func isFlexible(key, version int16) bool {
+	if key < 0 || int(key) >= len(flexibleVersions) {
+		return false
+	}
+	v := flexibleVersions[key]
+	return v >= 0 && version >= v
+}
+
+// responseHeaderFlexible returns true if the response header has the tagged fields, the response
+// header of ApiVersions never has the tagged fields, so the clients can parse it before the versions
+// are negotiated.
+func responseHeaderFlexible(key, version int16) bool {
+	return key != apiVersions && isFlexible(key, version)
+}
+
+var (
+	errIncomplete = errors.New("incomplete message")
+	errMalformed  = errors.New("malformed message")
+)
+
+// sizeLength is the length of the size of the messages
+const sizeLength = 4
+
+// messageSize returns the size of the message including the size field
+func messageSize(data []byte) (int, error) {
+	if len(data) < sizeLength {
+		return 0, errIncomplete
+	}
+	size := int32(binary.BigEndian.Uint32(data))
+	if size < 0 {
+		return 0, fmt.Errorf("invalid message size %d", size)
+	}
+	return sizeLength + int(size), nil
+}
+
+// RequestHeader is the header of the requests
+type RequestHeader struct {
+	APIKey        int16
+	APIVersion    int16
+	CorrelationID int32
+	ClientID      string
+}
+
+// reader reads the fields of a message, the error is saved and the following reads return zero values
+type reader struct {
+	b   []byte
+	off int
+	err error
+}
+
+func (r *reader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.b)-r.off < n {
+		r.err = errMalformed
+		return nil
+	}
+	v := r.b[r.off : r.off+n]
+	r.off += n
+	return v
+}
+
+func (r *reader) int8() int8 {
+	if v := r.next(1); v != nil {
+		return int8(v[0])
+	}
+	return 0
+}
+
+func (r *reader) int16() int16 {
+	if v := r.next(2); v != nil {
+		return int16(binary.BigEndian.Uint16(v))
+	}
+	return 0
+}
+
+func (r *reader) int32() int32 {
+	if v := r.next(4); v != nil {
+		return int32(binary.BigEndian.Uint32(v))
+	}
+	return 0
+}
+
+func (r *reader) int64() int64 {
+	if v := r.next(8); v != nil {
+		return int64(binary.BigEndian.Uint64(v))
+	}
+	return 0
+}
+
+func (r *reader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.b[r.off:])
+	if n <= 0 {
+		r.err = errMalformed
+		return 0
+	}
+	r.off += n
+	return v
+}
+
+// length reads the length of a string, bytes or array, which is -1 for null
+func (r *reader) length(flexible, int32Length bool) int {
+	if flexible {
+		// the compact length is the length plus 1
+		return int(r.uvarint()) - 1
+	}
+	if int32Length {
+		return int(r.int32())
+	}
+	return int(r.int16())
+}
+
+// string reads a string or a nullable string
+func (r *reader) string(flexible bool) string {
+	n := r.length(flexible, false)
+	if n < 0 {
+		return ""
+	}
+	return string(r.next(n))
+}
+
+// bytes reads the bytes or the nullable bytes, and returns the length
+func (r *reader) bytes(flexible bool) int {
+	n := r.length(flexible, true)
+	if n < 0 {
+		return 0
+	}
+	r.next(n)
+	return n
+}
+
+// array reads the length of an array, which is 0 for null
+func (r *reader) array(flexible bool) int {
+	n := r.length(flexible, true)
+	if n < 0 || n > len(r.b)-r.off {
+		if n > 0 {
+			r.err = errMalformed
+		}
+		return 0
+	}
+	return n
+}
+
+// taggedFields skips the tagged fields
+func (r *reader) taggedFields(flexible bool) {
+	if !flexible {
+		return
+	}
+	for n := r.uvarint(); n > 0 && r.err == nil; n-- {
+		r.uvarint()
+		r.next(int(r.uvarint()))
+	}
+}
+
+// ParseRequestHeader parses the request header of the message without the size, and returns the
+// offset of the request body
+func ParseRequestHeader(msg []byte) (*RequestHeader, int, error) {
+	r := &reader{b: msg}
+	h := &RequestHeader{
+		APIKey:        r.int16(),
+		APIVersion:    r.int16(),
+		CorrelationID: r.int32(),
+	}
+	// the request header v0 of ControlledShutdown v0 has no client id
+	if !(h.APIKey == apiControlledShutdown && h.APIVersion == 0) {
+		h.ClientID = r.string(false)
+	}
+	r.taggedFields(isFlexible(h.APIKey, h.APIVersion))
+	if r.err != nil {
+		return nil, 0, r.err
+	}
+	return h, r.off, nil
+}
+
+func appendInt32(out []byte, v int32) []byte {
+	return append(out, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUvarint(out []byte, v uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	return append(out, b[:n]...)
+}
+
+// appendString appends a string, which is compact in the flexible versions
+func appendString(out []byte, s string, flexible bool) []byte {
+	if flexible {
+		out = appendUvarint(out, uint64(len(s))+1)
+	} else {
+		out = append(out, byte(len(s)>>8), byte(len(s)))
+	}
+	return append(out, s...)
+}
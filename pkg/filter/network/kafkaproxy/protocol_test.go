@@ -0,0 +1,222 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafkaproxy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writer encodes the fields of the messages in the tests
+type writer struct {
+	b []byte
+}
+
+func (w *writer) int8(v int8) *writer {
+	w.b = append(w.b, byte(v))
+	return w
+}
+
+func (w *writer) int16(v int16) *writer {
+	w.b = append(w.b, byte(v>>8), byte(v))
+	return w
+}
+
+func (w *writer) int32(v int32) *writer {
+	w.b = appendInt32(w.b, v)
+	return w
+}
+
+func (w *writer) int64(v int64) *writer {
+	return w.int32(int32(v >> 32)).int32(int32(v))
+}
+
+func (w *writer) string(s string, flexible bool) *writer {
+	w.b = appendString(w.b, s, flexible)
+	return w
+}
+
+func (w *writer) nullString(flexible bool) *writer {
+	if flexible {
+		w.b = append(w.b, 0)
+		return w
+	}
+	return w.int16(-1)
+}
+
+func (w *writer) bytes(b []byte, flexible bool) *writer {
+	if flexible {
+		w.b = appendUvarint(w.b, uint64(len(b))+1)
+	} else {
+		w.int32(int32(len(b)))
+	}
+	w.b = append(w.b, b...)
+	return w
+}
+
+func (w *writer) array(n int, flexible bool) *writer {
+	if flexible {
+		w.b = appendUvarint(w.b, uint64(n)+1)
+		return w
+	}
+	return w.int32(int32(n))
+}
+
+func (w *writer) tags(flexible bool) *writer {
+	if flexible {
+		w.b = append(w.b, 0)
+	}
+	return w
+}
+
+// requestHeader encodes the request header
+func requestHeader(key, version int16, correlationID int32) *writer {
+	w := &writer{}
+	w.int16(key).int16(version).int32(correlationID).string("client", false)
+	return w.tags(isFlexible(key, version))
+}
+
+func TestAPITables(t *testing.T) {
+	require.Equal(t, len(apiNames), len(flexibleVersions))
+	require.Equal(t, "produce", apiName(apiProduce))
+	require.Equal(t, "api_versions", apiName(apiVersions))
+	require.Equal(t, unknownAPI, apiName(1000))
+	require.Equal(t, unknownAPI, apiName(-1))
+	require.True(t, isFlexible(apiMetadata, 9))
+	require.False(t, isFlexible(apiMetadata, 8))
+	require.False(t, isFlexible(apiSaslHandshake, 1))
+	require.True(t, isFlexible(apiVersions, 3))
+	require.False(t, responseHeaderFlexible(apiVersions, 3))
+}
+
+func TestParseRequestHeader(t *testing.T) {
+	for _, version := range []int16{8, 9} {
+		w := requestHeader(apiMetadata, version, 42)
+		body := len(w.b)
+		if version == 9 {
+			// a tagged field in the header
+			w.b[len(w.b)-1] = 1
+			w.b = append(w.b, 0, 1, 'x')
+			body += 3
+		}
+		w.int32(0)
+		h, off, err := ParseRequestHeader(w.b)
+		require.NoError(t, err)
+		require.Equal(t, &RequestHeader{APIKey: apiMetadata, APIVersion: version, CorrelationID: 42, ClientID: "client"}, h)
+		require.Equal(t, body, off)
+	}
+	_, _, err := ParseRequestHeader([]byte{0, 3, 0, 9, 0})
+	require.Error(t, err)
+
+	// ControlledShutdown v0 has no client id
+	h, off, err := ParseRequestHeader((&writer{}).int16(apiControlledShutdown).int16(0).int32(1).b)
+	require.NoError(t, err)
+	require.Equal(t, 8, off)
+	require.Equal(t, "", h.ClientID)
+
+	_, err = messageSize([]byte{0xff, 0, 0, 0})
+	require.Error(t, err)
+	n, err := messageSize([]byte{0, 0, 1, 0})
+	require.NoError(t, err)
+	require.Equal(t, 260, n)
+}
+
+// produceBody encodes the Produce request body
+func produceBody(version, acks int16, topics map[string][]byte) []byte {
+	flexible := isFlexible(apiProduce, version)
+	w := &writer{}
+	if version >= 3 {
+		w.nullString(flexible)
+	}
+	w.int16(acks).int32(1000).array(len(topics), flexible)
+	for topic, records := range topics {
+		w.string(topic, flexible).array(1, flexible).int32(0).bytes(records, flexible).tags(flexible).tags(flexible)
+	}
+	return w.tags(flexible).b
+}
+
+// fetchBody encodes the Fetch request body
+func fetchBody(version int16, topics ...string) []byte {
+	flexible := isFlexible(apiFetch, version)
+	w := &writer{}
+	if version < 15 {
+		w.int32(-1)
+	}
+	w.int32(500).int32(1)
+	if version >= 3 {
+		w.int32(1 << 20)
+	}
+	if version >= 4 {
+		w.int8(0)
+	}
+	if version >= 7 {
+		w.int32(0).int32(-1)
+	}
+	w.array(len(topics), flexible)
+	for _, topic := range topics {
+		if version >= 13 {
+			w.b = append(w.b, make([]byte, 16)...)
+		} else {
+			w.string(topic, flexible)
+		}
+		w.array(1, flexible).int32(0)
+		if version >= 9 {
+			w.int32(-1)
+		}
+		w.int64(100)
+		if version >= 12 {
+			w.int32(-1)
+		}
+		if version >= 5 {
+			w.int64(-1)
+		}
+		w.int32(1 << 20).tags(flexible).tags(flexible)
+	}
+	return w.tags(flexible).b
+}
+
+func TestParseProduceRequest(t *testing.T) {
+	for _, version := range []int16{0, 3, 8, 9} {
+		acks, topics, err := ParseProduceRequest(produceBody(version, 1, map[string][]byte{"orders": make([]byte, 100)}), version)
+		require.NoError(t, err)
+		require.Equal(t, int16(1), acks)
+		require.Equal(t, []TopicData{{Topic: "orders", Bytes: 100}}, topics)
+	}
+	acks, _, err := ParseProduceRequest(produceBody(9, 0, nil), 9)
+	require.NoError(t, err)
+	require.Equal(t, int16(0), acks)
+	_, _, err = ParseProduceRequest([]byte{0, 1, 0}, 0)
+	require.Error(t, err)
+}
+
+func TestParseFetchRequest(t *testing.T) {
+	for _, version := range []int16{0, 4, 7, 11, 12} {
+		topics, err := ParseFetchRequest(fetchBody(version, "orders", "users"), version)
+		require.NoError(t, err, "version %d", version)
+		require.Equal(t, []TopicData{{Topic: "orders"}, {Topic: "users"}}, topics)
+	}
+	// the topic ids are not returned
+	for _, version := range []int16{13, 15} {
+		topics, err := ParseFetchRequest(fetchBody(version, "orders"), version)
+		require.NoError(t, err)
+		require.Empty(t, topics)
+	}
+	_, err := ParseFetchRequest([]byte{0, 0}, 0)
+	require.Error(t, err)
+}
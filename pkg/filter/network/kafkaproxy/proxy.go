@@ -0,0 +1,353 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafkaproxy
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/filter/network/internal/upstream"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/upstream/cluster"
+	"mosn.io/pkg/buffer"
+)
+
+// responsePeekSize is the size of the response size and the correlation id
+const responsePeekSize = sizeLength + 4
+
+// proxy is a ReadFilter that proxies a Kafka connection to a broker. The request headers are decoded to
+// record the metrics by the api keys and the topics, and the responses are matched with the requests by
+// the correlation ids. The broker addresses in the Metadata and FindCoordinator responses can be
+// rewritten, so the clients connect to the brokers through the listeners of MOSN.
+//
+// The requests are forwarded after they are received completely, the responses are forwarded as they
+// are received, except the responses rewritten by the proxy. The connection is passed through after
+// the SaslHandshake v0 request, because the SASL tokens are not framed as Kafka requests.
+type proxy struct {
+	config         *v2.KafkaProxy
+	rewriter       *rewriter
+	stats          *proxyStats
+	clusterManager types.ClusterManager
+	readCallbacks  api.ReadFilterCallbacks
+	ctx            context.Context
+
+	mutex       sync.Mutex
+	upstream    *upstreamConn
+	closed      bool
+	passthrough bool
+	// requests are the requests waiting for the responses, the responses are in the order of the requests
+	requests []*request
+	// remaining is the remaining size of the response being forwarded
+	remaining int
+}
+
+// request is a request waiting for the response
+type request struct {
+	header *RequestHeader
+	start  time.Time
+}
+
+func newProxy(ctx context.Context, config *v2.KafkaProxy, rw *rewriter) *proxy {
+	return &proxy{
+		config:         config,
+		rewriter:       rw,
+		stats:          getProxyStats(config.StatPrefix),
+		clusterManager: cluster.GetClusterMngAdapterInstance().ClusterManager,
+		ctx:            ctx,
+	}
+}
+
+func (p *proxy) OnNewConnection() api.FilterStatus {
+	uc, err := p.connectUpstream()
+	if err != nil {
+		log.DefaultLogger.Errorf("[kafka proxy] connect upstream failed: %v", err)
+		p.readCallbacks.Connection().Close(api.NoFlush, api.LocalClose)
+		return api.Stop
+	}
+	p.mutex.Lock()
+	p.upstream = uc
+	p.mutex.Unlock()
+	p.readCallbacks.SetUpstreamHost(uc.conn.Host())
+	return api.Continue
+}
+
+func (p *proxy) InitializeReadFilterCallbacks(cb api.ReadFilterCallbacks) {
+	p.readCallbacks = cb
+	p.readCallbacks.Connection().AddConnectionEventListener(p)
+}
+
+func (p *proxy) OnData(buf buffer.IoBuffer) api.FilterStatus {
+	var up []byte
+	p.mutex.Lock()
+	for !p.closed && p.upstream != nil && buf.Len() > 0 {
+		if p.passthrough {
+			up = append(up, buf.Bytes()...)
+			buf.Drain(buf.Len())
+			break
+		}
+		data := buf.Bytes()
+		n, err := messageSize(data)
+		if err == nil && len(data) < n {
+			err = errIncomplete
+		}
+		if err == errIncomplete {
+			break
+		}
+		if err == nil {
+			err = p.handleRequest(data[:n])
+		}
+		if err != nil {
+			log.DefaultLogger.Errorf("[kafka proxy] decode request failed: %v", err)
+			p.stats.RequestDecodeError.Inc(1)
+			p.passthrough = true
+			continue
+		}
+		up = append(up, data[:n]...)
+		buf.Drain(n)
+	}
+	if p.closed {
+		buf.Drain(buf.Len())
+	}
+	upstream := p.upstream
+	p.mutex.Unlock()
+
+	if len(up) > 0 && upstream != nil {
+		upstream.write(up)
+	}
+	return api.Stop
+}
+
+// handleRequest records the metrics of the request message including the size
+func (p *proxy) handleRequest(data []byte) error {
+	msg := data[sizeLength:]
+	h, off, err := ParseRequestHeader(msg)
+	if err != nil {
+		return err
+	}
+	name := apiName(h.APIKey)
+	stats := getAPIStats(p.config.StatPrefix, name)
+	stats.RequestTotal.Inc(1)
+	stats.RequestBytes.Inc(int64(len(data)))
+
+	expectResponse := true
+	switch h.APIKey {
+	case apiProduce:
+		acks, topics, err := ParseProduceRequest(msg[off:], h.APIVersion)
+		if err != nil {
+			return fmt.Errorf("parse produce request failed: %v", err)
+		}
+		// the Produce request with acks 0 has no response
+		expectResponse = acks != 0
+		p.recordTopics(name, topics)
+	case apiFetch:
+		topics, err := ParseFetchRequest(msg[off:], h.APIVersion)
+		if err != nil {
+			return fmt.Errorf("parse fetch request failed: %v", err)
+		}
+		p.recordTopics(name, topics)
+	case apiSaslHandshake:
+		// the SASL tokens follow the SaslHandshake v0 without the Kafka request headers
+		if h.APIVersion == 0 {
+			p.passthrough = true
+		}
+	}
+	if expectResponse {
+		p.requests = append(p.requests, &request{
+			header: h,
+			start:  time.Now(),
+		})
+	}
+	return nil
+}
+
+func (p *proxy) recordTopics(api string, topics []TopicData) {
+	if !p.config.TopicStats {
+		return
+	}
+	for _, t := range topics {
+		stats := getTopicStats(p.config.StatPrefix, api, t.Topic)
+		stats.RequestTotal.Inc(1)
+		stats.RequestBytes.Inc(int64(t.Bytes))
+	}
+}
+
+// handleResponses handles the responses of the upstream, and returns the data forwarded to the client
+func (p *proxy) handleResponses(buf buffer.IoBuffer) []byte {
+	var down []byte
+	for buf.Len() > 0 {
+		data := buf.Bytes()
+		if p.passthrough {
+			down = append(down, data...)
+			buf.Drain(len(data))
+			break
+		}
+		if p.remaining > 0 {
+			n := p.remaining
+			if n > len(data) {
+				n = len(data)
+			}
+			down = append(down, data[:n]...)
+			buf.Drain(n)
+			p.remaining -= n
+			continue
+		}
+		if len(data) < responsePeekSize {
+			break
+		}
+		n, err := messageSize(data)
+		if err == nil && n < responsePeekSize {
+			err = errMalformed
+		}
+		var req *request
+		if err == nil {
+			correlationID := int32(binary.BigEndian.Uint32(data[sizeLength:]))
+			if len(p.requests) == 0 || p.requests[0].header.CorrelationID != correlationID {
+				err = fmt.Errorf("unexpected correlation id %d", correlationID)
+			} else {
+				req = p.requests[0]
+			}
+		}
+		if err == nil && p.rewriter.needRewrite(req.header.APIKey) {
+			if len(data) < n {
+				break
+			}
+			msg, count, rerr := p.rewriter.Rewrite(req.header.APIKey, req.header.APIVersion, data[sizeLength:n])
+			if rerr == nil {
+				p.stats.BrokerRewrite.Inc(int64(count))
+				p.completeRequest(req, n)
+				down = appendMessage(down, msg)
+				buf.Drain(n)
+				continue
+			}
+			err = fmt.Errorf("rewrite %s response failed: %v", apiName(req.header.APIKey), rerr)
+		}
+		if err != nil {
+			log.DefaultLogger.Errorf("[kafka proxy] decode response failed: %v", err)
+			p.stats.ResponseDecodeError.Inc(1)
+			p.passthrough = true
+			p.requests = nil
+			continue
+		}
+		p.completeRequest(req, n)
+		p.remaining = n
+	}
+	return down
+}
+
+// completeRequest records the metrics of the response with the size
+func (p *proxy) completeRequest(req *request, size int) {
+	p.requests = p.requests[1:]
+	stats := getAPIStats(p.config.StatPrefix, apiName(req.header.APIKey))
+	stats.ResponseTotal.Inc(1)
+	stats.ResponseBytes.Inc(int64(size))
+	stats.RequestTime.Update(time.Since(req.start).Nanoseconds())
+}
+
+// connectUpstream leases a connection of the cluster, the connection is connected asynchronously
+// and the requests are sent when it is connected.
+func (p *proxy) connectUpstream() (*upstreamConn, error) {
+	uc := &upstreamConn{
+		proxy: p,
+	}
+	conn, err := upstream.Connect(p.ctx, p.clusterManager, p.config.Cluster, p.readCallbacks, upstreamProtocol, uc)
+	if err != nil {
+		return nil, err
+	}
+	uc.conn = conn
+	return uc, nil
+}
+
+// removeUpstream marks the upstream connection closed, it returns false if it is removed
+func (p *proxy) removeUpstream(uc *upstreamConn) bool {
+	if uc.closed {
+		return false
+	}
+	uc.closed = true
+	return true
+}
+
+// OnEvent handles the downstream connection events
+func (p *proxy) OnEvent(event api.ConnectionEvent) {
+	if !event.IsClose() {
+		return
+	}
+	p.mutex.Lock()
+	p.closed = true
+	uc := p.upstream
+	removed := uc != nil && p.removeUpstream(uc)
+	p.mutex.Unlock()
+
+	// the connection is not reused, the responses of the pending requests may be received
+	if removed {
+		uc.conn.Release(false)
+	}
+}
+
+// upstreamConn is the upstream connection of a downstream connection
+type upstreamConn struct {
+	proxy  *proxy
+	conn   *upstream.Conn
+	closed bool
+}
+
+func (uc *upstreamConn) write(data []byte) {
+	if err := uc.conn.Write(data); err != nil {
+		log.DefaultLogger.Errorf("[kafka proxy] write to upstream %s failed: %v", uc.conn.Host().AddressString(), err)
+	}
+}
+
+func (uc *upstreamConn) OnData(conn *upstream.Conn, buf buffer.IoBuffer) {
+	p := uc.proxy
+	p.mutex.Lock()
+	var down []byte
+	if !uc.closed {
+		down = p.handleResponses(buf)
+	} else {
+		buf.Drain(buf.Len())
+	}
+	closed := p.closed
+	p.mutex.Unlock()
+
+	if len(down) > 0 && !closed {
+		if err := p.readCallbacks.Connection().Write(buffer.NewIoBufferBytes(down)); err != nil {
+			log.DefaultLogger.Errorf("[kafka proxy] write to downstream failed: %v", err)
+		}
+	}
+}
+
+// OnClose handles the upstream connection closed by the broker or failed to connect
+func (uc *upstreamConn) OnClose(conn *upstream.Conn, event api.ConnectionEvent) {
+	p := uc.proxy
+	p.mutex.Lock()
+	removed := p.removeUpstream(uc)
+	closed := p.closed
+	p.mutex.Unlock()
+	if event.ConnectFailure() {
+		log.DefaultLogger.Errorf("[kafka proxy] connect upstream %s failed", conn.Host().AddressString())
+	}
+	// the broker closes the connection, the responses received are flushed to the client
+	if removed && !closed {
+		p.readCallbacks.Connection().Close(api.FlushWrite, api.RemoteClose)
+	}
+}
@@ -0,0 +1,243 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafkaproxy
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/filter/network/internal/proxytest"
+)
+
+// fakeBroker is a Kafka broker which responds the Metadata requests with itself as the only broker,
+// and responds the other requests with empty bodies
+type fakeBroker struct {
+	*proxytest.Server
+	mutex sync.Mutex
+	apis  []int16
+}
+
+func newFakeBroker(t *testing.T) *fakeBroker {
+	b := &fakeBroker{}
+	b.Server = proxytest.NewServer(t, b.serve)
+	return b
+}
+
+func (b *fakeBroker) received() []int16 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return append([]int16(nil), b.apis...)
+}
+
+func (b *fakeBroker) serve(conn net.Conn) {
+	defer conn.Close()
+	host, portStr, _ := net.SplitHostPort(conn.LocalAddr().String())
+	port, _ := strconv.Atoi(portStr)
+	for {
+		size := make([]byte, sizeLength)
+		if _, err := io.ReadFull(conn, size); err != nil {
+			return
+		}
+		msg := make([]byte, binary.BigEndian.Uint32(size))
+		if _, err := io.ReadFull(conn, msg); err != nil {
+			return
+		}
+		h, off, err := ParseRequestHeader(msg)
+		if err != nil {
+			return
+		}
+		b.mutex.Lock()
+		b.apis = append(b.apis, h.APIKey)
+		b.mutex.Unlock()
+
+		var resp []byte
+		switch h.APIKey {
+		case apiMetadata:
+			resp = metadataResponse(h.APIVersion, h.CorrelationID, testBroker{0, host, int32(port)})
+		case apiProduce:
+			acks, _, err := ParseProduceRequest(msg[off:], h.APIVersion)
+			if err != nil {
+				return
+			}
+			if acks == 0 {
+				continue
+			}
+			resp = (&writer{}).int32(h.CorrelationID).int32(0).b
+		default:
+			resp = (&writer{}).int32(h.CorrelationID).int32(0).b
+		}
+		conn.Write(appendMessage(nil, resp))
+	}
+}
+
+// testClient is a downstream connection of the proxy
+type testClient struct {
+	*proxytest.Client
+	proxy *proxy
+}
+
+func newTestClient(t *testing.T, ctrl *gomock.Controller, config *v2.KafkaProxy) *testClient {
+	p := newProxy(context.Background(), config, newRewriter(config))
+	return &testClient{
+		Client: proxytest.NewClient(t, ctrl, p, decodeMessage),
+		proxy:  p,
+	}
+}
+
+func decodeMessage(data []byte) (int, error) {
+	n, err := messageSize(data)
+	if err != nil || len(data) < n {
+		return 0, nil
+	}
+	return n, nil
+}
+
+// send sends the request message, and returns the received response messages without the sizes
+func (c *testClient) send(msg []byte, count int) [][]byte {
+	return trimSizes(c.Send(appendMessage(nil, msg), count))
+}
+
+func (c *testClient) read(count int) [][]byte {
+	return trimSizes(c.Read(count))
+}
+
+func trimSizes(messages [][]byte) [][]byte {
+	for i, msg := range messages {
+		messages[i] = msg[sizeLength:]
+	}
+	return messages
+}
+
+func setupCluster(t *testing.T, b *fakeBroker) {
+	proxytest.SetupClusters(map[string][]string{"kafka": {b.Addr()}})
+}
+
+func resetStats() {
+	proxytest.ResetStats(&proxyStatsCache, &apiStatsCache, &topicStatsCache)
+}
+
+func TestKafkaProxy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	broker := newFakeBroker(t)
+	defer broker.Close()
+	resetStats()
+	setupCluster(t, broker)
+	defer proxytest.Cleanup()
+
+	config := &v2.KafkaProxy{
+		StatPrefix: "test_kafka",
+		Cluster:    "kafka",
+		BrokerAddressRewrites: []*v2.KafkaBrokerAddressRewrite{
+			{ID: 0, Host: "kafka.mosn.local", Port: 19092},
+		},
+		TopicStats: true,
+	}
+	c := newTestClient(t, ctrl, config)
+
+	// the broker address is rewritten
+	for _, version := range []int16{1, 9} {
+		msg := requestHeader(apiMetadata, version, int32(version)).array(0, isFlexible(apiMetadata, version)).tags(isFlexible(apiMetadata, version)).b
+		resp := c.send(msg, 1)[0]
+		require.Equal(t, metadataResponse(version, int32(version), testBroker{0, "kafka.mosn.local", 19092}), resp)
+	}
+
+	// the Produce request with acks 0 has no response
+	produce := append(requestHeader(apiProduce, 3, 10).b, produceBody(3, 0, map[string][]byte{"orders": make([]byte, 64)})...)
+	c.send(produce, 0)
+	produceAcks := append(requestHeader(apiProduce, 9, 11).b, produceBody(9, 1, map[string][]byte{"orders": make([]byte, 32)})...)
+	resp := c.send(produceAcks, 1)[0]
+	require.Equal(t, int32(11), int32(binary.BigEndian.Uint32(resp)))
+
+	fetch := append(requestHeader(apiFetch, 11, 12).b, fetchBody(11, "orders", "users")...)
+	resp = c.send(fetch, 1)[0]
+	require.Equal(t, int32(12), int32(binary.BigEndian.Uint32(resp)))
+	require.Equal(t, []int16{apiMetadata, apiMetadata, apiProduce, apiProduce, apiFetch}, broker.received())
+
+	stats := getProxyStats("test_kafka")
+	require.Equal(t, int64(2), stats.BrokerRewrite.Count())
+	require.Equal(t, int64(0), stats.RequestDecodeError.Count())
+	require.Equal(t, int64(0), stats.ResponseDecodeError.Count())
+	produceStats := getAPIStats("test_kafka", "produce")
+	require.Equal(t, int64(2), produceStats.RequestTotal.Count())
+	require.Equal(t, int64(1), produceStats.ResponseTotal.Count())
+	require.Equal(t, int64(len(produce)+len(produceAcks)+2*sizeLength), produceStats.RequestBytes.Count())
+	require.Equal(t, int64(2), getAPIStats("test_kafka", "metadata").ResponseTotal.Count())
+	require.Equal(t, int64(1), getAPIStats("test_kafka", "fetch").ResponseTotal.Count())
+	topic := getTopicStats("test_kafka", "produce", "orders")
+	require.Equal(t, int64(2), topic.RequestTotal.Count())
+	require.Equal(t, int64(96), topic.RequestBytes.Count())
+	require.Equal(t, int64(1), getTopicStats("test_kafka", "fetch", "users").RequestTotal.Count())
+
+	// the connection is passed through after a request can not be decoded
+	c.send([]byte{0, 0}, 0)
+	require.Equal(t, int64(1), stats.RequestDecodeError.Count())
+	require.Eventually(t, c.IsClosed, 3*time.Second, 5*time.Millisecond)
+}
+
+func TestKafkaProxyNoUpstream(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	resetStats()
+	// the broker of the cluster is down
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ln.Close()
+	proxytest.SetupClusters(map[string][]string{"kafka_down": {ln.Addr().String()}})
+	defer proxytest.Cleanup()
+
+	for _, cluster := range []string{"kafka_missing", "kafka_down"} {
+		c := newTestClient(t, ctrl, &v2.KafkaProxy{Cluster: cluster})
+		require.Eventually(t, c.IsClosed, time.Second, 10*time.Millisecond)
+	}
+}
+
+func TestParseKafkaProxy(t *testing.T) {
+	p, err := ParseKafkaProxy(map[string]interface{}{
+		"cluster": "kafka",
+		"broker_address_rewrites": []interface{}{
+			map[string]interface{}{"id": 1, "host": "kafka-1.mosn.local", "port": 19093},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, &v2.KafkaBrokerAddressRewrite{ID: 1, Host: "kafka-1.mosn.local", Port: 19093}, p.BrokerAddressRewrites[0])
+	_, err = ParseKafkaProxy(map[string]interface{}{})
+	require.Error(t, err)
+	_, err = ParseKafkaProxy(map[string]interface{}{
+		"cluster":                 "kafka",
+		"broker_address_rewrites": []interface{}{map[string]interface{}{"id": 1}},
+	})
+	require.Error(t, err)
+	_, err = ParseKafkaProxy(map[string]interface{}{
+		"cluster": "kafka",
+		"broker_address_rewrites": []interface{}{
+			map[string]interface{}{"id": 1, "port": 19093},
+			map[string]interface{}{"id": 1, "port": 19094},
+		},
+	})
+	require.Error(t, err)
+}
@@ -0,0 +1,118 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafkaproxy
+
+// TopicData is the data of a topic in the Produce or Fetch request
+type TopicData struct {
+	Topic string
+	// Bytes is the size of the record batches in the Produce request
+	Bytes int
+}
+
+// ParseProduceRequest parses the acks and the topics of the Produce request body
+func ParseProduceRequest(body []byte, version int16) (int16, []TopicData, error) {
+	flexible := isFlexible(apiProduce, version)
+	r := &reader{b: body}
+	if version >= 3 {
+		// transactional id
+		r.string(flexible)
+	}
+	acks := r.int16()
+	r.int32()
+	var topics []TopicData
+	for n := r.array(flexible); n > 0 && r.err == nil; n-- {
+		t := TopicData{Topic: r.string(flexible)}
+		for m := r.array(flexible); m > 0 && r.err == nil; m-- {
+			r.int32()
+			t.Bytes += r.bytes(flexible)
+			r.taggedFields(flexible)
+		}
+		r.taggedFields(flexible)
+		topics = append(topics, t)
+	}
+	if r.err != nil {
+		return 0, nil, r.err
+	}
+	return acks, topics, nil
+}
+
+// ParseFetchRequest parses the topics of the Fetch request body, the topics referred by the topic ids
+// since version 13 are not returned
+func ParseFetchRequest(body []byte, version int16) ([]TopicData, error) {
+	flexible := isFlexible(apiFetch, version)
+	r := &reader{b: body}
+	if version < 15 {
+		// replica id
+		r.int32()
+	}
+	// max wait ms and min bytes
+	r.int32()
+	r.int32()
+	if version >= 3 {
+		// max bytes
+		r.int32()
+	}
+	if version >= 4 {
+		// isolation level
+		r.int8()
+	}
+	if version >= 7 {
+		// session id and session epoch
+		r.int32()
+		r.int32()
+	}
+	var topics []TopicData
+	for n := r.array(flexible); n > 0 && r.err == nil; n-- {
+		var topic string
+		if version >= 13 {
+			// topic id
+			r.next(16)
+		} else {
+			topic = r.string(flexible)
+		}
+		for m := r.array(flexible); m > 0 && r.err == nil; m-- {
+			// partition
+			r.int32()
+			if version >= 9 {
+				// current leader epoch
+				r.int32()
+			}
+			// fetch offset
+			r.int64()
+			if version >= 12 {
+				// last fetched epoch
+				r.int32()
+			}
+			if version >= 5 {
+				// log start offset
+				r.int64()
+			}
+			// partition max bytes
+			r.int32()
+			r.taggedFields(flexible)
+		}
+		r.taggedFields(flexible)
+		if topic != "" {
+			topics = append(topics, TopicData{Topic: topic})
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return topics, nil
+}
@@ -0,0 +1,159 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafkaproxy
+
+import (
+	"encoding/binary"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
+
+// rewriter rewrites the broker addresses in the Metadata and FindCoordinator responses
+type rewriter struct {
+	rules map[int32]*v2.KafkaBrokerAddressRewrite
+}
+
+func newRewriter(config *v2.KafkaProxy) *rewriter {
+	if len(config.BrokerAddressRewrites) == 0 {
+		return nil
+	}
+	r := &rewriter{rules: map[int32]*v2.KafkaBrokerAddressRewrite{}}
+	for _, rule := range config.BrokerAddressRewrites {
+		r.rules[rule.ID] = rule
+	}
+	return r
+}
+
+// needRewrite returns true if the response of the api key should be rewritten
+func (rw *rewriter) needRewrite(key int16) bool {
+	return rw != nil && (key == apiMetadata || key == apiFindCoordinator)
+}
+
+// splice is a broker address in the response
+type splice struct {
+	nodeID int32
+	// start and end are the offsets of the host and the port
+	start, end int
+}
+
+// readBroker reads the node id, the host and the port, and saves the offsets of the host and the port
+func readBroker(r *reader, flexible bool, splices []splice) []splice {
+	nodeID := r.int32()
+	start := r.off
+	r.string(flexible)
+	r.int32()
+	if r.err != nil {
+		return splices
+	}
+	return append(splices, splice{nodeID: nodeID, start: start, end: r.off})
+}
+
+// Rewrite rewrites the broker addresses of the response message without the size, it returns the
+// rewritten message and the number of the rewritten addresses.
+func (rw *rewriter) Rewrite(key, version int16, msg []byte) ([]byte, int, error) {
+	r := &reader{b: msg}
+	// correlation id
+	r.int32()
+	r.taggedFields(responseHeaderFlexible(key, version))
+	flexible := isFlexible(key, version)
+	var splices []splice
+	switch key {
+	case apiMetadata:
+		if version >= 3 {
+			// throttle time ms
+			r.int32()
+		}
+		for n := r.array(flexible); n > 0 && r.err == nil; n-- {
+			splices = readBroker(r, flexible, splices)
+			if version >= 1 {
+				// rack
+				r.string(flexible)
+			}
+			r.taggedFields(flexible)
+		}
+	case apiFindCoordinator:
+		if version >= 1 {
+			// throttle time ms
+			r.int32()
+		}
+		if version >= 4 {
+			for n := r.array(flexible); n > 0 && r.err == nil; n-- {
+				// key
+				r.string(flexible)
+				splices = readBroker(r, flexible, splices)
+				// error code and error message
+				r.int16()
+				r.string(flexible)
+				r.taggedFields(flexible)
+			}
+			break
+		}
+		// error code
+		r.int16()
+		if version >= 1 {
+			// error message
+			r.string(flexible)
+		}
+		splices = readBroker(r, flexible, splices)
+	}
+	if r.err != nil {
+		return nil, 0, r.err
+	}
+
+	var out []byte
+	last, count := 0, 0
+	for _, s := range splices {
+		rule, ok := rw.rules[s.nodeID]
+		if !ok {
+			continue
+		}
+		if out == nil {
+			out = make([]byte, 0, len(msg)+64)
+		}
+		out = append(out, msg[last:s.start]...)
+		host := rule.Host
+		port := rule.Port
+		if host == "" || port == 0 {
+			// keep the original host or port
+			orig := &reader{b: msg[s.start:s.end]}
+			origHost := orig.string(flexible)
+			origPort := orig.int32()
+			if host == "" {
+				host = origHost
+			}
+			if port == 0 {
+				port = origPort
+			}
+		}
+		out = appendString(out, host, flexible)
+		out = appendInt32(out, port)
+		last = s.end
+		count++
+	}
+	if count == 0 {
+		return msg, 0, nil
+	}
+	return append(out, msg[last:]...), count, nil
+}
+
+// appendMessage appends the message with the size
+func appendMessage(out []byte, msg []byte) []byte {
+	var size [sizeLength]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(msg)))
+	return append(append(out, size[:]...), msg...)
+}
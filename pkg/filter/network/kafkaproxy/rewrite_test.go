@@ -0,0 +1,119 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafkaproxy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
+
+type testBroker struct {
+	id   int32
+	host string
+	port int32
+}
+
+// metadataResponse encodes the Metadata response message without the size
+func metadataResponse(version int16, correlationID int32, brokers ...testBroker) []byte {
+	flexible := isFlexible(apiMetadata, version)
+	w := (&writer{}).int32(correlationID).tags(responseHeaderFlexible(apiMetadata, version))
+	if version >= 3 {
+		w.int32(0)
+	}
+	w.array(len(brokers), flexible)
+	for _, b := range brokers {
+		w.int32(b.id).string(b.host, flexible).int32(b.port)
+		if version >= 1 {
+			w.nullString(flexible)
+		}
+		w.tags(flexible)
+	}
+	if version >= 2 {
+		w.string("cluster", flexible)
+	}
+	if version >= 1 {
+		w.int32(brokers[0].id)
+	}
+	// no topics
+	return w.array(0, flexible).tags(flexible).b
+}
+
+// findCoordinatorResponse encodes the FindCoordinator response message without the size
+func findCoordinatorResponse(version int16, correlationID int32, b testBroker) []byte {
+	flexible := isFlexible(apiFindCoordinator, version)
+	w := (&writer{}).int32(correlationID).tags(responseHeaderFlexible(apiFindCoordinator, version))
+	if version >= 1 {
+		w.int32(0)
+	}
+	if version >= 4 {
+		w.array(1, flexible).string("group", flexible).int32(b.id).string(b.host, flexible).int32(b.port).int16(0).nullString(flexible).tags(flexible)
+		return w.tags(flexible).b
+	}
+	w.int16(0)
+	if version >= 1 {
+		w.nullString(flexible)
+	}
+	return w.int32(b.id).string(b.host, flexible).int32(b.port).tags(flexible).b
+}
+
+func TestRewrite(t *testing.T) {
+	rw := newRewriter(&v2.KafkaProxy{BrokerAddressRewrites: []*v2.KafkaBrokerAddressRewrite{
+		{ID: 0, Host: "mosn-0.local", Port: 19092},
+		{ID: 1, Port: 19093},
+		{ID: 2, Host: "mosn-2.local"},
+	}})
+	require.True(t, rw.needRewrite(apiMetadata))
+	require.False(t, rw.needRewrite(apiProduce))
+	require.False(t, (*rewriter)(nil).needRewrite(apiMetadata))
+	require.Nil(t, newRewriter(&v2.KafkaProxy{}))
+
+	brokers := []testBroker{
+		{0, "kafka-0", 9092},
+		{1, "kafka-1", 9092},
+		{2, "kafka-2", 9092},
+		{3, "kafka-3", 9092},
+	}
+	rewritten := []testBroker{
+		{0, "mosn-0.local", 19092},
+		{1, "kafka-1", 19093},
+		{2, "mosn-2.local", 9092},
+		{3, "kafka-3", 9092},
+	}
+	for _, version := range []int16{0, 1, 3, 8, 9, 12} {
+		msg, count, err := rw.Rewrite(apiMetadata, version, metadataResponse(version, 7, brokers...))
+		require.NoError(t, err, "version %d", version)
+		require.Equal(t, 3, count)
+		require.Equal(t, metadataResponse(version, 7, rewritten...), msg, "version %d", version)
+	}
+	for _, version := range []int16{0, 1, 3, 4} {
+		for i, b := range brokers {
+			msg, count, err := rw.Rewrite(apiFindCoordinator, version, findCoordinatorResponse(version, 7, b))
+			require.NoError(t, err, "version %d", version)
+			require.Equal(t, findCoordinatorResponse(version, 7, rewritten[i]), msg, "version %d", version)
+			if b.id == 3 {
+				require.Equal(t, 0, count)
+			} else {
+				require.Equal(t, 1, count)
+			}
+		}
+	}
+	_, _, err := rw.Rewrite(apiMetadata, 9, metadataResponse(9, 7, brokers...)[:10])
+	require.Error(t, err)
+}
@@ -0,0 +1,92 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafkaproxy
+
+import (
+	"sync"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"mosn.io/mosn/pkg/metrics"
+)
+
+type proxyStats struct {
+	RequestDecodeError  gometrics.Counter
+	ResponseDecodeError gometrics.Counter
+	BrokerRewrite       gometrics.Counter
+}
+
+type apiStats struct {
+	RequestTotal  gometrics.Counter
+	RequestBytes  gometrics.Counter
+	ResponseTotal gometrics.Counter
+	ResponseBytes gometrics.Counter
+	RequestTime   gometrics.Histogram
+}
+
+type topicStats struct {
+	RequestTotal gometrics.Counter
+	RequestBytes gometrics.Counter
+}
+
+var (
+	proxyStatsCache sync.Map
+	apiStatsCache   sync.Map
+	topicStatsCache sync.Map
+)
+
+func getProxyStats(statPrefix string) *proxyStats {
+	if v, ok := proxyStatsCache.Load(statPrefix); ok {
+		return v.(*proxyStats)
+	}
+	s := metrics.NewKafkaStats(statPrefix)
+	v, _ := proxyStatsCache.LoadOrStore(statPrefix, &proxyStats{
+		RequestDecodeError:  s.Counter(metrics.KafkaRequestDecodeError),
+		ResponseDecodeError: s.Counter(metrics.KafkaResponseDecodeError),
+		BrokerRewrite:       s.Counter(metrics.KafkaBrokerRewrite),
+	})
+	return v.(*proxyStats)
+}
+
+func getAPIStats(statPrefix, api string) *apiStats {
+	key := statPrefix + "|" + api
+	if v, ok := apiStatsCache.Load(key); ok {
+		return v.(*apiStats)
+	}
+	s := metrics.NewKafkaAPIStats(statPrefix, api)
+	v, _ := apiStatsCache.LoadOrStore(key, &apiStats{
+		RequestTotal:  s.Counter(metrics.KafkaRequestTotal),
+		RequestBytes:  s.Counter(metrics.KafkaRequestBytes),
+		ResponseTotal: s.Counter(metrics.KafkaResponseTotal),
+		ResponseBytes: s.Counter(metrics.KafkaResponseBytes),
+		RequestTime:   s.Histogram(metrics.KafkaRequestTime),
+	})
+	return v.(*apiStats)
+}
+
+func getTopicStats(statPrefix, api, topic string) *topicStats {
+	key := statPrefix + "|" + api + "|" + topic
+	if v, ok := topicStatsCache.Load(key); ok {
+		return v.(*topicStats)
+	}
+	s := metrics.NewKafkaTopicStats(statPrefix, api, topic)
+	v, _ := topicStatsCache.LoadOrStore(key, &topicStats{
+		RequestTotal: s.Counter(metrics.KafkaRequestTotal),
+		RequestBytes: s.Counter(metrics.KafkaRequestBytes),
+	})
+	return v.(*topicStats)
+}
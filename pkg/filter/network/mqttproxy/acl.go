@@ -0,0 +1,280 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqttproxy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
+
+// the actions of the topic ACLs
+const (
+	actionPublish   = "publish"
+	actionSubscribe = "subscribe"
+)
+
+// compileMatcher compiles the regular expression which matches the whole value
+func compileMatcher(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + expr + ")$")
+}
+
+// connMatcher matches the connections by the client id and the username
+type connMatcher struct {
+	clientID *regexp.Regexp
+	username *regexp.Regexp
+}
+
+func newConnMatcher(clientID, username string) (connMatcher, error) {
+	var (
+		m   connMatcher
+		err error
+	)
+	if m.clientID, err = compileMatcher(clientID); err != nil {
+		return m, fmt.Errorf("invalid client id %s: %v", clientID, err)
+	}
+	if m.username, err = compileMatcher(username); err != nil {
+		return m, fmt.Errorf("invalid username %s: %v", username, err)
+	}
+	return m, nil
+}
+
+func (m connMatcher) match(c *Connect) bool {
+	if m.clientID != nil && !m.clientID.MatchString(c.ClientID) {
+		return false
+	}
+	if m.username != nil && (!c.HasUsername || !m.username.MatchString(c.Username)) {
+		return false
+	}
+	return true
+}
+
+type aclRule struct {
+	connMatcher
+	publish   bool
+	subscribe bool
+	topics    []string
+	deny      bool
+}
+
+// acl checks the topics published and subscribed by the connections
+type acl struct {
+	rules         []*aclRule
+	denyByDefault bool
+}
+
+func newACL(config *v2.MqttProxy) (*acl, error) {
+	a := &acl{denyByDefault: config.DenyByDefault}
+	for _, c := range config.ACLs {
+		m, err := newConnMatcher(c.ClientID, c.Username)
+		if err != nil {
+			return nil, err
+		}
+		rule := &aclRule{
+			connMatcher: m,
+			topics:      c.Topics,
+			deny:        c.Deny,
+		}
+		switch c.Action {
+		case "":
+			rule.publish, rule.subscribe = true, true
+		case actionPublish:
+			rule.publish = true
+		case actionSubscribe:
+			rule.subscribe = true
+		default:
+			return nil, fmt.Errorf("unknown acl action %s", c.Action)
+		}
+		for _, topic := range c.Topics {
+			if !validFilter(topic) {
+				return nil, fmt.Errorf("invalid acl topic %s", topic)
+			}
+		}
+		a.rules = append(a.rules, rule)
+	}
+	return a, nil
+}
+
+// expand replaces %c and %u with the client id and the username, it returns false if
+// the client id or the username can not be a topic level.
+func expand(filter string, c *Connect) (string, bool) {
+	if !strings.Contains(filter, "%") {
+		return filter, true
+	}
+	if strings.Contains(filter, "%c") && !validLevel(c.ClientID) {
+		return "", false
+	}
+	if strings.Contains(filter, "%u") && (!c.HasUsername || !validLevel(c.Username)) {
+		return "", false
+	}
+	return strings.NewReplacer("%c", c.ClientID, "%u", c.Username).Replace(filter), true
+}
+
+// validLevel checks the value can be a topic level without wildcards
+func validLevel(v string) bool {
+	return v != "" && !strings.ContainsAny(v, "/+#")
+}
+
+// canPublish checks the connection is allowed to publish the topic
+func (a *acl) canPublish(c *Connect, topic string) bool {
+	for _, rule := range a.rules {
+		if !rule.publish || !rule.match(c) {
+			continue
+		}
+		for _, filter := range rule.topics {
+			if f, ok := expand(filter, c); ok && matchTopic(f, topic) {
+				return !rule.deny
+			}
+		}
+	}
+	return !a.denyByDefault
+}
+
+// canSubscribe checks the connection is allowed to subscribe the topic filter, the filter is
+// allowed if it is covered by an allow rule, and it is denied if it overlaps a deny rule.
+func (a *acl) canSubscribe(c *Connect, filter string) bool {
+	filter = stripShare(filter)
+	if !validFilter(filter) {
+		return false
+	}
+	for _, rule := range a.rules {
+		if !rule.subscribe || !rule.match(c) {
+			continue
+		}
+		for _, f := range rule.topics {
+			f, ok := expand(f, c)
+			if !ok {
+				continue
+			}
+			if rule.deny && overlapFilters(f, filter) {
+				return false
+			}
+			if !rule.deny && coverFilter(f, filter) {
+				return true
+			}
+		}
+	}
+	return !a.denyByDefault
+}
+
+// stripShare returns the topic filter of the shared subscription $share/{group}/{filter}
+func stripShare(filter string) string {
+	if strings.HasPrefix(filter, "$share/") {
+		parts := strings.SplitN(filter, "/", 3)
+		if len(parts) == 3 {
+			return parts[2]
+		}
+	}
+	return filter
+}
+
+// validFilter checks the wildcards of the topic filter
+func validFilter(filter string) bool {
+	if filter == "" {
+		return false
+	}
+	levels := strings.Split(filter, "/")
+	for i, level := range levels {
+		if strings.Contains(level, "#") && (level != "#" || i != len(levels)-1) {
+			return false
+		}
+		if strings.Contains(level, "+") && level != "+" {
+			return false
+		}
+	}
+	return true
+}
+
+// matchTopic checks the topic name matches the topic filter, the topics starting
+// with $ are not matched by the wildcards at the first level.
+func matchTopic(filter, topic string) bool {
+	if strings.HasPrefix(topic, "$") && startsWithWildcard(filter) {
+		return false
+	}
+	f := strings.Split(filter, "/")
+	t := strings.Split(topic, "/")
+	for i, level := range f {
+		if level == "#" {
+			return true
+		}
+		if i >= len(t) {
+			return false
+		}
+		if level != "+" && level != t[i] {
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
+
+// coverFilter checks all the topics matched by the filter are matched by the ACL filter
+func coverFilter(aclFilter, filter string) bool {
+	if strings.HasPrefix(filter, "$") && startsWithWildcard(aclFilter) {
+		return false
+	}
+	a := strings.Split(aclFilter, "/")
+	f := strings.Split(filter, "/")
+	for i, level := range a {
+		if level == "#" {
+			return true
+		}
+		if i >= len(f) || f[i] == "#" {
+			return false
+		}
+		if level != "+" && level != f[i] {
+			return false
+		}
+	}
+	return len(a) == len(f)
+}
+
+// startsWithWildcard checks the first level of the filter is a wildcard
+func startsWithWildcard(filter string) bool {
+	return strings.HasPrefix(filter, "+") || strings.HasPrefix(filter, "#")
+}
+
+// overlapFilters checks some topics are matched by both the filters
+func overlapFilters(a, b string) bool {
+	// the topics starting with $ are not matched by the wildcards at the first level
+	if strings.HasPrefix(a, "$") && startsWithWildcard(b) || strings.HasPrefix(b, "$") && startsWithWildcard(a) {
+		return false
+	}
+	x := strings.Split(a, "/")
+	y := strings.Split(b, "/")
+	for i := 0; i < len(x) && i < len(y); i++ {
+		if x[i] == "#" || y[i] == "#" {
+			return true
+		}
+		if x[i] != "+" && y[i] != "+" && x[i] != y[i] {
+			return false
+		}
+	}
+	if len(x) == len(y) {
+		return true
+	}
+	// the # also matches the parent level, such as a/# and a
+	if len(x) > len(y) {
+		return len(x) == len(y)+1 && x[len(y)] == "#"
+	}
+	return len(y) == len(x)+1 && y[len(x)] == "#"
+}
@@ -0,0 +1,148 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqttproxy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
+
+func TestMatchTopic(t *testing.T) {
+	cases := []struct {
+		filter, topic string
+		match         bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/b", "a/c", false},
+		{"a/+", "a/b", true},
+		{"a/+", "a/b/c", false},
+		{"a/#", "a", true},
+		{"a/#", "a/b/c", true},
+		{"+/+", "/b", true},
+		{"#", "a/b", true},
+		{"#", "$SYS/a", false},
+		{"+/a", "$SYS/a", false},
+		{"$SYS/#", "$SYS/a", true},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.match, matchTopic(c.filter, c.topic), "%s %s", c.filter, c.topic)
+	}
+}
+
+func TestCoverAndOverlapFilters(t *testing.T) {
+	cases := []struct {
+		acl, filter    string
+		cover, overlap bool
+	}{
+		{"a/#", "a/b/+", true, true},
+		{"a/+", "a/b", true, true},
+		{"a/+", "a/#", false, true},
+		{"a/b", "a/+", false, true},
+		{"a/b", "a/c", false, false},
+		{"#", "$SYS/a", false, false},
+		{"#", "+/a", true, true},
+		{"$SYS/#", "#", false, false},
+		{"a/+/c", "a/b/+", false, true},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.cover, coverFilter(c.acl, c.filter), "cover %s %s", c.acl, c.filter)
+		require.Equal(t, c.overlap, overlapFilters(c.acl, c.filter), "overlap %s %s", c.acl, c.filter)
+	}
+}
+
+func TestValidFilter(t *testing.T) {
+	for _, f := range []string{"a", "a/+/b", "#", "a/#", "+", "/"} {
+		require.True(t, validFilter(f), f)
+	}
+	for _, f := range []string{"", "a/#/b", "a+", "a/b#"} {
+		require.False(t, validFilter(f), f)
+	}
+	require.Equal(t, "a/b", stripShare("$share/group/a/b"))
+	require.Equal(t, "a/b", stripShare("a/b"))
+}
+
+func TestExpand(t *testing.T) {
+	c := &Connect{ClientID: "c1", Username: "tom", HasUsername: true}
+	topic, ok := expand("devices/%c/%u/#", c)
+	require.True(t, ok)
+	require.Equal(t, "devices/c1/tom/#", topic)
+
+	_, ok = expand("devices/%c", &Connect{ClientID: "c/+"})
+	require.False(t, ok)
+	_, ok = expand("users/%u", &Connect{ClientID: "c1"})
+	require.False(t, ok)
+}
+
+func TestACL(t *testing.T) {
+	a, err := newACL(&v2.MqttProxy{
+		DenyByDefault: true,
+		ACLs: []*v2.MqttTopicACL{
+			{Username: "admin", Topics: []string{"#"}},
+			{Action: "publish", Topics: []string{"devices/%c/#"}},
+			{Action: "subscribe", Topics: []string{"devices/+/secret"}, Deny: true},
+			{Action: "subscribe", Topics: []string{"devices/#"}},
+		},
+	})
+	require.NoError(t, err)
+
+	admin := &Connect{ClientID: "admin1", Username: "admin", HasUsername: true}
+	device := &Connect{ClientID: "d1"}
+	require.True(t, a.canPublish(admin, "any/topic"))
+	require.True(t, a.canPublish(device, "devices/d1/status"))
+	require.False(t, a.canPublish(device, "devices/d2/status"))
+	require.True(t, a.canSubscribe(admin, "devices/+/secret"))
+	require.True(t, a.canSubscribe(device, "devices/d2/status"))
+	require.True(t, a.canSubscribe(device, "$share/g/devices/+/status"))
+	require.False(t, a.canSubscribe(device, "devices/d2/secret"))
+	require.False(t, a.canSubscribe(device, "devices/#"))
+	require.False(t, a.canSubscribe(device, "others/#"))
+	require.False(t, a.canSubscribe(device, "devices/#/a"))
+
+	a, err = newACL(&v2.MqttProxy{})
+	require.NoError(t, err)
+	require.True(t, a.canPublish(device, "a"))
+	require.True(t, a.canSubscribe(device, "#"))
+
+	_, err = newACL(&v2.MqttProxy{ACLs: []*v2.MqttTopicACL{{Action: "connect"}}})
+	require.Error(t, err)
+	_, err = newACL(&v2.MqttProxy{ACLs: []*v2.MqttTopicACL{{Topics: []string{"a/#/b"}}}})
+	require.Error(t, err)
+	_, err = newACL(&v2.MqttProxy{ACLs: []*v2.MqttTopicACL{{ClientID: "("}}})
+	require.Error(t, err)
+}
+
+func TestRouter(t *testing.T) {
+	r, err := newRouter(&v2.MqttProxy{
+		Cluster: "default",
+		Routes: []*v2.MqttRoute{
+			{ClientID: "sensor-.*", Cluster: "sensors"},
+			{Username: "admin|ops", Cluster: "internal"},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "sensors", r.route(&Connect{ClientID: "sensor-1"}))
+	require.Equal(t, "default", r.route(&Connect{ClientID: "my-sensor-1"}))
+	require.Equal(t, "internal", r.route(&Connect{ClientID: "c1", Username: "ops", HasUsername: true}))
+	require.Equal(t, "default", r.route(&Connect{ClientID: "c1", Username: "opsx", HasUsername: true}))
+	require.Equal(t, "default", r.route(&Connect{ClientID: "c1"}))
+
+	_, err = newRouter(&v2.MqttProxy{Routes: []*v2.MqttRoute{{Username: "[", Cluster: "a"}}})
+	require.Error(t, err)
+}
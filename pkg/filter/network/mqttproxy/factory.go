@@ -0,0 +1,97 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqttproxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/filter/network/internal/upstream"
+)
+
+func init() {
+	api.RegisterNetwork(v2.MQTT_PROXY, CreateMqttProxyFactory)
+	upstream.Register(upstreamProtocol)
+}
+
+var upstreamProtocol = api.ProtocolName(v2.MQTT_PROXY)
+
+type mqttProxyFilterConfigFactory struct {
+	Proxy  *v2.MqttProxy
+	router *router
+	acl    *acl
+}
+
+func (f *mqttProxyFilterConfigFactory) CreateFilterChain(context context.Context, callbacks api.NetWorkFilterChainFactoryCallbacks) {
+	rf := newProxy(context, f.Proxy, f.router, f.acl)
+	callbacks.AddReadFilter(rf)
+}
+
+func CreateMqttProxyFactory(conf map[string]interface{}) (api.NetworkFilterChainFactory, error) {
+	p, err := ParseMqttProxy(conf)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newRouter(p)
+	if err != nil {
+		return nil, fmt.Errorf("[config] invalid mqtt proxy route: %v", err)
+	}
+	a, err := newACL(p)
+	if err != nil {
+		return nil, fmt.Errorf("[config] invalid mqtt proxy acl: %v", err)
+	}
+	return &mqttProxyFilterConfigFactory{
+		Proxy:  p,
+		router: r,
+		acl:    a,
+	}, nil
+}
+
+// ParseMqttProxy parses the mqtt proxy config
+func ParseMqttProxy(cfg map[string]interface{}) (*v2.MqttProxy, error) {
+	proxy := &v2.MqttProxy{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("[config] config is not a mqtt proxy config: %v", err)
+	}
+	if err := json.Unmarshal(data, proxy); err != nil {
+		return nil, fmt.Errorf("[config] config is not a mqtt proxy config: %v", err)
+	}
+	if proxy.Cluster == "" && len(proxy.Routes) == 0 {
+		return nil, errors.New("[config] mqtt proxy has no cluster or route")
+	}
+	for _, r := range proxy.Routes {
+		if r.Cluster == "" {
+			return nil, errors.New("[config] mqtt proxy route has no cluster")
+		}
+	}
+	if proxy.MaxPacketSize <= 0 || proxy.MaxPacketSize > MaxPacketSize {
+		proxy.MaxPacketSize = MaxPacketSize
+	}
+	if proxy.TopicStatsDepth == 0 {
+		proxy.TopicStatsDepth = defaultTopicStatsDepth
+	}
+	if proxy.MaxTopicStats <= 0 {
+		proxy.MaxTopicStats = defaultMaxTopicStats
+	}
+	return proxy, nil
+}
@@ -0,0 +1,504 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqttproxy
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// packet types
+const (
+	CONNECT     byte = 1
+	CONNACK     byte = 2
+	PUBLISH     byte = 3
+	PUBACK      byte = 4
+	PUBREC      byte = 5
+	PUBREL      byte = 6
+	PUBCOMP     byte = 7
+	SUBSCRIBE   byte = 8
+	SUBACK      byte = 9
+	UNSUBSCRIBE byte = 10
+	UNSUBACK    byte = 11
+	PINGREQ     byte = 12
+	PINGRESP    byte = 13
+	DISCONNECT  byte = 14
+	AUTH        byte = 15
+)
+
+// protocol levels
+const (
+	Version31  byte = 3
+	Version311 byte = 4
+	Version5   byte = 5
+)
+
+// MaxPacketSize is the max size of the remaining length allowed by the protocol
+const MaxPacketSize = 268435455
+
+// reason codes
+const (
+	// connack return codes of MQTT 3.1.1
+	connackServerUnavailable311 byte = 0x03
+	// suback return code of the failure in MQTT 3.1.1
+	subackFailure311 byte = 0x80
+
+	reasonNotAuthorized     byte = 0x87
+	reasonServerUnavailable byte = 0x88
+	reasonPacketTooLarge    byte = 0x95
+	reasonProtocolError     byte = 0x82
+)
+
+// propTopicAlias is the topic alias property of MQTT 5.0
+const propTopicAlias byte = 0x23
+
+var (
+	errIncomplete = errors.New("incomplete packet")
+	errMalformed  = errors.New("malformed packet")
+)
+
+// Packet is a MQTT control packet
+type Packet struct {
+	Type  byte
+	Flags byte
+	// Body is the variable header and the payload
+	Body []byte
+	// Raw is the whole packet
+	Raw []byte
+}
+
+// readVarint reads a variable byte integer, which is at most 4 bytes
+func readVarint(b []byte) (int, int, error) {
+	v, multiplier := 0, 1
+	for i := 0; i < 4; i++ {
+		if i >= len(b) {
+			return 0, 0, errIncomplete
+		}
+		v += int(b[i]&0x7f) * multiplier
+		if b[i]&0x80 == 0 {
+			return v, i + 1, nil
+		}
+		multiplier *= 128
+	}
+	return 0, 0, errMalformed
+}
+
+func appendVarint(out []byte, v int) []byte {
+	for {
+		b := byte(v % 128)
+		v /= 128
+		if v > 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if v == 0 {
+			return out
+		}
+	}
+}
+
+// DecodePacket decodes a packet, and returns the packet and the bytes consumed
+func DecodePacket(data []byte, maxSize int) (*Packet, int, error) {
+	if len(data) < 2 {
+		return nil, 0, errIncomplete
+	}
+	length, n, err := readVarint(data[1:])
+	if err == errIncomplete && len(data) >= 5 {
+		err = errMalformed
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if maxSize > 0 && length > maxSize {
+		return nil, 0, fmt.Errorf("packet size %d exceeds the limit %d", length, maxSize)
+	}
+	total := 1 + n + length
+	if len(data) < total {
+		return nil, 0, errIncomplete
+	}
+	return &Packet{
+		Type:  data[0] >> 4,
+		Flags: data[0] & 0x0f,
+		Body:  data[1+n : total],
+		Raw:   data[:total],
+	}, total, nil
+}
+
+// EncodePacket appends the packet with the fixed header to out
+func EncodePacket(out []byte, typ, flags byte, body []byte) []byte {
+	out = append(out, typ<<4|flags&0x0f)
+	out = appendVarint(out, len(body))
+	return append(out, body...)
+}
+
+// reader reads the fields of the variable header and the payload
+type reader struct {
+	b   []byte
+	err error
+}
+
+func (r *reader) byte() byte {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.b) < 1 {
+		r.err = errMalformed
+		return 0
+	}
+	v := r.b[0]
+	r.b = r.b[1:]
+	return v
+}
+
+func (r *reader) uint16() uint16 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.b) < 2 {
+		r.err = errMalformed
+		return 0
+	}
+	v := binary.BigEndian.Uint16(r.b)
+	r.b = r.b[2:]
+	return v
+}
+
+func (r *reader) bytes() []byte {
+	n := int(r.uint16())
+	if r.err != nil {
+		return nil
+	}
+	if len(r.b) < n {
+		r.err = errMalformed
+		return nil
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *reader) string() string {
+	return string(r.bytes())
+}
+
+// properties reads the properties of MQTT 5.0
+func (r *reader) properties() []byte {
+	if r.err != nil {
+		return nil
+	}
+	n, size, err := readVarint(r.b)
+	if err != nil || len(r.b) < size+n {
+		r.err = errMalformed
+		return nil
+	}
+	v := r.b[size : size+n]
+	r.b = r.b[size+n:]
+	return v
+}
+
+func appendString(out []byte, s string) []byte {
+	out = append(out, byte(len(s)>>8), byte(len(s)))
+	return append(out, s...)
+}
+
+// propertySize returns the size of the property value by the property identifier
+func propertySize(id byte, b []byte) (int, error) {
+	switch id {
+	case 0x01, 0x17, 0x19, 0x24, 0x25, 0x28, 0x29, 0x2a:
+		return 1, nil
+	case 0x13, 0x21, 0x22, 0x23:
+		return 2, nil
+	case 0x02, 0x11, 0x18, 0x27:
+		return 4, nil
+	case 0x0b:
+		_, n, err := readVarint(b)
+		if err != nil {
+			return 0, errMalformed
+		}
+		return n, nil
+	case 0x03, 0x08, 0x09, 0x12, 0x15, 0x16, 0x1a, 0x1c, 0x1f:
+		if len(b) < 2 {
+			return 0, errMalformed
+		}
+		return 2 + int(binary.BigEndian.Uint16(b)), nil
+	case 0x26:
+		if len(b) < 2 {
+			return 0, errMalformed
+		}
+		n := 2 + int(binary.BigEndian.Uint16(b))
+		if len(b) < n+2 {
+			return 0, errMalformed
+		}
+		return n + 2 + int(binary.BigEndian.Uint16(b[n:])), nil
+	default:
+		return 0, fmt.Errorf("unknown property 0x%02x", id)
+	}
+}
+
+// findProperty returns the value of the property in the properties
+func findProperty(props []byte, id byte) ([]byte, bool, error) {
+	for len(props) > 0 {
+		pid := props[0]
+		props = props[1:]
+		n, err := propertySize(pid, props)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(props) < n {
+			return nil, false, errMalformed
+		}
+		if pid == id {
+			return props[:n], true, nil
+		}
+		props = props[n:]
+	}
+	return nil, false, nil
+}
+
+// Connect is the CONNECT packet
+type Connect struct {
+	ProtocolName string
+	Version      byte
+	Flags        byte
+	KeepAlive    uint16
+	ClientID     string
+	WillTopic    string
+	Username     string
+	HasUsername  bool
+}
+
+// ParseConnect parses the CONNECT packet
+func ParseConnect(body []byte) (*Connect, error) {
+	r := &reader{b: body}
+	c := &Connect{
+		ProtocolName: r.string(),
+		Version:      r.byte(),
+		Flags:        r.byte(),
+		KeepAlive:    r.uint16(),
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	switch {
+	case c.ProtocolName == "MQTT" && (c.Version == Version311 || c.Version == Version5):
+	case c.ProtocolName == "MQIsdp" && c.Version == Version31:
+	default:
+		return nil, fmt.Errorf("unsupported protocol %s level %d", c.ProtocolName, c.Version)
+	}
+	if c.Flags&0x01 != 0 {
+		return nil, errors.New("reserved connect flag is set")
+	}
+	if c.Version == Version5 {
+		r.properties()
+	}
+	c.ClientID = r.string()
+	if c.Flags&0x04 != 0 {
+		if c.Version == Version5 {
+			r.properties()
+		}
+		c.WillTopic = r.string()
+		r.bytes()
+	}
+	if c.Flags&0x80 != 0 {
+		c.HasUsername = true
+		c.Username = r.string()
+	}
+	if c.Flags&0x40 != 0 {
+		r.bytes()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return c, nil
+}
+
+// Publish is the PUBLISH packet
+type Publish struct {
+	Topic    string
+	QoS      byte
+	Retain   bool
+	Dup      bool
+	PacketID uint16
+	// TopicAlias is the topic alias of MQTT 5.0, the topic is empty if it refers to an alias
+	TopicAlias uint16
+	Properties []byte
+	Payload    []byte
+}
+
+// ParsePublish parses the PUBLISH packet
+func ParsePublish(flags byte, body []byte, version byte) (*Publish, error) {
+	p := &Publish{
+		QoS:    flags >> 1 & 0x03,
+		Retain: flags&0x01 != 0,
+		Dup:    flags&0x08 != 0,
+	}
+	if p.QoS > 2 {
+		return nil, errors.New("invalid publish qos 3")
+	}
+	r := &reader{b: body}
+	p.Topic = r.string()
+	if p.QoS > 0 {
+		p.PacketID = r.uint16()
+	}
+	if version == Version5 {
+		p.Properties = r.properties()
+		if r.err == nil {
+			v, ok, err := findProperty(p.Properties, propTopicAlias)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				p.TopicAlias = binary.BigEndian.Uint16(v)
+			}
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	p.Payload = r.b
+	return p, nil
+}
+
+// Encode encodes the PUBLISH packet
+func (p *Publish) Encode(out []byte, version byte) []byte {
+	flags := p.QoS << 1
+	if p.Retain {
+		flags |= 0x01
+	}
+	if p.Dup {
+		flags |= 0x08
+	}
+	body := appendString(make([]byte, 0, len(p.Topic)+len(p.Properties)+len(p.Payload)+8), p.Topic)
+	if p.QoS > 0 {
+		body = append(body, byte(p.PacketID>>8), byte(p.PacketID))
+	}
+	if version == Version5 {
+		body = appendVarint(body, len(p.Properties))
+		body = append(body, p.Properties...)
+	}
+	body = append(body, p.Payload...)
+	return EncodePacket(out, PUBLISH, flags, body)
+}
+
+// Subscription is a topic filter of the SUBSCRIBE packet
+type Subscription struct {
+	Filter  string
+	Options byte
+}
+
+// Subscribe is the SUBSCRIBE packet
+type Subscribe struct {
+	PacketID      uint16
+	Properties    []byte
+	Subscriptions []Subscription
+}
+
+// ParseSubscribe parses the SUBSCRIBE packet
+func ParseSubscribe(body []byte, version byte) (*Subscribe, error) {
+	r := &reader{b: body}
+	s := &Subscribe{PacketID: r.uint16()}
+	if version == Version5 {
+		s.Properties = r.properties()
+	}
+	for r.err == nil && len(r.b) > 0 {
+		s.Subscriptions = append(s.Subscriptions, Subscription{
+			Filter:  r.string(),
+			Options: r.byte(),
+		})
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(s.Subscriptions) == 0 {
+		return nil, errors.New("subscribe without topic filter")
+	}
+	return s, nil
+}
+
+// Encode encodes the SUBSCRIBE packet
+func (s *Subscribe) Encode(out []byte, version byte) []byte {
+	body := []byte{byte(s.PacketID >> 8), byte(s.PacketID)}
+	if version == Version5 {
+		body = appendVarint(body, len(s.Properties))
+		body = append(body, s.Properties...)
+	}
+	for _, sub := range s.Subscriptions {
+		body = appendString(body, sub.Filter)
+		body = append(body, sub.Options)
+	}
+	return EncodePacket(out, SUBSCRIBE, 0x02, body)
+}
+
+// Suback is the SUBACK packet
+type Suback struct {
+	PacketID   uint16
+	Properties []byte
+	Codes      []byte
+}
+
+// ParseSuback parses the SUBACK packet
+func ParseSuback(body []byte, version byte) (*Suback, error) {
+	r := &reader{b: body}
+	s := &Suback{PacketID: r.uint16()}
+	if version == Version5 {
+		s.Properties = r.properties()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	s.Codes = r.b
+	return s, nil
+}
+
+// Encode encodes the SUBACK packet
+func (s *Suback) Encode(out []byte, version byte) []byte {
+	body := []byte{byte(s.PacketID >> 8), byte(s.PacketID)}
+	if version == Version5 {
+		body = appendVarint(body, len(s.Properties))
+		body = append(body, s.Properties...)
+	}
+	body = append(body, s.Codes...)
+	return EncodePacket(out, SUBACK, 0, body)
+}
+
+// subackFailure returns the failure code of the unauthorized subscription
+func subackFailure(version byte) byte {
+	if version == Version5 {
+		return reasonNotAuthorized
+	}
+	return subackFailure311
+}
+
+// encodeConnack encodes the CONNACK packet which refuses the connection
+func encodeConnack(out []byte, version, reason byte) []byte {
+	if version == Version5 {
+		return EncodePacket(out, CONNACK, 0, []byte{0, reason, 0})
+	}
+	return EncodePacket(out, CONNACK, 0, []byte{0, connackServerUnavailable311})
+}
+
+// encodeAck encodes the PUBACK or PUBREC packet with the reason code of MQTT 5.0
+func encodeAck(out []byte, typ byte, packetID uint16, reason byte) []byte {
+	return EncodePacket(out, typ, 0, []byte{byte(packetID >> 8), byte(packetID), reason, 0})
+}
+
+// encodeDisconnect encodes the DISCONNECT packet with the reason code of MQTT 5.0
+func encodeDisconnect(out []byte, reason byte) []byte {
+	return EncodePacket(out, DISCONNECT, 0, []byte{reason, 0})
+}
@@ -0,0 +1,168 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqttproxy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// encodeConnect encodes a CONNECT packet, the username is omitted if it is empty
+func encodeConnect(version byte, clientID, username string) []byte {
+	name := "MQTT"
+	if version == Version31 {
+		name = "MQIsdp"
+	}
+	body := appendString(nil, name)
+	flags := byte(0x02)
+	if username != "" {
+		flags |= 0x80
+	}
+	body = append(body, version, flags, 0, 60)
+	if version == Version5 {
+		body = append(body, 0)
+	}
+	body = appendString(body, clientID)
+	if username != "" {
+		body = appendString(body, username)
+	}
+	return EncodePacket(nil, CONNECT, 0, body)
+}
+
+func TestDecodePacket(t *testing.T) {
+	data := EncodePacket(nil, PINGREQ, 0, nil)
+	payload := make([]byte, 200)
+	data = EncodePacket(data, PUBLISH, 0x02, payload)
+	require.Equal(t, []byte{0x32, 0xc8, 0x01}, data[2:5])
+
+	pkt, n, err := DecodePacket(data, MaxPacketSize)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, byte(PINGREQ), pkt.Type)
+
+	pkt, n, err = DecodePacket(data[2:], MaxPacketSize)
+	require.NoError(t, err)
+	require.Equal(t, 203, n)
+	require.Equal(t, byte(PUBLISH), pkt.Type)
+	require.Equal(t, byte(0x02), pkt.Flags)
+	require.Equal(t, payload, pkt.Body)
+	require.Equal(t, data[2:], pkt.Raw)
+
+	for i := 0; i < len(data)-2; i++ {
+		_, _, err = DecodePacket(data[2:2+i], MaxPacketSize)
+		require.Equal(t, errIncomplete, err)
+	}
+	// the packet is too large
+	_, _, err = DecodePacket(data[2:], 100)
+	require.Error(t, err)
+	// the remaining length has more than 4 bytes
+	_, _, err = DecodePacket([]byte{0x30, 0xff, 0xff, 0xff, 0xff, 0x01}, MaxPacketSize)
+	require.Error(t, err)
+}
+
+func TestParseConnect(t *testing.T) {
+	for _, version := range []byte{Version31, Version311, Version5} {
+		pkt, _, err := DecodePacket(encodeConnect(version, "client1", "tom"), MaxPacketSize)
+		require.NoError(t, err)
+		c, err := ParseConnect(pkt.Body)
+		require.NoError(t, err)
+		require.Equal(t, version, c.Version)
+		require.Equal(t, "client1", c.ClientID)
+		require.Equal(t, "tom", c.Username)
+		require.True(t, c.HasUsername)
+	}
+
+	pkt, _, _ := DecodePacket(encodeConnect(Version311, "client1", ""), MaxPacketSize)
+	c, err := ParseConnect(pkt.Body)
+	require.NoError(t, err)
+	require.False(t, c.HasUsername)
+
+	// unsupported protocol level
+	_, err = ParseConnect(append(appendString(nil, "MQTT"), 6, 0x02, 0, 60, 0, 0))
+	require.Error(t, err)
+	// truncated
+	_, err = ParseConnect(pkt.Body[:len(pkt.Body)-1])
+	require.Error(t, err)
+}
+
+func TestPublishCodec(t *testing.T) {
+	// MQTT 3.1.1
+	pub := &Publish{Topic: "a/b", QoS: 1, Retain: true, PacketID: 10, Payload: []byte("hello")}
+	pkt, _, err := DecodePacket(pub.Encode(nil, Version311), MaxPacketSize)
+	require.NoError(t, err)
+	require.Equal(t, byte(0x03), pkt.Flags)
+	actual, err := ParsePublish(pkt.Flags, pkt.Body, Version311)
+	require.NoError(t, err)
+	require.Equal(t, pub, actual)
+
+	// MQTT 5.0 with the topic alias and the user property
+	props := []byte{propTopicAlias, 0, 3, 0x26}
+	props = appendString(appendString(props, "k"), "v")
+	pub = &Publish{Topic: "a/b", QoS: 0, TopicAlias: 3, Properties: props, Payload: []byte("hello")}
+	pkt, _, err = DecodePacket(pub.Encode(nil, Version5), MaxPacketSize)
+	require.NoError(t, err)
+	actual, err = ParsePublish(pkt.Flags, pkt.Body, Version5)
+	require.NoError(t, err)
+	require.Equal(t, pub, actual)
+
+	// the publish refers to the topic alias
+	pub.Topic = ""
+	actual, err = ParsePublish(0, pub.Encode(nil, Version5)[2:], Version5)
+	require.NoError(t, err)
+	require.Equal(t, "", actual.Topic)
+	require.Equal(t, uint16(3), actual.TopicAlias)
+
+	_, err = ParsePublish(0x06, pkt.Body, Version5)
+	require.Error(t, err)
+	// the unknown property
+	_, err = ParsePublish(0, append(appendString(nil, "a"), 2, 0x7f, 0), Version5)
+	require.Error(t, err)
+}
+
+func TestSubscribeCodec(t *testing.T) {
+	for _, version := range []byte{Version311, Version5} {
+		sub := &Subscribe{
+			PacketID: 7,
+			Subscriptions: []Subscription{
+				{Filter: "a/#", Options: 1},
+				{Filter: "$share/g/b/+", Options: 0},
+			},
+		}
+		if version == Version5 {
+			sub.Properties = []byte{0x0b, 0x05}
+		}
+		pkt, _, err := DecodePacket(sub.Encode(nil, version), MaxPacketSize)
+		require.NoError(t, err)
+		require.Equal(t, byte(SUBSCRIBE), pkt.Type)
+		require.Equal(t, byte(0x02), pkt.Flags)
+		actual, err := ParseSubscribe(pkt.Body, version)
+		require.NoError(t, err)
+		require.Equal(t, sub, actual)
+
+		suback := &Suback{PacketID: 7, Properties: sub.Properties, Codes: []byte{1, subackFailure(version)}}
+		pkt, _, err = DecodePacket(suback.Encode(nil, version), MaxPacketSize)
+		require.NoError(t, err)
+		actualSuback, err := ParseSuback(pkt.Body, version)
+		require.NoError(t, err)
+		require.Equal(t, suback, actualSuback)
+	}
+
+	_, err := ParseSubscribe([]byte{0, 1}, Version311)
+	require.Error(t, err)
+}
@@ -0,0 +1,450 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqttproxy
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/filter/network/internal/upstream"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/upstream/cluster"
+	"mosn.io/pkg/buffer"
+)
+
+// proxy is a ReadFilter that proxies a MQTT connection. The downstream connection is routed to an
+// upstream broker by the CONNECT packet, then the packets are forwarded in both directions, and the
+// PUBLISH and SUBSCRIBE packets sent by the client are checked by the topic ACLs. The TLS of the
+// clients is terminated by the listener, and the TLS of the brokers is configured by the cluster.
+type proxy struct {
+	config         *v2.MqttProxy
+	router         *router
+	acl            *acl
+	stats          *proxyStats
+	clusterManager types.ClusterManager
+	readCallbacks  api.ReadFilterCallbacks
+	ctx            context.Context
+
+	mutex    sync.Mutex
+	connect  *Connect
+	upstream *upstreamConn
+	closed   bool
+	// clientAliases are the topic aliases of the PUBLISH packets sent by the client, and
+	// forwardedAliases are the aliases forwarded to the broker, which differ from the client
+	// aliases if the PUBLISH packets setting the aliases are denied.
+	clientAliases    map[uint16]string
+	forwardedAliases map[uint16]string
+	// brokerAliases are the topic aliases of the PUBLISH packets sent by the broker
+	brokerAliases map[uint16]string
+	// subscribes are the masks of the SUBSCRIBE packets partially denied by the packet id,
+	// the SUBACK codes of the denied topic filters are inserted into the SUBACK of the broker.
+	subscribes map[uint16][]bool
+}
+
+func newProxy(ctx context.Context, config *v2.MqttProxy, r *router, a *acl) *proxy {
+	return &proxy{
+		config:           config,
+		router:           r,
+		acl:              a,
+		stats:            getProxyStats(config.StatPrefix),
+		clusterManager:   cluster.GetClusterMngAdapterInstance().ClusterManager,
+		ctx:              ctx,
+		clientAliases:    map[uint16]string{},
+		forwardedAliases: map[uint16]string{},
+		brokerAliases:    map[uint16]string{},
+		subscribes:       map[uint16][]bool{},
+	}
+}
+
+func (p *proxy) OnNewConnection() api.FilterStatus {
+	return api.Continue
+}
+
+func (p *proxy) InitializeReadFilterCallbacks(cb api.ReadFilterCallbacks) {
+	p.readCallbacks = cb
+	p.readCallbacks.Connection().AddConnectionEventListener(p)
+}
+
+func (p *proxy) OnData(buf buffer.IoBuffer) api.FilterStatus {
+	p.mutex.Lock()
+	var (
+		up, down []byte
+		quit     bool
+	)
+	for !p.closed && !quit {
+		pkt, n, err := DecodePacket(buf.Bytes(), p.config.MaxPacketSize)
+		if err == errIncomplete {
+			break
+		}
+		if err != nil {
+			log.DefaultLogger.Errorf("[mqtt proxy] decode packet failed: %v", err)
+			p.stats.ProtocolError.Inc(1)
+			if p.connect != nil && p.connect.Version == Version5 {
+				down = encodeDisconnect(down, reasonPacketTooLarge)
+			}
+			quit = true
+			break
+		}
+		up, down, quit = p.handle(pkt, up, down)
+		buf.Drain(n)
+	}
+	if quit || p.closed {
+		buf.Drain(buf.Len())
+	}
+	uc := p.upstream
+	closed := p.closed
+	p.mutex.Unlock()
+
+	if len(up) > 0 && uc != nil {
+		uc.write(up)
+	}
+	if len(down) > 0 && !closed {
+		p.writeDownstream(down)
+	}
+	if quit && !closed {
+		p.readCallbacks.Connection().Close(api.FlushWrite, api.LocalClose)
+	}
+	return api.Stop
+}
+
+func (p *proxy) writeDownstream(data []byte) {
+	if err := p.readCallbacks.Connection().Write(buffer.NewIoBufferBytes(data)); err != nil {
+		log.DefaultLogger.Errorf("[mqtt proxy] write to downstream failed: %v", err)
+	}
+}
+
+// handle handles a packet sent by the client, the packets forwarded to the broker are appended to up,
+// and the packets responded by the proxy are appended to down. It returns true if the connection
+// should be closed.
+func (p *proxy) handle(pkt *Packet, up, down []byte) ([]byte, []byte, bool) {
+	if p.connect == nil {
+		if pkt.Type != CONNECT {
+			log.DefaultLogger.Errorf("[mqtt proxy] the first packet is %d, not CONNECT", pkt.Type)
+			p.stats.ProtocolError.Inc(1)
+			return up, down, true
+		}
+		c, err := ParseConnect(pkt.Body)
+		if err != nil {
+			log.DefaultLogger.Errorf("[mqtt proxy] parse CONNECT failed: %v", err)
+			p.stats.ProtocolError.Inc(1)
+			return up, down, true
+		}
+		p.connect = c
+		p.stats.ConnectTotal.Inc(1)
+		if err := p.connectUpstream(c); err != nil {
+			log.DefaultLogger.Errorf("[mqtt proxy] client %s connect upstream failed: %v", c.ClientID, err)
+			p.stats.ConnectRejected.Inc(1)
+			return up, encodeConnack(down, c.Version, reasonServerUnavailable), true
+		}
+		return append(up, pkt.Raw...), down, false
+	}
+
+	version := p.connect.Version
+	switch pkt.Type {
+	case CONNECT:
+		log.DefaultLogger.Errorf("[mqtt proxy] client %s sent CONNECT twice", p.connect.ClientID)
+		p.stats.ProtocolError.Inc(1)
+		if version == Version5 {
+			down = encodeDisconnect(down, reasonProtocolError)
+		}
+		return up, down, true
+	case PUBLISH:
+		return p.handlePublish(pkt, up, down)
+	case SUBSCRIBE:
+		return p.handleSubscribe(pkt, up, down)
+	default:
+		return append(up, pkt.Raw...), down, false
+	}
+}
+
+// resolveTopic returns the topic of the PUBLISH packet, and saves the topic alias
+func resolveTopic(aliases map[uint16]string, pub *Publish) string {
+	if pub.TopicAlias == 0 {
+		return pub.Topic
+	}
+	if pub.Topic != "" {
+		aliases[pub.TopicAlias] = pub.Topic
+		return pub.Topic
+	}
+	return aliases[pub.TopicAlias]
+}
+
+func (p *proxy) handlePublish(pkt *Packet, up, down []byte) ([]byte, []byte, bool) {
+	c := p.connect
+	pub, err := ParsePublish(pkt.Flags, pkt.Body, c.Version)
+	if err != nil {
+		log.DefaultLogger.Errorf("[mqtt proxy] client %s parse PUBLISH failed: %v", c.ClientID, err)
+		p.stats.ProtocolError.Inc(1)
+		return up, down, true
+	}
+	topic := resolveTopic(p.clientAliases, pub)
+	if topic == "" {
+		log.DefaultLogger.Errorf("[mqtt proxy] client %s sent PUBLISH with unknown topic alias %d", c.ClientID, pub.TopicAlias)
+		p.stats.ProtocolError.Inc(1)
+		return up, encodeDisconnect(down, reasonProtocolError), true
+	}
+
+	if !p.acl.canPublish(c, topic) {
+		p.stats.PublishDenied.Inc(1)
+		if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+			log.DefaultLogger.Debugf("[mqtt proxy] client %s is not authorized to publish %s", c.ClientID, topic)
+		}
+		// MQTT 3.1.1 has no negative acknowledgement, so the connection is closed
+		if c.Version != Version5 {
+			return up, down, true
+		}
+		switch pub.QoS {
+		case 1:
+			down = encodeAck(down, PUBACK, pub.PacketID, reasonNotAuthorized)
+		case 2:
+			down = encodeAck(down, PUBREC, pub.PacketID, reasonNotAuthorized)
+		}
+		return up, down, false
+	}
+
+	stats := p.topicStats(topic)
+	stats.PublishInTotal.Inc(1)
+	stats.PublishInBytes.Inc(int64(len(pub.Payload)))
+
+	if pub.TopicAlias != 0 {
+		if pub.Topic == "" && p.forwardedAliases[pub.TopicAlias] != topic {
+			// the broker does not know the alias, so the topic is sent with the alias
+			pub.Topic = topic
+			p.forwardedAliases[pub.TopicAlias] = topic
+			return pub.Encode(up, c.Version), down, false
+		}
+		p.forwardedAliases[pub.TopicAlias] = topic
+	}
+	return append(up, pkt.Raw...), down, false
+}
+
+func (p *proxy) handleSubscribe(pkt *Packet, up, down []byte) ([]byte, []byte, bool) {
+	c := p.connect
+	sub, err := ParseSubscribe(pkt.Body, c.Version)
+	if err != nil {
+		log.DefaultLogger.Errorf("[mqtt proxy] client %s parse SUBSCRIBE failed: %v", c.ClientID, err)
+		p.stats.ProtocolError.Inc(1)
+		return up, down, true
+	}
+	mask := make([]bool, len(sub.Subscriptions))
+	allowed := sub.Subscriptions[:0:0]
+	for i, s := range sub.Subscriptions {
+		if !p.acl.canSubscribe(c, s.Filter) {
+			p.stats.SubscribeDenied.Inc(1)
+			if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+				log.DefaultLogger.Debugf("[mqtt proxy] client %s is not authorized to subscribe %s", c.ClientID, s.Filter)
+			}
+			continue
+		}
+		mask[i] = true
+		allowed = append(allowed, s)
+		p.topicStats(stripShare(s.Filter)).SubscribeTotal.Inc(1)
+	}
+
+	switch len(allowed) {
+	case len(sub.Subscriptions):
+		return append(up, pkt.Raw...), down, false
+	case 0:
+		codes := make([]byte, len(mask))
+		for i := range codes {
+			codes[i] = subackFailure(c.Version)
+		}
+		suback := &Suback{PacketID: sub.PacketID, Codes: codes}
+		return up, suback.Encode(down, c.Version), false
+	default:
+		p.subscribes[sub.PacketID] = mask
+		sub.Subscriptions = allowed
+		return sub.Encode(up, c.Version), down, false
+	}
+}
+
+// handleUpstream handles a packet sent by the broker, and appends the packet forwarded to the client to down
+func (p *proxy) handleUpstream(pkt *Packet, down []byte) ([]byte, error) {
+	version := p.connect.Version
+	switch pkt.Type {
+	case PUBLISH:
+		pub, err := ParsePublish(pkt.Flags, pkt.Body, version)
+		if err != nil {
+			return down, err
+		}
+		if topic := resolveTopic(p.brokerAliases, pub); topic != "" {
+			stats := p.topicStats(topic)
+			stats.PublishOutTotal.Inc(1)
+			stats.PublishOutBytes.Inc(int64(len(pub.Payload)))
+		}
+	case SUBACK:
+		mask, ok := p.subscribes[subackPacketID(pkt.Body)]
+		if !ok {
+			break
+		}
+		suback, err := ParseSuback(pkt.Body, version)
+		if err != nil {
+			return down, err
+		}
+		delete(p.subscribes, suback.PacketID)
+		codes := make([]byte, 0, len(mask))
+		for _, allowed := range mask {
+			switch {
+			case !allowed:
+				codes = append(codes, subackFailure(version))
+			case len(suback.Codes) > 0:
+				codes = append(codes, suback.Codes[0])
+				suback.Codes = suback.Codes[1:]
+			default:
+				return down, errors.New("SUBACK codes mismatch the SUBSCRIBE")
+			}
+		}
+		suback.Codes = codes
+		return suback.Encode(down, version), nil
+	}
+	return append(down, pkt.Raw...), nil
+}
+
+// subackPacketID returns the packet id of the SUBACK packet
+func subackPacketID(body []byte) uint16 {
+	if len(body) < 2 {
+		return 0
+	}
+	return uint16(body[0])<<8 | uint16(body[1])
+}
+
+// connectUpstream leases the upstream connection of the cluster routed by the CONNECT packet,
+// the connection is connected asynchronously and the packets are sent when it is connected.
+func (p *proxy) connectUpstream(c *Connect) error {
+	clusterName := p.router.route(c)
+	if clusterName == "" {
+		return errors.New("no route")
+	}
+	uc := &upstreamConn{proxy: p}
+	conn, err := upstream.Connect(p.ctx, p.clusterManager, clusterName, p.readCallbacks, upstreamProtocol, uc)
+	if err != nil {
+		return err
+	}
+	uc.conn = conn
+	p.readCallbacks.SetUpstreamHost(conn.Host())
+	p.stats.ConnectionActive.Inc(1)
+	p.upstream = uc
+	return nil
+}
+
+// removeUpstream finalizes the stats of the upstream connection, it returns false if it is removed
+func (p *proxy) removeUpstream(uc *upstreamConn) bool {
+	if uc.closed {
+		return false
+	}
+	uc.closed = true
+	p.stats.ConnectionActive.Dec(1)
+	return true
+}
+
+// OnEvent handles the downstream connection events
+func (p *proxy) OnEvent(event api.ConnectionEvent) {
+	if !event.IsClose() {
+		return
+	}
+	p.mutex.Lock()
+	p.closed = true
+	uc := p.upstream
+	removed := uc != nil && p.removeUpstream(uc)
+	p.mutex.Unlock()
+
+	// the session state is bound to the connection, so it is not reused
+	if removed {
+		uc.conn.Release(false)
+	}
+}
+
+// upstreamConn is the upstream connection of a downstream connection
+type upstreamConn struct {
+	proxy  *proxy
+	conn   *upstream.Conn
+	closed bool
+}
+
+func (uc *upstreamConn) write(data []byte) {
+	if err := uc.conn.Write(data); err != nil {
+		log.DefaultLogger.Errorf("[mqtt proxy] write to upstream %s failed: %v", uc.conn.Host().AddressString(), err)
+	}
+}
+
+func (uc *upstreamConn) OnData(conn *upstream.Conn, buf buffer.IoBuffer) {
+	p := uc.proxy
+	p.mutex.Lock()
+	var down []byte
+	broken := false
+	for !uc.closed {
+		pkt, n, err := DecodePacket(buf.Bytes(), MaxPacketSize)
+		if err == errIncomplete {
+			break
+		}
+		if err == nil {
+			down, err = p.handleUpstream(pkt, down)
+		}
+		if err != nil {
+			log.DefaultLogger.Errorf("[mqtt proxy] decode packet from upstream %s failed: %v", conn.Host().AddressString(), err)
+			broken = true
+			break
+		}
+		buf.Drain(n)
+	}
+	if broken || uc.closed {
+		buf.Drain(buf.Len())
+	}
+	if broken {
+		p.removeUpstream(uc)
+	}
+	closed := p.closed
+	p.mutex.Unlock()
+
+	if len(down) > 0 && !closed {
+		p.writeDownstream(down)
+	}
+	if broken {
+		conn.Close()
+		if !closed {
+			p.readCallbacks.Connection().Close(api.FlushWrite, api.LocalClose)
+		}
+	}
+}
+
+// OnClose handles the upstream connection closed by the broker or failed to connect
+func (uc *upstreamConn) OnClose(conn *upstream.Conn, event api.ConnectionEvent) {
+	p := uc.proxy
+	p.mutex.Lock()
+	removed := p.removeUpstream(uc)
+	closed := p.closed
+	var down []byte
+	if removed && event.ConnectFailure() {
+		log.DefaultLogger.Errorf("[mqtt proxy] client %s connect upstream %s failed", p.connect.ClientID, conn.Host().AddressString())
+		p.stats.ConnectRejected.Inc(1)
+		down = encodeConnack(down, p.connect.Version, reasonServerUnavailable)
+	}
+	p.mutex.Unlock()
+	if !removed || closed {
+		return
+	}
+	if len(down) > 0 {
+		p.writeDownstream(down)
+	}
+	// the packets sent by the broker are flushed to the client
+	p.readCallbacks.Connection().Close(api.FlushWrite, api.RemoteClose)
+}
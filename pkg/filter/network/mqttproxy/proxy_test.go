@@ -0,0 +1,402 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqttproxy
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/filter/network/internal/proxytest"
+	"mosn.io/mosn/pkg/metrics"
+)
+
+// fakeBroker is a MQTT broker which sends the PUBLISH packets back to the clients
+type fakeBroker struct {
+	*proxytest.Server
+	mutex   sync.Mutex
+	clients []string
+	topics  []string
+	filters []string
+}
+
+func newFakeBroker(t *testing.T) *fakeBroker {
+	b := &fakeBroker{}
+	b.Server = proxytest.NewServer(t, b.serve)
+	return b
+}
+
+func (b *fakeBroker) received() ([]string, []string, []string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.clients, b.topics, b.filters
+}
+
+func (b *fakeBroker) serve(conn net.Conn) {
+	defer conn.Close()
+	var (
+		in      []byte
+		version byte
+		aliases = map[uint16]string{}
+	)
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+		in = append(in, buf[:n]...)
+		var out []byte
+		for {
+			pkt, n, err := DecodePacket(in, MaxPacketSize)
+			if err != nil {
+				break
+			}
+			in = in[n:]
+			b.mutex.Lock()
+			switch pkt.Type {
+			case CONNECT:
+				c, _ := ParseConnect(pkt.Body)
+				version = c.Version
+				b.clients = append(b.clients, c.ClientID)
+				if version == Version5 {
+					out = EncodePacket(out, CONNACK, 0, []byte{0, 0, 0})
+				} else {
+					out = EncodePacket(out, CONNACK, 0, []byte{0, 0})
+				}
+			case PUBLISH:
+				pub, _ := ParsePublish(pkt.Flags, pkt.Body, version)
+				b.topics = append(b.topics, resolveTopic(aliases, pub))
+				if pub.QoS == 1 {
+					out = EncodePacket(out, PUBACK, 0, []byte{byte(pub.PacketID >> 8), byte(pub.PacketID)})
+				}
+				echo := &Publish{Topic: resolveTopic(aliases, pub), Payload: pub.Payload}
+				out = echo.Encode(out, version)
+			case SUBSCRIBE:
+				sub, _ := ParseSubscribe(pkt.Body, version)
+				suback := &Suback{PacketID: sub.PacketID}
+				for _, s := range sub.Subscriptions {
+					b.filters = append(b.filters, s.Filter)
+					suback.Codes = append(suback.Codes, s.Options&0x03)
+				}
+				out = suback.Encode(out, version)
+			case PINGREQ:
+				out = EncodePacket(out, PINGRESP, 0, nil)
+			}
+			b.mutex.Unlock()
+		}
+		conn.Write(out)
+	}
+}
+
+// testClient is a downstream connection of the proxy
+type testClient struct {
+	*proxytest.Client
+	t     *testing.T
+	proxy *proxy
+}
+
+func newTestClient(t *testing.T, ctrl *gomock.Controller, config *v2.MqttProxy) *testClient {
+	if config.MaxPacketSize == 0 {
+		config.MaxPacketSize = MaxPacketSize
+	}
+	if config.MaxTopicStats == 0 {
+		config.MaxTopicStats = defaultMaxTopicStats
+	}
+	r, err := newRouter(config)
+	require.NoError(t, err)
+	a, err := newACL(config)
+	require.NoError(t, err)
+	p := newProxy(context.Background(), config, r, a)
+	return &testClient{
+		Client: proxytest.NewClient(t, ctrl, p, decodePacket),
+		t:      t,
+		proxy:  p,
+	}
+}
+
+func decodePacket(data []byte) (int, error) {
+	_, n, err := DecodePacket(data, MaxPacketSize)
+	if err == errIncomplete {
+		return 0, nil
+	}
+	return n, err
+}
+
+// send sends the data and returns the received packets
+func (c *testClient) send(data []byte, count int) []*Packet {
+	var packets []*Packet
+	for _, msg := range c.Send(data, count) {
+		pkt, _, err := DecodePacket(msg, MaxPacketSize)
+		require.NoError(c.t, err)
+		packets = append(packets, pkt)
+	}
+	return packets
+}
+
+func requireType(t *testing.T, packets []*Packet, types ...byte) {
+	var actual []byte
+	for _, pkt := range packets {
+		actual = append(actual, pkt.Type)
+	}
+	require.Equal(t, types, actual)
+}
+
+func setupClusters(t *testing.T, clusters map[string]*fakeBroker) {
+	addrs := map[string][]string{}
+	for name, b := range clusters {
+		addrs[name] = []string{b.Addr()}
+	}
+	proxytest.SetupClusters(addrs)
+}
+
+func resetStats() {
+	proxytest.ResetStats(&proxyStatsCache)
+	topicStatsMutex.Lock()
+	topicStatsCache = map[string]map[string]*topicStats{}
+	topicStatsMutex.Unlock()
+}
+
+func TestTopicStats(t *testing.T) {
+	resetStats()
+	defer metrics.ResetAll()
+
+	require.Equal(t, "a/b/#", statsTopic("a/b/c/d", 2))
+	require.Equal(t, "a/b", statsTopic("a/b", 2))
+	require.Equal(t, "a/b/c/d", statsTopic("a/b/c/d", -1))
+
+	config, err := ParseMqttProxy(map[string]interface{}{"cluster": "mqtt"})
+	require.NoError(t, err)
+	require.Equal(t, defaultTopicStatsDepth, config.TopicStatsDepth)
+	require.Equal(t, defaultMaxTopicStats, config.MaxTopicStats)
+
+	// the topics exceeding the limit are counted in the other topic
+	a := getTopicStats("test_limit", "a", 2)
+	b := getTopicStats("test_limit", "b", 2)
+	c := getTopicStats("test_limit", "c", 2)
+	d := getTopicStats("test_limit", "d", 2)
+	require.NotSame(t, a, b)
+	require.Same(t, c, d)
+	require.Same(t, c, getTopicStats("test_limit", otherTopic, 2))
+	require.Same(t, a, getTopicStats("test_limit", "a", 2))
+	// the limit is applied to each stat prefix
+	require.NotSame(t, c, getTopicStats("test_limit_other", "c", 2))
+}
+
+func TestMqttProxyRoute(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	brokerDefault, brokerSensors, brokerAdmin := newFakeBroker(t), newFakeBroker(t), newFakeBroker(t)
+	defer brokerDefault.Close()
+	defer brokerSensors.Close()
+	defer brokerAdmin.Close()
+	resetStats()
+	setupClusters(t, map[string]*fakeBroker{
+		"mqtt_default": brokerDefault,
+		"mqtt_sensors": brokerSensors,
+		"mqtt_admin":   brokerAdmin,
+	})
+	defer proxytest.Cleanup()
+
+	config := &v2.MqttProxy{
+		StatPrefix: "test_mqtt",
+		Cluster:    "mqtt_default",
+		Routes: []*v2.MqttRoute{
+			{ClientID: "sensor-.*", Cluster: "mqtt_sensors"},
+			{Username: "admin", Cluster: "mqtt_admin"},
+		},
+	}
+	for _, cs := range []struct {
+		clientID, username string
+		broker             *fakeBroker
+	}{
+		{"sensor-1", "", brokerSensors},
+		{"app-1", "admin", brokerAdmin},
+		{"app-2", "", brokerDefault},
+	} {
+		c := newTestClient(t, ctrl, config)
+		requireType(t, c.send(encodeConnect(Version311, cs.clientID, cs.username), 1), CONNACK)
+		requireType(t, c.send(EncodePacket(nil, PINGREQ, 0, nil), 1), PINGRESP)
+		clients, _, _ := cs.broker.received()
+		require.Equal(t, []string{cs.clientID}, clients)
+
+		c.proxy.OnEvent(api.RemoteClose)
+		require.True(t, c.proxy.upstream.closed)
+	}
+	stats := getProxyStats("test_mqtt")
+	require.Equal(t, int64(3), stats.ConnectTotal.Count())
+	require.Equal(t, int64(0), stats.ConnectionActive.Count())
+}
+
+func TestMqttProxyNoUpstream(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	resetStats()
+	// the broker of the cluster is down
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ln.Close()
+	proxytest.SetupClusters(map[string][]string{"mqtt_down": {ln.Addr().String()}})
+	defer proxytest.Cleanup()
+
+	for _, cluster := range []string{"mqtt_missing", "mqtt_down"} {
+		for _, version := range []byte{Version311, Version5} {
+			c := newTestClient(t, ctrl, &v2.MqttProxy{Cluster: cluster})
+			packets := c.send(encodeConnect(version, "c1", ""), 1)
+			requireType(t, packets, CONNACK)
+			if version == Version5 {
+				require.Equal(t, byte(reasonServerUnavailable), packets[0].Body[1])
+			} else {
+				require.Equal(t, byte(connackServerUnavailable311), packets[0].Body[1])
+			}
+			require.Eventually(t, c.IsClosed, time.Second, 10*time.Millisecond)
+		}
+	}
+	require.Equal(t, int64(4), getProxyStats("").ConnectRejected.Count())
+	require.Equal(t, int64(0), getProxyStats("").ConnectionActive.Count())
+
+	// the first packet is not CONNECT
+	c := newTestClient(t, ctrl, &v2.MqttProxy{Cluster: "mqtt_missing"})
+	c.send(EncodePacket(nil, PINGREQ, 0, nil), 0)
+	require.True(t, c.IsClosed())
+	require.Equal(t, int64(1), getProxyStats("").ProtocolError.Count())
+}
+
+func TestMqttProxyACL(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	broker := newFakeBroker(t)
+	defer broker.Close()
+	resetStats()
+	setupClusters(t, map[string]*fakeBroker{"mqtt": broker})
+	defer proxytest.Cleanup()
+
+	config := &v2.MqttProxy{
+		StatPrefix:      "test_acl",
+		Cluster:         "mqtt",
+		DenyByDefault:   true,
+		TopicStatsDepth: 2,
+		ACLs: []*v2.MqttTopicACL{
+			{Action: "publish", Topics: []string{"devices/%c/#"}},
+			{Action: "subscribe", Topics: []string{"devices/+/status"}},
+		},
+	}
+
+	// MQTT 5.0
+	c := newTestClient(t, ctrl, config)
+	requireType(t, c.send(encodeConnect(Version5, "d1", ""), 1), CONNACK)
+
+	// the allowed publish is echoed by the broker
+	pub := &Publish{Topic: "devices/d1/status", QoS: 1, PacketID: 1, Payload: []byte("on")}
+	packets := c.send(pub.Encode(nil, Version5), 2)
+	requireType(t, packets, PUBACK, PUBLISH)
+
+	// the denied publish is acknowledged with not authorized
+	pub = &Publish{Topic: "devices/d2/status", QoS: 1, PacketID: 2, Payload: []byte("on")}
+	packets = c.send(pub.Encode(nil, Version5), 1)
+	requireType(t, packets, PUBACK)
+	require.Equal(t, []byte{0, 2, reasonNotAuthorized, 0}, packets[0].Body)
+	pub.QoS = 0
+	c.send(pub.Encode(nil, Version5), 0)
+
+	// the topic alias is set by a denied publish, the allowed publish refers to the alias
+	// is sent to the broker with the topic
+	props := []byte{propTopicAlias, 0, 1}
+	denied := &Publish{Topic: "devices/d2/status", TopicAlias: 1, Properties: props, Payload: []byte("x")}
+	c.send(denied.Encode(nil, Version5), 0)
+	allowed := &Publish{Topic: "devices/d1/alias", TopicAlias: 1, Properties: props, Payload: []byte("y")}
+	requireType(t, c.send(allowed.Encode(nil, Version5), 1), PUBLISH)
+	allowed.Topic = ""
+	requireType(t, c.send(allowed.Encode(nil, Version5), 1), PUBLISH)
+	allowed.TopicAlias = 2
+	allowed.Properties = []byte{propTopicAlias, 0, 2}
+	allowed.Topic = "devices/d1/other"
+	requireType(t, c.send(allowed.Encode(nil, Version5), 1), PUBLISH)
+	allowed.Topic = ""
+	allowed.Properties = props
+	allowed.TopicAlias = 1
+	c.send(allowed.Encode(nil, Version5), 1)
+	_, topics, _ := broker.received()
+	require.Equal(t, []string{"devices/d1/status", "devices/d1/alias", "devices/d1/alias", "devices/d1/other", "devices/d1/alias"}, topics)
+
+	// the subscribe is partially denied
+	sub := &Subscribe{PacketID: 3, Subscriptions: []Subscription{
+		{Filter: "devices/#", Options: 1},
+		{Filter: "devices/+/status", Options: 1},
+		{Filter: "others", Options: 0},
+	}}
+	packets = c.send(sub.Encode(nil, Version5), 1)
+	requireType(t, packets, SUBACK)
+	suback, err := ParseSuback(packets[0].Body, Version5)
+	require.NoError(t, err)
+	require.Equal(t, uint16(3), suback.PacketID)
+	require.Equal(t, []byte{reasonNotAuthorized, 1, reasonNotAuthorized}, suback.Codes)
+	_, _, filters := broker.received()
+	require.Equal(t, []string{"devices/+/status"}, filters)
+
+	// the subscribe is denied by the proxy
+	sub = &Subscribe{PacketID: 4, Subscriptions: []Subscription{{Filter: "#"}}}
+	packets = c.send(sub.Encode(nil, Version5), 1)
+	suback, err = ParseSuback(packets[0].Body, Version5)
+	require.NoError(t, err)
+	require.Equal(t, []byte{reasonNotAuthorized}, suback.Codes)
+	_, _, filters = broker.received()
+	require.Len(t, filters, 1)
+
+	// the packet is too large
+	c.proxy.config.MaxPacketSize = 10
+	packets = c.send(pub.Encode(nil, Version5), 1)
+	requireType(t, packets, DISCONNECT)
+	require.Equal(t, byte(reasonPacketTooLarge), packets[0].Body[0])
+	require.True(t, c.IsClosed())
+
+	// MQTT 3.1.1 closes the connection which publishes a denied topic
+	config.MaxPacketSize = MaxPacketSize
+	c = newTestClient(t, ctrl, config)
+	requireType(t, c.send(encodeConnect(Version311, "d1", ""), 1), CONNACK)
+	sub = &Subscribe{PacketID: 1, Subscriptions: []Subscription{
+		{Filter: "a", Options: 0},
+		{Filter: "devices/d1/status", Options: 1},
+	}}
+	packets = c.send(sub.Encode(nil, Version311), 1)
+	suback, err = ParseSuback(packets[0].Body, Version311)
+	require.NoError(t, err)
+	require.Equal(t, []byte{subackFailure311, 1}, suback.Codes)
+	pub = &Publish{Topic: "devices/d2/status", Payload: []byte("on")}
+	c.send(pub.Encode(nil, Version311), 0)
+	require.True(t, c.IsClosed())
+
+	stats := getProxyStats("test_acl")
+	require.Equal(t, int64(4), stats.PublishDenied.Count())
+	require.Equal(t, int64(4), stats.SubscribeDenied.Count())
+	require.Equal(t, int64(1), stats.ProtocolError.Count())
+	require.Equal(t, int64(0), stats.ConnectionActive.Count())
+
+	topicStats := getTopicStats("test_acl", "devices/d1/#", defaultMaxTopicStats)
+	require.Equal(t, int64(5), topicStats.PublishInTotal.Count())
+	require.Equal(t, int64(6), topicStats.PublishInBytes.Count())
+	require.Equal(t, int64(5), topicStats.PublishOutTotal.Count())
+	require.Equal(t, int64(1), topicStats.SubscribeTotal.Count())
+	require.Equal(t, int64(1), getTopicStats("test_acl", "devices/+/#", defaultMaxTopicStats).SubscribeTotal.Count())
+}
@@ -0,0 +1,58 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqttproxy
+
+import (
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
+
+type route struct {
+	connMatcher
+	cluster string
+}
+
+// router routes the connections to the clusters by the CONNECT packets
+type router struct {
+	routes         []*route
+	defaultCluster string
+}
+
+func newRouter(config *v2.MqttProxy) (*router, error) {
+	r := &router{defaultCluster: config.Cluster}
+	for _, c := range config.Routes {
+		m, err := newConnMatcher(c.ClientID, c.Username)
+		if err != nil {
+			return nil, err
+		}
+		r.routes = append(r.routes, &route{
+			connMatcher: m,
+			cluster:     c.Cluster,
+		})
+	}
+	return r, nil
+}
+
+// route returns the cluster of the first matched route, or the default cluster
+func (r *router) route(c *Connect) string {
+	for _, rt := range r.routes {
+		if rt.match(c) {
+			return rt.cluster
+		}
+	}
+	return r.defaultCluster
+}
@@ -0,0 +1,129 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqttproxy
+
+import (
+	"strings"
+	"sync"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"mosn.io/mosn/pkg/metrics"
+)
+
+type proxyStats struct {
+	ConnectTotal     gometrics.Counter
+	ConnectRejected  gometrics.Counter
+	ConnectionActive gometrics.Counter
+	PublishDenied    gometrics.Counter
+	SubscribeDenied  gometrics.Counter
+	ProtocolError    gometrics.Counter
+}
+
+type topicStats struct {
+	PublishInTotal  gometrics.Counter
+	PublishInBytes  gometrics.Counter
+	PublishOutTotal gometrics.Counter
+	PublishOutBytes gometrics.Counter
+	SubscribeTotal  gometrics.Counter
+}
+
+const (
+	defaultTopicStatsDepth = 2
+	defaultMaxTopicStats   = 100
+	// otherTopic counts the topics exceeding the max topic stats, it is not a valid topic name or filter
+	otherTopic = "#other"
+)
+
+var (
+	proxyStatsCache sync.Map
+
+	topicStatsMutex sync.RWMutex
+	// topicStatsCache is the topic stats of each stat prefix
+	topicStatsCache = map[string]map[string]*topicStats{}
+)
+
+func getProxyStats(statPrefix string) *proxyStats {
+	if v, ok := proxyStatsCache.Load(statPrefix); ok {
+		return v.(*proxyStats)
+	}
+	s := metrics.NewMqttStats(statPrefix)
+	v, _ := proxyStatsCache.LoadOrStore(statPrefix, &proxyStats{
+		ConnectTotal:     s.Counter(metrics.MqttConnectTotal),
+		ConnectRejected:  s.Counter(metrics.MqttConnectRejected),
+		ConnectionActive: s.Counter(metrics.MqttConnectionActive),
+		PublishDenied:    s.Counter(metrics.MqttPublishDenied),
+		SubscribeDenied:  s.Counter(metrics.MqttSubscribeDenied),
+		ProtocolError:    s.Counter(metrics.MqttProtocolError),
+	})
+	return v.(*proxyStats)
+}
+
+// statsTopic returns the topic used in the metrics, the levels deeper than the depth are replaced with #
+func statsTopic(topic string, depth int) string {
+	if depth <= 0 {
+		return topic
+	}
+	levels := strings.SplitN(topic, "/", depth+1)
+	if len(levels) <= depth {
+		return topic
+	}
+	return strings.Join(levels[:depth], "/") + "/#"
+}
+
+// getTopicStats returns the stats of the topic, the topics are counted in the other topic
+// if the stat prefix has max topics already, so the metrics are bounded.
+func getTopicStats(statPrefix, topic string, max int) *topicStats {
+	topicStatsMutex.RLock()
+	s, ok := topicStatsCache[statPrefix][topic]
+	topicStatsMutex.RUnlock()
+	if ok {
+		return s
+	}
+
+	topicStatsMutex.Lock()
+	defer topicStatsMutex.Unlock()
+	topics, ok := topicStatsCache[statPrefix]
+	if !ok {
+		topics = map[string]*topicStats{}
+		topicStatsCache[statPrefix] = topics
+	}
+	if s, ok = topics[topic]; ok {
+		return s
+	}
+	if len(topics) >= max {
+		topic = otherTopic
+		if s, ok = topics[topic]; ok {
+			return s
+		}
+	}
+	mts := metrics.NewMqttTopicStats(statPrefix, topic)
+	s = &topicStats{
+		PublishInTotal:  mts.Counter(metrics.MqttPublishInTotal),
+		PublishInBytes:  mts.Counter(metrics.MqttPublishInBytes),
+		PublishOutTotal: mts.Counter(metrics.MqttPublishOutTotal),
+		PublishOutBytes: mts.Counter(metrics.MqttPublishOutBytes),
+		SubscribeTotal:  mts.Counter(metrics.MqttSubscribeTotal),
+	}
+	topics[topic] = s
+	return s
+}
+
+// topicStats returns the stats of the topic with the configured depth and limit
+func (p *proxy) topicStats(topic string) *topicStats {
+	return getTopicStats(p.config.StatPrefix, statsTopic(topic, p.config.TopicStatsDepth), p.config.MaxTopicStats)
+}
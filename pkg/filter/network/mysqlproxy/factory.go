@@ -0,0 +1,98 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mysqlproxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/filter/network/internal/upstream"
+)
+
+func init() {
+	api.RegisterNetwork(v2.MYSQL_PROXY, CreateMysqlProxyFactory)
+	upstream.Register(upstreamProtocol)
+}
+
+var upstreamProtocol = api.ProtocolName(v2.MYSQL_PROXY)
+
+type mysqlProxyFilterConfigFactory struct {
+	Proxy *v2.MysqlProxy
+	// credentials are the passwords of the users to the read cluster
+	credentials map[string]string
+}
+
+func (f *mysqlProxyFilterConfigFactory) CreateFilterChain(context context.Context, callbacks api.NetWorkFilterChainFactoryCallbacks) {
+	rf := newProxy(context, f.Proxy, f.credentials)
+	callbacks.AddReadFilter(rf)
+}
+
+func CreateMysqlProxyFactory(conf map[string]interface{}) (api.NetworkFilterChainFactory, error) {
+	p, err := ParseMysqlProxy(conf)
+	if err != nil {
+		return nil, err
+	}
+	credentials, err := loadCredentials(p.ReadCredentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	return &mysqlProxyFilterConfigFactory{
+		Proxy:       p,
+		credentials: credentials,
+	}, nil
+}
+
+// ParseMysqlProxy parses the mysql proxy config
+func ParseMysqlProxy(cfg map[string]interface{}) (*v2.MysqlProxy, error) {
+	proxy := &v2.MysqlProxy{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("[config] config is not a mysql proxy config: %v", err)
+	}
+	if err := json.Unmarshal(data, proxy); err != nil {
+		return nil, fmt.Errorf("[config] config is not a mysql proxy config: %v", err)
+	}
+	if proxy.Cluster == "" {
+		return nil, errors.New("[config] mysql proxy has no cluster")
+	}
+	if proxy.ReadCluster != "" && proxy.ReadCredentialsFile == "" {
+		return nil, errors.New("[config] mysql proxy read cluster has no credentials file")
+	}
+	return proxy, nil
+}
+
+// loadCredentials reads the passwords of the users from the json file
+func loadCredentials(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("[config] read mysql proxy credentials file failed: %v", err)
+	}
+	credentials := map[string]string{}
+	if err := json.Unmarshal(data, &credentials); err != nil {
+		return nil, fmt.Errorf("[config] mysql proxy credentials file is invalid: %v", err)
+	}
+	return credentials, nil
+}
@@ -0,0 +1,456 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mysqlproxy
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	headerSize = 4
+	// maxPayloadSize is the max payload size of a packet, a payload of the max size is continued
+	// by the next packet
+	maxPayloadSize = 0xffffff
+)
+
+// the capability flags
+const (
+	clientFoundRows             = 0x00000002
+	clientConnectWithDB         = 0x00000008
+	clientCompress              = 0x00000020
+	clientProtocol41            = 0x00000200
+	clientSSL                   = 0x00000800
+	clientSecureConnection      = 0x00008000
+	clientMultiStatements       = 0x00010000
+	clientMultiResults          = 0x00020000
+	clientPSMultiResults        = 0x00040000
+	clientPluginAuth            = 0x00080000
+	clientConnectAttrs          = 0x00100000
+	clientPluginAuthLenencData  = 0x00200000
+	clientSessionTrack          = 0x00800000
+	clientDeprecateEOF          = 0x01000000
+	clientQueryAttributes       = 0x08000000
+	clientOptionalResultsetMeta = 0x02000000
+)
+
+// formatCapabilities change the format of the packets, the connections to the read cluster must
+// negotiate the same flags as the client
+const formatCapabilities = clientFoundRows | clientMultiStatements | clientMultiResults | clientPSMultiResults |
+	clientSessionTrack | clientDeprecateEOF | clientQueryAttributes | clientOptionalResultsetMeta
+
+// the server status flags
+const (
+	serverStatusInTrans           = 0x0001
+	serverStatusAutocommit        = 0x0002
+	serverStatusMoreResultsExists = 0x0008
+)
+
+// the commands
+const (
+	comQuit             = 0x01
+	comInitDB           = 0x02
+	comQuery            = 0x03
+	comFieldList        = 0x04
+	comStatistics       = 0x09
+	comPing             = 0x0e
+	comChangeUser       = 0x11
+	comBinlogDump       = 0x12
+	comRegisterSlave    = 0x15
+	comStmtPrepare      = 0x16
+	comStmtExecute      = 0x17
+	comStmtSendLongData = 0x18
+	comStmtClose        = 0x19
+	comStmtReset        = 0x1a
+	comSetOption        = 0x1b
+	comStmtFetch        = 0x1c
+	comBinlogDumpGTID   = 0x1e
+	comResetConnection  = 0x1f
+)
+
+// the first byte of the response packets
+const (
+	iOK          = 0x00
+	iAuthMore    = 0x01
+	iLocalInfile = 0xfb
+	iEOF         = 0xfe
+	iERR         = 0xff
+)
+
+// erUnknownError is the error code used by the errors of the proxy
+const erUnknownError = 1105
+
+var (
+	errIncomplete = errors.New("incomplete packet")
+	errMalformed  = errors.New("malformed packet")
+)
+
+// Packet is a MySQL packet
+type Packet struct {
+	Seq     byte
+	Payload []byte
+	// Raw is the whole packet including the header
+	Raw []byte
+}
+
+// DecodePacket decodes a packet from the data, and returns the packet and the size of it
+func DecodePacket(data []byte) (*Packet, int, error) {
+	if len(data) < headerSize {
+		return nil, 0, errIncomplete
+	}
+	size := int(data[0]) | int(data[1])<<8 | int(data[2])<<16
+	n := headerSize + size
+	if len(data) < n {
+		return nil, 0, errIncomplete
+	}
+	return &Packet{
+		Seq:     data[3],
+		Payload: data[headerSize:n],
+		Raw:     data[:n],
+	}, n, nil
+}
+
+// EncodePacket appends the packet of the payload to out, the payload must be smaller than maxPayloadSize
+func EncodePacket(out []byte, seq byte, payload []byte) []byte {
+	size := len(payload)
+	out = append(out, byte(size), byte(size>>8), byte(size>>16), seq)
+	return append(out, payload...)
+}
+
+// encodeError encodes the ERR packet, the sql state is omitted before the capabilities are negotiated
+func encodeError(out []byte, seq byte, protocol41 bool, code uint16, msg string) []byte {
+	payload := []byte{iERR, byte(code), byte(code >> 8)}
+	if protocol41 {
+		payload = append(payload, "#HY000"...)
+	}
+	payload = append(payload, msg...)
+	return EncodePacket(out, seq, payload)
+}
+
+// isEOF returns true if the payload is an EOF packet, or an OK packet which replaces the EOF packet
+func isEOF(payload []byte) bool {
+	return len(payload) > 0 && payload[0] == iEOF && len(payload) < maxPayloadSize
+}
+
+// reader reads the fields of a payload, the error is saved and the following reads return zero values
+type reader struct {
+	b   []byte
+	err error
+}
+
+func (r *reader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.b) < n {
+		r.err = errMalformed
+		return nil
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *reader) byte() byte {
+	if v := r.next(1); v != nil {
+		return v[0]
+	}
+	return 0
+}
+
+func (r *reader) uint16() uint16 {
+	if v := r.next(2); v != nil {
+		return binary.LittleEndian.Uint16(v)
+	}
+	return 0
+}
+
+func (r *reader) uint32() uint32 {
+	if v := r.next(4); v != nil {
+		return binary.LittleEndian.Uint32(v)
+	}
+	return 0
+}
+
+// lenenc reads a length encoded integer
+func (r *reader) lenenc() uint64 {
+	switch b := r.byte(); b {
+	case 0xfc:
+		return uint64(r.uint16())
+	case 0xfd:
+		if v := r.next(3); v != nil {
+			return uint64(v[0]) | uint64(v[1])<<8 | uint64(v[2])<<16
+		}
+	case 0xfe:
+		if v := r.next(8); v != nil {
+			return binary.LittleEndian.Uint64(v)
+		}
+	case 0xfb, 0xff:
+		if r.err == nil {
+			r.err = errMalformed
+		}
+	default:
+		return uint64(b)
+	}
+	return 0
+}
+
+// nulString reads a string terminated by NUL, the rest is read if there is no NUL
+func (r *reader) nulString() string {
+	if r.err != nil {
+		return ""
+	}
+	i := bytes.IndexByte(r.b, 0)
+	if i < 0 {
+		s := string(r.b)
+		r.b = nil
+		return s
+	}
+	s := string(r.b[:i])
+	r.b = r.b[i+1:]
+	return s
+}
+
+func appendUint32(out []byte, v uint32) []byte {
+	return append(out, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendLenenc(out []byte, v uint64) []byte {
+	switch {
+	case v < 0xfb:
+		return append(out, byte(v))
+	case v < 1<<16:
+		return append(out, 0xfc, byte(v), byte(v>>8))
+	case v < 1<<24:
+		return append(out, 0xfd, byte(v), byte(v>>8), byte(v>>16))
+	default:
+		out = append(out, 0xfe)
+		return appendUint32(appendUint32(out, uint32(v)), uint32(v>>32))
+	}
+}
+
+// Greeting is the initial handshake packet sent by the server
+type Greeting struct {
+	ProtocolVersion byte
+	ServerVersion   string
+	ConnectionID    uint32
+	Capabilities    uint32
+	Charset         byte
+	Status          uint16
+	AuthData        []byte
+	AuthPlugin      string
+}
+
+// ParseGreeting parses the HandshakeV10 packet
+func ParseGreeting(payload []byte) (*Greeting, error) {
+	r := &reader{b: payload}
+	g := &Greeting{ProtocolVersion: r.byte()}
+	if r.err == nil && g.ProtocolVersion != 10 {
+		return nil, fmt.Errorf("unsupported protocol version %d", g.ProtocolVersion)
+	}
+	g.ServerVersion = r.nulString()
+	g.ConnectionID = r.uint32()
+	g.AuthData = append(g.AuthData, r.next(8)...)
+	r.byte()
+	g.Capabilities = uint32(r.uint16())
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(r.b) == 0 {
+		return g, nil
+	}
+	g.Charset = r.byte()
+	g.Status = r.uint16()
+	g.Capabilities |= uint32(r.uint16()) << 16
+	authLen := int(r.byte())
+	r.next(10)
+	if g.Capabilities&clientSecureConnection != 0 {
+		n := authLen - 8
+		if n < 13 {
+			n = 13
+		}
+		// the auth data is terminated by NUL
+		g.AuthData = append(g.AuthData, bytes.TrimRight(r.next(n), "\x00")...)
+	}
+	if g.Capabilities&clientPluginAuth != 0 {
+		g.AuthPlugin = r.nulString()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return g, nil
+}
+
+// HandshakeResponse is the HandshakeResponse41 packet sent by the client
+type HandshakeResponse struct {
+	Capabilities  uint32
+	MaxPacketSize uint32
+	Charset       byte
+	Username      string
+	AuthResponse  []byte
+	Database      string
+	AuthPlugin    string
+	// ConnectAttrs is the raw connection attributes
+	ConnectAttrs []byte
+}
+
+// isSSLRequest returns true if the payload is a SSLRequest packet, which is a truncated handshake response
+func isSSLRequest(payload []byte) bool {
+	return len(payload) == 32 && binary.LittleEndian.Uint32(payload)&clientSSL != 0
+}
+
+// ParseHandshakeResponse parses the HandshakeResponse41 packet
+func ParseHandshakeResponse(payload []byte) (*HandshakeResponse, error) {
+	r := &reader{b: payload}
+	h := &HandshakeResponse{Capabilities: r.uint32()}
+	if r.err == nil && h.Capabilities&clientProtocol41 == 0 {
+		return nil, errors.New("unsupported handshake response 320")
+	}
+	h.MaxPacketSize = r.uint32()
+	h.Charset = r.byte()
+	r.next(23)
+	h.Username = r.nulString()
+	switch {
+	case h.Capabilities&clientPluginAuthLenencData != 0:
+		h.AuthResponse = r.next(int(r.lenenc()))
+	case h.Capabilities&clientSecureConnection != 0:
+		h.AuthResponse = r.next(int(r.byte()))
+	default:
+		h.AuthResponse = []byte(r.nulString())
+	}
+	if h.Capabilities&clientConnectWithDB != 0 {
+		h.Database = r.nulString()
+	}
+	if h.Capabilities&clientPluginAuth != 0 {
+		h.AuthPlugin = r.nulString()
+	}
+	if h.Capabilities&clientConnectAttrs != 0 {
+		h.ConnectAttrs = r.next(int(r.lenenc()))
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return h, nil
+}
+
+// Encode encodes the HandshakeResponse41 payload
+func (h *HandshakeResponse) Encode() []byte {
+	out := appendUint32(nil, h.Capabilities)
+	out = appendUint32(out, h.MaxPacketSize)
+	out = append(out, h.Charset)
+	out = append(out, make([]byte, 23)...)
+	out = append(append(out, h.Username...), 0)
+	switch {
+	case h.Capabilities&clientPluginAuthLenencData != 0:
+		out = appendLenenc(out, uint64(len(h.AuthResponse)))
+		out = append(out, h.AuthResponse...)
+	case h.Capabilities&clientSecureConnection != 0:
+		out = append(out, byte(len(h.AuthResponse)))
+		out = append(out, h.AuthResponse...)
+	default:
+		out = append(append(out, h.AuthResponse...), 0)
+	}
+	if h.Capabilities&clientConnectWithDB != 0 {
+		out = append(append(out, h.Database...), 0)
+	}
+	if h.Capabilities&clientPluginAuth != 0 {
+		out = append(append(out, h.AuthPlugin...), 0)
+	}
+	if h.Capabilities&clientConnectAttrs != 0 {
+		out = appendLenenc(out, uint64(len(h.ConnectAttrs)))
+		out = append(out, h.ConnectAttrs...)
+	}
+	return out
+}
+
+// parseStatus returns the server status of the OK or EOF packet
+func parseStatus(payload []byte) (uint16, bool) {
+	r := &reader{b: payload}
+	switch r.byte() {
+	case iOK:
+		r.lenenc()
+		r.lenenc()
+	case iEOF:
+		if len(payload) == 5 {
+			r.uint16()
+			break
+		}
+		// the OK packet replaces the EOF packet
+		r.lenenc()
+		r.lenenc()
+	default:
+		return 0, false
+	}
+	status := r.uint16()
+	return status, r.err == nil
+}
+
+// parseError returns the error code and message of the ERR packet
+func parseError(payload []byte) (uint16, string) {
+	r := &reader{b: payload}
+	r.byte()
+	code := r.uint16()
+	if len(r.b) > 0 && r.b[0] == '#' {
+		r.next(6)
+	}
+	return code, string(r.b)
+}
+
+// the authentication plugins supported by the connections to the read cluster
+const (
+	nativePassword      = "mysql_native_password"
+	cachingSha2Password = "caching_sha2_password"
+)
+
+// scramblePassword computes the auth response of the plugin
+func scramblePassword(plugin string, scramble []byte, password string) ([]byte, error) {
+	if password == "" {
+		return nil, nil
+	}
+	switch plugin {
+	case nativePassword:
+		// SHA1(password) XOR SHA1(scramble + SHA1(SHA1(password)))
+		stage1 := sha1.Sum([]byte(password))
+		stage2 := sha1.Sum(stage1[:])
+		h := sha1.New()
+		h.Write(scramble)
+		h.Write(stage2[:])
+		out := h.Sum(nil)
+		for i := range out {
+			out[i] ^= stage1[i]
+		}
+		return out, nil
+	case cachingSha2Password:
+		// SHA256(password) XOR SHA256(SHA256(SHA256(password)) + scramble)
+		stage1 := sha256.Sum256([]byte(password))
+		stage2 := sha256.Sum256(stage1[:])
+		h := sha256.New()
+		h.Write(stage2[:])
+		h.Write(scramble)
+		out := h.Sum(nil)
+		for i := range out {
+			out[i] ^= stage1[i]
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("unsupported auth plugin %s", plugin)
+}
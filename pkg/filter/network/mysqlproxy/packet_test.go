@@ -0,0 +1,162 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mysqlproxy
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testScramble = []byte("0123456789abcdefghij")
+
+// encodeGreeting encodes the HandshakeV10 payload
+func encodeGreeting(capabilities uint32, status uint16, plugin string) []byte {
+	out := []byte{10}
+	out = append(out, "8.0.30-test\x00"...)
+	out = appendUint32(out, 7)
+	out = append(out, testScramble[:8]...)
+	out = append(out, 0, byte(capabilities), byte(capabilities>>8), 33, byte(status), byte(status>>8))
+	out = append(out, byte(capabilities>>16), byte(capabilities>>24), 21)
+	out = append(out, make([]byte, 10)...)
+	out = append(out, testScramble[8:]...)
+	out = append(out, 0)
+	return append(append(out, plugin...), 0)
+}
+
+func TestDecodePacket(t *testing.T) {
+	data := EncodePacket(nil, 0, []byte{comPing})
+	data = EncodePacket(data, 3, []byte("hello"))
+	pkt, n, err := DecodePacket(data)
+	require.NoError(t, err)
+	require.Equal(t, 5, n)
+	require.Equal(t, []byte{comPing}, pkt.Payload)
+	pkt, n, err = DecodePacket(data[5:])
+	require.NoError(t, err)
+	require.Equal(t, 9, n)
+	require.Equal(t, byte(3), pkt.Seq)
+	require.Equal(t, []byte("hello"), pkt.Payload)
+	require.Equal(t, data[5:], pkt.Raw)
+	for i := 0; i < 9; i++ {
+		_, _, err = DecodePacket(data[5 : 5+i])
+		require.Equal(t, errIncomplete, err)
+	}
+}
+
+func TestLenenc(t *testing.T) {
+	for _, v := range []uint64{0, 250, 251, 65535, 65536, 1<<24 - 1, 1 << 24, 1<<64 - 1} {
+		r := &reader{b: appendLenenc(nil, v)}
+		require.Equal(t, v, r.lenenc())
+		require.NoError(t, r.err)
+		require.Empty(t, r.b)
+	}
+	r := &reader{b: []byte{0xfc, 1}}
+	r.lenenc()
+	require.Error(t, r.err)
+}
+
+func TestHandshake(t *testing.T) {
+	capabilities := uint32(clientProtocol41 | clientSecureConnection | clientPluginAuth | clientDeprecateEOF | clientConnectWithDB)
+	g, err := ParseGreeting(encodeGreeting(capabilities, serverStatusAutocommit, nativePassword))
+	require.NoError(t, err)
+	require.Equal(t, "8.0.30-test", g.ServerVersion)
+	require.Equal(t, uint32(7), g.ConnectionID)
+	require.Equal(t, capabilities, g.Capabilities)
+	require.Equal(t, uint16(serverStatusAutocommit), g.Status)
+	require.Equal(t, testScramble, g.AuthData)
+	require.Equal(t, nativePassword, g.AuthPlugin)
+
+	_, err = ParseGreeting([]byte{9, 'a', 0})
+	require.Error(t, err)
+
+	for _, flags := range []uint32{0, clientPluginAuthLenencData | clientConnectAttrs} {
+		h := &HandshakeResponse{
+			Capabilities:  capabilities | flags,
+			MaxPacketSize: 1 << 24,
+			Charset:       45,
+			Username:      "root",
+			AuthResponse:  []byte("0123456789abcdefghij"),
+			Database:      "test",
+			AuthPlugin:    nativePassword,
+		}
+		if flags&clientConnectAttrs != 0 {
+			h.ConnectAttrs = []byte{4, '_', 'p', 'i', 'd', 1, '1'}
+		}
+		actual, err := ParseHandshakeResponse(h.Encode())
+		require.NoError(t, err)
+		require.Equal(t, h, actual)
+	}
+
+	ssl := appendUint32(nil, capabilities|clientSSL)
+	ssl = append(ssl, make([]byte, 28)...)
+	require.True(t, isSSLRequest(ssl))
+	require.False(t, isSSLRequest(append(ssl, 0)))
+	_, err = ParseHandshakeResponse([]byte{0, 0, 0, 0})
+	require.Error(t, err)
+}
+
+func TestStatusAndError(t *testing.T) {
+	status, ok := parseStatus([]byte{iOK, 1, 0, serverStatusInTrans, 0, 0, 0})
+	require.True(t, ok)
+	require.Equal(t, uint16(serverStatusInTrans), status)
+	status, ok = parseStatus([]byte{iEOF, 0, 0, serverStatusMoreResultsExists, 0})
+	require.True(t, ok)
+	require.Equal(t, uint16(serverStatusMoreResultsExists), status)
+	status, ok = parseStatus([]byte{iEOF, 0, 0, serverStatusAutocommit, 0, 0, 0})
+	require.True(t, ok)
+	require.Equal(t, uint16(serverStatusAutocommit), status)
+	_, ok = parseStatus([]byte{iERR, 0, 0})
+	require.False(t, ok)
+
+	pkt, _, _ := DecodePacket(encodeError(nil, 1, true, 1045, "denied"))
+	code, msg := parseError(pkt.Payload)
+	require.Equal(t, uint16(1045), code)
+	require.Equal(t, "denied", msg)
+}
+
+func TestScramblePassword(t *testing.T) {
+	// the server verifies the native password by SHA1(scramble + stored) XOR response == SHA1(password)
+	response, err := scramblePassword(nativePassword, testScramble, "secret")
+	require.NoError(t, err)
+	stage1 := sha1.Sum([]byte("secret"))
+	stored := sha1.Sum(stage1[:])
+	h := sha1.Sum(append(append([]byte(nil), testScramble...), stored[:]...))
+	for i := range h {
+		h[i] ^= response[i]
+	}
+	require.Equal(t, stage1[:], h[:])
+
+	response, err = scramblePassword(cachingSha2Password, testScramble, "secret")
+	require.NoError(t, err)
+	digest1 := sha256.Sum256([]byte("secret"))
+	digest2 := sha256.Sum256(digest1[:])
+	h2 := sha256.Sum256(append(append([]byte(nil), digest2[:]...), testScramble...))
+	for i := range h2 {
+		h2[i] ^= response[i]
+	}
+	require.True(t, bytes.Equal(digest1[:], h2[:]))
+
+	response, err = scramblePassword(nativePassword, testScramble, "")
+	require.NoError(t, err)
+	require.Empty(t, response)
+	_, err = scramblePassword("sha256_password", testScramble, "secret")
+	require.Error(t, err)
+}
@@ -0,0 +1,796 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mysqlproxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/filter/network/internal/upstream"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/upstream/cluster"
+	"mosn.io/pkg/buffer"
+)
+
+// the phases of the downstream connection
+const (
+	// phaseGreeting waits the initial handshake of the server
+	phaseGreeting = iota
+	// phaseHandshake waits the handshake response of the client
+	phaseHandshake
+	// phaseAuth forwards the authentication packets until the server responds OK
+	phaseAuth
+	// phaseCommand decodes the commands and the responses
+	phaseCommand
+	// phasePassthrough forwards the packets without decoding, such as the TLS connections, the
+	// compressed connections and the replication connections
+	phasePassthrough
+)
+
+// maxLoggedQuery limits the size of the statements in the slow query log
+const maxLoggedQuery = 1024
+
+// proxy is a ReadFilter that proxies a MySQL connection. The handshake and the commands are decoded to
+// record the metrics and the slow queries, the packets are forwarded unchanged. The connection is passed
+// through if the client requests TLS, so the TLS between the client and the server is not terminated.
+//
+// If the read cluster is configured, the proxy authenticates a connection to the read cluster as the client,
+// with the password of the user in the credentials file, and the read only statements out of transactions
+// are sent to it. The read connection is not created for the users not in the file. The statements
+// changing the session state, such as SET and USE, are replayed on the read connection after they succeed
+// on the primary connection.
+type proxy struct {
+	config             *v2.MysqlProxy
+	credentials        map[string]string
+	slowQueryThreshold time.Duration
+	stats              *proxyStats
+	clusterManager     types.ClusterManager
+	readCallbacks      api.ReadFilterCallbacks
+	ctx                context.Context
+
+	mutex   sync.Mutex
+	phase   int
+	closed  bool
+	primary *upstreamConn
+	replica *upstreamConn
+	// capabilities are the capabilities negotiated by the client and the primary server
+	capabilities uint32
+	greeting     *Greeting
+	handshake    *HandshakeResponse
+	database     string
+	// status is the last server status of the primary connection
+	status uint16
+	// pinned sends all the statements to the primary connection, because the session state can not be replayed
+	pinned bool
+	// continued is true if the last packet of the client is continued by the next packet
+	continued bool
+	// request is the command waiting for the response
+	request *request
+}
+
+// request is a command of the client
+type request struct {
+	command  byte
+	stmt     *Statement
+	typ      string
+	query    string
+	start    time.Time
+	upstream *upstreamConn
+	response *response
+	// replay is the command replayed on the read connection if the command succeeds
+	replay []byte
+}
+
+// actions are the writes and the closes done outside the lock
+type actions struct {
+	down      []byte
+	writes    []*upstreamConn
+	data      [][]byte
+	closes    []*upstreamConn
+	closeDown bool
+	downEvent api.ConnectionEvent
+}
+
+func (a *actions) write(uc *upstreamConn, data []byte) {
+	if uc == nil || len(data) == 0 {
+		return
+	}
+	if n := len(a.writes); n > 0 && a.writes[n-1] == uc {
+		a.data[n-1] = append(a.data[n-1], data...)
+		return
+	}
+	a.writes = append(a.writes, uc)
+	a.data = append(a.data, append([]byte(nil), data...))
+}
+
+func (a *actions) close(uc *upstreamConn) {
+	if uc != nil {
+		a.closes = append(a.closes, uc)
+	}
+}
+
+func (a *actions) closeDownstream(event api.ConnectionEvent) {
+	a.closeDown = true
+	a.downEvent = event
+}
+
+func newProxy(ctx context.Context, config *v2.MysqlProxy, credentials map[string]string) *proxy {
+	return &proxy{
+		config:             config,
+		credentials:        credentials,
+		slowQueryThreshold: config.SlowQueryThreshold.Duration,
+		stats:              getProxyStats(config.StatPrefix),
+		clusterManager:     cluster.GetClusterMngAdapterInstance().ClusterManager,
+		ctx:                ctx,
+	}
+}
+
+func (p *proxy) OnNewConnection() api.FilterStatus {
+	p.mutex.Lock()
+	uc, err := p.createUpstream(p.config.Cluster, false)
+	p.primary = uc
+	p.mutex.Unlock()
+	if err != nil {
+		log.DefaultLogger.Errorf("[mysql proxy] connect upstream failed: %v", err)
+		// the server can send an ERR packet instead of the initial handshake
+		p.writeDownstream(encodeError(nil, 0, false, erUnknownError, err.Error()))
+		p.readCallbacks.Connection().Close(api.FlushWrite, api.LocalClose)
+		return api.Stop
+	}
+	p.readCallbacks.SetUpstreamHost(uc.conn.Host())
+	return api.Continue
+}
+
+func (p *proxy) InitializeReadFilterCallbacks(cb api.ReadFilterCallbacks) {
+	p.readCallbacks = cb
+	p.readCallbacks.Connection().AddConnectionEventListener(p)
+}
+
+func (p *proxy) OnData(buf buffer.IoBuffer) api.FilterStatus {
+	a := &actions{}
+	p.mutex.Lock()
+	for !p.closed && p.primary != nil && buf.Len() > 0 {
+		if p.phase == phasePassthrough {
+			a.write(p.primary, buf.Bytes())
+			buf.Drain(buf.Len())
+			break
+		}
+		pkt, n, err := DecodePacket(buf.Bytes())
+		if err != nil {
+			break
+		}
+		p.handleDownstream(pkt, a)
+		buf.Drain(n)
+	}
+	if p.closed {
+		buf.Drain(buf.Len())
+	}
+	p.mutex.Unlock()
+	p.do(a)
+	return api.Stop
+}
+
+// do does the actions outside the lock
+func (p *proxy) do(a *actions) {
+	if len(a.down) > 0 {
+		p.writeDownstream(a.down)
+	}
+	for i, uc := range a.writes {
+		uc.write(a.data[i])
+	}
+	// the connections are not reused, because the sessions are authenticated as the clients
+	for _, uc := range a.closes {
+		uc.conn.Release(false)
+	}
+	if a.closeDown {
+		p.readCallbacks.Connection().Close(api.FlushWrite, a.downEvent)
+	}
+}
+
+func (p *proxy) writeDownstream(data []byte) {
+	if err := p.readCallbacks.Connection().Write(buffer.NewIoBufferBytes(data)); err != nil {
+		log.DefaultLogger.Errorf("[mysql proxy] write to downstream failed: %v", err)
+	}
+}
+
+// handleDownstream handles a packet sent by the client
+func (p *proxy) handleDownstream(pkt *Packet, a *actions) {
+	continued := p.continued
+	p.continued = len(pkt.Payload) == maxPayloadSize
+	switch p.phase {
+	case phaseHandshake:
+		p.handleHandshake(pkt, a)
+		return
+	case phaseCommand:
+		if continued || p.request != nil {
+			// the continuation of a large command, or the content of the LOCAL INFILE request
+			target := p.primary
+			if p.request != nil {
+				target = p.request.upstream
+			}
+			a.write(target, pkt.Raw)
+			return
+		}
+		p.handleCommand(pkt, a)
+		return
+	}
+	a.write(p.primary, pkt.Raw)
+}
+
+func (p *proxy) handleHandshake(pkt *Packet, a *actions) {
+	a.write(p.primary, pkt.Raw)
+	if isSSLRequest(pkt.Payload) {
+		p.stats.TLSPassthrough.Inc(1)
+		p.passthrough(a)
+		return
+	}
+	h, err := ParseHandshakeResponse(pkt.Payload)
+	if err != nil {
+		log.DefaultLogger.Errorf("[mysql proxy] parse handshake response failed: %v", err)
+		p.stats.ProtocolError.Inc(1)
+		p.passthrough(a)
+		return
+	}
+	p.handshake = h
+	p.capabilities = h.Capabilities & p.greeting.Capabilities
+	if p.capabilities&clientCompress != 0 {
+		p.passthrough(a)
+		return
+	}
+	p.database = h.Database
+	p.phase = phaseAuth
+}
+
+// passthrough stops decoding the packets, the read connection is not used anymore
+func (p *proxy) passthrough(a *actions) {
+	p.phase = phasePassthrough
+	p.dropReplica(a)
+}
+
+// queryText returns the statement of the COM_QUERY payload, the query attributes are skipped
+func queryText(payload []byte, capabilities uint32) (string, bool) {
+	q := payload[1:]
+	if capabilities&clientQueryAttributes != 0 {
+		r := &reader{b: q}
+		params := r.lenenc()
+		r.lenenc()
+		if r.err != nil || params > 0 {
+			return "", false
+		}
+		q = r.b
+	}
+	return string(q), true
+}
+
+func (p *proxy) handleCommand(pkt *Packet, a *actions) {
+	if len(pkt.Payload) == 0 {
+		p.stats.ProtocolError.Inc(1)
+		a.write(p.primary, pkt.Raw)
+		return
+	}
+	req := &request{
+		command:  pkt.Payload[0],
+		typ:      unknownType,
+		start:    time.Now(),
+		upstream: p.primary,
+	}
+	switch req.command {
+	case comQuery:
+		req.stmt = &Statement{Type: unknownType}
+		if q, ok := queryText(pkt.Payload, p.capabilities); ok {
+			req.query = q
+			req.stmt = ParseStatement(q)
+			if p.continued {
+				// the statement is truncated, it is sent to the primary connection
+				req.stmt.ReadOnly, req.stmt.Session = false, false
+			}
+		}
+		req.typ = req.stmt.Type
+		if req.stmt.Session {
+			req.replay = pkt.Raw
+		}
+		if req.stmt.Pin {
+			p.pin(a)
+		}
+		if req.stmt.ReadOnly && p.canReadSplit() {
+			req.upstream = p.replica
+			p.stats.ReadSplit.Inc(1)
+		}
+	case comInitDB:
+		req.query = string(pkt.Payload[1:])
+		req.replay = pkt.Raw
+	case comResetConnection:
+		req.replay = pkt.Raw
+	case comChangeUser:
+		// the new user is authenticated like the handshake
+		p.pin(a)
+		p.phase = phaseAuth
+	case comBinlogDump, comBinlogDumpGTID, comRegisterSlave:
+		p.passthrough(a)
+	}
+	if typ, ok := commandTypes[req.command]; ok {
+		req.typ = typ
+	}
+	if p.continued {
+		req.replay = nil
+	}
+	a.write(req.upstream, pkt.Raw)
+	if p.phase != phaseCommand || !expectResponse(req.command) {
+		p.recordRequest(req, false, 0)
+		return
+	}
+	req.response = newResponse(req.command, p.capabilities)
+	p.request = req
+}
+
+// canReadSplit returns true if the read only statements can be sent to the read connection
+func (p *proxy) canReadSplit() bool {
+	return p.replica != nil && p.replica.idle() && !p.pinned &&
+		p.replica.database == p.database &&
+		p.status&serverStatusAutocommit != 0 && p.status&serverStatusInTrans == 0
+}
+
+// pin sends all the following statements to the primary connection
+func (p *proxy) pin(a *actions) {
+	p.pinned = true
+	p.dropReplica(a)
+}
+
+// complete completes the request after the response ends
+func (p *proxy) complete(req *request, a *actions) {
+	p.request = nil
+	resp := req.response
+	if req.upstream == p.primary && resp.HasStatus {
+		p.status = resp.Status
+	}
+	if !resp.Failed {
+		switch {
+		case req.command == comInitDB:
+			p.database = req.query
+		case req.stmt != nil && req.stmt.Database != "":
+			p.database = req.stmt.Database
+		}
+		if req.replay != nil && req.upstream == p.primary && p.replica != nil {
+			p.replica.enqueue(req.replay, p.database, a)
+		}
+	}
+	p.recordRequest(req, resp.Failed, time.Since(req.start))
+}
+
+// recordRequest records the metrics and the slow query log of the request
+func (p *proxy) recordRequest(req *request, failed bool, cost time.Duration) {
+	stats := getQueryStats(p.config.StatPrefix, req.typ)
+	stats.QueryTotal.Inc(1)
+	if failed {
+		stats.QueryError.Inc(1)
+	}
+	stats.QueryTime.Update(cost.Nanoseconds())
+	if p.config.TableStats && req.stmt != nil {
+		for _, table := range req.stmt.Tables {
+			ts := getTableStats(p.config.StatPrefix, table, req.typ)
+			ts.QueryTotal.Inc(1)
+			if failed {
+				ts.QueryError.Inc(1)
+			}
+		}
+	}
+	if p.slowQueryThreshold > 0 && cost >= p.slowQueryThreshold {
+		p.stats.SlowQuery.Inc(1)
+		query := req.query
+		if len(query) > maxLoggedQuery {
+			query = query[:maxLoggedQuery] + "..."
+		}
+		user := ""
+		if p.handshake != nil {
+			user = p.handshake.Username
+		}
+		log.DefaultLogger.Warnf("[mysql proxy] slow query, cost: %v, type: %s, user: %s, database: %s, upstream: %s, query: %s",
+			cost, req.typ, user, p.database, req.upstream.conn.Host().AddressString(), query)
+	}
+}
+
+// handleUpstream handles a packet sent by the primary connection
+func (p *proxy) handleUpstream(uc *upstreamConn, pkt *Packet, a *actions) error {
+	continued := uc.continued
+	uc.continued = len(pkt.Payload) == maxPayloadSize
+	switch p.phase {
+	case phaseGreeting:
+		g, err := ParseGreeting(pkt.Payload)
+		if err != nil {
+			// the ERR packet refusing the connection, or an unsupported protocol
+			if len(pkt.Payload) == 0 || pkt.Payload[0] != iERR {
+				p.stats.ProtocolError.Inc(1)
+			}
+			p.passthrough(a)
+			break
+		}
+		p.greeting = g
+		p.status = g.Status
+		if g.Capabilities&clientProtocol41 == 0 {
+			p.passthrough(a)
+			break
+		}
+		p.phase = phaseHandshake
+	case phaseAuth:
+		if continued || len(pkt.Payload) == 0 {
+			break
+		}
+		switch pkt.Payload[0] {
+		case iOK:
+			p.stats.LoginSuccess.Inc(1)
+			if status, ok := parseStatus(pkt.Payload); ok {
+				p.status = status
+			}
+			p.phase = phaseCommand
+			if p.config.ReadCluster != "" && !p.pinned && p.replica == nil {
+				if _, ok := p.credentials[p.handshake.Username]; ok {
+					p.connectReplica()
+				}
+			}
+		case iERR:
+			p.stats.LoginFailure.Inc(1)
+		}
+	case phaseCommand:
+		req := p.request
+		if continued || req == nil || req.upstream != uc {
+			break
+		}
+		done, err := req.response.Feed(pkt.Payload)
+		if err != nil {
+			a.down = append(a.down, pkt.Raw...)
+			return err
+		}
+		if done {
+			p.complete(req, a)
+		}
+	}
+	a.down = append(a.down, pkt.Raw...)
+	return nil
+}
+
+// connectReplica creates the read connection, the read only statements are sent to the primary connection
+// until the read connection is authenticated
+func (p *proxy) connectReplica() {
+	uc, err := p.createUpstream(p.config.ReadCluster, true)
+	if err != nil {
+		log.DefaultLogger.Errorf("[mysql proxy] connect read cluster failed: %v", err)
+		p.stats.ReadSplitFailed.Inc(1)
+		return
+	}
+	p.replica = uc
+}
+
+// dropReplica closes the read connection, the request sent to it is failed
+func (p *proxy) dropReplica(a *actions) {
+	uc := p.replica
+	if uc == nil {
+		return
+	}
+	p.replica = nil
+	if p.removeUpstream(uc) {
+		a.close(uc)
+	}
+	if p.request != nil && p.request.upstream == uc {
+		// the response is not complete, the connection is closed to avoid the client waiting forever
+		p.request = nil
+		a.closeDownstream(api.LocalClose)
+	}
+}
+
+// failReplica drops the read connection because of the error
+func (p *proxy) failReplica(err error, a *actions) {
+	log.DefaultLogger.Errorf("[mysql proxy] read connection failed: %v", err)
+	p.stats.ReadSplitFailed.Inc(1)
+	p.dropReplica(a)
+}
+
+// createUpstream leases a connection of the cluster, the connection is connected asynchronously
+// and the packets are sent when it is connected.
+func (p *proxy) createUpstream(clusterName string, replica bool) (*upstreamConn, error) {
+	uc := &upstreamConn{
+		proxy:   p,
+		replica: replica,
+	}
+	conn, err := upstream.Connect(p.ctx, p.clusterManager, clusterName, p.readCallbacks, upstreamProtocol, uc)
+	if err != nil {
+		return nil, err
+	}
+	uc.conn = conn
+	return uc, nil
+}
+
+// removeUpstream marks the upstream connection closed, it returns false if it is removed
+func (p *proxy) removeUpstream(uc *upstreamConn) bool {
+	if uc.closed {
+		return false
+	}
+	uc.closed = true
+	return true
+}
+
+// OnEvent handles the downstream connection events
+func (p *proxy) OnEvent(event api.ConnectionEvent) {
+	if !event.IsClose() {
+		return
+	}
+	a := &actions{}
+	p.mutex.Lock()
+	p.closed = true
+	if p.primary != nil && p.removeUpstream(p.primary) {
+		a.close(p.primary)
+	}
+	p.request = nil
+	p.dropReplica(a)
+	p.mutex.Unlock()
+
+	a.closeDown = false
+	p.do(a)
+}
+
+// the phases of the read connection
+const (
+	replicaGreeting = iota
+	replicaAuth
+	replicaReady
+)
+
+// upstreamConn is a connection to the primary cluster or the read cluster
+type upstreamConn struct {
+	proxy   *proxy
+	conn    *upstream.Conn
+	replica bool
+	closed  bool
+	// continued is true if the last packet is continued by the next packet
+	continued bool
+
+	// the fields of the read connection
+	phase      int
+	authPlugin string
+	scramble   []byte
+	// database is the database of the read connection
+	database string
+	// replays are the commands replayed on the read connection, and replaying is the response of the first one
+	replays   [][]byte
+	databases []string
+	replaying *response
+}
+
+func (uc *upstreamConn) write(data []byte) {
+	if err := uc.conn.Write(data); err != nil {
+		log.DefaultLogger.Errorf("[mysql proxy] write to upstream %s failed: %v", uc.conn.Host().AddressString(), err)
+	}
+}
+
+// idle returns true if the read connection is authenticated and is not replaying the commands
+func (uc *upstreamConn) idle() bool {
+	return uc.phase == replicaReady && uc.replaying == nil
+}
+
+// enqueue replays the command on the read connection, the database is the database after the command
+func (uc *upstreamConn) enqueue(command []byte, database string, a *actions) {
+	uc.replays = append(uc.replays, append([]byte(nil), command...))
+	uc.databases = append(uc.databases, database)
+	uc.replayNext(a)
+}
+
+func (uc *upstreamConn) replayNext(a *actions) {
+	if uc.phase != replicaReady || uc.replaying != nil || len(uc.replays) == 0 {
+		return
+	}
+	command := uc.replays[0]
+	uc.replaying = newResponse(command[headerSize], uc.proxy.capabilities)
+	a.write(uc, command)
+}
+
+// handleReplica handles a packet sent by the read connection
+func (uc *upstreamConn) handleReplica(pkt *Packet, a *actions) error {
+	p := uc.proxy
+	continued := uc.continued
+	uc.continued = len(pkt.Payload) == maxPayloadSize
+	if continued || len(pkt.Payload) == 0 {
+		if req := p.request; req != nil && req.upstream == uc {
+			a.down = append(a.down, pkt.Raw...)
+		}
+		return nil
+	}
+	switch uc.phase {
+	case replicaGreeting:
+		return uc.handleGreeting(pkt, a)
+	case replicaAuth:
+		return uc.handleAuth(pkt, a)
+	}
+	if r := uc.replaying; r != nil {
+		done, err := r.Feed(pkt.Payload)
+		if err != nil {
+			return err
+		}
+		if !done {
+			return nil
+		}
+		if r.Failed {
+			code, msg := parseError(pkt.Payload)
+			return fmt.Errorf("replay failed: %d %s", code, msg)
+		}
+		uc.database = uc.databases[0]
+		uc.replays, uc.databases = uc.replays[1:], uc.databases[1:]
+		uc.replaying = nil
+		uc.replayNext(a)
+		return nil
+	}
+	req := p.request
+	if req == nil || req.upstream != uc {
+		return errors.New("unexpected packet")
+	}
+	a.down = append(a.down, pkt.Raw...)
+	done, err := req.response.Feed(pkt.Payload)
+	if err != nil {
+		return err
+	}
+	if done {
+		p.complete(req, a)
+	}
+	return nil
+}
+
+// handleGreeting responds the handshake of the read connection as the client
+func (uc *upstreamConn) handleGreeting(pkt *Packet, a *actions) error {
+	p := uc.proxy
+	g, err := ParseGreeting(pkt.Payload)
+	if err != nil {
+		if len(pkt.Payload) > 0 && pkt.Payload[0] == iERR {
+			code, msg := parseError(pkt.Payload)
+			return fmt.Errorf("connection refused: %d %s", code, msg)
+		}
+		return err
+	}
+	required := clientProtocol41 | clientSecureConnection | p.capabilities&formatCapabilities
+	if g.Capabilities&required != required {
+		return fmt.Errorf("capabilities %x are not supported by %s", required&^g.Capabilities, g.ServerVersion)
+	}
+	uc.authPlugin = nativePassword
+	if g.Capabilities&clientPluginAuth != 0 && g.AuthPlugin == cachingSha2Password {
+		uc.authPlugin = g.AuthPlugin
+	}
+	uc.scramble = g.AuthData
+	auth, err := scramblePassword(uc.authPlugin, uc.scramble, p.credentials[p.handshake.Username])
+	if err != nil {
+		return err
+	}
+	h := &HandshakeResponse{
+		Capabilities:  p.capabilities&formatCapabilities | clientProtocol41 | clientSecureConnection | clientPluginAuth,
+		MaxPacketSize: p.handshake.MaxPacketSize,
+		Charset:       p.handshake.Charset,
+		Username:      p.handshake.Username,
+		AuthResponse:  auth,
+		AuthPlugin:    uc.authPlugin,
+	}
+	h.Capabilities &= g.Capabilities
+	if p.database != "" {
+		h.Capabilities |= clientConnectWithDB
+		h.Database = p.database
+	}
+	uc.database = p.database
+	uc.phase = replicaAuth
+	a.write(uc, EncodePacket(nil, pkt.Seq+1, h.Encode()))
+	return nil
+}
+
+// handleAuth handles the authentication result of the read connection
+func (uc *upstreamConn) handleAuth(pkt *Packet, a *actions) error {
+	p := uc.proxy
+	switch pkt.Payload[0] {
+	case iOK:
+		uc.phase = replicaReady
+		uc.replayNext(a)
+		return nil
+	case iERR:
+		code, msg := parseError(pkt.Payload)
+		return fmt.Errorf("authentication failed: %d %s", code, msg)
+	case iEOF:
+		// the auth switch request
+		r := &reader{b: pkt.Payload[1:]}
+		uc.authPlugin = r.nulString()
+		uc.scramble = bytes.TrimRight(r.b, "\x00")
+		auth, err := scramblePassword(uc.authPlugin, uc.scramble, p.credentials[p.handshake.Username])
+		if err != nil {
+			return err
+		}
+		a.write(uc, EncodePacket(nil, pkt.Seq+1, auth))
+		return nil
+	case iAuthMore:
+		// the fast authentication of caching_sha2_password succeeds, and the OK packet follows
+		if len(pkt.Payload) == 2 && pkt.Payload[1] == 0x03 {
+			return nil
+		}
+		return errors.New("full authentication is not supported, the users should use mysql_native_password")
+	}
+	return errMalformed
+}
+
+func (uc *upstreamConn) OnData(conn *upstream.Conn, buf buffer.IoBuffer) {
+	p := uc.proxy
+	a := &actions{}
+	p.mutex.Lock()
+	for !uc.closed && buf.Len() > 0 {
+		if !uc.replica && p.phase == phasePassthrough {
+			a.down = append(a.down, buf.Bytes()...)
+			buf.Drain(buf.Len())
+			break
+		}
+		pkt, n, err := DecodePacket(buf.Bytes())
+		if err != nil {
+			break
+		}
+		buf.Drain(n)
+		if uc.replica {
+			err = uc.handleReplica(pkt, a)
+			if err != nil {
+				p.failReplica(err, a)
+			}
+			continue
+		}
+		if err = p.handleUpstream(uc, pkt, a); err != nil {
+			log.DefaultLogger.Errorf("[mysql proxy] decode response from %s failed: %v", conn.Host().AddressString(), err)
+			p.stats.ProtocolError.Inc(1)
+			p.request = nil
+			p.passthrough(a)
+		}
+	}
+	if uc.closed {
+		buf.Drain(buf.Len())
+	}
+	if p.closed {
+		a.down = nil
+		a.closeDown = false
+	}
+	p.mutex.Unlock()
+	p.do(a)
+}
+
+// OnClose handles the upstream connection closed by the server or failed to connect
+func (uc *upstreamConn) OnClose(conn *upstream.Conn, event api.ConnectionEvent) {
+	p := uc.proxy
+	a := &actions{}
+	p.mutex.Lock()
+	removed := p.removeUpstream(uc)
+	if uc.replica {
+		if p.replica == uc {
+			p.failReplica(fmt.Errorf("connection closed: %s", event), a)
+		}
+	} else if removed && !p.closed {
+		if event.ConnectFailure() && p.phase == phaseGreeting {
+			log.DefaultLogger.Errorf("[mysql proxy] connect upstream %s failed", conn.Host().AddressString())
+			// the server can send an ERR packet instead of the initial handshake
+			a.down = encodeError(a.down, 0, false, erUnknownError, "connect upstream failed")
+		}
+		// the server closes the connection, the packets sent by the server are flushed to the client
+		a.closeDownstream(api.RemoteClose)
+	}
+	if p.closed {
+		a.closeDown = false
+	}
+	p.mutex.Unlock()
+	p.do(a)
+}
@@ -0,0 +1,435 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mysqlproxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/filter/network/internal/proxytest"
+)
+
+const testCapabilities = clientProtocol41 | clientSecureConnection | clientPluginAuth | clientConnectWithDB |
+	clientDeprecateEOF | clientMultiResults | clientSSL
+
+// fakeMysql is a MySQL server which responds a row for SELECT and OK for the other statements
+type fakeMysql struct {
+	*proxytest.Server
+	username string
+	password string
+	mutex    sync.Mutex
+	queries  []string
+}
+
+func newFakeMysql(t *testing.T, username, password string) *fakeMysql {
+	s := &fakeMysql{
+		username: username,
+		password: password,
+	}
+	s.Server = proxytest.NewServer(t, s.serve)
+	return s
+}
+
+func (s *fakeMysql) received() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string(nil), s.queries...)
+}
+
+func readPacket(conn net.Conn) (*Packet, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+	payload := make([]byte, int(header[0])|int(header[1])<<8|int(header[2])<<16)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return nil, err
+	}
+	return &Packet{Seq: header[3], Payload: payload}, nil
+}
+
+func okStatus(status uint16) []byte {
+	return []byte{iOK, 0, 0, byte(status), byte(status >> 8), 0, 0}
+}
+
+func (s *fakeMysql) serve(conn net.Conn) {
+	defer conn.Close()
+	conn.Write(EncodePacket(nil, 0, encodeGreeting(testCapabilities, serverStatusAutocommit, nativePassword)))
+	pkt, err := readPacket(conn)
+	if err != nil {
+		return
+	}
+	if isSSLRequest(pkt.Payload) {
+		// the TLS handshake is not supported, the data is echoed
+		io.Copy(conn, conn)
+		return
+	}
+	h, err := ParseHandshakeResponse(pkt.Payload)
+	if err != nil {
+		return
+	}
+	expected, _ := scramblePassword(nativePassword, testScramble, s.password)
+	if h.Username != s.username || !bytes.Equal(h.AuthResponse, expected) {
+		conn.Write(encodeError(nil, 2, true, 1045, "Access denied"))
+		return
+	}
+	status := uint16(serverStatusAutocommit)
+	conn.Write(EncodePacket(nil, 2, okStatus(status)))
+	capabilities := h.Capabilities & testCapabilities
+	for {
+		pkt, err := readPacket(conn)
+		if err != nil || len(pkt.Payload) == 0 || pkt.Payload[0] == comQuit {
+			return
+		}
+		query := string(pkt.Payload[1:])
+		if pkt.Payload[0] != comQuery {
+			query = commandTypes[pkt.Payload[0]] + " " + query
+		}
+		s.mutex.Lock()
+		s.queries = append(s.queries, query)
+		s.mutex.Unlock()
+
+		q := strings.ToLower(query)
+		var out []byte
+		switch {
+		case strings.Contains(q, "error"):
+			out = encodeError(out, 1, true, 1064, "syntax error")
+		case strings.HasPrefix(q, "begin"):
+			status |= serverStatusInTrans
+			out = EncodePacket(out, 1, okStatus(status))
+		case strings.HasPrefix(q, "commit"):
+			status &^= serverStatusInTrans
+			out = EncodePacket(out, 1, okStatus(status))
+		case strings.HasPrefix(q, "select"):
+			if strings.Contains(q, "sleep") {
+				time.Sleep(50 * time.Millisecond)
+			}
+			out = EncodePacket(out, 1, []byte{1})
+			out = EncodePacket(out, 2, []byte{3, 'd', 'e', 'f'})
+			seq := byte(3)
+			if capabilities&clientDeprecateEOF == 0 {
+				out = EncodePacket(out, seq, []byte{iEOF, 0, 0, byte(status), byte(status >> 8)})
+				seq++
+			}
+			out = EncodePacket(out, seq, []byte{1, '1'})
+			if capabilities&clientDeprecateEOF == 0 {
+				out = EncodePacket(out, seq+1, []byte{iEOF, 0, 0, byte(status), byte(status >> 8)})
+			} else {
+				out = EncodePacket(out, seq+1, []byte{iEOF, 0, 0, byte(status), byte(status >> 8), 0, 0})
+			}
+		default:
+			out = EncodePacket(out, 1, okStatus(status))
+		}
+		conn.Write(out)
+	}
+}
+
+// testClient is a downstream connection of the proxy
+type testClient struct {
+	*proxytest.Client
+	t     *testing.T
+	proxy *proxy
+}
+
+func newTestClient(t *testing.T, ctrl *gomock.Controller, config *v2.MysqlProxy, credentials map[string]string) *testClient {
+	p := newProxy(context.Background(), config, credentials)
+	return &testClient{
+		Client: proxytest.NewClient(t, ctrl, p, decodePacket),
+		t:      t,
+		proxy:  p,
+	}
+}
+
+func decodePacket(data []byte) (int, error) {
+	_, n, err := DecodePacket(data)
+	if err == errIncomplete {
+		return 0, nil
+	}
+	return n, err
+}
+
+func toPackets(t *testing.T, messages [][]byte) []*Packet {
+	var packets []*Packet
+	for _, msg := range messages {
+		pkt, _, err := DecodePacket(msg)
+		require.NoError(t, err)
+		packets = append(packets, pkt)
+	}
+	return packets
+}
+
+// send sends the data and returns the received packets
+func (c *testClient) send(data []byte, count int) []*Packet {
+	return toPackets(c.t, c.Send(data, count))
+}
+
+func (c *testClient) read(count int) []*Packet {
+	return toPackets(c.t, c.Read(count))
+}
+
+// login reads the greeting and authenticates the client
+func (c *testClient) login(username, password, database string, capabilities uint32) *Packet {
+	greeting := c.read(1)[0]
+	g, err := ParseGreeting(greeting.Payload)
+	require.NoError(c.t, err)
+	auth, _ := scramblePassword(nativePassword, g.AuthData, password)
+	h := &HandshakeResponse{
+		Capabilities:  capabilities,
+		MaxPacketSize: 1 << 24,
+		Charset:       45,
+		Username:      username,
+		AuthResponse:  auth,
+		Database:      database,
+		AuthPlugin:    nativePassword,
+	}
+	return c.send(EncodePacket(nil, 1, h.Encode()), 1)[0]
+}
+
+// query sends the statement, and returns the number of the response packets
+func (c *testClient) query(sql string, count int) []*Packet {
+	return c.send(EncodePacket(nil, 0, append([]byte{comQuery}, sql...)), count)
+}
+
+func (c *testClient) waitReplica() {
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		c.proxy.mutex.Lock()
+		ready := c.proxy.canReadSplit()
+		c.proxy.mutex.Unlock()
+		if ready {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	c.t.Fatal("the read connection is not ready")
+}
+
+func setupClusters(t *testing.T, clusters map[string]*fakeMysql) {
+	addrs := map[string][]string{}
+	for name, s := range clusters {
+		addrs[name] = []string{s.Addr()}
+	}
+	proxytest.SetupClusters(addrs)
+}
+
+func resetStats() {
+	proxytest.ResetStats(&proxyStatsCache, &queryStatsCache, &tableStatsCache)
+}
+
+func TestMysqlProxy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	primary := newFakeMysql(t, "app", "secret")
+	defer primary.Close()
+	resetStats()
+	setupClusters(t, map[string]*fakeMysql{"mysql": primary})
+	defer proxytest.Cleanup()
+
+	config := &v2.MysqlProxy{
+		StatPrefix:         "test_mysql",
+		Cluster:            "mysql",
+		SlowQueryThreshold: api.DurationConfig{Duration: 30 * time.Millisecond},
+		TableStats:         true,
+	}
+	for _, capabilities := range []uint32{clientProtocol41 | clientSecureConnection | clientPluginAuth | clientConnectWithDB,
+		clientProtocol41 | clientSecureConnection | clientPluginAuth | clientDeprecateEOF} {
+		c := newTestClient(t, ctrl, config, nil)
+		require.Equal(t, byte(iOK), c.login("app", "secret", "test", capabilities).Payload[0])
+		count := 5
+		if capabilities&clientDeprecateEOF != 0 {
+			count = 4
+		}
+		packets := c.query("SELECT * FROM users", count)
+		require.Equal(t, []byte{1, '1'}, packets[count-2].Payload)
+		c.query("select sleep(1) from users", count)
+		c.query("insert into orders values (1)", 1)
+		require.Equal(t, byte(iERR), c.query("error", 1)[0].Payload[0])
+		require.Equal(t, byte(iOK), c.send(EncodePacket(nil, 0, []byte{comPing}), 1)[0].Payload[0])
+		// COM_STMT_CLOSE has no response
+		c.send(EncodePacket(nil, 0, []byte{comStmtClose, 1, 0, 0, 0}), 0)
+		require.Equal(t, byte(iOK), c.send(EncodePacket(nil, 0, append([]byte{comInitDB}, "db2"...)), 1)[0].Payload[0])
+		require.Equal(t, "db2", c.proxy.database)
+		c.send(EncodePacket(nil, 0, []byte{comQuit}), 0)
+	}
+
+	stats := getProxyStats("test_mysql")
+	require.Equal(t, int64(2), stats.LoginSuccess.Count())
+	require.Equal(t, int64(2), stats.SlowQuery.Count())
+	require.Equal(t, int64(4), getQueryStats("test_mysql", "select").QueryTotal.Count())
+	require.Equal(t, int64(2), getQueryStats("test_mysql", "insert").QueryTotal.Count())
+	require.Equal(t, int64(2), getQueryStats("test_mysql", unknownType).QueryError.Count())
+	require.Equal(t, int64(2), getQueryStats("test_mysql", "ping").QueryTotal.Count())
+	require.Equal(t, int64(2), getQueryStats("test_mysql", "stmt_close").QueryTotal.Count())
+	require.Equal(t, int64(2), getQueryStats("test_mysql", "quit").QueryTotal.Count())
+	require.Equal(t, int64(4), getTableStats("test_mysql", "users", "select").QueryTotal.Count())
+	require.Equal(t, int64(2), getTableStats("test_mysql", "orders", "insert").QueryTotal.Count())
+
+	// the login failure
+	c := newTestClient(t, ctrl, config, nil)
+	require.Equal(t, byte(iERR), c.login("app", "wrong", "", clientProtocol41|clientSecureConnection|clientPluginAuth).Payload[0])
+	require.Equal(t, int64(1), stats.LoginFailure.Count())
+
+	// the TLS connection is passed through
+	c = newTestClient(t, ctrl, config, nil)
+	c.read(1)
+	ssl := appendUint32(nil, clientProtocol41|clientSSL)
+	ssl = append(ssl, make([]byte, 28)...)
+	c.send(EncodePacket(nil, 1, ssl), 0)
+	data := []byte("\x16\x03\x01tls handshake")
+	c.send(data, 0)
+	require.Eventually(t, func() bool {
+		return bytes.Equal(data, c.Received())
+	}, 3*time.Second, 5*time.Millisecond)
+	require.Equal(t, int64(1), stats.TLSPassthrough.Count())
+	c.proxy.OnEvent(api.RemoteClose)
+}
+
+func TestMysqlProxyReadSplit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	// the replica has the same users as the primary
+	primary, replica := newFakeMysql(t, "app", "secret"), newFakeMysql(t, "app", "secret")
+	defer primary.Close()
+	defer replica.Close()
+	resetStats()
+	setupClusters(t, map[string]*fakeMysql{"mysql": primary, "mysql_read": replica})
+	defer proxytest.Cleanup()
+
+	config := &v2.MysqlProxy{
+		StatPrefix:  "test_split",
+		Cluster:     "mysql",
+		ReadCluster: "mysql_read",
+	}
+	capabilities := uint32(clientProtocol41 | clientSecureConnection | clientPluginAuth | clientConnectWithDB | clientDeprecateEOF)
+	c := newTestClient(t, ctrl, config, map[string]string{"app": "secret"})
+	require.Equal(t, byte(iOK), c.login("app", "secret", "test", capabilities).Payload[0])
+	c.waitReplica()
+
+	c.query("select * from t", 4)
+	c.query("select * from t for update", 4)
+	c.query("SET NAMES utf8mb4", 1)
+	c.query("use db2", 1)
+	c.waitReplica()
+	require.Equal(t, "db2", c.proxy.replica.database)
+	c.query("select 1", 4)
+	// the statements in the transaction are sent to the primary connection
+	c.query("begin", 1)
+	c.query("select 2", 4)
+	c.query("commit", 1)
+	c.query("select 3", 4)
+	// the error of the read connection is responded to the client
+	require.Equal(t, byte(iERR), c.query("select error", 1)[0].Payload[0])
+
+	require.Equal(t, []string{"select * from t", "SET NAMES utf8mb4", "use db2", "select 1", "select 3", "select error"}, replica.received())
+	require.Equal(t, []string{"select * from t for update", "SET NAMES utf8mb4", "use db2", "begin", "select 2", "commit"}, primary.received())
+	stats := getProxyStats("test_split")
+	require.Equal(t, int64(4), stats.ReadSplit.Count())
+	require.Equal(t, int64(1), getQueryStats("test_split", "select").QueryError.Count())
+
+	// the session is pinned to the primary connection after LOCK TABLES
+	c.query("lock tables t read", 1)
+	require.Nil(t, c.proxy.replica)
+	c.query("select 4", 4)
+	require.Equal(t, "select 4", primary.received()[7])
+	c.proxy.OnEvent(api.RemoteClose)
+
+	// the read connection fails to authenticate, the statements are sent to the primary connection
+	c = newTestClient(t, ctrl, config, map[string]string{"app": "wrong"})
+	require.Equal(t, byte(iOK), c.login("app", "secret", "", capabilities).Payload[0])
+	require.Eventually(t, func() bool {
+		return stats.ReadSplitFailed.Count() == 1
+	}, 3*time.Second, 5*time.Millisecond)
+	c.query("select 5", 4)
+	require.Equal(t, "select 5", primary.received()[8])
+	c.proxy.OnEvent(api.RemoteClose)
+
+	// the read connection is not created for the users without credentials
+	c = newTestClient(t, ctrl, config, map[string]string{"other": "secret"})
+	require.Equal(t, byte(iOK), c.login("app", "secret", "", capabilities).Payload[0])
+	c.query("select 6", 4)
+	require.Equal(t, "select 6", primary.received()[9])
+	c.proxy.mutex.Lock()
+	require.Nil(t, c.proxy.replica)
+	c.proxy.mutex.Unlock()
+	require.Equal(t, int64(1), stats.ReadSplitFailed.Count())
+	c.proxy.OnEvent(api.RemoteClose)
+}
+
+func TestMysqlProxyNoUpstream(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	resetStats()
+	// the server of the cluster is down
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ln.Close()
+	proxytest.SetupClusters(map[string][]string{"mysql_down": {ln.Addr().String()}})
+	defer proxytest.Cleanup()
+
+	for _, cluster := range []string{"mysql_missing", "mysql_down"} {
+		c := newTestClient(t, ctrl, &v2.MysqlProxy{Cluster: cluster}, nil)
+		pkt := c.read(1)[0]
+		require.Equal(t, byte(iERR), pkt.Payload[0])
+		require.Eventually(t, c.IsClosed, time.Second, 10*time.Millisecond)
+	}
+}
+
+func TestParseMysqlProxy(t *testing.T) {
+	p, err := ParseMysqlProxy(map[string]interface{}{
+		"cluster":               "mysql",
+		"read_cluster":          "mysql_read",
+		"read_credentials_file": "credentials.json",
+		"slow_query_threshold":  "1s",
+	})
+	require.NoError(t, err)
+	require.Equal(t, time.Second, p.SlowQueryThreshold.Duration)
+	_, err = ParseMysqlProxy(map[string]interface{}{})
+	require.Error(t, err)
+	_, err = ParseMysqlProxy(map[string]interface{}{"cluster": "mysql", "read_cluster": "mysql_read"})
+	require.Error(t, err)
+}
+
+func TestLoadCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"app": "secret"}`), 0600))
+	f, err := CreateMysqlProxyFactory(map[string]interface{}{
+		"cluster":               "mysql",
+		"read_cluster":          "mysql_read",
+		"read_credentials_file": path,
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"app": "secret"}, f.(*mysqlProxyFilterConfigFactory).credentials)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`["app"]`), 0600))
+	_, err = loadCredentials(path)
+	require.Error(t, err)
+	_, err = loadCredentials(filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(t, err)
+}
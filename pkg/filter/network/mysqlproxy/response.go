@@ -0,0 +1,228 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mysqlproxy
+
+// the states of the response
+const (
+	// stateFirst expects the first packet of a result
+	stateFirst = iota
+	// stateDefinitions expects the column or parameter definitions
+	stateDefinitions
+	// stateDefinitionsEOF expects the EOF packet after the definitions
+	stateDefinitionsEOF
+	// stateRows expects the rows or the terminator of the result set
+	stateRows
+	// stateFieldList expects the column definitions or the EOF of COM_FIELD_LIST
+	stateFieldList
+)
+
+// response tracks the response packets of a command to find the end of the response
+type response struct {
+	command      byte
+	capabilities uint32
+	state        int
+	// definitions is the number of the definitions remaining, and nextDefinitions is the number of the
+	// column definitions after the parameter definitions of COM_STMT_PREPARE
+	definitions     uint64
+	nextDefinitions uint64
+	// rows is true if the rows follow the definitions
+	rows bool
+	// Failed is true if the command is failed by an ERR packet
+	Failed bool
+	// Status is the server status of the last OK or EOF packet
+	Status    uint16
+	HasStatus bool
+	// LocalInfile is true if the server requests the content of a local file, the client sends the
+	// content before the server responds the result
+	LocalInfile bool
+}
+
+// expectResponse returns false if the command has no response
+func expectResponse(command byte) bool {
+	switch command {
+	case comQuit, comStmtClose, comStmtSendLongData:
+		return false
+	}
+	return true
+}
+
+func newResponse(command byte, capabilities uint32) *response {
+	return &response{
+		command:      command,
+		capabilities: capabilities,
+	}
+}
+
+func (r *response) deprecateEOF() bool {
+	return r.capabilities&clientDeprecateEOF != 0
+}
+
+func (r *response) saveStatus(payload []byte) {
+	if status, ok := parseStatus(payload); ok {
+		r.Status = status
+		r.HasStatus = true
+	}
+}
+
+// moreResults returns true if the server status of the terminator has more results
+func (r *response) moreResults() bool {
+	return r.HasStatus && r.Status&serverStatusMoreResultsExists != 0
+}
+
+// Feed consumes a response packet, the continuation packets of the large packets must not be fed.
+// It returns true at the end of the response.
+func (r *response) Feed(payload []byte) (bool, error) {
+	if len(payload) == 0 {
+		return false, errMalformed
+	}
+	switch r.state {
+	case stateFirst:
+		return r.feedFirst(payload)
+	case stateDefinitions:
+		r.definitions--
+		if r.definitions == 0 {
+			if r.deprecateEOF() {
+				return r.endDefinitions(), nil
+			}
+			r.state = stateDefinitionsEOF
+		}
+	case stateDefinitionsEOF:
+		if !isEOF(payload) {
+			return false, errMalformed
+		}
+		return r.endDefinitions(), nil
+	case stateRows:
+		switch {
+		case payload[0] == iERR:
+			r.Failed = true
+			return true, nil
+		case isEOF(payload):
+			r.saveStatus(payload)
+			if r.moreResults() {
+				r.state = stateFirst
+				return false, nil
+			}
+			return true, nil
+		}
+	case stateFieldList:
+		switch {
+		case payload[0] == iERR:
+			r.Failed = true
+			return true, nil
+		case isEOF(payload):
+			r.saveStatus(payload)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *response) feedFirst(payload []byte) (bool, error) {
+	r.LocalInfile = false
+	if r.command == comStmtFetch {
+		// COM_STMT_FETCH responds the binary rows without the column definitions, the rows start with 0x00
+		r.state = stateRows
+		return r.Feed(payload)
+	}
+	switch payload[0] {
+	case iERR:
+		r.Failed = true
+		return true, nil
+	case iOK:
+		if r.command == comStmtPrepare {
+			return r.feedPrepareOK(payload)
+		}
+		r.saveStatus(payload)
+		if (r.command == comQuery || r.command == comStmtExecute) && r.moreResults() {
+			return false, nil
+		}
+		return true, nil
+	}
+	switch r.command {
+	case comQuery, comStmtExecute:
+	case comFieldList:
+		r.state = stateFieldList
+		return r.Feed(payload)
+	default:
+		// the EOF of COM_SET_OPTION, the string of COM_STATISTICS and so on
+		r.saveStatus(payload)
+		return true, nil
+	}
+	if r.command == comQuery && payload[0] == iLocalInfile {
+		r.LocalInfile = true
+		return false, nil
+	}
+	rd := &reader{b: payload}
+	columns := rd.lenenc()
+	if r.capabilities&clientOptionalResultsetMeta != 0 && rd.byte() == 0 {
+		columns = 0
+	}
+	if rd.err != nil {
+		return false, rd.err
+	}
+	r.rows = true
+	r.definitions, r.nextDefinitions = columns, 0
+	return r.startDefinitions(), nil
+}
+
+func (r *response) feedPrepareOK(payload []byte) (bool, error) {
+	// OK, statement id, columns, parameters, filler, warnings and the optional metadata flag
+	rd := &reader{b: payload[1:]}
+	rd.uint32()
+	columns := uint64(rd.uint16())
+	params := uint64(rd.uint16())
+	if rd.err != nil {
+		return false, rd.err
+	}
+	rd.next(3)
+	if r.capabilities&clientOptionalResultsetMeta != 0 && rd.err == nil && len(rd.b) > 0 && rd.byte() == 0 {
+		columns, params = 0, 0
+	}
+	r.rows = false
+	r.definitions, r.nextDefinitions = params, columns
+	return r.startDefinitions(), nil
+}
+
+// startDefinitions starts the definitions, it returns true if the response ends
+func (r *response) startDefinitions() bool {
+	if r.definitions > 0 {
+		r.state = stateDefinitions
+		return false
+	}
+	if r.rows {
+		// the metadata is omitted, or the column count is 0
+		r.state = stateRows
+		return false
+	}
+	if r.nextDefinitions > 0 {
+		r.definitions, r.nextDefinitions = r.nextDefinitions, 0
+		r.state = stateDefinitions
+		return false
+	}
+	return true
+}
+
+// endDefinitions ends the definitions, it returns true if the response ends
+func (r *response) endDefinitions() bool {
+	if r.rows {
+		r.state = stateRows
+		return false
+	}
+	r.definitions, r.nextDefinitions = r.nextDefinitions, 0
+	return r.startDefinitions()
+}
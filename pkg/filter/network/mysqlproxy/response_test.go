@@ -0,0 +1,113 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mysqlproxy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	okPacket          = []byte{iOK, 0, 0, serverStatusAutocommit, 0, 0, 0}
+	okMorePacket      = []byte{iOK, 0, 0, serverStatusAutocommit | serverStatusMoreResultsExists, 0, 0, 0}
+	eofPacket         = []byte{iEOF, 0, 0, serverStatusAutocommit, 0}
+	eofMorePacket     = []byte{iEOF, 0, 0, serverStatusAutocommit | serverStatusMoreResultsExists, 0}
+	okEOFPacket       = []byte{iEOF, 0, 0, serverStatusInTrans, 0, 0, 0}
+	errPacket         = []byte{iERR, 0x28, 0x04, '#', 'H', 'Y', '0', '0', '0', 'e'}
+	columnPacket      = []byte{3, 'd', 'e', 'f'}
+	textRowPacket     = []byte{1, '1'}
+	binaryRowPacket   = []byte{iOK, 0, 1}
+	prepareOKPacket   = []byte{iOK, 1, 0, 0, 0, 2, 0, 1, 0, 0, 0, 0}
+	localInfilePacket = []byte{iLocalInfile, 'f'}
+)
+
+// feed feeds the packets and requires the response ends at the last packet
+func feed(t *testing.T, r *response, packets ...[]byte) {
+	for i, pkt := range packets {
+		done, err := r.Feed(pkt)
+		require.NoError(t, err)
+		require.Equal(t, i == len(packets)-1, done, "packet %d", i)
+	}
+}
+
+func TestResponse(t *testing.T) {
+	// OK and ERR
+	r := newResponse(comQuery, 0)
+	feed(t, r, okPacket)
+	require.False(t, r.Failed)
+	require.Equal(t, uint16(serverStatusAutocommit), r.Status)
+	r = newResponse(comInitDB, 0)
+	feed(t, r, errPacket)
+	require.True(t, r.Failed)
+
+	// the text result set with EOF packets
+	feed(t, newResponse(comQuery, 0), []byte{2}, columnPacket, columnPacket, eofPacket, textRowPacket, textRowPacket, eofPacket)
+	// the text result set without EOF packets
+	r = newResponse(comQuery, clientDeprecateEOF)
+	feed(t, r, []byte{1}, columnPacket, textRowPacket, okEOFPacket)
+	require.Equal(t, uint16(serverStatusInTrans), r.Status)
+	// the result set failed by an ERR packet
+	r = newResponse(comQuery, clientDeprecateEOF)
+	feed(t, r, []byte{1}, columnPacket, textRowPacket, errPacket)
+	require.True(t, r.Failed)
+	// multiple results
+	feed(t, newResponse(comQuery, 0), okMorePacket, []byte{1}, columnPacket, eofPacket, textRowPacket, eofMorePacket, errPacket)
+	// the optional metadata is omitted
+	feed(t, newResponse(comQuery, clientDeprecateEOF|clientOptionalResultsetMeta), []byte{1, 0}, textRowPacket, okEOFPacket)
+	feed(t, newResponse(comQuery, clientDeprecateEOF|clientOptionalResultsetMeta), []byte{1, 1}, columnPacket, textRowPacket, okEOFPacket)
+
+	// the binary result set, the rows start with 0x00
+	feed(t, newResponse(comStmtExecute, 0), []byte{1}, columnPacket, eofPacket, binaryRowPacket, binaryRowPacket, eofPacket)
+	feed(t, newResponse(comStmtFetch, 0), binaryRowPacket, eofPacket)
+	feed(t, newResponse(comStmtExecute, 0), okPacket)
+
+	// the prepared statement has 2 columns and 1 parameter
+	feed(t, newResponse(comStmtPrepare, 0), prepareOKPacket, columnPacket, eofPacket, columnPacket, columnPacket, eofPacket)
+	feed(t, newResponse(comStmtPrepare, clientDeprecateEOF), prepareOKPacket, columnPacket, columnPacket, columnPacket)
+	feed(t, newResponse(comStmtPrepare, 0), []byte{iOK, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+
+	// LOCAL INFILE
+	r = newResponse(comQuery, 0)
+	done, err := r.Feed(localInfilePacket)
+	require.NoError(t, err)
+	require.False(t, done)
+	require.True(t, r.LocalInfile)
+	feed(t, r, okPacket)
+	require.False(t, r.LocalInfile)
+
+	// the other commands
+	feed(t, newResponse(comFieldList, 0), columnPacket, columnPacket, eofPacket)
+	feed(t, newResponse(comStatistics, 0), []byte("Uptime: 1"))
+	feed(t, newResponse(comSetOption, 0), eofPacket)
+
+	require.True(t, expectResponse(comQuery))
+	require.False(t, expectResponse(comStmtClose))
+
+	// malformed
+	r = newResponse(comQuery, clientDeprecateEOF)
+	_, err = r.Feed([]byte{0xfc, 1})
+	require.Error(t, err)
+	r = newResponse(comQuery, 0)
+	for _, pkt := range [][]byte{{1}, columnPacket} {
+		_, err = r.Feed(pkt)
+		require.NoError(t, err)
+	}
+	_, err = r.Feed(textRowPacket)
+	require.Error(t, err)
+}
@@ -0,0 +1,464 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mysqlproxy
+
+import (
+	"strings"
+)
+
+// unknownType is used in the metrics of the unknown statements and commands, to avoid high label cardinality
+const unknownType = "unknown"
+
+// maxTables limits the tables extracted from a statement
+const maxTables = 8
+
+// statementTypes are the statement types used in the metrics, which are the first keywords of the statements
+var statementTypes = map[string]bool{
+	"select": true, "insert": true, "update": true, "delete": true, "replace": true,
+	"call": true, "do": true, "with": true, "load": true, "handler": true, "table": true, "values": true,
+	"begin": true, "start": true, "commit": true, "rollback": true, "savepoint": true, "release": true, "xa": true,
+	"set": true, "use": true, "show": true, "explain": true, "describe": true, "desc": true, "help": true,
+	"create": true, "alter": true, "drop": true, "rename": true, "truncate": true,
+	"lock": true, "unlock": true, "grant": true, "revoke": true,
+	"prepare": true, "execute": true, "deallocate": true,
+	"analyze": true, "check": true, "checksum": true, "optimize": true, "repair": true,
+	"flush": true, "kill": true, "reset": true, "purge": true, "change": true, "install": true, "uninstall": true,
+}
+
+// commandTypes are the types of the commands except COM_QUERY used in the metrics
+var commandTypes = map[byte]string{
+	comQuit:            "quit",
+	comInitDB:          "init_db",
+	comFieldList:       "field_list",
+	comStatistics:      "statistics",
+	comPing:            "ping",
+	comChangeUser:      "change_user",
+	comStmtPrepare:     "stmt_prepare",
+	comStmtExecute:     "stmt_execute",
+	comStmtClose:       "stmt_close",
+	comStmtReset:       "stmt_reset",
+	comSetOption:       "set_option",
+	comStmtFetch:       "stmt_fetch",
+	comResetConnection: "reset_connection",
+}
+
+// token is a token of a statement, the comments are skipped and the quoted identifiers are unquoted
+type token struct {
+	text string
+	// ident is true if the token is a word or a quoted identifier
+	ident bool
+	// quoted is true if the token is a quoted identifier
+	quoted bool
+}
+
+// lexer splits a statement into tokens
+type lexer struct {
+	s string
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// skip skips the white spaces and the comments
+func (l *lexer) skip() {
+	for len(l.s) > 0 {
+		switch c := l.s[0]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f':
+			l.s = l.s[1:]
+		case c == '#' || strings.HasPrefix(l.s, "-- ") || l.s == "--" || strings.HasPrefix(l.s, "--\t") || strings.HasPrefix(l.s, "--\n"):
+			i := strings.IndexByte(l.s, '\n')
+			if i < 0 {
+				l.s = ""
+			} else {
+				l.s = l.s[i+1:]
+			}
+		case strings.HasPrefix(l.s, "/*") && !strings.HasPrefix(l.s, "/*!") && !strings.HasPrefix(l.s, "/*+"):
+			i := strings.Index(l.s[2:], "*/")
+			if i < 0 {
+				l.s = ""
+			} else {
+				l.s = l.s[i+4:]
+			}
+		case strings.HasPrefix(l.s, "/*!") || strings.HasPrefix(l.s, "/*+"):
+			// the executable comments and the optimizer hints are ignored, but their content is not a statement
+			i := strings.Index(l.s[3:], "*/")
+			if i < 0 {
+				l.s = ""
+			} else {
+				l.s = l.s[i+5:]
+			}
+		default:
+			return
+		}
+	}
+}
+
+// next returns the next token, it returns false at the end of the statement
+func (l *lexer) next() (token, bool) {
+	l.skip()
+	if len(l.s) == 0 {
+		return token{}, false
+	}
+	c := l.s[0]
+	switch {
+	case c == '`' || c == '"' || c == '\'':
+		i := 1
+		for i < len(l.s) {
+			if l.s[i] == '\\' && c != '`' {
+				i += 2
+				continue
+			}
+			if l.s[i] == c {
+				// the doubled quote is an escaped quote
+				if i+1 < len(l.s) && l.s[i+1] == c {
+					i += 2
+					continue
+				}
+				break
+			}
+			i++
+		}
+		if i > len(l.s) {
+			i = len(l.s)
+		}
+		text := l.s[1:i]
+		if i < len(l.s) {
+			i++
+		}
+		l.s = l.s[i:]
+		if c == '`' {
+			return token{text: strings.ReplaceAll(text, "``", "`"), ident: true, quoted: true}, true
+		}
+		return token{text: "'"}, true
+	case isWordChar(c) || c == '@':
+		i := 1
+		for i < len(l.s) && (isWordChar(l.s[i]) || c == '@' && l.s[i] == '@') {
+			i++
+		}
+		text := l.s[:i]
+		l.s = l.s[i:]
+		return token{text: text, ident: c != '@'}, true
+	default:
+		l.s = l.s[1:]
+		return token{text: string(c)}, true
+	}
+}
+
+// Statement is the summary of a SQL statement
+type Statement struct {
+	// Type is the lower case first keyword of the statement, or unknownType
+	Type string
+	// Tables are the tables referred by the statement
+	Tables []string
+	// ReadOnly is true if the statement can be sent to the read cluster
+	ReadOnly bool
+	// Session is true if the statement changes the session state which must be replayed on the read cluster
+	Session bool
+	// Pin is true if the statement creates the session state can not be replayed, all the following
+	// statements are sent to the primary cluster
+	Pin bool
+	// Database is the database of the USE statement
+	Database string
+}
+
+// keyword returns the lower case keyword of the token
+func (t token) keyword() string {
+	if !t.ident || t.quoted {
+		return ""
+	}
+	return strings.ToLower(t.text)
+}
+
+// ParseStatement parses a SQL statement
+func ParseStatement(sql string) *Statement {
+	l := &lexer{s: sql}
+	var tokens []token
+	for {
+		t, ok := l.next()
+		if !ok {
+			break
+		}
+		tokens = append(tokens, t)
+	}
+	// the trailing semicolons are not multiple statements
+	for len(tokens) > 0 && tokens[len(tokens)-1].text == ";" {
+		tokens = tokens[:len(tokens)-1]
+	}
+	// the parentheses around the statement
+	start := 0
+	for start < len(tokens) && tokens[start].text == "(" {
+		start++
+	}
+	stmt := &Statement{Type: unknownType}
+	if start == len(tokens) {
+		return stmt
+	}
+	if kw := tokens[start].keyword(); statementTypes[kw] {
+		stmt.Type = kw
+	}
+	multiple := false
+	for _, t := range tokens[start:] {
+		if t.text == ";" {
+			multiple = true
+			break
+		}
+	}
+	stmt.Tables = extractTables(tokens[start:])
+
+	switch stmt.Type {
+	case "select":
+		stmt.ReadOnly = !multiple && isReadOnlySelect(tokens[start:])
+	case "set":
+		stmt.Session = !multiple && !isTransactionSet(tokens[start:])
+	case "use":
+		if !multiple && len(tokens) == start+2 && tokens[start+1].ident {
+			stmt.Session = true
+			stmt.Database = tokens[start+1].text
+		}
+	case "lock", "handler":
+		stmt.Pin = true
+	case "create":
+		for _, t := range tokens[start+1:] {
+			if kw := t.keyword(); kw == "temporary" {
+				stmt.Pin = true
+			} else if kw != "or" && kw != "replace" && kw != "global" && kw != "local" {
+				break
+			}
+		}
+	}
+	if !stmt.Pin {
+		for _, t := range tokens[start:] {
+			switch t.keyword() {
+			case "get_lock", "release_lock", "release_all_locks":
+				stmt.Pin = true
+			}
+		}
+	}
+	// the multiple statements and the statements changing the session state partially are sent to the
+	// primary cluster only, the session state is not replayed
+	if multiple && !stmt.Pin {
+		for _, t := range tokens[start:] {
+			switch t.keyword() {
+			case "set", "use", "lock", "temporary", "prepare":
+				stmt.Pin = true
+			}
+		}
+	}
+	return stmt
+}
+
+// isReadOnlySelect returns true if the SELECT statement does not lock rows, write variables or files,
+// and does not depend on the state of the primary session.
+func isReadOnlySelect(tokens []token) bool {
+	for i, t := range tokens {
+		if strings.HasPrefix(t.text, "@") && !strings.HasPrefix(t.text, "@@") {
+			// the user variables are not replayed on the read cluster
+			return false
+		}
+		switch t.keyword() {
+		case "for":
+			if i+1 < len(tokens) {
+				switch tokens[i+1].keyword() {
+				case "update", "share":
+					return false
+				}
+			}
+		case "lock", "into", "last_insert_id", "found_rows", "row_count", "sql_calc_found_rows",
+			"get_lock", "release_lock", "is_free_lock", "is_used_lock", "nextval", "lastval", "setval":
+			return false
+		}
+	}
+	return true
+}
+
+// isTransactionSet returns true if the SET statement sets the characteristics of the transactions,
+// such as SET TRANSACTION ISOLATION LEVEL, which does not affect the read only statements
+func isTransactionSet(tokens []token) bool {
+	for _, t := range tokens[1:] {
+		switch t.keyword() {
+		case "transaction":
+			return true
+		case "session", "global", "local":
+			continue
+		}
+		return false
+	}
+	return false
+}
+
+// tableKeywords are the keywords followed by table names
+var tableKeywords = map[string]bool{
+	"from": true, "join": true, "into": true, "update": true, "table": true, "tables": true,
+}
+
+// tableStopKeywords are the keywords which can not be a table alias
+var tableStopKeywords = map[string]bool{
+	"where": true, "join": true, "inner": true, "left": true, "right": true, "cross": true, "natural": true,
+	"straight_join": true, "on": true, "using": true, "group": true, "order": true, "limit": true, "having": true,
+	"set": true, "values": true, "value": true, "select": true, "union": true, "for": true, "lock": true,
+	"partition": true, "window": true, "into": true, "as": true, "use": true, "ignore": true, "force": true,
+	"outer": true, "except": true, "intersect": true, "like": true, "if": true, "exists": true, "not": true,
+	"add": true, "drop": true, "modify": true, "change": true, "rename": true, "read": true, "write": true,
+	"default": true, "low_priority": true, "with": true, "to": true, "engine": true,
+}
+
+// extractTables returns the tables after FROM, JOIN, INTO, UPDATE and TABLE, the duplicated tables are removed
+func extractTables(tokens []token) []string {
+	e := &tableExtractor{tokens: tokens}
+	e.extract()
+	return e.tables
+}
+
+// parenthesis is a parenthesis of a statement
+type parenthesis struct {
+	// expr is true for the expressions such as EXTRACT(YEAR FROM d), and false for the subqueries
+	expr bool
+	// inList is true if the subquery is a derived table in a table list, the list is continued after it
+	inList bool
+}
+
+type tableExtractor struct {
+	tokens []token
+	tables []string
+	parens []parenthesis
+}
+
+func (e *tableExtractor) add(name string) {
+	for _, t := range e.tables {
+		if t == name {
+			return
+		}
+	}
+	if len(e.tables) < maxTables {
+		e.tables = append(e.tables, name)
+	}
+}
+
+func (e *tableExtractor) keyword(i int) string {
+	if i < len(e.tokens) {
+		return e.tokens[i].keyword()
+	}
+	return ""
+}
+
+func (e *tableExtractor) extract() {
+	for i := 0; i < len(e.tokens); i++ {
+		switch e.tokens[i].text {
+		case "(":
+			subquery := e.keyword(i+1) == "select" || e.keyword(i+1) == "with"
+			e.parens = append(e.parens, parenthesis{expr: !subquery})
+			continue
+		case ")":
+			if len(e.parens) == 0 {
+				continue
+			}
+			paren := e.parens[len(e.parens)-1]
+			e.parens = e.parens[:len(e.parens)-1]
+			if paren.inList {
+				i = e.list(e.skipAlias(i+1), true) - 1
+			}
+			continue
+		}
+		kw := e.keyword(i)
+		if !tableKeywords[kw] || len(e.parens) > 0 && e.parens[len(e.parens)-1].expr {
+			continue
+		}
+		// UPDATE is a table keyword only at the beginning, not FOR UPDATE or ON DUPLICATE KEY UPDATE
+		if kw == "update" && i > 0 {
+			continue
+		}
+		// INTO @var and INTO OUTFILE are not tables
+		if kw == "into" && (e.keyword(i+1) == "outfile" || e.keyword(i+1) == "dumpfile") {
+			continue
+		}
+		j := i + 1
+		// the modifiers of UPDATE and the IF [NOT] EXISTS of DDL
+		for {
+			switch e.keyword(j) {
+			case "low_priority", "ignore", "if", "not", "exists":
+				j++
+				continue
+			}
+			break
+		}
+		i = e.list(j, false) - 1
+	}
+}
+
+// list extracts the comma separated tables from i, and returns the index after the list. If continued is
+// true, the list is continued only if the token at i is a comma.
+func (e *tableExtractor) list(i int, continued bool) int {
+	if continued {
+		if i >= len(e.tokens) || e.tokens[i].text != "," {
+			return i
+		}
+		i++
+	}
+	for i < len(e.tokens) {
+		if e.tokens[i].text == "(" && (e.keyword(i+1) == "select" || e.keyword(i+1) == "with") {
+			// the derived table, the list is continued after the subquery
+			e.parens = append(e.parens, parenthesis{inList: true})
+			return i + 1
+		}
+		name, n := tableName(e.tokens[i:])
+		if n == 0 {
+			return i
+		}
+		e.add(name)
+		i = e.skipAlias(i + n)
+		// LOCK TABLES t READ
+		switch e.keyword(i) {
+		case "read", "write":
+			i++
+		}
+		if i >= len(e.tokens) || e.tokens[i].text != "," {
+			return i
+		}
+		i++
+	}
+	return i
+}
+
+// skipAlias skips the alias of the table at i
+func (e *tableExtractor) skipAlias(i int) int {
+	if e.keyword(i) == "as" {
+		i++
+	}
+	if i < len(e.tokens) && e.tokens[i].ident && !tableStopKeywords[e.tokens[i].keyword()] {
+		i++
+	}
+	return i
+}
+
+// tableName returns the table name of the tokens such as db.table, and the number of tokens consumed
+func tableName(tokens []token) (string, int) {
+	if len(tokens) == 0 || !tokens[0].ident {
+		return "", 0
+	}
+	if !tokens[0].quoted && (tableStopKeywords[tokens[0].keyword()] || statementTypes[tokens[0].keyword()]) {
+		return "", 0
+	}
+	name := tokens[0].text
+	n := 1
+	if len(tokens) >= 3 && tokens[1].text == "." && tokens[2].ident {
+		name += "." + tokens[2].text
+		n = 3
+	}
+	return name, n
+}
@@ -0,0 +1,73 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mysqlproxy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStatement(t *testing.T) {
+	cases := []struct {
+		sql      string
+		typ      string
+		tables   []string
+		readOnly bool
+		session  bool
+		pin      bool
+	}{
+		{sql: "SELECT * FROM users WHERE id = 1", typ: "select", tables: []string{"users"}, readOnly: true},
+		{sql: "  /* comment */ select a.id, b.name from db1.users a join `orders` b on a.id = b.uid;", typ: "select", tables: []string{"db1.users", "orders"}, readOnly: true},
+		{sql: "(SELECT 1 FROM t1) UNION (SELECT 2 FROM t2)", typ: "select", tables: []string{"t1", "t2"}, readOnly: true},
+		{sql: "select * from (select id from t1) x, t2 y where x.id = y.id", typ: "select", tables: []string{"t1", "t2"}, readOnly: true},
+		{sql: "select extract(year from created) from t", typ: "select", tables: []string{"t"}, readOnly: true},
+		{sql: "select * from t for update", typ: "select", tables: []string{"t"}},
+		{sql: "select * from t lock in share mode", typ: "select", tables: []string{"t"}},
+		{sql: "select last_insert_id()", typ: "select"},
+		{sql: "select id into @x from t", typ: "select", tables: []string{"t"}},
+		{sql: "select @@version", typ: "select", readOnly: true},
+		{sql: "select ';' from t", typ: "select", tables: []string{"t"}, readOnly: true},
+		{sql: "select 1; delete from t", typ: "select", tables: []string{"t"}},
+		{sql: "select get_lock('a', 1)", typ: "select", pin: true},
+		{sql: "-- comment\nINSERT INTO t (a, b) VALUES (1, 2) ON DUPLICATE KEY UPDATE b = 3", typ: "insert", tables: []string{"t"}},
+		{sql: "update low_priority t1, t2 set t1.a = t2.a", typ: "update", tables: []string{"t1", "t2"}},
+		{sql: "delete from t where id in (select id from t2)", typ: "delete", tables: []string{"t", "t2"}},
+		{sql: "REPLACE INTO `my``table` VALUES (1)", typ: "replace", tables: []string{"my`table"}},
+		{sql: "create table if not exists t (id int)", typ: "create", tables: []string{"t"}},
+		{sql: "create temporary table t (id int)", typ: "create", tables: []string{"t"}, pin: true},
+		{sql: "lock tables t1 read, t2 write", typ: "lock", tables: []string{"t1", "t2"}, pin: true},
+		{sql: "SET NAMES utf8mb4", typ: "set", session: true},
+		{sql: "set session transaction isolation level read committed", typ: "set"},
+		{sql: "use `db1`", typ: "use", session: true},
+		{sql: "set a = 1; use db1", typ: "set", pin: true},
+		{sql: "begin", typ: "begin"},
+		{sql: "/*!40101 SET NAMES utf8 */", typ: unknownType},
+		{sql: "foo bar", typ: unknownType},
+		{sql: "", typ: unknownType},
+	}
+	for _, c := range cases {
+		stmt := ParseStatement(c.sql)
+		require.Equal(t, c.typ, stmt.Type, c.sql)
+		require.Equal(t, c.tables, stmt.Tables, c.sql)
+		require.Equal(t, c.readOnly, stmt.ReadOnly, c.sql)
+		require.Equal(t, c.session, stmt.Session, c.sql)
+		require.Equal(t, c.pin, stmt.Pin, c.sql)
+	}
+	require.Equal(t, "db1", ParseStatement("use `db1`").Database)
+}
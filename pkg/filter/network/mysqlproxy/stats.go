@@ -0,0 +1,96 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mysqlproxy
+
+import (
+	"sync"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"mosn.io/mosn/pkg/metrics"
+)
+
+type proxyStats struct {
+	LoginSuccess    gometrics.Counter
+	LoginFailure    gometrics.Counter
+	TLSPassthrough  gometrics.Counter
+	ProtocolError   gometrics.Counter
+	SlowQuery       gometrics.Counter
+	ReadSplit       gometrics.Counter
+	ReadSplitFailed gometrics.Counter
+}
+
+type queryStats struct {
+	QueryTotal gometrics.Counter
+	QueryError gometrics.Counter
+	QueryTime  gometrics.Histogram
+}
+
+type tableStats struct {
+	QueryTotal gometrics.Counter
+	QueryError gometrics.Counter
+}
+
+var (
+	proxyStatsCache sync.Map
+	queryStatsCache sync.Map
+	tableStatsCache sync.Map
+)
+
+func getProxyStats(statPrefix string) *proxyStats {
+	if v, ok := proxyStatsCache.Load(statPrefix); ok {
+		return v.(*proxyStats)
+	}
+	s := metrics.NewMysqlStats(statPrefix)
+	v, _ := proxyStatsCache.LoadOrStore(statPrefix, &proxyStats{
+		LoginSuccess:    s.Counter(metrics.MysqlLoginSuccess),
+		LoginFailure:    s.Counter(metrics.MysqlLoginFailure),
+		TLSPassthrough:  s.Counter(metrics.MysqlTLSPassthrough),
+		ProtocolError:   s.Counter(metrics.MysqlProtocolError),
+		SlowQuery:       s.Counter(metrics.MysqlSlowQuery),
+		ReadSplit:       s.Counter(metrics.MysqlReadSplit),
+		ReadSplitFailed: s.Counter(metrics.MysqlReadSplitFailed),
+	})
+	return v.(*proxyStats)
+}
+
+func getQueryStats(statPrefix, queryType string) *queryStats {
+	key := statPrefix + "|" + queryType
+	if v, ok := queryStatsCache.Load(key); ok {
+		return v.(*queryStats)
+	}
+	s := metrics.NewMysqlQueryStats(statPrefix, queryType)
+	v, _ := queryStatsCache.LoadOrStore(key, &queryStats{
+		QueryTotal: s.Counter(metrics.MysqlQueryTotal),
+		QueryError: s.Counter(metrics.MysqlQueryError),
+		QueryTime:  s.Histogram(metrics.MysqlQueryTime),
+	})
+	return v.(*queryStats)
+}
+
+func getTableStats(statPrefix, table, queryType string) *tableStats {
+	key := statPrefix + "|" + table + "|" + queryType
+	if v, ok := tableStatsCache.Load(key); ok {
+		return v.(*tableStats)
+	}
+	s := metrics.NewMysqlTableStats(statPrefix, table, queryType)
+	v, _ := tableStatsCache.LoadOrStore(key, &tableStats{
+		QueryTotal: s.Counter(metrics.MysqlQueryTotal),
+		QueryError: s.Counter(metrics.MysqlQueryError),
+	})
+	return v.(*tableStats)
+}
@@ -0,0 +1,80 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rbac
+
+import (
+	"context"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/rbac"
+	"mosn.io/pkg/buffer"
+)
+
+// rbacFilter checks the connection when it is accepted, and closes the connection if it is not allowed.
+// If the rules match the peer identity, the check is deferred to the first data received, so the
+// tls handshake is finished and the peer identity can be matched.
+type rbacFilter struct {
+	ctx       context.Context
+	rbac      *rbac.RBAC
+	cb        api.ReadFilterCallbacks
+	evaluated bool
+	allowed   bool
+}
+
+func newRBACFilter(ctx context.Context, r *rbac.RBAC) *rbacFilter {
+	return &rbacFilter{
+		ctx:  ctx,
+		rbac: r,
+	}
+}
+
+// evaluate checks the connection once, the connection is closed if it is not allowed
+func (f *rbacFilter) evaluate() {
+	if f.evaluated {
+		return
+	}
+	f.evaluated = true
+	f.allowed = f.rbac.Allowed(f.ctx, nil)
+	if !f.allowed {
+		f.cb.Connection().Close(api.NoFlush, api.LocalClose)
+	}
+}
+
+func (f *rbacFilter) OnData(buf buffer.IoBuffer) api.FilterStatus {
+	f.evaluate()
+	if !f.allowed {
+		buf.Drain(buf.Len())
+		return api.Stop
+	}
+	return api.Continue
+}
+
+func (f *rbacFilter) OnNewConnection() api.FilterStatus {
+	if f.rbac.RequiresPeerIdentity() {
+		return api.Continue
+	}
+	f.evaluate()
+	if !f.allowed {
+		return api.Stop
+	}
+	return api.Continue
+}
+
+func (f *rbacFilter) InitializeReadFilterCallbacks(cb api.ReadFilterCallbacks) {
+	f.cb = cb
+}
@@ -0,0 +1,125 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rbac
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/mock"
+	"mosn.io/mosn/pkg/rbac"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+)
+
+func newTestFilter(ctrl *gomock.Controller, r *rbac.RBAC, remote string) (*rbacFilter, *mock.MockConnection) {
+	conn := mock.NewMockConnection(ctrl)
+	remoteAddr, _ := net.ResolveTCPAddr("tcp", remote)
+	localAddr, _ := net.ResolveTCPAddr("tcp", "127.0.0.1:2045")
+	conn.EXPECT().RemoteAddr().Return(remoteAddr).AnyTimes()
+	conn.EXPECT().LocalAddr().Return(localAddr).AnyTimes()
+	// a plain connection without tls has no peer identity
+	raw, _ := net.Pipe()
+	conn.EXPECT().RawConn().Return(raw).AnyTimes()
+	cb := mock.NewMockReadFilterCallbacks(ctrl)
+	cb.EXPECT().Connection().Return(conn).AnyTimes()
+	ctx := variable.NewVariableContext(context.Background())
+	_ = variable.Set(ctx, types.VariableConnection, conn)
+	f := newRBACFilter(ctx, r)
+	f.InitializeReadFilterCallbacks(cb)
+	return f, conn
+}
+
+func TestRBACFilter(t *testing.T) {
+	factory, err := CreateRBACFactory(map[string]interface{}{
+		"stat_prefix": "test_network",
+		"rules": map[string]interface{}{
+			"policies": []interface{}{
+				map[string]interface{}{
+					"name": "internal",
+					"permissions": []interface{}{
+						map[string]interface{}{"destination_port": 2045},
+					},
+					"principals": []interface{}{
+						map[string]interface{}{"source_ip": "10.0.0.0/8"},
+					},
+				},
+			},
+		},
+	})
+	require.Nil(t, err)
+	r := factory.(*rbacFilterConfigFactory).rbac
+
+	ctrl := gomock.NewController(t)
+	newFilter := func(remote string) (*rbacFilter, *mock.MockConnection) {
+		return newTestFilter(ctrl, r, remote)
+	}
+
+	allowed, _ := newFilter("10.1.1.1:12345")
+	assert.Equal(t, api.Continue, allowed.OnNewConnection())
+	assert.Equal(t, api.Continue, allowed.OnData(buffer.NewIoBufferString("hello")))
+
+	// denied connection is closed when it is accepted
+	denied, conn := newFilter("192.168.1.1:12345")
+	conn.EXPECT().Close(api.NoFlush, api.LocalClose).Return(nil).Times(1)
+	assert.Equal(t, api.Stop, denied.OnNewConnection())
+	buf := buffer.NewIoBufferString("hello")
+	assert.Equal(t, api.Stop, denied.OnData(buf))
+	assert.Equal(t, 0, buf.Len())
+	// evaluated only once
+	buf = buffer.NewIoBufferString("world")
+	assert.Equal(t, api.Stop, denied.OnData(buf))
+	assert.Equal(t, 0, buf.Len())
+}
+
+func TestRBACFilterPeerIdentity(t *testing.T) {
+	factory, err := CreateRBACFactory(map[string]interface{}{
+		"stat_prefix": "test_network_peer",
+		"rules": map[string]interface{}{
+			"policies": []interface{}{
+				map[string]interface{}{
+					"name": "authenticated",
+					"permissions": []interface{}{
+						map[string]interface{}{"any": true},
+					},
+					"principals": []interface{}{
+						map[string]interface{}{"authenticated": map[string]interface{}{}},
+					},
+				},
+			},
+		},
+	})
+	require.Nil(t, err)
+	r := factory.(*rbacFilterConfigFactory).rbac
+	require.True(t, r.RequiresPeerIdentity())
+
+	ctrl := gomock.NewController(t)
+	// the check is deferred to the first data, the connection has no peer identity
+	f, conn := newTestFilter(ctrl, r, "10.1.1.1:12345")
+	assert.Equal(t, api.Continue, f.OnNewConnection())
+	conn.EXPECT().Close(api.NoFlush, api.LocalClose).Return(nil).Times(1)
+	buf := buffer.NewIoBufferString("hello")
+	assert.Equal(t, api.Stop, f.OnData(buf))
+	assert.Equal(t, 0, buf.Len())
+}
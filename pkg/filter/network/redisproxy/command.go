@@ -0,0 +1,109 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redisproxy
+
+import (
+	"strconv"
+)
+
+type commandKind int
+
+const (
+	// simpleCommand has a single key at the first argument
+	simpleCommand commandKind = iota
+	// evalCommand has the keys after the script and the key count, the first key is used to route
+	evalCommand
+	// multiGetCommand is split into a GET for each key, the replies are merged into an array
+	multiGetCommand
+	// multiSetCommand is split into a SET for each key value pair, the reply is OK if all succeed
+	multiSetCommand
+	// multiSumCommand is split into a command for each key, the integer replies are summed
+	multiSumCommand
+	// connectionCommand is sent to all the upstream connections and replayed on the new ones, such as AUTH
+	connectionCommand
+	// localCommand is replied by the proxy
+	localCommand
+)
+
+type command struct {
+	kind commandKind
+	// minArgs is the min number of the arguments, including the command name
+	minArgs int
+}
+
+var commands = map[string]*command{}
+
+func registerCommands(kind commandKind, minArgs int, names ...string) {
+	for _, name := range names {
+		commands[name] = &command{kind: kind, minArgs: minArgs}
+	}
+}
+
+func init() {
+	registerCommands(simpleCommand, 2,
+		// strings
+		"append", "bitcount", "bitfield", "bitpos", "decr", "decrby", "get", "getbit", "getdel", "getex",
+		"getrange", "getset", "incr", "incrby", "incrbyfloat", "psetex", "set", "setbit", "setex", "setnx",
+		"setrange", "strlen",
+		// keys
+		"dump", "expire", "expireat", "expiretime", "persist", "pexpire", "pexpireat", "pexpiretime", "pttl",
+		"restore", "sort", "ttl", "type",
+		// hashes
+		"hdel", "hexists", "hget", "hgetall", "hincrby", "hincrbyfloat", "hkeys", "hlen", "hmget", "hmset",
+		"hrandfield", "hscan", "hset", "hsetnx", "hstrlen", "hvals",
+		// lists
+		"lindex", "linsert", "llen", "lpop", "lpos", "lpush", "lpushx", "lrange", "lrem", "lset", "ltrim",
+		"rpop", "rpush", "rpushx",
+		// sets
+		"sadd", "scard", "sismember", "smembers", "smismember", "spop", "srandmember", "srem", "sscan",
+		// sorted sets
+		"zadd", "zcard", "zcount", "zincrby", "zlexcount", "zmscore", "zpopmax", "zpopmin", "zrandmember",
+		"zrange", "zrangebylex", "zrangebyscore", "zrank", "zrem", "zremrangebylex", "zremrangebyrank",
+		"zremrangebyscore", "zrevrange", "zrevrangebylex", "zrevrangebyscore", "zrevrank", "zscan", "zscore",
+		// geo and hyperloglog
+		"geoadd", "geodist", "geohash", "geopos", "georadius_ro", "georadiusbymember_ro", "geosearch",
+		"pfadd", "pfcount",
+		// streams
+		"xack", "xadd", "xautoclaim", "xclaim", "xdel", "xlen", "xpending", "xrange", "xrevrange", "xtrim",
+	)
+	registerCommands(evalCommand, 3, "eval", "evalsha", "eval_ro", "evalsha_ro")
+	registerCommands(multiGetCommand, 2, "mget")
+	registerCommands(multiSetCommand, 3, "mset")
+	registerCommands(multiSumCommand, 2, "del", "unlink", "exists", "touch")
+	registerCommands(connectionCommand, 2, "auth")
+	registerCommands(connectionCommand, 1, "hello")
+	registerCommands(localCommand, 1, "ping", "quit")
+	registerCommands(localCommand, 2, "echo")
+}
+
+// lookupCommand returns the command by the lower case name, or nil if it is not supported
+func lookupCommand(name string) *command {
+	return commands[name]
+}
+
+// evalKeyIndex returns the index of the first key of the eval command, or -1 if there is no key
+func evalKeyIndex(args [][]byte) (int, bool) {
+	numKeys, err := strconv.Atoi(string(args[2]))
+	if err != nil || numKeys < 0 || len(args) < 3+numKeys {
+		return -1, false
+	}
+	if numKeys == 0 {
+		return -1, true
+	}
+	return 3, true
+}
@@ -0,0 +1,76 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redisproxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/filter/network/internal/upstream"
+)
+
+func init() {
+	api.RegisterNetwork(v2.REDIS_PROXY, CreateRedisProxyFactory)
+	upstream.Register(upstreamProtocol)
+}
+
+type redisProxyFilterConfigFactory struct {
+	Proxy  *v2.RedisProxy
+	router *router
+}
+
+func (f *redisProxyFilterConfigFactory) CreateFilterChain(context context.Context, callbacks api.NetWorkFilterChainFactoryCallbacks) {
+	rf := newProxy(context, f.Proxy.StatPrefix, f.router)
+	callbacks.AddReadFilter(rf)
+}
+
+func CreateRedisProxyFactory(conf map[string]interface{}) (api.NetworkFilterChainFactory, error) {
+	p, err := ParseRedisProxy(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &redisProxyFilterConfigFactory{
+		Proxy:  p,
+		router: newRouter(p),
+	}, nil
+}
+
+// ParseRedisProxy parses the redis proxy config
+func ParseRedisProxy(cfg map[string]interface{}) (*v2.RedisProxy, error) {
+	proxy := &v2.RedisProxy{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("[config] config is not a redis proxy config: %v", err)
+	}
+	if err := json.Unmarshal(data, proxy); err != nil {
+		return nil, fmt.Errorf("[config] config is not a redis proxy config: %v", err)
+	}
+	if proxy.Cluster == "" && len(proxy.PrefixRoutes) == 0 {
+		return nil, errors.New("[config] redis proxy has no cluster or prefix route")
+	}
+	for _, r := range proxy.PrefixRoutes {
+		if r.Cluster == "" {
+			return nil, fmt.Errorf("[config] redis proxy prefix route %s has no cluster", r.Prefix)
+		}
+	}
+	return proxy, nil
+}
@@ -0,0 +1,535 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redisproxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/filter/network/internal/upstream"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/upstream/cluster"
+	"mosn.io/pkg/buffer"
+)
+
+// upstreamProtocol is the protocol of the cluster connection pools used by the redis proxy
+var upstreamProtocol = api.ProtocolName(v2.REDIS_PROXY)
+
+// request is a command sent by the client, or a part of a split command
+type request struct {
+	command string
+	start   time.Time
+	reply   *Value
+	done    bool
+	// internal requests are the connection commands replayed on the new upstream connections,
+	// the replies are not sent to the client
+	internal bool
+	// parent is the split command, which is completed when all the children are completed
+	parent    *request
+	children  []*request
+	remaining int
+	merge     func(children []*request) *Value
+}
+
+// proxy is a ReadFilter that proxies a downstream connection. The commands are routed by the keys,
+// and each downstream connection leases its own upstream connections from the cluster connection pools,
+// so the connection states such as AUTH are kept by the upstream connections. The upstream connections
+// without the states are reused by the other downstream connections.
+type proxy struct {
+	statPrefix     string
+	router         *router
+	clusterManager types.ClusterManager
+	readCallbacks  api.ReadFilterCallbacks
+	ctx            context.Context
+
+	mutex sync.Mutex
+	// pending are the client commands, the replies are sent in order
+	pending   []*request
+	upstreams map[string]*upstreamConn
+	// setups are the connection commands replayed on the new upstream connections
+	setups [][][]byte
+	closed bool
+	// quit closes the downstream connection after the replies are sent
+	quit bool
+}
+
+func newProxy(ctx context.Context, statPrefix string, r *router) *proxy {
+	return &proxy{
+		statPrefix:     statPrefix,
+		router:         r,
+		clusterManager: cluster.GetClusterMngAdapterInstance().ClusterManager,
+		ctx:            ctx,
+		upstreams:      map[string]*upstreamConn{},
+	}
+}
+
+func (p *proxy) OnNewConnection() api.FilterStatus {
+	return api.Continue
+}
+
+func (p *proxy) InitializeReadFilterCallbacks(cb api.ReadFilterCallbacks) {
+	p.readCallbacks = cb
+	p.readCallbacks.Connection().AddConnectionEventListener(p)
+}
+
+func (p *proxy) OnData(buf buffer.IoBuffer) api.FilterStatus {
+	p.mutex.Lock()
+	for !p.quit {
+		args, n, err := DecodeCommand(buf.Bytes())
+		if err == errIncomplete {
+			break
+		}
+		if err != nil {
+			log.DefaultLogger.Errorf("[redis proxy] decode command failed: %v", err)
+			buf.Drain(buf.Len())
+			p.pending = append(p.pending, &request{
+				reply: errorValue("ERR Protocol error: %v", err),
+				done:  true,
+			})
+			p.quit = true
+			break
+		}
+		buf.Drain(n)
+		if len(args) > 0 {
+			p.handleCommand(args)
+		}
+	}
+	if p.quit {
+		buf.Drain(buf.Len())
+	}
+	for _, uc := range p.upstreams {
+		uc.flush()
+	}
+	p.flushDownstream()
+	quit := p.shouldQuit()
+	p.mutex.Unlock()
+
+	// close outside the lock, the close event is handled synchronously
+	if quit {
+		p.readCallbacks.Connection().Close(api.FlushWrite, api.LocalClose)
+	}
+	return api.Stop
+}
+
+// shouldQuit returns true if the client quits and all the replies are sent
+func (p *proxy) shouldQuit() bool {
+	return p.quit && !p.closed && len(p.pending) == 0
+}
+
+// OnEvent handles the downstream connection events
+func (p *proxy) OnEvent(event api.ConnectionEvent) {
+	if !event.IsClose() {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.closed = true
+	p.pending = nil
+	for _, uc := range p.upstreams {
+		// the connection is reused if it has no states and no commands waiting for the replies
+		uc.closed = true
+		uc.conn.Release(len(p.setups) == 0 && len(uc.queue) == 0)
+	}
+	p.upstreams = map[string]*upstreamConn{}
+}
+
+func (p *proxy) handleCommand(args [][]byte) {
+	name := strings.ToLower(string(args[0]))
+	req := &request{
+		command: name,
+		start:   time.Now(),
+	}
+	p.pending = append(p.pending, req)
+
+	cmd := lookupCommand(name)
+	if cmd == nil {
+		req.command = unknownCommand
+		p.complete(req, errorValue("ERR unsupported command '%s'", args[0]))
+		return
+	}
+	if len(args) < cmd.minArgs {
+		p.complete(req, errorValue("ERR wrong number of arguments for '%s' command", name))
+		return
+	}
+
+	switch cmd.kind {
+	case simpleCommand:
+		p.sendKey(req, args, 1)
+	case evalCommand:
+		index, ok := evalKeyIndex(args)
+		if !ok {
+			p.complete(req, errorValue("ERR invalid number of keys for '%s' command", name))
+		} else if index < 0 {
+			p.send(req, p.router.anyCluster(), nil, args)
+		} else {
+			p.sendKey(req, args, index)
+		}
+	case multiGetCommand:
+		var children [][][]byte
+		for _, key := range args[1:] {
+			children = append(children, [][]byte{[]byte("get"), key})
+		}
+		p.split(req, children, mergeArray)
+	case multiSetCommand:
+		if len(args)%2 != 1 {
+			p.complete(req, errorValue("ERR wrong number of arguments for '%s' command", name))
+			return
+		}
+		var children [][][]byte
+		for i := 1; i < len(args); i += 2 {
+			children = append(children, [][]byte{[]byte("set"), args[i], args[i+1]})
+		}
+		p.split(req, children, mergeOK)
+	case multiSumCommand:
+		var children [][][]byte
+		for _, key := range args[1:] {
+			children = append(children, [][]byte{args[0], key})
+		}
+		p.split(req, children, mergeSum)
+	case connectionCommand:
+		p.sendConnectionCommand(req, args)
+	case localCommand:
+		p.handleLocalCommand(req, name, args)
+	}
+}
+
+func (p *proxy) handleLocalCommand(req *request, name string, args [][]byte) {
+	switch name {
+	case "ping":
+		if len(args) > 1 {
+			p.complete(req, bulkString(args[1]))
+		} else {
+			p.complete(req, simpleString("PONG"))
+		}
+	case "echo":
+		p.complete(req, bulkString(args[1]))
+	case "quit":
+		p.complete(req, simpleString("OK"))
+		p.quit = true
+	}
+}
+
+// sendKey routes the command by the key at the index
+func (p *proxy) sendKey(req *request, args [][]byte, index int) {
+	clusterName, key := p.router.route(args[index])
+	args[index] = key
+	p.send(req, clusterName, key, args)
+}
+
+func (p *proxy) send(req *request, clusterName string, key []byte, args [][]byte) {
+	uc, err := p.upstreamFor(clusterName, key)
+	if err != nil {
+		p.complete(req, errorValue("ERR %v", err))
+		return
+	}
+	uc.send(req, args)
+}
+
+// split sends the children commands, the request is completed with the merged replies
+func (p *proxy) split(req *request, children [][][]byte, merge func([]*request) *Value) {
+	req.merge = merge
+	req.remaining = len(children)
+	for range children {
+		req.children = append(req.children, &request{
+			command: req.command,
+			parent:  req,
+		})
+	}
+	for i, args := range children {
+		p.sendKey(req.children[i], args, 1)
+	}
+}
+
+// sendConnectionCommand sends the command to all the upstream connections, and saves it
+// to be replayed on the new upstream connections if it succeeds.
+func (p *proxy) sendConnectionCommand(req *request, args [][]byte) {
+	upstreams := make([]*upstreamConn, 0, len(p.upstreams))
+	for _, uc := range p.upstreams {
+		upstreams = append(upstreams, uc)
+	}
+	if len(upstreams) == 0 {
+		uc, err := p.upstreamFor(p.router.anyCluster(), nil)
+		if err != nil {
+			p.complete(req, errorValue("ERR %v", err))
+			return
+		}
+		upstreams = append(upstreams, uc)
+	}
+	req.remaining = len(upstreams)
+	req.merge = func(children []*request) *Value {
+		reply := mergeFirst(children)
+		if !reply.IsError() {
+			p.saveSetup(args)
+		}
+		return reply
+	}
+	for _, uc := range upstreams {
+		child := &request{
+			command: req.command,
+			parent:  req,
+		}
+		req.children = append(req.children, child)
+		uc.send(child, args)
+	}
+}
+
+func (p *proxy) saveSetup(args [][]byte) {
+	for i, setup := range p.setups {
+		if bytes.EqualFold(setup[0], args[0]) {
+			p.setups[i] = args
+			return
+		}
+	}
+	p.setups = append(p.setups, args)
+}
+
+func (p *proxy) upstreamFor(clusterName string, key []byte) (*upstreamConn, error) {
+	if clusterName == "" {
+		return nil, fmt.Errorf("no upstream cluster for key '%s'", key)
+	}
+	snapshot := p.clusterManager.GetClusterSnapshot(p.ctx, clusterName)
+	if snapshot == nil || reflect.ValueOf(snapshot).IsNil() {
+		return nil, fmt.Errorf("upstream cluster %s not found", clusterName)
+	}
+	host := chooseHost(snapshot, key)
+	if host == nil {
+		return nil, fmt.Errorf("no healthy upstream in cluster %s", clusterName)
+	}
+	return p.getUpstream(snapshot, host)
+}
+
+// getUpstream returns the upstream connection of the host, the connection is leased from the
+// cluster connection pool and connected asynchronously
+func (p *proxy) getUpstream(snapshot types.ClusterSnapshot, host types.Host) (*upstreamConn, error) {
+	addr := host.AddressString()
+	if uc, ok := p.upstreams[addr]; ok {
+		return uc, nil
+	}
+	uc := &upstreamConn{
+		proxy: p,
+		addr:  addr,
+	}
+	conn, err := upstream.Get(p.ctx, p.clusterManager, snapshot, host, upstreamProtocol, uc)
+	if err != nil {
+		return nil, err
+	}
+	uc.conn = conn
+	p.upstreams[addr] = uc
+
+	for _, setup := range p.setups {
+		uc.send(&request{
+			command:  strings.ToLower(string(setup[0])),
+			internal: true,
+		}, setup)
+	}
+	return uc, nil
+}
+
+// complete completes the request with the reply, the replies are sent by flushDownstream
+func (p *proxy) complete(req *request, reply *Value) {
+	if req.done {
+		return
+	}
+	req.reply = reply
+	req.done = true
+
+	if req.internal {
+		if reply.IsError() {
+			log.DefaultLogger.Warnf("[redis proxy] replay %s on the upstream connection failed: %s", req.command, reply.Str)
+		}
+		return
+	}
+	if parent := req.parent; parent != nil {
+		parent.remaining--
+		if parent.remaining == 0 {
+			p.complete(parent, parent.merge(parent.children))
+		}
+		return
+	}
+	if req.command != "" {
+		stats := getCommandStats(p.statPrefix, req.command)
+		stats.CommandTotal.Inc(1)
+		stats.CommandTime.Update(time.Since(req.start).Nanoseconds())
+		if reply.IsError() {
+			stats.CommandError.Inc(1)
+		}
+	}
+}
+
+// flushDownstream sends the completed replies in order
+func (p *proxy) flushDownstream() {
+	if p.closed {
+		return
+	}
+	var out []byte
+	i := 0
+	for ; i < len(p.pending) && p.pending[i].done; i++ {
+		out = p.pending[i].reply.Encode(out)
+		p.pending[i] = nil
+	}
+	p.pending = p.pending[i:]
+	if len(out) > 0 {
+		if err := p.readCallbacks.Connection().Write(buffer.NewIoBufferBytes(out)); err != nil {
+			log.DefaultLogger.Errorf("[redis proxy] write reply to downstream failed: %v", err)
+		}
+	}
+}
+
+func (p *proxy) removeUpstream(uc *upstreamConn) {
+	if uc.closed {
+		return
+	}
+	uc.closed = true
+	if p.upstreams[uc.addr] == uc {
+		delete(p.upstreams, uc.addr)
+	}
+	queue := uc.queue
+	uc.queue = nil
+	for _, req := range queue {
+		p.complete(req, errorValue("ERR upstream connection closed"))
+	}
+}
+
+func mergeArray(children []*request) *Value {
+	values := make([]*Value, 0, len(children))
+	for _, child := range children {
+		values = append(values, child.reply)
+	}
+	return arrayValue(values)
+}
+
+func mergeOK(children []*request) *Value {
+	for _, child := range children {
+		if child.reply.IsError() {
+			return child.reply
+		}
+	}
+	return simpleString("OK")
+}
+
+func mergeSum(children []*request) *Value {
+	var sum int64
+	for _, child := range children {
+		if child.reply.IsError() {
+			return child.reply
+		}
+		if child.reply.Type != TypeInteger {
+			return errorValue("ERR unexpected reply type from upstream: %q", child.reply.Type)
+		}
+		sum += child.reply.Int
+	}
+	return integerValue(sum)
+}
+
+// mergeFirst returns the first error, or the first reply if there is no error
+func mergeFirst(children []*request) *Value {
+	for _, child := range children {
+		if child.reply.IsError() {
+			return child.reply
+		}
+	}
+	return children[0].reply
+}
+
+// upstreamConn is an upstream connection of a downstream connection, the commands
+// are pipelined and the replies are matched with the commands in order.
+type upstreamConn struct {
+	proxy *proxy
+	addr  string
+	conn  *upstream.Conn
+	queue []*request
+	// out is the encoded commands waiting to be written
+	out    []byte
+	closed bool
+}
+
+func (uc *upstreamConn) send(req *request, args [][]byte) {
+	uc.queue = append(uc.queue, req)
+	uc.out = encodeCommand(uc.out, args)
+}
+
+func (uc *upstreamConn) flush() {
+	if len(uc.out) == 0 {
+		return
+	}
+	if err := uc.conn.Write(uc.out); err != nil {
+		log.DefaultLogger.Errorf("[redis proxy] write to upstream %s failed: %v", uc.addr, err)
+	}
+	uc.out = nil
+}
+
+func (uc *upstreamConn) OnData(conn *upstream.Conn, buf buffer.IoBuffer) {
+	p := uc.proxy
+	p.mutex.Lock()
+	broken := false
+	for {
+		v, n, err := DecodeValue(buf.Bytes())
+		if err == errIncomplete {
+			break
+		}
+		if err != nil {
+			log.DefaultLogger.Errorf("[redis proxy] decode reply from upstream %s failed: %v", uc.addr, err)
+			buf.Drain(buf.Len())
+			broken = true
+			break
+		}
+		buf.Drain(n)
+		if v.Type == TypePush {
+			// the out of band data is not supported, since the subscription commands are not supported
+			continue
+		}
+		if len(uc.queue) == 0 {
+			log.DefaultLogger.Warnf("[redis proxy] unexpected reply from upstream %s", uc.addr)
+			continue
+		}
+		req := uc.queue[0]
+		uc.queue[0] = nil
+		uc.queue = uc.queue[1:]
+		p.complete(req, v)
+	}
+	if broken {
+		p.removeUpstream(uc)
+	}
+	p.flushDownstream()
+	quit := p.shouldQuit()
+	p.mutex.Unlock()
+
+	if broken {
+		conn.Close()
+	}
+	if quit {
+		p.readCallbacks.Connection().Close(api.FlushWrite, api.LocalClose)
+	}
+}
+
+// OnClose handles the upstream connection closed or failed to connect
+func (uc *upstreamConn) OnClose(conn *upstream.Conn, event api.ConnectionEvent) {
+	p := uc.proxy
+	p.mutex.Lock()
+	p.removeUpstream(uc)
+	p.flushDownstream()
+	p.mutex.Unlock()
+}
@@ -0,0 +1,385 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redisproxy
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/filter/network/internal/proxytest"
+)
+
+// fakeRedis is a redis server supports a few commands
+type fakeRedis struct {
+	*proxytest.Server
+	password string
+	mutex    sync.Mutex
+	data     map[string]string
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	s := &fakeRedis{
+		password: password,
+		data:     map[string]string{},
+	}
+	s.Server = proxytest.NewServer(t, s.serve)
+	return s
+}
+
+func (s *fakeRedis) keys() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var keys []string
+	for k := range s.data {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	authed := s.password == ""
+	var in []byte
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+		in = append(in, buf[:n]...)
+		var out []byte
+		for {
+			args, n, err := DecodeCommand(in)
+			if err != nil {
+				break
+			}
+			in = in[n:]
+			out = s.handle(args, &authed).Encode(out)
+		}
+		conn.Write(out)
+	}
+}
+
+func (s *fakeRedis) handle(args [][]byte, authed *bool) *Value {
+	name := strings.ToLower(string(args[0]))
+	if name == "auth" {
+		if string(args[1]) != s.password {
+			return errorValue("WRONGPASS invalid password")
+		}
+		*authed = true
+		return simpleString("OK")
+	}
+	if !*authed {
+		return errorValue("NOAUTH Authentication required.")
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	switch name {
+	case "get":
+		if v, ok := s.data[string(args[1])]; ok {
+			return bulkString([]byte(v))
+		}
+		return &Value{Type: TypeBulkString, Null: true}
+	case "set":
+		s.data[string(args[1])] = string(args[2])
+		return simpleString("OK")
+	case "del", "exists":
+		_, ok := s.data[string(args[1])]
+		if ok && name == "del" {
+			delete(s.data, string(args[1]))
+		}
+		if ok {
+			return integerValue(1)
+		}
+		return integerValue(0)
+	}
+	return errorValue("ERR unknown command '%s'", args[0])
+}
+
+// testClient is a downstream connection of the proxy
+type testClient struct {
+	*proxytest.Client
+	t     *testing.T
+	proxy *proxy
+}
+
+func newTestClient(t *testing.T, ctrl *gomock.Controller, config *v2.RedisProxy) *testClient {
+	p := newProxy(context.Background(), config.StatPrefix, newRouter(config))
+	return &testClient{
+		Client: proxytest.NewClient(t, ctrl, p, decodeValue),
+		t:      t,
+		proxy:  p,
+	}
+}
+
+func decodeValue(data []byte) (int, error) {
+	_, n, err := DecodeValue(data)
+	if err == errIncomplete {
+		return 0, nil
+	}
+	return n, err
+}
+
+// do sends the commands and returns the replies
+func (c *testClient) do(commands ...[]string) []*Value {
+	var data []byte
+	for _, cmd := range commands {
+		var args [][]byte
+		for _, arg := range cmd {
+			args = append(args, []byte(arg))
+		}
+		data = encodeCommand(data, args)
+	}
+	return c.send(data, len(commands))
+}
+
+// send sends the data in pieces to test the pipeline decoding, and returns the replies
+func (c *testClient) send(data []byte, count int) []*Value {
+	var replies []*Value
+	for _, msg := range c.Send(data, count) {
+		v, _, err := DecodeValue(msg)
+		require.NoError(c.t, err)
+		replies = append(replies, v)
+	}
+	return replies
+}
+
+func requireReplies(t *testing.T, replies []*Value, expected ...string) {
+	var actual []string
+	for _, v := range replies {
+		actual = append(actual, string(v.Encode(nil)))
+	}
+	require.Equal(t, expected, actual)
+}
+
+func setupClusters(t *testing.T, clusters map[string][]*fakeRedis) {
+	addrs := map[string][]string{}
+	for name, servers := range clusters {
+		for _, s := range servers {
+			addrs[name] = append(addrs[name], s.Addr())
+		}
+	}
+	proxytest.SetupClusters(addrs)
+}
+
+func TestRedisProxy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	shard1, shard2, users := newFakeRedis(t, ""), newFakeRedis(t, ""), newFakeRedis(t, "")
+	defer shard1.Close()
+	defer shard2.Close()
+	defer users.Close()
+	commandStatsCache = sync.Map{}
+	setupClusters(t, map[string][]*fakeRedis{
+		"redis_default": {shard1, shard2},
+		"redis_users":   {users},
+	})
+	defer proxytest.Cleanup()
+
+	c := newTestClient(t, ctrl, &v2.RedisProxy{
+		StatPrefix: "test_redis",
+		Cluster:    "redis_default",
+		PrefixRoutes: []*v2.RedisPrefixRoute{
+			{Prefix: "user:", Cluster: "redis_users", RemovePrefix: true},
+		},
+	})
+
+	// pipelined commands
+	requireReplies(t, c.do(
+		[]string{"PING"},
+		[]string{"SET", "a", "1"},
+		[]string{"SET", "user:1", "tom"},
+		[]string{"GET", "a"},
+		[]string{"GET", "user:1"},
+		[]string{"ECHO", "hello"},
+	), "+PONG\r\n", "+OK\r\n", "+OK\r\n", "$1\r\n1\r\n", "$3\r\ntom\r\n", "$5\r\nhello\r\n")
+	require.Equal(t, []string{"1"}, users.keys())
+
+	// multi keys commands are split into the shards
+	var msetArgs []string
+	var keys []string
+	for i := 0; i < 20; i++ {
+		key := string([]byte{byte('k'), byte('a' + i)})
+		keys = append(keys, key)
+		msetArgs = append(msetArgs, key, key)
+	}
+	requireReplies(t, c.do(append([]string{"MSET"}, msetArgs...)), "+OK\r\n")
+	require.NotEmpty(t, shard1.keys())
+	require.NotEmpty(t, shard2.keys())
+	require.Len(t, append(shard1.keys(), shard2.keys()...), 21)
+
+	replies := c.do(append([]string{"MGET"}, append(keys, "missing")...))
+	require.Len(t, replies[0].Array, 21)
+	for i, key := range keys {
+		require.Equal(t, key, string(replies[0].Array[i].Str))
+	}
+	require.True(t, replies[0].Array[20].Null)
+
+	requireReplies(t, c.do(
+		append([]string{"EXISTS"}, keys[:5]...),
+		append([]string{"DEL"}, append(keys, "missing", "user:1")...),
+		[]string{"GET", "user:1"},
+	), ":5\r\n", ":21\r\n", "$-1\r\n")
+
+	// errors are replied in order
+	requireReplies(t, c.do(
+		[]string{"SUBSCRIBE", "channel"},
+		[]string{"GET"},
+		[]string{"MSET", "a"},
+		[]string{"EVAL", "return 1", "x"},
+		[]string{"INCR", "a"},
+	),
+		"-ERR unsupported command 'SUBSCRIBE'\r\n",
+		"-ERR wrong number of arguments for 'get' command\r\n",
+		"-ERR wrong number of arguments for 'mset' command\r\n",
+		"-ERR invalid number of keys for 'eval' command\r\n",
+		"-ERR unknown command 'INCR'\r\n",
+	)
+
+	// the per command metrics
+	stats := getCommandStats("test_redis", "get")
+	require.Equal(t, int64(4), stats.CommandTotal.Count())
+	require.Equal(t, int64(4), stats.CommandTime.Count())
+	require.Equal(t, int64(1), stats.CommandError.Count())
+	require.Equal(t, int64(1), getCommandStats("test_redis", unknownCommand).CommandError.Count())
+
+	// inline command and quit
+	replies = c.send([]byte("PING\r\nQUIT\r\nPING\r\n"), 2)
+	requireReplies(t, replies, "+PONG\r\n", "+OK\r\n")
+	require.True(t, c.IsClosed())
+	require.Len(t, c.proxy.upstreams, 0)
+}
+
+func TestRedisProxyAuth(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	shard1, shard2 := newFakeRedis(t, "secret"), newFakeRedis(t, "secret")
+	defer shard1.Close()
+	defer shard2.Close()
+	setupClusters(t, map[string][]*fakeRedis{
+		"redis_auth": {shard1, shard2},
+	})
+	defer proxytest.Cleanup()
+
+	c := newTestClient(t, ctrl, &v2.RedisProxy{Cluster: "redis_auth"})
+	replies := c.do([]string{"GET", "a"})
+	require.True(t, bytes.HasPrefix(replies[0].Str, []byte("NOAUTH")))
+	requireReplies(t, c.do([]string{"AUTH", "wrong"}), "-WRONGPASS invalid password\r\n")
+	require.Len(t, c.proxy.setups, 0)
+	requireReplies(t, c.do([]string{"AUTH", "secret"}), "+OK\r\n")
+	require.Len(t, c.proxy.setups, 1)
+
+	// the auth is replayed on the new upstream connections
+	var msetArgs []string
+	for i := 0; i < 20; i++ {
+		key := string([]byte{byte('k'), byte('a' + i)})
+		msetArgs = append(msetArgs, key, key)
+	}
+	requireReplies(t, c.do(append([]string{"MSET"}, msetArgs...)), "+OK\r\n")
+	require.Len(t, c.proxy.upstreams, 2)
+
+	// the connection is closed when the client sends invalid data
+	replies = c.send([]byte("*1\r\n:1\r\n"), 1)
+	require.True(t, replies[0].IsError())
+	require.True(t, c.IsClosed())
+}
+
+func TestRedisProxyReuseUpstream(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	server := newFakeRedis(t, "")
+	defer server.Close()
+	setupClusters(t, map[string][]*fakeRedis{
+		"redis_reuse": {server},
+	})
+	defer proxytest.Cleanup()
+
+	config := &v2.RedisProxy{Cluster: "redis_reuse"}
+	c := newTestClient(t, ctrl, config)
+	requireReplies(t, c.do([]string{"SET", "a", "1"}), "+OK\r\n")
+	c.proxy.OnEvent(api.RemoteClose)
+
+	// the upstream connection without the states is reused
+	c = newTestClient(t, ctrl, config)
+	requireReplies(t, c.do([]string{"GET", "a"}), "$1\r\n1\r\n")
+	require.Equal(t, 1, server.Accepted())
+
+	// the upstream connection with the states is closed
+	requireReplies(t, c.do([]string{"AUTH", ""}), "+OK\r\n")
+	c.proxy.OnEvent(api.RemoteClose)
+	c = newTestClient(t, ctrl, config)
+	requireReplies(t, c.do([]string{"GET", "a"}), "$1\r\n1\r\n")
+	require.Equal(t, 2, server.Accepted())
+}
+
+func TestRedisProxyNoUpstream(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	proxytest.SetupClusters(nil)
+	defer proxytest.Cleanup()
+	c := newTestClient(t, ctrl, &v2.RedisProxy{
+		PrefixRoutes: []*v2.RedisPrefixRoute{
+			{Prefix: "user:", Cluster: "redis_users"},
+		},
+	})
+	requireReplies(t, c.do(
+		[]string{"GET", "a"},
+		[]string{"GET", "user:1"},
+		[]string{"AUTH", "secret"},
+		[]string{"MGET", "a", "user:1"},
+	),
+		"-ERR no upstream cluster for key 'a'\r\n",
+		"-ERR upstream cluster redis_users not found\r\n",
+		"-ERR upstream cluster redis_users not found\r\n",
+		"*2\r\n-ERR no upstream cluster for key 'a'\r\n-ERR upstream cluster redis_users not found\r\n",
+	)
+}
+
+func TestParseRedisProxy(t *testing.T) {
+	p, err := ParseRedisProxy(map[string]interface{}{
+		"stat_prefix": "redis",
+		"cluster":     "default",
+		"prefix_routes": []interface{}{
+			map[string]interface{}{
+				"prefix":        "user:",
+				"cluster":       "users",
+				"remove_prefix": true,
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "default", p.Cluster)
+	require.Equal(t, &v2.RedisPrefixRoute{Prefix: "user:", Cluster: "users", RemovePrefix: true}, p.PrefixRoutes[0])
+
+	_, err = ParseRedisProxy(map[string]interface{}{})
+	require.Error(t, err)
+	_, err = ParseRedisProxy(map[string]interface{}{
+		"prefix_routes": []interface{}{map[string]interface{}{"prefix": "user:"}},
+	})
+	require.Error(t, err)
+	_, err = CreateRedisProxyFactory(map[string]interface{}{"cluster": "default"})
+	require.NoError(t, err)
+}
@@ -0,0 +1,290 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redisproxy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// the RESP2 and RESP3 types
+const (
+	TypeSimpleString   = '+'
+	TypeError          = '-'
+	TypeInteger        = ':'
+	TypeBulkString     = '$'
+	TypeArray          = '*'
+	TypeNull           = '_'
+	TypeBoolean        = '#'
+	TypeDouble         = ','
+	TypeBigNumber      = '('
+	TypeBulkError      = '!'
+	TypeVerbatimString = '='
+	TypeMap            = '%'
+	TypeSet            = '~'
+	TypeAttribute      = '|'
+	TypePush           = '>'
+)
+
+const (
+	maxBulkLength  = 512 * 1024 * 1024
+	maxArrayLength = 1024 * 1024
+	maxInlineSize  = 64 * 1024
+)
+
+var (
+	errIncomplete = errors.New("incomplete resp data")
+	crlf          = []byte("\r\n")
+)
+
+// Value is a RESP value
+type Value struct {
+	Type byte
+	// Str is the data of the string, error, double and big number types
+	Str []byte
+	// Int is the data of the integer and boolean types
+	Int int64
+	// Array is the elements of the aggregate types, the map keys and values are flattened
+	Array []*Value
+	// Null is the RESP2 null bulk string and null array
+	Null bool
+	// Attribute is the RESP3 attribute sent before the value
+	Attribute *Value
+}
+
+// IsError returns true if the value is an error reply
+func (v *Value) IsError() bool {
+	return v.Type == TypeError || v.Type == TypeBulkError
+}
+
+func simpleString(s string) *Value {
+	return &Value{Type: TypeSimpleString, Str: []byte(s)}
+}
+
+func errorValue(format string, args ...interface{}) *Value {
+	return &Value{Type: TypeError, Str: []byte(fmt.Sprintf(format, args...))}
+}
+
+func integerValue(i int64) *Value {
+	return &Value{Type: TypeInteger, Int: i}
+}
+
+func bulkString(b []byte) *Value {
+	return &Value{Type: TypeBulkString, Str: b}
+}
+
+func arrayValue(values []*Value) *Value {
+	return &Value{Type: TypeArray, Array: values}
+}
+
+// Encode appends the RESP encoded value to b
+func (v *Value) Encode(b []byte) []byte {
+	if v.Attribute != nil {
+		b = v.Attribute.Encode(b)
+	}
+	b = append(b, v.Type)
+	switch v.Type {
+	case TypeSimpleString, TypeError, TypeDouble, TypeBigNumber:
+		b = append(b, v.Str...)
+	case TypeInteger:
+		b = strconv.AppendInt(b, v.Int, 10)
+	case TypeBoolean:
+		if v.Int != 0 {
+			b = append(b, 't')
+		} else {
+			b = append(b, 'f')
+		}
+	case TypeNull:
+	case TypeBulkString, TypeBulkError, TypeVerbatimString:
+		if v.Null {
+			b = append(b, "-1"...)
+			break
+		}
+		b = strconv.AppendInt(b, int64(len(v.Str)), 10)
+		b = append(b, crlf...)
+		b = append(b, v.Str...)
+	case TypeArray, TypeSet, TypePush, TypeMap, TypeAttribute:
+		if v.Null {
+			b = append(b, "-1"...)
+			break
+		}
+		n := len(v.Array)
+		if v.Type == TypeMap || v.Type == TypeAttribute {
+			n /= 2
+		}
+		b = strconv.AppendInt(b, int64(n), 10)
+		b = append(b, crlf...)
+		for _, e := range v.Array {
+			b = e.Encode(b)
+		}
+		return b
+	}
+	return append(b, crlf...)
+}
+
+// encodeCommand appends the command as an array of bulk strings
+func encodeCommand(b []byte, args [][]byte) []byte {
+	b = append(b, TypeArray)
+	b = strconv.AppendInt(b, int64(len(args)), 10)
+	b = append(b, crlf...)
+	for _, arg := range args {
+		b = append(b, TypeBulkString)
+		b = strconv.AppendInt(b, int64(len(arg)), 10)
+		b = append(b, crlf...)
+		b = append(b, arg...)
+		b = append(b, crlf...)
+	}
+	return b
+}
+
+// readLine returns the line without the crlf and the consumed length
+func readLine(data []byte) ([]byte, int, error) {
+	i := bytes.Index(data, crlf)
+	if i < 0 {
+		if len(data) > maxInlineSize {
+			return nil, 0, errors.New("resp line is too long")
+		}
+		return nil, 0, errIncomplete
+	}
+	return data[:i], i + 2, nil
+}
+
+func parseLength(line []byte, max int) (int, error) {
+	n, err := strconv.Atoi(string(line))
+	if err != nil || n < -1 || n > max {
+		return 0, fmt.Errorf("invalid resp length: %q", line)
+	}
+	return n, nil
+}
+
+// DecodeValue decodes a RESP value from data, returns errIncomplete if more data is needed.
+// The decoded value does not reference data.
+func DecodeValue(data []byte) (*Value, int, error) {
+	if len(data) == 0 {
+		return nil, 0, errIncomplete
+	}
+	line, n, err := readLine(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(line) == 0 {
+		return nil, 0, errors.New("invalid resp value: empty line")
+	}
+	v := &Value{Type: line[0]}
+	body := line[1:]
+	switch v.Type {
+	case TypeSimpleString, TypeError, TypeDouble, TypeBigNumber:
+		v.Str = append([]byte(nil), body...)
+	case TypeInteger:
+		if v.Int, err = strconv.ParseInt(string(body), 10, 64); err != nil {
+			return nil, 0, fmt.Errorf("invalid resp integer: %q", body)
+		}
+	case TypeBoolean:
+		switch string(body) {
+		case "t":
+			v.Int = 1
+		case "f":
+		default:
+			return nil, 0, fmt.Errorf("invalid resp boolean: %q", body)
+		}
+	case TypeNull:
+	case TypeBulkString, TypeBulkError, TypeVerbatimString:
+		length, err := parseLength(body, maxBulkLength)
+		if err != nil {
+			return nil, 0, err
+		}
+		if length < 0 {
+			v.Null = true
+			break
+		}
+		if len(data) < n+length+2 {
+			return nil, 0, errIncomplete
+		}
+		if !bytes.Equal(data[n+length:n+length+2], crlf) {
+			return nil, 0, errors.New("invalid resp bulk string terminator")
+		}
+		v.Str = append([]byte(nil), data[n:n+length]...)
+		n += length + 2
+	case TypeArray, TypeSet, TypePush, TypeMap, TypeAttribute:
+		length, err := parseLength(body, maxArrayLength)
+		if err != nil {
+			return nil, 0, err
+		}
+		if length < 0 {
+			v.Null = true
+			break
+		}
+		if v.Type == TypeMap || v.Type == TypeAttribute {
+			length *= 2
+		}
+		v.Array = make([]*Value, 0, length)
+		for i := 0; i < length; i++ {
+			e, m, err := DecodeValue(data[n:])
+			if err != nil {
+				return nil, 0, err
+			}
+			v.Array = append(v.Array, e)
+			n += m
+		}
+		if v.Type == TypeAttribute {
+			// the attribute is sent before the value it describes
+			described, m, err := DecodeValue(data[n:])
+			if err != nil {
+				return nil, 0, err
+			}
+			described.Attribute = v
+			return described, n + m, nil
+		}
+	default:
+		return nil, 0, fmt.Errorf("unknown resp type: %q", v.Type)
+	}
+	return v, n, nil
+}
+
+// DecodeCommand decodes a command sent by the client, both the array of bulk strings
+// and the inline command are supported. The returned args do not reference data.
+func DecodeCommand(data []byte) ([][]byte, int, error) {
+	if len(data) == 0 {
+		return nil, 0, errIncomplete
+	}
+	if data[0] != TypeArray {
+		line, n, err := readLine(data)
+		if err != nil {
+			return nil, 0, err
+		}
+		var args [][]byte
+		for _, f := range bytes.Fields(line) {
+			args = append(args, append([]byte(nil), f...))
+		}
+		return args, n, nil
+	}
+	v, n, err := DecodeValue(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	args := make([][]byte, 0, len(v.Array))
+	for _, e := range v.Array {
+		if e.Type != TypeBulkString || e.Null {
+			return nil, 0, errors.New("invalid command, expected an array of bulk strings")
+		}
+		args = append(args, e.Str)
+	}
+	return args, n, nil
+}
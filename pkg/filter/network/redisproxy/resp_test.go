@@ -0,0 +1,102 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redisproxy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeValue(t *testing.T) {
+	for _, data := range []string{
+		"+OK\r\n",
+		"-ERR unknown\r\n",
+		":-100\r\n",
+		"$5\r\nhello\r\n",
+		"$0\r\n\r\n",
+		"$-1\r\n",
+		"*-1\r\n",
+		"*3\r\n:1\r\n$3\r\nfoo\r\n*1\r\n+bar\r\n",
+		// resp3
+		"_\r\n",
+		"#t\r\n",
+		"#f\r\n",
+		",3.14\r\n",
+		"(3492890328409238509324850943850943825024385\r\n",
+		"!21\r\nSYNTAX invalid syntax\r\n",
+		"=15\r\ntxt:Some string\r\n",
+		"%2\r\n+first\r\n:1\r\n+second\r\n:2\r\n",
+		"~2\r\n+a\r\n+b\r\n",
+		">2\r\n+message\r\n+hello\r\n",
+		"|1\r\n+key-popularity\r\n%1\r\n$1\r\na\r\n,0.19\r\n*1\r\n:2039\r\n",
+	} {
+		v, n, err := DecodeValue([]byte(data))
+		require.NoError(t, err, data)
+		require.Equal(t, len(data), n, data)
+		require.Equal(t, data, string(v.Encode(nil)), data)
+
+		// any partial data is incomplete
+		for i := 0; i < len(data); i++ {
+			_, _, err := DecodeValue([]byte(data[:i]))
+			require.Equal(t, errIncomplete, err, data[:i])
+		}
+	}
+
+	v, _, err := DecodeValue([]byte("-ERR\r\n"))
+	require.NoError(t, err)
+	require.True(t, v.IsError())
+	v, _, err = DecodeValue([]byte("|1\r\n+a\r\n+b\r\n:1\r\n"))
+	require.NoError(t, err)
+	require.Equal(t, byte(TypeInteger), v.Type)
+	require.NotNil(t, v.Attribute)
+
+	for _, data := range []string{
+		"?unknown\r\n",
+		":abc\r\n",
+		"#x\r\n",
+		"$-2\r\n",
+		"$3\r\nfoobar\r\n",
+		"*2000000\r\n",
+		"\r\n",
+		"*1\r\n\r\n",
+	} {
+		_, _, err := DecodeValue([]byte(data))
+		require.Error(t, err, data)
+		require.NotEqual(t, errIncomplete, err, data)
+	}
+}
+
+func TestDecodeCommand(t *testing.T) {
+	data := encodeCommand(nil, [][]byte{[]byte("SET"), []byte("key"), []byte("va\r\nlue")})
+	args, n, err := DecodeCommand(data)
+	require.NoError(t, err)
+	require.Equal(t, len(data), n)
+	require.Equal(t, [][]byte{[]byte("SET"), []byte("key"), []byte("va\r\nlue")}, args)
+
+	// inline command
+	args, n, err = DecodeCommand([]byte("get  key\r\nping"))
+	require.NoError(t, err)
+	require.Equal(t, 10, n)
+	require.Equal(t, [][]byte{[]byte("get"), []byte("key")}, args)
+	_, _, err = DecodeCommand([]byte("ping"))
+	require.Equal(t, errIncomplete, err)
+
+	_, _, err = DecodeCommand([]byte("*1\r\n:1\r\n"))
+	require.Error(t, err)
+}
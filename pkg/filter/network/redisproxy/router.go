@@ -0,0 +1,150 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redisproxy
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	"github.com/dchest/siphash"
+	"github.com/trainyao/go-maglev"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+)
+
+type prefixRoute struct {
+	prefix       []byte
+	cluster      string
+	removePrefix bool
+}
+
+// router routes the keys to the clusters by the key prefixes
+type router struct {
+	// routes are sorted by the prefix length, the longest prefix is matched first
+	routes          []*prefixRoute
+	defaultCluster  string
+	caseInsensitive bool
+}
+
+func newRouter(config *v2.RedisProxy) *router {
+	r := &router{
+		defaultCluster:  config.Cluster,
+		caseInsensitive: config.CaseInsensitive,
+	}
+	for _, rc := range config.PrefixRoutes {
+		prefix := []byte(rc.Prefix)
+		if r.caseInsensitive {
+			prefix = bytes.ToLower(prefix)
+		}
+		r.routes = append(r.routes, &prefixRoute{
+			prefix:       prefix,
+			cluster:      rc.Cluster,
+			removePrefix: rc.RemovePrefix,
+		})
+	}
+	sort.SliceStable(r.routes, func(i, j int) bool {
+		return len(r.routes[i].prefix) > len(r.routes[j].prefix)
+	})
+	return r
+}
+
+// route returns the cluster of the key and the key sent to the cluster
+func (r *router) route(key []byte) (string, []byte) {
+	matchKey := key
+	if r.caseInsensitive {
+		matchKey = bytes.ToLower(key)
+	}
+	for _, pr := range r.routes {
+		if bytes.HasPrefix(matchKey, pr.prefix) {
+			if pr.removePrefix {
+				return pr.cluster, key[len(pr.prefix):]
+			}
+			return pr.cluster, key
+		}
+	}
+	return r.defaultCluster, key
+}
+
+// anyCluster returns a cluster for the commands without any key
+func (r *router) anyCluster() string {
+	if r.defaultCluster != "" || len(r.routes) == 0 {
+		return r.defaultCluster
+	}
+	return r.routes[0].cluster
+}
+
+// hashKey hashes the key for sharding. If the key contains a hash tag like {user1000}.following,
+// only the tag is hashed, so the keys with the same tag are sent to the same host.
+func hashKey(key []byte) uint64 {
+	if start := bytes.IndexByte(key, '{'); start >= 0 {
+		if end := bytes.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return siphash.Hash(0xbeefcafebabedead, 0, key)
+}
+
+// shardTable is the maglev table of a cluster's hosts
+type shardTable struct {
+	hosts types.HostSet
+	table *maglev.Table
+}
+
+// shardTables caches the shard table of each cluster, the table is rebuilt when the hosts changed
+var shardTables sync.Map
+
+func getShardTable(clusterName string, hosts types.HostSet) *shardTable {
+	if v, ok := shardTables.Load(clusterName); ok {
+		if st := v.(*shardTable); st.hosts == hosts {
+			return st
+		}
+	}
+	st := &shardTable{hosts: hosts}
+	names := make([]string, 0, hosts.Size())
+	hosts.Range(func(host types.Host) bool {
+		names = append(names, host.AddressString())
+		return true
+	})
+	if len(names) > 0 && len(names) < maglev.BigM {
+		st.table = maglev.New(names, uint64(maglev.SmallM))
+	} else if len(names) > 0 {
+		log.DefaultLogger.Errorf("[redis proxy] cluster %s host count too large for sharding: %d", clusterName, len(names))
+	}
+	shardTables.Store(clusterName, st)
+	return st
+}
+
+// chooseHost chooses the host of the key by consistent hashing,
+// the next healthy host is chosen if the host is unhealthy.
+func chooseHost(snapshot types.ClusterSnapshot, key []byte) types.Host {
+	st := getShardTable(snapshot.ClusterInfo().Name(), snapshot.HostSet())
+	if st.table == nil {
+		return nil
+	}
+	index := st.table.Lookup(hashKey(key))
+	total := st.hosts.Size()
+	for i := 0; i < total; i++ {
+		host := st.hosts.Get((index + i) % total)
+		if host.Health() {
+			return host
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redisproxy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/upstream/cluster"
+)
+
+func TestRouter(t *testing.T) {
+	r := newRouter(&v2.RedisProxy{
+		Cluster: "default",
+		PrefixRoutes: []*v2.RedisPrefixRoute{
+			{Prefix: "user:", Cluster: "user"},
+			{Prefix: "user:vip:", Cluster: "vip", RemovePrefix: true},
+		},
+	})
+	for _, tc := range []struct {
+		key, cluster, routed string
+	}{
+		{"user:1", "user", "user:1"},
+		{"user:vip:1", "vip", "1"},
+		{"USER:1", "default", "USER:1"},
+		{"order:1", "default", "order:1"},
+	} {
+		clusterName, key := r.route([]byte(tc.key))
+		require.Equal(t, tc.cluster, clusterName, tc.key)
+		require.Equal(t, tc.routed, string(key), tc.key)
+	}
+	require.Equal(t, "default", r.anyCluster())
+
+	r = newRouter(&v2.RedisProxy{
+		CaseInsensitive: true,
+		PrefixRoutes: []*v2.RedisPrefixRoute{
+			{Prefix: "User:", Cluster: "user", RemovePrefix: true},
+		},
+	})
+	clusterName, key := r.route([]byte("USER:Abc"))
+	require.Equal(t, "user", clusterName)
+	require.Equal(t, "Abc", string(key))
+	clusterName, _ = r.route([]byte("order:1"))
+	require.Equal(t, "", clusterName)
+	require.Equal(t, "user", r.anyCluster())
+}
+
+func TestHashKey(t *testing.T) {
+	require.Equal(t, hashKey([]byte("user1000")), hashKey([]byte("{user1000}.following")))
+	require.Equal(t, hashKey([]byte("{user1000}.followers")), hashKey([]byte("{user1000}.following")))
+	require.NotEqual(t, hashKey([]byte("foo{}")), hashKey([]byte("foo{}bar")))
+	require.NotEqual(t, hashKey([]byte("a")), hashKey([]byte("b")))
+}
+
+func TestChooseHost(t *testing.T) {
+	clusterConfig := v2.Cluster{
+		Name:        "redis_shard",
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_ROUNDROBIN,
+	}
+	var hosts []v2.Host
+	for _, addr := range []string{"127.0.0.1:6379", "127.0.0.1:6380", "127.0.0.1:6381"} {
+		hosts = append(hosts, v2.Host{HostConfig: v2.HostConfig{Address: addr}})
+	}
+	cluster.NewClusterManagerSingleton([]v2.Cluster{clusterConfig}, map[string][]v2.Host{"redis_shard": hosts}, nil)
+	defer cluster.GetClusterMngAdapterInstance().Destroy()
+	adapter := cluster.GetClusterMngAdapterInstance()
+
+	chosen := func() map[string]string {
+		snapshot := adapter.GetClusterSnapshot(context.Background(), "redis_shard")
+		result := map[string]string{}
+		for i := 0; i < 100; i++ {
+			key := []byte{byte('a' + i%26), byte('a' + i/26)}
+			host := chooseHost(snapshot, key)
+			require.NotNil(t, host)
+			result[string(key)] = host.AddressString()
+		}
+		return result
+	}
+	before := chosen()
+	used := map[string]bool{}
+	for _, addr := range before {
+		used[addr] = true
+	}
+	require.Len(t, used, 3)
+	require.Equal(t, before, chosen())
+
+	// the keys of the removed host are moved, the others are kept
+	require.NoError(t, adapter.TriggerHostDel("redis_shard", []string{"127.0.0.1:6381"}))
+	after := chosen()
+	for key, addr := range before {
+		if addr != "127.0.0.1:6381" {
+			require.Equal(t, addr, after[key], key)
+		} else {
+			require.NotEqual(t, addr, after[key], key)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redisproxy
+
+import (
+	"sync"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"mosn.io/mosn/pkg/metrics"
+)
+
+// unknownCommand is used in the metrics of the unsupported commands, to avoid high label cardinality
+const unknownCommand = "unknown"
+
+type commandStats struct {
+	CommandTotal gometrics.Counter
+	CommandError gometrics.Counter
+	CommandTime  gometrics.Histogram
+}
+
+var commandStatsCache sync.Map
+
+func getCommandStats(statPrefix, command string) *commandStats {
+	key := statPrefix + "|" + command
+	if v, ok := commandStatsCache.Load(key); ok {
+		return v.(*commandStats)
+	}
+	s := metrics.NewRedisCommandStats(statPrefix, command)
+	v, _ := commandStatsCache.LoadOrStore(key, &commandStats{
+		CommandTotal: s.Counter(metrics.RedisCommandTotal),
+		CommandError: s.Counter(metrics.RedisCommandError),
+		CommandTime:  s.Histogram(metrics.RedisCommandTime),
+	})
+	return v.(*commandStats)
+}
@@ -19,17 +19,22 @@ package dubbo
 
 import (
 	"context"
+	"net/http"
 
 	"mosn.io/api"
 	v2 "mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/istio"
 	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/http2"
 	"mosn.io/mosn/pkg/protocol/xprotocol/dubbo"
 	"mosn.io/mosn/pkg/types"
 	"mosn.io/pkg/buffer"
 	"mosn.io/pkg/variable"
 )
 
+const grpcStatusHeader = "grpc-status"
+
 func init() {
 	api.RegisterStream(v2.DubboStream, buildStream)
 }
@@ -44,11 +49,55 @@ func buildStream(conf map[string]interface{}) (api.StreamFilterChainFactory, err
 func (f *factory) CreateFilterChain(ctx context.Context, callbacks api.StreamFilterChainFactoryCallbacks) {
 	filter := buildDubboFilter(ctx)
 	callbacks.AddStreamReceiverFilter(filter, api.BeforeRoute)
+	callbacks.AddStreamReceiverFilter(&tripleRestoreFilter{filter: filter}, api.AfterRoute)
 	callbacks.AddStreamSenderFilter(filter, api.BeforeSend)
 }
 
 type dubboFilter struct {
 	handler api.StreamReceiverFilterHandler
+	// triple is the original request of the Triple stream, which is restored after route
+	triple *tripleRequest
+}
+
+// tripleRequest is the host, the path and the original headers changed for the dubbo routes
+type tripleRequest struct {
+	host, path string
+	headers    []tripleHeader
+}
+
+// tripleHeader is the original value of a header, the header is deleted when restoring if it did not exist
+type tripleHeader struct {
+	key, value string
+	exists     bool
+}
+
+// setHeader sets the header and saves the original value for restoring
+func (t *tripleRequest) setHeader(headers api.HeaderMap, key, value string) {
+	saved := false
+	for _, h := range t.headers {
+		if h.key == key {
+			saved = true
+			break
+		}
+	}
+	if !saved {
+		old, ok := headers.Get(key)
+		t.headers = append(t.headers, tripleHeader{key: key, value: old, exists: ok})
+	}
+	headers.Set(key, value)
+}
+
+// restore restores the host, the path and the headers of the Triple request
+func (t *tripleRequest) restore(ctx context.Context, headers api.HeaderMap) {
+	variable.SetString(ctx, types.VarHost, t.host)
+	variable.SetString(ctx, types.VarPath, t.path)
+	for _, h := range t.headers {
+		if h.exists {
+			headers.Set(h.key, h.value)
+		} else {
+			headers.Del(h.key)
+		}
+	}
 }
 
 func buildDubboFilter(ctx context.Context) *dubboFilter {
@@ -60,7 +109,7 @@ func (d *dubboFilter) OnDestroy() {}
 func (d *dubboFilter) OnReceive(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
 
 	proto, err := variable.Get(ctx, types.VariableDownStreamProtocol)
-	if err != nil || proto == nil || dubbo.ProtocolName != proto {
+	if err != nil || proto == nil || (dubbo.ProtocolName != proto && protocol.HTTP2 != proto) {
 		return api.StreamFilterContinue
 	}
 
@@ -70,6 +119,10 @@ func (d *dubboFilter) OnReceive(ctx context.Context, headers api.HeaderMap, buf
 	}
 	listener := lv.(string)
 
+	if proto == protocol.HTTP2 {
+		return d.onTripleReceive(ctx, listener, headers)
+	}
+
 	service, ok := headers.Get(dubbo.ServiceNameHeader)
 	if !ok {
 		log.DefaultLogger.Errorf("%s is empty, may be the protocol is not dubbo", dubbo.ServiceNameHeader)
@@ -97,6 +150,69 @@ func (d *dubboFilter) OnReceive(ctx context.Context, headers api.HeaderMap, buf
 	return api.StreamFilterContinue
 }
 
+// onTripleReceive extracts the service aware meta of the Triple request into the variables and headers,
+// and adapts the host and path in the same way as dubbo, so the dubbo routes are applied unchanged.
+// The host, path and headers are restored after route, because the Triple request is sent to the upstream.
+func (d *dubboFilter) onTripleReceive(ctx context.Context, listener string, headers api.HeaderMap) api.StreamFilterStatus {
+	path, _ := variable.GetString(ctx, types.VarPath)
+	if !dubbo.IsTripleRequest(path, headers) {
+		return api.StreamFilterContinue
+	}
+	meta, _ := dubbo.GetTripleServiceAwareMeta(ctx, path, headers)
+	service, method := meta[dubbo.ServiceNameHeader], meta[dubbo.MethodNameHeader]
+
+	host, _ := variable.GetString(ctx, types.VarHost)
+	d.triple = &tripleRequest{host: host, path: path}
+	for _, k := range []string{dubbo.ServiceNameHeader, dubbo.InterfaceNameHeader, dubbo.MethodNameHeader,
+		dubbo.VersionNameHeader, dubbo.GroupNameHeader} {
+		if meta[k] == "" {
+			continue
+		}
+		d.triple.setHeader(headers, k, meta[k])
+	}
+	variable.SetString(ctx, types.VarHost, service)
+	variable.SetString(ctx, types.VarPath, "/")
+
+	variable.SetString(ctx, VarDubboRequestService, service)
+	variable.SetString(ctx, VarDubboRequestMethod, method)
+	variable.SetString(ctx, VarDubboRequestVersion, meta[dubbo.VersionNameHeader])
+	variable.SetString(ctx, VarDubboRequestGroup, meta[dubbo.GroupNameHeader])
+
+	if stats := getStats(listener, service, method); stats != nil {
+		stats.RequestServiceInfo.Inc(1)
+	}
+
+	// only the configured pod labels are added, the Triple headers are sent to the upstream
+	// as http2 headers, all the labels may be too large or leak the pod information.
+	podLabels := istio.GetPodLabels()
+	for _, k := range triplePodLabels {
+		if v, ok := podLabels[k]; ok {
+			d.triple.setHeader(headers, k, v)
+		}
+	}
+
+	return api.StreamFilterContinue
+}
+
+// tripleRestoreFilter restores the Triple request changed by the dubbo filter after route
+type tripleRestoreFilter struct {
+	filter *dubboFilter
+}
+
+func (f *tripleRestoreFilter) OnReceive(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
+	triple := f.filter.triple
+	if triple == nil {
+		return api.StreamFilterContinue
+	}
+	f.filter.triple = nil
+	triple.restore(ctx, headers)
+	return api.StreamFilterContinue
+}
+
+func (f *tripleRestoreFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {}
+
+func (f *tripleRestoreFilter) OnDestroy() {}
+
 func (d *dubboFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
 	d.handler = handler
 }
@@ -127,6 +243,13 @@ func (d *dubboFilter) Append(ctx context.Context, headers api.HeaderMap, buf buf
 	switch frame := headers.(type) {
 	case *dubbo.Frame:
 		isSuccess = frame.GetStatusCode() == dubbo.RespStatusOK
+	case *http2.RspHeader:
+		// the triple status is in the headers of the trailers-only response, or in the trailers
+		status, ok := frame.Get(grpcStatusHeader)
+		if !ok && trailers != nil {
+			status, _ = trailers.Get(grpcStatusHeader)
+		}
+		isSuccess = frame.Rsp.StatusCode == http.StatusOK && status == "0"
 	default:
 		log.DefaultLogger.Errorf("this filter {%s} just for dubbo protocol, please check your config.", v2.DubboStream)
 		return api.StreamFiltertermination
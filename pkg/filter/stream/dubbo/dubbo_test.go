@@ -0,0 +1,104 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubbo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/istio"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/xprotocol/dubbo"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/variable"
+)
+
+func TestTripleRoute(t *testing.T) {
+	// the pod labels are loaded once, so the labels file should be ready before the first request
+	dir := t.TempDir()
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "labels"), []byte("zone=\"gz\"\napp=\"greeter\"\n"), 0644))
+	istio.IstioPodInfoPath = dir
+	Init(map[string]interface{}{
+		"triple_pod_labels": []interface{}{"zone", "missing"},
+	})
+	defer Init(nil)
+	ctx := variable.NewVariableContext(context.Background())
+	require.Nil(t, variable.Set(ctx, types.VariableDownStreamProtocol, protocol.HTTP2))
+	require.Nil(t, variable.Set(ctx, types.VariableListenerName, "test_listener"))
+	require.Nil(t, variable.SetString(ctx, types.VarHost, "127.0.0.1:20000"))
+	require.Nil(t, variable.SetString(ctx, types.VarPath, "/org.apache.dubbo.Greeter/sayHello"))
+	headers := protocol.CommonHeader{
+		"content-type":        "application/grpc+proto",
+		"tri-service-version": "1.0.0",
+		"group":               "old",
+		"version":             "client",
+	}
+
+	filter := buildDubboFilter(ctx)
+	restore := &tripleRestoreFilter{filter: filter}
+	assert.Equal(t, api.StreamFilterContinue, filter.OnReceive(ctx, headers, nil, nil))
+
+	// the host, path and headers are same as the dubbo requests when routing
+	host, _ := variable.GetString(ctx, types.VarHost)
+	assert.Equal(t, "org.apache.dubbo.Greeter", host)
+	path, _ := variable.GetString(ctx, types.VarPath)
+	assert.Equal(t, "/", path)
+	for k, v := range map[string]string{
+		dubbo.ServiceNameHeader:   "org.apache.dubbo.Greeter",
+		dubbo.InterfaceNameHeader: "org.apache.dubbo.Greeter",
+		dubbo.MethodNameHeader:    "sayHello",
+		dubbo.VersionNameHeader:   "1.0.0",
+		"zone":                    "gz",
+	} {
+		value, _ := headers.Get(k)
+		assert.Equal(t, v, value, k)
+	}
+	// only the configured pod labels are added
+	_, ok := headers.Get("app")
+	assert.False(t, ok)
+	service, _ := variable.GetString(ctx, VarDubboRequestService)
+	assert.Equal(t, "org.apache.dubbo.Greeter", service)
+
+	// the triple request is restored after route
+	assert.Equal(t, api.StreamFilterContinue, restore.OnReceive(ctx, headers, nil, nil))
+	host, _ = variable.GetString(ctx, types.VarHost)
+	assert.Equal(t, "127.0.0.1:20000", host)
+	path, _ = variable.GetString(ctx, types.VarPath)
+	assert.Equal(t, "/org.apache.dubbo.Greeter/sayHello", path)
+	_, ok = headers.Get(dubbo.ServiceNameHeader)
+	assert.False(t, ok)
+	_, ok = headers.Get("zone")
+	assert.False(t, ok)
+	// the overwritten header is restored to the client value
+	version, _ := headers.Get(dubbo.VersionNameHeader)
+	assert.Equal(t, "client", version)
+	// the existing header is kept, the empty group does not overwrite it
+	group, _ := headers.Get(dubbo.GroupNameHeader)
+	assert.Equal(t, "old", group)
+
+	// not a triple request
+	require.Nil(t, variable.SetString(ctx, types.VarPath, "/"))
+	filter = buildDubboFilter(ctx)
+	assert.Equal(t, api.StreamFilterContinue, filter.OnReceive(ctx, protocol.CommonHeader{}, nil, nil))
+	assert.Nil(t, filter.triple)
+}
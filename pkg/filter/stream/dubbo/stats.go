@@ -39,6 +39,10 @@ var (
 
 	podSubsetKey = ""
 	metricPre    = "mosn"
+
+	// triplePodLabels are the pod labels added to the Triple request headers, no labels are added by default
+	triplePodLabelsKey = "triple_pod_labels"
+	triplePodLabels    []string
 )
 
 var (
@@ -26,12 +26,16 @@ const (
 	dubboProtocolName      = "Dubbo_"
 	VarDubboRequestService = dubboProtocolName + "service"
 	VarDubboRequestMethod  = dubboProtocolName + "method"
+	VarDubboRequestVersion = dubboProtocolName + "version"
+	VarDubboRequestGroup   = dubboProtocolName + "group"
 )
 
 var (
 	buildinVariables = []variable.Variable{
 		variable.NewStringVariable(VarDubboRequestService, nil, nil, variable.DefaultStringSetter, 0),
 		variable.NewStringVariable(VarDubboRequestMethod, nil, nil, variable.DefaultStringSetter, 0),
+		variable.NewStringVariable(VarDubboRequestVersion, nil, nil, variable.DefaultStringSetter, 0),
+		variable.NewStringVariable(VarDubboRequestGroup, nil, nil, variable.DefaultStringSetter, 0),
 	}
 )
 
@@ -41,6 +45,8 @@ func Init(conf map[string]interface{}) {
 		variable.Register(buildinVariables[idx])
 	}
 
+	triplePodLabels = parseTriplePodLabels(conf[triplePodLabelsKey])
+
 	// init subset key
 	sskObj := conf[subsetKey]
 	if sskObj == nil {
@@ -54,3 +60,19 @@ func Init(conf map[string]interface{}) {
 	podSubsetKey = ssk
 	return
 }
+
+// parseTriplePodLabels parses the pod labels added to the Triple request headers for routing
+func parseTriplePodLabels(obj interface{}) []string {
+	var labels []string
+	switch v := obj.(type) {
+	case []string:
+		labels = append(labels, v...)
+	case []interface{}:
+		for _, l := range v {
+			if s, ok := l.(string); ok && s != "" {
+				labels = append(labels, s)
+			}
+		}
+	}
+	return labels
+}
@@ -0,0 +1,54 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rbac
+
+import (
+	"context"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/rbac"
+)
+
+func init() {
+	api.RegisterStream(v2.RBACStream, CreateRBACFilterFactory)
+}
+
+type rbacFilterFactory struct {
+	rbac *rbac.RBAC
+}
+
+// CreateRBACFilterFactory creates the rbac stream filter factory
+func CreateRBACFilterFactory(conf map[string]interface{}) (api.StreamFilterChainFactory, error) {
+	cfg, err := rbac.ParseRBAC(conf)
+	if err != nil {
+		return nil, err
+	}
+	r, err := rbac.NewRBAC(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &rbacFilterFactory{
+		rbac: r,
+	}, nil
+}
+
+func (f *rbacFilterFactory) CreateFilterChain(ctx context.Context, callbacks api.StreamFilterChainFactoryCallbacks) {
+	filter := NewRBACFilter(f.rbac)
+	callbacks.AddStreamReceiverFilter(filter, api.BeforeRoute)
+}
@@ -0,0 +1,53 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rbac
+
+import (
+	"context"
+	"net/http"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/rbac"
+	"mosn.io/pkg/buffer"
+)
+
+// RBACFilter denies the requests that not allowed by the rbac rules
+type RBACFilter struct {
+	rbac    *rbac.RBAC
+	handler api.StreamReceiverFilterHandler
+}
+
+func NewRBACFilter(r *rbac.RBAC) *RBACFilter {
+	return &RBACFilter{
+		rbac: r,
+	}
+}
+
+func (f *RBACFilter) OnReceive(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
+	if f.rbac.Allowed(ctx, headers) {
+		return api.StreamFilterContinue
+	}
+	f.handler.SendHijackReply(http.StatusForbidden, headers)
+	return api.StreamFilterStop
+}
+
+func (f *RBACFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
+	f.handler = handler
+}
+
+func (f *RBACFilter) OnDestroy() {}
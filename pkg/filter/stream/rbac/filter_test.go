@@ -0,0 +1,75 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rbac
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/mock"
+	"mosn.io/mosn/pkg/protocol"
+)
+
+func TestRBACFilter(t *testing.T) {
+	factory, err := CreateRBACFilterFactory(map[string]interface{}{
+		"stat_prefix": "test_stream",
+		"rules": map[string]interface{}{
+			"action": "DENY",
+			"policies": []interface{}{
+				map[string]interface{}{
+					"name": "deny-guest",
+					"permissions": []interface{}{
+						map[string]interface{}{"any": true},
+					},
+					"principals": []interface{}{
+						map[string]interface{}{"header": map[string]interface{}{"name": "x-user", "exact": "guest"}},
+					},
+				},
+			},
+		},
+	})
+	require.Nil(t, err)
+	filter := NewRBACFilter(factory.(*rbacFilterFactory).rbac)
+	ctrl := gomock.NewController(t)
+	handler := mock.NewMockStreamReceiverFilterHandler(ctrl)
+	filter.SetReceiveFilterHandler(handler)
+
+	assert.Equal(t, api.StreamFilterContinue, filter.OnReceive(context.Background(), protocol.CommonHeader{"x-user": "admin"}, nil, nil))
+
+	guest := protocol.CommonHeader{"x-user": "guest"}
+	handler.EXPECT().SendHijackReply(http.StatusForbidden, guest).Times(1)
+	assert.Equal(t, api.StreamFilterStop, filter.OnReceive(context.Background(), guest, nil, nil))
+}
+
+func TestCreateRBACFilterFactoryError(t *testing.T) {
+	_, err := CreateRBACFilterFactory(map[string]interface{}{
+		"stat_prefix": "test_stream",
+	})
+	assert.NotNil(t, err)
+	_, err = CreateRBACFilterFactory(map[string]interface{}{
+		"rules": map[string]interface{}{
+			"action": "AUDIT",
+		},
+	})
+	assert.NotNil(t, err)
+}
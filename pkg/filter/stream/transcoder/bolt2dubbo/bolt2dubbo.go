@@ -0,0 +1,185 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bolt2dubbo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"mosn.io/api"
+	apit "mosn.io/api/extensions/transcoder"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+
+	"mosn.io/mosn/pkg/filter/stream/transcoder"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/xprotocol/bolt"
+	"mosn.io/mosn/pkg/protocol/xprotocol/dubbo"
+	"mosn.io/mosn/pkg/protocol/xprotocol/generic"
+	"mosn.io/mosn/pkg/types"
+)
+
+func init() {
+	transcoder.MustRegister("bolt2dubbo", NewTranscoder)
+	// the type used by the transcoder rules, which is {src protocol}_{upstream protocol}
+	transcoder.MustRegister(string(bolt.ProtocolName)+"_"+string(dubbo.ProtocolName), NewTranscoder)
+}
+
+const (
+	hessianSerializationID = 2
+
+	// the flags of the dubbo request, which are the request bit, the two way bit and the serialization id
+	flagRequest = 0x80
+	flagTwoWay  = 0x40
+
+	timeoutAttachment = "timeout"
+)
+
+// bolt2dubbo bridges the SOFARPC clients to the dubbo upstreams, the SOFARPC request is converted
+// to the dubbo invocation, and the hessian arguments and result are copied as they are.
+type bolt2dubbo struct {
+}
+
+func NewTranscoder(cfg map[string]interface{}) apit.Transcoder {
+	return &bolt2dubbo{}
+}
+
+func (t *bolt2dubbo) Accept(ctx context.Context, headers api.HeaderMap, buf api.IoBuffer, trailers api.HeaderMap) bool {
+	req, ok := headers.(*bolt.Request)
+	return ok && req.CmdCode == bolt.CmdCodeRpcRequest && req.Class == generic.SofaRequestClass
+}
+
+func (t *bolt2dubbo) TranscodingRequest(ctx context.Context, headers api.HeaderMap, buf api.IoBuffer, trailers api.HeaderMap) (api.HeaderMap, api.IoBuffer, api.HeaderMap, error) {
+	req, ok := headers.(*bolt.Request)
+	if !ok {
+		return headers, buf, trailers, errors.New("request is not bolt")
+	}
+	if req.Codec != bolt.Hessian2Serialize {
+		return nil, nil, nil, fmt.Errorf("unsupported bolt codec %d", req.Codec)
+	}
+	var body []byte
+	if buf != nil {
+		body = buf.Bytes()
+	}
+	inv, err := generic.DecodeSofaRequest(body)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("decode bolt request failed: %v", err)
+	}
+	if inv.Attachments == nil {
+		inv.Attachments = map[string]string{}
+	}
+	if req.Timeout > 0 {
+		inv.Attachments[timeoutAttachment] = strconv.Itoa(int(req.Timeout))
+	}
+	payload, err := generic.EncodeDubboRequest(inv)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("encode dubbo request failed: %v", err)
+	}
+
+	frame := &dubbo.Frame{
+		Header: dubbo.Header{
+			Magic:           dubbo.MagicTag,
+			Flag:            flagRequest | hessianSerializationID,
+			Direction:       dubbo.EventRequest,
+			SerializationId: hessianSerializationID,
+			CommonHeader: protocol.CommonHeader{
+				dubbo.ServiceNameHeader: inv.Service,
+				dubbo.MethodNameHeader:  inv.Method,
+			},
+		},
+	}
+	if req.CmdType != bolt.CmdTypeRequestOneway {
+		frame.Flag |= flagTwoWay
+		frame.IsTwoWay = true
+	}
+	if inv.Version != "" {
+		frame.Set(dubbo.VersionNameHeader, inv.Version)
+	}
+	if inv.Group != "" {
+		frame.Set(dubbo.GroupNameHeader, inv.Group)
+	}
+	data := buffer.NewIoBufferBytes(payload)
+	frame.SetData(data)
+
+	_ = variable.Set(ctx, types.VariableUpstreamProtocol, dubbo.ProtocolName)
+	return frame, data, nil, nil
+}
+
+// dubboStatusToBolt maps the dubbo response status to the bolt response status
+func dubboStatusToBolt(status byte) uint16 {
+	switch status {
+	case dubbo.RespStatusOK:
+		return bolt.ResponseStatusSuccess
+	case dubbo.RespStatusClientTimeout, dubbo.RespStatusServerTimeout:
+		return bolt.ResponseStatusTimeout
+	case dubbo.RespStatusServiceNotFound:
+		return bolt.ResponseStatusNoProcessor
+	case dubbo.RespStatusServerThreadpoolExhaustedError:
+		return bolt.ResponseStatusServerThreadpoolBusy
+	case dubbo.RespStatusBadRequest:
+		return bolt.ResponseStatusServerDeserialException
+	case dubbo.RespStatusBadResponse:
+		return bolt.ResponseStatusServerSerialException
+	default:
+		return bolt.ResponseStatusServerException
+	}
+}
+
+// newBoltResponse returns a bolt response, the body of which is the SOFARPC response
+func newBoltResponse(status uint16, res *generic.Result, errorMsg string) (*bolt.Response, api.IoBuffer, error) {
+	body, err := generic.EncodeSofaResponse(res, errorMsg)
+	if err != nil {
+		return nil, nil, err
+	}
+	data := buffer.NewIoBufferBytes(body)
+	rsp := bolt.NewRpcResponse(0, status, nil, data)
+	rsp.Class = generic.SofaResponseClass
+	return rsp, data, nil
+}
+
+func (t *bolt2dubbo) TranscodingResponse(ctx context.Context, headers api.HeaderMap, buf api.IoBuffer, trailers api.HeaderMap) (api.HeaderMap, api.IoBuffer, api.HeaderMap, error) {
+	frame, ok := headers.(*dubbo.Frame)
+	if !ok {
+		// if the response is not dubbo response, it maybe come from hijack or send directly response.
+		// so we just returns the original data
+		return headers, buf, trailers, nil
+	}
+	var body []byte
+	if buf != nil {
+		body = buf.Bytes()
+	}
+	if frame.Status != dubbo.RespStatusOK {
+		msg := generic.DecodeError(body)
+		if msg == "" {
+			msg = "dubbo response status " + strconv.Itoa(int(frame.Status))
+		}
+		rsp, data, err := newBoltResponse(dubboStatusToBolt(frame.Status), nil, msg)
+		return rsp, data, nil, err
+	}
+	res, err := generic.DecodeDubboResponse(body)
+	if err != nil {
+		log.Proxy.Errorf(ctx, "[stream filter][transcoder][bolt2dubbo] decode dubbo response failed: %v", err)
+		rsp, data, err := newBoltResponse(bolt.ResponseStatusServerSerialException, nil, "decode dubbo response failed: "+err.Error())
+		return rsp, data, nil, err
+	}
+	rsp, data, err := newBoltResponse(bolt.ResponseStatusSuccess, res, "")
+	return rsp, data, nil, err
+}
@@ -0,0 +1,209 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bolt2dubbo
+
+import (
+	"context"
+	"testing"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+	"github.com/apache/dubbo-go-hessian2/java_exception"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/xprotocol/bolt"
+	"mosn.io/mosn/pkg/protocol/xprotocol/dubbo"
+	"mosn.io/mosn/pkg/protocol/xprotocol/generic"
+	"mosn.io/mosn/pkg/types"
+)
+
+type testUser struct {
+	Name string
+	Age  int32
+}
+
+func (testUser) JavaClassName() string {
+	return "com.example.User"
+}
+
+type sofaRequest struct {
+	TargetAppName           string            `hessian:"targetAppName"`
+	MethodName              string            `hessian:"methodName"`
+	TargetServiceUniqueName string            `hessian:"targetServiceUniqueName"`
+	RequestProps            map[string]string `hessian:"requestProps"`
+	MethodArgSigs           []string          `hessian:"methodArgSigs"`
+}
+
+func (sofaRequest) JavaClassName() string {
+	return "com.alipay.sofa.rpc.core.request.SofaRequest"
+}
+
+type sofaResponse struct {
+	IsError       bool              `hessian:"isError"`
+	ErrorMsg      string            `hessian:"errorMsg"`
+	AppResponse   interface{}       `hessian:"appResponse"`
+	ResponseProps map[string]string `hessian:"responseProps"`
+}
+
+func (sofaResponse) JavaClassName() string {
+	return "com.alipay.sofa.rpc.core.response.SofaResponse"
+}
+
+func init() {
+	hessian.RegisterPOJO(&testUser{})
+	hessian.RegisterPOJO(&sofaRequest{})
+	hessian.RegisterPOJO(&sofaResponse{})
+}
+
+func newBoltRequest(t *testing.T) *bolt.Request {
+	body := generic.EncodeHessian(t, &sofaRequest{
+		TargetAppName:           "app",
+		MethodName:              "greet",
+		TargetServiceUniqueName: "com.example.Greeter:1.0.0:blue",
+		RequestProps:            map[string]string{"trace": "abc"},
+		MethodArgSigs:           []string{"java.lang.String", "com.example.User"},
+	}, "hello", &testUser{Name: "alice", Age: 20})
+	req := bolt.NewRpcRequest(1, nil, buffer.NewIoBufferBytes(body))
+	req.Class = "com.alipay.sofa.rpc.core.request.SofaRequest"
+	req.Timeout = 3000
+	return req
+}
+
+func newDubboResponse(status byte, body []byte) *dubbo.Frame {
+	frame := &dubbo.Frame{
+		Header: dubbo.Header{
+			Magic:           dubbo.MagicTag,
+			Flag:            0x02,
+			Status:          status,
+			Direction:       dubbo.EventResponse,
+			SerializationId: 2,
+			CommonHeader:    protocol.CommonHeader{},
+		},
+	}
+	frame.SetData(buffer.NewIoBufferBytes(body))
+	return frame
+}
+
+func TestTranscodingRequest(t *testing.T) {
+	ctx := variable.NewVariableContext(context.Background())
+	tc := NewTranscoder(nil)
+	req := newBoltRequest(t)
+	assert.True(t, tc.Accept(ctx, req, req.Content, nil))
+	assert.False(t, tc.Accept(ctx, protocol.CommonHeader{}, nil, nil))
+	other := bolt.NewRpcRequest(1, nil, nil)
+	other.Class = "com.example.Request"
+	assert.False(t, tc.Accept(ctx, other, nil, nil))
+
+	headers, buf, _, err := tc.TranscodingRequest(ctx, req, req.Content, nil)
+	require.Nil(t, err)
+	frame, ok := headers.(*dubbo.Frame)
+	require.True(t, ok)
+	assert.Equal(t, byte(0xc2), frame.Flag)
+	assert.True(t, frame.IsTwoWay)
+	assert.Equal(t, api.Request, frame.GetStreamType())
+	service, _ := frame.Get(dubbo.ServiceNameHeader)
+	method, _ := frame.Get(dubbo.MethodNameHeader)
+	version, _ := frame.Get(dubbo.VersionNameHeader)
+	group, _ := frame.Get(dubbo.GroupNameHeader)
+	assert.Equal(t, []string{"com.example.Greeter", "greet", "1.0.0", "blue"}, []string{service, method, version, group})
+	proto, err := variable.Get(ctx, types.VariableUpstreamProtocol)
+	require.Nil(t, err)
+	assert.Equal(t, dubbo.ProtocolName, proto)
+
+	decoded := generic.DecodeHessian(t, buf.Bytes(), 8)
+	assert.Equal(t, []interface{}{"2.0.2", "com.example.Greeter", "1.0.0", "greet",
+		"Ljava/lang/String;Lcom/example/User;", "hello", &testUser{Name: "alice", Age: 20}}, decoded[:7])
+	attachments := decoded[7].(map[interface{}]interface{})
+	assert.Equal(t, "abc", attachments["trace"])
+	assert.Equal(t, "3000", attachments["timeout"])
+	assert.Equal(t, "blue", attachments["group"])
+
+	// the oneway request
+	req = newBoltRequest(t)
+	req.CmdType = bolt.CmdTypeRequestOneway
+	headers, _, _, err = tc.TranscodingRequest(ctx, req, req.Content, nil)
+	require.Nil(t, err)
+	assert.Equal(t, byte(0x82), headers.(*dubbo.Frame).Flag)
+	assert.False(t, headers.(*dubbo.Frame).IsTwoWay)
+
+	// the invalid request
+	req = bolt.NewRpcRequest(1, nil, buffer.NewIoBufferBytes(generic.EncodeHessian(t, "hello")))
+	req.Class = "com.alipay.sofa.rpc.core.request.SofaRequest"
+	_, _, _, err = tc.TranscodingRequest(ctx, req, req.Content, nil)
+	assert.NotNil(t, err)
+}
+
+func TestTranscodingResponse(t *testing.T) {
+	ctx := variable.NewVariableContext(context.Background())
+	tc := NewTranscoder(nil)
+
+	frame := newDubboResponse(dubbo.RespStatusOK, generic.EncodeHessian(t, int32(4), &testUser{Name: "bob", Age: 30},
+		map[string]string{"trace": "abc"}))
+	headers, buf, _, err := tc.TranscodingResponse(ctx, frame, frame.GetData(), nil)
+	require.Nil(t, err)
+	rsp, ok := headers.(*bolt.Response)
+	require.True(t, ok)
+	assert.Equal(t, bolt.ResponseStatusSuccess, rsp.ResponseStatus)
+	assert.Equal(t, "com.alipay.sofa.rpc.core.response.SofaResponse", rsp.Class)
+	result := generic.DecodeHessian(t, buf.Bytes(), 1)[0].(*sofaResponse)
+	assert.False(t, result.IsError)
+	assert.Equal(t, testUser{Name: "bob", Age: 30}, result.AppResponse)
+	assert.Equal(t, "abc", result.ResponseProps["trace"])
+
+	// the exception is returned as the response
+	frame = newDubboResponse(dubbo.RespStatusOK, generic.EncodeHessian(t, int32(0), java_exception.NewException("boom")))
+	headers, buf, _, err = tc.TranscodingResponse(ctx, frame, frame.GetData(), nil)
+	require.Nil(t, err)
+	assert.Equal(t, bolt.ResponseStatusSuccess, headers.(*bolt.Response).ResponseStatus)
+	result = generic.DecodeHessian(t, buf.Bytes(), 1)[0].(*sofaResponse)
+	assert.False(t, result.IsError)
+	assert.Equal(t, "boom", result.AppResponse.(java_exception.Exception).Error())
+
+	// the error status
+	for status, expected := range map[byte]uint16{
+		dubbo.RespStatusServerTimeout:                  bolt.ResponseStatusTimeout,
+		dubbo.RespStatusServiceNotFound:                bolt.ResponseStatusNoProcessor,
+		dubbo.RespStatusServerThreadpoolExhaustedError: bolt.ResponseStatusServerThreadpoolBusy,
+		dubbo.RespStatusBadRequest:                     bolt.ResponseStatusServerDeserialException,
+		dubbo.RespStatusServiceError:                   bolt.ResponseStatusServerException,
+	} {
+		frame = newDubboResponse(status, generic.EncodeHessian(t, "failed"))
+		headers, buf, _, err = tc.TranscodingResponse(ctx, frame, frame.GetData(), nil)
+		require.Nil(t, err)
+		assert.Equal(t, expected, headers.(*bolt.Response).ResponseStatus, status)
+		result = generic.DecodeHessian(t, buf.Bytes(), 1)[0].(*sofaResponse)
+		assert.True(t, result.IsError)
+		assert.Equal(t, "failed", result.ErrorMsg)
+	}
+
+	// the invalid response
+	frame = newDubboResponse(dubbo.RespStatusOK, generic.EncodeHessian(t, "bad"))
+	headers, _, _, err = tc.TranscodingResponse(ctx, frame, frame.GetData(), nil)
+	require.Nil(t, err)
+	assert.Equal(t, bolt.ResponseStatusServerSerialException, headers.(*bolt.Response).ResponseStatus)
+
+	// the hijacked response is returned as it is
+	hijacked := bolt.NewRpcResponse(0, bolt.ResponseStatusNoProcessor, nil, nil)
+	headers, _, _, err = tc.TranscodingResponse(ctx, hijacked, nil, nil)
+	require.Nil(t, err)
+	assert.Equal(t, hijacked, headers)
+}
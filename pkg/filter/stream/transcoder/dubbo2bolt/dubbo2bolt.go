@@ -0,0 +1,204 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubbo2bolt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"mosn.io/api"
+	apit "mosn.io/api/extensions/transcoder"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+
+	"mosn.io/mosn/pkg/filter/stream/transcoder"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/xprotocol/bolt"
+	"mosn.io/mosn/pkg/protocol/xprotocol/dubbo"
+	"mosn.io/mosn/pkg/protocol/xprotocol/generic"
+	"mosn.io/mosn/pkg/types"
+)
+
+func init() {
+	transcoder.MustRegister("dubbo2bolt", NewTranscoder)
+	// the type used by the transcoder rules, which is {src protocol}_{upstream protocol}
+	transcoder.MustRegister(string(dubbo.ProtocolName)+"_"+string(bolt.ProtocolName), NewTranscoder)
+}
+
+const (
+	hessianSerializationID = 2
+
+	defaultVersion = "1.0"
+
+	timeoutAttachment = "timeout"
+
+	// the bolt headers used by the SOFARPC routing and tracing
+	targetServiceHeader = "sofa_head_target_service"
+	targetMethodHeader  = "sofa_head_method_name"
+)
+
+type config struct {
+	// AppName is the caller application name in the SOFARPC request
+	AppName string `json:"app_name,omitempty"`
+	// DefaultVersion is the service version used if the dubbo request has no version,
+	// the version is required by the SOFARPC service unique name.
+	DefaultVersion string `json:"default_version,omitempty"`
+}
+
+// dubbo2bolt bridges the dubbo clients to the SOFARPC upstreams, the dubbo invocation is converted
+// to the SOFARPC request, and the hessian arguments and result are copied as they are.
+type dubbo2bolt struct {
+	cfg config
+}
+
+func NewTranscoder(cfg map[string]interface{}) apit.Transcoder {
+	t := &dubbo2bolt{
+		cfg: config{DefaultVersion: defaultVersion},
+	}
+	if v, ok := cfg["app_name"].(string); ok {
+		t.cfg.AppName = v
+	}
+	if v, ok := cfg["default_version"].(string); ok && v != "" {
+		t.cfg.DefaultVersion = v
+	}
+	return t
+}
+
+func (t *dubbo2bolt) Accept(ctx context.Context, headers api.HeaderMap, buf api.IoBuffer, trailers api.HeaderMap) bool {
+	frame, ok := headers.(*dubbo.Frame)
+	return ok && frame.GetStreamType() == api.Request && !frame.IsHeartbeatFrame()
+}
+
+func (t *dubbo2bolt) TranscodingRequest(ctx context.Context, headers api.HeaderMap, buf api.IoBuffer, trailers api.HeaderMap) (api.HeaderMap, api.IoBuffer, api.HeaderMap, error) {
+	frame, ok := headers.(*dubbo.Frame)
+	if !ok {
+		return headers, buf, trailers, errors.New("request is not dubbo")
+	}
+	if frame.SerializationId != hessianSerializationID {
+		return nil, nil, nil, fmt.Errorf("unsupported dubbo serialization id %d", frame.SerializationId)
+	}
+	var body []byte
+	if buf != nil {
+		body = buf.Bytes()
+	}
+	inv, err := generic.DecodeDubboRequest(body)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("decode dubbo request failed: %v", err)
+	}
+	if inv.Group == "" {
+		inv.Group, _ = frame.Get(dubbo.GroupNameHeader)
+	}
+	if inv.Version == "" {
+		inv.Version = t.cfg.DefaultVersion
+	}
+	payload, err := generic.EncodeSofaRequest(inv, t.cfg.AppName)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("encode bolt request failed: %v", err)
+	}
+
+	uniqueName := generic.UniqueName(inv.Service, inv.Version, inv.Group)
+	data := buffer.NewIoBufferBytes(payload)
+	req := bolt.NewRpcRequest(0, protocol.CommonHeader{
+		types.RPCRouteMatchKey: uniqueName,
+		targetServiceHeader:    uniqueName,
+		targetMethodHeader:     inv.Method,
+	}, data)
+	req.Class = generic.SofaRequestClass
+	if !frame.IsTwoWay {
+		req.CmdType = bolt.CmdTypeRequestOneway
+	}
+	if timeout, err := strconv.Atoi(inv.Attachments[timeoutAttachment]); err == nil && timeout > 0 {
+		req.Timeout = int32(timeout)
+	}
+
+	_ = variable.Set(ctx, types.VariableUpstreamProtocol, bolt.ProtocolName)
+	return req, data, nil, nil
+}
+
+// boltStatusToDubbo maps the bolt response status to the dubbo response status
+func boltStatusToDubbo(status uint16) byte {
+	switch status {
+	case bolt.ResponseStatusSuccess:
+		return dubbo.RespStatusOK
+	case bolt.ResponseStatusTimeout:
+		return dubbo.RespStatusServerTimeout
+	case bolt.ResponseStatusNoProcessor:
+		return dubbo.RespStatusServiceNotFound
+	case bolt.ResponseStatusServerThreadpoolBusy:
+		return dubbo.RespStatusServerThreadpoolExhaustedError
+	case bolt.ResponseStatusServerDeserialException, bolt.ResponseStatusCodecException:
+		return dubbo.RespStatusBadRequest
+	case bolt.ResponseStatusServerSerialException:
+		return dubbo.RespStatusBadResponse
+	default:
+		return dubbo.RespStatusServerError
+	}
+}
+
+func newDubboResponse(status byte, body []byte) (*dubbo.Frame, api.IoBuffer) {
+	frame := &dubbo.Frame{
+		Header: dubbo.Header{
+			Magic:           dubbo.MagicTag,
+			Flag:            hessianSerializationID,
+			Status:          status,
+			Direction:       dubbo.EventResponse,
+			SerializationId: hessianSerializationID,
+			CommonHeader:    protocol.CommonHeader{},
+		},
+	}
+	data := buffer.NewIoBufferBytes(body)
+	frame.SetData(data)
+	return frame, data
+}
+
+func (t *dubbo2bolt) TranscodingResponse(ctx context.Context, headers api.HeaderMap, buf api.IoBuffer, trailers api.HeaderMap) (api.HeaderMap, api.IoBuffer, api.HeaderMap, error) {
+	rsp, ok := headers.(*bolt.Response)
+	if !ok {
+		// if the response is not bolt response, it maybe come from hijack or send directly response.
+		// so we just returns the original data
+		return headers, buf, trailers, nil
+	}
+	if rsp.ResponseStatus != bolt.ResponseStatusSuccess {
+		frame, data := newDubboResponse(boltStatusToDubbo(rsp.ResponseStatus),
+			generic.EncodeError(fmt.Sprintf("bolt response status %d", rsp.ResponseStatus)))
+		return frame, data, nil, nil
+	}
+	var body []byte
+	if buf != nil {
+		body = buf.Bytes()
+	}
+	res, errorMsg, err := generic.DecodeSofaResponse(body)
+	if err != nil {
+		log.Proxy.Errorf(ctx, "[stream filter][transcoder][dubbo2bolt] decode bolt response failed: %v", err)
+		frame, data := newDubboResponse(dubbo.RespStatusBadResponse, generic.EncodeError("decode bolt response failed: "+err.Error()))
+		return frame, data, nil, nil
+	}
+	if errorMsg != "" {
+		frame, data := newDubboResponse(dubbo.RespStatusServiceError, generic.EncodeError(errorMsg))
+		return frame, data, nil, nil
+	}
+	payload, err := generic.EncodeDubboResponse(res)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	frame, data := newDubboResponse(dubbo.RespStatusOK, payload)
+	return frame, data, nil, nil
+}
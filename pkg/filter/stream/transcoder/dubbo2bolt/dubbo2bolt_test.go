@@ -0,0 +1,223 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubbo2bolt
+
+import (
+	"context"
+	"testing"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+	"github.com/apache/dubbo-go-hessian2/java_exception"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/xprotocol/bolt"
+	"mosn.io/mosn/pkg/protocol/xprotocol/dubbo"
+	"mosn.io/mosn/pkg/protocol/xprotocol/generic"
+	"mosn.io/mosn/pkg/types"
+)
+
+type testUser struct {
+	Name string
+	Age  int32
+}
+
+func (testUser) JavaClassName() string {
+	return "com.example.User"
+}
+
+type sofaRequest struct {
+	TargetAppName           string            `hessian:"targetAppName"`
+	MethodName              string            `hessian:"methodName"`
+	TargetServiceUniqueName string            `hessian:"targetServiceUniqueName"`
+	RequestProps            map[string]string `hessian:"requestProps"`
+	MethodArgSigs           []string          `hessian:"methodArgSigs"`
+}
+
+func (sofaRequest) JavaClassName() string {
+	return "com.alipay.sofa.rpc.core.request.SofaRequest"
+}
+
+type sofaResponse struct {
+	IsError       bool              `hessian:"isError"`
+	ErrorMsg      string            `hessian:"errorMsg"`
+	AppResponse   interface{}       `hessian:"appResponse"`
+	ResponseProps map[string]string `hessian:"responseProps"`
+}
+
+func (sofaResponse) JavaClassName() string {
+	return "com.alipay.sofa.rpc.core.response.SofaResponse"
+}
+
+func init() {
+	hessian.RegisterPOJO(&testUser{})
+	hessian.RegisterPOJO(&sofaRequest{})
+	hessian.RegisterPOJO(&sofaResponse{})
+}
+
+func newDubboRequest(t *testing.T, version string, attachments map[interface{}]interface{}) *dubbo.Frame {
+	body := generic.EncodeHessian(t,
+		"2.0.2", "com.example.Greeter", version, "greet",
+		"Ljava/lang/String;Lcom/example/User;",
+		"hello",
+		&testUser{Name: "alice", Age: 20},
+		attachments,
+	)
+	frame := &dubbo.Frame{
+		Header: dubbo.Header{
+			Magic:           dubbo.MagicTag,
+			Flag:            0xc2,
+			Direction:       dubbo.EventRequest,
+			IsTwoWay:        true,
+			SerializationId: 2,
+			CommonHeader:    protocol.CommonHeader{},
+		},
+	}
+	frame.SetData(buffer.NewIoBufferBytes(body))
+	return frame
+}
+
+func TestTranscodingRequest(t *testing.T) {
+	ctx := variable.NewVariableContext(context.Background())
+	tc := NewTranscoder(map[string]interface{}{"app_name": "gateway"})
+	frame := newDubboRequest(t, "2.0.0", map[interface{}]interface{}{
+		"group":   "blue",
+		"timeout": "3000",
+		"trace":   "abc",
+	})
+	assert.True(t, tc.Accept(ctx, frame, frame.GetData(), nil))
+	assert.False(t, tc.Accept(ctx, protocol.CommonHeader{}, nil, nil))
+
+	headers, buf, _, err := tc.TranscodingRequest(ctx, frame, frame.GetData(), nil)
+	require.Nil(t, err)
+	req, ok := headers.(*bolt.Request)
+	require.True(t, ok)
+	assert.Equal(t, "com.alipay.sofa.rpc.core.request.SofaRequest", req.Class)
+	assert.Equal(t, bolt.CmdTypeRequest, req.CmdType)
+	assert.Equal(t, int32(3000), req.Timeout)
+	service, _ := req.Get(types.RPCRouteMatchKey)
+	assert.Equal(t, "com.example.Greeter:2.0.0:blue", service)
+	target, _ := req.Get("sofa_head_target_service")
+	assert.Equal(t, "com.example.Greeter:2.0.0:blue", target)
+	method, _ := req.Get("sofa_head_method_name")
+	assert.Equal(t, "greet", method)
+	proto, err := variable.Get(ctx, types.VariableUpstreamProtocol)
+	require.Nil(t, err)
+	assert.Equal(t, bolt.ProtocolName, proto)
+
+	decoded := generic.DecodeHessian(t, buf.Bytes(), 3)
+	sofaReq := decoded[0].(*sofaRequest)
+	assert.Equal(t, "gateway", sofaReq.TargetAppName)
+	assert.Equal(t, "greet", sofaReq.MethodName)
+	assert.Equal(t, "com.example.Greeter:2.0.0:blue", sofaReq.TargetServiceUniqueName)
+	assert.Equal(t, []string{"java.lang.String", "com.example.User"}, sofaReq.MethodArgSigs)
+	assert.Equal(t, "abc", sofaReq.RequestProps["trace"])
+	assert.Equal(t, "hello", decoded[1])
+	assert.Equal(t, &testUser{Name: "alice", Age: 20}, decoded[2])
+
+	// the default version, the group in the header and the oneway request
+	frame = newDubboRequest(t, "", map[interface{}]interface{}{})
+	frame.Set(dubbo.GroupNameHeader, "green")
+	frame.Flag = 0x82
+	frame.IsTwoWay = false
+	headers, _, _, err = tc.TranscodingRequest(ctx, frame, frame.GetData(), nil)
+	require.Nil(t, err)
+	req = headers.(*bolt.Request)
+	assert.Equal(t, bolt.CmdTypeRequestOneway, req.CmdType)
+	assert.Equal(t, int32(-1), req.Timeout)
+	service, _ = req.Get(types.RPCRouteMatchKey)
+	assert.Equal(t, "com.example.Greeter:1.0:green", service)
+
+	// the unsupported serialization
+	frame = newDubboRequest(t, "", nil)
+	frame.SerializationId = 6
+	_, _, _, err = tc.TranscodingRequest(ctx, frame, frame.GetData(), nil)
+	assert.NotNil(t, err)
+}
+
+func TestTranscodingResponse(t *testing.T) {
+	ctx := variable.NewVariableContext(context.Background())
+	tc := NewTranscoder(nil)
+
+	newResponse := func(status uint16, body []byte) *bolt.Response {
+		rsp := bolt.NewRpcResponse(1, status, nil, buffer.NewIoBufferBytes(body))
+		rsp.Class = "com.alipay.sofa.rpc.core.response.SofaResponse"
+		return rsp
+	}
+
+	rsp := newResponse(bolt.ResponseStatusSuccess, generic.EncodeHessian(t, &sofaResponse{AppResponse: &testUser{Name: "bob", Age: 30}}))
+	headers, buf, _, err := tc.TranscodingResponse(ctx, rsp, rsp.Content, nil)
+	require.Nil(t, err)
+	frame, ok := headers.(*dubbo.Frame)
+	require.True(t, ok)
+	assert.Equal(t, byte(dubbo.RespStatusOK), frame.Status)
+	assert.Equal(t, api.Response, frame.GetStreamType())
+	assert.Equal(t, []interface{}{int32(1), &testUser{Name: "bob", Age: 30}}, generic.DecodeHessian(t, buf.Bytes(), 2))
+
+	// the null value
+	rsp = newResponse(bolt.ResponseStatusSuccess, generic.EncodeHessian(t, &sofaResponse{}))
+	_, buf, _, err = tc.TranscodingResponse(ctx, rsp, rsp.Content, nil)
+	require.Nil(t, err)
+	assert.Equal(t, []interface{}{int32(2)}, generic.DecodeHessian(t, buf.Bytes(), 1))
+
+	// the exception thrown by the service
+	rsp = newResponse(bolt.ResponseStatusSuccess, generic.EncodeHessian(t, &sofaResponse{AppResponse: java_exception.NewException("boom")}))
+	_, buf, _, err = tc.TranscodingResponse(ctx, rsp, rsp.Content, nil)
+	require.Nil(t, err)
+	decoded := generic.DecodeHessian(t, buf.Bytes(), 2)
+	assert.Equal(t, int32(0), decoded[0])
+	assert.Equal(t, "boom", decoded[1].(*java_exception.Exception).Error())
+
+	// the error of the RPC framework
+	rsp = newResponse(bolt.ResponseStatusSuccess, generic.EncodeHessian(t, &sofaResponse{IsError: true, ErrorMsg: "no provider"}))
+	headers, buf, _, err = tc.TranscodingResponse(ctx, rsp, rsp.Content, nil)
+	require.Nil(t, err)
+	assert.Equal(t, byte(dubbo.RespStatusServiceError), headers.(*dubbo.Frame).Status)
+	assert.Equal(t, []interface{}{"no provider"}, generic.DecodeHessian(t, buf.Bytes(), 1))
+
+	// the error status
+	for status, expected := range map[uint16]byte{
+		bolt.ResponseStatusTimeout:                 dubbo.RespStatusServerTimeout,
+		bolt.ResponseStatusNoProcessor:             dubbo.RespStatusServiceNotFound,
+		bolt.ResponseStatusServerThreadpoolBusy:    dubbo.RespStatusServerThreadpoolExhaustedError,
+		bolt.ResponseStatusServerDeserialException: dubbo.RespStatusBadRequest,
+		bolt.ResponseStatusServerException:         dubbo.RespStatusServerError,
+	} {
+		rsp = newResponse(status, nil)
+		headers, buf, _, err = tc.TranscodingResponse(ctx, rsp, rsp.Content, nil)
+		require.Nil(t, err)
+		assert.Equal(t, expected, headers.(*dubbo.Frame).Status, status)
+		assert.Contains(t, generic.DecodeHessian(t, buf.Bytes(), 1)[0], "bolt response status")
+	}
+
+	// the invalid response
+	rsp = newResponse(bolt.ResponseStatusSuccess, generic.EncodeHessian(t, "bad"))
+	headers, _, _, err = tc.TranscodingResponse(ctx, rsp, rsp.Content, nil)
+	require.Nil(t, err)
+	assert.Equal(t, byte(dubbo.RespStatusBadResponse), headers.(*dubbo.Frame).Status)
+
+	// the hijacked response is returned as it is
+	hijacked := &dubbo.Frame{}
+	headers, _, _, err = tc.TranscodingResponse(ctx, hijacked, nil, nil)
+	require.Nil(t, err)
+	assert.Equal(t, hijacked, headers)
+}
@@ -0,0 +1,280 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubbo2triple
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+	"google.golang.org/grpc/codes"
+	"mosn.io/api"
+	apit "mosn.io/api/extensions/transcoder"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+
+	"mosn.io/mosn/pkg/filter/stream/transcoder"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/http2"
+	"mosn.io/mosn/pkg/protocol/xprotocol/dubbo"
+	"mosn.io/mosn/pkg/protocol/xprotocol/generic"
+	"mosn.io/mosn/pkg/types"
+)
+
+func init() {
+	transcoder.MustRegister("dubbo2triple", NewTranscoder)
+	// the type used by the transcoder rules, which is {src protocol}_{upstream protocol}
+	transcoder.MustRegister(string(dubbo.ProtocolName)+"_"+string(protocol.HTTP2), NewTranscoder)
+}
+
+const (
+	// defaultSerializeType is the name of the hessian 2 serialization in the Triple wrapper
+	defaultSerializeType   = "hessian4"
+	hessianSerializationID = 2
+
+	// the flags in the dubbo response body
+	responseValue     = 1
+	responseNullValue = 2
+
+	grpcStatusHeader  = "grpc-status"
+	grpcMessageHeader = "grpc-message"
+	grpcTimeoutHeader = "grpc-timeout"
+)
+
+// the attachments which are sent as the Triple request line and headers
+var reservedAttachments = map[string]bool{
+	"path":                    true,
+	dubbo.InterfaceNameHeader: true,
+	dubbo.VersionNameHeader:   true,
+	dubbo.GroupNameHeader:     true,
+	"dubbo":                   true,
+	"timeout":                 true,
+	"content-type":            true,
+	"te":                      true,
+	grpcTimeoutHeader:         true,
+}
+
+type config struct {
+	SerializeType string `json:"serialize_type,omitempty"`
+}
+
+// dubbo2triple bridges the dubbo 2 clients to the Triple upstreams, the hessian arguments and result
+// are carried by the Triple wrapper messages.
+type dubbo2triple struct {
+	cfg config
+}
+
+func NewTranscoder(cfg map[string]interface{}) apit.Transcoder {
+	t := &dubbo2triple{
+		cfg: config{SerializeType: defaultSerializeType},
+	}
+	if v, ok := cfg["serialize_type"].(string); ok && v != "" {
+		t.cfg.SerializeType = v
+	}
+	return t
+}
+
+func (t *dubbo2triple) Accept(ctx context.Context, headers api.HeaderMap, buf api.IoBuffer, trailers api.HeaderMap) bool {
+	frame, ok := headers.(*dubbo.Frame)
+	return ok && frame.GetStreamType() == api.Request && !frame.IsHeartbeatFrame()
+}
+
+// validHeaderKey checks the attachment key can be sent as an HTTP/2 header
+func validHeaderKey(key string) bool {
+	if key == "" || key[0] == ':' {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if c <= ' ' || c >= 0x7f || strings.IndexByte("\"(),/:;<=>?@[\\]{}", c) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *dubbo2triple) TranscodingRequest(ctx context.Context, headers api.HeaderMap, buf api.IoBuffer, trailers api.HeaderMap) (api.HeaderMap, api.IoBuffer, api.HeaderMap, error) {
+	frame, ok := headers.(*dubbo.Frame)
+	if !ok {
+		return headers, buf, trailers, errors.New("request is not dubbo")
+	}
+	if frame.SerializationId != hessianSerializationID {
+		return nil, nil, nil, fmt.Errorf("unsupported dubbo serialization id %d", frame.SerializationId)
+	}
+	var body []byte
+	if buf != nil {
+		body = buf.Bytes()
+	}
+	inv, err := generic.DecodeDubboRequest(body)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("decode dubbo request failed: %v", err)
+	}
+	// the triple wrapper carries each argument as a standalone hessian stream
+	args, err := inv.Args.Standalone()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("decode dubbo request failed: %v", err)
+	}
+	group := inv.Group
+	if group == "" {
+		group, _ = frame.Get(dubbo.GroupNameHeader)
+	}
+
+	tripleHeaders := protocol.CommonHeader{
+		"content-type": dubbo.TripleContentType,
+		"te":           "trailers",
+	}
+	if inv.Version != "" {
+		tripleHeaders[dubbo.TripleServiceVersionHeader] = inv.Version
+	}
+	if group != "" {
+		tripleHeaders[dubbo.TripleServiceGroupHeader] = group
+	}
+	if timeout, err := strconv.Atoi(inv.Attachments["timeout"]); err == nil && timeout > 0 {
+		tripleHeaders[grpcTimeoutHeader] = strconv.Itoa(timeout) + "m"
+	}
+	for k, v := range inv.Attachments {
+		key := strings.ToLower(k)
+		if !reservedAttachments[key] && validHeaderKey(key) {
+			tripleHeaders[key] = v
+		}
+	}
+
+	wrapper := &requestWrapper{
+		SerializeType: t.cfg.SerializeType,
+		Args:          args,
+		ArgTypes:      inv.ArgTypes,
+	}
+	data := buffer.NewIoBufferBytes(appendGrpcMessage(nil, wrapper.marshal()))
+
+	_ = variable.Set(ctx, types.VariableUpstreamProtocol, protocol.HTTP2)
+	variable.SetString(ctx, types.VarMethod, http.MethodPost)
+	variable.SetString(ctx, types.VarPath, "/"+inv.Service+"/"+inv.Method)
+
+	return tripleHeaders, data, nil, nil
+}
+
+// grpcStatusToDubbo maps the grpc status code to the dubbo response status
+func grpcStatusToDubbo(code codes.Code) byte {
+	switch code {
+	case codes.OK:
+		return dubbo.RespStatusOK
+	case codes.DeadlineExceeded:
+		return dubbo.RespStatusServerTimeout
+	case codes.NotFound, codes.Unimplemented:
+		return dubbo.RespStatusServiceNotFound
+	case codes.ResourceExhausted:
+		return dubbo.RespStatusServerThreadpoolExhaustedError
+	case codes.InvalidArgument:
+		return dubbo.RespStatusBadRequest
+	case codes.Internal, codes.Unavailable:
+		return dubbo.RespStatusServerError
+	default:
+		return dubbo.RespStatusServiceError
+	}
+}
+
+func newDubboResponse(status byte, body []byte) (*dubbo.Frame, api.IoBuffer) {
+	frame := &dubbo.Frame{
+		Header: dubbo.Header{
+			Magic:           dubbo.MagicTag,
+			Flag:            hessianSerializationID,
+			Status:          status,
+			Direction:       dubbo.EventResponse,
+			SerializationId: hessianSerializationID,
+			CommonHeader:    protocol.CommonHeader{},
+		},
+	}
+	data := buffer.NewIoBufferBytes(body)
+	frame.SetData(data)
+	return frame, data
+}
+
+// newDubboErrorResponse returns an error response, the body of which is the error message
+func newDubboErrorResponse(status byte, msg string) (*dubbo.Frame, api.IoBuffer) {
+	encoder := hessian.NewEncoder()
+	_ = encoder.Encode(msg)
+	return newDubboResponse(status, encoder.Buffer())
+}
+
+func (t *dubbo2triple) TranscodingResponse(ctx context.Context, headers api.HeaderMap, buf api.IoBuffer, trailers api.HeaderMap) (api.HeaderMap, api.IoBuffer, api.HeaderMap, error) {
+	rsp, ok := headers.(*http2.RspHeader)
+	if !ok {
+		// if the response is not triple response, it maybe come from hijack or send directly response.
+		// so we just returns the original data
+		return headers, buf, trailers, nil
+	}
+	if rsp.Rsp != nil && rsp.Rsp.StatusCode != http.StatusOK {
+		frame, data := newDubboErrorResponse(dubbo.RespStatusServerError, fmt.Sprintf("triple http status %d", rsp.Rsp.StatusCode))
+		return frame, data, nil, nil
+	}
+
+	// the status is in the headers of the trailers-only response, or in the trailers
+	status, ok := rsp.Get(grpcStatusHeader)
+	msg, _ := rsp.Get(grpcMessageHeader)
+	if !ok && trailers != nil {
+		status, ok = trailers.Get(grpcStatusHeader)
+		msg, _ = trailers.Get(grpcMessageHeader)
+	}
+	if !ok {
+		frame, data := newDubboErrorResponse(dubbo.RespStatusBadResponse, "triple response without grpc-status")
+		return frame, data, nil, nil
+	}
+	code, err := strconv.Atoi(status)
+	if err != nil {
+		frame, data := newDubboErrorResponse(dubbo.RespStatusBadResponse, "invalid grpc-status: "+status)
+		return frame, data, nil, nil
+	}
+	if code != int(codes.OK) {
+		if unescaped, err := url.PathUnescape(msg); err == nil {
+			msg = unescaped
+		}
+		frame, data := newDubboErrorResponse(grpcStatusToDubbo(codes.Code(code)), msg)
+		return frame, data, nil, nil
+	}
+
+	var body []byte
+	if buf != nil {
+		body = buf.Bytes()
+	}
+	message, err := readGrpcMessage(body)
+	wrapper := &responseWrapper{}
+	if err == nil {
+		err = wrapper.unmarshal(message)
+	}
+	if err != nil {
+		log.Proxy.Errorf(ctx, "[stream filter][transcoder][dubbo2triple] decode triple response failed: %v", err)
+		frame, data := newDubboErrorResponse(dubbo.RespStatusBadResponse, "decode triple response failed: "+err.Error())
+		return frame, data, nil, nil
+	}
+
+	// the flag is followed by the hessian serialized result
+	encoder := hessian.NewEncoder()
+	if len(wrapper.Data) == 0 {
+		_ = encoder.Encode(int32(responseNullValue))
+	} else {
+		_ = encoder.Encode(int32(responseValue))
+	}
+	frame, data := newDubboResponse(dubbo.RespStatusOK, append(encoder.Buffer(), wrapper.Data...))
+	return frame, data, nil, nil
+}
@@ -0,0 +1,293 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubbo2triple
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/http2"
+	"mosn.io/mosn/pkg/protocol/xprotocol/dubbo"
+	"mosn.io/mosn/pkg/protocol/xprotocol/generic"
+	"mosn.io/mosn/pkg/types"
+)
+
+type testUser struct {
+	Name string
+	Age  int32
+}
+
+func (testUser) JavaClassName() string {
+	return "com.example.User"
+}
+
+func init() {
+	hessian.RegisterPOJO(&testUser{})
+}
+
+func newDubboRequest(t *testing.T, attachments map[interface{}]interface{}) (*dubbo.Frame, []byte) {
+	body := generic.EncodeHessian(t,
+		"2.0.2", "com.example.Greeter", "1.0.0", "greet",
+		"Ljava/lang/String;Lcom/example/User;Lcom/example/User;[I",
+		"hello",
+		&testUser{Name: "alice", Age: 20},
+		&testUser{Name: "bob", Age: 30},
+		[]int32{1, 2, 3},
+		attachments,
+	)
+	frame := &dubbo.Frame{
+		Header: dubbo.Header{
+			Magic:           dubbo.MagicTag,
+			Flag:            0xc2,
+			Direction:       dubbo.EventRequest,
+			IsTwoWay:        true,
+			SerializationId: 2,
+			CommonHeader:    protocol.CommonHeader{},
+		},
+	}
+	frame.SetData(buffer.NewIoBufferBytes(body))
+	return frame, body
+}
+
+// decodeRequestWrapper decodes the grpc message of the triple request
+func decodeRequestWrapper(t *testing.T, data []byte) *requestWrapper {
+	message, err := readGrpcMessage(data)
+	require.Nil(t, err)
+	w := &requestWrapper{}
+	for len(message) > 0 {
+		num, typ, n := protowire.ConsumeTag(message)
+		require.True(t, n > 0)
+		require.Equal(t, protowire.BytesType, typ)
+		message = message[n:]
+		v, n := protowire.ConsumeBytes(message)
+		require.True(t, n > 0)
+		message = message[n:]
+		switch num {
+		case 1:
+			w.SerializeType = string(v)
+		case 2:
+			w.Args = append(w.Args, v)
+		case 3:
+			w.ArgTypes = append(w.ArgTypes, string(v))
+		}
+	}
+	return w
+}
+
+func TestTranscodingRequest(t *testing.T) {
+	ctx := variable.NewVariableContext(context.Background())
+	tc := NewTranscoder(nil)
+	frame, body := newDubboRequest(t, map[interface{}]interface{}{
+		"group":     "blue",
+		"timeout":   "3000",
+		"interface": "com.example.GreeterService",
+		"TraceId":   "abc",
+		"bad:key":   "ignored",
+	})
+	assert.True(t, tc.Accept(ctx, frame, frame.GetData(), nil))
+	assert.False(t, tc.Accept(ctx, protocol.CommonHeader{}, nil, nil))
+
+	headers, data, trailers, err := tc.TranscodingRequest(ctx, frame, buffer.NewIoBufferBytes(body), nil)
+	require.Nil(t, err)
+	assert.Nil(t, trailers)
+	assert.Equal(t, protocol.CommonHeader{
+		"content-type":        "application/grpc+proto",
+		"te":                  "trailers",
+		"tri-service-version": "1.0.0",
+		"tri-service-group":   "blue",
+		"grpc-timeout":        "3000m",
+		"traceid":             "abc",
+	}, headers)
+
+	proto, _ := variable.Get(ctx, types.VariableUpstreamProtocol)
+	assert.Equal(t, protocol.HTTP2, proto)
+	method, _ := variable.GetString(ctx, types.VarMethod)
+	assert.Equal(t, http.MethodPost, method)
+	path, _ := variable.GetString(ctx, types.VarPath)
+	assert.Equal(t, "/com.example.GreeterService/greet", path)
+
+	w := decodeRequestWrapper(t, data.Bytes())
+	assert.Equal(t, defaultSerializeType, w.SerializeType)
+	assert.Equal(t, []string{"java.lang.String", "com.example.User", "com.example.User", "[I"}, w.ArgTypes)
+	require.Len(t, w.Args, 4)
+	// each argument is a standalone hessian stream
+	expected := []interface{}{"hello", &testUser{Name: "alice", Age: 20}, &testUser{Name: "bob", Age: 30}, []int32{1, 2, 3}}
+	for i, arg := range w.Args {
+		v, err := hessian.NewDecoder(arg).Decode()
+		require.Nil(t, err)
+		assert.Equal(t, expected[i], v)
+	}
+
+	// the serialization other than hessian is not supported
+	frame.SerializationId = 6
+	_, _, _, err = tc.TranscodingRequest(ctx, frame, buffer.NewIoBufferBytes(body), nil)
+	assert.NotNil(t, err)
+}
+
+func TestTranscodingRequestWithoutAttachments(t *testing.T) {
+	ctx := variable.NewVariableContext(context.Background())
+	tc := NewTranscoder(map[string]interface{}{"serialize_type": "hessian2"})
+	body := generic.EncodeHessian(t, "2.0.2", "com.example.Greeter", nil, "ping", "")
+	frame, _ := newDubboRequest(t, nil)
+	frame.Set(dubbo.GroupNameHeader, "red")
+	headers, data, _, err := tc.TranscodingRequest(ctx, frame, buffer.NewIoBufferBytes(body), nil)
+	require.Nil(t, err)
+	assert.Equal(t, protocol.CommonHeader{
+		"content-type":      "application/grpc+proto",
+		"te":                "trailers",
+		"tri-service-group": "red",
+	}, headers)
+	w := decodeRequestWrapper(t, data.Bytes())
+	assert.Equal(t, "hessian2", w.SerializeType)
+	assert.Empty(t, w.Args)
+	path, _ := variable.GetString(ctx, types.VarPath)
+	assert.Equal(t, "/com.example.Greeter/ping", path)
+}
+
+func newTripleResponse(statusCode int, header http.Header) *http2.RspHeader {
+	if header == nil {
+		header = http.Header{}
+	}
+	return http2.NewRspHeader(&http.Response{StatusCode: statusCode, Header: header})
+}
+
+func encodeResponseWrapper(w *responseWrapper) []byte {
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendString(b, w.SerializeType)
+	if w.Data != nil {
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendBytes(b, w.Data)
+	}
+	b = protowire.AppendTag(b, 3, protowire.BytesType)
+	b = protowire.AppendString(b, w.Type)
+	// unknown field
+	b = protowire.AppendTag(b, 10, protowire.VarintType)
+	b = protowire.AppendVarint(b, 1)
+	return appendGrpcMessage(nil, b)
+}
+
+func decodeDubboResponse(t *testing.T, headers api.HeaderMap, data api.IoBuffer) (byte, []interface{}) {
+	frame, ok := headers.(*dubbo.Frame)
+	require.True(t, ok)
+	assert.Equal(t, dubbo.EventResponse, frame.Direction)
+	buf := frame.GetData().Bytes()
+	assert.Equal(t, data.Bytes(), buf)
+	decoder := hessian.NewDecoder(buf)
+	var values []interface{}
+	for {
+		v, err := decoder.Decode()
+		if err != nil {
+			break
+		}
+		values = append(values, v)
+	}
+	return frame.Status, values
+}
+
+func TestTranscodingResponse(t *testing.T) {
+	ctx := context.Background()
+	tc := NewTranscoder(nil)
+
+	// the response in the trailers
+	body := encodeResponseWrapper(&responseWrapper{
+		SerializeType: defaultSerializeType,
+		Data:          generic.EncodeHessian(t, &testUser{Name: "carol", Age: 40}),
+		Type:          "com.example.User",
+	})
+	trailers := http2.NewHeaderMap(http.Header{"Grpc-Status": []string{"0"}})
+	headers, data, outTrailers, err := tc.TranscodingResponse(ctx, newTripleResponse(http.StatusOK, nil), buffer.NewIoBufferBytes(body), trailers)
+	require.Nil(t, err)
+	assert.Nil(t, outTrailers)
+	status, values := decodeDubboResponse(t, headers, data)
+	assert.Equal(t, byte(dubbo.RespStatusOK), status)
+	assert.Equal(t, []interface{}{int32(responseValue), &testUser{Name: "carol", Age: 40}}, values)
+
+	// the null result
+	body = encodeResponseWrapper(&responseWrapper{SerializeType: defaultSerializeType})
+	headers, data, _, err = tc.TranscodingResponse(ctx, newTripleResponse(http.StatusOK, nil), buffer.NewIoBufferBytes(body), trailers)
+	require.Nil(t, err)
+	status, values = decodeDubboResponse(t, headers, data)
+	assert.Equal(t, byte(dubbo.RespStatusOK), status)
+	assert.Equal(t, []interface{}{int32(responseNullValue)}, values)
+
+	for _, cas := range []struct {
+		name     string
+		rsp      *http2.RspHeader
+		body     []byte
+		trailers api.HeaderMap
+		status   byte
+		msg      string
+	}{
+		{
+			name:   "trailers only",
+			rsp:    newTripleResponse(http.StatusOK, http.Header{"Grpc-Status": {"12"}, "Grpc-Message": {"method%20not%20found"}}),
+			status: dubbo.RespStatusServiceNotFound,
+			msg:    "method not found",
+		},
+		{
+			name:     "deadline exceeded",
+			rsp:      newTripleResponse(http.StatusOK, nil),
+			trailers: http2.NewHeaderMap(http.Header{"Grpc-Status": {"4"}, "Grpc-Message": {"timeout"}}),
+			status:   dubbo.RespStatusServerTimeout,
+			msg:      "timeout",
+		},
+		{
+			name:   "http error",
+			rsp:    newTripleResponse(http.StatusServiceUnavailable, nil),
+			status: dubbo.RespStatusServerError,
+			msg:    "triple http status 503",
+		},
+		{
+			name:   "without status",
+			rsp:    newTripleResponse(http.StatusOK, nil),
+			status: dubbo.RespStatusBadResponse,
+			msg:    "triple response without grpc-status",
+		},
+		{
+			name:     "compressed",
+			rsp:      newTripleResponse(http.StatusOK, nil),
+			body:     []byte{1, 0, 0, 0, 0},
+			trailers: trailers,
+			status:   dubbo.RespStatusBadResponse,
+			msg:      "decode triple response failed: compressed grpc message is not supported",
+		},
+	} {
+		headers, data, _, err := tc.TranscodingResponse(ctx, cas.rsp, buffer.NewIoBufferBytes(cas.body), cas.trailers)
+		require.Nil(t, err, cas.name)
+		status, values := decodeDubboResponse(t, headers, data)
+		assert.Equal(t, cas.status, status, cas.name)
+		assert.Equal(t, []interface{}{cas.msg}, values, cas.name)
+	}
+
+	// the hijack response is not transcoded
+	hijack := &dubbo.Frame{}
+	headers, _, _, err = tc.TranscodingResponse(ctx, hijack, nil, nil)
+	require.Nil(t, err)
+	assert.Equal(t, hijack, headers)
+}
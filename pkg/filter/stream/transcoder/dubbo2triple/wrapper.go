@@ -0,0 +1,127 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubbo2triple
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+/**
+ * The Triple wrapper messages, which carry the hessian serialized arguments and result
+ * for the services without the protobuf IDL.
+ *
+ * message TripleRequestWrapper {
+ *   string serializeType = 1;
+ *   repeated bytes args = 2;
+ *   repeated string argTypes = 3;
+ * }
+ *
+ * message TripleResponseWrapper {
+ *   string serializeType = 1;
+ *   bytes data = 2;
+ *   string type = 3;
+ * }
+ */
+
+type requestWrapper struct {
+	SerializeType string
+	Args          [][]byte
+	ArgTypes      []string
+}
+
+type responseWrapper struct {
+	SerializeType string
+	Data          []byte
+	Type          string
+}
+
+func (w *requestWrapper) marshal() []byte {
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendString(b, w.SerializeType)
+	for _, arg := range w.Args {
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendBytes(b, arg)
+	}
+	for _, typ := range w.ArgTypes {
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendString(b, typ)
+	}
+	return b
+}
+
+func (w *responseWrapper) unmarshal(b []byte) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if typ != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			b = b[n:]
+			continue
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		switch num {
+		case 1:
+			w.SerializeType = string(v)
+		case 2:
+			w.Data = append([]byte(nil), v...)
+		case 3:
+			w.Type = string(v)
+		}
+	}
+	return nil
+}
+
+// grpcMessagePrefixSize is the size of the compressed flag and the message length
+const grpcMessagePrefixSize = 5
+
+func appendGrpcMessage(b, message []byte) []byte {
+	b = append(b, 0)
+	n := uint32(len(message))
+	b = append(b, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	return append(b, message...)
+}
+
+// readGrpcMessage reads the first length-prefixed message, the unary response has only one message
+func readGrpcMessage(b []byte) ([]byte, error) {
+	if len(b) < grpcMessagePrefixSize {
+		return nil, errors.New("grpc message is truncated")
+	}
+	if b[0] != 0 {
+		return nil, errors.New("compressed grpc message is not supported")
+	}
+	n := binary.BigEndian.Uint32(b[1:])
+	if uint32(len(b)-grpcMessagePrefixSize) < n {
+		return nil, fmt.Errorf("grpc message is truncated, expect %d bytes", n)
+	}
+	return b[grpcMessagePrefixSize : grpcMessagePrefixSize+int(n)], nil
+}
@@ -0,0 +1,339 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpcjson
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// methodBinding maps a HTTP method and path template to a gRPC method
+type methodBinding struct {
+	method     protoreflect.MethodDescriptor
+	httpMethod string
+	template   *pathTemplate
+	// variables are the resolved field paths of the template variables
+	variables [][]protoreflect.FieldDescriptor
+	// body is empty, * or a top level field of the request message
+	body protoreflect.FieldDescriptor
+	// bodyAll is true if the body is *
+	bodyAll bool
+	// responseBody is a top level field of the response message, the whole message is used if it is nil
+	responseBody protoreflect.FieldDescriptor
+	// grpcPath is the path of the gRPC request, which is /package.Service/Method
+	grpcPath string
+}
+
+// score is the number of literal segments, the more specific binding is preferred
+func (b *methodBinding) score() int {
+	n := 0
+	for _, seg := range b.template.segments {
+		if seg.kind == segmentLiteral {
+			n++
+		}
+	}
+	return n
+}
+
+type descriptorSet struct {
+	bindings []*methodBinding
+	resolver *typeResolver
+}
+
+// match finds the binding of the request, and returns the values of the template variables
+func (s *descriptorSet) match(method, path string) (*methodBinding, []string) {
+	var (
+		matched *methodBinding
+		values  []string
+	)
+	for _, b := range s.bindings {
+		if b.httpMethod != method {
+			continue
+		}
+		if matched != nil && b.score() <= matched.score() {
+			continue
+		}
+		if v, ok := b.template.match(path); ok {
+			matched, values = b, v
+		}
+	}
+	return matched, values
+}
+
+// typeResolver resolves the types in the descriptor set first, and then the linked types
+type typeResolver struct {
+	local *dynamicpb.Types
+}
+
+func (r *typeResolver) FindMessageByName(name protoreflect.FullName) (protoreflect.MessageType, error) {
+	if mt, err := r.local.FindMessageByName(name); err == nil {
+		return mt, nil
+	}
+	return protoregistry.GlobalTypes.FindMessageByName(name)
+}
+
+func (r *typeResolver) FindMessageByURL(url string) (protoreflect.MessageType, error) {
+	if mt, err := r.local.FindMessageByURL(url); err == nil {
+		return mt, nil
+	}
+	return protoregistry.GlobalTypes.FindMessageByURL(url)
+}
+
+func (r *typeResolver) FindExtensionByName(name protoreflect.FullName) (protoreflect.ExtensionType, error) {
+	if xt, err := r.local.FindExtensionByName(name); err == nil {
+		return xt, nil
+	}
+	return protoregistry.GlobalTypes.FindExtensionByName(name)
+}
+
+func (r *typeResolver) FindExtensionByNumber(message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error) {
+	if xt, err := r.local.FindExtensionByNumber(message, field); err == nil {
+		return xt, nil
+	}
+	return protoregistry.GlobalTypes.FindExtensionByNumber(message, field)
+}
+
+// fileResolver resolves the dependencies in the descriptor set first, and then the linked files,
+// so the descriptor sets generated without --include_imports are supported.
+type fileResolver struct {
+	local *protoregistry.Files
+}
+
+func (r *fileResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	if fd, err := r.local.FindFileByPath(path); err == nil {
+		return fd, nil
+	}
+	return protoregistry.GlobalFiles.FindFileByPath(path)
+}
+
+func (r *fileResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if d, err := r.local.FindDescriptorByName(name); err == nil {
+		return d, nil
+	}
+	return protoregistry.GlobalFiles.FindDescriptorByName(name)
+}
+
+// the descriptor sets are parsed once, the key is the descriptor file path or the inline descriptor
+var descriptorSets sync.Map
+
+func getDescriptorSet(cfg *config) (*descriptorSet, error) {
+	key := cfg.ProtoDescriptor
+	if key == "" {
+		key = "bin:" + cfg.ProtoDescriptorBin
+	}
+	key += "|" + strings.Join(cfg.Services, ",")
+	if cfg.AutoMapping {
+		key += "|auto"
+	}
+	if s, ok := descriptorSets.Load(key); ok {
+		return s.(*descriptorSet), nil
+	}
+	s, err := loadDescriptorSet(cfg)
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := descriptorSets.LoadOrStore(key, s)
+	return actual.(*descriptorSet), nil
+}
+
+func loadDescriptorSet(cfg *config) (*descriptorSet, error) {
+	var (
+		data []byte
+		err  error
+	)
+	switch {
+	case cfg.ProtoDescriptor != "":
+		data, err = ioutil.ReadFile(cfg.ProtoDescriptor)
+	case cfg.ProtoDescriptorBin != "":
+		data, err = base64.StdEncoding.DecodeString(cfg.ProtoDescriptorBin)
+	default:
+		err = errors.New("proto_descriptor or proto_descriptor_bin is required")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(data, set); err != nil {
+		return nil, fmt.Errorf("unmarshal descriptor set failed: %v", err)
+	}
+	files, err := buildFiles(set)
+	if err != nil {
+		return nil, err
+	}
+
+	services := make(map[string]bool, len(cfg.Services))
+	for _, name := range cfg.Services {
+		services[name] = true
+	}
+	s := &descriptorSet{
+		resolver: &typeResolver{local: dynamicpb.NewTypes(files)},
+	}
+	for _, fdp := range set.GetFile() {
+		fd, err := files.FindFileByPath(fdp.GetName())
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < fd.Services().Len(); i++ {
+			sd := fd.Services().Get(i)
+			if len(services) > 0 && !services[string(sd.FullName())] {
+				continue
+			}
+			for j := 0; j < sd.Methods().Len(); j++ {
+				bindings, err := newMethodBindings(sd.Methods().Get(j), cfg.AutoMapping)
+				if err != nil {
+					return nil, err
+				}
+				s.bindings = append(s.bindings, bindings...)
+			}
+		}
+	}
+	return s, nil
+}
+
+// buildFiles builds the files in the dependency order, the files in the set may be in any order
+func buildFiles(set *descriptorpb.FileDescriptorSet) (*protoregistry.Files, error) {
+	protos := make(map[string]*descriptorpb.FileDescriptorProto, len(set.GetFile()))
+	for _, fdp := range set.GetFile() {
+		protos[fdp.GetName()] = fdp
+	}
+	files := &protoregistry.Files{}
+	resolver := &fileResolver{local: files}
+	building := make(map[string]bool)
+
+	var build func(name string) error
+	build = func(name string) error {
+		if _, err := files.FindFileByPath(name); err == nil {
+			return nil
+		}
+		fdp, ok := protos[name]
+		if !ok {
+			// the linked files are used
+			return nil
+		}
+		if building[name] {
+			return fmt.Errorf("import cycle of %s", name)
+		}
+		building[name] = true
+		for _, dep := range fdp.GetDependency() {
+			if err := build(dep); err != nil {
+				return err
+			}
+		}
+		fd, err := protodesc.NewFile(fdp, resolver)
+		if err != nil {
+			return fmt.Errorf("build file %s failed: %v", name, err)
+		}
+		return files.RegisterFile(fd)
+	}
+	for _, fdp := range set.GetFile() {
+		if err := build(fdp.GetName()); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func newMethodBindings(md protoreflect.MethodDescriptor, autoMapping bool) ([]*methodBinding, error) {
+	grpcPath := fmt.Sprintf("/%s/%s", md.Parent().FullName(), md.Name())
+	var rules []*annotations.HttpRule
+	if opts, ok := md.Options().(*descriptorpb.MethodOptions); ok && opts != nil && proto.HasExtension(opts, annotations.E_Http) {
+		rule := proto.GetExtension(opts, annotations.E_Http).(*annotations.HttpRule)
+		rules = append(rules, rule)
+		rules = append(rules, rule.GetAdditionalBindings()...)
+	} else if autoMapping {
+		// the method without the annotation is mapped to POST /package.Service/Method
+		rules = append(rules, &annotations.HttpRule{
+			Pattern: &annotations.HttpRule_Post{Post: grpcPath},
+			Body:    "*",
+		})
+	}
+
+	bindings := make([]*methodBinding, 0, len(rules))
+	for _, rule := range rules {
+		b, err := newMethodBinding(md, rule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid http rule of %s: %v", md.FullName(), err)
+		}
+		b.grpcPath = grpcPath
+		bindings = append(bindings, b)
+	}
+	return bindings, nil
+}
+
+func newMethodBinding(md protoreflect.MethodDescriptor, rule *annotations.HttpRule) (*methodBinding, error) {
+	b := &methodBinding{method: md}
+	var path string
+	switch pattern := rule.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		b.httpMethod, path = http.MethodGet, pattern.Get
+	case *annotations.HttpRule_Put:
+		b.httpMethod, path = http.MethodPut, pattern.Put
+	case *annotations.HttpRule_Post:
+		b.httpMethod, path = http.MethodPost, pattern.Post
+	case *annotations.HttpRule_Delete:
+		b.httpMethod, path = http.MethodDelete, pattern.Delete
+	case *annotations.HttpRule_Patch:
+		b.httpMethod, path = http.MethodPatch, pattern.Patch
+	case *annotations.HttpRule_Custom:
+		b.httpMethod, path = pattern.Custom.GetKind(), pattern.Custom.GetPath()
+	default:
+		return nil, errors.New("pattern is not set")
+	}
+
+	var err error
+	if b.template, err = parseTemplate(path); err != nil {
+		return nil, err
+	}
+	for _, v := range b.template.variables {
+		fields, err := resolveFieldPath(md.Input(), v.fieldPath)
+		if err != nil {
+			return nil, err
+		}
+		b.variables = append(b.variables, fields)
+	}
+
+	switch body := rule.GetBody(); body {
+	case "":
+	case "*":
+		b.bodyAll = true
+	default:
+		if b.body = md.Input().Fields().ByName(protoreflect.Name(body)); b.body == nil {
+			return nil, fmt.Errorf("body field %s is not found", body)
+		}
+	}
+	if responseBody := rule.GetResponseBody(); responseBody != "" {
+		if b.responseBody = md.Output().Fields().ByName(protoreflect.Name(responseBody)); b.responseBody == nil {
+			return nil, fmt.Errorf("response body field %s is not found", responseBody)
+		}
+	}
+	return b, nil
+}
@@ -0,0 +1,471 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package grpcjson transcodes the HTTP/JSON requests to the gRPC upstreams.
+//
+// The gRPC methods are loaded from a protobuf descriptor set, and the REST paths are mapped by
+// the google.api.http annotations. The path variables, the query parameters and the JSON body are
+// converted to the request message, and the response messages are converted back to JSON. The
+// server streaming responses are converted to a JSON array, and the client streaming requests are
+// read from a JSON array. The non-OK grpc-status is mapped to the HTTP status code, and the body is
+// the JSON of google.rpc.Status.
+package grpcjson
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"mosn.io/api"
+	apit "mosn.io/api/extensions/transcoder"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+
+	"mosn.io/mosn/pkg/filter/stream/transcoder"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/http"
+	"mosn.io/mosn/pkg/protocol/http2"
+	"mosn.io/mosn/pkg/types"
+)
+
+func init() {
+	transcoder.MustRegister("grpcjson", NewTranscoder)
+}
+
+const (
+	contentTypeJSON = "application/json"
+	contentTypeGrpc = "application/grpc"
+
+	headerGrpcStatus  = "grpc-status"
+	headerGrpcMessage = "grpc-message"
+	headerGrpcDetails = "grpc-status-details-bin"
+
+	// grpcFrameHeaderSize is the size of the compressed flag and the message length
+	grpcFrameHeaderSize = 5
+)
+
+// the headers which are not forwarded
+var skippedHeaders = map[string]bool{
+	"content-type":      true,
+	"content-length":    true,
+	"connection":        true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+	"host":              true,
+	"te":                true,
+	"trailer":           true,
+}
+
+type printOptions struct {
+	AddWhitespace              bool `json:"add_whitespace,omitempty"`
+	AlwaysPrintPrimitiveFields bool `json:"always_print_primitive_fields,omitempty"`
+	AlwaysPrintEnumsAsInts     bool `json:"always_print_enums_as_ints,omitempty"`
+	PreserveProtoFieldNames    bool `json:"preserve_proto_field_names,omitempty"`
+}
+
+type config struct {
+	// ProtoDescriptor is the path of the descriptor set file, which is generated by protoc --descriptor_set_out
+	ProtoDescriptor string `json:"proto_descriptor,omitempty"`
+	// ProtoDescriptorBin is the base64 encoded descriptor set
+	ProtoDescriptorBin string `json:"proto_descriptor_bin,omitempty"`
+	// Services are the full names of the transcoded services, all the services are transcoded if it is empty
+	Services []string `json:"services,omitempty"`
+	// AutoMapping maps the methods without the annotations to POST /package.Service/Method
+	AutoMapping                  bool         `json:"auto_mapping,omitempty"`
+	IgnoreUnknownQueryParameters bool         `json:"ignore_unknown_query_parameters,omitempty"`
+	PrintOptions                 printOptions `json:"print_options,omitempty"`
+}
+
+// grpcJSON is created for each request, the matched binding is kept to convert the response
+type grpcJSON struct {
+	cfg     config
+	set     *descriptorSet
+	binding *methodBinding
+	// values are the values of the template variables
+	values []string
+}
+
+func NewTranscoder(cfg map[string]interface{}) apit.Transcoder {
+	t := &grpcJSON{}
+	t.cfg.ProtoDescriptor, _ = cfg["proto_descriptor"].(string)
+	t.cfg.ProtoDescriptorBin, _ = cfg["proto_descriptor_bin"].(string)
+	if services, ok := cfg["services"].([]interface{}); ok {
+		for _, s := range services {
+			if name, ok := s.(string); ok {
+				t.cfg.Services = append(t.cfg.Services, name)
+			}
+		}
+	}
+	t.cfg.AutoMapping, _ = cfg["auto_mapping"].(bool)
+	t.cfg.IgnoreUnknownQueryParameters, _ = cfg["ignore_unknown_query_parameters"].(bool)
+	if opts, ok := cfg["print_options"].(map[string]interface{}); ok {
+		t.cfg.PrintOptions.AddWhitespace, _ = opts["add_whitespace"].(bool)
+		t.cfg.PrintOptions.AlwaysPrintPrimitiveFields, _ = opts["always_print_primitive_fields"].(bool)
+		t.cfg.PrintOptions.AlwaysPrintEnumsAsInts, _ = opts["always_print_enums_as_ints"].(bool)
+		t.cfg.PrintOptions.PreserveProtoFieldNames, _ = opts["preserve_proto_field_names"].(bool)
+	}
+	return t
+}
+
+// Accept accepts the HTTP/1 requests which match the bindings of the descriptor set
+func (t *grpcJSON) Accept(ctx context.Context, headers api.HeaderMap, buf api.IoBuffer, trailers api.HeaderMap) bool {
+	header, ok := headers.(http.RequestHeader)
+	if !ok {
+		return false
+	}
+	set, err := getDescriptorSet(&t.cfg)
+	if err != nil {
+		log.Proxy.Errorf(ctx, "[stream filter][transcoder][grpcjson] load descriptor set failed: %v", err)
+		return false
+	}
+	// the escaped path is used, so the escaped slashes are kept in the variables
+	path, err := variable.GetString(ctx, types.VarPathOriginal)
+	if err != nil || path == "" {
+		path, _ = variable.GetString(ctx, types.VarPath)
+	}
+	t.set = set
+	t.binding, t.values = set.match(string(header.Method()), path)
+	return t.binding != nil
+}
+
+func (t *grpcJSON) TranscodingRequest(ctx context.Context, headers api.HeaderMap, buf api.IoBuffer, trailers api.HeaderMap) (api.HeaderMap, api.IoBuffer, api.HeaderMap, error) {
+	header, ok := headers.(http.RequestHeader)
+	if !ok || t.binding == nil {
+		return headers, buf, trailers, errors.New("request is not accepted")
+	}
+	queryString, _ := variable.GetString(ctx, types.VarQueryString)
+	query, err := url.ParseQuery(queryString)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid query: %v", err)
+	}
+	var body []byte
+	if buf != nil {
+		body = bytes.TrimSpace(buf.Bytes())
+	}
+
+	// the client streaming request is a JSON array of the messages
+	bodies := [][]byte{body}
+	if t.binding.method.IsStreamingClient() && (t.binding.bodyAll || t.binding.body != nil) {
+		var raws []json.RawMessage
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &raws); err != nil {
+				return nil, nil, nil, fmt.Errorf("the body of the client streaming request must be an array: %v", err)
+			}
+		}
+		bodies = bodies[:0]
+		for _, raw := range raws {
+			bodies = append(bodies, raw)
+		}
+	}
+
+	data := buffer.NewIoBuffer(len(body))
+	for i, b := range bodies {
+		msg, err := t.newRequestMessage(b, query, i == 0)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if err := writeFrame(data, msg); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	out := make(protocol.CommonHeader, header.Len())
+	header.VisitAll(func(key, value []byte) {
+		k := strings.ToLower(string(key))
+		if !skippedHeaders[k] {
+			out[k] = string(value)
+		}
+	})
+	out["content-type"] = contentTypeGrpc
+	out["te"] = "trailers"
+
+	_ = variable.Set(ctx, types.VariableUpstreamProtocol, protocol.HTTP2)
+	variable.SetString(ctx, types.VarMethod, fasthttp.MethodPost)
+	variable.SetString(ctx, types.VarPath, t.binding.grpcPath)
+	variable.SetString(ctx, types.VarPathOriginal, t.binding.grpcPath)
+	variable.SetString(ctx, types.VarQueryString, "")
+
+	return out, data, nil, nil
+}
+
+// newRequestMessage converts the body, the query parameters and the path variables to the request message,
+// the query parameters and the path variables are only set to the first message of the client streaming.
+func (t *grpcJSON) newRequestMessage(body []byte, query url.Values, first bool) (proto.Message, error) {
+	b := t.binding
+	msg := dynamicpb.NewMessage(b.method.Input())
+	opts := protojson.UnmarshalOptions{Resolver: t.set.resolver}
+	if len(body) > 0 {
+		switch {
+		case b.bodyAll:
+			if err := opts.Unmarshal(body, msg); err != nil {
+				return nil, fmt.Errorf("invalid body: %v", err)
+			}
+		case b.body != nil:
+			// the body is the value of the field
+			wrapped := make([]byte, 0, len(body)+len(b.body.Name())+5)
+			wrapped = append(wrapped, `{"`...)
+			wrapped = append(wrapped, b.body.Name()...)
+			wrapped = append(wrapped, `":`...)
+			wrapped = append(wrapped, body...)
+			wrapped = append(wrapped, '}')
+			if err := opts.Unmarshal(wrapped, msg); err != nil {
+				return nil, fmt.Errorf("invalid body: %v", err)
+			}
+		}
+	}
+	if !first {
+		return msg, nil
+	}
+
+	bound := make(map[string]bool, len(b.template.variables))
+	for _, v := range b.template.variables {
+		bound[strings.Join(v.fieldPath, ".")] = true
+	}
+	if b.body != nil {
+		bound[string(b.body.Name())] = true
+		bound[b.body.JSONName()] = true
+	}
+	// all the fields are bound by the body if it is *
+	if !b.bodyAll {
+		if err := setQuery(msg, query, bound, t.cfg.IgnoreUnknownQueryParameters); err != nil {
+			return nil, fmt.Errorf("invalid query: %v", err)
+		}
+	}
+	// the path variables take precedence over the body and the query parameters
+	for i, fields := range b.variables {
+		if err := setField(msg, fields, t.values[i]); err != nil {
+			return nil, fmt.Errorf("invalid path: %v", err)
+		}
+	}
+	return msg, nil
+}
+
+func writeFrame(buf api.IoBuffer, msg proto.Message) error {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	var header [grpcFrameHeaderSize]byte
+	binary.BigEndian.PutUint32(header[1:], uint32(len(data)))
+	if _, err := buf.Write(header[:]); err != nil {
+		return err
+	}
+	_, err = buf.Write(data)
+	return err
+}
+
+// readFrames splits the gRPC messages in the body
+func readFrames(body []byte) ([][]byte, error) {
+	var messages [][]byte
+	for len(body) > 0 {
+		if len(body) < grpcFrameHeaderSize {
+			return nil, errors.New("incomplete grpc frame header")
+		}
+		if body[0] != 0 {
+			return nil, errors.New("compressed grpc message is not supported")
+		}
+		size := binary.BigEndian.Uint32(body[1:grpcFrameHeaderSize])
+		body = body[grpcFrameHeaderSize:]
+		if uint64(len(body)) < uint64(size) {
+			return nil, errors.New("incomplete grpc message")
+		}
+		messages = append(messages, body[:size])
+		body = body[size:]
+	}
+	return messages, nil
+}
+
+func (t *grpcJSON) marshalOptions() protojson.MarshalOptions {
+	opts := protojson.MarshalOptions{
+		Resolver:        t.set.resolver,
+		UseProtoNames:   t.cfg.PrintOptions.PreserveProtoFieldNames,
+		UseEnumNumbers:  t.cfg.PrintOptions.AlwaysPrintEnumsAsInts,
+		EmitUnpopulated: t.cfg.PrintOptions.AlwaysPrintPrimitiveFields,
+	}
+	if t.cfg.PrintOptions.AddWhitespace {
+		opts.Multiline = true
+		opts.Indent = "  "
+	}
+	return opts
+}
+
+// grpcStatus reads the status from the trailers, or from the headers if the response is trailers-only
+func grpcStatus(headers, trailers api.HeaderMap) (codes.Code, string, string) {
+	source := headers
+	if trailers != nil {
+		if _, ok := trailers.Get(headerGrpcStatus); ok {
+			source = trailers
+		}
+	}
+	status, ok := source.Get(headerGrpcStatus)
+	if !ok {
+		return codes.Unknown, "grpc-status is missing", ""
+	}
+	code, err := strconv.Atoi(status)
+	if err != nil {
+		return codes.Unknown, "invalid grpc-status " + status, ""
+	}
+	message, _ := source.Get(headerGrpcMessage)
+	// the message is percent encoded
+	if unescaped, err := url.PathUnescape(message); err == nil {
+		message = unescaped
+	}
+	details, _ := source.Get(headerGrpcDetails)
+	return codes.Code(code), message, details
+}
+
+func (t *grpcJSON) TranscodingResponse(ctx context.Context, headers api.HeaderMap, buf api.IoBuffer, trailers api.HeaderMap) (api.HeaderMap, api.IoBuffer, api.HeaderMap, error) {
+	rsp, ok := headers.(*http2.RspHeader)
+	if !ok || t.binding == nil {
+		// if the response is not http2 response, it maybe come from hijack or send directly response.
+		// so we just returns the original data
+		return headers, buf, trailers, nil
+	}
+
+	out := http.ResponseHeader{ResponseHeader: &fasthttp.ResponseHeader{}}
+	rsp.Range(func(key, value string) bool {
+		k := strings.ToLower(key)
+		if !skippedHeaders[k] && !strings.HasPrefix(k, "grpc-") {
+			out.Set(key, value)
+		}
+		return true
+	})
+	out.SetContentType(contentTypeJSON)
+
+	var (
+		body []byte
+		err  error
+	)
+	code, message, details := grpcStatus(rsp, trailers)
+	if code == codes.OK {
+		var data []byte
+		if buf != nil {
+			data = buf.Bytes()
+		}
+		body, err = t.responseJSON(data)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	} else {
+		body, err = t.statusJSON(code, message, details)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	status := httpStatus(code)
+	out.SetStatusCode(status)
+	// the status of the HTTP/1 response is set by the variable
+	variable.SetString(ctx, types.VarHeaderStatus, strconv.Itoa(status))
+
+	return out, buffer.NewIoBufferBytes(body), nil, nil
+}
+
+// responseJSON converts the response messages to JSON, the server streaming response is a JSON array
+func (t *grpcJSON) responseJSON(data []byte) ([]byte, error) {
+	frames, err := readFrames(data)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([][]byte, 0, len(frames))
+	for _, frame := range frames {
+		msg := dynamicpb.NewMessage(t.binding.method.Output())
+		if err := proto.Unmarshal(frame, msg); err != nil {
+			return nil, fmt.Errorf("unmarshal response failed: %v", err)
+		}
+		b, err := t.messageJSON(msg)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, b)
+	}
+
+	if t.binding.method.IsStreamingServer() {
+		body := append([]byte{'['}, bytes.Join(messages, []byte{','})...)
+		return append(body, ']'), nil
+	}
+	if len(messages) != 1 {
+		return nil, fmt.Errorf("expect one response message, but got %d", len(messages))
+	}
+	return messages[0], nil
+}
+
+// messageJSON converts the message or the response body field to JSON
+func (t *grpcJSON) messageJSON(msg protoreflect.Message) ([]byte, error) {
+	opts := t.marshalOptions()
+	fd := t.binding.responseBody
+	if fd == nil {
+		return opts.Marshal(msg.Interface())
+	}
+	if fd.Message() != nil && !fd.IsList() && !fd.IsMap() {
+		return opts.Marshal(msg.Get(fd).Message().Interface())
+	}
+	// the scalar and the repeated fields are converted by a message which only has the field
+	field := dynamicpb.NewMessage(msg.Descriptor())
+	field.Set(fd, msg.Get(fd))
+	opts.UseProtoNames = true
+	opts.EmitUnpopulated = true
+	opts.Multiline = false
+	b, err := opts.Marshal(field)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(b, &values); err != nil {
+		return nil, err
+	}
+	return values[string(fd.Name())], nil
+}
+
+// statusJSON converts the grpc status to the JSON of google.rpc.Status
+func (t *grpcJSON) statusJSON(code codes.Code, message, details string) ([]byte, error) {
+	opts := t.marshalOptions()
+	if details != "" {
+		// the binary header is base64 encoded, and the padding may be omitted
+		b, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(details, "="))
+		if err == nil {
+			detailed := &spb.Status{}
+			if proto.Unmarshal(b, detailed) == nil {
+				// the details can not be converted if the types are unknown
+				if body, err := opts.Marshal(detailed); err == nil {
+					return body, nil
+				}
+			}
+		}
+	}
+	return opts.Marshal(&spb.Status{
+		Code:    int32(code),
+		Message: message,
+	})
+}
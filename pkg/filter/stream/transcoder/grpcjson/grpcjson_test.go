@@ -0,0 +1,460 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpcjson
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	nethttp "net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/http"
+	"mosn.io/mosn/pkg/protocol/http2"
+	"mosn.io/mosn/pkg/types"
+)
+
+func field(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string, repeated bool) *descriptorpb.FieldDescriptorProto {
+	label := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	if repeated {
+		label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	}
+	f := &descriptorpb.FieldDescriptorProto{
+		Name:   proto.String(name),
+		Number: proto.Int32(number),
+		Type:   typ.Enum(),
+		Label:  label.Enum(),
+	}
+	if typeName != "" {
+		f.TypeName = proto.String(typeName)
+	}
+	return f
+}
+
+func method(name, input, output string, clientStreaming, serverStreaming bool, rule *annotations.HttpRule) *descriptorpb.MethodDescriptorProto {
+	m := &descriptorpb.MethodDescriptorProto{
+		Name:            proto.String(name),
+		InputType:       proto.String(input),
+		OutputType:      proto.String(output),
+		ClientStreaming: proto.Bool(clientStreaming),
+		ServerStreaming: proto.Bool(serverStreaming),
+	}
+	if rule != nil {
+		m.Options = &descriptorpb.MethodOptions{}
+		proto.SetExtension(m.Options, annotations.E_Http, rule)
+	}
+	return m
+}
+
+// bookstoreDescriptor is the base64 encoded descriptor set of the bookstore service, the annotations
+// file is not included, which is resolved by the linked files.
+func bookstoreDescriptor(t *testing.T) string {
+	var (
+		typeString = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		typeInt64  = descriptorpb.FieldDescriptorProto_TYPE_INT64
+		typeBool   = descriptorpb.FieldDescriptorProto_TYPE_BOOL
+		typeEnum   = descriptorpb.FieldDescriptorProto_TYPE_ENUM
+		typeMsg    = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+	)
+	file := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("bookstore.proto"),
+		Package:    proto.String("bookstore"),
+		Dependency: []string{"google/api/annotations.proto"},
+		Syntax:     proto.String("proto3"),
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Genre"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("UNKNOWN"), Number: proto.Int32(0)},
+				{Name: proto.String("FICTION"), Number: proto.Int32(1)},
+			},
+		}},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("Book"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("id", 1, typeInt64, "", false),
+				field("title", 2, typeString, "", false),
+				field("genre", 3, typeEnum, ".bookstore.Genre", false),
+				field("tags", 4, typeString, "", true),
+			}},
+			{Name: proto.String("Shelf"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("name", 1, typeString, "", false),
+			}},
+			{Name: proto.String("GetBookRequest"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("shelf", 1, typeMsg, ".bookstore.Shelf", false),
+				field("book", 2, typeInt64, "", false),
+				field("verbose", 3, typeBool, "", false),
+				field("fields", 4, typeString, "", true),
+				field("genre", 5, typeEnum, ".bookstore.Genre", false),
+			}},
+			{Name: proto.String("CreateBookRequest"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("shelf", 1, typeString, "", false),
+				field("book", 2, typeMsg, ".bookstore.Book", false),
+			}},
+			{Name: proto.String("ListBooksRequest"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("shelf", 1, typeString, "", false),
+			}},
+			{Name: proto.String("ListBooksResponse"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("books", 1, typeMsg, ".bookstore.Book", true),
+			}},
+			{Name: proto.String("Empty")},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Bookstore"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				method("GetBook", ".bookstore.GetBookRequest", ".bookstore.Book", false, false, &annotations.HttpRule{
+					Pattern: &annotations.HttpRule_Get{Get: "/v1/shelves/{shelf.name}/books/{book}"},
+				}),
+				method("GetFirstBook", ".bookstore.GetBookRequest", ".bookstore.Book", false, false, &annotations.HttpRule{
+					Pattern: &annotations.HttpRule_Get{Get: "/v1/shelves/{shelf.name}/books/first"},
+				}),
+				method("CreateBook", ".bookstore.CreateBookRequest", ".bookstore.Book", false, false, &annotations.HttpRule{
+					Pattern: &annotations.HttpRule_Post{Post: "/v1/{shelf=shelves/*}/books"},
+					Body:    "book",
+					AdditionalBindings: []*annotations.HttpRule{{
+						Pattern: &annotations.HttpRule_Put{Put: "/v1/books"},
+						Body:    "*",
+					}},
+				}),
+				method("ListBooks", ".bookstore.ListBooksRequest", ".bookstore.ListBooksResponse", false, false, &annotations.HttpRule{
+					Pattern:      &annotations.HttpRule_Get{Get: "/v1/shelves/{shelf}/books"},
+					ResponseBody: "books",
+				}),
+				method("StreamBooks", ".bookstore.ListBooksRequest", ".bookstore.Book", false, true, &annotations.HttpRule{
+					Pattern: &annotations.HttpRule_Get{Get: "/v1/shelves/{shelf}/books:stream"},
+				}),
+				method("BulkCreate", ".bookstore.Book", ".bookstore.ListBooksResponse", true, false, &annotations.HttpRule{
+					Pattern: &annotations.HttpRule_Post{Post: "/v1/books:bulk"},
+					Body:    "*",
+				}),
+				method("Ping", ".bookstore.Empty", ".bookstore.Empty", false, false, nil),
+			},
+		}},
+	}
+	data, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{file}})
+	require.Nil(t, err)
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func init() {
+	// the status variable is registered by the proxy
+	variable.Register(variable.NewStringVariable(types.VarHeaderStatus, nil, nil, variable.DefaultStringSetter, 0))
+}
+
+func newContext(method, path, query string) context.Context {
+	ctx := variable.NewVariableContext(context.Background())
+	variable.SetString(ctx, types.VarMethod, method)
+	variable.SetString(ctx, types.VarPath, path)
+	variable.SetString(ctx, types.VarPathOriginal, path)
+	variable.SetString(ctx, types.VarQueryString, query)
+	return ctx
+}
+
+func newRequest(method string) http.RequestHeader {
+	h := http.RequestHeader{RequestHeader: &fasthttp.RequestHeader{}}
+	h.SetMethod(method)
+	h.SetContentType("application/json")
+	h.Set("X-Request-Id", "1")
+	return h
+}
+
+// decodeRequest converts the gRPC messages of the request to JSON
+func decodeRequest(t *testing.T, tc *grpcJSON, data []byte) []string {
+	frames, err := readFrames(data)
+	require.Nil(t, err)
+	var messages []string
+	for _, frame := range frames {
+		msg := dynamicpb.NewMessage(tc.binding.method.Input())
+		require.Nil(t, proto.Unmarshal(frame, msg))
+		b, err := protojson.Marshal(msg)
+		require.Nil(t, err)
+		messages = append(messages, string(b))
+	}
+	return messages
+}
+
+// encodeResponse converts the JSON to the gRPC messages of the response
+func encodeResponse(t *testing.T, tc *grpcJSON, messages ...string) buffer.IoBuffer {
+	buf := buffer.NewIoBuffer(0)
+	for _, m := range messages {
+		msg := dynamicpb.NewMessage(tc.binding.method.Output())
+		require.Nil(t, protojson.Unmarshal([]byte(m), msg))
+		require.Nil(t, writeFrame(buf, msg))
+	}
+	return buf
+}
+
+func newResponse(header nethttp.Header) *http2.RspHeader {
+	return http2.NewRspHeader(&nethttp.Response{StatusCode: 200, Header: header})
+}
+
+var okTrailers = http2.NewHeaderMap(nethttp.Header{"Grpc-Status": {"0"}})
+
+func TestTranscodingUnary(t *testing.T) {
+	tc := NewTranscoder(map[string]interface{}{
+		"proto_descriptor_bin": bookstoreDescriptor(t),
+		"services":             []interface{}{"bookstore.Bookstore"},
+	}).(*grpcJSON)
+	ctx := newContext("GET", "/v1/shelves/s%2F1/books/2", "verbose=true&fields=a&fields=b&genre=FICTION")
+	req := newRequest("GET")
+	require.True(t, tc.Accept(ctx, req, nil, nil))
+	assert.Equal(t, "/bookstore.Bookstore/GetBook", tc.binding.grpcPath)
+
+	headers, data, trailers, err := tc.TranscodingRequest(ctx, req, nil, nil)
+	require.Nil(t, err)
+	assert.Nil(t, trailers)
+	ct, _ := headers.Get("content-type")
+	assert.Equal(t, "application/grpc", ct)
+	te, _ := headers.Get("te")
+	assert.Equal(t, "trailers", te)
+	id, _ := headers.Get("x-request-id")
+	assert.Equal(t, "1", id)
+	messages := decodeRequest(t, tc, data.Bytes())
+	require.Len(t, messages, 1)
+	assert.JSONEq(t, `{"shelf":{"name":"s/1"},"book":"2","verbose":true,"fields":["a","b"],"genre":"FICTION"}`, messages[0])
+
+	proto, _ := variable.Get(ctx, types.VariableUpstreamProtocol)
+	assert.Equal(t, protocol.HTTP2, proto)
+	path, _ := variable.GetString(ctx, types.VarPath)
+	assert.Equal(t, "/bookstore.Bookstore/GetBook", path)
+	method, _ := variable.GetString(ctx, types.VarMethod)
+	assert.Equal(t, "POST", method)
+	query, _ := variable.GetString(ctx, types.VarQueryString)
+	assert.Equal(t, "", query)
+
+	rsp := newResponse(nethttp.Header{"Content-Type": {"application/grpc"}, "X-Custom": {"v"}})
+	outHeaders, outData, _, err := tc.TranscodingResponse(ctx, rsp, encodeResponse(t, tc, `{"id":"2","title":"mosn","tags":["proxy"]}`), okTrailers)
+	require.Nil(t, err)
+	h := outHeaders.(http.ResponseHeader)
+	assert.Equal(t, 200, h.StatusCode())
+	assert.Equal(t, "application/json", string(h.ContentType()))
+	assert.Equal(t, "v", string(h.Peek("X-Custom")))
+	assert.JSONEq(t, `{"id":"2","title":"mosn","tags":["proxy"]}`, outData.String())
+	status, _ := variable.GetString(ctx, types.VarHeaderStatus)
+	assert.Equal(t, "200", status)
+}
+
+func TestTranscodingMatch(t *testing.T) {
+	descriptor := bookstoreDescriptor(t)
+	cases := []struct {
+		method string
+		path   string
+		grpc   string
+	}{
+		// the literal segments are preferred
+		{"GET", "/v1/shelves/s1/books/first", "/bookstore.Bookstore/GetFirstBook"},
+		{"GET", "/v1/shelves/s1/books/1", "/bookstore.Bookstore/GetBook"},
+		{"GET", "/v1/shelves/s1/books:stream", "/bookstore.Bookstore/StreamBooks"},
+		{"PUT", "/v1/books", "/bookstore.Bookstore/CreateBook"},
+		{"POST", "/bookstore.Bookstore/Ping", ""},
+		{"DELETE", "/v1/shelves/s1/books/1", ""},
+	}
+	for _, c := range cases {
+		tc := NewTranscoder(map[string]interface{}{"proto_descriptor_bin": descriptor}).(*grpcJSON)
+		ok := tc.Accept(newContext(c.method, c.path, ""), newRequest(c.method), nil, nil)
+		assert.Equal(t, c.grpc != "", ok, c.path)
+		if ok {
+			assert.Equal(t, c.grpc, tc.binding.grpcPath, c.path)
+		}
+	}
+
+	// the methods without the annotations are mapped if auto mapping is enabled
+	tc := NewTranscoder(map[string]interface{}{"proto_descriptor_bin": descriptor, "auto_mapping": true}).(*grpcJSON)
+	require.True(t, tc.Accept(newContext("POST", "/bookstore.Bookstore/Ping", ""), newRequest("POST"), nil, nil))
+	assert.Equal(t, "/bookstore.Bookstore/Ping", tc.binding.grpcPath)
+
+	tc = NewTranscoder(map[string]interface{}{"proto_descriptor_bin": descriptor, "services": []interface{}{"other.Service"}}).(*grpcJSON)
+	assert.False(t, tc.Accept(newContext("GET", "/v1/shelves/s1/books/1", ""), newRequest("GET"), nil, nil))
+
+	tc = NewTranscoder(map[string]interface{}{"proto_descriptor": "/not/exists.pb"}).(*grpcJSON)
+	assert.False(t, tc.Accept(newContext("GET", "/v1/shelves/s1/books/1", ""), newRequest("GET"), nil, nil))
+
+	tc = NewTranscoder(map[string]interface{}{"proto_descriptor_bin": descriptor}).(*grpcJSON)
+	assert.False(t, tc.Accept(newContext("GET", "/v1/shelves/s1/books/1", ""), protocol.CommonHeader{}, nil, nil))
+}
+
+func TestTranscodingDescriptorFile(t *testing.T) {
+	data, err := base64.StdEncoding.DecodeString(bookstoreDescriptor(t))
+	require.Nil(t, err)
+	dir, err := ioutil.TempDir("", "grpcjson")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "bookstore.pb")
+	require.Nil(t, ioutil.WriteFile(file, data, 0644))
+
+	tc := NewTranscoder(map[string]interface{}{"proto_descriptor": file}).(*grpcJSON)
+	require.True(t, tc.Accept(newContext("GET", "/v1/shelves/s1/books", ""), newRequest("GET"), nil, nil))
+	assert.Equal(t, "/bookstore.Bookstore/ListBooks", tc.binding.grpcPath)
+}
+
+func TestTranscodingBody(t *testing.T) {
+	descriptor := bookstoreDescriptor(t)
+	tc := NewTranscoder(map[string]interface{}{"proto_descriptor_bin": descriptor}).(*grpcJSON)
+	ctx := newContext("POST", "/v1/shelves/s1/books", "")
+	req := newRequest("POST")
+	require.True(t, tc.Accept(ctx, req, nil, nil))
+	_, data, _, err := tc.TranscodingRequest(ctx, req, buffer.NewIoBufferString(`{"title":"mosn","genre":"FICTION"}`), nil)
+	require.Nil(t, err)
+	assert.JSONEq(t, `{"shelf":"shelves/s1","book":{"title":"mosn","genre":"FICTION"}}`, decodeRequest(t, tc, data.Bytes())[0])
+
+	// the query parameters are not used if the body is *
+	tc = NewTranscoder(map[string]interface{}{"proto_descriptor_bin": descriptor}).(*grpcJSON)
+	ctx = newContext("PUT", "/v1/books", "shelf=ignored")
+	require.True(t, tc.Accept(ctx, newRequest("PUT"), nil, nil))
+	_, data, _, err = tc.TranscodingRequest(ctx, newRequest("PUT"), buffer.NewIoBufferString(`{"shelf":"s2","book":{"id":"3"}}`), nil)
+	require.Nil(t, err)
+	assert.JSONEq(t, `{"shelf":"s2","book":{"id":"3"}}`, decodeRequest(t, tc, data.Bytes())[0])
+
+	// the invalid requests
+	for _, c := range []struct {
+		method, path, query, body string
+	}{
+		{"POST", "/v1/shelves/s1/books", "", `{"title":1}`},
+		{"GET", "/v1/shelves/s1/books/x", "", ""},
+		{"GET", "/v1/shelves/s1/books/1", "unknown=1", ""},
+		{"GET", "/v1/shelves/s1/books/1", "genre=OTHER", ""},
+		{"POST", "/v1/books:bulk", "", `{"id":"1"}`},
+	} {
+		tc := NewTranscoder(map[string]interface{}{"proto_descriptor_bin": descriptor}).(*grpcJSON)
+		ctx := newContext(c.method, c.path, c.query)
+		req := newRequest(c.method)
+		require.True(t, tc.Accept(ctx, req, nil, nil), c.path)
+		_, _, _, err := tc.TranscodingRequest(ctx, req, buffer.NewIoBufferString(c.body), nil)
+		assert.NotNil(t, err, c.path)
+	}
+
+	tc = NewTranscoder(map[string]interface{}{"proto_descriptor_bin": descriptor, "ignore_unknown_query_parameters": true}).(*grpcJSON)
+	ctx = newContext("GET", "/v1/shelves/s1/books/1", "unknown=1")
+	require.True(t, tc.Accept(ctx, newRequest("GET"), nil, nil))
+	_, data, _, err = tc.TranscodingRequest(ctx, newRequest("GET"), nil, nil)
+	require.Nil(t, err)
+	assert.JSONEq(t, `{"shelf":{"name":"s1"},"book":"1"}`, decodeRequest(t, tc, data.Bytes())[0])
+}
+
+func TestTranscodingStreaming(t *testing.T) {
+	descriptor := bookstoreDescriptor(t)
+	tc := NewTranscoder(map[string]interface{}{"proto_descriptor_bin": descriptor}).(*grpcJSON)
+	ctx := newContext("POST", "/v1/books:bulk", "")
+	require.True(t, tc.Accept(ctx, newRequest("POST"), nil, nil))
+	_, data, _, err := tc.TranscodingRequest(ctx, newRequest("POST"), buffer.NewIoBufferString(`[{"id":"1"},{"id":"2"}]`), nil)
+	require.Nil(t, err)
+	assert.Equal(t, []string{`{"id":"1"}`, `{"id":"2"}`}, compactJSON(t, decodeRequest(t, tc, data.Bytes())))
+
+	tc = NewTranscoder(map[string]interface{}{"proto_descriptor_bin": descriptor}).(*grpcJSON)
+	ctx = newContext("GET", "/v1/shelves/s1/books:stream", "")
+	require.True(t, tc.Accept(ctx, newRequest("GET"), nil, nil))
+	_, _, _, err = tc.TranscodingRequest(ctx, newRequest("GET"), nil, nil)
+	require.Nil(t, err)
+	_, outData, _, err := tc.TranscodingResponse(ctx, newResponse(nethttp.Header{}), encodeResponse(t, tc, `{"id":"1"}`, `{"id":"2"}`), okTrailers)
+	require.Nil(t, err)
+	assert.JSONEq(t, `[{"id":"1"},{"id":"2"}]`, outData.String())
+
+	_, outData, _, err = tc.TranscodingResponse(ctx, newResponse(nethttp.Header{}), nil, okTrailers)
+	require.Nil(t, err)
+	assert.JSONEq(t, `[]`, outData.String())
+}
+
+func compactJSON(t *testing.T, messages []string) []string {
+	out := make([]string, 0, len(messages))
+	for _, m := range messages {
+		var v interface{}
+		require.Nil(t, json.Unmarshal([]byte(m), &v))
+		b, err := json.Marshal(v)
+		require.Nil(t, err)
+		out = append(out, string(b))
+	}
+	return out
+}
+
+func TestTranscodingResponseBody(t *testing.T) {
+	tc := NewTranscoder(map[string]interface{}{
+		"proto_descriptor_bin": bookstoreDescriptor(t),
+		"print_options": map[string]interface{}{
+			"always_print_enums_as_ints":    true,
+			"always_print_primitive_fields": true,
+		},
+	}).(*grpcJSON)
+	ctx := newContext("GET", "/v1/shelves/s1/books", "")
+	require.True(t, tc.Accept(ctx, newRequest("GET"), nil, nil))
+	_, _, _, err := tc.TranscodingRequest(ctx, newRequest("GET"), nil, nil)
+	require.Nil(t, err)
+	_, outData, _, err := tc.TranscodingResponse(ctx, newResponse(nethttp.Header{}),
+		encodeResponse(t, tc, `{"books":[{"id":"1","genre":"FICTION"}]}`), okTrailers)
+	require.Nil(t, err)
+	assert.JSONEq(t, `[{"id":"1","title":"","genre":1,"tags":[]}]`, outData.String())
+}
+
+func TestTranscodingError(t *testing.T) {
+	tc := NewTranscoder(map[string]interface{}{"proto_descriptor_bin": bookstoreDescriptor(t)}).(*grpcJSON)
+	ctx := newContext("GET", "/v1/shelves/s1/books/1", "")
+	req := newRequest("GET")
+	require.True(t, tc.Accept(ctx, req, nil, nil))
+	_, _, _, err := tc.TranscodingRequest(ctx, req, nil, nil)
+	require.Nil(t, err)
+
+	// the trailers-only response
+	rsp := newResponse(nethttp.Header{"Grpc-Status": {"5"}, "Grpc-Message": {"book%201%20not%20found"}})
+	outHeaders, outData, _, err := tc.TranscodingResponse(ctx, rsp, nil, nil)
+	require.Nil(t, err)
+	assert.Equal(t, 404, outHeaders.(http.ResponseHeader).StatusCode())
+	assert.Empty(t, outHeaders.(http.ResponseHeader).Peek("Grpc-Status"))
+	assert.JSONEq(t, `{"code":5,"message":"book 1 not found"}`, outData.String())
+	status, _ := variable.GetString(ctx, types.VarHeaderStatus)
+	assert.Equal(t, "404", status)
+
+	// the status details
+	details, err := proto.Marshal(&spb.Status{Code: 7, Message: "denied"})
+	require.Nil(t, err)
+	trailers := http2.NewHeaderMap(nethttp.Header{
+		"Grpc-Status":             {"7"},
+		"Grpc-Status-Details-Bin": {base64.RawStdEncoding.EncodeToString(details)},
+	})
+	outHeaders, outData, _, err = tc.TranscodingResponse(ctx, newResponse(nethttp.Header{}), nil, trailers)
+	require.Nil(t, err)
+	assert.Equal(t, 403, outHeaders.(http.ResponseHeader).StatusCode())
+	assert.JSONEq(t, `{"code":7,"message":"denied"}`, outData.String())
+
+	// the grpc-status is missing
+	outHeaders, _, _, err = tc.TranscodingResponse(ctx, newResponse(nethttp.Header{}), nil, nil)
+	require.Nil(t, err)
+	assert.Equal(t, 500, outHeaders.(http.ResponseHeader).StatusCode())
+
+	// the invalid response message
+	_, _, _, err = tc.TranscodingResponse(ctx, newResponse(nethttp.Header{}), buffer.NewIoBufferBytes([]byte{1, 0, 0, 0, 0}), okTrailers)
+	assert.NotNil(t, err)
+
+	// the hijack response is not transcoded
+	outHeaders, outData, _, err = tc.TranscodingResponse(ctx, req, buffer.NewIoBufferString("hijack"), nil)
+	require.Nil(t, err)
+	assert.Equal(t, req, outHeaders)
+	assert.Equal(t, "hijack", outData.String())
+}
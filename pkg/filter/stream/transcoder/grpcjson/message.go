@@ -0,0 +1,178 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpcjson
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// findField finds the field by the proto name or the json name
+func findField(md protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	if fd := md.Fields().ByName(protoreflect.Name(name)); fd != nil {
+		return fd
+	}
+	return md.Fields().ByJSONName(name)
+}
+
+// resolveFieldPath resolves the field path a.b.c, the fields except the last one must be the singular messages
+func resolveFieldPath(md protoreflect.MessageDescriptor, path []string) ([]protoreflect.FieldDescriptor, error) {
+	fields := make([]protoreflect.FieldDescriptor, 0, len(path))
+	for i, name := range path {
+		fd := findField(md, name)
+		if fd == nil {
+			return nil, fmt.Errorf("field %s is not found in %s", strings.Join(path[:i+1], "."), md.FullName())
+		}
+		fields = append(fields, fd)
+		if i == len(path)-1 {
+			break
+		}
+		if fd.Message() == nil || fd.IsList() || fd.IsMap() {
+			return nil, fmt.Errorf("field %s is not a singular message", strings.Join(path[:i+1], "."))
+		}
+		md = fd.Message()
+	}
+	return fields, nil
+}
+
+// setField sets the string value to the field path of the message, the value is appended if the field is repeated
+func setField(msg protoreflect.Message, fields []protoreflect.FieldDescriptor, value string) error {
+	for _, fd := range fields[:len(fields)-1] {
+		msg = msg.Mutable(fd).Message()
+	}
+	fd := fields[len(fields)-1]
+	if fd.IsMap() {
+		return fmt.Errorf("map field %s is not supported in the path or query", fd.FullName())
+	}
+	if fd.Message() != nil {
+		sub := msg.NewField(fd)
+		if err := parseMessage(sub.Message(), value); err != nil {
+			return fmt.Errorf("invalid value of field %s: %v", fd.FullName(), err)
+		}
+		if fd.IsList() {
+			msg.Mutable(fd).List().Append(sub)
+		} else {
+			msg.Set(fd, sub)
+		}
+		return nil
+	}
+	v, err := parseScalar(fd, value)
+	if err != nil {
+		return fmt.Errorf("invalid value of field %s: %v", fd.FullName(), err)
+	}
+	if fd.IsList() {
+		msg.Mutable(fd).List().Append(v)
+	} else {
+		msg.Set(fd, v)
+	}
+	return nil
+}
+
+// parseMessage parses the wrapper types and the well known types which are the json strings, such as
+// google.protobuf.Timestamp and google.protobuf.Duration
+func parseMessage(msg protoreflect.Message, value string) error {
+	if strings.HasPrefix(string(msg.Descriptor().FullName()), "google.protobuf.") && strings.HasSuffix(string(msg.Descriptor().Name()), "Value") {
+		if fd := msg.Descriptor().Fields().ByName("value"); fd != nil && msg.Descriptor().Fields().Len() == 1 {
+			v, err := parseScalar(fd, value)
+			if err != nil {
+				return err
+			}
+			msg.Set(fd, v)
+			return nil
+		}
+	}
+	return protojson.Unmarshal([]byte(strconv.Quote(value)), msg.Interface())
+}
+
+func parseScalar(fd protoreflect.FieldDescriptor, value string) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(value), nil
+	case protoreflect.BytesKind:
+		b, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			if b, err = base64.URLEncoding.DecodeString(value); err != nil {
+				return protoreflect.Value{}, err
+			}
+		}
+		return protoreflect.ValueOfBytes(b), nil
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(value)
+		return protoreflect.ValueOfBool(b), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		i, err := strconv.ParseInt(value, 10, 32)
+		return protoreflect.ValueOfInt32(int32(i)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		i, err := strconv.ParseInt(value, 10, 64)
+		return protoreflect.ValueOfInt64(i), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		i, err := strconv.ParseUint(value, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(i)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		i, err := strconv.ParseUint(value, 10, 64)
+		return protoreflect.ValueOfUint64(i), err
+	case protoreflect.FloatKind:
+		f, err := strconv.ParseFloat(value, 32)
+		return protoreflect.ValueOfFloat32(float32(f)), err
+	case protoreflect.DoubleKind:
+		f, err := strconv.ParseFloat(value, 64)
+		return protoreflect.ValueOfFloat64(f), err
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(value)); ev != nil {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		i, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("unknown enum value %s", value)
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(i)), nil
+	}
+	return protoreflect.Value{}, fmt.Errorf("unsupported kind %s", fd.Kind())
+}
+
+// setQuery sets the query parameters to the message, the parameters bound by the path are skipped
+func setQuery(msg protoreflect.Message, query url.Values, bound map[string]bool, ignoreUnknown bool) error {
+	for key, values := range query {
+		if bound[key] {
+			continue
+		}
+		fields, err := resolveFieldPath(msg.Descriptor(), strings.Split(key, "."))
+		if err != nil {
+			if ignoreUnknown {
+				continue
+			}
+			return err
+		}
+		fd := fields[len(fields)-1]
+		if !fd.IsList() && len(values) > 1 {
+			values = values[len(values)-1:]
+		}
+		for _, value := range values {
+			if err := setField(msg, fields, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpcjson
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// segmentKind is the kind of the path template segments
+type segmentKind int
+
+const (
+	segmentLiteral segmentKind = iota
+	// segmentSingle matches one path segment, which is *
+	segmentSingle
+	// segmentMulti matches the rest path segments, which is **
+	segmentMulti
+)
+
+type segment struct {
+	kind    segmentKind
+	literal string
+}
+
+// binding binds the segments [start, end) to a request field, end is -1 if the variable ends with **
+type binding struct {
+	fieldPath []string
+	start     int
+	end       int
+}
+
+// pathTemplate is the parsed google.api.http path template:
+//
+//	Template = "/" Segments [ Verb ] ;
+//	Segments = Segment { "/" Segment } ;
+//	Segment  = "*" | "**" | LITERAL | Variable ;
+//	Variable = "{" FieldPath [ "=" Segments ] "}" ;
+//	FieldPath = IDENT { "." IDENT } ;
+//	Verb     = ":" LITERAL ;
+type pathTemplate struct {
+	segments  []segment
+	variables []binding
+	verb      string
+}
+
+type templateParser struct {
+	tmpl string
+	pos  int
+	pt   *pathTemplate
+}
+
+func parseTemplate(tmpl string) (*pathTemplate, error) {
+	if !strings.HasPrefix(tmpl, "/") {
+		return nil, fmt.Errorf("path template %q must start with /", tmpl)
+	}
+	p := &templateParser{
+		tmpl: tmpl,
+		pos:  1,
+		pt:   &pathTemplate{},
+	}
+	if tmpl == "/" {
+		return p.pt, nil
+	}
+	if err := p.parseSegments(false); err != nil {
+		return nil, err
+	}
+	if p.pos < len(tmpl) {
+		if tmpl[p.pos] != ':' || p.pos == len(tmpl)-1 {
+			return nil, fmt.Errorf("invalid path template %q at %d", tmpl, p.pos)
+		}
+		p.pt.verb = tmpl[p.pos+1:]
+		if strings.ContainsAny(p.pt.verb, "/{}*") {
+			return nil, fmt.Errorf("invalid verb of path template %q", tmpl)
+		}
+	}
+	for i, seg := range p.pt.segments {
+		if seg.kind == segmentMulti && i != len(p.pt.segments)-1 {
+			return nil, fmt.Errorf("** must be the last segment of path template %q", tmpl)
+		}
+	}
+	return p.pt, nil
+}
+
+func (p *templateParser) parseSegments(inVariable bool) error {
+	for {
+		if err := p.parseSegment(inVariable); err != nil {
+			return err
+		}
+		if p.pos >= len(p.tmpl) || p.tmpl[p.pos] != '/' {
+			return nil
+		}
+		p.pos++
+	}
+}
+
+func (p *templateParser) parseSegment(inVariable bool) error {
+	rest := p.tmpl[p.pos:]
+	switch {
+	case strings.HasPrefix(rest, "**"):
+		p.pos += 2
+		p.pt.segments = append(p.pt.segments, segment{kind: segmentMulti})
+	case strings.HasPrefix(rest, "*"):
+		p.pos++
+		p.pt.segments = append(p.pt.segments, segment{kind: segmentSingle})
+	case strings.HasPrefix(rest, "{"):
+		if inVariable {
+			return fmt.Errorf("nested variable in path template %q", p.tmpl)
+		}
+		return p.parseVariable()
+	default:
+		end := strings.IndexAny(rest, "/:{}*")
+		if end < 0 {
+			end = len(rest)
+		}
+		if end == 0 {
+			return fmt.Errorf("invalid path template %q at %d", p.tmpl, p.pos)
+		}
+		p.pos += end
+		p.pt.segments = append(p.pt.segments, segment{kind: segmentLiteral, literal: rest[:end]})
+	}
+	return nil
+}
+
+func (p *templateParser) parseVariable() error {
+	p.pos++
+	rest := p.tmpl[p.pos:]
+	end := strings.IndexAny(rest, "=}")
+	if end <= 0 {
+		return fmt.Errorf("invalid variable in path template %q", p.tmpl)
+	}
+	v := binding{
+		fieldPath: strings.Split(rest[:end], "."),
+		start:     len(p.pt.segments),
+	}
+	p.pos += end
+	if p.tmpl[p.pos] == '=' {
+		p.pos++
+		if err := p.parseSegments(true); err != nil {
+			return err
+		}
+	} else {
+		// {field} is the same as {field=*}
+		p.pt.segments = append(p.pt.segments, segment{kind: segmentSingle})
+	}
+	if p.pos >= len(p.tmpl) || p.tmpl[p.pos] != '}' {
+		return fmt.Errorf("unclosed variable in path template %q", p.tmpl)
+	}
+	p.pos++
+	v.end = len(p.pt.segments)
+	if p.pt.segments[v.end-1].kind == segmentMulti {
+		v.end = -1
+	}
+	p.pt.variables = append(p.pt.variables, v)
+	return nil
+}
+
+var escapedSlash = strings.NewReplacer("%2F", "%252F", "%2f", "%252f")
+
+// match matches the escaped request path, and returns the values of the variables
+func (pt *pathTemplate) match(path string) ([]string, bool) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, false
+	}
+	path = path[1:]
+	if pt.verb != "" {
+		if !strings.HasSuffix(path, ":"+pt.verb) {
+			return nil, false
+		}
+		path = path[:len(path)-len(pt.verb)-1]
+	}
+	var parts []string
+	if path != "" {
+		parts = strings.Split(path, "/")
+	}
+
+	n := len(pt.segments)
+	if n > 0 && pt.segments[n-1].kind == segmentMulti {
+		if len(parts) < n-1 {
+			return nil, false
+		}
+	} else if len(parts) != n {
+		return nil, false
+	}
+	for i, seg := range pt.segments {
+		if seg.kind == segmentLiteral && seg.literal != parts[i] {
+			return nil, false
+		}
+	}
+
+	values := make([]string, 0, len(pt.variables))
+	for _, v := range pt.variables {
+		end := v.end
+		if end < 0 {
+			end = len(parts)
+		}
+		matched := parts[v.start:end]
+		if end-v.start == 1 {
+			// the single segment variable is fully unescaped
+			value, err := url.PathUnescape(matched[0])
+			if err != nil {
+				return nil, false
+			}
+			values = append(values, value)
+			continue
+		}
+		// the multi segments variable keeps the escaped slashes
+		for i, part := range matched {
+			value, err := url.PathUnescape(escapedSlash.Replace(part))
+			if err != nil {
+				return nil, false
+			}
+			matched[i] = value
+		}
+		values = append(values, strings.Join(matched, "/"))
+	}
+	return values, true
+}
@@ -0,0 +1,87 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpcjson
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTemplate(t *testing.T) {
+	pt, err := parseTemplate("/v1/{name=shelves/*/books/*}:publish")
+	require.Nil(t, err)
+	assert.Equal(t, []segment{
+		{kind: segmentLiteral, literal: "v1"},
+		{kind: segmentLiteral, literal: "shelves"},
+		{kind: segmentSingle},
+		{kind: segmentLiteral, literal: "books"},
+		{kind: segmentSingle},
+	}, pt.segments)
+	assert.Equal(t, []binding{{fieldPath: []string{"name"}, start: 1, end: 5}}, pt.variables)
+	assert.Equal(t, "publish", pt.verb)
+
+	pt, err = parseTemplate("/v1/{shelf.id}/files/{path=**}")
+	require.Nil(t, err)
+	assert.Equal(t, []binding{
+		{fieldPath: []string{"shelf", "id"}, start: 1, end: 2},
+		{fieldPath: []string{"path"}, start: 3, end: -1},
+	}, pt.variables)
+
+	for _, tmpl := range []string{
+		"v1/books",
+		"/v1/**/books",
+		"/v1/{name",
+		"/v1/{name={id}}",
+		"/v1/books:",
+		"/v1//books",
+		"/v1/{=*}",
+	} {
+		_, err := parseTemplate(tmpl)
+		assert.NotNil(t, err, tmpl)
+	}
+}
+
+func TestTemplateMatch(t *testing.T) {
+	cases := []struct {
+		tmpl   string
+		path   string
+		values []string
+		ok     bool
+	}{
+		{"/", "/", []string{}, true},
+		{"/v1/shelves/{shelf}/books/{book}", "/v1/shelves/s1/books/2", []string{"s1", "2"}, true},
+		{"/v1/shelves/{shelf}/books/{book}", "/v1/shelves/s1/books", nil, false},
+		{"/v1/shelves/{shelf}", "/v1/shelves/a%2Fb", []string{"a/b"}, true},
+		{"/v1/{name=shelves/*}", "/v1/shelves/s1", []string{"shelves/s1"}, true},
+		{"/v1/{name=shelves/*}", "/v1/books/s1", nil, false},
+		{"/v1/files/{path=**}", "/v1/files/a/b%2Fc/d", []string{"a/b%2Fc/d"}, true},
+		{"/v1/files/{path=**}", "/v1/files", []string{""}, true},
+		{"/v1/books/*:publish", "/v1/books/1:publish", []string{}, true},
+		{"/v1/books/*:publish", "/v1/books/1", nil, false},
+		{"/v1/books/{id}:publish", "/v1/books/1:unpublish", nil, false},
+	}
+	for _, c := range cases {
+		pt, err := parseTemplate(c.tmpl)
+		require.Nil(t, err, c.tmpl)
+		values, ok := pt.match(c.path)
+		assert.Equal(t, c.ok, ok, c.path)
+		assert.Equal(t, c.values, values, c.path)
+	}
+}
@@ -0,0 +1,260 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package grpcweb bridges the gRPC-Web requests of the browsers to the gRPC upstreams.
+//
+// The application/grpc-web(+proto) and application/grpc-web-text(+proto) HTTP/1.1 requests are
+// sent to the upstream as the HTTP/2 gRPC requests, and the gRPC trailers are encoded into the
+// trailer frame of the gRPC-Web response body. The CORS preflight requests are not transcoded,
+// they can be replied by a direct response route, and the gRPC status headers are exposed to
+// the cross-origin requests.
+package grpcweb
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+	"mosn.io/api"
+	apit "mosn.io/api/extensions/transcoder"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+
+	"mosn.io/mosn/pkg/filter/stream/transcoder"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/http"
+	"mosn.io/mosn/pkg/protocol/http2"
+	"mosn.io/mosn/pkg/types"
+)
+
+func init() {
+	transcoder.MustRegister("grpcweb", NewTranscoder)
+}
+
+const (
+	contentTypeGrpcWeb     = "application/grpc-web"
+	contentTypeGrpcWebText = "application/grpc-web-text"
+	contentTypeGrpc        = "application/grpc"
+
+	// trailerFrameFlag is the flag of the frame which carries the trailers in the gRPC-Web response body
+	trailerFrameFlag = 0x80
+
+	headerOrigin        = "origin"
+	headerExposeHeaders = "access-control-expose-headers"
+	headerGrpcStatus    = "grpc-status"
+	headerGrpcMessage   = "grpc-message"
+)
+
+// the headers which are not forwarded
+var skippedHeaders = map[string]bool{
+	"content-type":      true,
+	"content-length":    true,
+	"connection":        true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+	"host":              true,
+	"te":                true,
+	"x-grpc-web":        true,
+}
+
+type config struct {
+	// ExposeHeaders are the extra response headers exposed to the cross-origin requests
+	ExposeHeaders []string `json:"expose_headers,omitempty"`
+}
+
+// grpcWeb is created for each request, the request mode is kept to encode the response
+type grpcWeb struct {
+	cfg config
+	// text is true if the body is base64 encoded
+	text bool
+	// subtype is the content type suffix, such as +proto
+	subtype string
+	origin  string
+}
+
+func NewTranscoder(cfg map[string]interface{}) apit.Transcoder {
+	t := &grpcWeb{}
+	if headers, ok := cfg["expose_headers"].([]interface{}); ok {
+		for _, h := range headers {
+			if s, ok := h.(string); ok {
+				t.cfg.ExposeHeaders = append(t.cfg.ExposeHeaders, s)
+			}
+		}
+	}
+	return t
+}
+
+// Accept accepts the gRPC-Web requests, the CORS preflight requests are not accepted
+func (t *grpcWeb) Accept(ctx context.Context, headers api.HeaderMap, buf api.IoBuffer, trailers api.HeaderMap) bool {
+	header, ok := headers.(http.RequestHeader)
+	if !ok || string(header.Method()) != fasthttp.MethodPost {
+		return false
+	}
+	return strings.HasPrefix(string(header.ContentType()), contentTypeGrpcWeb)
+}
+
+func (t *grpcWeb) TranscodingRequest(ctx context.Context, headers api.HeaderMap, buf api.IoBuffer, trailers api.HeaderMap) (api.HeaderMap, api.IoBuffer, api.HeaderMap, error) {
+	header, ok := headers.(http.RequestHeader)
+	if !ok {
+		return headers, buf, trailers, errors.New("request is not http")
+	}
+	contentType := string(header.ContentType())
+	if strings.HasPrefix(contentType, contentTypeGrpcWebText) {
+		t.text = true
+		t.subtype = contentType[len(contentTypeGrpcWebText):]
+	} else {
+		t.subtype = contentType[len(contentTypeGrpcWeb):]
+	}
+
+	out := make(protocol.CommonHeader, header.Len())
+	header.VisitAll(func(key, value []byte) {
+		k := strings.ToLower(string(key))
+		if !skippedHeaders[k] {
+			out[k] = string(value)
+		}
+	})
+	out["content-type"] = contentTypeGrpc + t.subtype
+	out["te"] = "trailers"
+	t.origin = out[headerOrigin]
+
+	if t.text && buf != nil && buf.Len() > 0 {
+		data, err := decodeBase64(buf.Bytes())
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		buf = buffer.NewIoBufferBytes(data)
+	}
+
+	_ = variable.Set(ctx, types.VariableUpstreamProtocol, protocol.HTTP2)
+
+	return out, buf, nil, nil
+}
+
+// decodeBase64 decodes the text body, which may be the concatenated base64 chunks with the paddings
+func decodeBase64(b []byte) ([]byte, error) {
+	out := make([]byte, 0, base64.StdEncoding.DecodedLen(len(b)))
+	var quantum [4]byte
+	var n int
+	for _, c := range b {
+		if c == '\r' || c == '\n' || c == ' ' || c == '\t' {
+			continue
+		}
+		quantum[n] = c
+		n++
+		if n < len(quantum) {
+			continue
+		}
+		var decoded [3]byte
+		m, err := base64.StdEncoding.Decode(decoded[:], quantum[:])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, decoded[:m]...)
+		n = 0
+	}
+	if n != 0 {
+		return nil, errors.New("grpc-web text body is not a multiple of 4 bytes")
+	}
+	return out, nil
+}
+
+// encodeTrailers encodes the trailers as a gRPC-Web trailer frame
+func encodeTrailers(b []byte, trailers api.HeaderMap) []byte {
+	var lines []string
+	trailers.Range(func(key, value string) bool {
+		lines = append(lines, strings.ToLower(key)+":"+value+"\r\n")
+		return true
+	})
+	// sort the trailers to make the frame stable
+	sort.Strings(lines)
+	size := 0
+	for _, l := range lines {
+		size += len(l)
+	}
+	b = append(b, trailerFrameFlag, byte(size>>24), byte(size>>16), byte(size>>8), byte(size))
+	for _, l := range lines {
+		b = append(b, l...)
+	}
+	return b
+}
+
+func hasTrailers(trailers api.HeaderMap) bool {
+	if trailers == nil {
+		return false
+	}
+	has := false
+	trailers.Range(func(_, _ string) bool {
+		has = true
+		return false
+	})
+	return has
+}
+
+func (t *grpcWeb) TranscodingResponse(ctx context.Context, headers api.HeaderMap, buf api.IoBuffer, trailers api.HeaderMap) (api.HeaderMap, api.IoBuffer, api.HeaderMap, error) {
+	rsp, ok := headers.(*http2.RspHeader)
+	if !ok {
+		// if the response is not http2 response, it maybe come from hijack or send directly response.
+		// so we just returns the original data
+		return headers, buf, trailers, nil
+	}
+
+	out := http.ResponseHeader{ResponseHeader: &fasthttp.ResponseHeader{}}
+	if rsp.Rsp != nil {
+		out.SetStatusCode(rsp.Rsp.StatusCode)
+	}
+	rsp.Range(func(key, value string) bool {
+		if !skippedHeaders[strings.ToLower(key)] {
+			out.Set(key, value)
+		}
+		return true
+	})
+	if t.text {
+		out.SetContentType(contentTypeGrpcWebText + t.subtype)
+	} else {
+		out.SetContentType(contentTypeGrpcWeb + t.subtype)
+	}
+	if t.origin != "" {
+		// the browsers can only read the exposed headers of the cross-origin responses
+		exposed := []string{headerGrpcStatus, headerGrpcMessage}
+		exposed = append(exposed, t.cfg.ExposeHeaders...)
+		if v := out.Peek(headerExposeHeaders); len(v) > 0 {
+			exposed = append([]string{string(v)}, exposed...)
+		}
+		out.Set(headerExposeHeaders, strings.Join(exposed, ","))
+	}
+
+	// the trailers-only response keeps the status in the headers
+	var body []byte
+	if buf != nil {
+		body = buf.Bytes()
+	}
+	if hasTrailers(trailers) {
+		body = encodeTrailers(append([]byte(nil), body...), trailers)
+	}
+	if t.text {
+		encoded := make([]byte, base64.StdEncoding.EncodedLen(len(body)))
+		base64.StdEncoding.Encode(encoded, body)
+		body = encoded
+	}
+
+	return out, buffer.NewIoBufferBytes(body), nil, nil
+}
@@ -0,0 +1,159 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpcweb
+
+import (
+	"context"
+	"encoding/base64"
+	nethttp "net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/http"
+	"mosn.io/mosn/pkg/protocol/http2"
+	"mosn.io/mosn/pkg/types"
+)
+
+func newRequest(method, contentType string, headers map[string]string) http.RequestHeader {
+	h := http.RequestHeader{RequestHeader: &fasthttp.RequestHeader{}}
+	h.SetMethod(method)
+	h.SetContentType(contentType)
+	for k, v := range headers {
+		h.Set(k, v)
+	}
+	return h
+}
+
+var grpcMessage = []byte{0, 0, 0, 0, 3, 'a', 'b', 'c'}
+
+func TestAccept(t *testing.T) {
+	tc := NewTranscoder(nil)
+	ctx := context.Background()
+	assert.True(t, tc.Accept(ctx, newRequest("POST", "application/grpc-web", nil), nil, nil))
+	assert.True(t, tc.Accept(ctx, newRequest("POST", "application/grpc-web-text+proto", nil), nil, nil))
+	// the CORS preflight
+	assert.False(t, tc.Accept(ctx, newRequest("OPTIONS", "", map[string]string{"Access-Control-Request-Method": "POST"}), nil, nil))
+	assert.False(t, tc.Accept(ctx, newRequest("POST", "application/json", nil), nil, nil))
+	assert.False(t, tc.Accept(ctx, protocol.CommonHeader{}, nil, nil))
+}
+
+func TestTranscodingBinary(t *testing.T) {
+	ctx := variable.NewVariableContext(context.Background())
+	tc := NewTranscoder(nil)
+	req := newRequest("POST", "application/grpc-web+proto", map[string]string{
+		"X-Grpc-Web":   "1",
+		"X-User-Agent": "grpc-web-javascript/0.1",
+		"Connection":   "keep-alive",
+		"Grpc-Timeout": "1S",
+	})
+	headers, data, trailers, err := tc.TranscodingRequest(ctx, req, buffer.NewIoBufferBytes(grpcMessage), nil)
+	require.Nil(t, err)
+	assert.Nil(t, trailers)
+	assert.Equal(t, protocol.CommonHeader{
+		"content-type": "application/grpc+proto",
+		"te":           "trailers",
+		"x-user-agent": "grpc-web-javascript/0.1",
+		"grpc-timeout": "1S",
+	}, headers)
+	assert.Equal(t, grpcMessage, data.Bytes())
+	proto, _ := variable.Get(ctx, types.VariableUpstreamProtocol)
+	assert.Equal(t, protocol.HTTP2, proto)
+
+	rsp := http2.NewRspHeader(&nethttp.Response{
+		StatusCode: 200,
+		Header:     nethttp.Header{"Content-Type": {"application/grpc+proto"}, "X-Custom": {"v"}},
+	})
+	rspTrailers := http2.NewHeaderMap(nethttp.Header{"Grpc-Status": {"0"}, "Grpc-Message": {"OK"}})
+	outHeaders, outData, outTrailers, err := tc.TranscodingResponse(ctx, rsp, buffer.NewIoBufferBytes(grpcMessage), rspTrailers)
+	require.Nil(t, err)
+	assert.Nil(t, outTrailers)
+	h := outHeaders.(http.ResponseHeader)
+	assert.Equal(t, 200, h.StatusCode())
+	assert.Equal(t, "application/grpc-web+proto", string(h.ContentType()))
+	assert.Equal(t, "v", string(h.Peek("X-Custom")))
+	assert.Empty(t, h.Peek(headerExposeHeaders))
+
+	trailerFrame := "grpc-message:OK\r\ngrpc-status:0\r\n"
+	expected := append(append([]byte(nil), grpcMessage...), 0x80, 0, 0, 0, byte(len(trailerFrame)))
+	expected = append(expected, trailerFrame...)
+	assert.Equal(t, expected, outData.Bytes())
+}
+
+func TestTranscodingText(t *testing.T) {
+	ctx := variable.NewVariableContext(context.Background())
+	tc := NewTranscoder(map[string]interface{}{"expose_headers": []interface{}{"x-custom"}})
+	req := newRequest("POST", "application/grpc-web-text", map[string]string{"Origin": "https://example.com"})
+	// the concatenated base64 chunks
+	body := base64.StdEncoding.EncodeToString(grpcMessage[:4]) + "\r\n" + base64.StdEncoding.EncodeToString(grpcMessage[4:])
+	headers, data, _, err := tc.TranscodingRequest(ctx, req, buffer.NewIoBufferString(body), nil)
+	require.Nil(t, err)
+	ct, _ := headers.Get("content-type")
+	assert.Equal(t, "application/grpc", ct)
+	origin, _ := headers.Get("origin")
+	assert.Equal(t, "https://example.com", origin)
+	assert.Equal(t, grpcMessage, data.Bytes())
+
+	// the trailers-only response
+	rsp := http2.NewRspHeader(&nethttp.Response{
+		StatusCode: 200,
+		Header: nethttp.Header{
+			"Grpc-Status":                   {"5"},
+			"Grpc-Message":                  {"not found"},
+			"Access-Control-Expose-Headers": {"x-trace"},
+		},
+	})
+	outHeaders, outData, _, err := tc.TranscodingResponse(ctx, rsp, nil, http2.NewHeaderMap(nethttp.Header{}))
+	require.Nil(t, err)
+	h := outHeaders.(http.ResponseHeader)
+	assert.Equal(t, "application/grpc-web-text", string(h.ContentType()))
+	assert.Equal(t, "5", string(h.Peek("Grpc-Status")))
+	assert.Equal(t, "x-trace,grpc-status,grpc-message,x-custom", string(h.Peek(headerExposeHeaders)))
+	assert.Empty(t, outData.Bytes())
+
+	// the response with trailers is encoded as a whole
+	rsp = http2.NewRspHeader(&nethttp.Response{StatusCode: 200, Header: nethttp.Header{}})
+	outHeaders, outData, _, err = tc.TranscodingResponse(ctx, rsp, buffer.NewIoBufferBytes(grpcMessage),
+		http2.NewHeaderMap(nethttp.Header{"Grpc-Status": {"0"}}))
+	require.Nil(t, err)
+	decoded, err := base64.StdEncoding.DecodeString(outData.String())
+	require.Nil(t, err)
+	trailerFrame := "grpc-status:0\r\n"
+	expected := append(append([]byte(nil), grpcMessage...), 0x80, 0, 0, 0, byte(len(trailerFrame)))
+	assert.Equal(t, append(expected, trailerFrame...), decoded)
+
+	_, _, _, err = tc.TranscodingRequest(ctx, req, buffer.NewIoBufferString("abc"), nil)
+	assert.NotNil(t, err)
+	_, _, _, err = tc.TranscodingRequest(ctx, req, buffer.NewIoBufferString("ab!="), nil)
+	assert.NotNil(t, err)
+}
+
+func TestTranscodingHijackResponse(t *testing.T) {
+	tc := NewTranscoder(nil)
+	req := newRequest("POST", "application/grpc-web", nil)
+	headers, data, trailers, err := tc.TranscodingResponse(context.Background(), req, nil, nil)
+	require.Nil(t, err)
+	assert.Equal(t, req, headers)
+	assert.Nil(t, data)
+	assert.Nil(t, trailers)
+}
@@ -0,0 +1,379 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2dubbo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+	"github.com/valyala/fasthttp"
+	"mosn.io/api"
+	apit "mosn.io/api/extensions/transcoder"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/protocol/http"
+	"mosn.io/pkg/variable"
+
+	"mosn.io/mosn/pkg/filter/stream/transcoder"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/xprotocol/dubbo"
+	"mosn.io/mosn/pkg/protocol/xprotocol/generic"
+	"mosn.io/mosn/pkg/types"
+)
+
+func init() {
+	transcoder.MustRegister("http2dubbo", NewTranscoder)
+	// the type used by the transcoder rules, which is {src protocol}_{upstream protocol}
+	transcoder.MustRegister(string(protocol.HTTP1)+"_"+string(dubbo.ProtocolName), NewTranscoder)
+}
+
+const (
+	hessianSerializationID = 2
+
+	// the flags of the dubbo request, which are the request bit, the two way bit and the serialization id
+	flagRequest = 0xc0 | hessianSerializationID
+
+	timeoutAttachment = "timeout"
+
+	// the headers override the version and the group in the config
+	versionHeader = "X-Dubbo-Version"
+	groupHeader   = "X-Dubbo-Group"
+
+	contentTypeJSON = "application/json"
+)
+
+type config struct {
+	Version string `json:"version,omitempty"`
+	Group   string `json:"group,omitempty"`
+	// Timeout is the timeout of the invocation in milliseconds, which is sent as the attachment
+	Timeout int `json:"timeout,omitempty"`
+}
+
+// request is the JSON body of the HTTP request, the arguments are converted to the parameter types by the provider
+type request struct {
+	ParameterTypes []string          `json:"parameterTypes"`
+	Args           []interface{}     `json:"args"`
+	Attachments    map[string]string `json:"attachments"`
+}
+
+// http2dubbo converts the HTTP/JSON requests to the dubbo generic invocations, the path of the request
+// is /{service}/{method} and the body is the parameter types and the arguments.
+type http2dubbo struct {
+	cfg     config
+	service string
+	method  string
+}
+
+func NewTranscoder(cfg map[string]interface{}) apit.Transcoder {
+	t := &http2dubbo{}
+	if v, ok := cfg["version"].(string); ok {
+		t.cfg.Version = v
+	}
+	if v, ok := cfg["group"].(string); ok {
+		t.cfg.Group = v
+	}
+	switch v := cfg["timeout"].(type) {
+	case float64:
+		t.cfg.Timeout = int(v)
+	case int:
+		t.cfg.Timeout = v
+	case string:
+		t.cfg.Timeout, _ = strconv.Atoi(v)
+	}
+	return t
+}
+
+// parsePath parses the service and the method from the path /{service}/{method}
+func parsePath(path string) (service, method string, ok bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// Accept accepts the HTTP/1 POST requests whose path is /{service}/{method}
+func (t *http2dubbo) Accept(ctx context.Context, headers api.HeaderMap, buf api.IoBuffer, trailers api.HeaderMap) bool {
+	header, ok := headers.(http.RequestHeader)
+	if !ok || string(header.Method()) != fasthttp.MethodPost {
+		return false
+	}
+	path, _ := variable.GetString(ctx, types.VarPath)
+	t.service, t.method, ok = parsePath(path)
+	return ok
+}
+
+// convertNumber converts the JSON number to the hessian value of the parameter type
+func convertNumber(n json.Number, typ string) (interface{}, error) {
+	switch typ {
+	case "int", "java.lang.Integer", "short", "java.lang.Short", "byte", "java.lang.Byte":
+		v, err := strconv.ParseInt(string(n), 10, 32)
+		return int32(v), err
+	case "long", "java.lang.Long":
+		return n.Int64()
+	case "double", "java.lang.Double", "float", "java.lang.Float":
+		return n.Float64()
+	}
+	if v, err := n.Int64(); err == nil {
+		if v >= math.MinInt32 && v <= math.MaxInt32 {
+			return int32(v), nil
+		}
+		return v, nil
+	}
+	return n.Float64()
+}
+
+// convertValue converts the JSON value to the hessian value, the numbers of the parameter
+// are converted to the parameter type, and the nested numbers are converted by their values.
+func convertValue(v interface{}, typ string) (interface{}, error) {
+	switch v := v.(type) {
+	case json.Number:
+		return convertNumber(v, typ)
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			converted, err := convertValue(item, strings.TrimSuffix(typ, "[]"))
+			if err != nil {
+				return nil, err
+			}
+			items[i] = converted
+		}
+		return items, nil
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			converted, err := convertValue(item, "")
+			if err != nil {
+				return nil, err
+			}
+			m[k] = converted
+		}
+		return m, nil
+	default:
+		return v, nil
+	}
+}
+
+func (t *http2dubbo) TranscodingRequest(ctx context.Context, headers api.HeaderMap, buf api.IoBuffer, trailers api.HeaderMap) (api.HeaderMap, api.IoBuffer, api.HeaderMap, error) {
+	header, ok := headers.(http.RequestHeader)
+	if !ok || t.service == "" {
+		return headers, buf, trailers, errors.New("request is not accepted")
+	}
+	req := &request{}
+	if buf != nil && len(bytes.TrimSpace(buf.Bytes())) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(buf.Bytes()))
+		decoder.UseNumber()
+		if err := decoder.Decode(req); err != nil {
+			return nil, nil, nil, fmt.Errorf("invalid request body: %v", err)
+		}
+	}
+	if len(req.Args) != len(req.ParameterTypes) {
+		return nil, nil, nil, fmt.Errorf("%d args mismatch %d parameter types", len(req.Args), len(req.ParameterTypes))
+	}
+	args := make([]interface{}, len(req.Args))
+	for i, arg := range req.Args {
+		v, err := convertValue(arg, req.ParameterTypes[i])
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("invalid arg %d: %v", i, err)
+		}
+		args[i] = v
+	}
+
+	version, group := t.cfg.Version, t.cfg.Group
+	if v := header.Peek(versionHeader); len(v) > 0 {
+		version = string(v)
+	}
+	if v := header.Peek(groupHeader); len(v) > 0 {
+		group = string(v)
+	}
+	inv, err := generic.NewGenericInvocation(t.service, version, group, t.method, req.ParameterTypes, args)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for k, v := range req.Attachments {
+		inv.Attachments[k] = v
+	}
+	if t.cfg.Timeout > 0 {
+		inv.Attachments[timeoutAttachment] = strconv.Itoa(t.cfg.Timeout)
+	}
+	payload, err := generic.EncodeDubboRequest(inv)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	frame := &dubbo.Frame{
+		Header: dubbo.Header{
+			Magic:           dubbo.MagicTag,
+			Flag:            flagRequest,
+			Direction:       dubbo.EventRequest,
+			IsTwoWay:        true,
+			SerializationId: hessianSerializationID,
+			CommonHeader: protocol.CommonHeader{
+				dubbo.ServiceNameHeader: inv.Service,
+				dubbo.MethodNameHeader:  inv.Method,
+			},
+		},
+	}
+	if version != "" {
+		frame.Set(dubbo.VersionNameHeader, version)
+	}
+	if group != "" {
+		frame.Set(dubbo.GroupNameHeader, group)
+	}
+	data := buffer.NewIoBufferBytes(payload)
+	frame.SetData(data)
+
+	_ = variable.Set(ctx, types.VariableUpstreamProtocol, dubbo.ProtocolName)
+	return frame, data, nil, nil
+}
+
+// httpStatus maps the dubbo response status to the HTTP status
+func httpStatus(status byte) int {
+	switch status {
+	case dubbo.RespStatusOK:
+		return fasthttp.StatusOK
+	case dubbo.RespStatusClientTimeout, dubbo.RespStatusServerTimeout:
+		return fasthttp.StatusGatewayTimeout
+	case dubbo.RespStatusBadRequest:
+		return fasthttp.StatusBadRequest
+	case dubbo.RespStatusServiceNotFound:
+		return fasthttp.StatusNotFound
+	case dubbo.RespStatusServerThreadpoolExhaustedError:
+		return fasthttp.StatusServiceUnavailable
+	case dubbo.RespStatusBadResponse, dubbo.RespStatusServerError:
+		return fasthttp.StatusBadGateway
+	default:
+		return fasthttp.StatusInternalServerError
+	}
+}
+
+// toJSONValue converts the hessian value to the value which can be marshaled to JSON
+func toJSONValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[fmt.Sprint(k)] = toJSONValue(item)
+		}
+		return m
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = toJSONValue(item)
+		}
+		return items
+	case []hessian.Object:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = toJSONValue(item)
+		}
+		return items
+	case float64:
+		// NaN and Inf are not valid JSON numbers
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return strconv.FormatFloat(v, 'g', -1, 64)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func errorJSON(msg string) []byte {
+	b, _ := json.Marshal(map[string]string{"error": msg})
+	return b
+}
+
+// responseJSON converts the body of the dubbo response whose status is OK to JSON,
+// the status is 500 if the service throws an exception.
+func responseJSON(body []byte) (int, []byte, error) {
+	res, err := generic.DecodeDubboResponse(body)
+	if err != nil {
+		return 0, nil, err
+	}
+	if res.Value == nil {
+		return fasthttp.StatusOK, []byte("null"), nil
+	}
+	values, err := res.Value.Standalone()
+	if err != nil {
+		return 0, nil, err
+	}
+	v, err := generic.DecodeValue(values[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	if res.Exception {
+		msg := fmt.Sprint(v)
+		if e, ok := v.(error); ok {
+			msg = e.Error()
+		}
+		return fasthttp.StatusInternalServerError, errorJSON(msg), nil
+	}
+	b, err := json.Marshal(toJSONValue(v))
+	if err != nil {
+		return 0, nil, err
+	}
+	return fasthttp.StatusOK, b, nil
+}
+
+func (t *http2dubbo) TranscodingResponse(ctx context.Context, headers api.HeaderMap, buf api.IoBuffer, trailers api.HeaderMap) (api.HeaderMap, api.IoBuffer, api.HeaderMap, error) {
+	frame, ok := headers.(*dubbo.Frame)
+	if !ok {
+		// if the response is not dubbo response, it maybe come from hijack or send directly response.
+		// so we just returns the original data
+		return headers, buf, trailers, nil
+	}
+	var data []byte
+	if buf != nil {
+		data = buf.Bytes()
+	}
+
+	var (
+		status int
+		body   []byte
+		err    error
+	)
+	if frame.Status == dubbo.RespStatusOK {
+		status, body, err = responseJSON(data)
+		if err != nil {
+			log.Proxy.Errorf(ctx, "[stream filter][transcoder][http2dubbo] decode dubbo response failed: %v", err)
+			status, body = fasthttp.StatusBadGateway, errorJSON("decode dubbo response failed: "+err.Error())
+		}
+	} else {
+		msg := generic.DecodeError(data)
+		if msg == "" {
+			msg = "dubbo response status " + strconv.Itoa(int(frame.Status))
+		}
+		status, body = httpStatus(frame.Status), errorJSON(msg)
+	}
+
+	out := http.ResponseHeader{ResponseHeader: &fasthttp.ResponseHeader{}}
+	out.SetContentType(contentTypeJSON)
+	out.SetStatusCode(status)
+	// the status of the HTTP/1 response is set by the variable
+	variable.SetString(ctx, types.VarHeaderStatus, strconv.Itoa(status))
+
+	return out, buffer.NewIoBufferBytes(body), nil, nil
+}
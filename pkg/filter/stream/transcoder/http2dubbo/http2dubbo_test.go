@@ -0,0 +1,211 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2dubbo
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+	"github.com/apache/dubbo-go-hessian2/java_exception"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/protocol/http"
+	"mosn.io/pkg/variable"
+
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/xprotocol/dubbo"
+	"mosn.io/mosn/pkg/protocol/xprotocol/generic"
+	"mosn.io/mosn/pkg/types"
+)
+
+func init() {
+	// the status variable is registered by the proxy
+	variable.Register(variable.NewStringVariable(types.VarHeaderStatus, nil, nil, variable.DefaultStringSetter, 0))
+}
+
+func newContext(path string) context.Context {
+	ctx := variable.NewVariableContext(context.Background())
+	variable.SetString(ctx, types.VarPath, path)
+	return ctx
+}
+
+func newRequest(method string) http.RequestHeader {
+	h := http.RequestHeader{RequestHeader: &fasthttp.RequestHeader{}}
+	h.SetMethod(method)
+	h.SetContentType("application/json")
+	return h
+}
+
+func newDubboResponse(status byte, body []byte) *dubbo.Frame {
+	frame := &dubbo.Frame{
+		Header: dubbo.Header{
+			Magic:           dubbo.MagicTag,
+			Flag:            0x02,
+			Status:          status,
+			Direction:       dubbo.EventResponse,
+			SerializationId: 2,
+			CommonHeader:    protocol.CommonHeader{},
+		},
+	}
+	frame.SetData(buffer.NewIoBufferBytes(body))
+	return frame
+}
+
+func TestAccept(t *testing.T) {
+	tc := NewTranscoder(nil)
+	assert.True(t, tc.Accept(newContext("/com.example.Greeter/greet"), newRequest("POST"), nil, nil))
+	assert.False(t, tc.Accept(newContext("/com.example.Greeter/greet"), newRequest("GET"), nil, nil))
+	assert.False(t, tc.Accept(newContext("/com.example.Greeter"), newRequest("POST"), nil, nil))
+	assert.False(t, tc.Accept(newContext("/a/b/c"), newRequest("POST"), nil, nil))
+	assert.False(t, tc.Accept(newContext("/com.example.Greeter/greet"), protocol.CommonHeader{}, nil, nil))
+}
+
+func TestTranscodingRequest(t *testing.T) {
+	tc := NewTranscoder(map[string]interface{}{"version": "1.0.0", "group": "blue", "timeout": float64(3000)})
+	ctx := newContext("/com.example.Greeter/greet")
+	header := newRequest("POST")
+	header.Set("X-Dubbo-Group", "green")
+	body := buffer.NewIoBufferString(`{
+		"parameterTypes": ["java.lang.String", "int", "long", "com.example.User", "int[]"],
+		"args": ["hello", 1, 2, {"name": "alice", "age": 20, "score": 1.5}, [1, 2]],
+		"attachments": {"trace": "abc"}
+	}`)
+	require.True(t, tc.Accept(ctx, header, body, nil))
+	headers, buf, _, err := tc.TranscodingRequest(ctx, header, body, nil)
+	require.Nil(t, err)
+	frame, ok := headers.(*dubbo.Frame)
+	require.True(t, ok)
+	assert.Equal(t, byte(0xc2), frame.Flag)
+	service, _ := frame.Get(dubbo.ServiceNameHeader)
+	version, _ := frame.Get(dubbo.VersionNameHeader)
+	group, _ := frame.Get(dubbo.GroupNameHeader)
+	assert.Equal(t, []string{"com.example.Greeter", "1.0.0", "green"}, []string{service, version, group})
+	proto, err := variable.Get(ctx, types.VariableUpstreamProtocol)
+	require.Nil(t, err)
+	assert.Equal(t, dubbo.ProtocolName, proto)
+
+	decoded := generic.DecodeHessian(t, buf.Bytes(), 9)
+	assert.Equal(t, []interface{}{"2.0.2", "com.example.Greeter", "1.0.0", "$invoke",
+		"Ljava/lang/String;[Ljava/lang/String;[Ljava/lang/Object;", "greet",
+		[]string{"java.lang.String", "int", "long", "com.example.User", "int[]"}}, decoded[:7])
+	assert.Equal(t, []hessian.Object{
+		"hello", int32(1), int64(2),
+		map[interface{}]interface{}{"name": "alice", "age": int32(20), "score": 1.5},
+		[]interface{}{int32(1), int32(2)},
+	}, decoded[7])
+	attachments := decoded[8].(map[interface{}]interface{})
+	assert.Equal(t, "true", attachments["generic"])
+	assert.Equal(t, "abc", attachments["trace"])
+	assert.Equal(t, "3000", attachments["timeout"])
+	assert.Equal(t, "green", attachments["group"])
+
+	// the method without arguments
+	tc = NewTranscoder(nil)
+	require.True(t, tc.Accept(ctx, header, nil, nil))
+	_, buf, _, err = tc.TranscodingRequest(ctx, header, nil, nil)
+	require.Nil(t, err)
+	decoded = generic.DecodeHessian(t, buf.Bytes(), 9)
+	assert.Equal(t, []string{}, decoded[6])
+	assert.Empty(t, decoded[7])
+
+	// the invalid requests
+	for _, body := range []string{
+		`{"args": [1]}`,
+		`{"parameterTypes": ["int"], "args": ["a", "b"]}`,
+		`{"parameterTypes": ["int"], "args": [1.5]}`,
+		`[1]`,
+	} {
+		tc = NewTranscoder(nil)
+		require.True(t, tc.Accept(ctx, header, nil, nil))
+		_, _, _, err = tc.TranscodingRequest(ctx, header, buffer.NewIoBufferString(body), nil)
+		assert.NotNil(t, err, body)
+	}
+}
+
+func TestTranscodingResponse(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		frame  *dubbo.Frame
+		status int
+		body   string
+	}{
+		{
+			name: "value",
+			frame: newDubboResponse(dubbo.RespStatusOK, generic.EncodeHessian(t, int32(4),
+				map[interface{}]interface{}{"class": "com.example.User", "name": "bob", "tags": []string{"a"}},
+				map[string]string{"trace": "abc"})),
+			status: 200,
+			body:   `{"class":"com.example.User","name":"bob","tags":["a"]}`,
+		},
+		{
+			name:   "null",
+			frame:  newDubboResponse(dubbo.RespStatusOK, generic.EncodeHessian(t, int32(2))),
+			status: 200,
+			body:   `null`,
+		},
+		{
+			name:   "exception",
+			frame:  newDubboResponse(dubbo.RespStatusOK, generic.EncodeHessian(t, int32(0), java_exception.NewException("boom"))),
+			status: 500,
+			body:   `{"error":"boom"}`,
+		},
+		{
+			name:   "timeout",
+			frame:  newDubboResponse(dubbo.RespStatusServerTimeout, generic.EncodeHessian(t, "timeout")),
+			status: 504,
+			body:   `{"error":"timeout"}`,
+		},
+		{
+			name:   "not found",
+			frame:  newDubboResponse(dubbo.RespStatusServiceNotFound, nil),
+			status: 404,
+			body:   `{"error":"dubbo response status 60"}`,
+		},
+		{
+			name:   "invalid",
+			frame:  newDubboResponse(dubbo.RespStatusOK, generic.EncodeHessian(t, "bad")),
+			status: 502,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := newContext("/com.example.Greeter/greet")
+			headers, buf, _, err := NewTranscoder(nil).TranscodingResponse(ctx, tc.frame, tc.frame.GetData(), nil)
+			require.Nil(t, err)
+			rsp, ok := headers.(http.ResponseHeader)
+			require.True(t, ok)
+			assert.Equal(t, tc.status, rsp.StatusCode())
+			assert.Equal(t, "application/json", string(rsp.ContentType()))
+			status, err := variable.GetString(ctx, types.VarHeaderStatus)
+			require.Nil(t, err)
+			assert.Equal(t, strconv.Itoa(tc.status), status)
+			if tc.body != "" {
+				assert.JSONEq(t, tc.body, buf.String())
+			}
+		})
+	}
+
+	// the hijacked response is returned as it is
+	hijacked := http.ResponseHeader{ResponseHeader: &fasthttp.ResponseHeader{}}
+	headers, _, _, err := NewTranscoder(nil).TranscodingResponse(newContext("/"), hijacked, nil, nil)
+	require.Nil(t, err)
+	assert.Equal(t, hijacked, headers)
+}
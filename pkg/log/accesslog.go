@@ -21,8 +21,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 
 	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/types"
 	"mosn.io/pkg/buffer"
 	"mosn.io/pkg/log"
@@ -33,6 +36,7 @@ import (
 var (
 	DefaultDisableAccessLog bool
 	accessLogs              []*accesslog
+	accessLogsMutex         sync.Mutex
 
 	ErrLogFormatUndefined = errors.New("access log format undefined")
 	ErrEmptyVarDef        = errors.New("access log format error: empty variable definition")
@@ -48,24 +52,33 @@ func init() {
 }
 
 func DisableAllAccessLog() {
+	accessLogsMutex.Lock()
+	defer accessLogsMutex.Unlock()
 	DefaultDisableAccessLog = true
 	for _, lg := range accessLogs {
-		lg.logger.Toggle(true)
+		lg.toggle(true)
 	}
 }
 
 func EnableAllAccessLog() {
+	accessLogsMutex.Lock()
+	defer accessLogsMutex.Unlock()
 	DefaultDisableAccessLog = false
 	for _, lg := range accessLogs {
-		lg.logger.Toggle(false)
+		lg.toggle(false)
 	}
 }
 
 // types.AccessLog
 type accesslog struct {
-	output  string
-	entries []*logEntry
-	logger  *log.Logger
+	output      string
+	entries     []*logEntry
+	jsonEntries []*jsonEntry
+	filter      AccessLogFilter
+	logger      *log.Logger
+	// sink replaces the logger if configured
+	sink     AccessLogSink
+	disabled int32
 }
 
 type logEntry struct {
@@ -120,24 +133,142 @@ func NewAccessLog(output string, format string) (api.AccessLog, error) {
 		logger:  lg,
 	}
 
+	return saveAccessLog(l), nil
+}
+
+// NewJSONAccessLog creates an access log that outputs a json object per line
+func NewJSONAccessLog(output string, format map[string]interface{}) (api.AccessLog, error) {
+	if len(format) == 0 {
+		return nil, ErrJSONFormatUndefined
+	}
+
+	lg, err := log.GetOrCreateLogger(output, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := parseJSONFormat(format)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &accesslog{
+		output:      output,
+		jsonEntries: entries,
+		logger:      lg,
+	}
+
+	return saveAccessLog(l), nil
+}
+
+// CreateAccessLog creates an access log according to the config
+func CreateAccessLog(cfg *v2.AccessLog) (api.AccessLog, error) {
+	l := &accesslog{
+		output: cfg.Path,
+	}
+	var err error
+	if len(cfg.JSONFormat) > 0 {
+		l.jsonEntries, err = parseJSONFormat(cfg.JSONFormat)
+	} else {
+		l.entries, err = parseFormat(cfg.Format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Filter != nil {
+		if l.filter, err = NewAccessLogFilter(cfg.Filter); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Sink != nil {
+		if l.sink, err = NewAccessLogSink(cfg.Sink); err != nil {
+			return nil, err
+		}
+	} else {
+		if l.logger, err = log.GetOrCreateLogger(cfg.Path, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return saveAccessLog(l), nil
+}
+
+func saveAccessLog(l *accesslog) *accesslog {
+	accessLogsMutex.Lock()
+	defer accessLogsMutex.Unlock()
 	if DefaultDisableAccessLog {
-		lg.Toggle(true) // disable accesslog by default
+		l.toggle(true) // disable accesslog by default
 	}
 	// save all access logs
 	accessLogs = append(accessLogs, l)
 
-	return l, nil
+	return l
+}
+
+// CloseAccessLog removes the access log created by CreateAccessLog and closes its sink, it should
+// be called when the access log is not used anymore. The file logger is not closed because it is
+// shared by the access logs with the same path.
+func CloseAccessLog(al api.AccessLog) error {
+	l, ok := al.(*accesslog)
+	if !ok {
+		return nil
+	}
+	accessLogsMutex.Lock()
+	for i, lg := range accessLogs {
+		if lg == l {
+			accessLogs = append(accessLogs[:i], accessLogs[i+1:]...)
+			break
+		}
+	}
+	accessLogsMutex.Unlock()
+	if l.sink != nil {
+		return l.sink.Close()
+	}
+	return nil
+}
+
+func (l *accesslog) toggle(disable bool) {
+	if l.logger != nil {
+		l.logger.Toggle(disable)
+		return
+	}
+	if disable {
+		atomic.StoreInt32(&l.disabled, 1)
+	} else {
+		atomic.StoreInt32(&l.disabled, 0)
+	}
+}
+
+func (l *accesslog) isDisabled() bool {
+	if l.logger != nil {
+		return l.logger.Disable()
+	}
+	return atomic.LoadInt32(&l.disabled) == 1
 }
 
 func (l *accesslog) Log(ctx context.Context, reqHeaders api.HeaderMap, respHeaders api.HeaderMap, requestInfo api.RequestInfo) {
 	// return directly
-	if l.logger.Disable() {
+	if l.isDisabled() {
+		return
+	}
+	if l.filter != nil && !l.filter.Evaluate(ctx, reqHeaders, respHeaders, requestInfo) {
 		return
 	}
 
 	buf := log.GetLogBuffer(AccessLogLen)
-	for idx := range l.entries {
-		l.entries[idx].log(ctx, buf)
+	if l.jsonEntries != nil {
+		writeJSONEntries(ctx, buf, l.jsonEntries)
+	} else {
+		for idx := range l.entries {
+			l.entries[idx].log(ctx, buf)
+		}
+	}
+	if l.sink != nil {
+		l.sink.Write(ctx, buf.Bytes(), reqHeaders, respHeaders, requestInfo)
+		log.PutLogBuffer(buf)
+		return
 	}
 	buf.WriteString("\n")
 	l.logger.Print(buf, true)
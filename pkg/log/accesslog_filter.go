@@ -0,0 +1,316 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
+
+var (
+	ErrEmptyAccessLogFilter = errors.New("access log filter error: no condition configured")
+)
+
+// ResponseFlagNames maps the response flag names used in access log filter to api.ResponseFlag
+var ResponseFlagNames = map[string]api.ResponseFlag{
+	"no_healthy_upstream":             api.NoHealthyUpstream,
+	"upstream_request_timeout":        api.UpstreamRequestTimeout,
+	"upstream_local_reset":            api.UpstreamLocalReset,
+	"upstream_remote_reset":           api.UpstreamRemoteReset,
+	"upstream_connection_failure":     api.UpstreamConnectionFailure,
+	"upstream_connection_termination": api.UpstreamConnectionTermination,
+	"upstream_overflow":               api.UpstreamOverflow,
+	"no_route_found":                  api.NoRouteFound,
+	"delay_injected":                  api.DelayInjected,
+	"fault_injected":                  api.FaultInjected,
+	"rate_limited":                    api.RateLimited,
+	"request_entity_too_large":        api.ReqEntityTooLarge,
+	"downstream_terminate":            api.DownStreamTerminate,
+}
+
+// AccessLogFilter decides whether a request should be logged
+type AccessLogFilter interface {
+	Evaluate(ctx context.Context, reqHeaders api.HeaderMap, respHeaders api.HeaderMap, requestInfo api.RequestInfo) bool
+}
+
+// NewAccessLogFilter creates an AccessLogFilter from config, all the configured conditions must be matched.
+func NewAccessLogFilter(cfg *v2.AccessLogFilter) (AccessLogFilter, error) {
+	var filters andFilter
+	if cfg.StatusCode != nil {
+		filters = append(filters, &statusCodeFilter{min: cfg.StatusCode.Min, max: cfg.StatusCode.Max})
+	}
+	if cfg.Duration != nil {
+		filters = append(filters, &durationFilter{min: cfg.Duration.Min.Duration, max: cfg.Duration.Max.Duration})
+	}
+	if cfg.Header != nil {
+		f, err := newHeaderFilter(cfg.Header)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	if cfg.ResponseFlag != nil {
+		f := &responseFlagFilter{}
+		for _, name := range cfg.ResponseFlag.Flags {
+			flag, ok := ResponseFlagNames[name]
+			if !ok {
+				return nil, fmt.Errorf("access log filter error: unknown response flag %s", name)
+			}
+			f.flags |= flag
+		}
+		filters = append(filters, f)
+	}
+	if cfg.Sampling != nil {
+		if cfg.Sampling.Percent < 0 || cfg.Sampling.Percent > 100 {
+			return nil, fmt.Errorf("access log filter error: sampling percent %v not in [0, 100]", cfg.Sampling.Percent)
+		}
+		filters = append(filters, newSamplingFilter(cfg.Sampling))
+	}
+	if len(cfg.And) > 0 {
+		f, err := newFilters(cfg.And)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, andFilter(f))
+	}
+	if len(cfg.Or) > 0 {
+		f, err := newFilters(cfg.Or)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, orFilter(f))
+	}
+
+	switch len(filters) {
+	case 0:
+		return nil, ErrEmptyAccessLogFilter
+	case 1:
+		return filters[0], nil
+	default:
+		return filters, nil
+	}
+}
+
+func newFilters(cfgs []v2.AccessLogFilter) ([]AccessLogFilter, error) {
+	filters := make([]AccessLogFilter, 0, len(cfgs))
+	for i := range cfgs {
+		f, err := NewAccessLogFilter(&cfgs[i])
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+type andFilter []AccessLogFilter
+
+func (fs andFilter) Evaluate(ctx context.Context, reqHeaders api.HeaderMap, respHeaders api.HeaderMap, requestInfo api.RequestInfo) bool {
+	for _, f := range fs {
+		if !f.Evaluate(ctx, reqHeaders, respHeaders, requestInfo) {
+			return false
+		}
+	}
+	return true
+}
+
+type orFilter []AccessLogFilter
+
+func (fs orFilter) Evaluate(ctx context.Context, reqHeaders api.HeaderMap, respHeaders api.HeaderMap, requestInfo api.RequestInfo) bool {
+	for _, f := range fs {
+		if f.Evaluate(ctx, reqHeaders, respHeaders, requestInfo) {
+			return true
+		}
+	}
+	return false
+}
+
+type statusCodeFilter struct {
+	min int
+	max int
+}
+
+func (f *statusCodeFilter) Evaluate(ctx context.Context, reqHeaders api.HeaderMap, respHeaders api.HeaderMap, requestInfo api.RequestInfo) bool {
+	if requestInfo == nil {
+		return false
+	}
+	code := requestInfo.ResponseCode()
+	return code >= f.min && (f.max == 0 || code <= f.max)
+}
+
+type durationFilter struct {
+	min time.Duration
+	max time.Duration
+}
+
+func (f *durationFilter) Evaluate(ctx context.Context, reqHeaders api.HeaderMap, respHeaders api.HeaderMap, requestInfo api.RequestInfo) bool {
+	if requestInfo == nil {
+		return false
+	}
+	d := requestInfo.RequestFinishedDuration()
+	return d >= f.min && (f.max == 0 || d <= f.max)
+}
+
+type headerFilter struct {
+	name  string
+	value string
+	regex *regexp.Regexp
+}
+
+func newHeaderFilter(cfg *v2.HeaderMatcher) (*headerFilter, error) {
+	if cfg.Name == "" {
+		return nil, errors.New("access log filter error: header name is empty")
+	}
+	f := &headerFilter{name: cfg.Name, value: cfg.Value}
+	if cfg.Regex {
+		regex, err := regexp.Compile(cfg.Value)
+		if err != nil {
+			return nil, fmt.Errorf("access log filter error: invalid header regex %s: %v", cfg.Value, err)
+		}
+		f.regex = regex
+	}
+	return f, nil
+}
+
+// Evaluate matches the request headers
+func (f *headerFilter) Evaluate(ctx context.Context, reqHeaders api.HeaderMap, respHeaders api.HeaderMap, requestInfo api.RequestInfo) bool {
+	if reqHeaders == nil {
+		return false
+	}
+	value, ok := reqHeaders.Get(f.name)
+	switch {
+	case !ok:
+		return false
+	case f.regex != nil:
+		return f.regex.MatchString(value)
+	case f.value == "":
+		return true
+	default:
+		return value == f.value
+	}
+}
+
+type responseFlagFilter struct {
+	flags api.ResponseFlag
+}
+
+// allResponseFlags is used when no flag is configured
+var allResponseFlags = func() (all api.ResponseFlag) {
+	for _, flag := range ResponseFlagNames {
+		all |= flag
+	}
+	return
+}()
+
+func (f *responseFlagFilter) Evaluate(ctx context.Context, reqHeaders api.HeaderMap, respHeaders api.HeaderMap, requestInfo api.RequestInfo) bool {
+	if requestInfo == nil {
+		return false
+	}
+	flags := f.flags
+	if flags == 0 {
+		flags = allResponseFlags
+	}
+	return requestInfo.GetResponseFlag(flags)
+}
+
+// samplingPercent is a percent can be updated at runtime, stored as the float64 bits
+type samplingPercent struct {
+	bits uint64
+}
+
+func newSamplingPercent(percent float64) *samplingPercent {
+	return &samplingPercent{bits: math.Float64bits(percent)}
+}
+
+func (p *samplingPercent) load() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&p.bits))
+}
+
+func (p *samplingPercent) store(percent float64) {
+	atomic.StoreUint64(&p.bits, math.Float64bits(percent))
+}
+
+var (
+	samplingPercentsMutex sync.Mutex
+	// samplingPercents are the runtime percents of the sampling filters by the runtime keys
+	samplingPercents = map[string]*samplingPercent{}
+)
+
+// SetSamplingPercent updates the percent of the sampling filters with the runtime key,
+// the percent is kept for the filters created later.
+func SetSamplingPercent(key string, percent float64) error {
+	if key == "" {
+		return errors.New("access log filter error: sampling runtime key is empty")
+	}
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("access log filter error: sampling percent %v not in [0, 100]", percent)
+	}
+	samplingPercentsMutex.Lock()
+	defer samplingPercentsMutex.Unlock()
+	if p, ok := samplingPercents[key]; ok {
+		p.store(percent)
+		return nil
+	}
+	samplingPercents[key] = newSamplingPercent(percent)
+	return nil
+}
+
+// GetSamplingPercents returns the percents by the runtime keys
+func GetSamplingPercents() map[string]float64 {
+	samplingPercentsMutex.Lock()
+	defer samplingPercentsMutex.Unlock()
+	percents := make(map[string]float64, len(samplingPercents))
+	for key, p := range samplingPercents {
+		percents[key] = p.load()
+	}
+	return percents
+}
+
+// samplingFilter samples the requests by percent, the percent is shared by the runtime key
+// if it is configured, and can be updated by SetSamplingPercent.
+type samplingFilter struct {
+	percent *samplingPercent
+}
+
+func newSamplingFilter(cfg *v2.SamplingFilter) *samplingFilter {
+	if cfg.RuntimeKey == "" {
+		return &samplingFilter{percent: newSamplingPercent(cfg.Percent)}
+	}
+	samplingPercentsMutex.Lock()
+	defer samplingPercentsMutex.Unlock()
+	p, ok := samplingPercents[cfg.RuntimeKey]
+	if !ok {
+		p = newSamplingPercent(cfg.Percent)
+		samplingPercents[cfg.RuntimeKey] = p
+	}
+	return &samplingFilter{percent: p}
+}
+
+func (f *samplingFilter) Evaluate(ctx context.Context, reqHeaders api.HeaderMap, respHeaders api.HeaderMap, requestInfo api.RequestInfo) bool {
+	return rand.Float64()*100 < f.percent.load()
+}
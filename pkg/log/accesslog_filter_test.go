@@ -0,0 +1,150 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
+
+func newFilterTestRequestInfo(code int, duration time.Duration, flag api.ResponseFlag) api.RequestInfo {
+	info := newRequestInfo()
+	info.SetResponseCode(code)
+	info.SetRequestFinishedDuration(info.StartTime().Add(duration))
+	info.SetResponseFlag(flag)
+	return info
+}
+
+func parseFilterConfig(t *testing.T, cfg string) AccessLogFilter {
+	filterCfg := &v2.AccessLogFilter{}
+	require.Nil(t, json.Unmarshal([]byte(cfg), filterCfg))
+	f, err := NewAccessLogFilter(filterCfg)
+	require.Nil(t, err)
+	return f
+}
+
+func TestAccessLogFilter(t *testing.T) {
+	headers := newHeaderMap(map[string]string{"service": "test", "x-debug": "1"})
+	testCases := []struct {
+		cfg      string
+		info     api.RequestInfo
+		headers  api.HeaderMap
+		expected bool
+	}{
+		{`{"status_code":{"min":500}}`, newFilterTestRequestInfo(503, 0, 0), nil, true},
+		{`{"status_code":{"min":500}}`, newFilterTestRequestInfo(200, 0, 0), nil, false},
+		{`{"status_code":{"min":400,"max":499}}`, newFilterTestRequestInfo(503, 0, 0), nil, false},
+		{`{"status_code":{"min":400,"max":499}}`, newFilterTestRequestInfo(404, 0, 0), nil, true},
+		{`{"duration":{"min":"1s"}}`, newFilterTestRequestInfo(200, 2*time.Second, 0), nil, true},
+		{`{"duration":{"min":"1s"}}`, newFilterTestRequestInfo(200, time.Millisecond, 0), nil, false},
+		{`{"duration":{"max":"1s"}}`, newFilterTestRequestInfo(200, time.Millisecond, 0), nil, true},
+		{`{"header":{"name":"x-debug"}}`, nil, headers, true},
+		{`{"header":{"name":"x-trace"}}`, nil, headers, false},
+		{`{"header":{"name":"service","value":"test"}}`, nil, headers, true},
+		{`{"header":{"name":"service","value":"te"}}`, nil, headers, false},
+		{`{"header":{"name":"service","value":"^t.*t$","regex":true}}`, nil, headers, true},
+		{`{"response_flag":{}}`, newFilterTestRequestInfo(200, 0, api.UpstreamRequestTimeout), nil, true},
+		{`{"response_flag":{}}`, newFilterTestRequestInfo(200, 0, 0), nil, false},
+		{`{"response_flag":{"flags":["no_healthy_upstream"]}}`, newFilterTestRequestInfo(503, 0, api.UpstreamRequestTimeout), nil, false},
+		{`{"response_flag":{"flags":["no_healthy_upstream","upstream_request_timeout"]}}`, newFilterTestRequestInfo(503, 0, api.UpstreamRequestTimeout), nil, true},
+		{`{"sampling":{"percent":100}}`, nil, nil, true},
+		{`{"sampling":{"percent":0}}`, nil, nil, false},
+		// multiple conditions are ANDed
+		{`{"status_code":{"min":500},"header":{"name":"x-debug"}}`, newFilterTestRequestInfo(503, 0, 0), nil, false},
+		{`{"status_code":{"min":500},"header":{"name":"x-debug"}}`, newFilterTestRequestInfo(503, 0, 0), headers, true},
+		{`{"or":[{"status_code":{"min":500}},{"duration":{"min":"1s"}}]}`, newFilterTestRequestInfo(200, 2*time.Second, 0), nil, true},
+		{`{"or":[{"status_code":{"min":500}},{"duration":{"min":"1s"}}]}`, newFilterTestRequestInfo(200, time.Millisecond, 0), nil, false},
+		{`{"and":[{"status_code":{"min":200,"max":299}},{"or":[{"header":{"name":"x-debug"}},{"sampling":{"percent":0}}]}]}`, newFilterTestRequestInfo(200, 0, 0), headers, true},
+	}
+	for i, tc := range testCases {
+		f := parseFilterConfig(t, tc.cfg)
+		require.Equal(t, tc.expected, f.Evaluate(context.Background(), tc.headers, nil, tc.info), "case %d: %s", i, tc.cfg)
+	}
+}
+
+func TestAccessLogFilterInvalid(t *testing.T) {
+	for _, cfg := range []string{
+		`{}`,
+		`{"and":[{}]}`,
+		`{"header":{"value":"test"}}`,
+		`{"header":{"name":"service","value":"(","regex":true}}`,
+		`{"response_flag":{"flags":["unknown"]}}`,
+		`{"sampling":{"percent":101}}`,
+	} {
+		filterCfg := &v2.AccessLogFilter{}
+		require.Nil(t, json.Unmarshal([]byte(cfg), filterCfg))
+		_, err := NewAccessLogFilter(filterCfg)
+		require.NotNil(t, err, "config %s should be invalid", cfg)
+	}
+}
+
+func TestSamplingFilterRuntimeKey(t *testing.T) {
+	f := parseFilterConfig(t, `{"sampling":{"percent":0,"runtime_key":"filter_test"}}`)
+	// the filters with the same key share the percent, the initial percent is ignored
+	shared := parseFilterConfig(t, `{"sampling":{"percent":100,"runtime_key":"filter_test"}}`)
+	static := parseFilterConfig(t, `{"sampling":{"percent":0}}`)
+	require.False(t, f.Evaluate(context.Background(), nil, nil, nil))
+	require.False(t, shared.Evaluate(context.Background(), nil, nil, nil))
+
+	require.Nil(t, SetSamplingPercent("filter_test", 100))
+	require.True(t, f.Evaluate(context.Background(), nil, nil, nil))
+	require.True(t, shared.Evaluate(context.Background(), nil, nil, nil))
+	require.False(t, static.Evaluate(context.Background(), nil, nil, nil))
+	require.Equal(t, float64(100), GetSamplingPercents()["filter_test"])
+
+	// the percent is kept for the filters created later
+	require.Nil(t, SetSamplingPercent("filter_test_later", 100))
+	require.True(t, parseFilterConfig(t, `{"sampling":{"runtime_key":"filter_test_later"}}`).Evaluate(context.Background(), nil, nil, nil))
+
+	require.NotNil(t, SetSamplingPercent("", 10))
+	require.NotNil(t, SetSamplingPercent("filter_test", 101))
+	require.Equal(t, float64(100), GetSamplingPercents()["filter_test"])
+}
+
+func TestCreateAccessLogWithFilter(t *testing.T) {
+	registerTestVarDefs()
+
+	logName := "/tmp/mosn_bench/filter_access.log"
+	os.Remove(logName)
+	accessLog, err := CreateAccessLog(&v2.AccessLog{
+		Path:   logName,
+		Format: "%response_code%",
+		Filter: &v2.AccessLogFilter{StatusCode: &v2.StatusCodeFilter{Min: 500}},
+	})
+	require.Nil(t, err)
+
+	ctx := prepareLocalIpv6Ctx()
+	info := ctx.Value(requestInfoKey).(api.RequestInfo)
+	// filtered
+	info.SetResponseCode(200)
+	accessLog.Log(ctx, nil, nil, info)
+	info.SetResponseCode(502)
+	accessLog.Log(ctx, nil, nil, info)
+	time.Sleep(2 * time.Second)
+	b, err := ioutil.ReadFile(logName)
+	require.Nil(t, err)
+	require.Equal(t, "502\n", string(b))
+}
@@ -0,0 +1,336 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+)
+
+// json value types, a single variable value can be cast with %name|type%
+const (
+	JSONTypeAuto     = ""
+	JSONTypeString   = "string"
+	JSONTypeNumber   = "number"
+	JSONTypeBool     = "bool"
+	JSONTypeDuration = "duration" // output as milliseconds
+)
+
+var (
+	ErrJSONFormatUndefined = errors.New("access log json format undefined")
+	ErrJSONFormatValue     = errors.New("access log json format error: value must be string or object")
+	ErrJSONFormatType      = errors.New("access log json format error: unknown value type")
+)
+
+const jsonNull = "null"
+
+// jsonEntry is a field of the json access log
+type jsonEntry struct {
+	key string // escaped and quoted key
+	// single variable value, which is typed
+	name string
+	typ  string
+	// text template value, which is always a string
+	entries []*logEntry
+	// nested object
+	children []*jsonEntry
+}
+
+// parseJSONFormat parses the json format, the keys are sorted to keep a stable output.
+// A value can be:
+//   - a single variable "%name%", the value is typed according to the variable value,
+//     or cast by "%name|type%", missing variable is output as null
+//   - a text template mixed with variables, e.g. "%protocol% %request_header_host%", output as string
+//   - an object, output as nested object
+func parseJSONFormat(format map[string]interface{}) ([]*jsonEntry, error) {
+	keys := make([]string, 0, len(format))
+	for k := range format {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	entries := make([]*jsonEntry, 0, len(keys))
+	for _, k := range keys {
+		kb := buffer.NewIoBuffer(len(k) + 2)
+		writeJSONString(kb, k)
+		entry := &jsonEntry{key: kb.String()}
+
+		switch v := format[k].(type) {
+		case string:
+			if err := entry.parseValue(v); err != nil {
+				return nil, fmt.Errorf("%v, key: %s", err, k)
+			}
+		case map[string]interface{}:
+			children, err := parseJSONFormat(v)
+			if err != nil {
+				return nil, err
+			}
+			entry.children = children
+		default:
+			return nil, fmt.Errorf("%v, key: %s", ErrJSONFormatValue, k)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (je *jsonEntry) parseValue(v string) error {
+	// single variable
+	if len(v) > 2 && v[0] == '%' && v[len(v)-1] == '%' && !strings.Contains(v[1:len(v)-1], "%") {
+		name := v[1 : len(v)-1]
+		if idx := strings.LastIndexByte(name, '|'); idx > 0 {
+			name, je.typ = name[:idx], name[idx+1:]
+		}
+		switch je.typ {
+		case JSONTypeAuto, JSONTypeString, JSONTypeNumber, JSONTypeBool, JSONTypeDuration:
+		default:
+			return fmt.Errorf("%v: %s", ErrJSONFormatType, je.typ)
+		}
+		if _, err := variable.Check(name); err != nil {
+			// adapt istio unknown fields, always null
+			je.typ = jsonNull
+		}
+		je.name = name
+		return nil
+	}
+	// empty text
+	if v == "" {
+		je.entries = []*logEntry{}
+		return nil
+	}
+	entries, err := parseFormat(v)
+	if err != nil {
+		return err
+	}
+	je.entries = entries
+	return nil
+}
+
+func writeJSONEntries(ctx context.Context, buf buffer.IoBuffer, entries []*jsonEntry) {
+	buf.WriteByte('{')
+	for idx, entry := range entries {
+		if idx > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(entry.key)
+		buf.WriteByte(':')
+		entry.log(ctx, buf)
+	}
+	buf.WriteByte('}')
+}
+
+func (je *jsonEntry) log(ctx context.Context, buf buffer.IoBuffer) {
+	switch {
+	case je.children != nil:
+		writeJSONEntries(ctx, buf, je.children)
+	case je.entries != nil:
+		text := buffer.GetIoBuffer(64)
+		for _, e := range je.entries {
+			e.log(ctx, text)
+		}
+		writeJSONString(buf, text.String())
+		buffer.PutIoBuffer(text)
+	default:
+		je.logVariable(ctx, buf)
+	}
+}
+
+func (je *jsonEntry) logVariable(ctx context.Context, buf buffer.IoBuffer) {
+	if je.typ == jsonNull {
+		buf.WriteString(jsonNull)
+		return
+	}
+	v, err := variable.Get(ctx, je.name)
+	if err != nil || v == nil {
+		buf.WriteString(jsonNull)
+		return
+	}
+	if s, ok := v.(string); ok && s == variable.ValueNotFound {
+		buf.WriteString(jsonNull)
+		return
+	}
+
+	switch je.typ {
+	case JSONTypeString:
+		writeJSONString(buf, toString(v))
+	case JSONTypeNumber:
+		if !writeJSONNumber(buf, v) {
+			buf.WriteString(jsonNull)
+		}
+	case JSONTypeBool:
+		if b, err := strconv.ParseBool(toString(v)); err == nil {
+			buf.WriteString(strconv.FormatBool(b))
+		} else {
+			buf.WriteString(jsonNull)
+		}
+	case JSONTypeDuration:
+		if d, ok := toDuration(v); ok {
+			writeJSONFloat(buf, float64(d)/float64(time.Millisecond), 64)
+		} else {
+			buf.WriteString(jsonNull)
+		}
+	default:
+		writeJSONAuto(buf, v)
+	}
+}
+
+// writeJSONAuto writes the value according to its go type
+func writeJSONAuto(buf buffer.IoBuffer, v interface{}) {
+	switch val := v.(type) {
+	case bool:
+		buf.WriteString(strconv.FormatBool(val))
+	case time.Duration:
+		writeJSONFloat(buf, float64(val)/float64(time.Millisecond), 64)
+	case time.Time:
+		writeJSONString(buf, val.Format(time.RFC3339Nano))
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		writeJSONNumber(buf, val)
+	default:
+		writeJSONString(buf, toString(val))
+	}
+}
+
+func toString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case fmt.Stringer:
+		return s.String()
+	default:
+		return fmt.Sprintf("%v", s)
+	}
+}
+
+func toDuration(v interface{}) (time.Duration, bool) {
+	if d, ok := v.(time.Duration); ok {
+		return d, true
+	}
+	d, err := time.ParseDuration(toString(v))
+	return d, err == nil
+}
+
+// writeJSONNumber writes the number, the integers are written exactly rather than converted to float64
+// which loses the precision above 2^53. It returns false if v is not a number.
+func writeJSONNumber(buf buffer.IoBuffer, v interface{}) bool {
+	var b [24]byte
+	switch n := v.(type) {
+	case int:
+		buf.Write(strconv.AppendInt(b[:0], int64(n), 10))
+	case int8:
+		buf.Write(strconv.AppendInt(b[:0], int64(n), 10))
+	case int16:
+		buf.Write(strconv.AppendInt(b[:0], int64(n), 10))
+	case int32:
+		buf.Write(strconv.AppendInt(b[:0], int64(n), 10))
+	case int64:
+		buf.Write(strconv.AppendInt(b[:0], n, 10))
+	case time.Duration:
+		buf.Write(strconv.AppendInt(b[:0], int64(n), 10))
+	case uint:
+		buf.Write(strconv.AppendUint(b[:0], uint64(n), 10))
+	case uint8:
+		buf.Write(strconv.AppendUint(b[:0], uint64(n), 10))
+	case uint16:
+		buf.Write(strconv.AppendUint(b[:0], uint64(n), 10))
+	case uint32:
+		buf.Write(strconv.AppendUint(b[:0], uint64(n), 10))
+	case uint64:
+		buf.Write(strconv.AppendUint(b[:0], n, 10))
+	case float32:
+		writeJSONFloat(buf, float64(n), 32)
+	case float64:
+		writeJSONFloat(buf, n, 64)
+	default:
+		str := toString(v)
+		if i, err := strconv.ParseInt(str, 10, 64); err == nil {
+			buf.Write(strconv.AppendInt(b[:0], i, 10))
+		} else if u, err := strconv.ParseUint(str, 10, 64); err == nil {
+			buf.Write(strconv.AppendUint(b[:0], u, 10))
+		} else if f, err := strconv.ParseFloat(str, 64); err == nil {
+			writeJSONFloat(buf, f, 64)
+		} else {
+			return false
+		}
+	}
+	return true
+}
+
+// writeJSONFloat writes the float, NaN and Inf are written as null because they are not valid in JSON
+func writeJSONFloat(buf buffer.IoBuffer, f float64, bitSize int) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		buf.WriteString(jsonNull)
+		return
+	}
+	buf.WriteString(strconv.FormatFloat(f, 'f', -1, bitSize))
+}
+
+const hex = "0123456789abcdef"
+
+// writeJSONString writes the quoted and escaped string, invalid utf8 is replaced by U+FFFD
+func writeJSONString(buf buffer.IoBuffer, s string) {
+	buf.WriteByte('"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' {
+				i++
+				continue
+			}
+			buf.WriteString(s[start:i])
+			switch b {
+			case '"', '\\':
+				buf.WriteByte('\\')
+				buf.WriteByte(b)
+			case '\n':
+				buf.WriteString(`\n`)
+			case '\r':
+				buf.WriteString(`\r`)
+			case '\t':
+				buf.WriteString(`\t`)
+			default:
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hex[b>>4])
+				buf.WriteByte(hex[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf.WriteString(s[start:i])
+			buf.WriteString("\ufffd")
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	buf.WriteString(s[start:])
+	buf.WriteByte('"')
+}
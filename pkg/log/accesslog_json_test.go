@@ -0,0 +1,145 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+)
+
+func registerJSONTestVarDefs() {
+	registerTestVarDefs()
+	variable.Register(variable.NewVariable("json_test_int", nil, func(ctx context.Context, value *variable.IndexedValue, data interface{}) (interface{}, error) {
+		return 42, nil
+	}, nil, 0))
+	variable.Register(variable.NewVariable("json_test_duration", nil, func(ctx context.Context, value *variable.IndexedValue, data interface{}) (interface{}, error) {
+		return 1500 * time.Microsecond, nil
+	}, nil, 0))
+	variable.Register(variable.NewStringVariable("json_test_string_duration", nil, func(ctx context.Context, value *variable.IndexedValue, data interface{}) (string, error) {
+		return "2.5s", nil
+	}, nil, 0))
+	variable.Register(variable.NewStringVariable("json_test_bool", nil, func(ctx context.Context, value *variable.IndexedValue, data interface{}) (string, error) {
+		return "true", nil
+	}, nil, 0))
+	variable.Register(variable.NewVariable("json_test_uint64", nil, func(ctx context.Context, value *variable.IndexedValue, data interface{}) (interface{}, error) {
+		return uint64(math.MaxUint64), nil
+	}, nil, 0))
+	variable.Register(variable.NewVariable("json_test_int64", nil, func(ctx context.Context, value *variable.IndexedValue, data interface{}) (interface{}, error) {
+		return -int64(1<<53 + 1), nil
+	}, nil, 0))
+	variable.Register(variable.NewStringVariable("json_test_string_int", nil, func(ctx context.Context, value *variable.IndexedValue, data interface{}) (string, error) {
+		return "9007199254740993", nil
+	}, nil, 0))
+	variable.Register(variable.NewStringVariable("json_test_escape", nil, func(ctx context.Context, value *variable.IndexedValue, data interface{}) (string, error) {
+		return "a\"b\\c\n\x01\xff", nil
+	}, nil, 0))
+}
+
+func TestJSONAccessLogFormat(t *testing.T) {
+	registerJSONTestVarDefs()
+
+	entries, err := parseJSONFormat(map[string]interface{}{
+		"bytes_sent":      "%bytes_sent|number%",
+		"bytes_sent_str":  "%bytes_sent%",
+		"int":             "%json_test_int%",
+		"duration":        "%json_test_duration%",
+		"string_duration": "%json_test_string_duration|duration%",
+		"bool":            "%json_test_bool|bool%",
+		"escape":          "%json_test_escape%",
+		"upstream_host":   "%upstream_host%",
+		"unknown":         "%istio_unknown_field%",
+		"text":            "service is %request_header_service%",
+		"empty":           "",
+		"request": map[string]interface{}{
+			"service": "%request_header_service%",
+			"server":  "%response_header_server|string%",
+		},
+	})
+	require.Nil(t, err)
+
+	buf := buffer.NewIoBuffer(256)
+	writeJSONEntries(prepareLocalIpv6Ctx(), buf, entries)
+	require.Equal(t, `{"bool":true,"bytes_sent":2048,"bytes_sent_str":"2048","duration":1.5,"empty":"",`+
+		`"escape":"a\"b\\c\n\u0001�","int":42,"request":{"server":"MOSN","service":"test"},`+
+		`"string_duration":2500,"text":"service is test","unknown":null,"upstream_host":null}`, buf.String())
+
+	out := map[string]interface{}{}
+	require.Nil(t, json.Unmarshal(buf.Bytes(), &out))
+}
+
+func TestJSONAccessLogLargeIntegers(t *testing.T) {
+	registerJSONTestVarDefs()
+
+	// the integers above 2^53 can not be represented by float64 exactly
+	entries, err := parseJSONFormat(map[string]interface{}{
+		"uint64":        "%json_test_uint64%",
+		"uint64_number": "%json_test_uint64|number%",
+		"int64":         "%json_test_int64%",
+		"string_int":    "%json_test_string_int|number%",
+	})
+	require.Nil(t, err)
+
+	buf := buffer.NewIoBuffer(256)
+	writeJSONEntries(prepareLocalIpv6Ctx(), buf, entries)
+	require.Equal(t, `{"int64":-9007199254740993,"string_int":9007199254740993,`+
+		`"uint64":18446744073709551615,"uint64_number":18446744073709551615}`, buf.String())
+}
+
+func TestJSONAccessLogInvalidFormat(t *testing.T) {
+	registerJSONTestVarDefs()
+
+	for _, format := range []map[string]interface{}{
+		{"k": 1},
+		{"k": "%bytes_sent|float%"},
+		{"k": "%bytes_sent"},
+		{"k": map[string]interface{}{"nested": "%%"}},
+	} {
+		_, err := parseJSONFormat(format)
+		require.NotNil(t, err, "format %v should be invalid", format)
+	}
+
+	_, err := NewJSONAccessLog("/tmp/mosn_bench/json_access.log", nil)
+	require.Equal(t, ErrJSONFormatUndefined, err)
+}
+
+func TestJSONAccessLog(t *testing.T) {
+	registerJSONTestVarDefs()
+
+	logName := "/tmp/mosn_bench/json_access.log"
+	os.Remove(logName)
+	accessLog, err := NewJSONAccessLog(logName, map[string]interface{}{
+		"bytes_sent": "%bytes_sent|number%",
+		"service":    "%request_header_service%",
+	})
+	require.Nil(t, err)
+
+	accessLog.Log(prepareLocalIpv6Ctx(), nil, nil, nil)
+	time.Sleep(2 * time.Second)
+	b, err := ioutil.ReadFile(logName)
+	require.Nil(t, err)
+	require.Equal(t, "{\"bytes_sent\":2048,\"service\":\"test\"}\n", string(b))
+}
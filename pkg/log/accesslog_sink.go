@@ -0,0 +1,63 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
+
+// AccessLogSink receives the formatted access log entries instead of the log file.
+// Write is called in the request goroutine, so it should not block. The entry, context,
+// headers and request info are reused after Write returned, the sink must copy what it needs.
+// Close is called when the access log is replaced or removed, the sink should flush the
+// buffered entries and release the resources, the entries written after Close may be lost.
+type AccessLogSink interface {
+	Write(ctx context.Context, entry []byte, reqHeaders api.HeaderMap, respHeaders api.HeaderMap, requestInfo api.RequestInfo)
+	Close() error
+}
+
+// AccessLogSinkCreator creates an AccessLogSink from the config
+type AccessLogSinkCreator func(cfg map[string]interface{}) (AccessLogSink, error)
+
+var (
+	sinkCreatorsMutex sync.RWMutex
+	sinkCreators      = map[string]AccessLogSinkCreator{}
+)
+
+// RegisterAccessLogSink registers the sink creator with the type
+func RegisterAccessLogSink(typ string, creator AccessLogSinkCreator) {
+	sinkCreatorsMutex.Lock()
+	defer sinkCreatorsMutex.Unlock()
+	sinkCreators[typ] = creator
+}
+
+// NewAccessLogSink creates an AccessLogSink by the registered creator
+func NewAccessLogSink(cfg *v2.AccessLogSink) (AccessLogSink, error) {
+	sinkCreatorsMutex.RLock()
+	creator, ok := sinkCreators[cfg.Type]
+	sinkCreatorsMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("access log sink type %s is not registered", cfg.Type)
+	}
+	return creator(cfg.Config)
+}
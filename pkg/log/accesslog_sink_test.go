@@ -0,0 +1,87 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
+
+type testSink struct {
+	entries []string
+	closed  bool
+}
+
+func (s *testSink) Write(ctx context.Context, entry []byte, reqHeaders api.HeaderMap, respHeaders api.HeaderMap, requestInfo api.RequestInfo) {
+	s.entries = append(s.entries, string(entry))
+}
+
+func (s *testSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestCreateAccessLogWithSink(t *testing.T) {
+	registerTestVarDefs()
+
+	s := &testSink{}
+	RegisterAccessLogSink("test_sink", func(cfg map[string]interface{}) (AccessLogSink, error) {
+		require.Equal(t, "bar", cfg["foo"])
+		return s, nil
+	})
+
+	accessLog, err := CreateAccessLog(&v2.AccessLog{
+		Format: "%response_code%",
+		Sink: &v2.AccessLogSink{
+			Type:   "test_sink",
+			Config: map[string]interface{}{"foo": "bar"},
+		},
+	})
+	require.Nil(t, err)
+	require.Nil(t, accessLog.(*accesslog).logger)
+
+	ctx := prepareLocalIpv6Ctx()
+	info := ctx.Value(requestInfoKey).(api.RequestInfo)
+	info.SetResponseCode(200)
+	accessLog.Log(ctx, nil, nil, info)
+	require.Equal(t, []string{"200"}, s.entries)
+
+	// toggle works without logger
+	DisableAllAccessLog()
+	accessLog.Log(ctx, nil, nil, info)
+	EnableAllAccessLog()
+	info.SetResponseCode(502)
+	accessLog.Log(ctx, nil, nil, info)
+	require.Equal(t, []string{"200", "502"}, s.entries)
+
+	// the closed access log is removed and the sink is closed
+	require.Nil(t, CloseAccessLog(accessLog))
+	require.True(t, s.closed)
+	for _, lg := range accessLogs {
+		require.NotEqual(t, accessLog, lg)
+	}
+
+	_, err = CreateAccessLog(&v2.AccessLog{
+		Sink: &v2.AccessLogSink{Type: "not_registered"},
+	})
+	require.NotNil(t, err)
+}
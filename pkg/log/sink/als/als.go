@@ -0,0 +1,320 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package als
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	accesslogdatav3 "github.com/envoyproxy/go-control-plane/envoy/data/accesslog/v3"
+	alsv3 "github.com/envoyproxy/go-control-plane/envoy/service/accesslog/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/istio"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/log/sink"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/utils"
+)
+
+const (
+	sinkType       = "envoy_als"
+	defaultLogName = "mosn"
+)
+
+func init() {
+	log.RegisterAccessLogSink(sinkType, NewSink)
+}
+
+// alsConfig contains config for the envoy access log service exporter
+type alsConfig struct {
+	sink.Config
+	// NodeID and Cluster identify the node in the stream, defaults to the xds node info
+	NodeID  string `json:"node_id"`
+	Cluster string `json:"cluster"`
+	// AdditionalRequestHeaders and AdditionalResponseHeaders are the headers added to the log entries
+	AdditionalRequestHeaders  []string `json:"additional_request_headers,omitempty"`
+	AdditionalResponseHeaders []string `json:"additional_response_headers,omitempty"`
+}
+
+// alsSink sends the access log entries as envoy HTTPAccessLogEntry by the
+// StreamAccessLogs API. The entries are built from the request properties,
+// the formatted entry is not used.
+type alsSink struct {
+	config    *alsConfig
+	processor *sink.BatchProcessor
+	exporter  *exporter
+}
+
+// NewSink creates an envoy access log service sink
+func NewSink(cfg map[string]interface{}) (log.AccessLogSink, error) {
+	config := &alsConfig{}
+	if err := sink.ParseConfig(cfg, config, &config.Config); err != nil {
+		return nil, err
+	}
+	if config.LogName == "" {
+		config.LogName = defaultLogName
+	}
+	info := istio.GetGlobalXdsInfo()
+	if config.NodeID == "" {
+		config.NodeID = info.ServiceNode
+	}
+	if config.Cluster == "" {
+		config.Cluster = info.ServiceCluster
+	}
+	exporter, err := newExporter(config)
+	if err != nil {
+		return nil, err
+	}
+	processor, err := sink.NewBatchProcessor(sinkType, &config.Config, exporter)
+	if err != nil {
+		return nil, err
+	}
+	return &alsSink{config: config, processor: processor, exporter: exporter}, nil
+}
+
+func (s *alsSink) Write(ctx context.Context, entry []byte, reqHeaders api.HeaderMap, respHeaders api.HeaderMap, requestInfo api.RequestInfo) {
+	s.processor.Enqueue(s.newEntry(ctx, reqHeaders, respHeaders, requestInfo))
+}
+
+// Close exports the buffered entries and closes the connection
+func (s *alsSink) Close() error {
+	s.processor.Stop()
+	return s.exporter.close()
+}
+
+func (s *alsSink) newEntry(ctx context.Context, reqHeaders api.HeaderMap, respHeaders api.HeaderMap, requestInfo api.RequestInfo) *accesslogdatav3.HTTPAccessLogEntry {
+	common := &accesslogdatav3.AccessLogCommon{
+		SampleRate:      1,
+		UpstreamCluster: getVariable(ctx, types.VarUpstreamCluster),
+	}
+	request := &accesslogdatav3.HTTPRequestProperties{
+		Scheme:    getVariable(ctx, types.VarScheme),
+		Authority: getVariable(ctx, types.VarHost),
+		Path:      getVariable(ctx, types.VarPath),
+	}
+	if method, ok := corev3.RequestMethod_value[strings.ToUpper(getVariable(ctx, types.VarMethod))]; ok {
+		request.RequestMethod = corev3.RequestMethod(method)
+	}
+	response := &accesslogdatav3.HTTPResponseProperties{}
+
+	if reqHeaders != nil {
+		request.UserAgent, _ = reqHeaders.Get("User-Agent")
+		request.Referer, _ = reqHeaders.Get("Referer")
+		request.ForwardedFor, _ = reqHeaders.Get("X-Forwarded-For")
+		request.RequestId, _ = reqHeaders.Get("X-Request-Id")
+		request.RequestHeaders = getHeaders(reqHeaders, s.config.AdditionalRequestHeaders)
+	}
+	if respHeaders != nil {
+		response.ResponseHeaders = getHeaders(respHeaders, s.config.AdditionalResponseHeaders)
+	}
+
+	entry := &accesslogdatav3.HTTPAccessLogEntry{
+		CommonProperties: common,
+		Request:          request,
+		Response:         response,
+	}
+	if requestInfo == nil {
+		return entry
+	}
+
+	switch requestInfo.Protocol() {
+	case protocol.HTTP1:
+		entry.ProtocolVersion = accesslogdatav3.HTTPAccessLogEntry_HTTP11
+	case protocol.HTTP2:
+		entry.ProtocolVersion = accesslogdatav3.HTTPAccessLogEntry_HTTP2
+	}
+
+	common.StartTime = timestamppb.New(requestInfo.StartTime())
+	common.TimeToLastRxByte = durationpb.New(requestInfo.RequestReceivedDuration())
+	common.TimeToFirstUpstreamRxByte = durationpb.New(requestInfo.ResponseReceivedDuration())
+	common.TimeToLastDownstreamTxByte = durationpb.New(requestInfo.RequestFinishedDuration())
+	common.DownstreamRemoteAddress = toAddress(requestInfo.DownstreamRemoteAddress())
+	common.DownstreamLocalAddress = toAddress(requestInfo.DownstreamLocalAddress())
+	if host := requestInfo.UpstreamHost(); host != nil {
+		common.UpstreamRemoteAddress = parseAddress(host.AddressString())
+	}
+	common.UpstreamLocalAddress = parseAddress(requestInfo.UpstreamLocalAddress())
+	common.ResponseFlags = toResponseFlags(requestInfo)
+	if rule, ok := requestInfo.RouteEntry().(types.NamedRouteRule); ok {
+		common.RouteName = rule.Name()
+	}
+
+	request.RequestBodyBytes = requestInfo.BytesReceived()
+	response.ResponseBodyBytes = requestInfo.BytesSent()
+	if code := requestInfo.ResponseCode(); code > 0 {
+		response.ResponseCode = wrapperspb.UInt32(uint32(code))
+	}
+	return entry
+}
+
+func getVariable(ctx context.Context, name string) string {
+	v, err := log.GetVariableValueAsString(ctx, name)
+	if err != nil {
+		return ""
+	}
+	return v
+}
+
+func getHeaders(headers api.HeaderMap, keys []string) map[string]string {
+	if len(keys) == 0 {
+		return nil
+	}
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		if v, ok := headers.Get(key); ok {
+			values[key] = v
+		}
+	}
+	return values
+}
+
+func toAddress(addr net.Addr) *corev3.Address {
+	if addr == nil {
+		return nil
+	}
+	return parseAddress(addr.String())
+}
+
+func parseAddress(addr string) *corev3.Address {
+	if addr == "" {
+		return nil
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil
+	}
+	p, err := strconv.ParseUint(port, 10, 32)
+	if err != nil {
+		return nil
+	}
+	return &corev3.Address{
+		Address: &corev3.Address_SocketAddress{
+			SocketAddress: &corev3.SocketAddress{
+				Address:       host,
+				PortSpecifier: &corev3.SocketAddress_PortValue{PortValue: uint32(p)},
+			},
+		},
+	}
+}
+
+func toResponseFlags(requestInfo api.RequestInfo) *accesslogdatav3.ResponseFlags {
+	return &accesslogdatav3.ResponseFlags{
+		NoHealthyUpstream:               requestInfo.GetResponseFlag(api.NoHealthyUpstream),
+		UpstreamRequestTimeout:          requestInfo.GetResponseFlag(api.UpstreamRequestTimeout),
+		LocalReset:                      requestInfo.GetResponseFlag(api.UpstreamLocalReset),
+		UpstreamRemoteReset:             requestInfo.GetResponseFlag(api.UpstreamRemoteReset),
+		UpstreamConnectionFailure:       requestInfo.GetResponseFlag(api.UpstreamConnectionFailure),
+		UpstreamConnectionTermination:   requestInfo.GetResponseFlag(api.UpstreamConnectionTermination),
+		UpstreamOverflow:                requestInfo.GetResponseFlag(api.UpstreamOverflow),
+		NoRouteFound:                    requestInfo.GetResponseFlag(api.NoRouteFound),
+		DelayInjected:                   requestInfo.GetResponseFlag(api.DelayInjected),
+		FaultInjected:                   requestInfo.GetResponseFlag(api.FaultInjected),
+		RateLimited:                     requestInfo.GetResponseFlag(api.RateLimited),
+		DownstreamConnectionTermination: requestInfo.GetResponseFlag(api.DownStreamTerminate),
+	}
+}
+
+// exporter sends the entries in a long-lived stream, the node identifier is
+// sent in the first message of each stream. The stream is recreated after errors.
+type exporter struct {
+	config *alsConfig
+	conn   *grpc.ClientConn
+	client alsv3.AccessLogServiceClient
+	stream alsv3.AccessLogService_StreamAccessLogsClient
+	cancel context.CancelFunc
+}
+
+func newExporter(config *alsConfig) (*exporter, error) {
+	conn, err := sink.Dial(&config.Config)
+	if err != nil {
+		return nil, err
+	}
+	return &exporter{
+		config: config,
+		conn:   conn,
+		client: alsv3.NewAccessLogServiceClient(conn),
+	}, nil
+}
+
+// close is called after the processor is stopped
+func (e *exporter) close() error {
+	if e.cancel != nil {
+		e.cancel()
+		e.stream, e.cancel = nil, nil
+	}
+	return e.conn.Close()
+}
+
+// Export is called in the processor goroutine only
+func (e *exporter) Export(ctx context.Context, batch []interface{}) error {
+	entries := make([]*accesslogdatav3.HTTPAccessLogEntry, 0, len(batch))
+	for _, item := range batch {
+		entries = append(entries, item.(*accesslogdatav3.HTTPAccessLogEntry))
+	}
+	msg := &alsv3.StreamAccessLogsMessage{
+		LogEntries: &alsv3.StreamAccessLogsMessage_HttpLogs{
+			HttpLogs: &alsv3.StreamAccessLogsMessage_HTTPAccessLogEntries{LogEntry: entries},
+		},
+	}
+	if e.stream == nil {
+		// the stream outlives the export context
+		streamCtx, cancel := context.WithCancel(context.Background())
+		stream, err := e.client.StreamAccessLogs(streamCtx, grpc.WaitForReady(false))
+		if err != nil {
+			cancel()
+			return err
+		}
+		e.stream, e.cancel = stream, cancel
+		msg.Identifier = &alsv3.StreamAccessLogsMessage_Identifier{
+			Node: &corev3.Node{
+				Id:      e.config.NodeID,
+				Cluster: e.config.Cluster,
+			},
+			LogName: e.config.LogName,
+		}
+	}
+	if err := e.send(ctx, msg); err != nil {
+		e.cancel()
+		e.stream, e.cancel = nil, nil
+		return err
+	}
+	return nil
+}
+
+// send sends the message with the export timeout, the stream Send blocks by the flow control
+func (e *exporter) send(ctx context.Context, msg *alsv3.StreamAccessLogsMessage) error {
+	stream := e.stream
+	errCh := make(chan error, 1)
+	utils.GoWithRecover(func() {
+		errCh <- stream.Send(msg)
+	}, nil)
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,144 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package als
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	alsv3 "github.com/envoyproxy/go-control-plane/envoy/service/accesslog/v3"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/protocol"
+)
+
+type testALSServer struct {
+	alsv3.UnimplementedAccessLogServiceServer
+	mutex    sync.Mutex
+	messages []*alsv3.StreamAccessLogsMessage
+}
+
+func (s *testALSServer) StreamAccessLogs(stream alsv3.AccessLogService_StreamAccessLogsServer) error {
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		s.mutex.Lock()
+		s.messages = append(s.messages, msg)
+		s.mutex.Unlock()
+	}
+}
+
+func (s *testALSServer) count() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.messages)
+}
+
+type testHeaders struct {
+	api.HeaderMap
+	values map[string]string
+}
+
+func (h *testHeaders) Get(key string) (string, bool) {
+	v, ok := h.values[key]
+	return v, ok
+}
+
+func TestALSSink(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	server := &testALSServer{}
+	gs := grpc.NewServer()
+	alsv3.RegisterAccessLogServiceServer(gs, server)
+	go gs.Serve(ln)
+	defer gs.Stop()
+
+	s, err := NewSink(map[string]interface{}{
+		"address":                    ln.Addr().String(),
+		"insecure":                   true,
+		"log_name":                   "als_test",
+		"node_id":                    "node-1",
+		"batch_size":                 1,
+		"additional_request_headers": []string{"x-tenant"},
+	})
+	require.Nil(t, err)
+
+	reqHeaders := &testHeaders{values: map[string]string{
+		"User-Agent": "curl",
+		"x-tenant":   "foo",
+	}}
+	info := &testRequestInfo{
+		start:    time.Now(),
+		code:     503,
+		flags:    api.NoHealthyUpstream,
+		protocol: protocol.HTTP1,
+		remote:   &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 12345},
+	}
+	s.Write(context.Background(), nil, reqHeaders, nil, info)
+	s.Write(context.Background(), nil, reqHeaders, nil, info)
+	require.Eventually(t, func() bool {
+		return server.count() == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Nil(t, s.Close())
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	// only the first message of the stream has the identifier
+	first := server.messages[0]
+	require.Equal(t, "node-1", first.Identifier.Node.Id)
+	require.Equal(t, "als_test", first.Identifier.LogName)
+	require.Nil(t, server.messages[1].Identifier)
+
+	entry := first.GetHttpLogs().LogEntry[0]
+	require.Equal(t, "curl", entry.Request.UserAgent)
+	require.Equal(t, map[string]string{"x-tenant": "foo"}, entry.Request.RequestHeaders)
+	require.Equal(t, uint32(503), entry.Response.ResponseCode.GetValue())
+	require.True(t, entry.CommonProperties.ResponseFlags.NoHealthyUpstream)
+	require.Equal(t, "10.0.0.1", entry.CommonProperties.DownstreamRemoteAddress.GetSocketAddress().Address)
+	require.Equal(t, uint32(12345), entry.CommonProperties.DownstreamRemoteAddress.GetSocketAddress().GetPortValue())
+}
+
+type testRequestInfo struct {
+	api.RequestInfo
+	start    time.Time
+	code     int
+	flags    api.ResponseFlag
+	protocol api.ProtocolName
+	remote   net.Addr
+}
+
+func (r *testRequestInfo) StartTime() time.Time                       { return r.start }
+func (r *testRequestInfo) RequestReceivedDuration() time.Duration     { return time.Millisecond }
+func (r *testRequestInfo) ResponseReceivedDuration() time.Duration    { return 2 * time.Millisecond }
+func (r *testRequestInfo) RequestFinishedDuration() time.Duration     { return 3 * time.Millisecond }
+func (r *testRequestInfo) BytesSent() uint64                          { return 10 }
+func (r *testRequestInfo) BytesReceived() uint64                      { return 20 }
+func (r *testRequestInfo) Protocol() api.ProtocolName                 { return r.protocol }
+func (r *testRequestInfo) ResponseCode() int                          { return r.code }
+func (r *testRequestInfo) GetResponseFlag(flag api.ResponseFlag) bool { return r.flags&flag != 0 }
+func (r *testRequestInfo) UpstreamHost() api.HostInfo                 { return nil }
+func (r *testRequestInfo) UpstreamLocalAddress() string               { return "" }
+func (r *testRequestInfo) DownstreamLocalAddress() net.Addr           { return nil }
+func (r *testRequestInfo) DownstreamRemoteAddress() net.Addr          { return r.remote }
+func (r *testRequestInfo) RouteEntry() api.RouteRule                  { return nil }
@@ -0,0 +1,312 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package sink contains the shared parts of the remote access log sinks,
+// the entries are buffered and exported in batches by a background goroutine.
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/pkg/utils"
+)
+
+const (
+	defaultBufferSize    = 1024
+	defaultBatchSize     = 100
+	defaultFlushInterval = time.Second
+	defaultTimeout       = 5 * time.Second
+	defaultMaxBackoff    = 30 * time.Second
+	defaultMaxRetries    = 10
+	initialBackoff       = 100 * time.Millisecond
+)
+
+// metrics of the access log sinks, labeled by sink type and log name
+const (
+	MetricsType          = "accesslog"
+	MetricsSent          = "sent"
+	MetricsDropped       = "dropped"
+	MetricsExportFailed  = "export_failed"
+	MetricsQueueCapacity = "queue_capacity"
+)
+
+var (
+	ErrEmptyAddress = errors.New("access log sink address is empty")
+	ErrNoSecurity   = errors.New("access log sink requires tls, or insecure should be set to use plaintext")
+	ErrTLSInsecure  = errors.New("access log sink tls and insecure are exclusive")
+)
+
+// Config is the common config of the remote access log sinks
+type Config struct {
+	Address string `json:"address"`
+	LogName string `json:"log_name"`
+	// BufferSize is the max entries waiting for export, the new entries are dropped if the buffer is full
+	BufferSize    int                `json:"buffer_size"`
+	BatchSize     int                `json:"batch_size"`
+	FlushInterval api.DurationConfig `json:"flush_interval"`
+	// Timeout limits each export
+	Timeout api.DurationConfig `json:"timeout"`
+	// MaxBackoff limits the wait time after export failures, the entries are buffered during backoff
+	MaxBackoff api.DurationConfig `json:"max_backoff"`
+	// MaxRetries limits the retries of a failed batch, the batch is dropped after that
+	MaxRetries int `json:"max_retries"`
+	// TLS secures the connection to the collector, plaintext is used only if Insecure is set explicitly
+	TLS      *v2.TLSConfig `json:"tls,omitempty"`
+	Insecure bool          `json:"insecure,omitempty"`
+}
+
+// ParseConfig parses the config map into v, which should embed Config, and sets the defaults
+func ParseConfig(cfg map[string]interface{}, v interface{}, c *Config) error {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+	if c.Address == "" {
+		return ErrEmptyAddress
+	}
+	if c.TLS == nil && !c.Insecure {
+		return ErrNoSecurity
+	}
+	if c.TLS != nil && c.Insecure {
+		return ErrTLSInsecure
+	}
+	if c.BufferSize <= 0 {
+		c.BufferSize = defaultBufferSize
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaultBatchSize
+	}
+	if c.BatchSize > c.BufferSize {
+		c.BatchSize = c.BufferSize
+	}
+	if c.FlushInterval.Duration <= 0 {
+		c.FlushInterval.Duration = defaultFlushInterval
+	}
+	if c.Timeout.Duration <= 0 {
+		c.Timeout.Duration = defaultTimeout
+	}
+	if c.MaxBackoff.Duration <= 0 {
+		c.MaxBackoff.Duration = defaultMaxBackoff
+	}
+	if c.MaxRetries <= 0 {
+		c.MaxRetries = defaultMaxRetries
+	}
+	return nil
+}
+
+// Exporter sends a batch of entries to the remote collector
+type Exporter interface {
+	Export(ctx context.Context, batch []interface{}) error
+}
+
+// BatchProcessor buffers the entries in a bounded queue and exports them in batches.
+// Enqueue never blocks, the entries are dropped when the queue is full, which happens
+// when the collector is slower than the requests or is unavailable.
+type BatchProcessor struct {
+	config   *Config
+	exporter Exporter
+	queue    chan interface{}
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+
+	sent         gometrics.Counter
+	dropped      gometrics.Counter
+	exportFailed gometrics.Counter
+}
+
+// NewBatchProcessor creates a BatchProcessor and starts the export goroutine
+func NewBatchProcessor(typ string, config *Config, exporter Exporter) (*BatchProcessor, error) {
+	stats, err := metrics.NewMetrics(MetricsType, map[string]string{
+		"sink":     typ,
+		"log_name": config.LogName,
+	})
+	if err != nil {
+		return nil, err
+	}
+	p := &BatchProcessor{
+		config:       config,
+		exporter:     exporter,
+		queue:        make(chan interface{}, config.BufferSize),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+		sent:         stats.Counter(MetricsSent),
+		dropped:      stats.Counter(MetricsDropped),
+		exportFailed: stats.Counter(MetricsExportFailed),
+	}
+	stats.Gauge(MetricsQueueCapacity).Update(int64(config.BufferSize))
+	utils.GoWithRecover(p.run, nil)
+	return p, nil
+}
+
+// Enqueue adds the entry to the queue, returns false if the entry is dropped
+func (p *BatchProcessor) Enqueue(entry interface{}) bool {
+	select {
+	case p.queue <- entry:
+		return true
+	default:
+		p.dropped.Inc(1)
+		return false
+	}
+}
+
+// Stop exports the buffered entries and stops the processor
+func (p *BatchProcessor) Stop() {
+	p.once.Do(func() {
+		close(p.stop)
+	})
+	<-p.done
+}
+
+func (p *BatchProcessor) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.config.FlushInterval.Duration)
+	defer ticker.Stop()
+
+	batch := make([]interface{}, 0, p.config.BatchSize)
+	var backoff time.Duration
+	var retries int
+	var retry *time.Timer
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := p.export(batch); err != nil {
+			if !retryable(err) || retries >= p.config.MaxRetries {
+				p.dropped.Inc(int64(len(batch)))
+				log.DefaultLogger.Errorf("[accesslog] [sink] export %d entries to %s failed after %d retries, dropped: %v",
+					len(batch), p.config.Address, retries, err)
+				backoff, retries = 0, 0
+				batch = batch[:0]
+				return
+			}
+			retries++
+			if backoff *= 2; backoff == 0 {
+				backoff = initialBackoff
+			} else if backoff > p.config.MaxBackoff.Duration {
+				backoff = p.config.MaxBackoff.Duration
+			}
+			// keep the batch and retry it after backoff, the queue is not consumed during
+			// backoff, the new entries are buffered in the queue and dropped when the queue is full.
+			retry = time.NewTimer(backoff)
+			log.DefaultLogger.Errorf("[accesslog] [sink] export %d entries to %s failed, retry after %s: %v",
+				len(batch), p.config.Address, backoff, err)
+			return
+		}
+		backoff, retries = 0, 0
+		batch = batch[:0]
+	}
+
+	for {
+		queue := p.queue
+		var retryC <-chan time.Time
+		if retry != nil {
+			queue = nil
+			retryC = retry.C
+		}
+
+		select {
+		case <-p.stop:
+			if retry != nil {
+				retry.Stop()
+			}
+			p.drain(batch)
+			return
+		case entry := <-queue:
+			batch = append(batch, entry)
+			if len(batch) >= p.config.BatchSize {
+				flush()
+			}
+		case <-retryC:
+			retry = nil
+			flush()
+		case <-ticker.C:
+			if retry == nil {
+				flush()
+			}
+		}
+	}
+}
+
+// drain exports the remaining entries, gives up at the first failure and drops the remaining entries
+func (p *BatchProcessor) drain(batch []interface{}) {
+	for {
+		select {
+		case entry := <-p.queue:
+			batch = append(batch, entry)
+			if len(batch) < p.config.BatchSize {
+				continue
+			}
+		default:
+		}
+		if len(batch) > 0 {
+			if err := p.export(batch); err != nil {
+				remain := len(batch) + len(p.queue)
+				p.dropped.Inc(int64(remain))
+				log.DefaultLogger.Errorf("[accesslog] [sink] export %d entries to %s failed when stopping, %d entries dropped: %v",
+					len(batch), p.config.Address, remain, err)
+				return
+			}
+			batch = batch[:0]
+		}
+		if len(p.queue) == 0 {
+			return
+		}
+	}
+}
+
+func (p *BatchProcessor) export(batch []interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.Timeout.Duration)
+	defer cancel()
+	if err := p.exporter.Export(ctx, batch); err != nil {
+		p.exportFailed.Inc(1)
+		return err
+	}
+	p.sent.Inc(int64(len(batch)))
+	return nil
+}
+
+// retryable returns false if the collector rejects the batch, retrying it won't succeed.
+// The errors without grpc status, e.g. the export timeout, are retryable.
+func retryable(err error) bool {
+	st, ok := status.FromError(err)
+	if !ok {
+		return true
+	}
+	switch st.Code() {
+	case codes.InvalidArgument, codes.PermissionDenied, codes.Unauthenticated, codes.NotFound,
+		codes.AlreadyExists, codes.FailedPrecondition, codes.Unimplemented:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,252 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sink
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"mosn.io/mosn/pkg/metrics"
+)
+
+type testExporter struct {
+	mutex   sync.Mutex
+	batches [][]interface{}
+	err     error
+	block   chan struct{}
+}
+
+func (e *testExporter) Export(ctx context.Context, batch []interface{}) error {
+	if e.block != nil {
+		<-e.block
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.err != nil {
+		return e.err
+	}
+	b := make([]interface{}, len(batch))
+	copy(b, batch)
+	e.batches = append(e.batches, b)
+	return nil
+}
+
+func (e *testExporter) count() (batches, entries int) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	for _, b := range e.batches {
+		entries += len(b)
+	}
+	return len(e.batches), entries
+}
+
+func newTestConfig(t *testing.T, cfg map[string]interface{}) *Config {
+	metrics.ResetAll()
+	cfg["insecure"] = true
+	c := &Config{}
+	require.Nil(t, ParseConfig(cfg, c, c))
+	return c
+}
+
+func TestParseConfig(t *testing.T) {
+	c := newTestConfig(t, map[string]interface{}{"address": "127.0.0.1:4317"})
+	require.Equal(t, defaultBufferSize, c.BufferSize)
+	require.Equal(t, defaultBatchSize, c.BatchSize)
+	require.Equal(t, defaultFlushInterval, c.FlushInterval.Duration)
+	require.Equal(t, defaultTimeout, c.Timeout.Duration)
+	require.Equal(t, defaultMaxRetries, c.MaxRetries)
+
+	c = newTestConfig(t, map[string]interface{}{
+		"address":        "127.0.0.1:4317",
+		"buffer_size":    10,
+		"batch_size":     20,
+		"flush_interval": "100ms",
+	})
+	require.Equal(t, 10, c.BatchSize)
+	require.Equal(t, 100*time.Millisecond, c.FlushInterval.Duration)
+
+	require.Equal(t, ErrEmptyAddress, ParseConfig(map[string]interface{}{}, c, &Config{}))
+
+	// plaintext should be set explicitly
+	c = &Config{}
+	require.Equal(t, ErrNoSecurity, ParseConfig(map[string]interface{}{"address": "127.0.0.1:4317"}, c, c))
+	c = &Config{}
+	require.Equal(t, ErrTLSInsecure, ParseConfig(map[string]interface{}{
+		"address":  "127.0.0.1:4317",
+		"insecure": true,
+		"tls":      map[string]interface{}{"ca_cert": "ca.pem"},
+	}, c, c))
+}
+
+func TestBatchProcessorFlush(t *testing.T) {
+	exporter := &testExporter{}
+	p, err := NewBatchProcessor("test", newTestConfig(t, map[string]interface{}{
+		"address":        "flush",
+		"batch_size":     3,
+		"flush_interval": "50ms",
+	}), exporter)
+	require.Nil(t, err)
+
+	for i := 0; i < 4; i++ {
+		require.True(t, p.Enqueue(i))
+	}
+	// a full batch is exported at once, the remaining one by the interval
+	require.Eventually(t, func() bool {
+		batches, entries := exporter.count()
+		return batches == 2 && entries == 4
+	}, time.Second, 10*time.Millisecond)
+
+	require.True(t, p.Enqueue(4))
+	p.Stop()
+	_, entries := exporter.count()
+	require.Equal(t, 5, entries)
+	require.Equal(t, int64(5), p.sent.Count())
+}
+
+func TestBatchProcessorDrop(t *testing.T) {
+	exporter := &testExporter{block: make(chan struct{})}
+	p, err := NewBatchProcessor("test", newTestConfig(t, map[string]interface{}{
+		"address":     "drop",
+		"buffer_size": 2,
+		"batch_size":  1,
+	}), exporter)
+	require.Nil(t, err)
+
+	// the first entry is taken by the blocked export, the next two fill the queue
+	require.True(t, p.Enqueue(0))
+	require.Eventually(t, func() bool {
+		return len(p.queue) == 0
+	}, time.Second, 10*time.Millisecond)
+	require.True(t, p.Enqueue(1))
+	require.True(t, p.Enqueue(2))
+	require.False(t, p.Enqueue(3))
+	require.Equal(t, int64(1), p.dropped.Count())
+
+	close(exporter.block)
+	p.Stop()
+	_, entries := exporter.count()
+	require.Equal(t, 3, entries)
+}
+
+func TestBatchProcessorBackoff(t *testing.T) {
+	exporter := &testExporter{err: errors.New("unavailable")}
+	p, err := NewBatchProcessor("test", newTestConfig(t, map[string]interface{}{
+		"address":     "backoff",
+		"buffer_size": 2,
+		"batch_size":  1,
+		"max_backoff": "10s",
+	}), exporter)
+	require.Nil(t, err)
+
+	require.True(t, p.Enqueue(0))
+	require.Eventually(t, func() bool {
+		return p.exportFailed.Count() == 1
+	}, time.Second, 10*time.Millisecond)
+	// the queue is not consumed during backoff
+	require.True(t, p.Enqueue(1))
+	require.True(t, p.Enqueue(2))
+	require.False(t, p.Enqueue(3))
+	require.Equal(t, int64(1), p.dropped.Count())
+	// the failed batch is retried
+	require.Eventually(t, func() bool {
+		return p.exportFailed.Count() >= 2
+	}, time.Second, 10*time.Millisecond)
+
+	// the failed batch and the buffered entries are exported after recovered
+	exporter.mutex.Lock()
+	exporter.err = nil
+	exporter.mutex.Unlock()
+	require.Eventually(t, func() bool {
+		_, entries := exporter.count()
+		return entries == 3
+	}, 2*time.Second, 10*time.Millisecond)
+	exporter.mutex.Lock()
+	require.Equal(t, []interface{}{0}, exporter.batches[0])
+	exporter.mutex.Unlock()
+	p.Stop()
+
+	// the entries are dropped if the export still fails when stopping
+	exporter = &testExporter{err: errors.New("unavailable")}
+	p, err = NewBatchProcessor("test", newTestConfig(t, map[string]interface{}{
+		"address":    "backoff",
+		"batch_size": 1,
+	}), exporter)
+	require.Nil(t, err)
+	require.True(t, p.Enqueue(0))
+	require.Eventually(t, func() bool {
+		return p.exportFailed.Count() == 1
+	}, time.Second, 10*time.Millisecond)
+	require.True(t, p.Enqueue(1))
+	p.Stop()
+	require.Equal(t, int64(2), p.dropped.Count())
+}
+
+func TestBatchProcessorRetries(t *testing.T) {
+	exporter := &testExporter{err: errors.New("unavailable")}
+	p, err := NewBatchProcessor("test", newTestConfig(t, map[string]interface{}{
+		"address":     "retries",
+		"batch_size":  1,
+		"max_backoff": "10ms",
+		"max_retries": 2,
+	}), exporter)
+	require.Nil(t, err)
+
+	// the batch is dropped after the retries
+	require.True(t, p.Enqueue(0))
+	require.Eventually(t, func() bool {
+		return p.dropped.Count() == 1
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, int64(3), p.exportFailed.Count())
+
+	// the batch is dropped at once if the collector rejects it
+	exporter.mutex.Lock()
+	exporter.err = status.Error(codes.PermissionDenied, "denied")
+	exporter.mutex.Unlock()
+	require.True(t, p.Enqueue(1))
+	require.Eventually(t, func() bool {
+		return p.dropped.Count() == 2
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, int64(4), p.exportFailed.Count())
+
+	// the next batch is exported after recovered
+	exporter.mutex.Lock()
+	exporter.err = nil
+	exporter.mutex.Unlock()
+	require.True(t, p.Enqueue(2))
+	require.Eventually(t, func() bool {
+		_, entries := exporter.count()
+		return entries == 1
+	}, time.Second, 10*time.Millisecond)
+	p.Stop()
+	require.Equal(t, []interface{}{2}, exporter.batches[0])
+}
+
+func TestRetryable(t *testing.T) {
+	require.True(t, retryable(errors.New("unavailable")))
+	require.True(t, retryable(context.DeadlineExceeded))
+	require.True(t, retryable(status.Error(codes.Unavailable, "unavailable")))
+	require.True(t, retryable(status.Error(codes.ResourceExhausted, "exhausted")))
+	require.False(t, retryable(status.Error(codes.InvalidArgument, "invalid")))
+	require.False(t, retryable(status.Error(codes.Unauthenticated, "unauthenticated")))
+}
@@ -0,0 +1,72 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sink
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"mosn.io/mosn/pkg/mtls"
+)
+
+const alpnHTTP2 = "h2"
+
+var errTLSNotReady = errors.New("access log sink tls is not ready")
+
+// Dial creates the grpc connection to the collector, the connection is established
+// lazily and reconnected by grpc. If TLS is configured, the connection is secured by
+// the mosn tls context, which supports the certificates from files and sds.
+func Dial(config *Config) (*grpc.ClientConn, error) {
+	if config.TLS == nil {
+		return grpc.Dial(config.Address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+	tlsConfig := *config.TLS
+	tlsConfig.Status = true
+	// the grpc servers require the negotiated http2
+	if tlsConfig.ALPN == "" {
+		tlsConfig.ALPN = alpnHTTP2
+	}
+	mng, err := mtls.NewTLSClientContextManager("accesslog_sink_"+config.Address, &tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	dialer := func(ctx context.Context, addr string) (net.Conn, error) {
+		// never fall back to plaintext
+		if !mng.Enabled() {
+			return nil, errTLSNotReady
+		}
+		var d net.Dialer
+		c, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := mng.Conn(c)
+		if err != nil {
+			return nil, err
+		}
+		// clears the handshake deadline
+		conn.SetReadDeadline(time.Time{})
+		return conn, nil
+	}
+	// the transport credentials are insecure because the handshake is done by the dialer
+	return grpc.Dial(config.Address, grpc.WithContextDialer(dialer), grpc.WithTransportCredentials(insecure.NewCredentials()))
+}
@@ -0,0 +1,207 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package otlp
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	collectorlogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/log/sink"
+	"mosn.io/mosn/pkg/types"
+)
+
+const (
+	sinkType           = "otlp"
+	defaultServiceName = "mosn"
+	scopeName          = "mosn.io/mosn/pkg/log"
+)
+
+func init() {
+	log.RegisterAccessLogSink(sinkType, NewSink)
+}
+
+// otlpConfig contains config for the otlp logs exporter
+type otlpConfig struct {
+	sink.Config
+	ServiceName string `json:"service_name"`
+	// Headers are sent as grpc metadata, e.g. the authentication token
+	Headers map[string]string `json:"headers,omitempty"`
+	// ResourceAttributes are added to the resource besides service.name
+	ResourceAttributes map[string]string `json:"resource_attributes,omitempty"`
+}
+
+// otlpSink sends the access log entries as OTLP log records, the formatted
+// entry is the body and the request properties are the attributes.
+type otlpSink struct {
+	processor *sink.BatchProcessor
+	exporter  *exporter
+}
+
+// NewSink creates an otlp access log sink
+func NewSink(cfg map[string]interface{}) (log.AccessLogSink, error) {
+	config := &otlpConfig{}
+	if err := sink.ParseConfig(cfg, config, &config.Config); err != nil {
+		return nil, err
+	}
+	if config.ServiceName == "" {
+		config.ServiceName = defaultServiceName
+	}
+	exporter, err := newExporter(config)
+	if err != nil {
+		return nil, err
+	}
+	processor, err := sink.NewBatchProcessor(sinkType, &config.Config, exporter)
+	if err != nil {
+		return nil, err
+	}
+	return &otlpSink{processor: processor, exporter: exporter}, nil
+}
+
+func (s *otlpSink) Write(ctx context.Context, entry []byte, reqHeaders api.HeaderMap, respHeaders api.HeaderMap, requestInfo api.RequestInfo) {
+	s.processor.Enqueue(newLogRecord(ctx, entry, requestInfo))
+}
+
+// Close exports the buffered entries and closes the connection
+func (s *otlpSink) Close() error {
+	s.processor.Stop()
+	return s.exporter.conn.Close()
+}
+
+func newLogRecord(ctx context.Context, entry []byte, requestInfo api.RequestInfo) *logspb.LogRecord {
+	now := time.Now()
+	record := &logspb.LogRecord{
+		TimeUnixNano:         uint64(now.UnixNano()),
+		ObservedTimeUnixNano: uint64(now.UnixNano()),
+		SeverityNumber:       logspb.SeverityNumber_SEVERITY_NUMBER_INFO,
+		SeverityText:         "INFO",
+		Body:                 stringValue(string(entry)),
+	}
+	for _, attr := range variableAttributes {
+		if v, err := log.GetVariableValueAsString(ctx, attr.variable); err == nil && v != "" {
+			record.Attributes = append(record.Attributes, stringAttribute(attr.key, v))
+		}
+	}
+	if requestInfo == nil {
+		return record
+	}
+	if start := requestInfo.StartTime(); !start.IsZero() {
+		record.TimeUnixNano = uint64(start.UnixNano())
+	}
+	record.Attributes = append(record.Attributes,
+		intAttribute("http.status_code", int64(requestInfo.ResponseCode())),
+		intAttribute("mosn.request_duration_ms", requestInfo.RequestFinishedDuration().Milliseconds()),
+		intAttribute("mosn.bytes_received", int64(requestInfo.BytesReceived())),
+		intAttribute("mosn.bytes_sent", int64(requestInfo.BytesSent())),
+	)
+	if p := requestInfo.Protocol(); p != "" {
+		record.Attributes = append(record.Attributes, stringAttribute("mosn.protocol", string(p)))
+	}
+	if addr := requestInfo.DownstreamRemoteAddress(); addr != nil {
+		record.Attributes = append(record.Attributes, stringAttribute("net.sock.peer.addr", addr.String()))
+	}
+	if host := requestInfo.UpstreamHost(); host != nil {
+		record.Attributes = append(record.Attributes, stringAttribute("mosn.upstream_host", host.AddressString()))
+	}
+	return record
+}
+
+// variableAttributes are the attributes read from variables, missing variables are ignored
+var variableAttributes = []struct {
+	key      string
+	variable string
+}{
+	{"http.method", types.VarMethod},
+	{"http.scheme", types.VarScheme},
+	{"http.host", types.VarHost},
+	{"http.target", types.VarPath},
+	{"mosn.upstream_cluster", types.VarUpstreamCluster},
+}
+
+func stringValue(s string) *commonpb.AnyValue {
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: s}}
+}
+
+func stringAttribute(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: stringValue(value)}
+}
+
+func intAttribute(key string, value int64) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: value}}}
+}
+
+// exporter exports the log records by the otlp grpc LogsService
+type exporter struct {
+	conn     *grpc.ClientConn
+	client   collectorlogs.LogsServiceClient
+	resource *resourcepb.Resource
+	md       metadata.MD
+}
+
+func newExporter(config *otlpConfig) (*exporter, error) {
+	conn, err := sink.Dial(&config.Config)
+	if err != nil {
+		return nil, err
+	}
+	resource := &resourcepb.Resource{
+		Attributes: []*commonpb.KeyValue{stringAttribute("service.name", config.ServiceName)},
+	}
+	keys := make([]string, 0, len(config.ResourceAttributes))
+	for k := range config.ResourceAttributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		resource.Attributes = append(resource.Attributes, stringAttribute(k, config.ResourceAttributes[k]))
+	}
+	return &exporter{
+		conn:     conn,
+		client:   collectorlogs.NewLogsServiceClient(conn),
+		resource: resource,
+		md:       metadata.New(config.Headers),
+	}, nil
+}
+
+func (e *exporter) Export(ctx context.Context, batch []interface{}) error {
+	records := make([]*logspb.LogRecord, 0, len(batch))
+	for _, item := range batch {
+		records = append(records, item.(*logspb.LogRecord))
+	}
+	req := &collectorlogs.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{{
+			Resource: e.resource,
+			ScopeLogs: []*logspb.ScopeLogs{{
+				Scope:      &commonpb.InstrumentationScope{Name: scopeName},
+				LogRecords: records,
+			}},
+		}},
+	}
+	if len(e.md) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, e.md)
+	}
+	_, err := e.client.Export(ctx, req)
+	return err
+}
@@ -0,0 +1,146 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package otlp
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	collectorlogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"mosn.io/mosn/pkg/mtls/certtool"
+)
+
+type testLogsServer struct {
+	collectorlogs.UnimplementedLogsServiceServer
+	mutex    sync.Mutex
+	requests []*collectorlogs.ExportLogsServiceRequest
+	token    []string
+}
+
+func (s *testLogsServer) Export(ctx context.Context, req *collectorlogs.ExportLogsServiceRequest) (*collectorlogs.ExportLogsServiceResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.token = md.Get("x-token")
+	s.requests = append(s.requests, req)
+	return &collectorlogs.ExportLogsServiceResponse{}, nil
+}
+
+func (s *testLogsServer) count() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.requests)
+}
+
+func TestOTLPSink(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	server := &testLogsServer{}
+	gs := grpc.NewServer()
+	collectorlogs.RegisterLogsServiceServer(gs, server)
+	go gs.Serve(ln)
+	defer gs.Stop()
+
+	s, err := NewSink(map[string]interface{}{
+		"address":             ln.Addr().String(),
+		"insecure":            true,
+		"log_name":            "otlp_test",
+		"batch_size":          2,
+		"headers":             map[string]string{"x-token": "secret"},
+		"resource_attributes": map[string]string{"env": "test"},
+	})
+	require.Nil(t, err)
+
+	s.Write(context.Background(), []byte("line 1"), nil, nil, nil)
+	s.Write(context.Background(), []byte("line 2"), nil, nil, nil)
+	require.Eventually(t, func() bool {
+		return server.count() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	// the buffered entries are exported when closing
+	s.Write(context.Background(), []byte("line 3"), nil, nil, nil)
+	require.Nil(t, s.Close())
+	require.Equal(t, 2, server.count())
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	require.Equal(t, []string{"secret"}, server.token)
+	rl := server.requests[0].ResourceLogs[0]
+	require.Equal(t, "service.name", rl.Resource.Attributes[0].Key)
+	require.Equal(t, "mosn", rl.Resource.Attributes[0].Value.GetStringValue())
+	require.Equal(t, "env", rl.Resource.Attributes[1].Key)
+	records := rl.ScopeLogs[0].LogRecords
+	require.Len(t, records, 2)
+	require.Equal(t, "line 1", records[0].Body.GetStringValue())
+	require.Equal(t, "line 2", records[1].Body.GetStringValue())
+}
+
+func TestOTLPSinkTLS(t *testing.T) {
+	priv, err := certtool.GeneratePrivateKey("P256")
+	require.Nil(t, err)
+	tmpl, err := certtool.CreateTemplate("collector", false, []string{"collector.test"})
+	require.Nil(t, err)
+	info, err := certtool.SignCertificate(tmpl, priv)
+	require.Nil(t, err)
+	cert, err := tls.X509KeyPair([]byte(info.CertPem), []byte(info.KeyPem))
+	require.Nil(t, err)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	server := &testLogsServer{}
+	gs := grpc.NewServer(grpc.Creds(credentials.NewServerTLSFromCert(&cert)))
+	collectorlogs.RegisterLogsServiceServer(gs, server)
+	go gs.Serve(ln)
+	defer gs.Stop()
+
+	s, err := NewSink(map[string]interface{}{
+		"address":    ln.Addr().String(),
+		"log_name":   "otlp_tls_test",
+		"batch_size": 1,
+		"tls": map[string]interface{}{
+			"ca_cert":     certtool.GetRootCA().CertPem,
+			"server_name": "collector.test",
+		},
+	})
+	require.Nil(t, err)
+	s.Write(context.Background(), []byte("line 1"), nil, nil, nil)
+	require.Eventually(t, func() bool {
+		return server.count() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Nil(t, s.Close())
+
+	// the server rejects the plaintext connection
+	s, err = NewSink(map[string]interface{}{
+		"address":    ln.Addr().String(),
+		"log_name":   "otlp_plaintext_test",
+		"insecure":   true,
+		"batch_size": 1,
+		"timeout":    "100ms",
+	})
+	require.Nil(t, err)
+	s.Write(context.Background(), []byte("line 2"), nil, nil, nil)
+	require.Nil(t, s.Close())
+	require.Equal(t, 1, server.count())
+}
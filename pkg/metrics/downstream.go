@@ -57,6 +57,15 @@ const (
 	DownstreamRequestOtherTotal  = "request_other_code"
 )
 
+// metrics key in virtual host/route, the status codes are counted by class
+const (
+	DownstreamRequest1xxTotal = "request_1xx_total"
+	DownstreamRequest2xxTotal = "request_2xx_total"
+	DownstreamRequest3xxTotal = "request_3xx_total"
+	DownstreamRequest4xxTotal = "request_4xx_total"
+	DownstreamRequest5xxTotal = "request_5xx_total"
+)
+
 // NewProxyStats returns a stats with namespace prefix proxy
 func NewProxyStats(proxyName string) types.Metrics {
 	metrics, _ := NewMetrics(DownstreamType, map[string]string{"proxy": proxyName})
@@ -68,3 +77,15 @@ func NewListenerStats(listenerName string) types.Metrics {
 	metrics, _ := NewMetrics(DownstreamType, map[string]string{"listener": listenerName})
 	return metrics
 }
+
+// NewVirtualHostStats returns a stats with namespace prefix proxy and virtual host
+func NewVirtualHostStats(proxyName, virtualHostName string) types.Metrics {
+	metrics, _ := NewMetrics(DownstreamType, map[string]string{"proxy": proxyName, "virtual_host": virtualHostName})
+	return metrics
+}
+
+// NewRouteStats returns a stats with namespace prefix proxy, virtual host and route
+func NewRouteStats(proxyName, virtualHostName, routeName string) types.Metrics {
+	metrics, _ := NewMetrics(DownstreamType, map[string]string{"proxy": proxyName, "virtual_host": virtualHostName, "route": routeName})
+	return metrics
+}
@@ -0,0 +1,197 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+// BucketHistogram is a histogram that counts the values in fixed buckets
+// besides the sampled values, the buckets can be exported as prometheus histogram.
+type BucketHistogram interface {
+	gometrics.Histogram
+	// Buckets returns the upper bounds of the buckets, in increasing order
+	Buckets() []float64
+	// BucketCounts returns the cumulative counts of each bucket,
+	// the values that greater than the last bound are only counted in Count
+	BucketCounts() []int64
+}
+
+var (
+	bucketsMutex     sync.RWMutex
+	histogramBuckets []float64
+	keyBuckets       map[string][]float64
+)
+
+// SetHistogramBuckets sets the default buckets for all histograms and the buckets for
+// the specified keys. Histograms without buckets only keep the sampled values.
+// It should be called before the histograms created.
+func SetHistogramBuckets(buckets []float64, keys map[string][]float64) error {
+	if err := checkBuckets(buckets); err != nil {
+		return err
+	}
+	for key, b := range keys {
+		if err := checkBuckets(b); err != nil {
+			return fmt.Errorf("invalid buckets for %s: %v", key, err)
+		}
+	}
+	bucketsMutex.Lock()
+	defer bucketsMutex.Unlock()
+	histogramBuckets = buckets
+	keyBuckets = keys
+	return nil
+}
+
+func checkBuckets(buckets []float64) error {
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i] <= buckets[i-1] {
+			return fmt.Errorf("buckets must be in increasing order: %v", buckets)
+		}
+	}
+	return nil
+}
+
+func getHistogramBuckets(key string) []float64 {
+	bucketsMutex.RLock()
+	defer bucketsMutex.RUnlock()
+	if b, ok := keyBuckets[key]; ok {
+		return b
+	}
+	return histogramBuckets
+}
+
+// NewBucketHistogram returns a BucketHistogram with the sample and buckets.
+func NewBucketHistogram(s gometrics.Sample, buckets []float64) BucketHistogram {
+	return &bucketHistogram{
+		Histogram: gometrics.NewHistogram(s),
+		bounds:    buckets,
+		counts:    make([]int64, len(buckets)+1),
+	}
+}
+
+// bucketHistogram counts the values in the buckets under the lock, so the bucket counts,
+// the count and the sum in a snapshot are consistent. The count is the count of the buckets
+// instead of the sample, the last bucket counts the values greater than the last bound.
+type bucketHistogram struct {
+	gometrics.Histogram
+	bounds []float64
+	mutex  sync.Mutex
+	counts []int64 // not cumulative
+	sum    int64
+}
+
+func (h *bucketHistogram) Buckets() []float64 {
+	return h.bounds
+}
+
+func (h *bucketHistogram) BucketCounts() []int64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	counts, _ := h.cumulativeCounts()
+	return counts
+}
+
+// cumulativeCounts returns the cumulative counts of the buckets and the count of all values
+func (h *bucketHistogram) cumulativeCounts() ([]int64, int64) {
+	counts := make([]int64, len(h.bounds))
+	var total int64
+	for i := range h.bounds {
+		total += h.counts[i]
+		counts[i] = total
+	}
+	return counts, total + h.counts[len(h.bounds)]
+}
+
+func (h *bucketHistogram) Clear() {
+	h.Histogram.Clear()
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for i := range h.counts {
+		h.counts[i] = 0
+	}
+	h.sum = 0
+}
+
+// Count returns the count of all values
+func (h *bucketHistogram) Count() int64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	_, count := h.cumulativeCounts()
+	return count
+}
+
+// Sum returns the sum of all values, not only the sampled values
+func (h *bucketHistogram) Sum() int64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.sum
+}
+
+func (h *bucketHistogram) Update(v int64) {
+	h.Histogram.Update(v)
+	idx := sort.SearchFloat64s(h.bounds, float64(v))
+	h.mutex.Lock()
+	h.counts[idx]++
+	h.sum += v
+	h.mutex.Unlock()
+}
+
+func (h *bucketHistogram) Snapshot() gometrics.Histogram {
+	snapshot := &bucketHistogramSnapshot{
+		Histogram: h.Histogram.Snapshot(),
+		bounds:    h.bounds,
+	}
+	h.mutex.Lock()
+	snapshot.counts, snapshot.count = h.cumulativeCounts()
+	snapshot.sum = h.sum
+	h.mutex.Unlock()
+	return snapshot
+}
+
+// bucketHistogramSnapshot is a read-only copy of bucketHistogram
+type bucketHistogramSnapshot struct {
+	gometrics.Histogram
+	bounds []float64
+	counts []int64 // cumulative
+	count  int64
+	sum    int64
+}
+
+func (h *bucketHistogramSnapshot) Count() int64 {
+	return h.count
+}
+
+func (h *bucketHistogramSnapshot) Buckets() []float64 {
+	return h.bounds
+}
+
+func (h *bucketHistogramSnapshot) BucketCounts() []int64 {
+	return h.counts
+}
+
+func (h *bucketHistogramSnapshot) Sum() int64 {
+	return h.sum
+}
+
+func (h *bucketHistogramSnapshot) Snapshot() gometrics.Histogram {
+	return h
+}
@@ -0,0 +1,95 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"sync"
+	"testing"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketHistogram(t *testing.T) {
+	h := NewBucketHistogram(gometrics.NewUniformSample(100), []float64{10, 100, 1000})
+	for _, v := range []int64{1, 10, 50, 500, 5000} {
+		h.Update(v)
+	}
+	assert.Equal(t, []int64{2, 3, 4}, h.BucketCounts())
+	assert.Equal(t, int64(5561), h.Sum())
+	assert.Equal(t, int64(5), h.Count())
+
+	snapshot, ok := h.Snapshot().(BucketHistogram)
+	require.True(t, ok)
+	h.Update(1)
+	assert.Equal(t, []int64{2, 3, 4}, snapshot.BucketCounts())
+	assert.Equal(t, []float64{10, 100, 1000}, snapshot.Buckets())
+	assert.Equal(t, int64(5561), snapshot.Sum())
+	assert.Equal(t, int64(5), snapshot.Count())
+	assert.Equal(t, int64(5000), snapshot.Max())
+
+	h.Clear()
+	assert.Equal(t, []int64{0, 0, 0}, h.BucketCounts())
+	assert.Equal(t, int64(0), h.Sum())
+	assert.Equal(t, int64(0), h.Count())
+}
+
+func TestBucketHistogramSnapshotConsistent(t *testing.T) {
+	h := NewBucketHistogram(gometrics.NewUniformSample(100), []float64{10})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10000; i++ {
+			h.Update(1)
+		}
+	}()
+	// each value is in the bucket, the bucket count equals the count and the sum
+	for i := 0; i < 1000; i++ {
+		snapshot := h.Snapshot().(BucketHistogram)
+		count := snapshot.BucketCounts()[0]
+		require.Equal(t, count, snapshot.Count())
+		require.Equal(t, count, snapshot.Sum())
+	}
+	wg.Wait()
+	require.Equal(t, int64(10000), h.Count())
+}
+
+func TestSetHistogramBuckets(t *testing.T) {
+	ResetAll()
+	defer SetHistogramBuckets(nil, nil)
+
+	assert.NotNil(t, SetHistogramBuckets([]float64{10, 1}, nil))
+	assert.NotNil(t, SetHistogramBuckets(nil, map[string][]float64{"k": {1, 1}}))
+
+	require.Nil(t, SetHistogramBuckets([]float64{1, 10}, map[string][]float64{"special": {100}}))
+	stats, _ := NewMetrics("histogram", map[string]string{"hk": "bucket"})
+
+	h, ok := stats.Histogram("default").(BucketHistogram)
+	require.True(t, ok)
+	assert.Equal(t, []float64{1, 10}, h.Buckets())
+
+	h, ok = stats.Histogram("special").(BucketHistogram)
+	require.True(t, ok)
+	assert.Equal(t, []float64{100}, h.Buckets())
+
+	require.Nil(t, SetHistogramBuckets(nil, nil))
+	_, ok = stats.Histogram("no_buckets").(BucketHistogram)
+	assert.False(t, ok)
+}
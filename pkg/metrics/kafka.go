@@ -0,0 +1,57 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import "mosn.io/mosn/pkg/types"
+
+// KafkaType represents kafka proxy metrics type
+const KafkaType = "kafka"
+
+// metrics key in kafka proxy
+const (
+	KafkaRequestDecodeError  = "request_decode_error"
+	KafkaResponseDecodeError = "response_decode_error"
+	KafkaBrokerRewrite       = "broker_rewrite"
+)
+
+// metrics key in kafka proxy api and topic
+const (
+	KafkaRequestTotal  = "request_total"
+	KafkaRequestBytes  = "request_bytes"
+	KafkaResponseTotal = "response_total"
+	KafkaResponseBytes = "response_bytes"
+	KafkaRequestTime   = "request_time"
+)
+
+// NewKafkaStats returns a stats with namespace prefix kafka proxy
+func NewKafkaStats(statPrefix string) types.Metrics {
+	metrics, _ := NewMetrics(KafkaType, map[string]string{"kafka": statPrefix})
+	return metrics
+}
+
+// NewKafkaAPIStats returns a stats with namespace prefix kafka proxy and api
+func NewKafkaAPIStats(statPrefix, api string) types.Metrics {
+	metrics, _ := NewMetrics(KafkaType, map[string]string{"kafka": statPrefix, "api": api})
+	return metrics
+}
+
+// NewKafkaTopicStats returns a stats with namespace prefix kafka proxy, api and topic
+func NewKafkaTopicStats(statPrefix, api, topic string) types.Metrics {
+	metrics, _ := NewMetrics(KafkaType, map[string]string{"kafka": statPrefix, "api": api, "topic": topic})
+	return metrics
+}
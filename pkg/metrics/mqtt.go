@@ -0,0 +1,50 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import "mosn.io/mosn/pkg/types"
+
+// MqttType represents mqtt proxy metrics type
+const MqttType = "mqtt"
+
+// metrics key in mqtt proxy
+const (
+	MqttConnectTotal     = "connect_total"
+	MqttConnectRejected  = "connect_rejected"
+	MqttConnectionActive = "connection_active"
+	MqttPublishDenied    = "publish_denied"
+	MqttSubscribeDenied  = "subscribe_denied"
+	MqttProtocolError    = "protocol_error"
+	MqttPublishInTotal   = "publish_in_total"
+	MqttPublishInBytes   = "publish_in_bytes"
+	MqttPublishOutTotal  = "publish_out_total"
+	MqttPublishOutBytes  = "publish_out_bytes"
+	MqttSubscribeTotal   = "subscribe_total"
+)
+
+// NewMqttStats returns a stats with namespace prefix mqtt proxy
+func NewMqttStats(statPrefix string) types.Metrics {
+	metrics, _ := NewMetrics(MqttType, map[string]string{"mqtt": statPrefix})
+	return metrics
+}
+
+// NewMqttTopicStats returns a stats with namespace prefix mqtt proxy and topic
+func NewMqttTopicStats(statPrefix, topic string) types.Metrics {
+	metrics, _ := NewMetrics(MqttType, map[string]string{"mqtt": statPrefix, "topic": topic})
+	return metrics
+}
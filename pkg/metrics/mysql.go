@@ -0,0 +1,59 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import "mosn.io/mosn/pkg/types"
+
+// MysqlType represents mysql proxy metrics type
+const MysqlType = "mysql"
+
+// metrics key in mysql proxy
+const (
+	MysqlLoginSuccess    = "login_success"
+	MysqlLoginFailure    = "login_failure"
+	MysqlTLSPassthrough  = "tls_passthrough"
+	MysqlProtocolError   = "protocol_error"
+	MysqlSlowQuery       = "slow_query"
+	MysqlReadSplit       = "read_split"
+	MysqlReadSplitFailed = "read_split_failed"
+)
+
+// metrics key in mysql proxy query and table
+const (
+	MysqlQueryTotal = "query_total"
+	MysqlQueryError = "query_error"
+	MysqlQueryTime  = "query_time"
+)
+
+// NewMysqlStats returns a stats with namespace prefix mysql proxy
+func NewMysqlStats(statPrefix string) types.Metrics {
+	metrics, _ := NewMetrics(MysqlType, map[string]string{"mysql": statPrefix})
+	return metrics
+}
+
+// NewMysqlQueryStats returns a stats with namespace prefix mysql proxy and query type
+func NewMysqlQueryStats(statPrefix, queryType string) types.Metrics {
+	metrics, _ := NewMetrics(MysqlType, map[string]string{"mysql": statPrefix, "type": queryType})
+	return metrics
+}
+
+// NewMysqlTableStats returns a stats with namespace prefix mysql proxy, table and query type
+func NewMysqlTableStats(statPrefix, table, queryType string) types.Metrics {
+	metrics, _ := NewMetrics(MysqlType, map[string]string{"mysql": statPrefix, "table": table, "type": queryType})
+	return metrics
+}
@@ -130,6 +130,12 @@ func (psink *promSink) flushHistogram(tracker map[string]bool, buf types.IoBuffe
 	psink.flushGauge(tracker, buf, name+"_min", labels, float64(snapshot.Min()))
 	// max
 	psink.flushGauge(tracker, buf, name+"_max", labels, float64(snapshot.Max()))
+	// fixed buckets are exported as prometheus histogram, the percentiles can be
+	// calculated by histogram_quantile, so the percentiles gauge is ignored.
+	if bh, ok := snapshot.(metrics.BucketHistogram); ok {
+		psink.flushBuckets(tracker, buf, name, labels, bh)
+		return
+	}
 	// flush P90 P95 P99 percentiles
 	if len(psink.config.Percentiles) == 0 {
 		return
@@ -142,6 +148,55 @@ func (psink *promSink) flushHistogram(tracker map[string]bool, buf types.IoBuffe
 	}
 }
 
+func (psink *promSink) flushBuckets(tracker map[string]bool, buf types.IoBuffer, name string, labels string, snapshot metrics.BucketHistogram) {
+	// type
+	if !tracker[name] {
+		buf.WriteString("# TYPE ")
+		buf.WriteString(name)
+		buf.WriteString(" histogram\n")
+		tracker[name] = true
+	}
+	sep := ""
+	if labels != "" {
+		sep = ","
+	}
+	// the bucket counts, the count and the sum are read from the same snapshot, so +Inf and count are
+	// not less than the last bucket
+	counts := snapshot.BucketCounts()
+	count := snapshot.Count()
+	for i, bound := range snapshot.Buckets() {
+		buf.WriteString(name)
+		buf.WriteString("_bucket{")
+		buf.WriteString(labels)
+		buf.WriteString(sep)
+		buf.WriteString("le=\"")
+		writeFloat(buf, bound)
+		buf.WriteString("\"} ")
+		writeFloat(buf, float64(counts[i]))
+		buf.WriteString("\n")
+	}
+	buf.WriteString(name)
+	buf.WriteString("_bucket{")
+	buf.WriteString(labels)
+	buf.WriteString(sep)
+	buf.WriteString("le=\"+Inf\"} ")
+	writeFloat(buf, float64(count))
+	buf.WriteString("\n")
+	// sum and count
+	buf.WriteString(name)
+	buf.WriteString("_sum{")
+	buf.WriteString(labels)
+	buf.WriteString("} ")
+	writeFloat(buf, float64(snapshot.Sum()))
+	buf.WriteString("\n")
+	buf.WriteString(name)
+	buf.WriteString("_count{")
+	buf.WriteString(labels)
+	buf.WriteString("} ")
+	writeFloat(buf, float64(count))
+	buf.WriteString("\n")
+}
+
 func (psink *promSink) flushGauge(tracker map[string]bool, buf types.IoBuffer, name string, labels string, val float64) {
 	// type
 	if !tracker[name] {
@@ -27,6 +27,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"mosn.io/mosn/pkg/admin/store"
 	"mosn.io/mosn/pkg/metrics"
 	"mosn.io/mosn/pkg/metrics/sink"
@@ -485,3 +487,36 @@ func BenchmarkFlattenKey(b *testing.B) {
 		})
 	}
 }
+
+func TestPrometheusBucketHistogram(t *testing.T) {
+	metrics.ResetAll()
+	sink.SetFilterLabels(nil)
+	sink.SetFilterKeys(nil)
+	require.Nil(t, metrics.SetHistogramBuckets([]float64{1, 5, 10}, nil))
+	defer metrics.SetHistogramBuckets(nil, nil)
+
+	s, _ := metrics.NewMetrics("t1", map[string]string{"lbk1": "lbv1"})
+	for _, v := range []int64{1, 3, 7, 20} {
+		s.Histogram("k1").Update(v)
+	}
+
+	psink := &promSink{config: &promConfig{Percentiles: []int{50}, percentilesFloat: []float64{0.5}}}
+	buf := &bytes.Buffer{}
+	psink.Flush(buf, metrics.GetAll())
+	body := buf.String()
+
+	for _, expected := range []string{
+		"# TYPE t1_k1 histogram\n",
+		`t1_k1_bucket{lbk1="lbv1",le="1.0"} 1.0`,
+		`t1_k1_bucket{lbk1="lbv1",le="5.0"} 2.0`,
+		`t1_k1_bucket{lbk1="lbv1",le="10.0"} 3.0`,
+		`t1_k1_bucket{lbk1="lbv1",le="+Inf"} 4.0`,
+		`t1_k1_sum{lbk1="lbv1"} 31.0`,
+		`t1_k1_count{lbk1="lbv1"} 4.0`,
+		`t1_k1_min{lbk1="lbv1"} 1.0`,
+		`t1_k1_max{lbk1="lbv1"} 20.0`,
+	} {
+		assert.Contains(t, body, expected)
+	}
+	assert.NotContains(t, body, "percentile")
+}
@@ -0,0 +1,397 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package statsd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/metrics/sink"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/utils"
+)
+
+var (
+	sinkType             = "statsd"
+	defaultNetwork       = "udp"
+	defaultAddress       = "127.0.0.1:8125"
+	defaultFlushInterval = 10 * time.Second
+	// keeps a packet inside a common ethernet MTU after IP/UDP headers
+	defaultMaxPacketSize = 1432
+)
+
+const (
+	// TagFormatDogStatsD appends labels as DogStatsD tags: name:1|c|#k1:v1,k2:v2
+	TagFormatDogStatsD = "dogstatsd"
+	// TagFormatName embeds labels into the metric name: type.k1.v1.k2.v2.name:1|c
+	TagFormatName = "name"
+)
+
+func init() {
+	sink.RegisterSink(sinkType, builder)
+}
+
+// statsdConfig contains config for statsdSink
+type statsdConfig struct {
+	Network          string             `json:"network"` // udp, udp4, udp6 or unixgram
+	Address          string             `json:"address"`
+	Prefix           string             `json:"prefix"`
+	TagFormat        string             `json:"tag_format"`
+	FlushInterval    api.DurationConfig `json:"flush_interval"`
+	MaxPacketSize    int                `json:"max_packet_size"`
+	Percentiles      []int              `json:"percentiles,omitempty"`
+	percentilesFloat []float64          // not config, trans with Percentiles
+}
+
+// statsdSink pushes metrics to a StatsD compatible agent with specified interval
+type statsdSink struct {
+	config *statsdConfig
+
+	mutex sync.Mutex
+	// counters records the last flushed value of each counter,
+	// statsd counters are deltas while go-metrics counters are cumulative.
+	counters map[string]int64
+
+	conn net.Conn
+}
+
+// counterValue is a counter value waiting for the packet to be written
+type counterValue struct {
+	key   string
+	count int64
+}
+
+// packetWriter records the first error returned by the underlying writer
+type packetWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (pw *packetWriter) Write(p []byte) (int, error) {
+	if pw.err != nil {
+		return 0, pw.err
+	}
+	n, err := pw.w.Write(p)
+	if err != nil {
+		pw.err = err
+	}
+	return n, err
+}
+
+// ~ MetricsSink
+// Flush writes the metrics in statsd line protocol, each Write call on writer carries
+// one packet that is no larger than max_packet_size unless a single line exceeds it.
+func (ssink *statsdSink) Flush(writer io.Writer, ms []types.Metrics) {
+	ssink.mutex.Lock()
+	defer ssink.mutex.Unlock()
+
+	packet := buffer.GetIoBuffer(ssink.config.MaxPacketSize)
+	defer buffer.PutIoBuffer(packet)
+	line := buffer.GetIoBuffer(128)
+	defer buffer.PutIoBuffer(line)
+
+	// the counters in the packet are recorded only if the packet is written,
+	// so the deltas are sent in the next flush if the write is failed.
+	var pending []counterValue
+	commit := func() {
+		for _, c := range pending {
+			ssink.counters[c.key] = c.count
+		}
+	}
+	write := func() {
+		if _, err := writer.Write(packet.Bytes()); err == nil {
+			commit()
+		}
+		pending = pending[:0]
+		packet.Reset()
+	}
+	emit := func() {
+		if line.Len() == 0 {
+			return
+		}
+		if packet.Len() > 0 && packet.Len()+1+line.Len() > ssink.config.MaxPacketSize {
+			write()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.Write(line.Bytes())
+		line.Reset()
+	}
+
+	for _, m := range ms {
+		labelKeys, labelVals := m.SortedLabels()
+		if sink.IsExclusionLabels(labelKeys) {
+			continue
+		}
+		typ := m.Type()
+		tags := ssink.makeTags(labelKeys, labelVals)
+		namespace := ssink.makeNamespace(typ, labelKeys, labelVals)
+
+		m.Each(func(name string, i interface{}) {
+			if sink.IsExclusionKeys(name) {
+				return
+			}
+			key := namespace + sanitize(name)
+			switch metric := i.(type) {
+			case gometrics.Counter:
+				count := metric.Count()
+				ssink.writeCounter(line, key, tags, count)
+				emit()
+				pending = append(pending, counterValue{key: key + tags, count: count})
+			case gometrics.Gauge:
+				ssink.writeGauge(line, key, tags, metric.Value(), emit)
+			case gometrics.Histogram:
+				ssink.writeHistogram(line, key, tags, metric.Snapshot(), emit)
+			}
+		})
+	}
+	if packet.Len() > 0 {
+		write()
+	} else {
+		// nothing is written for the counters not changed
+		commit()
+	}
+}
+
+// writeCounter writes the delta since the last flushed value
+func (ssink *statsdSink) writeCounter(line types.IoBuffer, key, tags string, count int64) {
+	delta := count - ssink.counters[key+tags]
+	// counter has been reset or re-registered
+	if delta < 0 {
+		delta = count
+	}
+	if delta == 0 {
+		return
+	}
+	writeLine(line, key, strconv.FormatInt(delta, 10), "c", tags)
+}
+
+func (ssink *statsdSink) writeGauge(line types.IoBuffer, key, tags string, value int64, emit func()) {
+	// a signed gauge value is treated as a delta by statsd, so negative values
+	// must be preceded by a reset to zero.
+	if value < 0 {
+		writeLine(line, key, "0", "g", tags)
+		emit()
+	}
+	writeLine(line, key, strconv.FormatInt(value, 10), "g", tags)
+	emit()
+}
+
+func (ssink *statsdSink) writeHistogram(line types.IoBuffer, key, tags string, snapshot gometrics.Histogram, emit func()) {
+	ssink.writeGauge(line, key+"_min", tags, snapshot.Min(), emit)
+	ssink.writeGauge(line, key+"_max", tags, snapshot.Max(), emit)
+	if len(ssink.config.Percentiles) == 0 {
+		return
+	}
+	ps := snapshot.Percentiles(ssink.config.percentilesFloat)
+	if len(ps) == len(ssink.config.Percentiles) {
+		for i, p := range ssink.config.Percentiles {
+			writeLine(line, key+"_P"+strconv.Itoa(p), strconv.FormatFloat(ps[i], 'f', -1, 64), "g", tags)
+			emit()
+		}
+	}
+}
+
+// makeNamespace returns the metric name prefix, ends with a dot
+func (ssink *statsdSink) makeNamespace(typ string, keys, values []string) string {
+	var sb strings.Builder
+	if ssink.config.Prefix != "" {
+		sb.WriteString(ssink.config.Prefix)
+		sb.WriteByte('.')
+	}
+	sb.WriteString(sanitize(typ))
+	sb.WriteByte('.')
+	if ssink.config.TagFormat == TagFormatName {
+		for i := range keys {
+			sb.WriteString(sanitize(keys[i]))
+			sb.WriteByte('.')
+			sb.WriteString(sanitize(values[i]))
+			sb.WriteByte('.')
+		}
+	}
+	return sb.String()
+}
+
+// input: keys=[cluster,host] values=[app1,server2]
+// output: |#cluster:app1,host:server2
+func (ssink *statsdSink) makeTags(keys, values []string) string {
+	if ssink.config.TagFormat != TagFormatDogStatsD || len(keys) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("|#")
+	for i := range keys {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(sanitizeTag(keys[i]))
+		sb.WriteByte(':')
+		sb.WriteString(sanitizeTag(values[i]))
+	}
+	return sb.String()
+}
+
+func writeLine(line types.IoBuffer, key, value, typ, tags string) {
+	line.WriteString(key)
+	line.WriteByte(':')
+	line.WriteString(value)
+	line.WriteByte('|')
+	line.WriteString(typ)
+	line.WriteString(tags)
+}
+
+// sanitize replaces the characters that are reserved by statsd line protocol
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', '@', '#', ',', ' ', '\n':
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// sanitizeTag keeps ':' inside tag values as DogStatsD splits on the first one only
+func sanitizeTag(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '|', '@', '#', ',', ' ', '\n':
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// flush sends all metrics to the statsd agent, the connection is re-established
+// at the next flush if any write fails.
+func (ssink *statsdSink) flush() {
+	if ssink.conn == nil {
+		conn, err := net.Dial(ssink.config.Network, ssink.config.Address)
+		if err != nil {
+			log.DefaultLogger.Errorf("[metrics] [statsd] dial %s %s failed: %v", ssink.config.Network, ssink.config.Address, err)
+			return
+		}
+		ssink.conn = conn
+	}
+	pw := &packetWriter{w: ssink.conn}
+	ssink.Flush(pw, metrics.GetAll())
+	if pw.err != nil {
+		log.DefaultLogger.Errorf("[metrics] [statsd] flush metrics to %s failed: %v", ssink.config.Address, pw.err)
+		ssink.conn.Close()
+		ssink.conn = nil
+	}
+}
+
+func (ssink *statsdSink) run() {
+	ticker := time.NewTicker(ssink.config.FlushInterval.Duration)
+	defer ticker.Stop()
+	for range ticker.C {
+		ssink.flush()
+	}
+}
+
+// NewStatsdSink returns a metrics sink that pushes metrics to a statsd agent periodically
+func NewStatsdSink(config *statsdConfig) types.MetricsSink {
+	ssink := newStatsdSink(config)
+	utils.GoWithRecover(ssink.run, nil)
+	return ssink
+}
+
+func newStatsdSink(config *statsdConfig) *statsdSink {
+	return &statsdSink{
+		config:   config,
+		counters: make(map[string]int64),
+	}
+}
+
+// factory
+func builder(cfg map[string]interface{}) (types.MetricsSink, error) {
+	statsdCfg, err := parseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewStatsdSink(statsdCfg), nil
+}
+
+func parseConfig(cfg map[string]interface{}) (*statsdConfig, error) {
+	statsdCfg := &statsdConfig{}
+
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("parsing statsd sink error, err: %v, cfg: %v", err, cfg)
+	}
+	if err := json.Unmarshal(data, statsdCfg); err != nil {
+		return nil, fmt.Errorf("parsing statsd sink error, err: %v, cfg: %v", err, cfg)
+	}
+
+	switch statsdCfg.Network {
+	case "":
+		statsdCfg.Network = defaultNetwork
+	case "udp", "udp4", "udp6", "unixgram":
+	default:
+		return nil, fmt.Errorf("unsupported statsd network: %s", statsdCfg.Network)
+	}
+
+	if statsdCfg.Address == "" {
+		if statsdCfg.Network == "unixgram" {
+			return nil, fmt.Errorf("statsd sink's address is not specified")
+		}
+		statsdCfg.Address = defaultAddress
+	}
+
+	switch statsdCfg.TagFormat {
+	case "":
+		statsdCfg.TagFormat = TagFormatDogStatsD
+	case TagFormatDogStatsD, TagFormatName:
+	default:
+		return nil, fmt.Errorf("unsupported statsd tag format: %s", statsdCfg.TagFormat)
+	}
+
+	if statsdCfg.FlushInterval.Duration <= 0 {
+		statsdCfg.FlushInterval.Duration = defaultFlushInterval
+	}
+
+	if statsdCfg.MaxPacketSize <= 0 {
+		statsdCfg.MaxPacketSize = defaultMaxPacketSize
+	}
+
+	if len(statsdCfg.Percentiles) > 0 {
+		percentilesFloat := make([]float64, 0, len(statsdCfg.Percentiles))
+		for _, p := range statsdCfg.Percentiles {
+			if p < 0 || p > 100 {
+				return nil, fmt.Errorf("percentile {%d} must be between 0 and 100", p)
+			}
+			percentilesFloat = append(percentilesFloat, float64(p)/100)
+		}
+		statsdCfg.percentilesFloat = percentilesFloat
+	}
+
+	return statsdCfg, nil
+}
@@ -0,0 +1,235 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package statsd
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/metrics/sink"
+	"mosn.io/mosn/pkg/types"
+)
+
+// packetRecorder records every Write as one packet
+type packetRecorder struct {
+	packets []string
+	err     error
+}
+
+func (r *packetRecorder) Write(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	r.packets = append(r.packets, string(p))
+	return len(p), nil
+}
+
+func (r *packetRecorder) lines() []string {
+	var lines []string
+	for _, p := range r.packets {
+		lines = append(lines, strings.Split(p, "\n")...)
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+func newTestSink(t *testing.T, cfg map[string]interface{}) *statsdSink {
+	config, err := parseConfig(cfg)
+	require.Nil(t, err)
+	return newStatsdSink(config)
+}
+
+func TestParseConfig(t *testing.T) {
+	config, err := parseConfig(map[string]interface{}{})
+	require.Nil(t, err)
+	assert.Equal(t, "udp", config.Network)
+	assert.Equal(t, defaultAddress, config.Address)
+	assert.Equal(t, TagFormatDogStatsD, config.TagFormat)
+	assert.Equal(t, defaultFlushInterval, config.FlushInterval.Duration)
+	assert.Equal(t, defaultMaxPacketSize, config.MaxPacketSize)
+
+	config, err = parseConfig(map[string]interface{}{
+		"network":         "unixgram",
+		"address":         "/tmp/statsd.sock",
+		"tag_format":      "name",
+		"flush_interval":  "1s",
+		"max_packet_size": 512,
+		"percentiles":     []int{50, 99},
+	})
+	require.Nil(t, err)
+	assert.Equal(t, time.Second, config.FlushInterval.Duration)
+	assert.Equal(t, 512, config.MaxPacketSize)
+	assert.Equal(t, []float64{0.5, 0.99}, config.percentilesFloat)
+
+	for _, cfg := range []map[string]interface{}{
+		{"network": "tcp"},
+		{"network": "unixgram"},
+		{"tag_format": "influx"},
+		{"percentiles": []int{101}},
+		{"percentiles": []int{-1}},
+	} {
+		_, err := parseConfig(cfg)
+		assert.NotNil(t, err, "config %v should be invalid", cfg)
+	}
+}
+
+func TestStatsdDogStatsDFormat(t *testing.T) {
+	metrics.ResetAll()
+	defer metrics.ResetAll()
+
+	m, _ := metrics.NewMetrics("downstream", map[string]string{"listener": "egress", "proxy": "global"})
+	m.Counter("request_total").Inc(3)
+	m.Gauge("active").Update(-2)
+	m.Histogram("duration").Update(10)
+
+	ssink := newTestSink(t, map[string]interface{}{"prefix": "mosn"})
+	rec := &packetRecorder{}
+	ssink.Flush(rec, metrics.GetAll())
+	assert.Equal(t, []string{
+		"mosn.downstream.active:-2|g|#listener:egress,proxy:global",
+		"mosn.downstream.active:0|g|#listener:egress,proxy:global",
+		"mosn.downstream.duration_max:10|g|#listener:egress,proxy:global",
+		"mosn.downstream.duration_min:10|g|#listener:egress,proxy:global",
+		"mosn.downstream.request_total:3|c|#listener:egress,proxy:global",
+	}, rec.lines())
+
+	// counters are reported as deltas
+	m.Counter("request_total").Inc(2)
+	rec = &packetRecorder{}
+	ssink.Flush(rec, metrics.GetAll())
+	assert.Contains(t, rec.lines(), "mosn.downstream.request_total:2|c|#listener:egress,proxy:global")
+	rec = &packetRecorder{}
+	ssink.Flush(rec, metrics.GetAll())
+	for _, l := range rec.lines() {
+		assert.False(t, strings.Contains(l, "request_total"), "unchanged counter should not be flushed: %s", l)
+	}
+}
+
+func TestStatsdNameFormat(t *testing.T) {
+	metrics.ResetAll()
+	defer metrics.ResetAll()
+
+	m, _ := metrics.NewMetrics("upstream", map[string]string{"cluster": "a:b"})
+	m.Counter("request_total").Inc(1)
+	m.Histogram("duration").Update(4)
+
+	ssink := newTestSink(t, map[string]interface{}{
+		"tag_format":  "name",
+		"percentiles": []int{50},
+	})
+	rec := &packetRecorder{}
+	ssink.Flush(rec, metrics.GetAll())
+	assert.Equal(t, []string{
+		"upstream.cluster.a_b.duration_P50:4|g",
+		"upstream.cluster.a_b.duration_max:4|g",
+		"upstream.cluster.a_b.duration_min:4|g",
+		"upstream.cluster.a_b.request_total:1|c",
+	}, rec.lines())
+}
+
+func TestStatsdCounterWriteFailed(t *testing.T) {
+	metrics.ResetAll()
+	defer metrics.ResetAll()
+
+	m, _ := metrics.NewMetrics("downstream", map[string]string{"proxy": "global"})
+	m.Counter("request_total").Inc(2)
+
+	ssink := newTestSink(t, map[string]interface{}{"tag_format": "name"})
+	// the delta is kept if the packet is not written
+	ssink.Flush(&packetRecorder{err: errors.New("write failed")}, metrics.GetAll())
+	m.Counter("request_total").Inc(1)
+	rec := &packetRecorder{}
+	ssink.Flush(rec, metrics.GetAll())
+	assert.Equal(t, []string{"downstream.proxy.global.request_total:3|c"}, rec.lines())
+	// no delta after the packet is written
+	rec = &packetRecorder{}
+	ssink.Flush(rec, metrics.GetAll())
+	assert.Empty(t, rec.packets)
+}
+
+func TestStatsdPacketSize(t *testing.T) {
+	metrics.ResetAll()
+	defer metrics.ResetAll()
+
+	m, _ := metrics.NewMetrics("downstream", map[string]string{"proxy": "global"})
+	for _, k := range []string{"k1", "k2", "k3", "k4", "k5"} {
+		m.Gauge(k).Update(1)
+	}
+
+	ssink := newTestSink(t, map[string]interface{}{"max_packet_size": 64})
+	rec := &packetRecorder{}
+	ssink.Flush(rec, metrics.GetAll())
+	assert.True(t, len(rec.packets) > 1)
+	for _, p := range rec.packets {
+		assert.True(t, len(p) <= 64, "packet too large: %q", p)
+	}
+	assert.Len(t, rec.lines(), 5)
+}
+
+func TestStatsdExclusion(t *testing.T) {
+	metrics.ResetAll()
+	defer func() {
+		metrics.ResetAll()
+		sink.SetFilterLabels(nil)
+		sink.SetFilterKeys(nil)
+	}()
+
+	m1, _ := metrics.NewMetrics("downstream", map[string]string{"proxy": "global"})
+	m1.Counter("request_total").Inc(1)
+	m1.Counter("excluded").Inc(1)
+	m2, _ := metrics.NewMetrics("upstream", map[string]string{"cluster": "c1"})
+	m2.Counter("request_total").Inc(1)
+
+	sink.SetFilterLabels([]string{"cluster"})
+	sink.SetFilterKeys([]string{"excluded"})
+
+	ssink := newTestSink(t, map[string]interface{}{})
+	rec := &packetRecorder{}
+	ssink.Flush(rec, metrics.GetAll())
+	assert.Equal(t, []string{"downstream.request_total:1|c|#proxy:global"}, rec.lines())
+}
+
+func TestStatsdUDP(t *testing.T) {
+	metrics.ResetAll()
+	defer metrics.ResetAll()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer conn.Close()
+
+	m, _ := metrics.NewMetrics("downstream", map[string]string{"proxy": "global"})
+	m.Counter("request_total").Inc(1)
+
+	ssink := newTestSink(t, map[string]interface{}{"address": conn.LocalAddr().String()})
+	ssink.flush()
+
+	buf := make([]byte, defaultMaxPacketSize)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	require.Nil(t, err)
+	assert.Equal(t, "downstream.request_total:1|c|#proxy:global", string(buf[:n]))
+}
+
+var _ types.MetricsSink = &statsdSink{}
@@ -115,6 +115,19 @@ func NewMetrics(typ string, labels map[string]string) (types.Metrics, error) {
 	return stats, nil
 }
 
+// DeleteMetrics unregisters the metrics of the type and labels and removes it from the store,
+// the metrics is not exported anymore
+func DeleteMetrics(typ string, labels map[string]string) {
+	defaultStore.mutex.Lock()
+	defer defaultStore.mutex.Unlock()
+
+	name, _, _ := fullName(typ, labels)
+	if m, ok := defaultStore.metrics[name]; ok {
+		m.UnregisterAll()
+		delete(defaultStore.metrics, name)
+	}
+}
+
 func sortedLabels(labels map[string]string) (keys, values []string) {
 	keys = make([]string, 0, len(labels))
 	values = make([]string, 0, len(labels))
@@ -189,6 +202,9 @@ func (s *metrics) Histogram(key string) gometrics.Histogram {
 
 	construct := func() gometrics.Histogram {
 		return s.registry.GetOrRegister(key, func() gometrics.Histogram {
+			if buckets := getHistogramBuckets(key); len(buckets) > 0 {
+				return NewBucketHistogram(sampleFactory(), buckets)
+			}
 			return gometrics.NewHistogram(sampleFactory())
 		}).(gometrics.Histogram)
 	}
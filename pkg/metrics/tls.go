@@ -24,7 +24,10 @@ const TLSType = "mosn_tls"
 
 // tls metrics key
 const (
-	TLSConnpoolChanged = "connpool_changed"
+	TLSConnpoolChanged   = "connpool_changed"
+	TLSCertReloadSuccess = "cert_reload_success"
+	TLSCertReloadFailed  = "cert_reload_failed"
+	TLSCertExpirySeconds = "cert_expiry_seconds"
 )
 
 // NewTLSStats returns a TLSMetrics named ${name}
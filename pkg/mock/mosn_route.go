@@ -37,6 +37,20 @@ func (m *MockRouters) EXPECT() *MockRoutersMockRecorder {
 	return m.recorder
 }
 
+// AddOrUpdateVirtualHost mocks base method.
+func (m *MockRouterManager) AddOrUpdateVirtualHost(routerConfigName string, virtualHost *v2.VirtualHost) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddOrUpdateVirtualHost", routerConfigName, virtualHost)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddOrUpdateVirtualHost indicates an expected call of AddOrUpdateVirtualHost.
+func (mr *MockRouterManagerMockRecorder) AddOrUpdateVirtualHost(routerConfigName, virtualHost interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddOrUpdateVirtualHost", reflect.TypeOf((*MockRouterManager)(nil).AddOrUpdateVirtualHost), routerConfigName, virtualHost)
+}
+
 // AddRoute mocks base method.
 func (m *MockRouters) AddRoute(domain string, route *v2.Router) int {
 	m.ctrl.T.Helper()
@@ -186,6 +200,48 @@ func (mr *MockRouterManagerMockRecorder) RemoveAllRoutes(routerConfigName, domai
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveAllRoutes", reflect.TypeOf((*MockRouterManager)(nil).RemoveAllRoutes), routerConfigName, domain)
 }
 
+// RemoveRoute mocks base method.
+func (m *MockRouterManager) RemoveRoute(routerConfigName, virtualHostName, routeName string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveRoute", routerConfigName, virtualHostName, routeName)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveRoute indicates an expected call of RemoveRoute.
+func (mr *MockRouterManagerMockRecorder) RemoveRoute(routerConfigName, virtualHostName, routeName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveRoute", reflect.TypeOf((*MockRouterManager)(nil).RemoveRoute), routerConfigName, virtualHostName, routeName)
+}
+
+// UpdateRoute mocks base method.
+func (m *MockRouterManager) UpdateRoute(routerConfigName, virtualHostName string, route *v2.Router) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateRoute", routerConfigName, virtualHostName, route)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateRoute indicates an expected call of UpdateRoute.
+func (mr *MockRouterManagerMockRecorder) UpdateRoute(routerConfigName, virtualHostName, route interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRoute", reflect.TypeOf((*MockRouterManager)(nil).UpdateRoute), routerConfigName, virtualHostName, route)
+}
+
+// RemoveVirtualHost mocks base method.
+func (m *MockRouterManager) RemoveVirtualHost(routerConfigName, virtualHostName string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveVirtualHost", routerConfigName, virtualHostName)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveVirtualHost indicates an expected call of RemoveVirtualHost.
+func (mr *MockRouterManagerMockRecorder) RemoveVirtualHost(routerConfigName, virtualHostName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveVirtualHost", reflect.TypeOf((*MockRouterManager)(nil).RemoveVirtualHost), routerConfigName, virtualHostName)
+}
+
 // MockRouteHandler is a mock of RouteHandler interface.
 type MockRouteHandler struct {
 	ctrl     *gomock.Controller
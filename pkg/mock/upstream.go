@@ -124,6 +124,21 @@ func (mr *MockClusterManagerMockRecorder) ConnPoolForCluster(balancerContext, sn
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConnPoolForCluster", reflect.TypeOf((*MockClusterManager)(nil).ConnPoolForCluster), balancerContext, snapshot, protocol)
 }
 
+// ConnPoolForHost mocks base method.
+func (m *MockClusterManager) ConnPoolForHost(ctx context.Context, snapshot types.ClusterSnapshot, host types.Host, protocol api.ProtocolName) (types.ConnectionPool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConnPoolForHost", ctx, snapshot, host, protocol)
+	ret0, _ := ret[0].(types.ConnectionPool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConnPoolForHost indicates an expected call of ConnPoolForHost.
+func (mr *MockClusterManagerMockRecorder) ConnPoolForHost(ctx, snapshot, host, protocol interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConnPoolForHost", reflect.TypeOf((*MockClusterManager)(nil).ConnPoolForHost), ctx, snapshot, host, protocol)
+}
+
 // Destroy mocks base method.
 func (m *MockClusterManager) Destroy() {
 	m.ctrl.T.Helper()
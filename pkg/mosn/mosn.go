@@ -196,6 +196,13 @@ func (m *Mosn) initializeMetrics() {
 		metrics.SetExpDecayAlpha(config.SampleConfig.ExpDecayAlpha)
 	}
 
+	// set histogram buckets
+	if config.Histogram != nil {
+		if err := metrics.SetHistogramBuckets(config.Histogram.Buckets, config.Histogram.KeyBuckets); err != nil {
+			log.StartLogger.Errorf("[mosn] [init metrics] set histogram buckets failed: %v", err)
+		}
+	}
+
 	// create sinks
 	for _, cfg := range config.SinkConfigs {
 		_, err := sink.CreateMetricsSink(cfg.Type, cfg.Config)
@@ -62,42 +62,48 @@ func (c *TLSConn) Read(b []byte) (int, error) {
 // It implements the net.Conn interface.
 type Conn struct {
 	net.Conn
-	peek    [1]byte
-	haspeek bool
+	peeked []byte
 }
 
 // Peek returns 1 byte from connection, without draining any buffered data.
 func (c *Conn) Peek() ([]byte, error) {
-	b := make([]byte, 1, 1)
+	if len(c.peeked) > 0 {
+		return c.peeked[:1], nil
+	}
 	c.Conn.SetReadDeadline(time.Now().Add(types.DefaultIdleTimeout))
-	_, err := c.Conn.Read(b)
-	c.Conn.SetReadDeadline(time.Time{}) // clear read deadline
-	if err != nil {
-		if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
-			log.DefaultLogger.Debugf("[mtls] TLS Peek() error: %v, local address: %v, remote address: %v", err, c.Conn.LocalAddr(), c.Conn.RemoteAddr())
+	defer c.Conn.SetReadDeadline(time.Time{}) // clear read deadline
+	return c.PeekN(1)
+}
+
+// PeekN returns n bytes from connection, without draining any buffered data.
+// The peeked bytes will be returned by the following Read calls.
+// The read deadline should be set by the caller.
+func (c *Conn) PeekN(n int) ([]byte, error) {
+	for len(c.peeked) < n {
+		b := make([]byte, n-len(c.peeked))
+		m, err := c.Conn.Read(b)
+		c.peeked = append(c.peeked, b[:m]...)
+		if err != nil {
+			if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+				log.DefaultLogger.Debugf("[mtls] TLS Peek() error: %v, local address: %v, remote address: %v", err, c.Conn.LocalAddr(), c.Conn.RemoteAddr())
+			}
+			return nil, err
 		}
-		return nil, err
 	}
-	c.peek[0] = b[0]
-	c.haspeek = true
-	return b, nil
+	return c.peeked[:n], nil
 }
 
 // Read reads data from the connection.
 func (c *Conn) Read(b []byte) (int, error) {
-	peek := 0
-	if c.haspeek {
-		c.haspeek = false
-		b[0] = c.peek[0]
-		if len(b) == 1 {
-			return 1, nil
+	if len(c.peeked) > 0 {
+		n := copy(b, c.peeked)
+		c.peeked = c.peeked[n:]
+		if len(c.peeked) == 0 {
+			c.peeked = nil
 		}
-		peek = 1
-		b = b[peek:]
+		return n, nil
 	}
-
-	n, err := c.Conn.Read(b)
-	return n + peek, err
+	return c.Conn.Read(b)
 }
 
 // ConnectionState records basic TLS details about the connection.
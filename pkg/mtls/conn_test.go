@@ -0,0 +1,51 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mtls
+
+import (
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnPeek(t *testing.T) {
+	client, server := net.Pipe()
+	go func() {
+		client.Write([]byte("hello"))
+		client.Write([]byte(" world"))
+		client.Close()
+	}()
+	conn := &Conn{Conn: server}
+	b, err := conn.Peek()
+	require.Nil(t, err)
+	assert.Equal(t, "h", string(b))
+	b, err = conn.PeekN(8)
+	require.Nil(t, err)
+	assert.Equal(t, "hello wo", string(b))
+	// peek again returns the buffered data
+	b, err = conn.PeekN(2)
+	require.Nil(t, err)
+	assert.Equal(t, "he", string(b))
+	// read returns the peeked data first
+	data, err := ioutil.ReadAll(conn)
+	require.Nil(t, err)
+	assert.Equal(t, "hello world", string(data))
+}
@@ -0,0 +1,216 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mtls
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/mtls/crypto/tls"
+)
+
+var (
+	ErrorCertificateRevoked = errors.New("tls: certificate is revoked")
+	ErrorCRLExpired         = errors.New("tls: certificate revocation list is expired")
+	ErrorCRLIssuerNotFound  = errors.New("tls: issuer of certificate revocation list is not found")
+)
+
+type crlEntry struct {
+	list    *pkix.CertificateList
+	issuer  string
+	revoked map[string]struct{}
+}
+
+// expired returns true if the list is not updated before the next update time
+func (e *crlEntry) expired(now time.Time) bool {
+	next := e.list.TBSCertList.NextUpdate
+	return !next.IsZero() && now.After(next)
+}
+
+// revocationChecker checks the peer certificate chain by the certificate revocation lists
+type revocationChecker struct {
+	crls []*crlEntry
+	// cas is used to find the issuer that not sent by the peer
+	cas []*x509.Certificate
+}
+
+func newRevocationChecker(cfg *v2.TLSConfig, secret *SecretInfo) (*revocationChecker, error) {
+	if len(cfg.CRLFiles) == 0 {
+		return nil, nil
+	}
+	c := &revocationChecker{}
+	for _, file := range cfg.CRLFiles {
+		lists, err := loadCRLs(file)
+		if err != nil {
+			return nil, fmt.Errorf("load crl %s error: %v", file, err)
+		}
+		for _, list := range lists {
+			entry := &crlEntry{
+				list:    list,
+				issuer:  list.TBSCertList.Issuer.String(),
+				revoked: make(map[string]struct{}, len(list.TBSCertList.RevokedCertificates)),
+			}
+			for _, rc := range list.TBSCertList.RevokedCertificates {
+				entry.revoked[rc.SerialNumber.String()] = struct{}{}
+			}
+			if entry.expired(time.Now()) {
+				log.DefaultLogger.Alertf("tls.crl", "[mtls] crl %s issued by %s is expired at %s", file, entry.issuer, list.TBSCertList.NextUpdate)
+			}
+			c.crls = append(c.crls, entry)
+		}
+	}
+	cas := []string{secret.Validation}
+	for _, td := range cfg.TrustDomains {
+		cas = append(cas, td.CACert)
+	}
+	for _, ca := range cas {
+		if ca == "" {
+			continue
+		}
+		certs, err := loadCertificates(ca)
+		if err != nil {
+			return nil, fmt.Errorf("load ca certificate error: %v", err)
+		}
+		c.cas = append(c.cas, certs...)
+	}
+	return c, nil
+}
+
+// loadCRLs loads the revocation lists from a PEM or DER file
+func loadCRLs(file string) ([]*pkix.CertificateList, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var lists []*pkix.CertificateList
+	data := b
+	for len(data) > 0 {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "X509 CRL" {
+			continue
+		}
+		list, err := x509.ParseDERCRL(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, list)
+	}
+	if len(lists) > 0 {
+		return lists, nil
+	}
+	list, err := x509.ParseDERCRL(b)
+	if err != nil {
+		return nil, err
+	}
+	return []*pkix.CertificateList{list}, nil
+}
+
+// HandshakeVerify checks the peer certificate chain after the next verify function.
+// the verified chains are checked if the certificate is verified by the tls, otherwise the raw certificates are checked.
+func (c *revocationChecker) HandshakeVerify(next func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error) func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if next != nil {
+			if err := next(rawCerts, verifiedChains); err != nil {
+				return err
+			}
+		}
+		if len(verifiedChains) > 0 {
+			for _, chain := range verifiedChains {
+				if err := c.check(chain); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		chain := make([]*x509.Certificate, 0, len(rawCerts))
+		for _, asn1Data := range rawCerts {
+			cert, err := tls.LoadOrStoreCertificate(asn1Data)
+			if err != nil {
+				return err
+			}
+			chain = append(chain, cert)
+		}
+		return c.check(chain)
+	}
+}
+
+func (c *revocationChecker) check(chain []*x509.Certificate) error {
+	for i, cert := range chain {
+		var issuer *x509.Certificate
+		if i+1 < len(chain) {
+			issuer = chain[i+1]
+		} else {
+			issuer = c.findIssuer(cert)
+		}
+		if err := c.checkCertificate(cert, issuer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkCertificate checks the certificate by the lists issued by the issuer,
+// the list is ignored if the signature is not signed by the issuer.
+// the certificate is rejected if the list is expired, or the issuer is not found
+// so the signature of the list cannot be checked.
+func (c *revocationChecker) checkCertificate(cert, issuer *x509.Certificate) error {
+	name := cert.Issuer.ToRDNSequence().String()
+	serial := cert.SerialNumber.String()
+	now := time.Now()
+	for _, entry := range c.crls {
+		if entry.issuer != name {
+			continue
+		}
+		if issuer == nil {
+			return fmt.Errorf("%w: issuer %s, subject %s", ErrorCRLIssuerNotFound, name, cert.Subject.String())
+		}
+		_, revoked := entry.revoked[serial]
+		expired := entry.expired(now)
+		if !revoked && !expired {
+			continue
+		}
+		if err := issuer.CheckCRLSignature(entry.list); err != nil {
+			continue
+		}
+		if revoked {
+			return fmt.Errorf("%w: serial number %s, subject %s", ErrorCertificateRevoked, serial, cert.Subject.String())
+		}
+		return fmt.Errorf("%w: issuer %s, next update %s", ErrorCRLExpired, name, entry.list.TBSCertList.NextUpdate)
+	}
+	return nil
+}
+
+func (c *revocationChecker) findIssuer(cert *x509.Certificate) *x509.Certificate {
+	for _, ca := range c.cas {
+		if cert.CheckSignatureFrom(ca) == nil {
+			return ca
+		}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mtls
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/mtls/certtool"
+)
+
+// createCRL returns a DER encoded crl that revokes the certificates
+func (ca *testAuthority) createCRL(t *testing.T, number int64, certs ...*certtool.CertificateInfo) []byte {
+	return ca.createCRLUntil(t, number, time.Now().Add(time.Hour), certs...)
+}
+
+// createCRLUntil returns a DER encoded crl with the next update time
+func (ca *testAuthority) createCRLUntil(t *testing.T, number int64, nextUpdate time.Time, certs ...*certtool.CertificateInfo) []byte {
+	tmpl := &x509.RevocationList{
+		Number:     big.NewInt(number),
+		ThisUpdate: nextUpdate.Add(-2 * time.Hour),
+		NextUpdate: nextUpdate,
+	}
+	for _, c := range certs {
+		tmpl.RevokedCertificates = append(tmpl.RevokedCertificates, pkix.RevokedCertificate{
+			SerialNumber:   parseCertPem(t, c.CertPem).SerialNumber,
+			RevocationTime: time.Now(),
+		})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, tmpl, ca.cert, ca.priv.(crypto.Signer))
+	require.Nil(t, err)
+	return der
+}
+
+func TestLoadCRLs(t *testing.T) {
+	ca := newTestAuthority(t)
+	dir := t.TempDir()
+	der := ca.createCRL(t, 1)
+	derFile := filepath.Join(dir, "crl.der")
+	require.Nil(t, ioutil.WriteFile(derFile, der, 0644))
+	lists, err := loadCRLs(derFile)
+	require.Nil(t, err)
+	require.Len(t, lists, 1)
+	// multiple lists in one pem file
+	pemFile := filepath.Join(dir, "crl.pem")
+	data := append(pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: ca.createCRL(t, 2)})...)
+	require.Nil(t, ioutil.WriteFile(pemFile, data, 0644))
+	lists, err = loadCRLs(pemFile)
+	require.Nil(t, err)
+	require.Len(t, lists, 2)
+	// invalid file
+	invalidFile := filepath.Join(dir, "invalid")
+	require.Nil(t, ioutil.WriteFile(invalidFile, []byte("invalid"), 0644))
+	_, err = loadCRLs(invalidFile)
+	require.NotNil(t, err)
+}
+
+func TestCRLVerify(t *testing.T) {
+	ca := newTestAuthority(t)
+	other := newTestAuthority(t)
+	serverCert := ca.issue(t, "server")
+	revoked := ca.issue(t, "revoked")
+	good := ca.issue(t, "good")
+	dir := t.TempDir()
+	crlFile := filepath.Join(dir, "crl.pem")
+	writeCRL := func(der []byte) {
+		require.Nil(t, ioutil.WriteFile(crlFile, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), 0644))
+	}
+	// the list signed by other authority is ignored
+	otherCRL := filepath.Join(dir, "other.der")
+	require.Nil(t, ioutil.WriteFile(otherCRL, other.createCRL(t, 1, good), 0644))
+	writeCRL(ca.createCRL(t, 1, revoked))
+	serverCfg := &v2.TLSConfig{
+		Status:            true,
+		CACert:            ca.certPem,
+		CertChain:         serverCert.CertPem,
+		PrivateKey:        serverCert.KeyPem,
+		VerifyClient:      true,
+		RequireClientCert: true,
+		CRLFiles:          []string{crlFile, otherCRL},
+	}
+	provider, err := NewProvider("crl_test", serverCfg)
+	require.Nil(t, err)
+	fp, ok := provider.(*fileProvider)
+	require.True(t, ok, "crl files should be watched")
+	newClient := func(cert *certtool.CertificateInfo, crls ...string) *tlsContext {
+		cfg := &v2.TLSConfig{
+			Status:     true,
+			CACert:     ca.certPem,
+			CertChain:  cert.CertPem,
+			PrivateKey: cert.KeyPem,
+			ServerName: "server",
+			CRLFiles:   crls,
+		}
+		client, err := newTLSContext(cfg, &SecretInfo{
+			Certificate: cfg.CertChain,
+			PrivateKey:  cfg.PrivateKey,
+			Validation:  cfg.CACert,
+		})
+		require.Nil(t, err)
+		return client
+	}
+	_, serverErr, clientErr := handshake(t, fp.tlsContext(), newClient(good))
+	require.Nil(t, serverErr)
+	require.Nil(t, clientErr)
+	_, serverErr, _ = handshake(t, fp.tlsContext(), newClient(revoked))
+	require.NotNil(t, serverErr)
+	// revokes the good one
+	writeCRL(ca.createCRL(t, 2, revoked, good))
+	fp.reload()
+	_, serverErr, _ = handshake(t, fp.tlsContext(), newClient(good))
+	require.NotNil(t, serverErr)
+	// the server certificate is checked in client side
+	serverCRL := filepath.Join(dir, "server.der")
+	require.Nil(t, ioutil.WriteFile(serverCRL, ca.createCRL(t, 1, serverCert), 0644))
+	server, err := newTLSContext(&v2.TLSConfig{Status: true, CACert: ca.certPem}, &SecretInfo{
+		Certificate: serverCert.CertPem,
+		PrivateKey:  serverCert.KeyPem,
+		Validation:  ca.certPem,
+	})
+	require.Nil(t, err)
+	_, _, clientErr = handshake(t, server, newClient(good))
+	require.Nil(t, clientErr)
+	_, _, clientErr = handshake(t, server, newClient(good, serverCRL))
+	require.NotNil(t, clientErr)
+}
+
+func TestCRLCheckCertificate(t *testing.T) {
+	ca := newTestAuthority(t)
+	good := parseCertPem(t, ca.issue(t, "good").CertPem)
+	dir := t.TempDir()
+	newChecker := func(der []byte, validation string) *revocationChecker {
+		crlFile := filepath.Join(dir, "crl.der")
+		require.Nil(t, ioutil.WriteFile(crlFile, der, 0644))
+		c, err := newRevocationChecker(&v2.TLSConfig{CRLFiles: []string{crlFile}}, &SecretInfo{Validation: validation})
+		require.Nil(t, err)
+		return c
+	}
+	c := newChecker(ca.createCRL(t, 1), ca.certPem)
+	require.Nil(t, c.check([]*x509.Certificate{good}))
+	// the list is not updated in time
+	c = newChecker(ca.createCRLUntil(t, 2, time.Now().Add(-time.Minute)), ca.certPem)
+	require.True(t, errors.Is(c.check([]*x509.Certificate{good}), ErrorCRLExpired))
+	// the signature of the list cannot be checked without the issuer
+	c = newChecker(ca.createCRL(t, 3), "")
+	require.True(t, errors.Is(c.check([]*x509.Certificate{good}), ErrorCRLIssuerNotFound))
+}
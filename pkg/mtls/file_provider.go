@@ -0,0 +1,256 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mtls
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/utils"
+)
+
+var (
+	// fileWatchInterval is the interval of checking the certificate files
+	fileWatchInterval = 5 * time.Second
+
+	fileWatcherInstance = &fileWatcher{
+		providers: make(map[string][]*fileProvider),
+	}
+)
+
+// watchedFile records the content digest of a file referenced by the tls config.
+// the file is read by path every time, so a symlink swap is found as a content change.
+type watchedFile struct {
+	path   string
+	digest [sha256.Size]byte
+}
+
+func (f *watchedFile) changed() (bool, [sha256.Size]byte) {
+	b, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		// the file may be in the middle of rotation, try again later
+		return false, f.digest
+	}
+	digest := sha256.Sum256(b)
+	return digest != f.digest, digest
+}
+
+// newWatchedFiles returns the files referenced by the secret info and the trust domains,
+// the pem strings are not files and will not be watched.
+func newWatchedFiles(cfg *v2.TLSConfig, secret *SecretInfo) []*watchedFile {
+	var files []*watchedFile
+	paths := []string{secret.Certificate, secret.PrivateKey, secret.Validation}
+	for _, td := range cfg.TrustDomains {
+		paths = append(paths, td.CACert)
+	}
+	paths = append(paths, cfg.CRLFiles...)
+	if cfg.OCSPStaple != nil {
+		paths = append(paths, cfg.OCSPStaple.StapleFile)
+	}
+	for _, path := range paths {
+		if path == "" || strings.Contains(path, "-----BEGIN") {
+			continue
+		}
+		if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		files = append(files, &watchedFile{
+			path:   path,
+			digest: sha256.Sum256(b),
+		})
+	}
+	return files
+}
+
+// fileProvider is an implementation of types.Provider
+// fileProvider stored a tls context that makes by the certificate files,
+// and rebuilds the tls context when the files are changed.
+// the connections established already are not affected.
+type fileProvider struct {
+	value  atomic.Value // store *tlsContext
+	index  string
+	config *v2.TLSConfig
+	secret *SecretInfo
+	files  []*watchedFile
+	stats  *TLSStats
+	expiry time.Time
+}
+
+func newFileProvider(index string, cfg *v2.TLSConfig, secret *SecretInfo, ctx *tlsContext, files []*watchedFile) *fileProvider {
+	p := &fileProvider{
+		index:  index,
+		config: cfg,
+		secret: secret,
+		files:  files,
+		stats:  NewStats(index),
+	}
+	p.store(ctx)
+	return p
+}
+
+func (p *fileProvider) store(ctx *tlsContext) {
+	p.value.Store(ctx)
+	p.expiry = certificateExpiry(ctx)
+	p.updateExpiry()
+}
+
+func (p *fileProvider) updateExpiry() {
+	if p.expiry.IsZero() {
+		return
+	}
+	p.stats.CertExpirySeconds.Update(int64(time.Until(p.expiry).Seconds()))
+}
+
+// reload rebuilds the tls context if any of the files is changed.
+// if the rebuild is failed, the files will be checked again in next time.
+func (p *fileProvider) reload() {
+	var changed bool
+	digests := make([][sha256.Size]byte, len(p.files))
+	for i, f := range p.files {
+		var c bool
+		c, digests[i] = f.changed()
+		changed = changed || c
+	}
+	if !changed {
+		return
+	}
+	ctx, err := newTLSContext(p.config, p.secret)
+	if err != nil {
+		p.stats.CertReloadFailed.Inc(1)
+		log.DefaultLogger.Alertf("tls.reload", "[mtls] [file provider] provider %s reload certificate failed: %v", p.index, err)
+		return
+	}
+	for i, f := range p.files {
+		f.digest = digests[i]
+	}
+	p.store(ctx)
+	p.stats.CertReloadSuccess.Inc(1)
+	if log.DefaultLogger.GetLogLevel() >= log.INFO {
+		log.DefaultLogger.Infof("[mtls] [file provider] provider %s reload certificate success", p.index)
+	}
+	// notify certificates updates
+	for _, cb := range p.config.Callbacks {
+		if f, ok := sdsCallbacks[cb]; ok {
+			f(p.config)
+		}
+	}
+}
+
+func (p *fileProvider) tlsContext() *tlsContext {
+	ctx, _ := p.value.Load().(*tlsContext)
+	return ctx
+}
+
+func (p *fileProvider) GetTLSConfigContext(client bool) *types.TLSConfigContext {
+	return p.tlsContext().GetTLSConfigContext(client)
+}
+
+func (p *fileProvider) MatchedServerName(sn string) bool {
+	return p.tlsContext().MatchedServerName(sn)
+}
+
+func (p *fileProvider) MatchedALPN(protos []string) bool {
+	return p.tlsContext().MatchedALPN(protos)
+}
+
+func (p *fileProvider) Ready() bool {
+	return true
+}
+
+func (p *fileProvider) Empty() bool {
+	return p.tlsContext().server == nil
+}
+
+// certificateExpiry returns the expiry time of the certificate in tls context
+func certificateExpiry(ctx *tlsContext) time.Time {
+	cfg := ctx.GetTLSConfigContext(true).Config()
+	if cfg == nil || len(cfg.Certificates) == 0 || len(cfg.Certificates[0].Certificate) == 0 {
+		return time.Time{}
+	}
+	cert, err := x509.ParseCertificate(cfg.Certificates[0].Certificate[0])
+	if err != nil {
+		return time.Time{}
+	}
+	return cert.NotAfter
+}
+
+// fileWatcher checks the files of the file providers periodically
+type fileWatcher struct {
+	mutex sync.Mutex
+	once  sync.Once
+	// providers are keyed by the index of the context manager, a listener or a cluster
+	providers map[string][]*fileProvider
+}
+
+// watchFileProviders watches the file providers of the context manager, the providers of the
+// replaced context manager with the same index are not watched anymore.
+func watchFileProviders(index string, providers ...types.TLSProvider) {
+	var fps []*fileProvider
+	for _, provider := range providers {
+		if p, ok := provider.(*fileProvider); ok && p != nil {
+			fps = append(fps, p)
+		}
+	}
+	fileWatcherInstance.replace(index, fps)
+}
+
+func (w *fileWatcher) replace(index string, providers []*fileProvider) {
+	w.mutex.Lock()
+	if len(providers) == 0 {
+		delete(w.providers, index)
+		w.mutex.Unlock()
+		return
+	}
+	w.providers[index] = providers
+	w.mutex.Unlock()
+	w.once.Do(func() {
+		utils.GoWithRecover(w.run, nil)
+	})
+}
+
+func (w *fileWatcher) run() {
+	ticker := time.NewTicker(fileWatchInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		w.check()
+	}
+}
+
+func (w *fileWatcher) check() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for _, providers := range w.providers {
+		for _, p := range providers {
+			p.reload()
+			p.updateExpiry()
+		}
+	}
+}
@@ -0,0 +1,157 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mtls
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/mtls/certtool"
+)
+
+// writeSecretDir writes the certificate into a data dir like the kubernetes secret volume,
+// the files are linked to the data dir by the symlink ..data.
+func writeSecretDir(t *testing.T, dir, version, dns string) {
+	priv, err := certtool.GeneratePrivateKey("P256")
+	require.Nil(t, err)
+	tmpl, err := certtool.CreateTemplate(dns, false, []string{dns})
+	require.Nil(t, err)
+	cert, err := certtool.SignCertificate(tmpl, priv)
+	require.Nil(t, err)
+	dataDir := filepath.Join(dir, version)
+	require.Nil(t, os.MkdirAll(dataDir, 0755))
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dataDir, "tls.crt"), []byte(cert.CertPem), 0644))
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dataDir, "tls.key"), []byte(cert.KeyPem), 0644))
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dataDir, "ca.crt"), []byte(certtool.GetRootCA().CertPem), 0644))
+	// swap the data dir symlink atomically
+	tmpLink := filepath.Join(dir, "..data_tmp")
+	require.Nil(t, os.Symlink(version, tmpLink))
+	require.Nil(t, os.Rename(tmpLink, filepath.Join(dir, "..data")))
+	for _, name := range []string{"tls.crt", "tls.key", "ca.crt"} {
+		link := filepath.Join(dir, name)
+		if _, err := os.Lstat(link); err != nil {
+			require.Nil(t, os.Symlink(filepath.Join("..data", name), link))
+		}
+	}
+}
+
+func TestFileProviderReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mosn_tls_reload")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	writeSecretDir(t, dir, "v1", "www.a.com")
+	cfg := &v2.TLSConfig{
+		Status:     true,
+		CertChain:  filepath.Join(dir, "tls.crt"),
+		PrivateKey: filepath.Join(dir, "tls.key"),
+		CACert:     filepath.Join(dir, "ca.crt"),
+	}
+	provider, err := NewProvider("server_test_file_reload", cfg)
+	require.Nil(t, err)
+	p, ok := provider.(*fileProvider)
+	require.True(t, ok)
+	assert.Len(t, p.files, 3)
+	assert.True(t, p.Ready())
+	assert.False(t, p.Empty())
+	assert.True(t, p.MatchedServerName("www.a.com"))
+	assert.True(t, p.stats.CertExpirySeconds.Value() > 0)
+	oldCtx := p.GetTLSConfigContext(false)
+
+	// files are not changed
+	p.reload()
+	assert.Equal(t, int64(0), p.stats.CertReloadSuccess.Count())
+	assert.Equal(t, oldCtx, p.GetTLSConfigContext(false))
+
+	// symlink swapped
+	writeSecretDir(t, dir, "v2", "www.b.com")
+	p.reload()
+	assert.Equal(t, int64(1), p.stats.CertReloadSuccess.Count())
+	assert.True(t, p.MatchedServerName("www.b.com"))
+	assert.False(t, p.MatchedServerName("www.a.com"))
+	assert.False(t, p.GetTLSConfigContext(false).HashValue().Equal(oldCtx.HashValue()))
+
+	// a broken key keeps the current tls context
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "v2", "tls.key"), []byte("broken"), 0644))
+	p.reload()
+	assert.Equal(t, int64(1), p.stats.CertReloadFailed.Count())
+	assert.True(t, p.MatchedServerName("www.b.com"))
+	// failed reload is retried
+	p.reload()
+	assert.Equal(t, int64(2), p.stats.CertReloadFailed.Count())
+}
+
+func TestInlinePemIsNotWatched(t *testing.T) {
+	priv, err := certtool.GeneratePrivateKey("P256")
+	require.Nil(t, err)
+	tmpl, err := certtool.CreateTemplate("www.a.com", false, []string{"www.a.com"})
+	require.Nil(t, err)
+	cert, err := certtool.SignCertificate(tmpl, priv)
+	require.Nil(t, err)
+	provider, err := NewProvider("server_test_inline_pem", &v2.TLSConfig{
+		Status:     true,
+		CertChain:  cert.CertPem,
+		PrivateKey: cert.KeyPem,
+		CACert:     certtool.GetRootCA().CertPem,
+	})
+	require.Nil(t, err)
+	_, ok := provider.(*staticProvider)
+	assert.True(t, ok)
+}
+
+func TestFileProviderReplaced(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mosn_tls_replace")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	writeSecretDir(t, dir, "v1", "www.a.com")
+	newConfig := func(sni string) *v2.TLSConfig {
+		return &v2.TLSConfig{
+			Status:     true,
+			CertChain:  filepath.Join(dir, "tls.crt"),
+			PrivateKey: filepath.Join(dir, "tls.key"),
+			CACert:     filepath.Join(dir, "ca.crt"),
+			ServerName: sni,
+		}
+	}
+	watched := func(index string) []*fileProvider {
+		fileWatcherInstance.mutex.Lock()
+		defer fileWatcherInstance.mutex.Unlock()
+		return fileWatcherInstance.providers[index]
+	}
+	// the client context manager is updated with another config
+	_, err = NewTLSClientContextManager("test_file_replace", newConfig("www.a.com"))
+	require.Nil(t, err)
+	providers := watched(clientContextPrefix + "test_file_replace")
+	require.Len(t, providers, 1)
+	_, err = NewTLSClientContextManager("test_file_replace", newConfig("www.b.com"))
+	require.Nil(t, err)
+	replaced := watched(clientContextPrefix + "test_file_replace")
+	require.Len(t, replaced, 1)
+	assert.NotEqual(t, providers[0], replaced[0])
+
+	// the tls is disabled
+	_, err = NewTLSClientContextManager("test_file_replace", &v2.TLSConfig{})
+	require.Nil(t, err)
+	assert.Len(t, watched(clientContextPrefix+"test_file_replace"), 0)
+}
@@ -24,11 +24,17 @@ import (
 
 type TLSStats struct {
 	TLSConnpoolChanged gometrics.Counter
+	CertReloadSuccess  gometrics.Counter
+	CertReloadFailed   gometrics.Counter
+	CertExpirySeconds  gometrics.Gauge
 }
 
 func NewStats(name string) *TLSStats {
 	s := metrics.NewTLSStats(name)
 	return &TLSStats{
 		TLSConnpoolChanged: s.Counter(metrics.TLSConnpoolChanged),
+		CertReloadSuccess:  s.Counter(metrics.TLSCertReloadSuccess),
+		CertReloadFailed:   s.Counter(metrics.TLSCertReloadFailed),
+		CertExpirySeconds:  s.Gauge(metrics.TLSCertExpirySeconds),
 	}
 }
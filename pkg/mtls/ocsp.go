@@ -0,0 +1,305 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mtls
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/mtls/crypto/tls"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/utils"
+)
+
+var (
+	// ocspFetchTimeout is the timeout of fetching the response from the OCSP responder
+	ocspFetchTimeout = 10 * time.Second
+	// ocspRetryInterval is the interval of fetching the response again after a failure
+	ocspRetryInterval = time.Minute
+	// ocspDefaultRefreshInterval is used if the response does not contain the next update time
+	ocspDefaultRefreshInterval = time.Hour
+)
+
+// maxOCSPResponseSize limits the size of the fetched response
+const maxOCSPResponseSize = 1 << 20
+
+var ErrorOCSPStapleRequired = errors.New("tls: no valid ocsp response to staple")
+
+// mustStapleOID is the TLS feature extension defined in RFC 7633
+var mustStapleOID = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 24}
+
+// ocspResponse is a good status OCSP response
+type ocspResponse struct {
+	raw        []byte
+	thisUpdate time.Time
+	nextUpdate time.Time
+}
+
+func (r *ocspResponse) valid(now time.Time) bool {
+	return r.nextUpdate.IsZero() || now.Before(r.nextUpdate)
+}
+
+// refreshTime returns the time of fetching a new response, which is the half of the validity period
+func (r *ocspResponse) refreshTime() time.Time {
+	if r.nextUpdate.IsZero() {
+		return time.Now().Add(ocspDefaultRefreshInterval)
+	}
+	return r.thisUpdate.Add(r.nextUpdate.Sub(r.thisUpdate) / 2)
+}
+
+func parseOCSPResponse(raw []byte, leaf, issuer *x509.Certificate) (*ocspResponse, error) {
+	resp, err := ocsp.ParseResponseForCert(raw, leaf, issuer)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.Status {
+	case ocsp.Good:
+	case ocsp.Revoked:
+		return nil, fmt.Errorf("%w: revoked at %s", ErrorCertificateRevoked, resp.RevokedAt)
+	default:
+		return nil, errors.New("tls: certificate status is unknown in ocsp response")
+	}
+	return &ocspResponse{
+		raw:        raw,
+		thisUpdate: resp.ThisUpdate,
+		nextUpdate: resp.NextUpdate,
+	}, nil
+}
+
+// fetchOCSPResponse fetches the response from the first OCSP responder in the certificate
+func fetchOCSPResponse(leaf, issuer *x509.Certificate) (*ocspResponse, error) {
+	if len(leaf.OCSPServer) == 0 {
+		return nil, errors.New("no ocsp server in certificate")
+	}
+	req, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: ocspFetchTimeout}
+	resp, err := client.Post(leaf.OCSPServer[0], "application/ocsp-request", bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ocsp server %s response status %d", leaf.OCSPServer[0], resp.StatusCode)
+	}
+	raw, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxOCSPResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	return parseOCSPResponse(raw, leaf, issuer)
+}
+
+type ocspCacheEntry struct {
+	response  *ocspResponse
+	fetching  bool
+	nextFetch time.Time
+}
+
+// ocspCache caches the fetched responses by the certificate,
+// so the response can be shared by the tls contexts that rebuilt or created by different listeners.
+type ocspCache struct {
+	mutex   sync.Mutex
+	entries map[[sha256.Size]byte]*ocspCacheEntry
+}
+
+var ocspCacheInstance = &ocspCache{
+	entries: make(map[[sha256.Size]byte]*ocspCacheEntry),
+}
+
+// get returns the cached response, and fetches a new one in background if it needs a refresh.
+func (c *ocspCache) get(leaf, issuer *x509.Certificate) *ocspResponse {
+	key := sha256.Sum256(leaf.Raw)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &ocspCacheEntry{}
+		c.entries[key] = entry
+	}
+	if !entry.fetching && !time.Now().Before(entry.nextFetch) {
+		entry.fetching = true
+		utils.GoWithRecover(func() {
+			c.fetch(entry, leaf, issuer)
+		}, nil)
+	}
+	return entry.response
+}
+
+func (c *ocspCache) fetch(entry *ocspCacheEntry, leaf, issuer *x509.Certificate) {
+	resp, err := fetchOCSPResponse(leaf, issuer)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry.fetching = false
+	if err != nil {
+		entry.nextFetch = time.Now().Add(ocspRetryInterval)
+		log.DefaultLogger.Alertf("tls.ocsp", "[mtls] fetch ocsp response for %s failed: %v", leaf.Subject.String(), err)
+		return
+	}
+	entry.response = resp
+	entry.nextFetch = resp.refreshTime()
+	if log.DefaultLogger.GetLogLevel() >= log.INFO {
+		log.DefaultLogger.Infof("[mtls] fetch ocsp response for %s success, next update: %s", leaf.Subject.String(), resp.nextUpdate)
+	}
+}
+
+type stapledConfig struct {
+	response *ocspResponse
+	ctx      *types.TLSConfigContext
+}
+
+// ocspStapler makes the server tls config context with the OCSP response stapled
+type ocspStapler struct {
+	policy     string
+	mustStaple bool
+	leaf       *x509.Certificate
+	issuer     *x509.Certificate
+	// staple is the response read from the staple file
+	staple   *ocspResponse
+	fetch    bool
+	base     *tls.Config
+	hashFunc func(*tls.Config) *types.HashValue
+	current  atomic.Value // store *stapledConfig
+}
+
+func newOCSPStapler(cfg *v2.OCSPStapleConfig, secret *SecretInfo, base *tls.Config, hashFunc func(*tls.Config) *types.HashValue) (*ocspStapler, error) {
+	s := &ocspStapler{
+		policy:   cfg.Policy,
+		fetch:    cfg.Fetch,
+		base:     base,
+		hashFunc: hashFunc,
+	}
+	switch s.policy {
+	case "":
+		s.policy = v2.OCSPStaplePolicyLenient
+	case v2.OCSPStaplePolicyLenient, v2.OCSPStaplePolicyStrict, v2.OCSPStaplePolicyMust:
+	default:
+		return nil, fmt.Errorf("tls: unsupported ocsp staple policy %s", cfg.Policy)
+	}
+	if len(base.Certificates) == 0 || len(base.Certificates[0].Certificate) == 0 {
+		return nil, ErrorNoCertConfigure
+	}
+	chain := base.Certificates[0].Certificate
+	leaf, err := x509.ParseCertificate(chain[0])
+	if err != nil {
+		return nil, err
+	}
+	s.leaf = leaf
+	for _, ext := range leaf.Extensions {
+		if ext.Id.Equal(mustStapleOID) {
+			s.mustStaple = true
+		}
+	}
+	if len(chain) > 1 {
+		if s.issuer, err = x509.ParseCertificate(chain[1]); err != nil {
+			return nil, err
+		}
+	} else if secret.Validation != "" {
+		if cas, err := loadCertificates(secret.Validation); err == nil {
+			for _, ca := range cas {
+				if leaf.CheckSignatureFrom(ca) == nil {
+					s.issuer = ca
+					break
+				}
+			}
+		}
+	}
+	switch {
+	case cfg.StapleFile != "":
+		raw, err := ioutil.ReadFile(cfg.StapleFile)
+		if err != nil {
+			return nil, fmt.Errorf("load ocsp staple file %s error: %v", cfg.StapleFile, err)
+		}
+		// the response is not stapled if it is invalid, the policy decides the handshake can go on or not
+		if s.staple, err = parseOCSPResponse(raw, leaf, s.issuer); err != nil {
+			log.DefaultLogger.Alertf("tls.ocsp", "[mtls] ocsp staple file %s is invalid: %v", cfg.StapleFile, err)
+		}
+	case cfg.Fetch:
+		if s.issuer == nil {
+			return nil, errors.New("tls: fetch ocsp response requires the issuer certificate")
+		}
+	}
+	return s, nil
+}
+
+func (s *ocspStapler) response() *ocspResponse {
+	if s.fetch && s.staple == nil {
+		return ocspCacheInstance.get(s.leaf, s.issuer)
+	}
+	return s.staple
+}
+
+// required returns true if the handshake should be failed without a valid response
+func (s *ocspStapler) required() bool {
+	switch s.policy {
+	case v2.OCSPStaplePolicyMust:
+		return true
+	case v2.OCSPStaplePolicyStrict:
+		return s.mustStaple
+	default:
+		return false
+	}
+}
+
+// GetTLSConfigContext returns the server config context with the current valid response
+func (s *ocspStapler) GetTLSConfigContext() *types.TLSConfigContext {
+	resp := s.response()
+	if resp != nil && !resp.valid(time.Now()) {
+		resp = nil
+	}
+	if c, ok := s.current.Load().(*stapledConfig); ok && c.response == resp {
+		return c.ctx
+	}
+	c := s.build(resp)
+	s.current.Store(c)
+	return c.ctx
+}
+
+func (s *ocspStapler) build(resp *ocspResponse) *stapledConfig {
+	cfg := s.base.Clone()
+	switch {
+	case resp != nil:
+		certs := make([]tls.Certificate, len(cfg.Certificates))
+		copy(certs, cfg.Certificates)
+		certs[0].OCSPStaple = resp.raw
+		cfg.Certificates = certs
+	case s.required():
+		cfg.Certificates = nil
+		cfg.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return nil, ErrorOCSPStapleRequired
+		}
+	}
+	return &stapledConfig{
+		response: resp,
+		ctx:      types.NewTLSConfigContext(cfg, s.hashFunc),
+	}
+}
@@ -0,0 +1,195 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mtls
+
+import (
+	"crypto"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ocsp"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/mtls/certtool"
+)
+
+// createOCSPResponse returns a DER encoded ocsp response of the certificate
+func (ca *testAuthority) createOCSPResponse(t *testing.T, cert *certtool.CertificateInfo, status int) []byte {
+	now := time.Now()
+	resp, err := ocsp.CreateResponse(ca.cert, ca.cert, ocsp.Response{
+		Status:       status,
+		SerialNumber: parseCertPem(t, cert.CertPem).SerialNumber,
+		ThisUpdate:   now.Add(-time.Minute),
+		NextUpdate:   now.Add(time.Hour),
+		RevokedAt:    now.Add(-time.Minute),
+	}, ca.priv.(crypto.Signer))
+	require.Nil(t, err)
+	return resp
+}
+
+func newOCSPTestServer(t *testing.T, ca *testAuthority, cert *certtool.CertificateInfo, staple *v2.OCSPStapleConfig) *tlsContext {
+	cfg := &v2.TLSConfig{
+		Status:     true,
+		CACert:     ca.certPem,
+		CertChain:  cert.CertPem,
+		PrivateKey: cert.KeyPem,
+		OCSPStaple: staple,
+	}
+	ctx, err := newTLSContext(cfg, &SecretInfo{
+		Certificate: cfg.CertChain,
+		PrivateKey:  cfg.PrivateKey,
+		Validation:  cfg.CACert,
+	})
+	require.Nil(t, err)
+	return ctx
+}
+
+func newOCSPTestClient(t *testing.T, ca *testAuthority) *tlsContext {
+	client, err := newTLSContext(&v2.TLSConfig{
+		Status:     true,
+		ServerName: "server",
+	}, &SecretInfo{
+		Validation: ca.certPem,
+	})
+	require.Nil(t, err)
+	return client
+}
+
+func TestOCSPStapleFile(t *testing.T) {
+	ca := newTestAuthority(t)
+	cert := ca.issue(t, "server")
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.der")
+	require.Nil(t, ioutil.WriteFile(good, ca.createOCSPResponse(t, cert, ocsp.Good), 0644))
+	revoked := filepath.Join(dir, "revoked.der")
+	require.Nil(t, ioutil.WriteFile(revoked, ca.createOCSPResponse(t, cert, ocsp.Revoked), 0644))
+	client := newOCSPTestClient(t, ca)
+
+	testCases := []struct {
+		staple  *v2.OCSPStapleConfig
+		stapled bool
+		success bool
+	}{
+		{staple: &v2.OCSPStapleConfig{StapleFile: good}, stapled: true, success: true},
+		{staple: &v2.OCSPStapleConfig{StapleFile: good, Policy: v2.OCSPStaplePolicyMust}, stapled: true, success: true},
+		// the revoked response is not stapled
+		{staple: &v2.OCSPStapleConfig{StapleFile: revoked}, stapled: false, success: true},
+		{staple: &v2.OCSPStapleConfig{StapleFile: revoked, Policy: v2.OCSPStaplePolicyStrict}, stapled: false, success: true},
+		{staple: &v2.OCSPStapleConfig{StapleFile: revoked, Policy: v2.OCSPStaplePolicyMust}, success: false},
+	}
+	for i, tc := range testCases {
+		server := newOCSPTestServer(t, ca, cert, tc.staple)
+		_, state, serverErr, clientErr := handshakeStates(t, server, client)
+		if !tc.success {
+			require.NotNil(t, serverErr, "case %d", i)
+			require.NotNil(t, clientErr, "case %d", i)
+			continue
+		}
+		require.Nil(t, serverErr, "case %d", i)
+		require.Nil(t, clientErr, "case %d", i)
+		require.Equal(t, tc.stapled, len(state.OCSPResponse) > 0, "case %d", i)
+	}
+	// invalid config
+	_, err := newTLSContext(&v2.TLSConfig{
+		Status:     true,
+		OCSPStaple: &v2.OCSPStapleConfig{StapleFile: good, Policy: "unknown"},
+	}, &SecretInfo{
+		Certificate: cert.CertPem,
+		PrivateKey:  cert.KeyPem,
+	})
+	require.NotNil(t, err)
+	_, err = newTLSContext(&v2.TLSConfig{
+		Status:     true,
+		OCSPStaple: &v2.OCSPStapleConfig{StapleFile: filepath.Join(dir, "not_exists")},
+	}, &SecretInfo{
+		Certificate: cert.CertPem,
+		PrivateKey:  cert.KeyPem,
+	})
+	require.NotNil(t, err)
+}
+
+func TestOCSPMustStapleCertificate(t *testing.T) {
+	ca := newTestAuthority(t)
+	tmpl, err := certtool.CreateTemplate("server", false, []string{"server"})
+	require.Nil(t, err)
+	// TLS feature: status_request
+	tmpl.ExtraExtensions = append(tmpl.ExtraExtensions, pkix.Extension{
+		Id:    mustStapleOID,
+		Value: []byte{0x30, 0x03, 0x02, 0x01, 0x05},
+	})
+	cert := ca.issueTemplate(t, tmpl)
+	client := newOCSPTestClient(t, ca)
+	// no staple file and fetch
+	server := newOCSPTestServer(t, ca, cert, &v2.OCSPStapleConfig{})
+	require.True(t, server.stapler.mustStaple)
+	_, _, serverErr, _ := handshakeStates(t, server, client)
+	require.Nil(t, serverErr)
+	server = newOCSPTestServer(t, ca, cert, &v2.OCSPStapleConfig{Policy: v2.OCSPStaplePolicyStrict})
+	_, _, serverErr, _ = handshakeStates(t, server, client)
+	require.NotNil(t, serverErr)
+}
+
+func TestOCSPFetch(t *testing.T) {
+	ca := newTestAuthority(t)
+	var cert *certtool.CertificateInfo
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		b, _ := ioutil.ReadAll(r.Body)
+		req, err := ocsp.ParseRequest(b)
+		if err != nil || req.SerialNumber.Cmp(parseCertPem(t, cert.CertPem).SerialNumber) != 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write(ca.createOCSPResponse(t, cert, ocsp.Good))
+	}))
+	defer srv.Close()
+	tmpl, err := certtool.CreateTemplate("server", false, []string{"server"})
+	require.Nil(t, err)
+	tmpl.OCSPServer = []string{srv.URL}
+	cert = ca.issueTemplate(t, tmpl)
+
+	server := newOCSPTestServer(t, ca, cert, &v2.OCSPStapleConfig{Fetch: true})
+	require.Eventually(t, func() bool {
+		cfg := server.GetServerTLSConfigContext().Config()
+		return len(cfg.Certificates[0].OCSPStaple) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	_, state, serverErr, clientErr := handshakeStates(t, server, newOCSPTestClient(t, ca))
+	require.Nil(t, serverErr)
+	require.Nil(t, clientErr)
+	require.NotEmpty(t, state.OCSPResponse)
+	// the response is cached, and shared by the rebuilt tls context
+	rebuilt := newOCSPTestServer(t, ca, cert, &v2.OCSPStapleConfig{Fetch: true})
+	require.NotEmpty(t, rebuilt.GetServerTLSConfigContext().Config().Certificates[0].OCSPStaple)
+	require.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	// fetch requires the issuer
+	_, err = newTLSContext(&v2.TLSConfig{
+		Status:     true,
+		OCSPStaple: &v2.OCSPStapleConfig{Fetch: true},
+	}, &SecretInfo{
+		Certificate: cert.CertPem,
+		PrivateKey:  cert.KeyPem,
+	})
+	require.NotNil(t, err)
+}
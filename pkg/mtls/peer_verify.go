@@ -0,0 +1,340 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mtls
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"time"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/mtls/crypto/tls"
+)
+
+const spiffeScheme = "spiffe"
+
+var (
+	ErrorNoPeerCertificate   = errors.New("tls: no peer certificate")
+	ErrorSubjectAltNameMatch = errors.New("tls: subject alt names not matched")
+)
+
+// peerVerifier verifies the peer certificate by the subject alt names and the SPIFFE trust domains
+type peerVerifier struct {
+	matchers []*sanMatcher
+	// trustDomains stored the roots of each trust domain
+	trustDomains map[string][]*x509.Certificate
+	pools        map[string]*x509.CertPool
+}
+
+func newPeerVerifier(cfg *v2.TLSConfig) (*peerVerifier, error) {
+	if len(cfg.MatchSubjectAltNames) == 0 && len(cfg.TrustDomains) == 0 {
+		return nil, nil
+	}
+	v := &peerVerifier{}
+	for _, c := range cfg.MatchSubjectAltNames {
+		m, err := newSANMatcher(c)
+		if err != nil {
+			return nil, err
+		}
+		v.matchers = append(v.matchers, m)
+	}
+	if len(cfg.TrustDomains) > 0 {
+		v.trustDomains = make(map[string][]*x509.Certificate, len(cfg.TrustDomains))
+		v.pools = make(map[string]*x509.CertPool, len(cfg.TrustDomains))
+		for _, td := range cfg.TrustDomains {
+			if td.Name == "" {
+				return nil, errors.New("tls: trust domain name is empty")
+			}
+			certs, err := loadCertificates(td.CACert)
+			if err != nil {
+				return nil, fmt.Errorf("load trust domain %s bundle error: %v", td.Name, err)
+			}
+			name := strings.ToLower(td.Name)
+			v.trustDomains[name] = append(v.trustDomains[name], certs...)
+			pool, ok := v.pools[name]
+			if !ok {
+				pool = x509.NewCertPool()
+				v.pools[name] = pool
+			}
+			for _, cert := range certs {
+				pool.AddCert(cert)
+			}
+		}
+	}
+	return v, nil
+}
+
+// rootCAs returns a pool contains the trust domain roots and the validation roots.
+func (v *peerVerifier) rootCAs(validation string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if validation != "" {
+		certs, err := loadCertificates(validation)
+		if err != nil {
+			return nil, fmt.Errorf("load ca certificate error: %v", err)
+		}
+		for _, cert := range certs {
+			pool.AddCert(cert)
+		}
+	}
+	for _, certs := range v.trustDomains {
+		for _, cert := range certs {
+			pool.AddCert(cert)
+		}
+	}
+	return pool, nil
+}
+
+// ServerHandshakeVerify verifies the chains verified by the tls server already.
+// the next verify function from the config hooks is called first.
+func (v *peerVerifier) ServerHandshakeVerify(next func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error) func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if next != nil {
+			if err := next(rawCerts, verifiedChains); err != nil {
+				return err
+			}
+		}
+		// the client certificate is not required to be verified
+		if len(verifiedChains) == 0 {
+			return nil
+		}
+		return v.verifyChains(verifiedChains)
+	}
+}
+
+// ClientHandshakeVerify verifies the server certificate without the server name,
+// the subject alt names matchers and trust domains are used instead.
+func (v *peerVerifier) ClientHandshakeVerify(cfg *tls.Config, next func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error) func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if next != nil {
+			if err := next(rawCerts, verifiedChains); err != nil {
+				return err
+			}
+		}
+		now := time.Now()
+		if cfg.Time != nil {
+			now = cfg.Time()
+		}
+		return v.verifyPeer(rawCerts, cfg.RootCAs, now)
+	}
+}
+
+// verifyPeer verifies the certificate chain, and then verifies the subject alt names and trust domain.
+func (v *peerVerifier) verifyPeer(rawCerts [][]byte, roots *x509.CertPool, now time.Time) error {
+	if len(rawCerts) == 0 {
+		return ErrorNoPeerCertificate
+	}
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, asn1Data := range rawCerts {
+		cert, err := tls.LoadOrStoreCertificate(asn1Data)
+		if err != nil {
+			return err
+		}
+		certs = append(certs, cert)
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		CurrentTime:   now,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	leaf := certs[0]
+	if len(v.trustDomains) > 0 {
+		td, err := spiffeTrustDomain(leaf)
+		if err != nil {
+			return err
+		}
+		pool, ok := v.pools[td]
+		if !ok {
+			return fmt.Errorf("tls: trust domain %s is not trusted", td)
+		}
+		opts.Roots = pool
+	}
+	chains, err := leaf.Verify(opts)
+	if err != nil {
+		return err
+	}
+	return v.verifyChains(chains)
+}
+
+// verifyChains checks the verified chains are issued by the trust domain in the SPIFFE ID of the leaf certificate,
+// and the leaf certificate's subject alt names matches the matchers.
+func (v *peerVerifier) verifyChains(chains [][]*x509.Certificate) error {
+	leaf := chains[0][0]
+	if len(v.trustDomains) > 0 {
+		td, err := spiffeTrustDomain(leaf)
+		if err != nil {
+			return err
+		}
+		roots, ok := v.trustDomains[td]
+		if !ok {
+			return fmt.Errorf("tls: trust domain %s is not trusted", td)
+		}
+		if !issuedBy(chains, roots) {
+			return fmt.Errorf("tls: certificate is not issued by the trust domain %s", td)
+		}
+	}
+	if len(v.matchers) == 0 {
+		return nil
+	}
+	for _, m := range v.matchers {
+		if m.Match(leaf) {
+			return nil
+		}
+	}
+	return ErrorSubjectAltNameMatch
+}
+
+func issuedBy(chains [][]*x509.Certificate, roots []*x509.Certificate) bool {
+	for _, chain := range chains {
+		root := chain[len(chain)-1]
+		for _, r := range roots {
+			if bytes.Equal(root.Raw, r.Raw) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SpiffeID returns the SPIFFE ID of the certificate, a SPIFFE certificate contains exactly one URI SAN with spiffe scheme.
+func SpiffeID(cert *x509.Certificate) (string, error) {
+	if len(cert.URIs) != 1 || !strings.EqualFold(cert.URIs[0].Scheme, spiffeScheme) || cert.URIs[0].Host == "" {
+		return "", errors.New("tls: certificate does not contain a valid SPIFFE ID")
+	}
+	return cert.URIs[0].String(), nil
+}
+
+func spiffeTrustDomain(cert *x509.Certificate) (string, error) {
+	if _, err := SpiffeID(cert); err != nil {
+		return "", err
+	}
+	return strings.ToLower(cert.URIs[0].Host), nil
+}
+
+// loadCertificates loads the certificates from a pem string or a file
+func loadCertificates(index string) ([]*x509.Certificate, error) {
+	var data []byte
+	if strings.Contains(index, "-----BEGIN") {
+		data = []byte(index)
+	} else {
+		b, err := ioutil.ReadFile(index)
+		if err != nil {
+			return nil, err
+		}
+		data = b
+	}
+	var certs []*x509.Certificate
+	for len(data) > 0 {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificate")
+	}
+	return certs, nil
+}
+
+// sanMatcher is the runtime of v2.SubjectAltNameMatcher
+type sanMatcher struct {
+	typ   string
+	match func(s string) bool
+}
+
+func newSANMatcher(cfg v2.SubjectAltNameMatcher) (*sanMatcher, error) {
+	m := &sanMatcher{
+		typ: strings.ToUpper(cfg.Type),
+	}
+	switch m.typ {
+	case "", v2.SubjectAltNameURI, v2.SubjectAltNameDNS, v2.SubjectAltNameIP:
+	default:
+		return nil, fmt.Errorf("tls: unsupported subject alt name type %s", cfg.Type)
+	}
+	normalize := func(s string) string { return s }
+	if cfg.IgnoreCase {
+		normalize = strings.ToLower
+	}
+	switch {
+	case cfg.Exact != "":
+		exact := normalize(cfg.Exact)
+		m.match = func(s string) bool { return normalize(s) == exact }
+	case cfg.Prefix != "":
+		prefix := normalize(cfg.Prefix)
+		m.match = func(s string) bool { return strings.HasPrefix(normalize(s), prefix) }
+	case cfg.Suffix != "":
+		suffix := normalize(cfg.Suffix)
+		m.match = func(s string) bool { return strings.HasSuffix(normalize(s), suffix) }
+	case cfg.Regex != "":
+		expr := "^(?:" + cfg.Regex + ")$"
+		if cfg.IgnoreCase {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("tls: invalid subject alt name regex %s: %v", cfg.Regex, err)
+		}
+		m.match = re.MatchString
+	default:
+		return nil, errors.New("tls: subject alt name matcher is empty")
+	}
+	return m, nil
+}
+
+// Match returns true if any of the subject alt names in the certificate is matched
+func (m *sanMatcher) Match(cert *x509.Certificate) bool {
+	if m.typ == "" || m.typ == v2.SubjectAltNameURI {
+		for _, uri := range cert.URIs {
+			if m.match(uri.String()) {
+				return true
+			}
+		}
+	}
+	if m.typ == "" || m.typ == v2.SubjectAltNameDNS {
+		for _, dns := range cert.DNSNames {
+			if m.match(dns) {
+				return true
+			}
+		}
+	}
+	if m.typ == "" || m.typ == v2.SubjectAltNameIP {
+		for _, ip := range cert.IPAddresses {
+			if m.match(ip.String()) {
+				return true
+			}
+		}
+	}
+	return false
+}
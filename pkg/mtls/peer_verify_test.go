@@ -0,0 +1,229 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mtls
+
+import (
+	gotls "crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/mtls/certtool"
+	"mosn.io/mosn/pkg/mtls/crypto/tls"
+)
+
+type testAuthority struct {
+	cert    *x509.Certificate
+	priv    interface{}
+	certPem string
+}
+
+func newTestAuthority(t *testing.T) *testAuthority {
+	priv, err := certtool.GeneratePrivateKey("P256")
+	require.Nil(t, err)
+	tmpl, err := certtool.CreateTemplate("ca", true, nil)
+	require.Nil(t, err)
+	tmpl.KeyUsage |= x509.KeyUsageCRLSign
+	info, err := certtool.CreateCertificateInfo(tmpl, tmpl, priv, priv)
+	require.Nil(t, err)
+	block, _ := pem.Decode([]byte(info.CertPem))
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.Nil(t, err)
+	return &testAuthority{cert: cert, priv: priv, certPem: info.CertPem}
+}
+
+// issue issues a certificate with the uri subject alt names
+func (ca *testAuthority) issue(t *testing.T, cn string, uris ...string) *certtool.CertificateInfo {
+	tmpl, err := certtool.CreateTemplate(cn, false, []string{cn})
+	require.Nil(t, err)
+	for _, s := range uris {
+		u, err := url.Parse(s)
+		require.Nil(t, err)
+		tmpl.URIs = append(tmpl.URIs, u)
+	}
+	return ca.issueTemplate(t, tmpl)
+}
+
+func (ca *testAuthority) issueTemplate(t *testing.T, tmpl *x509.Certificate) *certtool.CertificateInfo {
+	priv, err := certtool.GeneratePrivateKey("P256")
+	require.Nil(t, err)
+	info, err := certtool.CreateCertificateInfo(tmpl, ca.cert, priv, ca.priv)
+	require.Nil(t, err)
+	return info
+}
+
+func parseCertPem(t *testing.T, s string) *x509.Certificate {
+	block, _ := pem.Decode([]byte(s))
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.Nil(t, err)
+	return cert
+}
+
+// handshake returns the server side connection state, the server and client side handshake error
+func handshake(t *testing.T, server, client *tlsContext) (gotls.ConnectionState, error, error) {
+	state, _, serverErr, clientErr := handshakeStates(t, server, client)
+	return state, serverErr, clientErr
+}
+
+// handshakeStates returns the connection states and the handshake errors of both sides
+func handshakeStates(t *testing.T, server, client *tlsContext) (gotls.ConnectionState, gotls.ConnectionState, error, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer ln.Close()
+	type result struct {
+		state gotls.ConnectionState
+		err   error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			ch <- result{err: err}
+			return
+		}
+		defer c.Close()
+		srv := tls.Server(c, server.GetServerTLSConfigContext().Config())
+		err = srv.Handshake()
+		ch <- result{state: srv.GetConnectionState(), err: err}
+	}()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	require.Nil(t, err)
+	defer c.Close()
+	cli := tls.Client(c, client.client.Config())
+	clientErr := cli.Handshake()
+	r := <-ch
+	return r.state, cli.GetConnectionState(), r.err, clientErr
+}
+
+func TestSANMatcher(t *testing.T) {
+	ca := newTestAuthority(t)
+	cert := parseCertPem(t, ca.issue(t, "www.test.com", "spiffe://cluster.local/ns/default/sa/app").CertPem)
+	testCases := []struct {
+		cfg     v2.SubjectAltNameMatcher
+		matched bool
+	}{
+		{cfg: v2.SubjectAltNameMatcher{Exact: "spiffe://cluster.local/ns/default/sa/app"}, matched: true},
+		{cfg: v2.SubjectAltNameMatcher{Type: "uri", Prefix: "spiffe://cluster.local/ns/default/"}, matched: true},
+		{cfg: v2.SubjectAltNameMatcher{Type: v2.SubjectAltNameDNS, Prefix: "spiffe://"}, matched: false},
+		{cfg: v2.SubjectAltNameMatcher{Type: v2.SubjectAltNameDNS, Suffix: ".test.com"}, matched: true},
+		{cfg: v2.SubjectAltNameMatcher{Type: v2.SubjectAltNameDNS, Exact: "WWW.TEST.COM"}, matched: false},
+		{cfg: v2.SubjectAltNameMatcher{Type: v2.SubjectAltNameDNS, Exact: "WWW.TEST.COM", IgnoreCase: true}, matched: true},
+		{cfg: v2.SubjectAltNameMatcher{Type: v2.SubjectAltNameURI, Regex: "spiffe://cluster.local/ns/[a-z]+/sa/app"}, matched: true},
+		// regex must match the whole value
+		{cfg: v2.SubjectAltNameMatcher{Type: v2.SubjectAltNameURI, Regex: "ns/default"}, matched: false},
+		{cfg: v2.SubjectAltNameMatcher{Type: v2.SubjectAltNameIP, Exact: "127.0.0.1"}, matched: true},
+	}
+	for i, tc := range testCases {
+		m, err := newSANMatcher(tc.cfg)
+		require.Nil(t, err, "case %d", i)
+		require.Equal(t, tc.matched, m.Match(cert), "case %d", i)
+	}
+	// invalid config
+	for _, cfg := range []v2.SubjectAltNameMatcher{
+		{},
+		{Type: "email", Exact: "a@b.com"},
+		{Regex: "("},
+	} {
+		_, err := newSANMatcher(cfg)
+		require.NotNil(t, err)
+	}
+}
+
+func TestSpiffeID(t *testing.T) {
+	ca := newTestAuthority(t)
+	cert := parseCertPem(t, ca.issue(t, "a", "spiffe://Example.org/app").CertPem)
+	id, err := SpiffeID(cert)
+	require.Nil(t, err)
+	require.Equal(t, "spiffe://Example.org/app", id)
+	td, err := spiffeTrustDomain(cert)
+	require.Nil(t, err)
+	require.Equal(t, "example.org", td)
+	// more than one uri is not a valid SPIFFE ID
+	cert = parseCertPem(t, ca.issue(t, "a", "spiffe://example.org/app", "spiffe://example.org/b").CertPem)
+	_, err = SpiffeID(cert)
+	require.NotNil(t, err)
+	cert = parseCertPem(t, ca.issue(t, "a", "https://example.org/app").CertPem)
+	_, err = SpiffeID(cert)
+	require.NotNil(t, err)
+}
+
+func TestTrustDomainVerify(t *testing.T) {
+	caA := newTestAuthority(t)
+	caB := newTestAuthority(t)
+	serverCert := caA.issue(t, "server", "spiffe://a.org/server")
+	serverCfg := &v2.TLSConfig{
+		Status:            true,
+		CertChain:         serverCert.CertPem,
+		PrivateKey:        serverCert.KeyPem,
+		RequireClientCert: true,
+		TrustDomains: []v2.TrustDomain{
+			{Name: "a.org", CACert: caA.certPem},
+			{Name: "b.org", CACert: caB.certPem},
+		},
+		MatchSubjectAltNames: []v2.SubjectAltNameMatcher{
+			{Type: v2.SubjectAltNameURI, Suffix: "/client"},
+		},
+	}
+	server, err := newTLSContext(serverCfg, &SecretInfo{
+		Certificate: serverCfg.CertChain,
+		PrivateKey:  serverCfg.PrivateKey,
+	})
+	require.Nil(t, err)
+	newClient := func(cert *certtool.CertificateInfo, sans ...v2.SubjectAltNameMatcher) *tlsContext {
+		cfg := &v2.TLSConfig{
+			Status:               true,
+			CertChain:            cert.CertPem,
+			PrivateKey:           cert.KeyPem,
+			ServerName:           "not.matched.server.name",
+			TrustDomains:         []v2.TrustDomain{{Name: "a.org", CACert: caA.certPem}},
+			MatchSubjectAltNames: sans,
+		}
+		client, err := newTLSContext(cfg, &SecretInfo{
+			Certificate: cfg.CertChain,
+			PrivateKey:  cfg.PrivateKey,
+		})
+		require.Nil(t, err)
+		return client
+	}
+	// client in trust domain b.org is accepted, the server is verified by the matchers instead of server name
+	client := newClient(caB.issue(t, "client", "spiffe://b.org/client"), v2.SubjectAltNameMatcher{Exact: "spiffe://a.org/server"})
+	state, serverErr, clientErr := handshake(t, server, client)
+	require.Nil(t, serverErr)
+	require.Nil(t, clientErr)
+	require.Equal(t, "spiffe://b.org/client", PeerIdentity(state))
+	// client claims trust domain a.org but issued by the b.org roots
+	client = newClient(caB.issue(t, "client", "spiffe://a.org/client"))
+	_, serverErr, _ = handshake(t, server, client)
+	require.NotNil(t, serverErr)
+	// client subject alt names not matched
+	client = newClient(caA.issue(t, "client", "spiffe://a.org/other"))
+	_, serverErr, _ = handshake(t, server, client)
+	require.NotNil(t, serverErr)
+	// unknown trust domain
+	client = newClient(caA.issue(t, "client", "spiffe://c.org/client"))
+	_, serverErr, _ = handshake(t, server, client)
+	require.NotNil(t, serverErr)
+	// server subject alt names not matched by the client
+	client = newClient(caA.issue(t, "client", "spiffe://a.org/client"), v2.SubjectAltNameMatcher{Exact: "spiffe://a.org/other"})
+	_, _, clientErr = handshake(t, server, client)
+	require.NotNil(t, clientErr)
+}
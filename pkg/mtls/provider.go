@@ -37,7 +37,7 @@ func (p *staticProvider) Empty() bool {
 }
 
 // NewProvider returns a types.Provider.
-// we support sds provider and static provider.
+// we support sds provider, file provider and static provider.
 func NewProvider(index string, cfg *v2.TLSConfig) (types.TLSProvider, error) {
 	if !cfg.Status {
 		return nil, nil
@@ -54,10 +54,18 @@ func NewProvider(index string, cfg *v2.TLSConfig) (types.TLSProvider, error) {
 			PrivateKey:  cfg.PrivateKey,
 			Validation:  cfg.CACert,
 		}
+		// the files are read before the tls context is made, so the changes
+		// during making the tls context can be found in next check
+		files := newWatchedFiles(cfg, secret)
 		ctx, err := newTLSContext(cfg, secret)
 		if err != nil {
 			return nil, err
 		}
+		// the certificates from files can be reloaded without restart,
+		// the file providers are watched by the context manager.
+		if len(files) > 0 {
+			return newFileProvider(index, cfg, secret, ctx, files), nil
+		}
 		return &staticProvider{
 			tlsContext: ctx,
 		}, nil
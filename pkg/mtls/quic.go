@@ -0,0 +1,87 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mtls
+
+import (
+	stdtls "crypto/tls"
+	"errors"
+
+	"mosn.io/mosn/pkg/mtls/crypto/tls"
+	"mosn.io/mosn/pkg/types"
+)
+
+// ErrorNoQUICConfig represents the tls context manager can not be used by quic connections
+var ErrorNoQUICConfig = errors.New("tls context manager does not support quic")
+
+// QUICServerConfig returns a standard tls.Config that used by the quic listeners.
+// quic runs the tls handshake itself instead of wrapping a net.Conn, so the
+// certificates selection is bridged from the tls context manager in GetConfigForClient.
+// The alpn replaces the protocols configured, quic requires the application protocol negotiated
+func QUICServerConfig(mng types.TLSContextManager, alpn []string) (*stdtls.Config, error) {
+	smng, ok := mng.(*serverContextManager)
+	if !ok || !smng.Enabled() {
+		return nil, ErrorNoQUICConfig
+	}
+	return &stdtls.Config{
+		GetConfigForClient: func(info *stdtls.ClientHelloInfo) (*stdtls.Config, error) {
+			cfg, err := smng.GetConfigForClient(&tls.ClientHelloInfo{
+				ServerName:      info.ServerName,
+				SupportedProtos: info.SupportedProtos,
+			})
+			if err != nil {
+				return nil, err
+			}
+			return stdConfig(cfg, alpn), nil
+		},
+		NextProtos: alpn,
+		MinVersion: stdtls.VersionTLS13,
+	}, nil
+}
+
+// QUICClientConfig returns a standard tls.Config that used by the quic upstream connections
+func QUICClientConfig(mng types.TLSClientContextManager, alpn []string) (*stdtls.Config, error) {
+	cmng, ok := mng.(*clientContextManager)
+	if !ok || !cmng.Enabled() {
+		return nil, ErrorNoQUICConfig
+	}
+	return stdConfig(cmng.provider.GetTLSConfigContext(true).Config(), alpn), nil
+}
+
+// stdConfig copies the fields that used in tls 1.3 handshake
+func stdConfig(cfg *tls.Config, alpn []string) *stdtls.Config {
+	c := &stdtls.Config{
+		RootCAs:               cfg.RootCAs,
+		ClientCAs:             cfg.ClientCAs,
+		ClientAuth:            stdtls.ClientAuthType(cfg.ClientAuth),
+		ServerName:            cfg.ServerName,
+		InsecureSkipVerify:    cfg.InsecureSkipVerify,
+		VerifyPeerCertificate: cfg.VerifyPeerCertificate,
+		NextProtos:            alpn,
+		MinVersion:            stdtls.VersionTLS13,
+	}
+	for _, cert := range cfg.Certificates {
+		c.Certificates = append(c.Certificates, stdtls.Certificate{
+			Certificate:                 cert.Certificate,
+			PrivateKey:                  cert.PrivateKey,
+			OCSPStaple:                  cert.OCSPStaple,
+			SignedCertificateTimestamps: cert.SignedCertificateTimestamps,
+			Leaf:                        cert.Leaf,
+		})
+	}
+	return c
+}
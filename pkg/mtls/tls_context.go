@@ -82,6 +82,9 @@ type tlsContext struct {
 	matches    map[string]struct{}
 	config     *v2.TLSConfig
 	secret     *SecretInfo
+	verifier   *peerVerifier
+	revocation *revocationChecker
+	stapler    *ocspStapler
 	client     *types.TLSConfigContext
 	server     *types.TLSConfigContext
 }
@@ -122,6 +125,17 @@ func (ctx *tlsContext) SetServerConfig(tmpl *tls.Config, cfg *v2.TLSConfig, hook
 	}
 	tlsConfig.ClientAuth = hooks.GetClientAuth(cfg)
 	tlsConfig.VerifyPeerCertificate = hooks.ServerHandshakeVerify(tlsConfig)
+	if ctx.verifier != nil {
+		// the requested client certificate should be verified before matching the subject alt names and trust domains
+		switch tlsConfig.ClientAuth {
+		case tls.RequestClientCert, tls.RequireAnyClientCert:
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+		tlsConfig.VerifyPeerCertificate = ctx.verifier.ServerHandshakeVerify(tlsConfig.VerifyPeerCertificate)
+	}
+	if ctx.revocation != nil {
+		tlsConfig.VerifyPeerCertificate = ctx.revocation.HandshakeVerify(tlsConfig.VerifyPeerCertificate)
+	}
 
 	ctx.server = types.NewTLSConfigContext(tlsConfig, hooks.GenerateHashValue)
 	// build matches
@@ -129,6 +143,9 @@ func (ctx *tlsContext) SetServerConfig(tmpl *tls.Config, cfg *v2.TLSConfig, hook
 }
 
 func (ctx *tlsContext) GetServerTLSConfigContext() *types.TLSConfigContext {
+	if ctx.stapler != nil {
+		return ctx.stapler.GetTLSConfigContext()
+	}
 	return ctx.server
 }
 
@@ -136,6 +153,9 @@ func (ctx *tlsContext) SetClientConfig(tmpl *tls.Config, cfg *v2.TLSConfig, hook
 	tlsConfig := tmpl.Clone()
 	tlsConfig.ServerName = cfg.ServerName
 	tlsConfig.VerifyPeerCertificate = hooks.ClientHandshakeVerify(tlsConfig)
+	if ctx.verifier != nil {
+		tlsConfig.VerifyPeerCertificate = ctx.verifier.ClientHandshakeVerify(tlsConfig, tlsConfig.VerifyPeerCertificate)
+	}
 	if tlsConfig.VerifyPeerCertificate != nil {
 		// use self verify, skip normal verify
 		tlsConfig.InsecureSkipVerify = true
@@ -143,6 +163,9 @@ func (ctx *tlsContext) SetClientConfig(tmpl *tls.Config, cfg *v2.TLSConfig, hook
 	if cfg.InsecureSkip {
 		tlsConfig.InsecureSkipVerify = true
 		tlsConfig.VerifyPeerCertificate = nil
+	} else if ctx.revocation != nil {
+		// the revocation is checked after the normal verify
+		tlsConfig.VerifyPeerCertificate = ctx.revocation.HandshakeVerify(tlsConfig.VerifyPeerCertificate)
 	}
 	ctx.client = types.NewTLSConfigContext(tlsConfig, hooks.GenerateHashValue)
 }
@@ -196,7 +219,7 @@ func (ctx *tlsContext) GetTLSConfigContext(client bool) *types.TLSConfigContext
 		if ctx.server == nil {
 			return nil
 		}
-		return ctx.server
+		return ctx.GetServerTLSConfigContext()
 	}
 }
 
@@ -214,12 +237,28 @@ func newTLSContext(cfg *v2.TLSConfig, secret *SecretInfo) (*tlsContext, error) {
 	if err != nil {
 		return nil, err
 	}
+	// verify the peer by the subject alt names and trust domains
+	verifier, err := newPeerVerifier(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if verifier != nil && len(cfg.TrustDomains) > 0 {
+		if pool, err = verifier.rootCAs(secret.Validation); err != nil {
+			return nil, err
+		}
+	}
 	tmpl.RootCAs = pool
 	tmpl.ClientCAs = pool
+	revocation, err := newRevocationChecker(cfg, secret)
+	if err != nil {
+		return nil, err
+	}
 	// set tls context
 	ctx := &tlsContext{
 		serverName: cfg.ServerName,
 		ticket:     cfg.Ticket,
+		verifier:   verifier,
+		revocation: revocation,
 	}
 	cert, err := hooks.GetCertificate(secret.Certificate, secret.PrivateKey)
 	switch err {
@@ -244,6 +283,11 @@ func newTLSContext(cfg *v2.TLSConfig, secret *SecretInfo) (*tlsContext, error) {
 		ctx.SetServerConfig(tmpl, cfg, hooks)
 	}
 	ctx.SetClientConfig(tmpl, cfg, hooks)
+	if ctx.server != nil && cfg.OCSPStaple != nil {
+		if ctx.stapler, err = newOCSPStapler(cfg.OCSPStaple, secret, ctx.server.Config(), hooks.GenerateHashValue); err != nil {
+			return nil, err
+		}
+	}
 
 	for _, cb := range tlsContextCallback {
 		cb(ctx)
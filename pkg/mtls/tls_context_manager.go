@@ -51,9 +51,10 @@ func NewTLSServerContextManager(cfg *v2.Listener) (types.TLSContextManager, erro
 	mng.config = &tls.Config{
 		GetConfigForClient: mng.GetConfigForClient,
 	}
+	index := serverContextPrefix + cfg.Name
 	for _, c := range cfg.FilterChains {
 		for _, tlsCfg := range c.TLSContexts {
-			provider, err := NewProvider(serverContextPrefix+cfg.Name, &tlsCfg)
+			provider, err := NewProvider(index, &tlsCfg)
 			if err != nil {
 				return nil, err
 			}
@@ -71,6 +72,7 @@ func NewTLSServerContextManager(cfg *v2.Listener) (types.TLSContextManager, erro
 			}
 		}
 	}
+	watchFileProviders(index, mng.providers...)
 	return mng, nil
 }
 
@@ -105,7 +107,13 @@ func (mng *serverContextManager) GetConfigForClient(info *tls.ClientHelloInfo) (
 }
 
 func (mng *serverContextManager) Conn(c net.Conn) (net.Conn, error) {
-	if _, ok := c.(*net.TCPConn); !ok {
+	// a connection may be peeked by the listener filters already
+	conn, peeked := c.(*Conn)
+	if peeked {
+		if _, ok := conn.Conn.(*net.TCPConn); !ok {
+			return c, nil
+		}
+	} else if _, ok := c.(*net.TCPConn); !ok {
 		return c, nil
 	}
 	if !mng.Enabled() {
@@ -117,8 +125,10 @@ func (mng *serverContextManager) Conn(c net.Conn) (net.Conn, error) {
 		}, nil
 	}
 	// inspector
-	conn := &Conn{
-		Conn: c,
+	if !peeked {
+		conn = &Conn{
+			Conn: c,
+		}
 	}
 	buf, err := conn.Peek()
 	if err != nil {
@@ -154,10 +164,12 @@ type clientContextManager struct {
 
 // NewTLSClientContextManager returns a types.TLSContextManager used in TLS Client
 func NewTLSClientContextManager(name string, cfg *v2.TLSConfig) (types.TLSClientContextManager, error) {
-	provider, err := NewProvider(clientContextPrefix+name, cfg)
+	index := clientContextPrefix + name
+	provider, err := NewProvider(index, cfg)
 	if err != nil {
 		return nil, err
 	}
+	watchFileProviders(index, provider)
 	mng := &clientContextManager{
 		provider: provider,
 		fallback: cfg.Fallback,
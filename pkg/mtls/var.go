@@ -0,0 +1,72 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mtls
+
+import (
+	"context"
+	gotls "crypto/tls"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/variable"
+)
+
+func init() {
+	variable.Register(variable.NewStringVariable(types.VarDownstreamPeerIdentity, nil, downstreamPeerIdentityGetter, nil, 0))
+}
+
+// downstreamPeerIdentityGetter
+// get the identity of the verified downstream peer certificate
+func downstreamPeerIdentityGetter(ctx context.Context, value *variable.IndexedValue, data interface{}) (string, error) {
+	v, err := variable.Get(ctx, types.VariableConnection)
+	if err != nil {
+		return variable.ValueNotFound, variable.ErrValueNotFound
+	}
+	conn, ok := v.(api.Connection)
+	if !ok {
+		return variable.ValueNotFound, variable.ErrValueNotFound
+	}
+	tlsConn, ok := conn.RawConn().(*TLSConn)
+	if !ok {
+		return variable.ValueNotFound, variable.ErrValueNotFound
+	}
+	if id := PeerIdentity(tlsConn.ConnectionState()); id != "" {
+		return id, nil
+	}
+	return variable.ValueNotFound, variable.ErrValueNotFound
+}
+
+// PeerIdentity returns the identity of the verified peer certificate.
+// the SPIFFE ID is preferred, then the first URI SAN, the first DNS SAN and the common name.
+// an empty string is returned if the peer certificate is not verified.
+func PeerIdentity(state gotls.ConnectionState) string {
+	if len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	leaf := state.VerifiedChains[0][0]
+	if id, err := SpiffeID(leaf); err == nil {
+		return id
+	}
+	if len(leaf.URIs) > 0 {
+		return leaf.URIs[0].String()
+	}
+	if len(leaf.DNSNames) > 0 {
+		return leaf.DNSNames[0]
+	}
+	return leaf.Subject.CommonName
+}
@@ -51,6 +51,20 @@ func RegisterProtocol(name api.ProtocolName, newPool types.NewConnPool, streamFa
 	return nil
 }
 
+// RegisterConnPool registers a connection pool factory without the protocol stream, the network filters
+// that encode and decode the upstream data themselves get the connections from the cluster connection pools
+// of the protocol. The protocol can not be used as the stream protocol of the listeners.
+func RegisterConnPool(name api.ProtocolName, newPool types.NewConnPool) error {
+	if _, ok := registry.ConnNewPoolFactories[name]; ok {
+		return ErrDuplicateProtocol
+	}
+	if newPool == nil {
+		return ErrInvalidParameters
+	}
+	registry.RegisterNewPoolFactory(name, newPool)
+	return nil
+}
+
 // The api for get the registered info
 var (
 	ErrNoMapping         = errors.New("no mapping function found")
@@ -71,6 +85,14 @@ func RangeAllRegisteredProtocol(f func(name api.ProtocolName)) {
 	}
 }
 
+// RangeAllRegisteredConnPool ranges the protocols that have the connection pool factories,
+// including the protocols registered by RegisterConnPool
+func RangeAllRegisteredConnPool(f func(name api.ProtocolName)) {
+	for proto := range registry.ConnNewPoolFactories {
+		f(proto)
+	}
+}
+
 func MappingHeaderStatusCode(ctx context.Context, p api.ProtocolName, headers api.HeaderMap) (int, error) {
 	if f, ok := registry.HttpMappingFactory[p]; ok {
 		return f.MappingHeaderStatusCode(ctx, headers)
@@ -91,6 +91,31 @@ func TestRegisterProtocolWithOutMapping(t *testing.T) {
 	require.ErrorIs(t, err, ErrNoMapping)
 }
 
+func TestRegisterConnPool(t *testing.T) {
+	reset()
+	newPool := func(ctx context.Context, host types.Host) types.ConnectionPool {
+		return nil
+	}
+	RegisterProtocol(api.ProtocolName("testprotocol"), newPool, &mockProtocolStreamFactory{}, nil)
+	name := api.ProtocolName("testpool")
+	require.Nil(t, RegisterConnPool(name, newPool))
+	require.ErrorIs(t, RegisterConnPool(name, newPool), ErrDuplicateProtocol)
+	require.ErrorIs(t, RegisterConnPool(api.ProtocolName("testpool2"), nil), ErrInvalidParameters)
+
+	// the protocol has no stream factory
+	require.False(t, ProtocolRegistered(name))
+	_, ok := GetNewPoolFactory(name)
+	require.True(t, ok)
+	_, ok = GetProtocolStreamFactory(name)
+	require.False(t, ok)
+
+	var protos []api.ProtocolName
+	RangeAllRegisteredConnPool(func(proto api.ProtocolName) {
+		protos = append(protos, proto)
+	})
+	require.ElementsMatch(t, []api.ProtocolName{"testprotocol", name}, protos)
+}
+
 func reset() {
 	protocolsSupported = map[api.ProtocolName]struct{}{
 		Auto: struct{}{}, // reserved protocol, support for Auto protocol config parsed
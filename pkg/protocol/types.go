@@ -32,6 +32,7 @@ const (
 	Auto  api.ProtocolName = "Auto"
 	HTTP1 api.ProtocolName = "Http1" // TODO: move it to protocol/HTTP
 	HTTP2 api.ProtocolName = "Http2" // TODO: move it to protocol/HTTP2
+	HTTP3 api.ProtocolName = "Http3"
 
 )
 
@@ -64,6 +64,8 @@ type Request struct {
 	Content api.IoBuffer // wrapper of raw content
 
 	ContentChanged bool // indicate that content changed
+
+	arguments *arguments // the arguments decoded from the content lazily
 }
 
 var _ api.XFrame = &Request{}
@@ -113,6 +115,7 @@ func (r *Request) SetData(data api.IoBuffer) {
 	if r.Content != data {
 		r.ContentChanged = true
 		r.Content = data
+		r.arguments = nil
 	}
 }
 
@@ -33,10 +33,20 @@ func init() {
 
 type Config struct {
 	EnableBoltGoAway bool `json:"enable_bolt_goaway,omitempty"`
+	// DecodePayload enables decoding the arguments in the content of the requests, which are
+	// decoded lazily when the bolt_request_arg_ variables are used.
+	DecodePayload bool `json:"decode_payload,omitempty"`
+	// MaxPayloadSize is the max size of the content to be decoded, the larger content is not decoded.
+	MaxPayloadSize int `json:"max_payload_size,omitempty"`
 }
 
+// defaultMaxPayloadSize is the default max size of the content to be decoded
+const defaultMaxPayloadSize = 64 * 1024
+
 var defaultConfig = Config{
 	EnableBoltGoAway: false,
+	DecodePayload:    false,
+	MaxPayloadSize:   defaultMaxPayloadSize,
 }
 
 func ConfigHandler(v interface{}) interface{} {
@@ -0,0 +1,161 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bolt
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"mosn.io/mosn/pkg/protocol/xprotocol/generic"
+)
+
+var errPayloadTooLarge = errors.New("the content is too large to be decoded")
+
+// arguments are the arguments decoded from the content of the request
+type arguments struct {
+	// values are the hessian2 arguments
+	values *generic.Values
+	// message is the only argument of the protobuf codec
+	message []byte
+	err     error
+}
+
+// getArguments decodes the arguments of the request when it is called at the first time
+func (r *Request) getArguments(maxSize int) *arguments {
+	if r.arguments != nil {
+		return r.arguments
+	}
+	if maxSize <= 0 {
+		maxSize = defaultMaxPayloadSize
+	}
+	args := &arguments{}
+	var content []byte
+	if r.Content != nil {
+		content = r.Content.Bytes()
+	}
+	switch {
+	case len(content) == 0:
+		args.err = generic.ErrValueNotFound
+	case len(content) > maxSize:
+		args.err = errPayloadTooLarge
+	case r.Codec == Hessian2Serialize:
+		inv, err := generic.DecodeSofaRequest(content)
+		if err != nil {
+			args.err = fmt.Errorf("decode the hessian2 content failed: %v", err)
+		} else {
+			args.values = inv.Args
+		}
+	case r.Codec == ProtobufSerialize:
+		args.message = content
+	default:
+		args.err = fmt.Errorf("the codec %d is not supported", r.Codec)
+	}
+	r.arguments = args
+	return args
+}
+
+// lookup finds the value of the path in the index-th argument
+func (args *arguments) lookup(index int, path []string) (string, error) {
+	if args.err != nil {
+		return "", args.err
+	}
+	if args.values != nil {
+		return args.values.Lookup(index, path)
+	}
+	if index != 0 {
+		return "", generic.ErrValueNotFound
+	}
+	return lookupProtobuf(args.message, path)
+}
+
+// lookupProtobuf finds the value of the path in the protobuf message without the descriptor, the
+// path consists of the field numbers. The varint is formatted as int64, the fixed32 and fixed64
+// are formatted as unsigned integers, and the bytes are returned as a string. The last one is
+// used if the field is repeated.
+func lookupProtobuf(message []byte, path []string) (string, error) {
+	if len(path) == 0 {
+		return "", errors.New("the protobuf message is not a scalar")
+	}
+	num, err := strconv.Atoi(path[0])
+	if err != nil || num <= 0 {
+		return "", generic.ErrValueNotFound
+	}
+	var (
+		found bool
+		typ   protowire.Type
+		value string
+		raw   []byte
+		n     int
+	)
+	for len(message) > 0 {
+		fieldNum, fieldType, l := protowire.ConsumeTag(message)
+		if l < 0 {
+			return "", protowire.ParseError(l)
+		}
+		message = message[l:]
+		if int(fieldNum) != num {
+			if l = protowire.ConsumeFieldValue(fieldNum, fieldType, message); l < 0 {
+				return "", protowire.ParseError(l)
+			}
+			message = message[l:]
+			continue
+		}
+		found, typ = true, fieldType
+		switch fieldType {
+		case protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(message)
+			value = strconv.FormatInt(int64(v), 10)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(message)
+			value = strconv.FormatUint(uint64(v), 10)
+		case protowire.Fixed64Type:
+			var v uint64
+			v, n = protowire.ConsumeFixed64(message)
+			value = strconv.FormatUint(v, 10)
+		case protowire.BytesType:
+			raw, n = protowire.ConsumeBytes(message)
+		default:
+			n = protowire.ConsumeFieldValue(fieldNum, fieldType, message)
+		}
+		if n < 0 {
+			return "", protowire.ParseError(n)
+		}
+		message = message[n:]
+	}
+	if !found {
+		return "", generic.ErrValueNotFound
+	}
+	if len(path) > 1 {
+		if typ != protowire.BytesType {
+			return "", generic.ErrValueNotFound
+		}
+		return lookupProtobuf(raw, path[1:])
+	}
+	switch typ {
+	case protowire.BytesType:
+		return string(raw), nil
+	case protowire.StartGroupType:
+		return "", errors.New("the protobuf group is not a scalar")
+	}
+	return value, nil
+}
@@ -41,6 +41,7 @@ const (
 	CmdCodeGoAway      uint16 = 100 // cmd control code, increasing from 100
 
 	Hessian2Serialize byte = 1 // serialize
+	ProtobufSerialize byte = 11
 
 	ResponseStatusSuccess                 uint16 = 0  // 0x00 response status
 	ResponseStatusError                   uint16 = 1  // 0x01
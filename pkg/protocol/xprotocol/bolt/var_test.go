@@ -0,0 +1,139 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bolt
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/variable"
+
+	"mosn.io/mosn/pkg/protocol/xprotocol/generic"
+	"mosn.io/mosn/pkg/types"
+)
+
+func newArgContext(request *Request, config Config) context.Context {
+	ctx := variable.NewVariableContext(context.Background())
+	_ = variable.Set(ctx, types.VariableDownStreamProtocol, ProtocolName)
+	_ = variable.Set(ctx, types.VariableDownStreamReqHeaders, request)
+	_ = variable.Set(ctx, types.VariableProxyGeneralConfig, map[api.ProtocolName]interface{}{
+		ProtocolName: config,
+	})
+	return ctx
+}
+
+func TestRequestArgVariable(t *testing.T) {
+	encoder := hessian.NewEncoder()
+	require.Nil(t, encoder.Encode("uid-1"))
+	require.Nil(t, encoder.Encode(map[interface{}]interface{}{"region": "hz"}))
+	args, err := generic.NewValues(encoder.Buffer())
+	require.Nil(t, err)
+	content, err := generic.EncodeSofaRequest(&generic.Invocation{
+		Service:  "com.example.UserService",
+		Version:  "1.0",
+		Method:   "getUser",
+		ArgTypes: []string{"java.lang.String", "java.util.Map"},
+		Args:     args,
+	}, "app")
+	require.Nil(t, err)
+
+	request := NewRpcRequest(1, nil, buffer.NewIoBufferBytes(content))
+	ctx := newArgContext(request, Config{DecodePayload: true})
+
+	v, err := variable.GetString(ctx, "bolt_request_arg_0")
+	assert.Nil(t, err)
+	assert.Equal(t, "uid-1", v)
+	v, err = variable.GetProtocolResource(ctx, api.ARG, "1.region")
+	assert.Nil(t, err)
+	assert.Equal(t, "hz", v)
+	for _, name := range []string{"bolt_request_arg_2", "bolt_request_arg_1.zone", "bolt_request_arg_x"} {
+		_, err = variable.GetString(ctx, name)
+		assert.NotNil(t, err, name)
+	}
+
+	// the decoded arguments are cached until the content changes
+	assert.NotNil(t, request.arguments)
+	request.SetData(buffer.NewIoBufferString("changed"))
+	assert.Nil(t, request.arguments)
+
+	// the payload decoding is disabled
+	request = NewRpcRequest(1, nil, buffer.NewIoBufferBytes(content))
+	ctx = newArgContext(request, defaultConfig)
+	_, err = variable.GetString(ctx, "bolt_request_arg_0")
+	assert.NotNil(t, err)
+	assert.Nil(t, request.arguments)
+
+	// the content is too large
+	ctx = newArgContext(request, Config{DecodePayload: true, MaxPayloadSize: len(content) - 1})
+	_, err = variable.GetString(ctx, "bolt_request_arg_0")
+	assert.NotNil(t, err)
+	assert.Equal(t, errPayloadTooLarge, request.arguments.err)
+}
+
+func TestRequestProtobufArgVariable(t *testing.T) {
+	var user []byte
+	user = protowire.AppendTag(user, 1, protowire.BytesType)
+	user = protowire.AppendString(user, "alice")
+	user = protowire.AppendTag(user, 2, protowire.VarintType)
+	user = protowire.AppendVarint(user, uint64(30))
+
+	var message []byte
+	message = protowire.AppendTag(message, 1, protowire.VarintType)
+	message = protowire.AppendVarint(message, uint64(1001))
+	message = protowire.AppendTag(message, 2, protowire.BytesType)
+	message = protowire.AppendBytes(message, user)
+	message = protowire.AppendTag(message, 3, protowire.Fixed32Type)
+	message = protowire.AppendFixed32(message, 7)
+	message = protowire.AppendTag(message, 4, protowire.VarintType)
+	// -1 of the int64 is encoded as the max uint64
+	message = protowire.AppendVarint(message, math.MaxUint64)
+	// the last one is used for the repeated field
+	message = protowire.AppendTag(message, 1, protowire.VarintType)
+	message = protowire.AppendVarint(message, uint64(1002))
+
+	request := NewRpcRequest(1, nil, buffer.NewIoBufferBytes(message))
+	request.Codec = ProtobufSerialize
+	ctx := newArgContext(request, Config{DecodePayload: true})
+
+	for name, expected := range map[string]string{
+		"bolt_request_arg_0.1":   "1002",
+		"bolt_request_arg_0.2.1": "alice",
+		"bolt_request_arg_0.2.2": "30",
+		"bolt_request_arg_0.3":   "7",
+		"bolt_request_arg_0.4":   "-1",
+	} {
+		v, err := variable.GetString(ctx, name)
+		assert.Nil(t, err, name)
+		assert.Equal(t, expected, v, name)
+	}
+	for _, name := range []string{"bolt_request_arg_0", "bolt_request_arg_1.1", "bolt_request_arg_0.5", "bolt_request_arg_0.1.1"} {
+		_, err := variable.GetString(ctx, name)
+		assert.NotNil(t, err, name)
+	}
+
+	// the message is truncated
+	_, err := lookupProtobuf(message[:len(message)-1], []string{"1"})
+	assert.NotNil(t, err)
+}
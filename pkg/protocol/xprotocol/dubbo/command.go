@@ -46,6 +46,8 @@ type Frame struct {
 
 	data    types.IoBuffer // wrapper of data
 	content types.IoBuffer // wrapper of payload
+
+	arguments *arguments // the arguments decoded from the payload lazily
 }
 
 var _ api.XFrame = &Frame{}
@@ -92,6 +94,7 @@ func (r *Frame) SetData(data types.IoBuffer) {
 	r.content = data
 	r.payload = data.Bytes()
 	r.DataLen = uint32(data.Len())
+	r.arguments = nil
 }
 
 func (r *Frame) GetStatusCode() uint32 {
@@ -0,0 +1,86 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubbo
+
+import (
+	"context"
+	"encoding/json"
+
+	"mosn.io/api"
+	"mosn.io/pkg/variable"
+
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+func init() {
+	protocol.RegisterProtocolConfigHandler(ProtocolName, ConfigHandler)
+}
+
+type Config struct {
+	// DecodePayload enables decoding the arguments in the body of the requests, which are
+	// decoded lazily when the dubbo_request_arg_ variables are used.
+	DecodePayload bool `json:"decode_payload,omitempty"`
+	// MaxPayloadSize is the max size of the body to be decoded, the larger body is not decoded.
+	MaxPayloadSize int `json:"max_payload_size,omitempty"`
+}
+
+// defaultMaxPayloadSize is the default max size of the body to be decoded
+const defaultMaxPayloadSize = 64 * 1024
+
+var defaultConfig = Config{
+	DecodePayload:  false,
+	MaxPayloadSize: defaultMaxPayloadSize,
+}
+
+func ConfigHandler(v interface{}) interface{} {
+	extendConfig, ok := v.(map[string]interface{})
+	if !ok {
+		return defaultConfig
+	}
+
+	tmpConfig, ok := extendConfig[string(ProtocolName)]
+	if !ok {
+		tmpConfig = extendConfig
+	}
+	tmpConfigBytes, err := json.Marshal(tmpConfig)
+	if err != nil {
+		return defaultConfig
+	}
+	config := defaultConfig
+	if err := json.Unmarshal(tmpConfigBytes, &config); err != nil {
+		return defaultConfig
+	}
+
+	return config
+}
+
+func parseConfig(ctx context.Context) Config {
+	config := defaultConfig
+	// get extend config from ctx
+	if pgc, err := variable.Get(ctx, types.VariableProxyGeneralConfig); err == nil && pgc != nil {
+		if extendConfig, ok := pgc.(map[api.ProtocolName]interface{}); ok {
+			if dubboConfig, ok := extendConfig[ProtocolName]; ok {
+				if cfg, ok := dubboConfig.(Config); ok {
+					config = cfg
+				}
+			}
+		}
+	}
+	return config
+}
@@ -0,0 +1,69 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubbo
+
+import (
+	"errors"
+	"fmt"
+
+	"mosn.io/mosn/pkg/protocol/xprotocol/generic"
+)
+
+var errPayloadTooLarge = errors.New("the payload is too large to be decoded")
+
+// arguments are the arguments decoded from the payload of the request
+type arguments struct {
+	values *generic.Values
+	err    error
+}
+
+// getArguments decodes the arguments of the request when it is called at the first time
+func (r *Frame) getArguments(maxSize int) *arguments {
+	if r.arguments != nil {
+		return r.arguments
+	}
+	if maxSize <= 0 {
+		maxSize = defaultMaxPayloadSize
+	}
+	args := &arguments{}
+	switch {
+	case r.Direction != EventRequest || r.IsEvent || len(r.payload) == 0:
+		args.err = generic.ErrValueNotFound
+	case len(r.payload) > maxSize:
+		args.err = errPayloadTooLarge
+	case r.SerializationId != 2:
+		args.err = fmt.Errorf("the serialization %d is not supported", r.SerializationId)
+	default:
+		inv, err := generic.DecodeDubboRequest(r.payload)
+		if err != nil {
+			args.err = fmt.Errorf("decode the hessian2 payload failed: %v", err)
+		} else {
+			args.values = inv.Args
+		}
+	}
+	r.arguments = args
+	return args
+}
+
+// lookup finds the value of the path in the index-th argument
+func (args *arguments) lookup(index int, path []string) (string, error) {
+	if args.err != nil {
+		return "", args.err
+	}
+	return args.values.Lookup(index, path)
+}
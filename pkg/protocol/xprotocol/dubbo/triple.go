@@ -0,0 +1,101 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubbo
+
+import (
+	"context"
+	"strings"
+
+	"mosn.io/api"
+	"mosn.io/pkg/variable"
+
+	"mosn.io/mosn/pkg/types"
+)
+
+// Dubbo 3 Triple is compatible with gRPC over HTTP/2, the request path is /{interface}/{method},
+// and the version and group are sent in the headers.
+const (
+	TripleServiceVersionHeader = "tri-service-version"
+	TripleServiceGroupHeader   = "tri-service-group"
+	TripleContentType          = "application/grpc+proto"
+
+	contentTypeHeader     = "content-type"
+	grpcContentTypePrefix = "application/grpc"
+)
+
+// IsTripleRequest checks whether the HTTP/2 request is a Triple request
+func IsTripleRequest(path string, headers api.HeaderMap) bool {
+	contentType, _ := headers.Get(contentTypeHeader)
+	if !strings.HasPrefix(contentType, grpcContentTypePrefix) {
+		return false
+	}
+	_, _, ok := ParseTriplePath(path)
+	return ok
+}
+
+// ParseTriplePath returns the interface and method of the Triple request path
+func ParseTriplePath(path string) (string, string, bool) {
+	i := strings.LastIndexByte(path, '/')
+	if i <= 0 || i == len(path)-1 {
+		return "", "", false
+	}
+	method := path[i+1:]
+	iface := path[strings.LastIndexByte(path[:i], '/')+1 : i]
+	if iface == "" {
+		return "", "", false
+	}
+	return iface, method, true
+}
+
+// GetTripleServiceAwareMeta returns the service aware meta of the Triple request, with the same keys
+// as the dubbo frame headers. The pub or sub metadata is used first like the dubbo frame,
+// and the Triple headers are used if the metadata is not matched.
+func GetTripleServiceAwareMeta(ctx context.Context, path string, headers api.HeaderMap) (map[string]string, bool) {
+	iface, method, ok := ParseTriplePath(path)
+	if !ok {
+		return nil, false
+	}
+	version, _ := headers.Get(TripleServiceVersionHeader)
+	group, _ := headers.Get(TripleServiceGroupHeader)
+	meta := map[string]string{
+		ServiceNameHeader:   iface,
+		InterfaceNameHeader: iface,
+		MethodNameHeader:    method,
+		VersionNameHeader:   version,
+		GroupNameHeader:     group,
+	}
+
+	var listener interface{}
+	if ctx != nil {
+		listener, _ = variable.Get(ctx, types.VariableListenerName)
+	}
+	var (
+		node    *Node
+		matched bool
+	)
+	if listener == IngressDubbo {
+		node, matched = DubboPubMetadata.Find(iface, version)
+	} else if listener == EgressDubbo {
+		node, matched = DubboSubMetadata.Find(iface, version)
+	}
+	if matched {
+		meta[ServiceNameHeader] = node.Service
+		meta[GroupNameHeader] = node.Group
+	}
+	return meta, true
+}
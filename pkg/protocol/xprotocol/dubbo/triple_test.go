@@ -0,0 +1,88 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubbo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"mosn.io/mosn/pkg/protocol"
+)
+
+func TestParseTriplePath(t *testing.T) {
+	for path, expected := range map[string][2]string{
+		"/org.apache.dubbo.Greeter/sayHello":     {"org.apache.dubbo.Greeter", "sayHello"},
+		"/ctx/org.apache.dubbo.Greeter/sayHello": {"org.apache.dubbo.Greeter", "sayHello"},
+	} {
+		iface, method, ok := ParseTriplePath(path)
+		assert.True(t, ok, path)
+		assert.Equal(t, expected[0], iface)
+		assert.Equal(t, expected[1], method)
+	}
+	for _, path := range []string{"", "/", "/sayHello", "//sayHello", "/org.apache.dubbo.Greeter/"} {
+		_, _, ok := ParseTriplePath(path)
+		assert.False(t, ok, path)
+	}
+}
+
+func TestIsTripleRequest(t *testing.T) {
+	headers := protocol.CommonHeader{"content-type": "application/grpc+proto"}
+	assert.True(t, IsTripleRequest("/org.apache.dubbo.Greeter/sayHello", headers))
+	assert.False(t, IsTripleRequest("/sayHello", headers))
+	assert.False(t, IsTripleRequest("/org.apache.dubbo.Greeter/sayHello", protocol.CommonHeader{"content-type": "application/json"}))
+}
+
+func TestGetTripleServiceAwareMeta(t *testing.T) {
+	defer DubboPubMetadata.Clear()
+
+	path := "/org.apache.dubbo.Greeter/sayHello"
+	headers := protocol.CommonHeader{
+		"content-type":             "application/grpc",
+		TripleServiceVersionHeader: "1.0.0",
+		TripleServiceGroupHeader:   "blue",
+	}
+	meta, ok := GetTripleServiceAwareMeta(context.Background(), path, headers)
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{
+		ServiceNameHeader:   "org.apache.dubbo.Greeter",
+		InterfaceNameHeader: "org.apache.dubbo.Greeter",
+		MethodNameHeader:    "sayHello",
+		VersionNameHeader:   "1.0.0",
+		GroupNameHeader:     "blue",
+	}, meta)
+
+	// the pub metadata is used on the ingress listener
+	DubboPubMetadata.Register("org.apache.dubbo.Greeter", &Node{
+		Service: "org.apache.dubbo.GreeterImpl",
+		Version: "1.0.0",
+		Group:   "green",
+	})
+	meta, ok = GetTripleServiceAwareMeta(newContextWithListenerName(IngressDubbo), path, headers)
+	assert.True(t, ok)
+	assert.Equal(t, "org.apache.dubbo.GreeterImpl", meta[ServiceNameHeader])
+	assert.Equal(t, "green", meta[GroupNameHeader])
+
+	// the sub metadata is not registered
+	meta, _ = GetTripleServiceAwareMeta(newContextWithListenerName(EgressDubbo), path, headers)
+	assert.Equal(t, "blue", meta[GroupNameHeader])
+
+	_, ok = GetTripleServiceAwareMeta(context.Background(), "/sayHello", headers)
+	assert.False(t, ok)
+}
@@ -0,0 +1,72 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubbo
+
+import (
+	"context"
+
+	"mosn.io/api"
+	"mosn.io/pkg/variable"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol/xprotocol/generic"
+	"mosn.io/mosn/pkg/types"
+)
+
+// VarPrefixRequestArg is the prefix of the variables of the request arguments, which is followed by
+// the index of the argument and the path in it, such as dubbo_request_arg_0.user.name
+const VarPrefixRequestArg = string(ProtocolName) + "_" + types.VarProtocolRequestArgPrefix
+
+var argIndex = len(VarPrefixRequestArg)
+
+func init() {
+	variable.RegisterPrefix(VarPrefixRequestArg, variable.NewStringVariable(VarPrefixRequestArg, nil, requestArgGetter, nil, 0))
+	variable.RegisterProtocolResource(ProtocolName, api.ARG, types.VarProtocolRequestArgPrefix)
+}
+
+// requestArgGetter decodes the arguments of the request if the payload decoding is enabled
+func requestArgGetter(ctx context.Context, value *variable.IndexedValue, data interface{}) (string, error) {
+	name, ok := data.(string)
+	if !ok {
+		return variable.ValueNotFound, variable.ErrValueNotFound
+	}
+	config := parseConfig(ctx)
+	if !config.DecodePayload {
+		return variable.ValueNotFound, variable.ErrValueNotFound
+	}
+	hv, err := variable.Get(ctx, types.VariableDownStreamReqHeaders)
+	if err != nil {
+		return variable.ValueNotFound, variable.ErrValueNotFound
+	}
+	frame, ok := hv.(*Frame)
+	if !ok {
+		return variable.ValueNotFound, variable.ErrValueNotFound
+	}
+	index, path, err := generic.ParseArgumentPath(name[argIndex:])
+	if err != nil {
+		return variable.ValueNotFound, variable.ErrValueNotFound
+	}
+	v, err := frame.getArguments(config.MaxPayloadSize).lookup(index, path)
+	if err != nil {
+		if err != generic.ErrValueNotFound && log.Proxy.GetLogLevel() >= log.DEBUG {
+			log.Proxy.Debugf(ctx, "[protocol][dubbo] get the argument %s failed: %v", name, err)
+		}
+		return variable.ValueNotFound, variable.ErrValueNotFound
+	}
+	return v, nil
+}
@@ -0,0 +1,96 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubbo
+
+import (
+	"context"
+	"testing"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+	"mosn.io/pkg/variable"
+
+	"mosn.io/mosn/pkg/protocol/xprotocol/generic"
+	"mosn.io/mosn/pkg/types"
+)
+
+func TestRequestArgVariable(t *testing.T) {
+	encoder := hessian.NewEncoder()
+	require.Nil(t, encoder.Encode(int64(1001)))
+	require.Nil(t, encoder.Encode([]string{"a", "b"}))
+	args, err := generic.NewValues(encoder.Buffer())
+	require.Nil(t, err)
+	body, err := generic.EncodeDubboRequest(&generic.Invocation{
+		Service:  "com.example.UserService",
+		Version:  "1.0",
+		Method:   "getUser",
+		ArgTypes: []string{"long", "java.util.List"},
+		Args:     args,
+	})
+	require.Nil(t, err)
+
+	frame := &Frame{
+		Header: Header{
+			Direction:       EventRequest,
+			SerializationId: 2,
+		},
+		payload: body,
+	}
+	newContext := func(config Config) context.Context {
+		ctx := variable.NewVariableContext(context.Background())
+		_ = variable.Set(ctx, types.VariableDownStreamProtocol, ProtocolName)
+		_ = variable.Set(ctx, types.VariableDownStreamReqHeaders, frame)
+		_ = variable.Set(ctx, types.VariableProxyGeneralConfig, map[api.ProtocolName]interface{}{
+			ProtocolName: config,
+		})
+		return ctx
+	}
+
+	// the payload decoding is disabled by default
+	ctx := newContext(ConfigHandler(nil).(Config))
+	_, err = variable.GetString(ctx, "dubbo_request_arg_0")
+	assert.NotNil(t, err)
+
+	ctx = newContext(ConfigHandler(map[string]interface{}{
+		"dubbo": map[string]interface{}{"decode_payload": true},
+	}).(Config))
+	v, err := variable.GetString(ctx, "dubbo_request_arg_0")
+	assert.Nil(t, err)
+	assert.Equal(t, "1001", v)
+	v, err = variable.GetProtocolResource(ctx, api.ARG, "1.1")
+	assert.Nil(t, err)
+	assert.Equal(t, "b", v)
+	_, err = variable.GetString(ctx, "dubbo_request_arg_1.2")
+	assert.NotNil(t, err)
+
+	// the payload is too large
+	frame.arguments = nil
+	ctx = newContext(Config{DecodePayload: true, MaxPayloadSize: len(body) - 1})
+	_, err = variable.GetString(ctx, "dubbo_request_arg_0")
+	assert.NotNil(t, err)
+	assert.Equal(t, errPayloadTooLarge, frame.arguments.err)
+
+	// the serialization is not hessian2
+	frame.arguments = nil
+	frame.SerializationId = 6
+	ctx = newContext(Config{DecodePayload: true})
+	_, err = variable.GetString(ctx, "dubbo_request_arg_0")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,262 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package generic
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DubboVersion is the dubbo protocol version of the encoded requests, which supports the attachments in the responses
+const DubboVersion = "2.0.2"
+
+// the attachments which are the fields of the invocation
+const (
+	attachmentPath      = "path"
+	attachmentInterface = "interface"
+	attachmentVersion   = "version"
+	attachmentGroup     = "group"
+)
+
+// GenericMethod is the method of the dubbo generic service, which is $invoke(String method, String[] parameterTypes, Object[] args)
+const GenericMethod = "$invoke"
+
+// genericArgTypes are the argument types of the generic method
+var genericArgTypes = []string{"java.lang.String", "[Ljava.lang.String;", "[Ljava.lang.Object;"}
+
+// the flags of the dubbo response body
+const (
+	dubboResponseException                = 0
+	dubboResponseValue                    = 1
+	dubboResponseNull                     = 2
+	dubboResponseExceptionWithAttachments = 3
+	dubboResponseValueWithAttachments     = 4
+	dubboResponseNullWithAttachments      = 5
+)
+
+// decodeAttachments decodes the string entries of a hessian map
+func decodeAttachments(raw []byte) (map[string]string, error) {
+	v, err := DecodeValue(raw)
+	if err != nil {
+		return nil, err
+	}
+	attachments := map[string]string{}
+	if m, ok := v.(map[interface{}]interface{}); ok {
+		for k, v := range m {
+			key, ok1 := k.(string)
+			val, ok2 := v.(string)
+			if ok1 && ok2 {
+				attachments[key] = val
+			}
+		}
+	}
+	return attachments, nil
+}
+
+// DecodeDubboRequest decodes the dubbo request body: dubbo version, path, version, method, argument
+// types, arguments and attachments. The interface, version and group in the attachments take precedence.
+func DecodeDubboRequest(body []byte) (*Invocation, error) {
+	s := newHessianScanner(body)
+	var fields [5]string
+	for i := range fields {
+		raw, err := s.nextStandalone()
+		if err != nil {
+			return nil, err
+		}
+		if fields[i], err = decodeString(raw); err != nil {
+			return nil, err
+		}
+	}
+	inv := &Invocation{
+		Service: fields[1],
+		Version: fields[2],
+		Method:  fields[3],
+	}
+	argTypes, err := ParseArgumentTypes(fields[4])
+	if err != nil {
+		return nil, err
+	}
+	inv.ArgTypes = argTypes
+	if inv.Args, err = s.values(len(argTypes)); err != nil {
+		return nil, fmt.Errorf("scan arguments failed: %v", err)
+	}
+
+	inv.Attachments = map[string]string{}
+	if len(s.remaining()) > 0 {
+		raw, err := s.nextStandalone()
+		if err != nil {
+			return nil, fmt.Errorf("scan attachments failed: %v", err)
+		}
+		if inv.Attachments, err = decodeAttachments(raw); err != nil {
+			return nil, fmt.Errorf("decode attachments failed: %v", err)
+		}
+	}
+	if v := inv.Attachments[attachmentInterface]; v != "" {
+		inv.Service = v
+	}
+	if v := inv.Attachments[attachmentVersion]; v != "" {
+		inv.Version = v
+	}
+	inv.Group = inv.Attachments[attachmentGroup]
+	return inv, nil
+}
+
+// EncodeDubboRequest encodes the dubbo request body
+func EncodeDubboRequest(inv *Invocation) ([]byte, error) {
+	e := &encoder{}
+	e.writeString(DubboVersion)
+	e.writeString(inv.Service)
+	e.writeString(inv.Version)
+	e.writeString(inv.Method)
+	e.writeString(ArgumentTypesDesc(inv.ArgTypes))
+	if inv.Args != nil {
+		if inv.Args.Len() != len(inv.ArgTypes) {
+			return nil, fmt.Errorf("%d arguments mismatch %d types", inv.Args.Len(), len(inv.ArgTypes))
+		}
+		if err := e.writeValues(inv.Args); err != nil {
+			return nil, err
+		}
+	} else if len(inv.ArgTypes) > 0 {
+		return nil, errors.New("arguments are missing")
+	}
+
+	attachments := make(map[string]string, len(inv.Attachments)+4)
+	for k, v := range inv.Attachments {
+		attachments[k] = v
+	}
+	attachments[attachmentPath] = inv.Service
+	attachments[attachmentInterface] = inv.Service
+	if inv.Version != "" {
+		attachments[attachmentVersion] = inv.Version
+	}
+	if inv.Group != "" {
+		attachments[attachmentGroup] = inv.Group
+	}
+	e.writeStringMap(attachments)
+	return e.buf, nil
+}
+
+// NewGenericInvocation returns the invocation of the dubbo generic service, the arguments are the values
+// decoded from JSON, and the objects are sent as maps which are converted to the parameter types by the provider.
+func NewGenericInvocation(service, version, group, method string, argTypes []string, args []interface{}) (*Invocation, error) {
+	if len(args) != len(argTypes) {
+		return nil, fmt.Errorf("%d arguments mismatch %d types", len(args), len(argTypes))
+	}
+	e := &encoder{}
+	e.writeString(method)
+	e.writeStringArray(argTypes)
+	e.writeListBegin("[object", len(args))
+	for i, arg := range args {
+		if err := e.writeValue(arg); err != nil {
+			return nil, fmt.Errorf("argument %d: %v", i, err)
+		}
+	}
+	values, err := NewValues(e.buf)
+	if err != nil {
+		return nil, err
+	}
+	return &Invocation{
+		Service:     service,
+		Version:     version,
+		Group:       group,
+		Method:      GenericMethod,
+		ArgTypes:    append([]string(nil), genericArgTypes...),
+		Args:        values,
+		Attachments: map[string]string{"generic": "true"},
+	}, nil
+}
+
+// DecodeDubboResponse decodes the body of the dubbo response whose status is OK
+func DecodeDubboResponse(body []byte) (*Result, error) {
+	s := newHessianScanner(body)
+	raw, err := s.nextStandalone()
+	if err != nil {
+		return nil, err
+	}
+	v, err := DecodeValue(raw)
+	if err != nil {
+		return nil, err
+	}
+	flag, ok := v.(int32)
+	if !ok {
+		return nil, fmt.Errorf("invalid dubbo response flag %v", v)
+	}
+
+	res := &Result{}
+	switch flag {
+	case dubboResponseException, dubboResponseExceptionWithAttachments:
+		res.Exception = true
+		if res.Value, err = s.values(1); err != nil {
+			return nil, err
+		}
+	case dubboResponseValue, dubboResponseValueWithAttachments:
+		if res.Value, err = s.values(1); err != nil {
+			return nil, err
+		}
+	case dubboResponseNull, dubboResponseNullWithAttachments:
+	default:
+		return nil, fmt.Errorf("invalid dubbo response flag %d", flag)
+	}
+	if flag >= dubboResponseExceptionWithAttachments {
+		raw, err := s.nextStandalone()
+		if err != nil {
+			return nil, fmt.Errorf("scan attachments failed: %v", err)
+		}
+		if res.Attachments, err = decodeAttachments(raw); err != nil {
+			return nil, fmt.Errorf("decode attachments failed: %v", err)
+		}
+	}
+	return res, nil
+}
+
+// EncodeDubboResponse encodes the body of the dubbo response whose status is OK, the attachments
+// are not encoded because they are not supported by the old dubbo versions.
+func EncodeDubboResponse(res *Result) ([]byte, error) {
+	e := &encoder{}
+	switch {
+	case res.Value == nil:
+		e.writeInt(dubboResponseNull)
+		return e.buf, nil
+	case res.Exception:
+		e.writeInt(dubboResponseException)
+	default:
+		e.writeInt(dubboResponseValue)
+	}
+	if err := e.writeValues(res.Value); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// EncodeError encodes the error message, which is the body of the failed dubbo response
+func EncodeError(msg string) []byte {
+	e := &encoder{}
+	e.writeString(msg)
+	return e.buf
+}
+
+// DecodeError decodes the body of the failed dubbo response
+func DecodeError(body []byte) string {
+	s := newHessianScanner(body)
+	raw, err := s.nextStandalone()
+	if err != nil {
+		return ""
+	}
+	msg, _ := decodeString(raw)
+	return msg
+}
@@ -0,0 +1,128 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package generic
+
+import (
+	"testing"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+	"github.com/apache/dubbo-go-hessian2/java_exception"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDubboRequest(t *testing.T) {
+	user := &testUser{Name: "alice", Age: 20}
+	body := EncodeHessian(t, "2.0.2", "com.example.UserService", "1.0.0", "save",
+		"Lcom/example/User;Ljava/lang/String;", user, "note",
+		map[string]string{"group": "g1", "trace": "t1"})
+	inv, err := DecodeDubboRequest(body)
+	require.Nil(t, err)
+	assert.Equal(t, "com.example.UserService", inv.Service)
+	assert.Equal(t, "1.0.0", inv.Version)
+	assert.Equal(t, "g1", inv.Group)
+	assert.Equal(t, "save", inv.Method)
+	assert.Equal(t, []string{"com.example.User", "java.lang.String"}, inv.ArgTypes)
+	assert.Equal(t, 2, inv.Args.Len())
+	assert.Equal(t, "t1", inv.Attachments["trace"])
+
+	encoded, err := EncodeDubboRequest(inv)
+	require.Nil(t, err)
+	decoded := DecodeHessian(t, encoded, 8)
+	assert.Equal(t, []interface{}{DubboVersion, "com.example.UserService", "1.0.0", "save",
+		"Lcom/example/User;Ljava/lang/String;", user, "note"}, decoded[:7])
+	attachments := decoded[7].(map[interface{}]interface{})
+	assert.Equal(t, "com.example.UserService", attachments["path"])
+	assert.Equal(t, "com.example.UserService", attachments["interface"])
+	assert.Equal(t, "1.0.0", attachments["version"])
+	assert.Equal(t, "g1", attachments["group"])
+	assert.Equal(t, "t1", attachments["trace"])
+
+	_, err = DecodeDubboRequest(body[:10])
+	assert.NotNil(t, err)
+	_, err = DecodeDubboRequest(EncodeHessian(t, "2.0.2", "svc", "", "m", "I"))
+	assert.NotNil(t, err)
+}
+
+func TestDubboResponse(t *testing.T) {
+	user := &testUser{Name: "alice", Age: 20}
+
+	res, err := DecodeDubboResponse(EncodeHessian(t, int32(dubboResponseValueWithAttachments), user,
+		map[string]string{"trace": "t1"}))
+	require.Nil(t, err)
+	assert.False(t, res.Exception)
+	assert.Equal(t, "t1", res.Attachments["trace"])
+	body, err := EncodeDubboResponse(res)
+	require.Nil(t, err)
+	assert.Equal(t, []interface{}{int32(dubboResponseValue), user}, DecodeHessian(t, body, 2))
+
+	res, err = DecodeDubboResponse(EncodeHessian(t, int32(dubboResponseException),
+		java_exception.NewException("boom")))
+	require.Nil(t, err)
+	assert.True(t, res.Exception)
+	body, err = EncodeDubboResponse(res)
+	require.Nil(t, err)
+	decoded := DecodeHessian(t, body, 2)
+	assert.Equal(t, int32(dubboResponseException), decoded[0])
+	assert.Equal(t, "boom", decoded[1].(*java_exception.Exception).Error())
+
+	res, err = DecodeDubboResponse(EncodeHessian(t, int32(dubboResponseNull)))
+	require.Nil(t, err)
+	assert.Nil(t, res.Value)
+	body, err = EncodeDubboResponse(res)
+	require.Nil(t, err)
+	assert.Equal(t, []interface{}{int32(dubboResponseNull)}, DecodeHessian(t, body, 1))
+
+	_, err = DecodeDubboResponse(EncodeHessian(t, int32(9)))
+	assert.NotNil(t, err)
+	_, err = DecodeDubboResponse(EncodeHessian(t, "1"))
+	assert.NotNil(t, err)
+}
+
+func TestDubboError(t *testing.T) {
+	body := EncodeError("service not found")
+	v, err := hessian.NewDecoder(body).Decode()
+	require.Nil(t, err)
+	assert.Equal(t, "service not found", v)
+	assert.Equal(t, "service not found", DecodeError(body))
+	assert.Equal(t, "", DecodeError(nil))
+}
+
+func TestGenericInvocation(t *testing.T) {
+	inv, err := NewGenericInvocation("com.example.UserService", "1.0.0", "g1", "save",
+		[]string{"com.example.User", "int"},
+		[]interface{}{map[string]interface{}{"name": "alice", "age": int32(20)}, int32(1)})
+	require.Nil(t, err)
+	body, err := EncodeDubboRequest(inv)
+	require.Nil(t, err)
+	decoded := DecodeHessian(t, body, 9)
+	assert.Equal(t, []interface{}{DubboVersion, "com.example.UserService", "1.0.0", GenericMethod,
+		"Ljava/lang/String;[Ljava/lang/String;[Ljava/lang/Object;", "save",
+		[]string{"com.example.User", "int"}}, decoded[:7])
+	assert.Equal(t, []hessian.Object{
+		map[interface{}]interface{}{"name": "alice", "age": int32(20)}, int32(1),
+	}, decoded[7])
+	attachments := decoded[8].(map[interface{}]interface{})
+	assert.Equal(t, "true", attachments["generic"])
+	assert.Equal(t, "g1", attachments["group"])
+
+	_, err = NewGenericInvocation("s", "", "", "m", []string{"int"}, nil)
+	assert.NotNil(t, err)
+	_, err = NewGenericInvocation("s", "", "", "m", []string{"int"}, []interface{}{struct{}{}})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,449 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package generic
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"unicode/utf16"
+)
+
+// stringChunkSize is the max chars of a string chunk
+const stringChunkSize = 0x8000
+
+// encoder writes the hessian 2 values. The class definitions, the types and the values which may be
+// referred are counted, so the copied values can be renumbered.
+type encoder struct {
+	buf   []byte
+	defs  int
+	types int
+	refs  int
+}
+
+func (e *encoder) writeNull() {
+	e.buf = append(e.buf, 'N')
+}
+
+func (e *encoder) writeBool(v bool) {
+	if v {
+		e.buf = append(e.buf, 'T')
+	} else {
+		e.buf = append(e.buf, 'F')
+	}
+}
+
+func (e *encoder) writeInt(v int32) {
+	switch {
+	case v >= -0x10 && v <= 0x2f:
+		e.buf = append(e.buf, byte(v+0x90))
+	case v >= -0x800 && v <= 0x7ff:
+		e.buf = append(e.buf, byte(0xc8+(v>>8)), byte(v))
+	case v >= -0x40000 && v <= 0x3ffff:
+		e.buf = append(e.buf, byte(0xd4+(v>>16)), byte(v>>8), byte(v))
+	default:
+		e.buf = append(e.buf, 'I', byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	}
+}
+
+func (e *encoder) writeLong(v int64) {
+	switch {
+	case v >= -0x08 && v <= 0x0f:
+		e.buf = append(e.buf, byte(v+0xe0))
+	case v >= -0x800 && v <= 0x7ff:
+		e.buf = append(e.buf, byte(0xf8+(v>>8)), byte(v))
+	case v >= -0x40000 && v <= 0x3ffff:
+		e.buf = append(e.buf, byte(0x3c+(v>>16)), byte(v>>8), byte(v))
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		e.buf = append(e.buf, 'Y', byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	default:
+		e.buf = append(e.buf, 'L')
+		e.buf = appendUint64(e.buf, uint64(v))
+	}
+}
+
+func (e *encoder) writeDouble(v float64) {
+	switch {
+	case v == 0 && !math.Signbit(v):
+		e.buf = append(e.buf, 0x5b)
+	case v == 1:
+		e.buf = append(e.buf, 0x5c)
+	case v == math.Trunc(v) && v >= math.MinInt8 && v <= math.MaxInt8:
+		e.buf = append(e.buf, 0x5d, byte(int8(v)))
+	case v == math.Trunc(v) && v >= math.MinInt16 && v <= math.MaxInt16:
+		i := int16(v)
+		e.buf = append(e.buf, 0x5e, byte(i>>8), byte(i))
+	default:
+		e.buf = append(e.buf, 'D')
+		e.buf = appendUint64(e.buf, math.Float64bits(v))
+	}
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], v)
+	return append(b, n[:]...)
+}
+
+// writeString writes a string, the length is the number of the utf-16 chars, and each char is
+// encoded in utf-8 like java, so the supplementary chars are encoded as the surrogate pairs.
+func (e *encoder) writeString(v string) {
+	chars := utf16.Encode([]rune(v))
+	for len(chars) > stringChunkSize {
+		e.buf = append(e.buf, 'R', byte(stringChunkSize>>8), byte(stringChunkSize&0xff))
+		e.writeChars(chars[:stringChunkSize])
+		chars = chars[stringChunkSize:]
+	}
+	n := len(chars)
+	switch {
+	case n <= 0x1f:
+		e.buf = append(e.buf, byte(n))
+	case n <= 0x3ff:
+		e.buf = append(e.buf, byte(0x30+(n>>8)), byte(n))
+	default:
+		e.buf = append(e.buf, 'S', byte(n>>8), byte(n))
+	}
+	e.writeChars(chars)
+}
+
+func (e *encoder) writeChars(chars []uint16) {
+	for _, c := range chars {
+		switch {
+		case c < 0x80:
+			e.buf = append(e.buf, byte(c))
+		case c < 0x800:
+			e.buf = append(e.buf, byte(0xc0|c>>6), byte(0x80|c&0x3f))
+		default:
+			e.buf = append(e.buf, byte(0xe0|c>>12), byte(0x80|(c>>6)&0x3f), byte(0x80|c&0x3f))
+		}
+	}
+}
+
+func (e *encoder) writeBinary(v []byte) {
+	for len(v) > stringChunkSize {
+		e.buf = append(e.buf, 'A', byte(stringChunkSize>>8), byte(stringChunkSize&0xff))
+		e.buf = append(e.buf, v[:stringChunkSize]...)
+		v = v[stringChunkSize:]
+	}
+	n := len(v)
+	switch {
+	case n <= 0x0f:
+		e.buf = append(e.buf, byte(0x20+n))
+	case n <= 0x3ff:
+		e.buf = append(e.buf, byte(0x34+(n>>8)), byte(n))
+	default:
+		e.buf = append(e.buf, 'B', byte(n>>8), byte(n))
+	}
+	e.buf = append(e.buf, v...)
+}
+
+// writeListBegin writes the header of a fixed length list, the list is untyped if the type is empty
+func (e *encoder) writeListBegin(typ string, n int) {
+	e.refs++
+	if typ == "" {
+		if n <= 7 {
+			e.buf = append(e.buf, byte(0x78+n))
+		} else {
+			e.buf = append(e.buf, 'X')
+			e.writeInt(int32(n))
+		}
+		return
+	}
+	if n <= 7 {
+		e.buf = append(e.buf, byte(0x70+n))
+	} else {
+		e.buf = append(e.buf, 'V')
+	}
+	e.writeString(typ)
+	e.types++
+	if n > 7 {
+		e.writeInt(int32(n))
+	}
+}
+
+// writeStringArray writes a java String[]
+func (e *encoder) writeStringArray(v []string) {
+	e.writeListBegin("[string", len(v))
+	for _, s := range v {
+		e.writeString(s)
+	}
+}
+
+// writeStringMap writes a java HashMap, the keys are sorted to make the data stable
+func (e *encoder) writeStringMap(m map[string]string) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	e.refs++
+	e.buf = append(e.buf, 'H')
+	for _, k := range keys {
+		e.writeString(k)
+		e.writeString(m[k])
+	}
+	e.buf = append(e.buf, 'Z')
+}
+
+// writeValue writes the values decoded from JSON, the objects are written as java HashMap
+func (e *encoder) writeValue(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		e.writeNull()
+	case bool:
+		e.writeBool(v)
+	case int32:
+		e.writeInt(v)
+	case int64:
+		e.writeLong(v)
+	case float64:
+		e.writeDouble(v)
+	case string:
+		e.writeString(v)
+	case []byte:
+		e.writeBinary(v)
+	case []interface{}:
+		e.writeListBegin("", len(v))
+		for _, item := range v {
+			if err := e.writeValue(item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		e.refs++
+		e.buf = append(e.buf, 'H')
+		for _, k := range keys {
+			e.writeString(k)
+			if err := e.writeValue(v[k]); err != nil {
+				return err
+			}
+		}
+		e.buf = append(e.buf, 'Z')
+	default:
+		return fmt.Errorf("unsupported hessian value %T", v)
+	}
+	return nil
+}
+
+// writeClassDef writes a class definition, and returns the index of it
+func (e *encoder) writeClassDef(name string, fields []string) int {
+	e.buf = append(e.buf, 'C')
+	e.writeString(name)
+	e.writeInt(int32(len(fields)))
+	for _, f := range fields {
+		e.writeString(f)
+	}
+	e.defs++
+	return e.defs - 1
+}
+
+// writeObject writes the header of an object, which is followed by the fields
+func (e *encoder) writeObject(def int) {
+	e.refs++
+	e.buf = appendObjectTag(e.buf, def)
+}
+
+func appendObjectTag(b []byte, def int) []byte {
+	if def <= 0x0f {
+		return append(b, byte(0x60+def))
+	}
+	e := encoder{buf: append(b, 'O')}
+	e.writeInt(int32(def))
+	return e.buf
+}
+
+// writeValues copies the values to the stream
+func (e *encoder) writeValues(v *Values) error {
+	s := &hessianScanner{
+		data:  v.data,
+		pos:   v.start,
+		defs:  append([]classDef(nil), v.defs...),
+		types: append([]typeDef(nil), v.types...),
+		refs:  v.refs,
+		copier: &copier{
+			out:   e,
+			defs:  make(map[int]int),
+			types: make(map[int]int),
+			refs:  v.refs,
+		},
+	}
+	if err := s.skipValues(v.count, 0); err != nil {
+		return err
+	}
+	if s.pos != v.end {
+		return fmt.Errorf("hessian values are changed")
+	}
+	start := v.start
+	for _, p := range s.copier.patches {
+		e.buf = append(e.buf, v.data[start:p.start]...)
+		e.buf = append(e.buf, p.data...)
+		start = p.end
+	}
+	e.buf = append(e.buf, v.data[start:v.end]...)
+	e.refs += s.refs - v.refs
+	return nil
+}
+
+type patch struct {
+	start, end int
+	data       []byte
+}
+
+// copier renumbers the class definitions, the types and the references of the copied values, the
+// class definitions and the types defined before the copied values are written when they are used.
+type copier struct {
+	out   *encoder
+	defs  map[int]int
+	types map[int]int
+	// refs is the number of the values which may be referred before the copied values
+	refs    int
+	patches []patch
+}
+
+func (c *copier) replace(s *hessianScanner, start int, data []byte) {
+	if string(s.data[start:s.pos]) != string(data) {
+		c.patches = append(c.patches, patch{start: start, end: s.pos, data: data})
+	}
+}
+
+func (c *copier) classDef(index int) {
+	c.defs[index] = c.out.defs
+	c.out.defs++
+}
+
+func (c *copier) object(s *hessianScanner, start, index int) {
+	var b []byte
+	def, ok := c.defs[index]
+	if !ok {
+		b = append(b, s.data[s.defs[index].start:s.defs[index].end]...)
+		def = c.out.defs
+		c.defs[index] = def
+		c.out.defs++
+	}
+	c.replace(s, start, appendObjectTag(b, def))
+}
+
+func (c *copier) typeDef(index int) {
+	c.types[index] = c.out.types
+	c.out.types++
+}
+
+func (c *copier) typeRef(s *hessianScanner, start, index int) {
+	if typ, ok := c.types[index]; ok {
+		e := encoder{}
+		e.writeInt(int32(typ))
+		c.replace(s, start, e.buf)
+		return
+	}
+	c.types[index] = c.out.types
+	c.out.types++
+	c.replace(s, start, s.data[s.types[index].start:s.types[index].end])
+}
+
+func (c *copier) ref(s *hessianScanner, start, index int) error {
+	if index < c.refs {
+		return fmt.Errorf("hessian reference %d is out of the copied values", index)
+	}
+	e := encoder{buf: []byte{'Q'}}
+	e.writeInt(int32(index - c.refs + c.out.refs))
+	c.replace(s, start, e.buf)
+	return nil
+}
+
+// Values are the raw hessian values in a stream, the class definitions, the types and the number
+// of the values which may be referred before them are kept, so they can be copied to another stream.
+type Values struct {
+	data       []byte
+	start, end int
+	defs       []classDef
+	types      []typeDef
+	refs       int
+	count      int
+}
+
+// NewValues scans all the values of a standalone hessian stream
+func NewValues(data []byte) (*Values, error) {
+	s := newHessianScanner(data)
+	v := &Values{data: data}
+	for s.pos < len(data) {
+		if err := s.skipValue(0); err != nil {
+			return nil, err
+		}
+		v.count++
+	}
+	v.end = s.pos
+	return v, nil
+}
+
+// Len returns the number of the values
+func (v *Values) Len() int {
+	return v.count
+}
+
+// Standalone returns each value as a standalone hessian stream
+func (v *Values) Standalone() ([][]byte, error) {
+	s := &hessianScanner{
+		data:  v.data[:v.end],
+		pos:   v.start,
+		defs:  append([]classDef(nil), v.defs...),
+		types: append([]typeDef(nil), v.types...),
+		refs:  v.refs,
+	}
+	out := make([][]byte, 0, v.count)
+	for i := 0; i < v.count; i++ {
+		b, err := s.nextStandalone()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, nil
+}
+
+// Bytes returns the values as a standalone hessian stream
+func (v *Values) Bytes() ([]byte, error) {
+	e := &encoder{}
+	if err := e.writeValues(v); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// class returns the class definition if the first value is an object
+func (v *Values) class() *classDef {
+	if v.count == 0 {
+		return nil
+	}
+	s := &hessianScanner{
+		data:  v.data[:v.end],
+		pos:   v.start,
+		defs:  append([]classDef(nil), v.defs...),
+		types: append([]typeDef(nil), v.types...),
+	}
+	def, err := s.nextObject()
+	if err != nil {
+		return nil
+	}
+	return def
+}
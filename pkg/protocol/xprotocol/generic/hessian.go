@@ -0,0 +1,537 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package generic
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+)
+
+var errHessianTruncated = errors.New("hessian data is truncated")
+
+// maxHessianDepth limits the nested depth of the scanned values
+const maxHessianDepth = 64
+
+type classDef struct {
+	// start and end are the range of the class definition in the data
+	start, end int
+	name       string
+	fields     []string
+}
+
+// typeDef is the range of a type name of the typed lists and maps
+type typeDef struct {
+	start, end int
+}
+
+// hessianScanner finds the ranges of the hessian 2 values without decoding them, so the values
+// of the unknown java classes can be forwarded. The class definitions and the types are recorded
+// because a value may refer to them by the index, and so are the number of the values which may
+// be referred by the references.
+type hessianScanner struct {
+	data  []byte
+	pos   int
+	defs  []classDef
+	types []typeDef
+	refs  int
+	// copier renumbers the indexes if the values are copied to another stream
+	copier *copier
+}
+
+func newHessianScanner(data []byte) *hessianScanner {
+	return &hessianScanner{data: data}
+}
+
+// next returns the raw bytes of the next value
+func (s *hessianScanner) next() ([]byte, error) {
+	start := s.pos
+	if err := s.skipValue(0); err != nil {
+		return nil, err
+	}
+	return s.data[start:s.pos], nil
+}
+
+// values scans the next n values
+func (s *hessianScanner) values(n int) (*Values, error) {
+	v := &Values{
+		data:  s.data,
+		start: s.pos,
+		defs:  append([]classDef(nil), s.defs...),
+		types: append([]typeDef(nil), s.types...),
+		refs:  s.refs,
+		count: n,
+	}
+	if err := s.skipValues(n, 0); err != nil {
+		return nil, err
+	}
+	v.end = s.pos
+	return v, nil
+}
+
+// nextStandalone returns the next value as a standalone hessian stream
+func (s *hessianScanner) nextStandalone() ([]byte, error) {
+	v, err := s.values(1)
+	if err != nil {
+		return nil, err
+	}
+	e := &encoder{}
+	if err := e.writeValues(v); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// nextObject reads the class definition and the header of an object, and returns the class,
+// the fields can be read by the next calls.
+func (s *hessianScanner) nextObject() (*classDef, error) {
+	tag, err := s.readByte()
+	if err != nil {
+		return nil, err
+	}
+	if tag == 'C' {
+		if err := s.readClassDef(s.pos - 1); err != nil {
+			return nil, err
+		}
+		if tag, err = s.readByte(); err != nil {
+			return nil, err
+		}
+	}
+	var index int
+	switch {
+	case tag == 'O':
+		if index, err = s.readInt(); err != nil {
+			return nil, err
+		}
+	case tag >= 0x60 && tag <= 0x6f:
+		index = int(tag) - 0x60
+	default:
+		return nil, fmt.Errorf("hessian tag 0x%x is not an object", tag)
+	}
+	if index < 0 || index >= len(s.defs) {
+		return nil, fmt.Errorf("invalid hessian class index %d", index)
+	}
+	s.refs++
+	return &s.defs[index], nil
+}
+
+func (s *hessianScanner) remaining() []byte {
+	return s.data[s.pos:]
+}
+
+func (s *hessianScanner) readByte() (byte, error) {
+	if s.pos >= len(s.data) {
+		return 0, errHessianTruncated
+	}
+	b := s.data[s.pos]
+	s.pos++
+	return b, nil
+}
+
+func (s *hessianScanner) discard(n int) error {
+	if n < 0 || len(s.data)-s.pos < n {
+		return errHessianTruncated
+	}
+	s.pos += n
+	return nil
+}
+
+func (s *hessianScanner) peek() (byte, error) {
+	if s.pos >= len(s.data) {
+		return 0, errHessianTruncated
+	}
+	return s.data[s.pos], nil
+}
+
+// readInt reads an int value, which is used as the length, count and index
+func (s *hessianScanner) readInt() (int, error) {
+	tag, err := s.readByte()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case tag >= 0x80 && tag <= 0xbf:
+		return int(tag) - 0x90, nil
+	case tag >= 0xc0 && tag <= 0xcf:
+		b, err := s.readByte()
+		return (int(tag)-0xc8)<<8 | int(b), err
+	case tag >= 0xd0 && tag <= 0xd7:
+		if len(s.data)-s.pos < 2 {
+			return 0, errHessianTruncated
+		}
+		v := (int(tag)-0xd4)<<16 | int(s.data[s.pos])<<8 | int(s.data[s.pos+1])
+		s.pos += 2
+		return v, nil
+	case tag == 'I':
+		if len(s.data)-s.pos < 4 {
+			return 0, errHessianTruncated
+		}
+		v := int(int32(binary.BigEndian.Uint32(s.data[s.pos:])))
+		s.pos += 4
+		return v, nil
+	}
+	return 0, fmt.Errorf("hessian tag 0x%x is not an int", tag)
+}
+
+func (s *hessianScanner) readLength() (int, error) {
+	n, err := s.readInt()
+	if err == nil && n < 0 {
+		err = fmt.Errorf("invalid hessian length %d", n)
+	}
+	return n, err
+}
+
+// readString reads and decodes a string
+func (s *hessianScanner) readString() (string, error) {
+	start := s.pos
+	tag, err := s.readByte()
+	if err != nil {
+		return "", err
+	}
+	if err := s.skipString(tag); err != nil {
+		return "", err
+	}
+	return decodeString(s.data[start:s.pos])
+}
+
+// skipChars skips n utf-16 chars encoded in utf-8
+func (s *hessianScanner) skipChars(n int) error {
+	for i := 0; i < n; i++ {
+		b, err := s.readByte()
+		if err != nil {
+			return err
+		}
+		switch {
+		case b < 0x80:
+		case b&0xe0 == 0xc0:
+			err = s.discard(1)
+		case b&0xf0 == 0xe0:
+			err = s.discard(2)
+		case b&0xf8 == 0xf0:
+			// the supplementary char is a surrogate pair in utf-16
+			err = s.discard(3)
+			i++
+		default:
+			err = fmt.Errorf("invalid hessian utf-8 byte 0x%x", b)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipString skips a string, the tag is read
+func (s *hessianScanner) skipString(tag byte) error {
+	for {
+		switch {
+		case tag <= 0x1f:
+			return s.skipChars(int(tag))
+		case tag >= 0x30 && tag <= 0x33:
+			b, err := s.readByte()
+			if err != nil {
+				return err
+			}
+			return s.skipChars((int(tag)-0x30)<<8 | int(b))
+		case tag == 'R' || tag == 'S':
+			if len(s.data)-s.pos < 2 {
+				return errHessianTruncated
+			}
+			n := int(binary.BigEndian.Uint16(s.data[s.pos:]))
+			s.pos += 2
+			if err := s.skipChars(n); err != nil {
+				return err
+			}
+			if tag == 'S' {
+				return nil
+			}
+			// the chunks are followed by the next chunk
+			var err error
+			if tag, err = s.readByte(); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("hessian tag 0x%x is not a string", tag)
+		}
+	}
+}
+
+// skipBinary skips a binary, the tag is read
+func (s *hessianScanner) skipBinary(tag byte) error {
+	for {
+		switch {
+		case tag >= 0x20 && tag <= 0x2f:
+			return s.discard(int(tag) - 0x20)
+		case tag >= 0x34 && tag <= 0x37:
+			b, err := s.readByte()
+			if err != nil {
+				return err
+			}
+			return s.discard((int(tag)-0x34)<<8 | int(b))
+		case tag == 'A' || tag == 'B':
+			if len(s.data)-s.pos < 2 {
+				return errHessianTruncated
+			}
+			n := int(binary.BigEndian.Uint16(s.data[s.pos:]))
+			if err := s.discard(2 + n); err != nil {
+				return err
+			}
+			if tag == 'B' {
+				return nil
+			}
+			// the chunks are followed by the next chunk
+			var err error
+			if tag, err = s.readByte(); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("hessian tag 0x%x is not a binary", tag)
+		}
+	}
+}
+
+func isBinaryTag(tag byte) bool {
+	return (tag >= 0x20 && tag <= 0x2f) || (tag >= 0x34 && tag <= 0x37) || tag == 'A' || tag == 'B'
+}
+
+func isStringTag(tag byte) bool {
+	return tag <= 0x1f || (tag >= 0x30 && tag <= 0x33) || tag == 'R' || tag == 'S'
+}
+
+// skipType skips the type of the list and map, which is a string or a reference to a former type
+func (s *hessianScanner) skipType() error {
+	start := s.pos
+	tag, err := s.peek()
+	if err != nil {
+		return err
+	}
+	if isStringTag(tag) {
+		s.pos++
+		if err := s.skipString(tag); err != nil {
+			return err
+		}
+		s.types = append(s.types, typeDef{start: start, end: s.pos})
+		if s.copier != nil {
+			s.copier.typeDef(len(s.types) - 1)
+		}
+		return nil
+	}
+	index, err := s.readInt()
+	if err != nil {
+		return err
+	}
+	if index < 0 || index >= len(s.types) {
+		return fmt.Errorf("invalid hessian type index %d", index)
+	}
+	if s.copier != nil {
+		s.copier.typeRef(s, start, index)
+	}
+	return nil
+}
+
+// readClassDef reads the class definition: name, field count and field names, the tag is read
+func (s *hessianScanner) readClassDef(start int) error {
+	name, err := s.readString()
+	if err != nil {
+		return err
+	}
+	n, err := s.readLength()
+	if err != nil {
+		return err
+	}
+	def := classDef{start: start, name: name}
+	for i := 0; i < n; i++ {
+		field, err := s.readString()
+		if err != nil {
+			return err
+		}
+		def.fields = append(def.fields, field)
+	}
+	def.end = s.pos
+	s.defs = append(s.defs, def)
+	if s.copier != nil {
+		s.copier.classDef(len(s.defs) - 1)
+	}
+	return nil
+}
+
+func (s *hessianScanner) skipValues(n, depth int) error {
+	for i := 0; i < n; i++ {
+		if err := s.skipValue(depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipUntilEnd skips the values until the end tag 'Z'
+func (s *hessianScanner) skipUntilEnd(depth int) error {
+	for {
+		tag, err := s.peek()
+		if err != nil {
+			return err
+		}
+		if tag == 'Z' {
+			s.pos++
+			return nil
+		}
+		if err := s.skipValue(depth); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *hessianScanner) skipObject(start, index, depth int) error {
+	if index < 0 || index >= len(s.defs) {
+		return fmt.Errorf("invalid hessian class index %d", index)
+	}
+	if s.copier != nil {
+		s.copier.object(s, start, index)
+	}
+	s.refs++
+	return s.skipValues(len(s.defs[index].fields), depth)
+}
+
+func (s *hessianScanner) skipValue(depth int) error {
+	if depth > maxHessianDepth {
+		return errors.New("hessian value is too deep")
+	}
+	depth++
+	start := s.pos
+	tag, err := s.readByte()
+	if err != nil {
+		return err
+	}
+	switch {
+	case isStringTag(tag):
+		return s.skipString(tag)
+	case isBinaryTag(tag):
+		return s.skipBinary(tag)
+	case tag >= 0x80 && tag <= 0xd7, tag == 'I':
+		s.pos = start
+		_, err := s.readInt()
+		return err
+	case tag >= 0xd8 && tag <= 0xef:
+		return nil
+	case tag >= 0xf0:
+		return s.discard(1)
+	case tag >= 0x38 && tag <= 0x3f:
+		return s.discard(2)
+	case tag == 'Y' || tag == 'K' || tag == 0x5f:
+		return s.discard(4)
+	case tag == 'L' || tag == 'D' || tag == 'J':
+		return s.discard(8)
+	case tag == 0x5b || tag == 0x5c || tag == 'N' || tag == 'T' || tag == 'F':
+		return nil
+	case tag == 0x5d:
+		return s.discard(1)
+	case tag == 0x5e:
+		return s.discard(2)
+	case tag == 'Q':
+		// the reference to a former value
+		index, err := s.readInt()
+		if err != nil {
+			return err
+		}
+		if index < 0 || index >= s.refs {
+			return fmt.Errorf("invalid hessian reference %d", index)
+		}
+		if s.copier != nil {
+			return s.copier.ref(s, start, index)
+		}
+		return nil
+	case tag == 'C':
+		// the class definition is followed by the object
+		if err := s.readClassDef(start); err != nil {
+			return err
+		}
+		return s.skipValue(depth)
+	case tag == 'O':
+		index, err := s.readInt()
+		if err != nil {
+			return err
+		}
+		return s.skipObject(start, index, depth)
+	case tag >= 0x60 && tag <= 0x6f:
+		return s.skipObject(start, int(tag)-0x60, depth)
+	case tag == 'U' || tag == 'M':
+		// typed variable length list and typed map
+		s.refs++
+		if err := s.skipType(); err != nil {
+			return err
+		}
+		return s.skipUntilEnd(depth)
+	case tag == 'W' || tag == 'H':
+		// untyped variable length list and untyped map
+		s.refs++
+		return s.skipUntilEnd(depth)
+	case tag == 'V':
+		s.refs++
+		if err := s.skipType(); err != nil {
+			return err
+		}
+		n, err := s.readLength()
+		if err != nil {
+			return err
+		}
+		return s.skipValues(n, depth)
+	case tag == 'X':
+		s.refs++
+		n, err := s.readLength()
+		if err != nil {
+			return err
+		}
+		return s.skipValues(n, depth)
+	case tag >= 0x70 && tag <= 0x77:
+		s.refs++
+		if err := s.skipType(); err != nil {
+			return err
+		}
+		return s.skipValues(int(tag)-0x70, depth)
+	case tag >= 0x78 && tag <= 0x7f:
+		s.refs++
+		return s.skipValues(int(tag)-0x78, depth)
+	}
+	return fmt.Errorf("unknown hessian tag 0x%x", tag)
+}
+
+// DecodeValue decodes a standalone hessian value, the objects of the unknown classes are decoded as maps.
+// The malformed data may panic the hessian decoder, which is returned as an error.
+func DecodeValue(raw []byte) (v interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			v, err = nil, fmt.Errorf("decode hessian value failed: %v", r)
+		}
+	}()
+	return hessian.NewDecoderWithSkip(raw).Decode()
+}
+
+// decodeString decodes a standalone string value, null is decoded as an empty string
+func decodeString(raw []byte) (string, error) {
+	v, err := DecodeValue(raw)
+	if err != nil || v == nil {
+		return "", err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("expect a string, but got %T", v)
+	}
+	return s, nil
+}
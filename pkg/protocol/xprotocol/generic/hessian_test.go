@@ -0,0 +1,224 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package generic
+
+import (
+	"encoding/hex"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testUser struct {
+	Name string
+	Age  int32
+}
+
+func (testUser) JavaClassName() string {
+	return "com.example.User"
+}
+
+type testOrder struct {
+	ID    int64
+	Owner *testUser
+	Items []string
+}
+
+func (testOrder) JavaClassName() string {
+	return "com.example.Order"
+}
+
+func init() {
+	hessian.RegisterPOJO(&testUser{})
+	hessian.RegisterPOJO(&testOrder{})
+}
+
+func TestHessianScanner(t *testing.T) {
+	values := []interface{}{
+		nil, true, false,
+		int32(0), int32(-16), int32(47), int32(-2048), int32(2047), int32(-262144), int32(262143), int32(1 << 30),
+		int64(0), int64(-8), int64(15), int64(-2048), int64(2047), int64(-262144), int64(262143), int64(1 << 30), int64(1 << 40),
+		0.0, 1.0, 100.0, 1000.0, 1.5, 3.14159,
+		"", "short", strings.Repeat("a", 100), strings.Repeat("中", 2000), strings.Repeat("x", 70000), "emoji 😀",
+		[]byte{}, []byte{1, 2, 3}, make([]byte, 1000), make([]byte, 70000),
+		time.Unix(1600000000, 0), time.Unix(1600000000, 123000000),
+		[]int32{1, 2, 3}, []string{"a", "b"}, []interface{}{"a", int32(1), nil}, make([]interface{}, 20),
+		map[interface{}]interface{}{"a": int32(1), "b": []string{"c"}},
+		&testUser{Name: "dave", Age: 50},
+		[]*testUser{{Name: "erin"}, {Name: "frank"}},
+	}
+	data := EncodeHessian(t, values...)
+	s := newHessianScanner(data)
+	for i, v := range values {
+		raw, err := s.nextStandalone()
+		require.Nil(t, err, "%d: %v", i, v)
+		decoded, err := hessian.NewDecoder(raw).Decode()
+		require.Nil(t, err, "%d: %v", i, v)
+		if tm, ok := v.(time.Time); ok {
+			assert.True(t, tm.Equal(decoded.(time.Time)), "%d", i)
+			continue
+		}
+		if v == nil {
+			assert.Nil(t, decoded)
+			continue
+		}
+		assert.EqualValues(t, v, decoded, "%d", i)
+	}
+	assert.Empty(t, s.remaining())
+	assert.Len(t, s.defs, 1)
+
+	// the truncated data
+	for n := 1; n < 30; n++ {
+		_, err := newHessianScanner(EncodeHessian(t, &testUser{Name: "grace", Age: 1})[:n]).next()
+		assert.NotNil(t, err, n)
+	}
+	_, err := newHessianScanner([]byte{'Z'}).next()
+	assert.NotNil(t, err)
+}
+
+func TestEncoder(t *testing.T) {
+	values := []interface{}{
+		nil, true, false,
+		int32(0), int32(-16), int32(47), int32(-2048), int32(2047), int32(-262144), int32(262143), int32(math.MinInt32),
+		int64(0), int64(-8), int64(15), int64(-2048), int64(2047), int64(-262144), int64(262143), int64(1 << 30), int64(math.MinInt64),
+		0.0, 1.0, -128.0, 32767.0, 1.5, math.MaxFloat64,
+		"", "short", strings.Repeat("中", 1000), strings.Repeat("x", 70000), "emoji 😀",
+		[]byte{}, []byte{1, 2, 3}, make([]byte, 1000), make([]byte, 70000),
+		[]interface{}{"a", int32(1), nil}, make([]interface{}, 20),
+		map[string]interface{}{"a": int32(1), "b": []interface{}{"c"}},
+	}
+	e := &encoder{}
+	for _, v := range values {
+		require.Nil(t, e.writeValue(v))
+	}
+	e.writeStringArray([]string{"a", "b"})
+	e.writeStringMap(map[string]string{"k": "v"})
+	assert.NotNil(t, e.writeValue(struct{}{}))
+
+	decoded := DecodeHessian(t, e.buf, len(values)+2)
+	for i, v := range values {
+		switch v := v.(type) {
+		case nil:
+			assert.Nil(t, decoded[i], "%d", i)
+		case map[string]interface{}:
+			assert.Equal(t, map[interface{}]interface{}{"a": int32(1), "b": []interface{}{"c"}}, decoded[i], "%d", i)
+		default:
+			assert.EqualValues(t, v, decoded[i], "%d", i)
+		}
+	}
+	assert.Equal(t, []string{"a", "b"}, decoded[len(values)])
+	assert.Equal(t, map[interface{}]interface{}{"k": "v"}, decoded[len(values)+1])
+	assert.Equal(t, 6, e.refs)
+	assert.Equal(t, 1, e.types)
+}
+
+func TestCopyValues(t *testing.T) {
+	user := &testUser{Name: "alice", Age: 20}
+	order := &testOrder{ID: 1, Owner: user, Items: []string{"book"}}
+	// the order refers to the user defined by the previous value
+	data := EncodeHessian(t, "head", user, &testUser{Name: "bob"}, order, []string{"x"}, []string{"y"})
+	s := newHessianScanner(data)
+	_, err := s.next()
+	require.Nil(t, err)
+	values, err := s.values(5)
+	require.Nil(t, err)
+	assert.Equal(t, 5, values.Len())
+
+	// the class definitions, the types and the references are renumbered
+	e := &encoder{}
+	def := e.writeClassDef("com.example.User", []string{"name", "age"})
+	e.writeObject(def)
+	e.writeString("zed")
+	e.writeInt(3)
+	e.writeListBegin("[int", 1)
+	e.writeInt(1)
+	require.Nil(t, e.writeValues(values))
+	decoded := DecodeHessian(t, e.buf, 7)
+	assert.Equal(t, &testUser{Name: "zed", Age: 3}, decoded[0])
+	assert.Equal(t, []int32{1}, decoded[1])
+	assert.Equal(t, user, decoded[2])
+	assert.Equal(t, &testUser{Name: "bob"}, decoded[3])
+	assert.Equal(t, order.ID, decoded[4].(*testOrder).ID)
+	assert.Equal(t, order.Items, decoded[4].(*testOrder).Items)
+	assert.Equal(t, user, decoded[4].(*testOrder).Owner)
+	assert.Equal(t, []string{"x"}, decoded[5])
+	assert.Equal(t, []string{"y"}, decoded[6])
+
+	// the standalone value can not refer to the other values
+	_, err = values.Standalone()
+	assert.NotNil(t, err)
+	independent, err := NewValues(EncodeHessian(t, user, &testUser{Name: "bob"}, []string{"y"}))
+	require.Nil(t, err)
+	standalone, err := independent.Standalone()
+	require.Nil(t, err)
+	require.Len(t, standalone, 3)
+	assert.Equal(t, &testUser{Name: "bob"}, DecodeHessian(t, standalone[1], 1)[0])
+	assert.Equal(t, []string{"y"}, DecodeHessian(t, standalone[2], 1)[0])
+
+	b, err := values.Bytes()
+	require.Nil(t, err)
+	assert.Equal(t, user, DecodeHessian(t, b, 3)[2].(*testOrder).Owner)
+
+	// the reference to the value before the copied values
+	s = newHessianScanner(data)
+	for i := 0; i < 3; i++ {
+		_, err = s.next()
+		require.Nil(t, err)
+	}
+	values, err = s.values(1)
+	require.Nil(t, err)
+	_, err = values.Bytes()
+	assert.NotNil(t, err)
+	assert.NotNil(t, (&encoder{}).writeValues(values))
+}
+
+func TestMalformedReference(t *testing.T) {
+	// the reference tag without any former value panics the hessian decoder
+	body, err := hex.DecodeString("51d0e00878de978e58989a2a0b72149d20d8a7")
+	require.Nil(t, err)
+	_, err = newHessianScanner(body).next()
+	assert.NotNil(t, err)
+	_, err = DecodeDubboRequest(body)
+	assert.NotNil(t, err)
+	_, err = DecodeDubboResponse(body)
+	assert.NotNil(t, err)
+	assert.Equal(t, "", DecodeError(body))
+	_, err = NewValues(body)
+	assert.NotNil(t, err)
+
+	// the reference to a value out of the standalone value
+	data := EncodeHessian(t, []string{"x"})
+	data = append(data, 'Q', 0x90)
+	s := newHessianScanner(data)
+	_, err = s.next()
+	require.Nil(t, err)
+	_, err = s.nextStandalone()
+	assert.NotNil(t, err)
+
+	// the panic of the hessian decoder is returned as an error
+	assert.Panics(t, func() {
+		hessian.NewDecoder(body).Decode()
+	})
+	_, err = DecodeValue(body)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,51 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package generic
+
+import (
+	"testing"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+)
+
+// EncodeHessian encodes the values as a hessian 2 stream, it is used by the tests of the transcoders
+func EncodeHessian(t testing.TB, values ...interface{}) []byte {
+	t.Helper()
+	encoder := hessian.NewEncoder()
+	for i, v := range values {
+		if err := encoder.Encode(v); err != nil {
+			t.Fatalf("encode hessian value %d failed: %v", i, err)
+		}
+	}
+	return encoder.Buffer()
+}
+
+// DecodeHessian decodes n values from the hessian 2 stream, it is used by the tests of the transcoders
+func DecodeHessian(t testing.TB, data []byte, n int) []interface{} {
+	t.Helper()
+	decoder := hessian.NewDecoder(data)
+	values := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		v, err := decoder.Decode()
+		if err != nil {
+			t.Fatalf("decode hessian value %d failed: %v", i, err)
+		}
+		values = append(values, v)
+	}
+	return values
+}
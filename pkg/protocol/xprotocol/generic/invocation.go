@@ -0,0 +1,169 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package generic is the generic invocation model of the hessian based RPC protocols, which is
+// used to transcode the requests and the responses between the protocols, and to extract the
+// arguments of the requests. The arguments and the results are kept as the raw hessian values,
+// because the java classes are unknown.
+package generic
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Invocation is a RPC request
+type Invocation struct {
+	Service string
+	Version string
+	Group   string
+	Method  string
+	// ArgTypes are the java class names of the arguments, such as java.lang.String and [I
+	ArgTypes []string
+	Args     *Values
+	// Attachments are the string attachments, such as the trace context
+	Attachments map[string]string
+}
+
+// Result is the result of a RPC request
+type Result struct {
+	// Value is the returned value or the exception, it is nil if the returned value is null
+	Value *Values
+	// Exception is true if the value is an exception thrown by the service
+	Exception   bool
+	Attachments map[string]string
+}
+
+var primitiveTypes = map[byte]string{
+	'V': "void",
+	'Z': "boolean",
+	'B': "byte",
+	'C': "char",
+	'D': "double",
+	'F': "float",
+	'I': "int",
+	'J': "long",
+	'S': "short",
+}
+
+var primitiveDescriptors = map[string]byte{
+	"void":    'V',
+	"boolean": 'Z',
+	"byte":    'B',
+	"char":    'C',
+	"double":  'D',
+	"float":   'F',
+	"int":     'I',
+	"long":    'J',
+	"short":   'S',
+}
+
+// ParseArgumentTypes converts the jvm type descriptors to the java class names, such as
+// Ljava/lang/String;[I to java.lang.String and [I
+func ParseArgumentTypes(desc string) ([]string, error) {
+	var names []string
+	for i := 0; i < len(desc); {
+		start := i
+		for i < len(desc) && desc[i] == '[' {
+			i++
+		}
+		if i == len(desc) {
+			return nil, fmt.Errorf("invalid argument types: %s", desc)
+		}
+		array := i > start
+		switch c := desc[i]; c {
+		case 'L':
+			end := strings.IndexByte(desc[i:], ';')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid argument types: %s", desc)
+			}
+			name := strings.ReplaceAll(desc[i+1:i+end], "/", ".")
+			if array {
+				names = append(names, desc[start:i]+"L"+name+";")
+			} else {
+				names = append(names, name)
+			}
+			i += end + 1
+		default:
+			name, ok := primitiveTypes[c]
+			if !ok {
+				return nil, fmt.Errorf("invalid argument types: %s", desc)
+			}
+			if array {
+				names = append(names, desc[start:i+1])
+			} else {
+				names = append(names, name)
+			}
+			i++
+		}
+	}
+	return names, nil
+}
+
+// ArgumentTypesDesc converts the java class names to the jvm type descriptors, it is the reverse of ParseArgumentTypes
+func ArgumentTypesDesc(names []string) string {
+	var b strings.Builder
+	for _, name := range names {
+		switch {
+		case strings.HasPrefix(name, "["):
+			b.WriteString(strings.ReplaceAll(name, ".", "/"))
+		case primitiveDescriptors[name] != 0:
+			b.WriteByte(primitiveDescriptors[name])
+		default:
+			b.WriteByte('L')
+			b.WriteString(strings.ReplaceAll(name, ".", "/"))
+			b.WriteByte(';')
+		}
+	}
+	return b.String()
+}
+
+// CanonicalName converts the java class name of the array to the canonical name, such as
+// [Ljava.lang.String; to java.lang.String[] and [I to int[]
+func CanonicalName(name string) string {
+	dims := 0
+	for dims < len(name) && name[dims] == '[' {
+		dims++
+	}
+	if dims == 0 || dims == len(name) {
+		return name
+	}
+	component := name[dims:]
+	if strings.HasPrefix(component, "L") && strings.HasSuffix(component, ";") {
+		component = component[1 : len(component)-1]
+	} else if p, ok := primitiveTypes[component[0]]; ok && len(component) == 1 {
+		component = p
+	}
+	return component + strings.Repeat("[]", dims)
+}
+
+// ClassName converts the canonical name of the array to the java class name, it is the reverse of CanonicalName
+func ClassName(name string) string {
+	dims := 0
+	for strings.HasSuffix(name, "[]") {
+		name = name[:len(name)-2]
+		dims++
+	}
+	if dims == 0 {
+		return name
+	}
+	prefix := strings.Repeat("[", dims)
+	if d, ok := primitiveDescriptors[name]; ok {
+		return prefix + string(d)
+	}
+	return prefix + "L" + name + ";"
+}
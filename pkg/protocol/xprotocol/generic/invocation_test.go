@@ -0,0 +1,57 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package generic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseArgumentTypes(t *testing.T) {
+	names, err := ParseArgumentTypes("ZBCDFIJS[[ILjava/util/Map;[Ljava/lang/String;")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"boolean", "byte", "char", "double", "float", "int", "long", "short",
+		"[[I", "java.util.Map", "[Ljava.lang.String;"}, names)
+	assert.Equal(t, "ZBCDFIJS[[ILjava/util/Map;[Ljava/lang/String;", ArgumentTypesDesc(names))
+	names, err = ParseArgumentTypes("")
+	require.Nil(t, err)
+	assert.Empty(t, names)
+	for _, desc := range []string{"[", "Ljava/lang/String", "X"} {
+		_, err := ParseArgumentTypes(desc)
+		assert.NotNil(t, err, desc)
+	}
+}
+
+func TestCanonicalName(t *testing.T) {
+	for name, canonical := range map[string]string{
+		"java.lang.String":       "java.lang.String",
+		"int":                    "int",
+		"[I":                     "int[]",
+		"[[J":                    "long[][]",
+		"[Ljava.lang.String;":    "java.lang.String[]",
+		"[[Lcom.example.User;":   "com.example.User[][]",
+		"java.util.List":         "java.util.List",
+		"[Ljava.lang.Object;":    "java.lang.Object[]",
+		"com.example.Outer$Item": "com.example.Outer$Item",
+	} {
+		assert.Equal(t, canonical, CanonicalName(name), name)
+		assert.Equal(t, name, ClassName(canonical), canonical)
+	}
+}
@@ -0,0 +1,204 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package generic
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrValueNotFound is returned if the value of the path does not exist or it is null
+var ErrValueNotFound = errors.New("hessian value is not found")
+
+// ParseArgumentPath parses the path of an argument, which is the index of the argument followed
+// by the path in the argument separated by the dots, such as 0.user.name
+func ParseArgumentPath(s string) (int, []string, error) {
+	parts := strings.Split(s, ".")
+	index, err := strconv.Atoi(parts[0])
+	if err != nil || index < 0 {
+		return 0, nil, fmt.Errorf("invalid argument index %q", parts[0])
+	}
+	for _, part := range parts[1:] {
+		if part == "" {
+			return 0, nil, fmt.Errorf("invalid argument path %q", s)
+		}
+	}
+	return index, parts[1:], nil
+}
+
+// Lookup finds the value of the path in the index-th value without decoding the others. The path
+// consists of the field names of the objects, the keys of the maps and the indexes of the lists.
+// The value must be a string, number, boolean or date, which is formatted as a string, and the
+// date is formatted as the milliseconds since the epoch like java.util.Date.
+func (v *Values) Lookup(index int, path []string) (string, error) {
+	if index < 0 || index >= v.count {
+		return "", ErrValueNotFound
+	}
+	if len(path) >= maxHessianDepth {
+		return "", errors.New("hessian path is too deep")
+	}
+	s := &hessianScanner{
+		data:  v.data[:v.end],
+		pos:   v.start,
+		defs:  append([]classDef(nil), v.defs...),
+		types: append([]typeDef(nil), v.types...),
+		refs:  v.refs,
+	}
+	if err := s.skipValues(index, 0); err != nil {
+		return "", err
+	}
+	return s.lookup(path)
+}
+
+// lookup finds the value of the path in the next value
+func (s *hessianScanner) lookup(path []string) (string, error) {
+	start := s.pos
+	if len(path) == 0 {
+		if err := s.skipValue(0); err != nil {
+			return "", err
+		}
+		return formatScalar(s.data[start:s.pos])
+	}
+	tag, err := s.readByte()
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case tag == 'C' || tag == 'O' || tag >= 0x60 && tag <= 0x6f:
+		s.pos = start
+		def, err := s.nextObject()
+		if err != nil {
+			return "", err
+		}
+		for i, field := range def.fields {
+			if field == path[0] {
+				if err := s.skipValues(i, 0); err != nil {
+					return "", err
+				}
+				return s.lookup(path[1:])
+			}
+		}
+	case tag == 'V' || tag == 'X' || tag >= 0x70 && tag <= 0x7f:
+		// fixed length list
+		s.refs++
+		if tag == 'V' || tag >= 0x70 && tag <= 0x77 {
+			if err := s.skipType(); err != nil {
+				return "", err
+			}
+		}
+		var n int
+		switch {
+		case tag == 'V' || tag == 'X':
+			if n, err = s.readLength(); err != nil {
+				return "", err
+			}
+		case tag <= 0x77:
+			n = int(tag) - 0x70
+		default:
+			n = int(tag) - 0x78
+		}
+		i, err := strconv.Atoi(path[0])
+		if err != nil || i < 0 || i >= n {
+			return "", ErrValueNotFound
+		}
+		if err := s.skipValues(i, 0); err != nil {
+			return "", err
+		}
+		return s.lookup(path[1:])
+	case tag == 'U' || tag == 'W':
+		// variable length list
+		s.refs++
+		if tag == 'U' {
+			if err := s.skipType(); err != nil {
+				return "", err
+			}
+		}
+		i, err := strconv.Atoi(path[0])
+		if err != nil || i < 0 {
+			return "", ErrValueNotFound
+		}
+		for ; ; i-- {
+			if tag, err := s.peek(); err != nil || tag == 'Z' {
+				return "", ErrValueNotFound
+			}
+			if i == 0 {
+				return s.lookup(path[1:])
+			}
+			if err := s.skipValue(0); err != nil {
+				return "", err
+			}
+		}
+	case tag == 'M' || tag == 'H':
+		s.refs++
+		if tag == 'M' {
+			if err := s.skipType(); err != nil {
+				return "", err
+			}
+		}
+		for {
+			if tag, err := s.peek(); err != nil || tag == 'Z' {
+				return "", ErrValueNotFound
+			}
+			keyStart := s.pos
+			if err := s.skipValue(0); err != nil {
+				return "", err
+			}
+			if key, err := formatScalar(s.data[keyStart:s.pos]); err == nil && key == path[0] {
+				return s.lookup(path[1:])
+			}
+			if err := s.skipValue(0); err != nil {
+				return "", err
+			}
+		}
+	}
+	return "", ErrValueNotFound
+}
+
+// formatScalar decodes a raw hessian value and formats it as a string
+func formatScalar(raw []byte) (string, error) {
+	if len(raw) == 0 {
+		return "", errHessianTruncated
+	}
+	switch tag := raw[0]; {
+	case tag == 'N':
+		return "", ErrValueNotFound
+	case tag == 'C' || tag == 'O' || tag == 'Q' || tag >= 0x55 && tag <= 0x58 || tag >= 0x60 && tag <= 0x7f,
+		tag == 'M' || tag == 'H':
+		return "", fmt.Errorf("hessian tag 0x%x is not a scalar", tag)
+	}
+	v, err := DecodeValue(raw)
+	if err != nil {
+		return "", err
+	}
+	switch val := v.(type) {
+	case nil:
+		return "", ErrValueNotFound
+	case string:
+		return val, nil
+	case []byte:
+		return string(val), nil
+	case time.Time:
+		return strconv.FormatInt(val.UnixNano()/int64(time.Millisecond), 10), nil
+	case int32, int64, float64, bool:
+		return fmt.Sprint(val), nil
+	}
+	return "", fmt.Errorf("hessian value %T is not a scalar", v)
+}
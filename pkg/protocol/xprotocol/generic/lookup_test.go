@@ -0,0 +1,111 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package generic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValuesLookup(t *testing.T) {
+	order := &testOrder{
+		ID:    10,
+		Owner: &testUser{Name: "alice", Age: 30},
+		Items: []string{"apple", "banana"},
+	}
+	data := EncodeHessian(t,
+		"uid-1", int32(7), true, 1.5, time.Unix(1600000000, 0), nil,
+		order,
+		map[interface{}]interface{}{"region": "hz", int32(1): "one", "user": &testUser{Name: "bob"}},
+		[]interface{}{"a", &testUser{Name: "carol"}},
+		// the class definitions of the user and order are referred by the index
+		&testOrder{ID: 11, Owner: &testUser{Name: "dave"}},
+	)
+	v, err := NewValues(data)
+	require.Nil(t, err)
+
+	for _, tc := range []struct {
+		index int
+		path  []string
+		value string
+	}{
+		{0, nil, "uid-1"},
+		{1, nil, "7"},
+		{2, nil, "true"},
+		{3, nil, "1.5"},
+		{4, nil, "1600000000000"},
+		{6, []string{"iD"}, "10"},
+		{6, []string{"owner", "name"}, "alice"},
+		{6, []string{"owner", "age"}, "30"},
+		{6, []string{"items", "1"}, "banana"},
+		{7, []string{"region"}, "hz"},
+		{7, []string{"1"}, "one"},
+		{7, []string{"user", "name"}, "bob"},
+		{8, []string{"0"}, "a"},
+		{8, []string{"1", "name"}, "carol"},
+		{9, []string{"owner", "name"}, "dave"},
+	} {
+		value, err := v.Lookup(tc.index, tc.path)
+		assert.Nil(t, err, "%d %v", tc.index, tc.path)
+		assert.Equal(t, tc.value, value, "%d %v", tc.index, tc.path)
+	}
+
+	for _, tc := range []struct {
+		index int
+		path  []string
+	}{
+		{5, nil},
+		{10, nil},
+		{-1, nil},
+		{0, []string{"field"}},
+		{6, []string{"unknown"}},
+		{6, []string{"items", "2"}},
+		{6, []string{"items", "x"}},
+		{7, []string{"zone"}},
+		{8, []string{"2"}},
+	} {
+		_, err := v.Lookup(tc.index, tc.path)
+		assert.Equal(t, ErrValueNotFound, err, "%d %v", tc.index, tc.path)
+	}
+
+	// the value is not a scalar
+	_, err = v.Lookup(6, []string{"owner"})
+	assert.NotNil(t, err)
+	_, err = v.Lookup(6, []string{"items"})
+	assert.NotNil(t, err)
+}
+
+func TestParseArgumentPath(t *testing.T) {
+	index, path, err := ParseArgumentPath("0")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, index)
+	assert.Empty(t, path)
+
+	index, path, err = ParseArgumentPath("2.user.tags.1")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, index)
+	assert.Equal(t, []string{"user", "tags", "1"}, path)
+
+	for _, s := range []string{"", "a", "-1", "0.", "0..name", ".name"} {
+		_, _, err := ParseArgumentPath(s)
+		assert.NotNil(t, err, s)
+	}
+}
@@ -0,0 +1,284 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package generic
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// the java classes of the SOFARPC request and response
+const (
+	SofaRequestClass  = "com.alipay.sofa.rpc.core.request.SofaRequest"
+	SofaResponseClass = "com.alipay.sofa.rpc.core.response.SofaResponse"
+)
+
+// the fields of the SOFARPC request and response
+const (
+	fieldTargetAppName           = "targetAppName"
+	fieldMethodName              = "methodName"
+	fieldTargetServiceUniqueName = "targetServiceUniqueName"
+	fieldRequestProps            = "requestProps"
+	fieldMethodArgSigs           = "methodArgSigs"
+
+	fieldIsError       = "isError"
+	fieldErrorMsg      = "errorMsg"
+	fieldAppResponse   = "appResponse"
+	fieldResponseProps = "responseProps"
+)
+
+// UniqueName returns the SOFARPC service unique name, which is interface:version[:uniqueId],
+// the group is used as the unique id.
+func UniqueName(service, version, group string) string {
+	name := service + ":" + version
+	if group != "" {
+		name += ":" + group
+	}
+	return name
+}
+
+// parseUniqueName parses the SOFARPC service unique name
+func parseUniqueName(name string) (service, version, group string) {
+	parts := strings.SplitN(name, ":", 3)
+	service = parts[0]
+	if len(parts) > 1 {
+		version = parts[1]
+	}
+	if len(parts) > 2 {
+		group = parts[2]
+	}
+	return
+}
+
+// decodeField decodes a field of the SOFARPC request and response
+func decodeField(s *hessianScanner) (interface{}, error) {
+	raw, err := s.nextStandalone()
+	if err != nil {
+		return nil, err
+	}
+	return DecodeValue(raw)
+}
+
+// DecodeSofaRequest decodes the SOFARPC request, which is a SofaRequest followed by the arguments.
+// The service and version are parsed from the target service unique name.
+func DecodeSofaRequest(body []byte) (*Invocation, error) {
+	s := newHessianScanner(body)
+	def, err := s.nextObject()
+	if err != nil {
+		return nil, err
+	}
+	if def.name != SofaRequestClass {
+		return nil, fmt.Errorf("unexpected class %s", def.name)
+	}
+	inv := &Invocation{Attachments: map[string]string{}}
+	for _, field := range def.fields {
+		v, err := decodeField(s)
+		if err != nil {
+			return nil, fmt.Errorf("decode field %s failed: %v", field, err)
+		}
+		switch field {
+		case fieldMethodName:
+			inv.Method, _ = v.(string)
+		case fieldTargetServiceUniqueName:
+			name, _ := v.(string)
+			inv.Service, inv.Version, inv.Group = parseUniqueName(name)
+		case fieldMethodArgSigs:
+			switch sigs := v.(type) {
+			case []string:
+				inv.ArgTypes = sigs
+			case []interface{}:
+				for _, sig := range sigs {
+					name, _ := sig.(string)
+					inv.ArgTypes = append(inv.ArgTypes, name)
+				}
+			}
+		case fieldRequestProps:
+			if m, ok := v.(map[interface{}]interface{}); ok {
+				for k, v := range m {
+					key, ok1 := k.(string)
+					val, ok2 := v.(string)
+					if ok1 && ok2 {
+						inv.Attachments[key] = val
+					}
+				}
+			}
+		}
+	}
+	if inv.Service == "" || inv.Method == "" {
+		return nil, errors.New("service or method is missing")
+	}
+	// SOFARPC uses the canonical names of the arrays
+	for i, name := range inv.ArgTypes {
+		inv.ArgTypes[i] = ClassName(name)
+	}
+	if inv.Args, err = s.values(len(inv.ArgTypes)); err != nil {
+		return nil, fmt.Errorf("scan arguments failed: %v", err)
+	}
+	return inv, nil
+}
+
+// EncodeSofaRequest encodes the SOFARPC request
+func EncodeSofaRequest(inv *Invocation, appName string) ([]byte, error) {
+	e := &encoder{}
+	def := e.writeClassDef(SofaRequestClass, []string{
+		fieldTargetAppName,
+		fieldMethodName,
+		fieldTargetServiceUniqueName,
+		fieldRequestProps,
+		fieldMethodArgSigs,
+	})
+	e.writeObject(def)
+	if appName != "" {
+		e.writeString(appName)
+	} else {
+		e.writeNull()
+	}
+	e.writeString(inv.Method)
+	e.writeString(UniqueName(inv.Service, inv.Version, inv.Group))
+	e.writeStringMap(inv.Attachments)
+	sigs := make([]string, 0, len(inv.ArgTypes))
+	for _, name := range inv.ArgTypes {
+		sigs = append(sigs, CanonicalName(name))
+	}
+	e.writeStringArray(sigs)
+	if inv.Args != nil {
+		if inv.Args.Len() != len(inv.ArgTypes) {
+			return nil, fmt.Errorf("%d arguments mismatch %d types", inv.Args.Len(), len(inv.ArgTypes))
+		}
+		if err := e.writeValues(inv.Args); err != nil {
+			return nil, err
+		}
+	} else if len(inv.ArgTypes) > 0 {
+		return nil, errors.New("arguments are missing")
+	}
+	return e.buf, nil
+}
+
+// isThrowable checks whether the value is a java exception by the fields of java.lang.Throwable,
+// because SOFARPC returns the exception thrown by the service as the response.
+func isThrowable(v *Values) bool {
+	def := v.class()
+	if def == nil {
+		return false
+	}
+	var message, stackTrace bool
+	for _, f := range def.fields {
+		switch f {
+		case "detailMessage":
+			message = true
+		case "stackTrace":
+			stackTrace = true
+		}
+	}
+	return message && stackTrace
+}
+
+// DecodeSofaResponse decodes the SOFARPC response, the error message is returned if the
+// response is an error of the RPC framework.
+func DecodeSofaResponse(body []byte) (*Result, string, error) {
+	s := newHessianScanner(body)
+	def, err := s.nextObject()
+	if err != nil {
+		return nil, "", err
+	}
+	if def.name != SofaResponseClass {
+		return nil, "", fmt.Errorf("unexpected class %s", def.name)
+	}
+	var (
+		isError  bool
+		errorMsg string
+		res      = &Result{}
+	)
+	for _, field := range def.fields {
+		if field == fieldAppResponse {
+			tag, err := s.peek()
+			if err != nil {
+				return nil, "", err
+			}
+			if tag == 'N' {
+				s.pos++
+				continue
+			}
+			if res.Value, err = s.values(1); err != nil {
+				return nil, "", fmt.Errorf("scan field %s failed: %v", field, err)
+			}
+			res.Exception = isThrowable(res.Value)
+			continue
+		}
+		v, err := decodeField(s)
+		if err != nil {
+			return nil, "", fmt.Errorf("decode field %s failed: %v", field, err)
+		}
+		switch field {
+		case fieldIsError:
+			isError, _ = v.(bool)
+		case fieldErrorMsg:
+			errorMsg, _ = v.(string)
+		case fieldResponseProps:
+			if m, ok := v.(map[interface{}]interface{}); ok {
+				res.Attachments = map[string]string{}
+				for k, v := range m {
+					key, ok1 := k.(string)
+					val, ok2 := v.(string)
+					if ok1 && ok2 {
+						res.Attachments[key] = val
+					}
+				}
+			}
+		}
+	}
+	if isError {
+		if errorMsg == "" {
+			errorMsg = "unknown error"
+		}
+		return nil, errorMsg, nil
+	}
+	return res, "", nil
+}
+
+// EncodeSofaResponse encodes the SOFARPC response, the response is an error if the error message is not empty
+func EncodeSofaResponse(res *Result, errorMsg string) ([]byte, error) {
+	e := &encoder{}
+	def := e.writeClassDef(SofaResponseClass, []string{
+		fieldIsError,
+		fieldErrorMsg,
+		fieldAppResponse,
+		fieldResponseProps,
+	})
+	e.writeObject(def)
+	e.writeBool(errorMsg != "")
+	if errorMsg != "" {
+		e.writeString(errorMsg)
+	} else {
+		e.writeNull()
+	}
+	if res != nil && res.Value != nil {
+		if err := e.writeValues(res.Value); err != nil {
+			return nil, err
+		}
+	} else {
+		e.writeNull()
+	}
+	if res != nil && len(res.Attachments) > 0 {
+		e.writeStringMap(res.Attachments)
+	} else {
+		e.writeNull()
+	}
+	return e.buf, nil
+}
@@ -0,0 +1,154 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package generic
+
+import (
+	"testing"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+	"github.com/apache/dubbo-go-hessian2/java_exception"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testSofaRequest struct {
+	TargetAppName           string            `hessian:"targetAppName"`
+	MethodName              string            `hessian:"methodName"`
+	TargetServiceUniqueName string            `hessian:"targetServiceUniqueName"`
+	RequestProps            map[string]string `hessian:"requestProps"`
+	MethodArgSigs           []string          `hessian:"methodArgSigs"`
+}
+
+func (testSofaRequest) JavaClassName() string {
+	return SofaRequestClass
+}
+
+type testSofaResponse struct {
+	IsError       bool              `hessian:"isError"`
+	ErrorMsg      string            `hessian:"errorMsg"`
+	AppResponse   interface{}       `hessian:"appResponse"`
+	ResponseProps map[string]string `hessian:"responseProps"`
+}
+
+func (testSofaResponse) JavaClassName() string {
+	return SofaResponseClass
+}
+
+func init() {
+	hessian.RegisterPOJO(&testSofaRequest{})
+	hessian.RegisterPOJO(&testSofaResponse{})
+}
+
+func TestUniqueName(t *testing.T) {
+	assert.Equal(t, "com.example.UserService:1.0", UniqueName("com.example.UserService", "1.0", ""))
+	assert.Equal(t, "com.example.UserService:1.0:g1", UniqueName("com.example.UserService", "1.0", "g1"))
+	service, version, group := parseUniqueName("com.example.UserService:1.0:g1")
+	assert.Equal(t, []string{"com.example.UserService", "1.0", "g1"}, []string{service, version, group})
+	service, version, group = parseUniqueName("com.example.UserService")
+	assert.Equal(t, []string{"com.example.UserService", "", ""}, []string{service, version, group})
+}
+
+func TestSofaRequest(t *testing.T) {
+	user := &testUser{Name: "alice", Age: 20}
+	body := EncodeHessian(t, &testSofaRequest{
+		TargetAppName:           "app",
+		MethodName:              "save",
+		TargetServiceUniqueName: "com.example.UserService:1.0:g1",
+		RequestProps:            map[string]string{"trace": "t1"},
+		MethodArgSigs:           []string{"com.example.User", "int[]"},
+	}, user, []int32{1, 2})
+	inv, err := DecodeSofaRequest(body)
+	require.Nil(t, err)
+	assert.Equal(t, "com.example.UserService", inv.Service)
+	assert.Equal(t, "1.0", inv.Version)
+	assert.Equal(t, "g1", inv.Group)
+	assert.Equal(t, "save", inv.Method)
+	assert.Equal(t, []string{"com.example.User", "[I"}, inv.ArgTypes)
+	assert.Equal(t, map[string]string{"trace": "t1"}, inv.Attachments)
+	assert.Equal(t, 2, inv.Args.Len())
+
+	// the dubbo request is converted to the SOFARPC request
+	dubboBody, err := EncodeDubboRequest(inv)
+	require.Nil(t, err)
+	inv, err = DecodeDubboRequest(dubboBody)
+	require.Nil(t, err)
+	encoded, err := EncodeSofaRequest(inv, "caller")
+	require.Nil(t, err)
+	decoded := DecodeHessian(t, encoded, 3)
+	req := decoded[0].(*testSofaRequest)
+	assert.Equal(t, "caller", req.TargetAppName)
+	assert.Equal(t, "save", req.MethodName)
+	assert.Equal(t, "com.example.UserService:1.0:g1", req.TargetServiceUniqueName)
+	assert.Equal(t, []string{"com.example.User", "int[]"}, req.MethodArgSigs)
+	assert.Equal(t, "t1", req.RequestProps["trace"])
+	assert.Equal(t, user, decoded[1])
+	assert.Equal(t, []int32{1, 2}, decoded[2])
+
+	_, err = DecodeSofaRequest(EncodeHessian(t, user))
+	assert.NotNil(t, err)
+	_, err = EncodeSofaRequest(&Invocation{Method: "m", ArgTypes: []string{"int"}}, "")
+	assert.NotNil(t, err)
+}
+
+func TestSofaResponse(t *testing.T) {
+	user := &testUser{Name: "alice", Age: 20}
+
+	res, errorMsg, err := DecodeSofaResponse(EncodeHessian(t, &testSofaResponse{
+		AppResponse:   user,
+		ResponseProps: map[string]string{"trace": "t1"},
+	}))
+	require.Nil(t, err)
+	assert.Empty(t, errorMsg)
+	assert.False(t, res.Exception)
+	assert.Equal(t, "t1", res.Attachments["trace"])
+	body, err := EncodeSofaResponse(res, "")
+	require.Nil(t, err)
+	resp := DecodeHessian(t, body, 1)[0].(*testSofaResponse)
+	assert.False(t, resp.IsError)
+	assert.Equal(t, *user, resp.AppResponse)
+	assert.Equal(t, "t1", resp.ResponseProps["trace"])
+
+	// the exception thrown by the service
+	res, _, err = DecodeSofaResponse(EncodeHessian(t, &testSofaResponse{
+		AppResponse: java_exception.NewException("boom"),
+	}))
+	require.Nil(t, err)
+	assert.True(t, res.Exception)
+	body, err = EncodeDubboResponse(res)
+	require.Nil(t, err)
+	assert.Equal(t, int32(dubboResponseException), DecodeHessian(t, body, 2)[0])
+
+	// the null value
+	res, _, err = DecodeSofaResponse(EncodeHessian(t, &testSofaResponse{}))
+	require.Nil(t, err)
+	assert.Nil(t, res.Value)
+
+	// the error of the RPC framework
+	_, errorMsg, err = DecodeSofaResponse(EncodeHessian(t, &testSofaResponse{IsError: true, ErrorMsg: "no provider"}))
+	require.Nil(t, err)
+	assert.Equal(t, "no provider", errorMsg)
+	body, err = EncodeSofaResponse(nil, "timeout")
+	require.Nil(t, err)
+	resp = DecodeHessian(t, body, 1)[0].(*testSofaResponse)
+	assert.True(t, resp.IsError)
+	assert.Equal(t, "timeout", resp.ErrorMsg)
+	assert.Nil(t, resp.AppResponse)
+
+	_, _, err = DecodeSofaResponse(EncodeHessian(t, user))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,118 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package thrift
+
+import (
+	"github.com/apache/thrift/lib/go/thrift"
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+type Header struct {
+	Transport string
+	Protocol  string
+	// MessageName is the method name in the message, including the service prefix in the multiplexed protocol
+	MessageName string
+	MessageType thrift.TMessageType
+	SeqId       int32
+
+	// the TTHeader fields, the string key values are kept in the CommonHeader
+	Flags       uint16
+	IntKeyValue map[uint16]string
+	ACLToken    string
+
+	protocol.CommonHeader
+}
+
+type Frame struct {
+	Header
+	// payload is the message struct after the message begin
+	payload []byte
+	content types.IoBuffer // wrapper of payload
+}
+
+var _ api.XFrame = &Frame{}
+var _ api.XRespFrame = &Frame{}
+
+// ~ XFrame
+func (r *Frame) GetRequestId() uint64 {
+	return uint64(uint32(r.SeqId))
+}
+
+func (r *Frame) SetRequestId(id uint64) {
+	r.SeqId = int32(uint32(id))
+}
+
+func (r *Frame) IsHeartbeatFrame() bool {
+	return r.MessageName == HeartbeatMethod
+}
+
+// thrift has no timeout in the message, use the default timeout
+func (r *Frame) GetTimeout() int32 {
+	return 0
+}
+
+func (r *Frame) GetStreamType() api.StreamType {
+	switch r.MessageType {
+	case thrift.REPLY, thrift.EXCEPTION:
+		return api.Response
+	case thrift.ONEWAY:
+		return api.RequestOneWay
+	default:
+		return api.Request
+	}
+}
+
+func (r *Frame) GetHeader() types.HeaderMap {
+	return r
+}
+
+// GetData returns the message struct, without the message begin
+func (r *Frame) GetData() types.IoBuffer {
+	return r.content
+}
+
+func (r *Frame) SetData(data types.IoBuffer) {
+	r.content = data
+	r.payload = data.Bytes()
+}
+
+// GetStatusCode returns the message type, REPLY is 2 and EXCEPTION is 3
+func (r *Frame) GetStatusCode() uint32 {
+	return uint32(r.MessageType)
+}
+
+func (r *Frame) Clone() api.HeaderMap {
+	clone := &Frame{
+		payload: make([]byte, len(r.payload)),
+	}
+	clone.Header = r.Header
+	clone.CommonHeader = r.CommonHeader.Clone().(protocol.CommonHeader)
+	if r.IntKeyValue != nil {
+		clone.IntKeyValue = make(map[uint16]string, len(r.IntKeyValue))
+		for k, v := range r.IntKeyValue {
+			clone.IntKeyValue[k] = v
+		}
+	}
+	copy(clone.payload, r.payload)
+	clone.content = buffer.NewIoBufferBytes(clone.payload)
+	return clone
+}
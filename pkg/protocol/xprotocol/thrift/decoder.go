@@ -0,0 +1,580 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package thrift
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"mosn.io/pkg/buffer"
+
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+var errIncomplete = errors.New("[xprotocol][thrift] incomplete data")
+
+// decodeFrame decodes a frame from data, returns nil if the data is incomplete
+func decodeFrame(ctx context.Context, data types.IoBuffer) (interface{}, error) {
+	b := data.Bytes()
+	frame := &Frame{
+		Header: Header{
+			CommonHeader: protocol.CommonHeader{},
+		},
+	}
+	var n int
+	var err error
+	switch {
+	case isMessageBegin(b):
+		n, err = decodeUnframed(frame, b)
+	case len(b) < frameLenSize+2:
+		return nil, nil
+	case binary.BigEndian.Uint16(b[frameLenSize:]) == ttheaderMagic:
+		n, err = decodeTTHeader(frame, b)
+	case isMessageBegin(b[frameLenSize:]):
+		n, err = decodeFramed(frame, b)
+	default:
+		err = errors.New("[xprotocol][thrift] unknown transport")
+	}
+	if err == errIncomplete {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	frame.Set(TransportNameHeader, frame.Transport)
+	frame.Set(ProtocolNameHeader, frame.Protocol)
+	frame.Set(MessageTypeNameHeader, strconv.Itoa(int(frame.MessageType)))
+	if i := strings.Index(frame.MessageName, MultiplexedSeparator); i >= 0 {
+		frame.Set(ServiceNameHeader, frame.MessageName[:i])
+		frame.Set(MethodNameHeader, frame.MessageName[i+1:])
+	} else {
+		frame.Set(MethodNameHeader, frame.MessageName)
+	}
+	frame.content = buffer.NewIoBufferBytes(frame.payload)
+
+	data.Drain(n)
+	return frame, nil
+}
+
+// isMessageBegin checks the message begin of the binary and compact protocol
+func isMessageBegin(b []byte) bool {
+	if len(b) >= 2 && b[0] == 0x80 && b[1] == 0x01 {
+		return true
+	}
+	return len(b) >= 2 && b[0] == compactProtocolID && b[1]&compactVersionMask == compactVersion
+}
+
+func messageProtocol(b []byte) string {
+	if b[0] == compactProtocolID {
+		return ProtocolCompact
+	}
+	return ProtocolBinary
+}
+
+// decodeUnframed decodes the message without the frame length, the message struct is skipped to find the end
+func decodeUnframed(frame *Frame, b []byte) (int, error) {
+	frame.Transport = TransportUnframed
+	frame.Protocol = messageProtocol(b)
+	n, err := decodeMessageBegin(frame, b)
+	if err != nil {
+		return 0, err
+	}
+	size, err := skipStruct(frame.Protocol, b[n:], 0)
+	if err != nil {
+		return 0, err
+	}
+	if n+size > MaxFrameSize {
+		return 0, fmt.Errorf("[xprotocol][thrift] message size %d is too large", n+size)
+	}
+	frame.payload = copyBytes(b[n : n+size])
+	return n + size, nil
+}
+
+func readFrameLength(b []byte) (int, error) {
+	size := binary.BigEndian.Uint32(b)
+	if size > MaxFrameSize {
+		return 0, fmt.Errorf("[xprotocol][thrift] frame size %d is too large", size)
+	}
+	if len(b) < frameLenSize+int(size) {
+		return 0, errIncomplete
+	}
+	return frameLenSize + int(size), nil
+}
+
+func decodeFramed(frame *Frame, b []byte) (int, error) {
+	frameLen, err := readFrameLength(b)
+	if err != nil {
+		return 0, err
+	}
+	message := b[frameLenSize:frameLen]
+	frame.Transport = TransportFramed
+	frame.Protocol = messageProtocol(message)
+	n, err := decodeMessageBegin(frame, message)
+	if err != nil {
+		return 0, truncated(err)
+	}
+	frame.payload = copyBytes(message[n:])
+	return frameLen, nil
+}
+
+/**
+ * TTHeader transport
+ *
+ * +-------------------+----------------+----------------+------------------+--------------------------+
+ * | length (4 bytes)  | magic (0x1000) | flags (2 bytes)| seq id (4 bytes) | header size/4 (2 bytes)  |
+ * +-------------------+----------------+----------------+------------------+--------------------------+
+ * | protocol id (1 byte) | transform count (1 byte) | transform ids | infos | padding |    message    |
+ * +----------------------+--------------------------+---------------+-------+---------+---------------+
+ *
+ * infos:
+ *   0x01 key value:     count (2 bytes), [key length (2 bytes), key, value length (2 bytes), value]...
+ *   0x10 int key value: count (2 bytes), [key (2 bytes), value length (2 bytes), value]...
+ *   0x11 acl token:     length (2 bytes), token
+ */
+func decodeTTHeader(frame *Frame, b []byte) (int, error) {
+	if len(b) < ttheaderFixedSize {
+		return 0, errIncomplete
+	}
+	frameLen, err := readFrameLength(b)
+	if err != nil {
+		return 0, err
+	}
+	frame.Transport = TransportTTHeader
+	frame.Flags = binary.BigEndian.Uint16(b[6:])
+	headerEnd := ttheaderFixedSize + int(binary.BigEndian.Uint16(b[12:]))*ttheaderHeaderSizeFactor
+	// the header holds at least the protocol id and the transform count
+	if headerEnd < ttheaderFixedSize+2 || headerEnd+2 > frameLen {
+		return 0, errors.New("[xprotocol][thrift] invalid ttheader header size")
+	}
+	header := b[ttheaderFixedSize:headerEnd]
+	switch header[0] {
+	case ttheaderProtocolBinary:
+		frame.Protocol = ProtocolBinary
+	case ttheaderProtocolCompact:
+		frame.Protocol = ProtocolCompact
+	default:
+		return 0, fmt.Errorf("[xprotocol][thrift] unsupported ttheader protocol id: %d", header[0])
+	}
+	if header[1] != 0 {
+		return 0, errors.New("[xprotocol][thrift] ttheader transforms are not supported")
+	}
+	if err := decodeTTHeaderInfos(frame, header[2:]); err != nil {
+		return 0, err
+	}
+
+	message := b[headerEnd:frameLen]
+	n, err := decodeMessageBegin(frame, message)
+	if err != nil {
+		return 0, truncated(err)
+	}
+	frame.payload = copyBytes(message[n:])
+	return frameLen, nil
+}
+
+func decodeTTHeaderInfos(frame *Frame, b []byte) error {
+	r := &infoReader{b: b}
+	for r.err == nil && len(r.b) > 0 {
+		switch id := r.uint8(); id {
+		case ttheaderInfoPadding:
+			// the rest are paddings
+			return nil
+		case ttheaderInfoKeyValue:
+			for i := r.uint16(); i > 0 && r.err == nil; i-- {
+				k := r.string()
+				v := r.string()
+				if r.err == nil {
+					frame.Set(k, v)
+				}
+			}
+		case ttheaderInfoIntKeyValue:
+			if frame.IntKeyValue == nil {
+				frame.IntKeyValue = map[uint16]string{}
+			}
+			for i := r.uint16(); i > 0 && r.err == nil; i-- {
+				k := r.uint16()
+				v := r.string()
+				if r.err == nil {
+					frame.IntKeyValue[k] = v
+				}
+			}
+		case ttheaderInfoACLToken:
+			frame.ACLToken = r.string()
+		default:
+			return fmt.Errorf("[xprotocol][thrift] unknown ttheader info id: %d", id)
+		}
+	}
+	return r.err
+}
+
+var errInvalidInfo = errors.New("[xprotocol][thrift] invalid ttheader info")
+
+type infoReader struct {
+	b   []byte
+	err error
+}
+
+func (r *infoReader) uint8() byte {
+	if r.err != nil || len(r.b) < 1 {
+		r.err = errInvalidInfo
+		return 0
+	}
+	v := r.b[0]
+	r.b = r.b[1:]
+	return v
+}
+
+func (r *infoReader) uint16() uint16 {
+	if r.err != nil || len(r.b) < 2 {
+		r.err = errInvalidInfo
+		return 0
+	}
+	v := binary.BigEndian.Uint16(r.b)
+	r.b = r.b[2:]
+	return v
+}
+
+func (r *infoReader) string() string {
+	n := int(r.uint16())
+	if r.err != nil || len(r.b) < n {
+		r.err = errInvalidInfo
+		return ""
+	}
+	v := string(r.b[:n])
+	r.b = r.b[n:]
+	return v
+}
+
+// truncated converts errIncomplete in a complete frame to an error
+func truncated(err error) error {
+	if err == errIncomplete {
+		return errors.New("[xprotocol][thrift] truncated message")
+	}
+	return err
+}
+
+func copyBytes(b []byte) []byte {
+	return append(make([]byte, 0, len(b)), b...)
+}
+
+// decodeMessageBegin decodes the message name, type and seq id, returns the length of the message begin
+func decodeMessageBegin(frame *Frame, b []byte) (int, error) {
+	if frame.Protocol == ProtocolCompact {
+		return decodeCompactMessageBegin(frame, b)
+	}
+	return decodeBinaryMessageBegin(frame, b)
+}
+
+// binary: version and type (4 bytes), name length (4 bytes), name, seq id (4 bytes)
+func decodeBinaryMessageBegin(frame *Frame, b []byte) (int, error) {
+	if len(b) < 8 {
+		return 0, errIncomplete
+	}
+	version := binary.BigEndian.Uint32(b)
+	if version&binaryVersionMask != binaryVersion1 {
+		return 0, fmt.Errorf("[xprotocol][thrift] bad binary protocol version: %x", version)
+	}
+	frame.MessageType = thrift.TMessageType(version & binaryTypeMask)
+	nameLen := int(int32(binary.BigEndian.Uint32(b[4:])))
+	if nameLen < 0 || nameLen > MaxFrameSize {
+		return 0, fmt.Errorf("[xprotocol][thrift] bad message name length: %d", nameLen)
+	}
+	n := 8 + nameLen + 4
+	if len(b) < n {
+		return 0, errIncomplete
+	}
+	frame.MessageName = string(b[8 : 8+nameLen])
+	frame.SeqId = int32(binary.BigEndian.Uint32(b[8+nameLen:]))
+	return n, nil
+}
+
+// compact: protocol id (1 byte), version and type (1 byte), seq id (varint), name length (varint), name
+func decodeCompactMessageBegin(frame *Frame, b []byte) (int, error) {
+	if len(b) < 2 {
+		return 0, errIncomplete
+	}
+	frame.MessageType = thrift.TMessageType(b[1] >> compactTypeShift)
+	n := 2
+	seqID, m, err := readVarint(b[n:])
+	if err != nil {
+		return 0, err
+	}
+	n += m
+	nameLen, m, err := readVarint(b[n:])
+	if err != nil {
+		return 0, err
+	}
+	n += m
+	if nameLen > MaxFrameSize {
+		return 0, fmt.Errorf("[xprotocol][thrift] bad message name length: %d", nameLen)
+	}
+	if len(b) < n+int(nameLen) {
+		return 0, errIncomplete
+	}
+	frame.SeqId = int32(uint32(seqID))
+	frame.MessageName = string(b[n : n+int(nameLen)])
+	return n + int(nameLen), nil
+}
+
+// readVarint reads an unsigned LEB128 varint
+func readVarint(b []byte) (uint64, int, error) {
+	v, n := binary.Uvarint(b)
+	if n == 0 {
+		return 0, 0, errIncomplete
+	}
+	if n < 0 {
+		return 0, 0, errors.New("[xprotocol][thrift] varint overflow")
+	}
+	return v, n, nil
+}
+
+// the compact protocol types
+const (
+	compactBooleanTrue  = 0x01
+	compactBooleanFalse = 0x02
+	compactByte         = 0x03
+	compactI16          = 0x04
+	compactI32          = 0x05
+	compactI64          = 0x06
+	compactDouble       = 0x07
+	compactBinary       = 0x08
+	compactList         = 0x09
+	compactSet          = 0x0A
+	compactMap          = 0x0B
+	compactStruct       = 0x0C
+)
+
+// skipStruct returns the length of the struct
+func skipStruct(proto string, b []byte, depth int) (int, error) {
+	if proto == ProtocolCompact {
+		return skipCompact(b, compactStruct, depth)
+	}
+	return skipBinary(b, thrift.STRUCT, depth)
+}
+
+func skipBinary(b []byte, t thrift.TType, depth int) (int, error) {
+	if depth > maxSkipDepth {
+		return 0, errors.New("[xprotocol][thrift] struct depth limit exceeded")
+	}
+	need := func(n int) (int, error) {
+		if len(b) < n {
+			return 0, errIncomplete
+		}
+		return n, nil
+	}
+	switch t {
+	case thrift.BOOL, thrift.BYTE:
+		return need(1)
+	case thrift.I16:
+		return need(2)
+	case thrift.I32:
+		return need(4)
+	case thrift.I64, thrift.DOUBLE:
+		return need(8)
+	case thrift.STRING:
+		if len(b) < 4 {
+			return 0, errIncomplete
+		}
+		size := int(int32(binary.BigEndian.Uint32(b)))
+		if size < 0 || size > MaxFrameSize {
+			return 0, fmt.Errorf("[xprotocol][thrift] bad string length: %d", size)
+		}
+		return need(4 + size)
+	case thrift.STRUCT:
+		n := 0
+		for {
+			if len(b) < n+1 {
+				return 0, errIncomplete
+			}
+			fieldType := thrift.TType(b[n])
+			n++
+			if fieldType == thrift.STOP {
+				return n, nil
+			}
+			// field id
+			n += 2
+			if len(b) < n {
+				return 0, errIncomplete
+			}
+			m, err := skipBinary(b[n:], fieldType, depth+1)
+			if err != nil {
+				return 0, err
+			}
+			n += m
+		}
+	case thrift.MAP:
+		if len(b) < 6 {
+			return 0, errIncomplete
+		}
+		keyType, valueType := thrift.TType(b[0]), thrift.TType(b[1])
+		size := int(int32(binary.BigEndian.Uint32(b[2:])))
+		if size < 0 || size > MaxFrameSize {
+			return 0, fmt.Errorf("[xprotocol][thrift] bad map size: %d", size)
+		}
+		n := 6
+		for i := 0; i < size; i++ {
+			for _, et := range [2]thrift.TType{keyType, valueType} {
+				m, err := skipBinary(b[n:], et, depth+1)
+				if err != nil {
+					return 0, err
+				}
+				n += m
+			}
+		}
+		return n, nil
+	case thrift.SET, thrift.LIST:
+		if len(b) < 5 {
+			return 0, errIncomplete
+		}
+		elemType := thrift.TType(b[0])
+		size := int(int32(binary.BigEndian.Uint32(b[1:])))
+		if size < 0 || size > MaxFrameSize {
+			return 0, fmt.Errorf("[xprotocol][thrift] bad list size: %d", size)
+		}
+		n := 5
+		for i := 0; i < size; i++ {
+			m, err := skipBinary(b[n:], elemType, depth+1)
+			if err != nil {
+				return 0, err
+			}
+			n += m
+		}
+		return n, nil
+	}
+	return 0, fmt.Errorf("[xprotocol][thrift] unknown binary type: %d", t)
+}
+
+func skipCompact(b []byte, t byte, depth int) (int, error) {
+	if depth > maxSkipDepth {
+		return 0, errors.New("[xprotocol][thrift] struct depth limit exceeded")
+	}
+	switch t {
+	case compactBooleanTrue, compactBooleanFalse, compactByte:
+		if len(b) < 1 {
+			return 0, errIncomplete
+		}
+		return 1, nil
+	case compactI16, compactI32, compactI64:
+		_, n, err := readVarint(b)
+		return n, err
+	case compactDouble:
+		if len(b) < 8 {
+			return 0, errIncomplete
+		}
+		return 8, nil
+	case compactBinary:
+		size, n, err := readVarint(b)
+		if err != nil {
+			return 0, err
+		}
+		if size > MaxFrameSize {
+			return 0, fmt.Errorf("[xprotocol][thrift] bad binary length: %d", size)
+		}
+		if len(b) < n+int(size) {
+			return 0, errIncomplete
+		}
+		return n + int(size), nil
+	case compactStruct:
+		n := 0
+		for {
+			if len(b) < n+1 {
+				return 0, errIncomplete
+			}
+			header := b[n]
+			n++
+			fieldType := header & 0x0f
+			if fieldType == 0 {
+				return n, nil
+			}
+			if header>>4 == 0 {
+				// the field id is not a delta
+				_, m, err := readVarint(b[n:])
+				if err != nil {
+					return 0, err
+				}
+				n += m
+			}
+			if fieldType == compactBooleanTrue || fieldType == compactBooleanFalse {
+				// the bool value is the field type
+				continue
+			}
+			m, err := skipCompact(b[n:], fieldType, depth+1)
+			if err != nil {
+				return 0, err
+			}
+			n += m
+		}
+	case compactMap:
+		size, n, err := readVarint(b)
+		if err != nil {
+			return 0, err
+		}
+		if size == 0 {
+			return n, nil
+		}
+		if size > MaxFrameSize {
+			return 0, fmt.Errorf("[xprotocol][thrift] bad map size: %d", size)
+		}
+		if len(b) < n+1 {
+			return 0, errIncomplete
+		}
+		keyType, valueType := b[n]>>4, b[n]&0x0f
+		n++
+		for i := uint64(0); i < size; i++ {
+			for _, et := range [2]byte{keyType, valueType} {
+				m, err := skipCompact(b[n:], et, depth+1)
+				if err != nil {
+					return 0, err
+				}
+				n += m
+			}
+		}
+		return n, nil
+	case compactList, compactSet:
+		if len(b) < 1 {
+			return 0, errIncomplete
+		}
+		size, elemType, n := uint64(b[0]>>4), b[0]&0x0f, 1
+		if size == 15 {
+			var m int
+			var err error
+			if size, m, err = readVarint(b[n:]); err != nil {
+				return 0, err
+			}
+			n += m
+		}
+		if size > MaxFrameSize {
+			return 0, fmt.Errorf("[xprotocol][thrift] bad list size: %d", size)
+		}
+		for i := uint64(0); i < size; i++ {
+			m, err := skipCompact(b[n:], elemType, depth+1)
+			if err != nil {
+				return 0, err
+			}
+			n += m
+		}
+		return n, nil
+	}
+	return 0, fmt.Errorf("[xprotocol][thrift] unknown compact type: %d", t)
+}
@@ -0,0 +1,155 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package thrift
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"mosn.io/pkg/buffer"
+
+	"mosn.io/mosn/pkg/types"
+)
+
+// encodeFrame encodes the frame in its transport and protocol, the message begin is rebuilt
+// so the modified seq id and the TTHeader headers are sent
+func encodeFrame(ctx context.Context, frame *Frame) (types.IoBuffer, error) {
+	message, err := encodeMessage(frame)
+	if err != nil {
+		return nil, err
+	}
+	switch frame.Transport {
+	case TransportUnframed:
+		return buffer.NewIoBufferBytes(message), nil
+	case TransportFramed, "":
+		buf := buffer.GetIoBuffer(frameLenSize + len(message))
+		buf.WriteUint32(uint32(len(message)))
+		buf.Write(message)
+		return buf, nil
+	case TransportTTHeader:
+		return encodeTTHeader(frame, message)
+	}
+	return nil, fmt.Errorf("[xprotocol][thrift] unknown transport: %s", frame.Transport)
+}
+
+func newProtocol(name string, trans thrift.TTransport) (thrift.TProtocol, error) {
+	switch name {
+	case ProtocolBinary, "":
+		return thrift.NewTBinaryProtocolTransport(trans), nil
+	case ProtocolCompact:
+		return thrift.NewTCompactProtocol(trans), nil
+	}
+	return nil, fmt.Errorf("[xprotocol][thrift] unknown protocol: %s", name)
+}
+
+// encodeMessage returns the message begin followed by the payload
+func encodeMessage(frame *Frame) ([]byte, error) {
+	trans := thrift.NewTMemoryBufferLen(len(frame.MessageName) + len(frame.payload) + 16)
+	proto, err := newProtocol(frame.Protocol, trans)
+	if err != nil {
+		return nil, err
+	}
+	if err := proto.WriteMessageBegin(frame.MessageName, frame.MessageType, frame.SeqId); err != nil {
+		return nil, err
+	}
+	if _, err := trans.Write(frame.payload); err != nil {
+		return nil, err
+	}
+	return trans.Bytes(), nil
+}
+
+func encodeTTHeader(frame *Frame, message []byte) (types.IoBuffer, error) {
+	header := make([]byte, 0, 64)
+	switch frame.Protocol {
+	case ProtocolBinary, "":
+		header = append(header, ttheaderProtocolBinary)
+	case ProtocolCompact:
+		header = append(header, ttheaderProtocolCompact)
+	}
+	// no transforms
+	header = append(header, 0)
+
+	// the key values are sorted to make the encoded header stable
+	var keys []string
+	frame.Range(func(k, v string) bool {
+		if !reservedHeaders[k] {
+			keys = append(keys, k)
+		}
+		return true
+	})
+	if len(keys) > 0 {
+		sort.Strings(keys)
+		header = append(header, ttheaderInfoKeyValue)
+		header = appendUint16(header, uint16(len(keys)))
+		for _, k := range keys {
+			v, _ := frame.Get(k)
+			header = appendString(header, k)
+			header = appendString(header, v)
+		}
+	}
+	if len(frame.IntKeyValue) > 0 {
+		intKeys := make([]int, 0, len(frame.IntKeyValue))
+		for k := range frame.IntKeyValue {
+			intKeys = append(intKeys, int(k))
+		}
+		sort.Ints(intKeys)
+		header = append(header, ttheaderInfoIntKeyValue)
+		header = appendUint16(header, uint16(len(intKeys)))
+		for _, k := range intKeys {
+			header = appendUint16(header, uint16(k))
+			header = appendString(header, frame.IntKeyValue[uint16(k)])
+		}
+	}
+	if frame.ACLToken != "" {
+		header = append(header, ttheaderInfoACLToken)
+		header = appendString(header, frame.ACLToken)
+	}
+	for len(header)%ttheaderHeaderSizeFactor != 0 {
+		header = append(header, ttheaderInfoPadding)
+	}
+	if len(header)/ttheaderHeaderSizeFactor > 0xffff {
+		return nil, fmt.Errorf("[xprotocol][thrift] ttheader header size %d is too large", len(header))
+	}
+
+	// the length does not include itself
+	frameLen := ttheaderFixedSize - frameLenSize + len(header) + len(message)
+	buf := buffer.GetIoBuffer(frameLenSize + frameLen)
+	buf.WriteUint32(uint32(frameLen))
+	buf.WriteUint16(ttheaderMagic)
+	buf.WriteUint16(frame.Flags)
+	buf.WriteUint32(uint32(frame.SeqId))
+	buf.WriteUint16(uint16(len(header) / ttheaderHeaderSizeFactor))
+	buf.Write(header)
+	buf.Write(message)
+	return buf, nil
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+// appendString appends a string with the 2 bytes length, the string longer than 65535 bytes is truncated
+func appendString(b []byte, s string) []byte {
+	if len(s) > 0xffff {
+		s = s[:0xffff]
+	}
+	b = appendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
@@ -0,0 +1,41 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package thrift
+
+import (
+	"encoding/binary"
+
+	"mosn.io/api"
+)
+
+// thriftMatcher matches the unframed message begin, the TTHeader magic and the framed message begin
+func thriftMatcher(data []byte) api.MatchResult {
+	if len(data) < 2 {
+		return api.MatchAgain
+	}
+	if isMessageBegin(data) {
+		return api.MatchSuccess
+	}
+	if len(data) < frameLenSize+2 {
+		return api.MatchAgain
+	}
+	if binary.BigEndian.Uint16(data[frameLenSize:]) == ttheaderMagic || isMessageBegin(data[frameLenSize:]) {
+		return api.MatchSuccess
+	}
+	return api.MatchFailed
+}
@@ -0,0 +1,143 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package thrift
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync/atomic"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+/**
+ * Thrift message, https://github.com/apache/thrift/blob/master/doc/specs/thrift-binary-protocol.md
+ *
+ * binary protocol message begin:
+ * +------------------------------+-------------------+------------+----------------+
+ * | 0x8001 (2) | 0x00 | type (1) | name length (4)   | name       | seq id (4)     |
+ * +------------------------------+-------------------+------------+----------------+
+ *
+ * compact protocol message begin:
+ * +----------+----------------------------------+-----------------+----------------------+------+
+ * | 0x82 (1) | type (3 bits), version (5 bits)  | seq id (varint) | name length (varint) | name |
+ * +----------+----------------------------------+-----------------+----------------------+------+
+ *
+ * the message struct follows the message begin. In the unframed transport the messages are sent
+ * one by one, in the framed transport each message has a 4 bytes length prefix, and in the TTHeader
+ * transport each message has a header carrying the key values, see decodeTTHeader.
+ */
+
+type thriftProtocol struct{}
+
+func (proto thriftProtocol) Name() types.ProtocolName {
+	return ProtocolName
+}
+
+func (proto thriftProtocol) Encode(ctx context.Context, model interface{}) (types.IoBuffer, error) {
+	if frame, ok := model.(*Frame); ok {
+		return encodeFrame(ctx, frame)
+	}
+	log.Proxy.Errorf(ctx, "[protocol][thrift] encode with unknown command : %+v", model)
+	return nil, api.ErrUnknownType
+}
+
+func (proto thriftProtocol) Decode(ctx context.Context, data types.IoBuffer) (interface{}, error) {
+	if data.Len() < 2 {
+		return nil, nil
+	}
+	return decodeFrame(ctx, data)
+}
+
+// heartbeater
+func (proto thriftProtocol) Trigger(ctx context.Context, requestId uint64) api.XFrame {
+	// thrift has no standard heartbeat, and the transport of the upstream is unknown
+	return nil
+}
+
+// Reply replies the heartbeat request with an exception, so the clients without the
+// heartbeat method can handle it as well
+func (proto thriftProtocol) Reply(ctx context.Context, request api.XFrame) api.XRespFrame {
+	return newExceptionFrame(request, thrift.UNKNOWN_APPLICATION_EXCEPTION, "heartbeat")
+}
+
+// hijacker
+func (proto thriftProtocol) Hijack(ctx context.Context, request api.XFrame, statusCode uint32) api.XRespFrame {
+	status, ok := thriftMosnStatusMap[int(statusCode)]
+	if !ok {
+		status = thriftStatusInfo{
+			Type: thrift.UNKNOWN_APPLICATION_EXCEPTION,
+			Msg:  fmt.Sprintf("%d status not define", statusCode),
+		}
+	}
+	return newExceptionFrame(request, status.Type, status.Msg)
+}
+
+// newExceptionFrame returns an exception message of the request, in the same transport and protocol
+func newExceptionFrame(request api.XFrame, exceptionType int32, msg string) *Frame {
+	frame := &Frame{
+		Header: Header{
+			Transport:    TransportFramed,
+			Protocol:     ProtocolBinary,
+			MessageType:  thrift.EXCEPTION,
+			SeqId:        int32(uint32(request.GetRequestId())),
+			CommonHeader: protocol.CommonHeader{},
+		},
+	}
+	if req, ok := request.(*Frame); ok {
+		frame.Transport = req.Transport
+		frame.Protocol = req.Protocol
+		frame.MessageName = req.MessageName
+	}
+	trans := thrift.NewTMemoryBuffer()
+	proto, err := newProtocol(frame.Protocol, trans)
+	if err == nil {
+		err = thrift.NewTApplicationException(exceptionType, msg).Write(proto)
+	}
+	if err != nil {
+		log.DefaultLogger.Errorf("[protocol][thrift] write exception failed: %v", err)
+	}
+	frame.payload = trans.Bytes()
+	frame.content = buffer.NewIoBufferBytes(frame.payload)
+	return frame
+}
+
+func (proto thriftProtocol) Mapping(httpStatusCode uint32) uint32 {
+	return httpStatusCode
+}
+
+// PoolMode returns whether pingpong or multiplex
+func (proto thriftProtocol) PoolMode() api.PoolMode {
+	return api.Multiplex
+}
+
+func (proto thriftProtocol) EnableWorkerPool() bool {
+	return true
+}
+
+// GenerateRequestID keeps the request id in the range of the positive seq id
+func (proto thriftProtocol) GenerateRequestID(streamID *uint64) uint64 {
+	return atomic.AddUint64(streamID, 1) & math.MaxInt32
+}
@@ -0,0 +1,275 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package thrift
+
+import (
+	"context"
+	"encoding/binary"
+	"net/http"
+	"testing"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+
+	"mosn.io/mosn/pkg/protocol"
+)
+
+// writeTestStruct writes a struct with the nested types, which are skipped by the unframed decoder
+func writeTestStruct(t *testing.T, proto thrift.TProtocol) {
+	require.Nil(t, proto.WriteStructBegin("args"))
+	require.Nil(t, proto.WriteFieldBegin("name", thrift.STRING, 1))
+	require.Nil(t, proto.WriteString("mosn"))
+	require.Nil(t, proto.WriteFieldBegin("ok", thrift.BOOL, 2))
+	require.Nil(t, proto.WriteBool(true))
+	require.Nil(t, proto.WriteFieldBegin("count", thrift.I64, 20))
+	require.Nil(t, proto.WriteI64(-12345678))
+	require.Nil(t, proto.WriteFieldBegin("ratio", thrift.DOUBLE, 21))
+	require.Nil(t, proto.WriteDouble(0.5))
+	require.Nil(t, proto.WriteFieldBegin("ids", thrift.LIST, 22))
+	require.Nil(t, proto.WriteListBegin(thrift.I32, 20))
+	for i := int32(0); i < 20; i++ {
+		require.Nil(t, proto.WriteI32(i))
+	}
+	require.Nil(t, proto.WriteFieldBegin("tags", thrift.MAP, 23))
+	require.Nil(t, proto.WriteMapBegin(thrift.STRING, thrift.BOOL, 2))
+	require.Nil(t, proto.WriteString("a"))
+	require.Nil(t, proto.WriteBool(false))
+	require.Nil(t, proto.WriteString("b"))
+	require.Nil(t, proto.WriteBool(true))
+	require.Nil(t, proto.WriteFieldBegin("empty", thrift.MAP, 24))
+	require.Nil(t, proto.WriteMapBegin(thrift.STRING, thrift.STRING, 0))
+	require.Nil(t, proto.WriteFieldBegin("inner", thrift.STRUCT, 25))
+	require.Nil(t, proto.WriteStructBegin("inner"))
+	require.Nil(t, proto.WriteFieldBegin("b", thrift.BYTE, 1))
+	require.Nil(t, proto.WriteByte(7))
+	require.Nil(t, proto.WriteFieldBegin("s", thrift.I16, 2))
+	require.Nil(t, proto.WriteI16(-2))
+	require.Nil(t, proto.WriteFieldStop())
+	require.Nil(t, proto.WriteFieldStop())
+}
+
+// newTestMessage returns the message begin and the message struct
+func newTestMessage(t *testing.T, protocol, name string, typeID thrift.TMessageType, seqID int32) ([]byte, []byte) {
+	trans := thrift.NewTMemoryBuffer()
+	proto, err := newProtocol(protocol, trans)
+	require.Nil(t, err)
+	require.Nil(t, proto.WriteMessageBegin(name, typeID, seqID))
+	begin := append([]byte(nil), trans.Bytes()...)
+	trans.Reset()
+	writeTestStruct(t, proto)
+	return begin, append([]byte(nil), trans.Bytes()...)
+}
+
+func framed(message []byte) []byte {
+	b := make([]byte, frameLenSize, frameLenSize+len(message))
+	binary.BigEndian.PutUint32(b, uint32(len(message)))
+	return append(b, message...)
+}
+
+func TestDecodeEncode(t *testing.T) {
+	proto := thriftProtocol{}
+	for _, transport := range []string{TransportUnframed, TransportFramed, TransportTTHeader} {
+		for _, protoName := range []string{ProtocolBinary, ProtocolCompact} {
+			begin, payload := newTestMessage(t, protoName, "EchoService:echo", thrift.CALL, 100)
+			frame := &Frame{
+				Header: Header{
+					Transport:    transport,
+					Protocol:     protoName,
+					MessageName:  "EchoService:echo",
+					MessageType:  thrift.CALL,
+					SeqId:        100,
+					CommonHeader: protocol.CommonHeader{},
+				},
+				payload: payload,
+			}
+			if transport == TransportTTHeader {
+				frame.Set("caller", "client")
+				frame.Set("env", "test")
+				frame.IntKeyValue = map[uint16]string{8: "idc"}
+				frame.ACLToken = "token"
+			}
+			buf, err := proto.Encode(context.Background(), frame)
+			require.Nil(t, err)
+			data := buf.Bytes()
+			switch transport {
+			case TransportUnframed:
+				assert.Equal(t, append(begin, payload...), data)
+			case TransportFramed:
+				assert.Equal(t, framed(append(begin, payload...)), data)
+			}
+			assert.Equal(t, api.MatchSuccess, thriftMatcher(data))
+
+			// the incomplete data
+			for _, n := range []int{1, 5, len(data) / 2, len(data) - 1} {
+				cmd, err := proto.Decode(context.Background(), buffer.NewIoBufferBytes(data[:n]))
+				assert.Nil(t, err, "%s %s %d", transport, protoName, n)
+				assert.Nil(t, cmd, "%s %s %d", transport, protoName, n)
+			}
+
+			// decode with a following message
+			input := buffer.NewIoBufferBytes(append(append([]byte(nil), data...), data[:3]...))
+			cmd, err := proto.Decode(context.Background(), input)
+			require.Nil(t, err, "%s %s", transport, protoName)
+			assert.Equal(t, 3, input.Len())
+			decoded := cmd.(*Frame)
+			assert.Equal(t, transport, decoded.Transport)
+			assert.Equal(t, protoName, decoded.Protocol)
+			assert.Equal(t, uint64(100), decoded.GetRequestId())
+			assert.Equal(t, api.Request, decoded.GetStreamType())
+			assert.Equal(t, payload, decoded.GetData().Bytes())
+			for k, v := range map[string]string{
+				ServiceNameHeader:     "EchoService",
+				MethodNameHeader:      "echo",
+				MessageTypeNameHeader: "1",
+				TransportNameHeader:   transport,
+				ProtocolNameHeader:    protoName,
+			} {
+				got, ok := decoded.Get(k)
+				assert.True(t, ok)
+				assert.Equal(t, v, got)
+			}
+			if transport == TransportTTHeader {
+				v, _ := decoded.Get("caller")
+				assert.Equal(t, "client", v)
+				assert.Equal(t, map[uint16]string{8: "idc"}, decoded.IntKeyValue)
+				assert.Equal(t, "token", decoded.ACLToken)
+			}
+
+			// the request id is changed by the stream layer
+			decoded.SetRequestId(200)
+			buf, err = proto.Encode(context.Background(), decoded)
+			require.Nil(t, err)
+			cmd, err = proto.Decode(context.Background(), buf)
+			require.Nil(t, err)
+			assert.Equal(t, int32(200), cmd.(*Frame).SeqId)
+			assert.Equal(t, payload, cmd.(*Frame).payload)
+		}
+	}
+}
+
+func TestDecodeMethodWithoutService(t *testing.T) {
+	begin, payload := newTestMessage(t, ProtocolBinary, "echo", thrift.ONEWAY, 1)
+	cmd, err := thriftProtocol{}.Decode(context.Background(), buffer.NewIoBufferBytes(framed(append(begin, payload...))))
+	require.Nil(t, err)
+	frame := cmd.(*Frame)
+	assert.Equal(t, api.RequestOneWay, frame.GetStreamType())
+	method, _ := frame.Get(MethodNameHeader)
+	assert.Equal(t, "echo", method)
+	_, ok := frame.Get(ServiceNameHeader)
+	assert.False(t, ok)
+}
+
+func TestDecodeError(t *testing.T) {
+	proto := thriftProtocol{}
+	for name, data := range map[string][]byte{
+		"unknown transport":  {0, 0, 0, 10, 1, 2},
+		"frame too large":    {0x7f, 0, 0, 0, 0x80, 0x01},
+		"bad name length":    {0x80, 0x01, 0, 1, 0xff, 0xff, 0xff, 0xff},
+		"truncated message":  framed([]byte{0x80, 0x01, 0, 1, 0, 0, 0, 10, 'a'}),
+		"ttheader protocol":  {0, 0, 0, 14, 0x10, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0x05, 0, 0, 0},
+		"ttheader transform": {0, 0, 0, 14, 0x10, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 1, 0, 0},
+		"ttheader no header": {0, 0, 0, 12, 0x10, 0x00, 0, 0, 0, 0, 0, 1, 0, 0, 0x80, 0x01},
+	} {
+		_, err := proto.Decode(context.Background(), buffer.NewIoBufferBytes(data))
+		assert.NotNil(t, err, name)
+	}
+}
+
+func TestHijackAndReply(t *testing.T) {
+	proto := thriftProtocol{}
+	for _, protocol := range []string{ProtocolBinary, ProtocolCompact} {
+		request := &Frame{
+			Header: Header{
+				Transport:   TransportTTHeader,
+				Protocol:    protocol,
+				MessageName: "Svc:echo",
+				MessageType: thrift.CALL,
+				SeqId:       9,
+			},
+		}
+		for statusCode, expected := range map[uint32]thriftStatusInfo{
+			api.NoHealthUpstreamCode: thriftMosnStatusMap[api.NoHealthUpstreamCode],
+			99:                       {Type: thrift.UNKNOWN_APPLICATION_EXCEPTION, Msg: "99 status not define"},
+		} {
+			resp := proto.Hijack(context.Background(), request, statusCode)
+			buf, err := proto.Encode(context.Background(), resp)
+			require.Nil(t, err)
+			cmd, err := proto.Decode(context.Background(), buf)
+			require.Nil(t, err)
+			frame := cmd.(*Frame)
+			assert.Equal(t, TransportTTHeader, frame.Transport)
+			assert.Equal(t, protocol, frame.Protocol)
+			assert.Equal(t, "Svc:echo", frame.MessageName)
+			assert.Equal(t, uint64(9), frame.GetRequestId())
+			assert.Equal(t, api.Response, frame.GetStreamType())
+
+			iprot, _ := newProtocol(protocol, thrift.NewStreamTransportR(frame.GetData()))
+			exception := thrift.NewTApplicationException(0, "")
+			err = exception.Read(iprot)
+			require.Nil(t, err)
+			assert.Equal(t, expected.Type, exception.TypeId())
+			assert.Equal(t, expected.Msg, exception.Error())
+
+			code, err := StatusMapping{}.MappingHeaderStatusCode(context.Background(), frame)
+			require.Nil(t, err)
+			assert.Equal(t, http.StatusInternalServerError, code)
+		}
+	}
+
+	heartbeat := &Frame{
+		Header: Header{
+			Transport:   TransportUnframed,
+			Protocol:    ProtocolBinary,
+			MessageName: HeartbeatMethod,
+			MessageType: thrift.CALL,
+			SeqId:       3,
+		},
+	}
+	assert.True(t, heartbeat.IsHeartbeatFrame())
+	assert.Nil(t, proto.Trigger(context.Background(), 1))
+	resp := proto.Reply(context.Background(), heartbeat)
+	buf, err := proto.Encode(context.Background(), resp)
+	require.Nil(t, err)
+	cmd, err := proto.Decode(context.Background(), buf)
+	require.Nil(t, err)
+	frame := cmd.(*Frame)
+	assert.Equal(t, TransportUnframed, frame.Transport)
+	assert.Equal(t, thrift.EXCEPTION, frame.MessageType)
+	assert.Equal(t, uint64(3), frame.GetRequestId())
+}
+
+func TestMatcher(t *testing.T) {
+	for _, cas := range []struct {
+		data     []byte
+		expected api.MatchResult
+	}{
+		{[]byte{0x80}, api.MatchAgain},
+		{[]byte{0x80, 0x01}, api.MatchSuccess},
+		{[]byte{0x82, 0x21}, api.MatchSuccess},
+		{[]byte{0, 0, 0}, api.MatchAgain},
+		{[]byte{0, 0, 0, 10, 0x80, 0x01}, api.MatchSuccess},
+		{[]byte{0, 0, 0, 10, 0x82, 0x21}, api.MatchSuccess},
+		{[]byte{0, 0, 0, 10, 0x10, 0x00}, api.MatchSuccess},
+		{[]byte{0, 0, 0, 10, 0xda, 0xbc}, api.MatchFailed},
+	} {
+		assert.Equal(t, cas.expected, thriftMatcher(cas.data), "%v", cas.data)
+	}
+}
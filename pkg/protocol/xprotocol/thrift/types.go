@@ -0,0 +1,114 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package thrift
+
+import (
+	"github.com/apache/thrift/lib/go/thrift"
+	"mosn.io/api"
+)
+
+const (
+	ProtocolName = "thrift"
+)
+
+// the transports of the frames
+const (
+	TransportUnframed = "unframed"
+	TransportFramed   = "framed"
+	// TransportTTHeader is the TTHeader transport, which carries the key value headers before the message
+	TransportTTHeader = "ttheader"
+)
+
+// the protocols of the messages
+const (
+	ProtocolBinary  = "binary"
+	ProtocolCompact = "compact"
+)
+
+const (
+	frameLenSize = 4
+	// MaxFrameSize limits the size of a frame, the same as the default of thrift
+	MaxFrameSize = 16384000
+
+	binaryVersionMask = 0xffff0000
+	binaryVersion1    = 0x80010000
+	binaryTypeMask    = 0x000000ff
+
+	compactProtocolID  = 0x82
+	compactVersion     = 1
+	compactVersionMask = 0x1f
+	compactTypeShift   = 5
+
+	ttheaderMagic            = 0x1000
+	ttheaderFixedSize        = 14
+	ttheaderProtocolBinary   = 0x00
+	ttheaderProtocolCompact  = 0x02
+	ttheaderInfoPadding      = 0x00
+	ttheaderInfoKeyValue     = 0x01
+	ttheaderInfoIntKeyValue  = 0x10
+	ttheaderInfoACLToken     = 0x11
+	ttheaderHeaderSizeFactor = 4
+
+	// maxSkipDepth limits the nested depth of the skipped structs
+	maxSkipDepth = 64
+)
+
+// the headers exposed by the frames, the service is the prefix of the multiplexed protocol method name
+const (
+	ServiceNameHeader     = "service"
+	MethodNameHeader      = "method"
+	MessageTypeNameHeader = "messageType"
+	TransportNameHeader   = "transport"
+	ProtocolNameHeader    = "protocol"
+)
+
+// MultiplexedSeparator separates the service and method in the multiplexed protocol method name
+const MultiplexedSeparator = ":"
+
+// HeartbeatMethod is the method name of the heartbeat requests, which are replied by mosn
+const HeartbeatMethod = "__mosn_heartbeat__"
+
+// reservedHeaders are exposed by the frames, they are not sent as the TTHeader key values
+var reservedHeaders = map[string]bool{
+	ServiceNameHeader:     true,
+	MethodNameHeader:      true,
+	MessageTypeNameHeader: true,
+	TransportNameHeader:   true,
+	ProtocolNameHeader:    true,
+}
+
+type thriftStatusInfo struct {
+	Type int32
+	Msg  string
+}
+
+var (
+	thriftMosnStatusMap = map[int]thriftStatusInfo{
+		api.CodecExceptionCode:    {Type: thrift.PROTOCOL_ERROR, Msg: "0|codec exception"},
+		api.UnknownCode:           {Type: thrift.UNKNOWN_APPLICATION_EXCEPTION, Msg: "2|unknown"},
+		api.DeserialExceptionCode: {Type: thrift.PROTOCOL_ERROR, Msg: "3|deserial exception"},
+		api.SuccessCode:           {Type: thrift.UNKNOWN_APPLICATION_EXCEPTION, Msg: "200|success"},
+		api.PermissionDeniedCode:  {Type: thrift.INTERNAL_ERROR, Msg: "403|permission denied"},
+		api.RouterUnavailableCode: {Type: thrift.UNKNOWN_METHOD, Msg: "404|router unavailable"},
+		api.InternalErrorCode:     {Type: thrift.INTERNAL_ERROR, Msg: "500|internal error"},
+		api.NoHealthUpstreamCode:  {Type: thrift.UNKNOWN_APPLICATION_EXCEPTION, Msg: "502|no health upstream"},
+		api.UpstreamOverFlowCode:  {Type: thrift.UNKNOWN_APPLICATION_EXCEPTION, Msg: "503|upstream overflow"},
+		api.TimeoutExceptionCode:  {Type: thrift.INTERNAL_ERROR, Msg: "504|timeout"},
+		api.LimitExceededCode:     {Type: thrift.UNKNOWN_APPLICATION_EXCEPTION, Msg: "509|limit exceeded"},
+	}
+)
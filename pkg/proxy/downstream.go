@@ -36,6 +36,7 @@ import (
 	"mosn.io/mosn/pkg/log"
 	"mosn.io/mosn/pkg/protocol"
 	"mosn.io/mosn/pkg/router"
+	"mosn.io/mosn/pkg/tap"
 	"mosn.io/mosn/pkg/trace"
 	"mosn.io/mosn/pkg/track"
 	"mosn.io/mosn/pkg/types"
@@ -119,6 +120,9 @@ type downStream struct {
 	snapshot types.ClusterSnapshot
 
 	phase types.Phase
+
+	// tap is not nil if the stream is matched by any active tap session
+	tap *tap.Capture
 }
 
 func newActiveStream(ctx context.Context, proxy *proxy, responseSender types.StreamSender, span api.Span) *downStream {
@@ -255,6 +259,10 @@ func (s *downStream) cleanStream() {
 	// write access log
 	s.writeLog()
 
+	if s.tap != nil {
+		s.finishTap()
+	}
+
 	// tell filters it's time to destroy
 	// after this func call, we should never touch the s.streamFilterChain
 	s.streamFilterChain.destroy()
@@ -301,6 +309,8 @@ func (s *downStream) requestMetrics() {
 			s.proxy.listenerStats.DownstreamRequestFailed.Inc(1)
 		}
 
+		s.routeMetrics(streamDurationNs)
+
 		s.requestInfo.SetProcessTimeDuration(time.Duration(processTime))
 
 	}
@@ -309,6 +319,30 @@ func (s *downStream) requestMetrics() {
 	s.proxy.listenerStats.DownstreamRequestActive.Dec(1)
 }
 
+// routeMetrics records the virtual host and route level metrics if enabled
+func (s *downStream) routeMetrics(streamDurationNs int64) {
+	cfg := s.proxy.config.StatsConfig
+	if cfg == nil || !(cfg.VirtualHost || cfg.Route) {
+		return
+	}
+	if s.route == nil {
+		return
+	}
+	rule := s.route.RouteRule()
+	if rule == nil || reflect.ValueOf(rule).IsNil() || rule.VirtualHost() == nil {
+		return
+	}
+	code := s.requestInfo.ResponseCode()
+	failed := s.isRequestFailed()
+	vhName := rule.VirtualHost().Name()
+	if cfg.VirtualHost {
+		getVirtualHostStats(s.proxy.config.RouterConfigName, s.proxy.config.Name, vhName).updateRequest(streamDurationNs, code, failed)
+	}
+	if cfg.Route {
+		getRouteStats(s.proxy.config.RouterConfigName, s.proxy.config.Name, vhName, getRouteName(rule)).updateRequest(streamDurationNs, code, failed)
+	}
+}
+
 // isRequestFailed marks request failed due to mosn process
 func (s *downStream) isRequestFailed() bool {
 	return s.requestInfo.GetResponseFlag(types.MosnProcessFailedFlags)
@@ -336,6 +370,37 @@ func (s *downStream) writeLog() {
 	s.streamFilterChain.Log(s.context, s.downstreamReqHeaders, s.downstreamRespHeaders, s.requestInfo)
 }
 
+// startTap captures the request if the stream is matched by any active tap session
+func (s *downStream) startTap() {
+	var body []byte
+	if s.downstreamReqDataBuf != nil {
+		body = s.downstreamReqDataBuf.Bytes()
+	}
+	s.tap = tap.NewCapture(s.proxy.listenerName, s.requestInfo.DownstreamRemoteAddress(), s.downstreamReqHeaders, body)
+}
+
+func (s *downStream) finishTap() {
+	var routeName string
+	if s.route != nil {
+		if rule := s.route.RouteRule(); rule != nil && !reflect.ValueOf(rule).IsNil() {
+			routeName = tapRouteName(rule)
+		}
+	}
+	s.tap.Finish(routeName, s.requestInfo)
+}
+
+// tapRouteName returns the route's configured name, or the path matcher if the name is empty,
+// the name is matched by the tap sessions and is not used as a metrics label.
+func tapRouteName(rule api.RouteRule) string {
+	if named, ok := rule.(types.NamedRouteRule); ok && named.Name() != "" {
+		return named.Name()
+	}
+	if pmc := rule.PathMatchCriterion(); pmc != nil {
+		return pmc.Matcher()
+	}
+	return ""
+}
+
 func (s *downStream) delete() {
 	if s.proxy != nil {
 		s.proxy.deleteActiveStream(s)
@@ -374,6 +439,10 @@ func (s *downStream) OnReceive(ctx context.Context, headers types.HeaderMap, dat
 	s.downstreamReqTrailers = trailers
 	s.tracks = track.TrackBufferByContext(ctx).Tracks
 
+	if tap.Active() {
+		s.startTap()
+	}
+
 	if log.Proxy.GetLogLevel() >= log.DEBUG {
 		log.Proxy.Debugf(s.context, "[proxy] [downstream] OnReceive")
 		log.Proxy.Tracef(s.context, "[proxy] [downstream] OnReceive headers:%+v, data:%+v, trailers:%+v", headers, data, trailers)
@@ -612,6 +681,14 @@ func (s *downStream) receive(ctx context.Context, id uint32, phase types.Phase)
 				return p
 			}
 
+			if s.tap != nil {
+				var body []byte
+				if s.downstreamRespDataBuf != nil {
+					body = s.downstreamRespDataBuf.Bytes()
+				}
+				s.tap.SetResponse(s.downstreamRespHeaders, body)
+			}
+
 			// maybe direct response
 			if s.upstreamRequest == nil {
 				fakeUpstreamRequest := &upstreamRequest{
@@ -31,6 +31,7 @@ import (
 	"mosn.io/mosn/pkg/protocol"
 	"mosn.io/mosn/pkg/router"
 	"mosn.io/mosn/pkg/streamfilter"
+	"mosn.io/mosn/pkg/tap"
 	"mosn.io/mosn/pkg/trace"
 	"mosn.io/mosn/pkg/types"
 	"mosn.io/pkg/buffer"
@@ -485,3 +486,58 @@ func TestGetUpstreamProtocol(t *testing.T) {
 		assert.Equal(t, tc.expectedProtocol, currentProtocol)
 	}
 }
+
+func TestDownstreamTap(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	rule := mock.NewMockRouteRule(ctrl)
+	pmc := mock.NewMockPathMatchCriterion(ctrl)
+	pmc.EXPECT().Matcher().Return("/api").AnyTimes()
+	rule.EXPECT().PathMatchCriterion().Return(pmc).AnyTimes()
+	route := mock.NewMockRoute(ctrl)
+	route.EXPECT().RouteRule().Return(rule).AnyTimes()
+
+	session, err := tap.Start(tap.Config{
+		Match:        tap.Match{Listener: "test_listener", Route: "/api"},
+		MaxBodyBytes: 1024,
+	})
+	assert.Nil(t, err)
+	defer session.Stop()
+
+	requestInfo := network.NewRequestInfo()
+	requestInfo.SetResponseCode(200)
+	s := &downStream{
+		context:              context.Background(),
+		route:                route,
+		requestInfo:          requestInfo,
+		downstreamReqHeaders: protocol.CommonHeader{"service": "test"},
+		downstreamReqDataBuf: buffer.NewIoBufferString("request"),
+		proxy:                &proxy{listenerName: "test_listener"},
+	}
+	s.startTap()
+	assert.NotNil(t, s.tap)
+	s.tap.SetResponse(protocol.CommonHeader{"status": "ok"}, []byte("response"))
+	s.finishTap()
+
+	// not matched listener
+	other := &downStream{
+		context:     context.Background(),
+		requestInfo: network.NewRequestInfo(),
+		proxy:       &proxy{listenerName: "other_listener"},
+	}
+	other.startTap()
+	assert.Nil(t, other.tap)
+	session.Stop()
+
+	var traces []*tap.Trace
+	session.Consume(func(trace *tap.Trace) error {
+		traces = append(traces, trace)
+		return nil
+	})
+	assert.Len(t, traces, 1)
+	assert.Equal(t, "test_listener", traces[0].Listener)
+	assert.Equal(t, "/api", traces[0].Route)
+	assert.Equal(t, 200, traces[0].ResponseCode)
+	assert.Equal(t, "request", traces[0].RequestBody)
+	assert.Equal(t, "response", traces[0].ResponseBody)
+}
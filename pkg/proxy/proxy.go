@@ -93,6 +93,7 @@ type proxy struct {
 	asMux               sync.RWMutex
 	stats               *Stats
 	listenerStats       *Stats
+	listenerName        string
 	accessLogs          []api.AccessLog
 	streamFilterFactory streamfilter.StreamFilterFactory
 	routeHandlerFactory router.MakeHandlerFunc
@@ -132,6 +133,7 @@ func NewProxy(ctx context.Context, config *v2.Proxy) Proxy {
 
 	lv, _ := variable.Get(ctx, types.VariableListenerName)
 	listenerName := lv.(string)
+	proxy.listenerName = listenerName
 	proxy.listenerStats = newListenerStats(listenerName)
 
 	if routersWrapper := router.GetRoutersMangerInstance().GetRouterWrapperByName(proxy.config.RouterConfigName); routersWrapper != nil {
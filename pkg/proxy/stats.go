@@ -18,8 +18,13 @@
 package proxy
 
 import (
+	"sync"
+
 	gometrics "github.com/rcrowley/go-metrics"
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/router"
 	"mosn.io/mosn/pkg/types"
 )
 
@@ -126,3 +131,139 @@ func (s *Stats) DownstreamUpdateRequestCode(returnCode int) {
 		s.DownstreamRequestOtherTotal.Inc(1)
 	}
 }
+
+// RouteStats is the metrics of a virtual host or a route
+type RouteStats struct {
+	metrics     types.Metrics
+	virtualHost string
+	// route is empty for the virtual host
+	route string
+
+	DownstreamRequestTotal      gometrics.Counter
+	DownstreamRequestTime       gometrics.Histogram
+	DownstreamRequestTimeTotal  gometrics.Counter
+	DownstreamRequestFailed     gometrics.Counter
+	DownstreamRequest1xxTotal   gometrics.Counter
+	DownstreamRequest2xxTotal   gometrics.Counter
+	DownstreamRequest3xxTotal   gometrics.Counter
+	DownstreamRequest4xxTotal   gometrics.Counter
+	DownstreamRequest5xxTotal   gometrics.Counter
+	DownstreamRequestOtherTotal gometrics.Counter
+}
+
+// routeStatsCache caches the RouteStats of each router config, avoid creating metrics for each request.
+// the stats of the removed virtual hosts and routes are evicted and unregistered when the routers are changed.
+var routeStatsCache sync.Map
+
+func init() {
+	router.RegisterRoutersUpdateCallback(evictRouteStats)
+}
+
+// unnamedRouteName is used for routes without name, the path matchers are not used to keep the label cardinality bounded
+const unnamedRouteName = "unnamed"
+
+// evictRouteStats removes the stats of the virtual hosts and the routes not in the router config
+func evictRouteStats(routerConfig *v2.RouterConfiguration) {
+	v, ok := routeStatsCache.Load(routerConfig.RouterConfigName)
+	if !ok {
+		return
+	}
+	exists := map[string]bool{}
+	for _, vh := range routerConfig.VirtualHosts {
+		exists[vh.Name+"|"] = true
+		for _, r := range vh.Routers {
+			name := r.Name
+			if name == "" {
+				name = unnamedRouteName
+			}
+			exists[vh.Name+"|"+name] = true
+		}
+	}
+	cache := v.(*sync.Map)
+	cache.Range(func(key, value interface{}) bool {
+		stats := value.(*RouteStats)
+		if !exists[stats.virtualHost+"|"+stats.route] {
+			cache.Delete(key)
+			metrics.DeleteMetrics(stats.metrics.Type(), stats.metrics.Labels())
+		}
+		return true
+	})
+}
+
+func newRouteStats(s types.Metrics, virtualHost, route string) *RouteStats {
+	return &RouteStats{
+		metrics:                     s,
+		virtualHost:                 virtualHost,
+		route:                       route,
+		DownstreamRequestTotal:      s.Counter(metrics.DownstreamRequestTotal),
+		DownstreamRequestTime:       s.Histogram(metrics.DownstreamRequestTime),
+		DownstreamRequestTimeTotal:  s.Counter(metrics.DownstreamRequestTimeTotal),
+		DownstreamRequestFailed:     s.Counter(metrics.DownstreamRequestFailed),
+		DownstreamRequest1xxTotal:   s.Counter(metrics.DownstreamRequest1xxTotal),
+		DownstreamRequest2xxTotal:   s.Counter(metrics.DownstreamRequest2xxTotal),
+		DownstreamRequest3xxTotal:   s.Counter(metrics.DownstreamRequest3xxTotal),
+		DownstreamRequest4xxTotal:   s.Counter(metrics.DownstreamRequest4xxTotal),
+		DownstreamRequest5xxTotal:   s.Counter(metrics.DownstreamRequest5xxTotal),
+		DownstreamRequestOtherTotal: s.Counter(metrics.DownstreamRequestOtherTotal),
+	}
+}
+
+func loadRouteStats(routerConfigName, key string, create func() *RouteStats) *RouteStats {
+	v, ok := routeStatsCache.Load(routerConfigName)
+	if !ok {
+		v, _ = routeStatsCache.LoadOrStore(routerConfigName, &sync.Map{})
+	}
+	cache := v.(*sync.Map)
+	if stats, ok := cache.Load(key); ok {
+		return stats.(*RouteStats)
+	}
+	stats, _ := cache.LoadOrStore(key, create())
+	return stats.(*RouteStats)
+}
+
+func getVirtualHostStats(routerConfigName, proxyName, virtualHostName string) *RouteStats {
+	return loadRouteStats(routerConfigName, proxyName+"|"+virtualHostName, func() *RouteStats {
+		return newRouteStats(metrics.NewVirtualHostStats(proxyName, virtualHostName), virtualHostName, "")
+	})
+}
+
+func getRouteStats(routerConfigName, proxyName, virtualHostName, routeName string) *RouteStats {
+	return loadRouteStats(routerConfigName, proxyName+"|"+virtualHostName+"|"+routeName, func() *RouteStats {
+		return newRouteStats(metrics.NewRouteStats(proxyName, virtualHostName, routeName), virtualHostName, routeName)
+	})
+}
+
+// getRouteName returns the route's configured name, or unnamed if the name is empty
+func getRouteName(rule api.RouteRule) string {
+	if named, ok := rule.(types.NamedRouteRule); ok && named.Name() != "" {
+		return named.Name()
+	}
+	return unnamedRouteName
+}
+
+func (s *RouteStats) DownstreamUpdateRequestCode(returnCode int) {
+	switch returnCode / 100 {
+	case 1:
+		s.DownstreamRequest1xxTotal.Inc(1)
+	case 2:
+		s.DownstreamRequest2xxTotal.Inc(1)
+	case 3:
+		s.DownstreamRequest3xxTotal.Inc(1)
+	case 4:
+		s.DownstreamRequest4xxTotal.Inc(1)
+	case 5:
+		s.DownstreamRequest5xxTotal.Inc(1)
+	default:
+		s.DownstreamRequestOtherTotal.Inc(1)
+	}
+}
+
+func (s *RouteStats) updateRequest(durationNs int64, returnCode int, failed bool) {
+	s.DownstreamRequestTotal.Inc(1)
+	s.DownstreamRequestTime.Update(durationNs)
+	s.DownstreamRequestTimeTotal.Inc(durationNs)
+	s.DownstreamUpdateRequestCode(returnCode)
+	if failed {
+		s.DownstreamRequestFailed.Inc(1)
+	}
+}
@@ -0,0 +1,132 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/mock"
+	"mosn.io/mosn/pkg/network"
+	"mosn.io/mosn/pkg/router"
+)
+
+func TestGetRouteName(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	vh := mock.NewMockVirtualHost(ctrl)
+	base, err := router.NewRouteRuleImplBase(vh, &v2.Router{
+		RouterConfig: v2.RouterConfig{Name: "named_route"},
+	})
+	assert.Nil(t, err)
+	named := router.CreateRPCRule(base, nil)
+	assert.Equal(t, "named_route", getRouteName(named.RouteRule()))
+
+	// the path matcher is not used as the name
+	pmc := mock.NewMockPathMatchCriterion(ctrl)
+	pmc.EXPECT().Matcher().Return("/api").AnyTimes()
+	rule := mock.NewMockRouteRule(ctrl)
+	rule.EXPECT().PathMatchCriterion().Return(pmc).AnyTimes()
+	assert.Equal(t, unnamedRouteName, getRouteName(rule))
+}
+
+func TestRouteMetrics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	metrics.ResetAll()
+	routeStatsCache = sync.Map{}
+	defer metrics.ResetAll()
+
+	vh := mock.NewMockVirtualHost(ctrl)
+	vh.EXPECT().Name().Return("test_vhost").AnyTimes()
+	rule := mock.NewMockRouteRule(ctrl)
+	rule.EXPECT().VirtualHost().Return(vh).AnyTimes()
+	pmc := mock.NewMockPathMatchCriterion(ctrl)
+	pmc.EXPECT().Matcher().Return("/api").AnyTimes()
+	rule.EXPECT().PathMatchCriterion().Return(pmc).AnyTimes()
+	route := mock.NewMockRoute(ctrl)
+	route.EXPECT().RouteRule().Return(rule).AnyTimes()
+
+	newStream := func(cfg *v2.ProxyStatsConfig, code int) *downStream {
+		requestInfo := network.NewRequestInfo()
+		requestInfo.SetResponseCode(code)
+		return &downStream{
+			context:     context.Background(),
+			route:       route,
+			requestInfo: requestInfo,
+			proxy: &proxy{
+				config: &v2.Proxy{Name: "test_proxy", RouterConfigName: "test_route_metrics", StatsConfig: cfg},
+			},
+		}
+	}
+
+	// disabled by default
+	newStream(nil, 200).routeMetrics(100)
+	assert.Len(t, metrics.GetAll(), 0)
+
+	newStream(&v2.ProxyStatsConfig{VirtualHost: true}, 200).routeMetrics(100)
+	newStream(&v2.ProxyStatsConfig{VirtualHost: true, Route: true}, 503).routeMetrics(300)
+
+	vhStats := getVirtualHostStats("test_route_metrics", "test_proxy", "test_vhost")
+	assert.Equal(t, int64(2), vhStats.DownstreamRequestTotal.Count())
+	assert.Equal(t, int64(1), vhStats.DownstreamRequest2xxTotal.Count())
+	assert.Equal(t, int64(1), vhStats.DownstreamRequest5xxTotal.Count())
+	assert.Equal(t, int64(400), vhStats.DownstreamRequestTimeTotal.Count())
+
+	routeStats := getRouteStats("test_route_metrics", "test_proxy", "test_vhost", unnamedRouteName)
+	assert.Equal(t, int64(1), routeStats.DownstreamRequestTotal.Count())
+	assert.Equal(t, int64(1), routeStats.DownstreamRequest5xxTotal.Count())
+	assert.Equal(t, int64(0), routeStats.DownstreamRequest2xxTotal.Count())
+	assert.Len(t, metrics.GetAll(), 2)
+
+	// the stats of the existing routes are kept when the routers are changed
+	routerConfig := &v2.RouterConfiguration{
+		RouterConfigurationConfig: v2.RouterConfigurationConfig{RouterConfigName: "test_route_metrics"},
+		VirtualHosts: []v2.VirtualHost{{
+			Name:    "test_vhost",
+			Domains: []string{"*"},
+			Routers: []v2.Router{{RouterConfig: v2.RouterConfig{
+				Match: v2.RouterMatch{Prefix: "/"},
+				Route: v2.RouteAction{RouterActionConfig: v2.RouterActionConfig{ClusterName: "test_cluster"}},
+			}}},
+		}},
+	}
+	assert.Nil(t, router.GetRoutersMangerInstance().AddOrUpdateRouters(routerConfig))
+	assert.True(t, routeStats == getRouteStats("test_route_metrics", "test_proxy", "test_vhost", unnamedRouteName))
+	assert.Len(t, metrics.GetAll(), 2)
+
+	// the stats of the removed routes are evicted and unregistered
+	routerConfig.VirtualHosts[0].Routers[0].Name = "renamed"
+	assert.Nil(t, router.GetRoutersMangerInstance().AddOrUpdateRouters(routerConfig))
+	assert.True(t, vhStats == getVirtualHostStats("test_route_metrics", "test_proxy", "test_vhost"))
+	assert.Len(t, metrics.GetAll(), 1)
+	routerConfig.VirtualHosts[0].Name = "other_vhost"
+	assert.Nil(t, router.GetRoutersMangerInstance().AddOrUpdateRouters(routerConfig))
+	assert.Len(t, metrics.GetAll(), 0)
+	v, _ := routeStatsCache.Load("test_route_metrics")
+	v.(*sync.Map).Range(func(key, value interface{}) bool {
+		t.Errorf("unexpected stats %v", key)
+		return true
+	})
+}
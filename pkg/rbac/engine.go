@@ -0,0 +1,210 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rbac
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
+)
+
+type policy struct {
+	name        string
+	permissions orMatcher
+	principals  orMatcher
+}
+
+func (p *policy) Match(ctx context.Context, headers api.HeaderMap) bool {
+	return p.permissions.Match(ctx, headers) && p.principals.Match(ctx, headers)
+}
+
+// Engine evaluates the requests by the policies
+type Engine struct {
+	action   string
+	policies []*policy
+	// peerIdentity is true if any principal matches the identity of the tls peer
+	peerIdentity bool
+}
+
+// NewEngine creates an engine by the rules
+func NewEngine(cfg *v2.RBACRules) (*Engine, error) {
+	e := &Engine{
+		action: cfg.Action,
+	}
+	switch e.action {
+	case "":
+		e.action = v2.RBACActionAllow
+	case v2.RBACActionAllow, v2.RBACActionDeny, v2.RBACActionLog:
+	default:
+		return nil, fmt.Errorf("unsupported rbac action %s", cfg.Action)
+	}
+	for i := range cfg.Policies {
+		pc := &cfg.Policies[i]
+		if pc.Name == "" {
+			return nil, errors.New("rbac policy name is empty")
+		}
+		if len(pc.Permissions) == 0 || len(pc.Principals) == 0 {
+			return nil, fmt.Errorf("rbac policy %s requires at least one permission and one principal", pc.Name)
+		}
+		p := &policy{
+			name: pc.Name,
+		}
+		for j := range pc.Permissions {
+			m, err := newPermission(&pc.Permissions[j])
+			if err != nil {
+				return nil, fmt.Errorf("rbac policy %s: %v", pc.Name, err)
+			}
+			p.permissions = append(p.permissions, m)
+		}
+		for j := range pc.Principals {
+			m, err := newPrincipal(&pc.Principals[j])
+			if err != nil {
+				return nil, fmt.Errorf("rbac policy %s: %v", pc.Name, err)
+			}
+			p.principals = append(p.principals, m)
+		}
+		e.policies = append(e.policies, p)
+		for j := range pc.Principals {
+			e.peerIdentity = e.peerIdentity || usesPeerIdentity(&pc.Principals[j])
+		}
+	}
+	return e, nil
+}
+
+func usesPeerIdentity(cfg *v2.RBACPrincipal) bool {
+	if cfg.Authenticated != nil {
+		return true
+	}
+	if cfg.NotID != nil && usesPeerIdentity(cfg.NotID) {
+		return true
+	}
+	for _, ids := range [][]v2.RBACPrincipal{cfg.AndIDs, cfg.OrIDs} {
+		for i := range ids {
+			if usesPeerIdentity(&ids[i]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Evaluate returns whether the request is allowed by the action, and the name of the first matched policy.
+func (e *Engine) Evaluate(ctx context.Context, headers api.HeaderMap) (allowed bool, matched string) {
+	found := false
+	for _, p := range e.policies {
+		if p.Match(ctx, headers) {
+			found = true
+			matched = p.name
+			break
+		}
+	}
+	switch e.action {
+	case v2.RBACActionDeny:
+		return !found, matched
+	case v2.RBACActionLog:
+		return true, matched
+	default:
+		return found, matched
+	}
+}
+
+// RBAC enforces the rules and evaluates the shadow rules
+type RBAC struct {
+	statPrefix string
+	rules      *Engine
+	shadow     *Engine
+	stats      *rbacStats
+}
+
+// NewRBAC creates a RBAC by the config
+func NewRBAC(cfg *v2.RBAC) (*RBAC, error) {
+	r := &RBAC{
+		statPrefix: cfg.StatPrefix,
+		stats:      getStats(cfg.StatPrefix),
+	}
+	var err error
+	if cfg.Rules != nil {
+		if r.rules, err = NewEngine(cfg.Rules); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.ShadowRules != nil {
+		if r.shadow, err = NewEngine(cfg.ShadowRules); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// RequiresPeerIdentity returns true if the rules or the shadow rules match the identity of the tls peer,
+// which is available after the tls handshake is finished.
+func (r *RBAC) RequiresPeerIdentity() bool {
+	return r.rules != nil && r.rules.peerIdentity || r.shadow != nil && r.shadow.peerIdentity
+}
+
+// ParseRBAC parses the rbac filter config
+func ParseRBAC(cfg map[string]interface{}) (*v2.RBAC, error) {
+	conf := &v2.RBAC{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("[config] config is not a rbac config: %v", err)
+	}
+	if err := json.Unmarshal(data, conf); err != nil {
+		return nil, fmt.Errorf("[config] config is not a rbac config: %v", err)
+	}
+	if conf.Rules == nil && conf.ShadowRules == nil {
+		return nil, errors.New("[config] rbac config has no rules")
+	}
+	return conf, nil
+}
+
+// Allowed evaluates the shadow rules for metrics, and returns whether the request is allowed by the rules.
+func (r *RBAC) Allowed(ctx context.Context, headers api.HeaderMap) bool {
+	if r.shadow != nil {
+		if allowed, matched := r.shadow.Evaluate(ctx, headers); allowed {
+			r.stats.ShadowAllowed.Inc(1)
+		} else {
+			r.stats.ShadowDenied.Inc(1)
+			if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+				log.DefaultLogger.Debugf("[rbac] %s shadow denied, matched policy: %s", r.statPrefix, matched)
+			}
+		}
+	}
+	if r.rules == nil {
+		return true
+	}
+	allowed, matched := r.rules.Evaluate(ctx, headers)
+	if r.rules.action == v2.RBACActionLog && matched != "" {
+		r.stats.Logged.Inc(1)
+		log.DefaultLogger.Infof("[rbac] %s request matched policy: %s", r.statPrefix, matched)
+	}
+	if allowed {
+		r.stats.Allowed.Inc(1)
+	} else {
+		r.stats.Denied.Inc(1)
+		if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+			log.DefaultLogger.Debugf("[rbac] %s denied, matched policy: %s", r.statPrefix, matched)
+		}
+	}
+	return allowed
+}
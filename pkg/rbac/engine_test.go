@@ -0,0 +1,280 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rbac
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/mock"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/variable"
+)
+
+const testJWTSubject = "jwt_payload_sub"
+
+func init() {
+	for _, name := range []string{
+		types.VarPath, types.VarMethod, types.VarHeaderRPCService, types.VarHeaderRPCMethod, testJWTSubject,
+	} {
+		if _, err := variable.Check(name); err != nil {
+			variable.Register(variable.NewStringVariable(name, nil, nil, variable.DefaultStringSetter, 0))
+		}
+	}
+	// the peer identity is read from the tls connection, set it directly in tests
+	peerIdentity := variable.NewStringVariable(types.VarDownstreamPeerIdentity, nil, nil, variable.DefaultStringSetter, 0)
+	if err := variable.Override(peerIdentity); err != nil {
+		variable.Register(peerIdentity)
+	}
+}
+
+func newTestContext(t *testing.T, remote, local string, vars map[string]string) context.Context {
+	ctrl := gomock.NewController(t)
+	conn := mock.NewMockConnection(ctrl)
+	remoteAddr, _ := net.ResolveTCPAddr("tcp", remote)
+	localAddr, _ := net.ResolveTCPAddr("tcp", local)
+	conn.EXPECT().RemoteAddr().Return(remoteAddr).AnyTimes()
+	conn.EXPECT().LocalAddr().Return(localAddr).AnyTimes()
+	ctx := variable.NewVariableContext(context.Background())
+	_ = variable.Set(ctx, types.VariableConnection, conn)
+	for k, v := range vars {
+		require.Nil(t, variable.SetString(ctx, k, v))
+	}
+	return ctx
+}
+
+func TestEngineActions(t *testing.T) {
+	policies := []v2.RBACPolicy{
+		{
+			Name: "admin",
+			Permissions: []v2.RBACPermission{
+				{Path: &v2.StringMatcher{Prefix: "/admin"}},
+			},
+			Principals: []v2.RBACPrincipal{
+				{SourceIP: "10.0.0.0/8"},
+			},
+		},
+	}
+	ctx := newTestContext(t, "10.1.1.1:12345", "127.0.0.1:2045", map[string]string{types.VarPath: "/admin/users"})
+	other := newTestContext(t, "192.168.1.1:12345", "127.0.0.1:2045", map[string]string{types.VarPath: "/admin/users"})
+	for _, tc := range []struct {
+		action  string
+		matched bool
+		other   bool
+	}{
+		{"", true, false},
+		{v2.RBACActionAllow, true, false},
+		{v2.RBACActionDeny, false, true},
+		{v2.RBACActionLog, true, true},
+	} {
+		e, err := NewEngine(&v2.RBACRules{Action: tc.action, Policies: policies})
+		require.Nil(t, err)
+		allowed, name := e.Evaluate(ctx, nil)
+		assert.Equal(t, tc.matched, allowed, "action %s", tc.action)
+		assert.Equal(t, "admin", name)
+		allowed, name = e.Evaluate(other, nil)
+		assert.Equal(t, tc.other, allowed, "action %s", tc.action)
+		assert.Equal(t, "", name)
+	}
+}
+
+func TestPermissions(t *testing.T) {
+	ctx := newTestContext(t, "10.1.1.1:12345", "127.0.0.1:2045", map[string]string{
+		types.VarPath:             "/api/v1/users",
+		types.VarMethod:           "GET",
+		types.VarHeaderRPCService: "com.alipay.test.TestService:1.0",
+		types.VarHeaderRPCMethod:  "sayHello",
+	})
+	headers := protocol.CommonHeader{"x-user": "alice"}
+	for i, tc := range []struct {
+		permission v2.RBACPermission
+		matched    bool
+	}{
+		{v2.RBACPermission{Any: true}, true},
+		{v2.RBACPermission{Path: &v2.StringMatcher{Exact: "/api/v1/users"}}, true},
+		{v2.RBACPermission{Path: &v2.StringMatcher{Suffix: "/USERS", IgnoreCase: true}}, true},
+		{v2.RBACPermission{Path: &v2.StringMatcher{Regex: "/api/v[0-9]+"}}, false},
+		{v2.RBACPermission{Path: &v2.StringMatcher{Regex: "/api/v[0-9]+/.*"}}, true},
+		{v2.RBACPermission{Method: &v2.StringMatcher{Exact: "POST"}}, false},
+		{v2.RBACPermission{RPCService: &v2.StringMatcher{Prefix: "com.alipay.test."}}, true},
+		{v2.RBACPermission{RPCMethod: &v2.StringMatcher{Exact: "sayHello"}}, true},
+		{v2.RBACPermission{DestinationPort: 2045}, true},
+		{v2.RBACPermission{DestinationPort: 2046}, false},
+		{v2.RBACPermission{Header: &v2.RBACValueMatcher{Name: "x-user", Present: true}}, true},
+		{v2.RBACPermission{Header: &v2.RBACValueMatcher{Name: "x-group", Present: true}}, false},
+		{v2.RBACPermission{Header: &v2.RBACValueMatcher{Name: "x-user", StringMatcher: v2.StringMatcher{Exact: "bob"}}}, false},
+		{v2.RBACPermission{AndRules: []v2.RBACPermission{
+			{Method: &v2.StringMatcher{Exact: "GET"}},
+			{DestinationPort: 2045},
+		}}, true},
+		{v2.RBACPermission{AndRules: []v2.RBACPermission{
+			{Method: &v2.StringMatcher{Exact: "GET"}},
+			{DestinationPort: 2046},
+		}}, false},
+		{v2.RBACPermission{OrRules: []v2.RBACPermission{
+			{Method: &v2.StringMatcher{Exact: "POST"}},
+			{DestinationPort: 2045},
+		}}, true},
+		{v2.RBACPermission{NotRule: &v2.RBACPermission{Method: &v2.StringMatcher{Exact: "GET"}}}, false},
+	} {
+		m, err := newPermission(&tc.permission)
+		require.Nil(t, err, "case %d", i)
+		assert.Equal(t, tc.matched, m.Match(ctx, headers), "case %d", i)
+	}
+}
+
+func TestPrincipals(t *testing.T) {
+	ctx := newTestContext(t, "10.1.1.1:12345", "127.0.0.1:2045", map[string]string{
+		types.VarDownstreamPeerIdentity: "spiffe://cluster.local/ns/default/sa/client",
+		testJWTSubject:                  "alice",
+	})
+	anonymous := newTestContext(t, "10.1.1.1:12345", "127.0.0.1:2045", nil)
+	for i, tc := range []struct {
+		principal v2.RBACPrincipal
+		matched   bool
+		anonymous bool
+	}{
+		{v2.RBACPrincipal{Any: true}, true, true},
+		{v2.RBACPrincipal{Authenticated: &v2.StringMatcher{}}, true, false},
+		{v2.RBACPrincipal{Authenticated: &v2.StringMatcher{Prefix: "spiffe://cluster.local/"}}, true, false},
+		{v2.RBACPrincipal{Authenticated: &v2.StringMatcher{Exact: "spiffe://other.domain/client"}}, false, false},
+		{v2.RBACPrincipal{SourceIP: "10.1.1.1"}, true, true},
+		{v2.RBACPrincipal{SourceIP: "10.2.0.0/16"}, false, false},
+		{v2.RBACPrincipal{Variable: &v2.RBACValueMatcher{Name: testJWTSubject, StringMatcher: v2.StringMatcher{Exact: "alice"}}}, true, false},
+		{v2.RBACPrincipal{NotID: &v2.RBACPrincipal{Authenticated: &v2.StringMatcher{}}}, false, true},
+		{v2.RBACPrincipal{AndIDs: []v2.RBACPrincipal{
+			{SourceIP: "10.0.0.0/8"},
+			{Authenticated: &v2.StringMatcher{}},
+		}}, true, false},
+		{v2.RBACPrincipal{OrIDs: []v2.RBACPrincipal{
+			{SourceIP: "192.168.0.0/16"},
+			{Authenticated: &v2.StringMatcher{}},
+		}}, true, false},
+	} {
+		m, err := newPrincipal(&tc.principal)
+		require.Nil(t, err, "case %d", i)
+		assert.Equal(t, tc.matched, m.Match(ctx, nil), "case %d", i)
+		assert.Equal(t, tc.anonymous, m.Match(anonymous, nil), "case %d anonymous", i)
+	}
+}
+
+func TestEngineConfigError(t *testing.T) {
+	anyPermission := []v2.RBACPermission{{Any: true}}
+	anyID := []v2.RBACPrincipal{{Any: true}}
+	for i, rules := range []*v2.RBACRules{
+		{Action: "AUDIT"},
+		{Policies: []v2.RBACPolicy{{Permissions: anyPermission, Principals: anyID}}},
+		{Policies: []v2.RBACPolicy{{Name: "p", Permissions: anyPermission}}},
+		{Policies: []v2.RBACPolicy{{Name: "p", Permissions: []v2.RBACPermission{{}}, Principals: anyID}}},
+		{Policies: []v2.RBACPolicy{{Name: "p", Permissions: anyPermission, Principals: []v2.RBACPrincipal{{SourceIP: "10.0.0.256"}}}}},
+		{Policies: []v2.RBACPolicy{{Name: "p", Permissions: []v2.RBACPermission{{Path: &v2.StringMatcher{Regex: "(["}}}, Principals: anyID}}},
+		{Policies: []v2.RBACPolicy{{Name: "p", Permissions: []v2.RBACPermission{{Header: &v2.RBACValueMatcher{Present: true}}}, Principals: anyID}}},
+	} {
+		_, err := NewEngine(rules)
+		assert.NotNil(t, err, "case %d", i)
+	}
+}
+
+func TestRequiresPeerIdentity(t *testing.T) {
+	rules := func(ids ...v2.RBACPrincipal) *v2.RBACRules {
+		return &v2.RBACRules{Policies: []v2.RBACPolicy{{Name: "p", Permissions: []v2.RBACPermission{{Any: true}}, Principals: ids}}}
+	}
+	authenticated := v2.RBACPrincipal{Authenticated: &v2.StringMatcher{}}
+	for i, c := range []struct {
+		cfg      *v2.RBAC
+		expected bool
+	}{
+		{&v2.RBAC{}, false},
+		{&v2.RBAC{Rules: rules(v2.RBACPrincipal{SourceIP: "10.0.0.0/8"})}, false},
+		{&v2.RBAC{Rules: rules(authenticated)}, true},
+		{&v2.RBAC{Rules: rules(v2.RBACPrincipal{NotID: &authenticated})}, true},
+		{&v2.RBAC{Rules: rules(v2.RBACPrincipal{OrIDs: []v2.RBACPrincipal{{Any: true}, authenticated}})}, true},
+		{&v2.RBAC{ShadowRules: rules(v2.RBACPrincipal{AndIDs: []v2.RBACPrincipal{authenticated}})}, true},
+	} {
+		r, err := NewRBAC(c.cfg)
+		require.Nil(t, err)
+		assert.Equal(t, c.expected, r.RequiresPeerIdentity(), "case %d", i)
+	}
+}
+
+func TestParseRBAC(t *testing.T) {
+	_, err := ParseRBAC(map[string]interface{}{"stat_prefix": "test"})
+	assert.NotNil(t, err)
+	cfg, err := ParseRBAC(map[string]interface{}{
+		"stat_prefix": "test",
+		"rules": map[string]interface{}{
+			"action": "DENY",
+			"policies": []interface{}{
+				map[string]interface{}{
+					"name":        "deny-admin",
+					"permissions": []interface{}{map[string]interface{}{"path": map[string]interface{}{"prefix": "/admin"}}},
+					"principals":  []interface{}{map[string]interface{}{"any": true}},
+				},
+			},
+		},
+	})
+	require.Nil(t, err)
+	require.NotNil(t, cfg.Rules)
+	assert.Equal(t, v2.RBACActionDeny, cfg.Rules.Action)
+	require.Len(t, cfg.Rules.Policies, 1)
+	assert.Equal(t, "/admin", cfg.Rules.Policies[0].Permissions[0].Path.Prefix)
+}
+
+func TestRBACStats(t *testing.T) {
+	policies := []v2.RBACPolicy{
+		{
+			Name:        "deny-admin",
+			Permissions: []v2.RBACPermission{{Path: &v2.StringMatcher{Prefix: "/admin"}}},
+			Principals:  []v2.RBACPrincipal{{Any: true}},
+		},
+	}
+	r, err := NewRBAC(&v2.RBAC{
+		StatPrefix:  "test_stats",
+		Rules:       &v2.RBACRules{Action: v2.RBACActionLog, Policies: policies},
+		ShadowRules: &v2.RBACRules{Action: v2.RBACActionDeny, Policies: policies},
+	})
+	require.Nil(t, err)
+	admin := newTestContext(t, "10.1.1.1:12345", "127.0.0.1:2045", map[string]string{types.VarPath: "/admin"})
+	index := newTestContext(t, "10.1.1.1:12345", "127.0.0.1:2045", map[string]string{types.VarPath: "/index"})
+	// shadow rules never deny
+	assert.True(t, r.Allowed(admin, nil))
+	assert.True(t, r.Allowed(index, nil))
+	stats := getStats("test_stats")
+	assert.Equal(t, int64(2), stats.Allowed.Count())
+	assert.Equal(t, int64(0), stats.Denied.Count())
+	assert.Equal(t, int64(1), stats.Logged.Count())
+	assert.Equal(t, int64(1), stats.ShadowAllowed.Count())
+	assert.Equal(t, int64(1), stats.ShadowDenied.Count())
+
+	// shadow rules only
+	r, err = NewRBAC(&v2.RBAC{
+		StatPrefix:  "test_shadow_only",
+		ShadowRules: &v2.RBACRules{Action: v2.RBACActionDeny, Policies: policies},
+	})
+	require.Nil(t, err)
+	assert.True(t, r.Allowed(admin, nil))
+	stats = getStats("test_shadow_only")
+	assert.Equal(t, int64(0), stats.Allowed.Count())
+	assert.Equal(t, int64(1), stats.ShadowDenied.Count())
+}
@@ -0,0 +1,342 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rbac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/variable"
+)
+
+// matcher matches a request, the headers is nil in network filter
+type matcher interface {
+	Match(ctx context.Context, headers api.HeaderMap) bool
+}
+
+type anyMatcher struct{}
+
+func (anyMatcher) Match(context.Context, api.HeaderMap) bool {
+	return true
+}
+
+type andMatcher []matcher
+
+func (m andMatcher) Match(ctx context.Context, headers api.HeaderMap) bool {
+	for _, sub := range m {
+		if !sub.Match(ctx, headers) {
+			return false
+		}
+	}
+	return true
+}
+
+type orMatcher []matcher
+
+func (m orMatcher) Match(ctx context.Context, headers api.HeaderMap) bool {
+	for _, sub := range m {
+		if sub.Match(ctx, headers) {
+			return true
+		}
+	}
+	return false
+}
+
+type notMatcher struct {
+	matcher matcher
+}
+
+func (m *notMatcher) Match(ctx context.Context, headers api.HeaderMap) bool {
+	return !m.matcher.Match(ctx, headers)
+}
+
+// stringMatcher is the runtime of v2.StringMatcher
+type stringMatcher func(s string) bool
+
+func newStringMatcher(cfg v2.StringMatcher) (stringMatcher, error) {
+	normalize := func(s string) string { return s }
+	if cfg.IgnoreCase {
+		normalize = strings.ToLower
+	}
+	switch {
+	case cfg.Exact != "":
+		exact := normalize(cfg.Exact)
+		return func(s string) bool { return normalize(s) == exact }, nil
+	case cfg.Prefix != "":
+		prefix := normalize(cfg.Prefix)
+		return func(s string) bool { return strings.HasPrefix(normalize(s), prefix) }, nil
+	case cfg.Suffix != "":
+		suffix := normalize(cfg.Suffix)
+		return func(s string) bool { return strings.HasSuffix(normalize(s), suffix) }, nil
+	case cfg.Regex != "":
+		expr := "^(?:" + cfg.Regex + ")$"
+		if cfg.IgnoreCase {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %s: %v", cfg.Regex, err)
+		}
+		return re.MatchString, nil
+	default:
+		return nil, errors.New("string matcher is empty")
+	}
+}
+
+// variableMatcher matches the string variable
+type variableMatcher struct {
+	name  string
+	match stringMatcher
+}
+
+func (m *variableMatcher) Match(ctx context.Context, _ api.HeaderMap) bool {
+	value, err := variable.GetString(ctx, m.name)
+	if err != nil {
+		return false
+	}
+	// match presence only
+	if m.match == nil {
+		return true
+	}
+	return m.match(value)
+}
+
+type headerMatcher struct {
+	name  string
+	match stringMatcher
+}
+
+func (m *headerMatcher) Match(_ context.Context, headers api.HeaderMap) bool {
+	if headers == nil {
+		return false
+	}
+	value, ok := headers.Get(m.name)
+	if !ok {
+		return false
+	}
+	if m.match == nil {
+		return true
+	}
+	return m.match(value)
+}
+
+func newValueMatcher(cfg *v2.RBACValueMatcher) (string, stringMatcher, error) {
+	if cfg.Name == "" {
+		return "", nil, errors.New("matcher name is empty")
+	}
+	if cfg.Present {
+		return cfg.Name, nil, nil
+	}
+	match, err := newStringMatcher(cfg.StringMatcher)
+	if err != nil {
+		return "", nil, fmt.Errorf("matcher %s: %v", cfg.Name, err)
+	}
+	return cfg.Name, match, nil
+}
+
+func connection(ctx context.Context) api.Connection {
+	cv, err := variable.Get(ctx, types.VariableConnection)
+	if err != nil {
+		return nil
+	}
+	conn, _ := cv.(api.Connection)
+	return conn
+}
+
+// sourceIPMatcher matches the remote address of the downstream connection
+type sourceIPMatcher struct {
+	ipNet *net.IPNet
+}
+
+func (m *sourceIPMatcher) Match(ctx context.Context, _ api.HeaderMap) bool {
+	conn := connection(ctx)
+	if conn == nil || conn.RemoteAddr() == nil {
+		return false
+	}
+	ip := addrIP(conn.RemoteAddr())
+	return ip != nil && m.ipNet.Contains(ip)
+}
+
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
+
+func newSourceIPMatcher(s string) (*sourceIPMatcher, error) {
+	if ip := net.ParseIP(s); ip != nil {
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 8 * net.IPv4len
+		}
+		return &sourceIPMatcher{ipNet: &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}}, nil
+	}
+	_, ipNet, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source ip %s: %v", s, err)
+	}
+	return &sourceIPMatcher{ipNet: ipNet}, nil
+}
+
+// destinationPortMatcher matches the local port of the downstream connection
+type destinationPortMatcher uint32
+
+func (m destinationPortMatcher) Match(ctx context.Context, _ api.HeaderMap) bool {
+	conn := connection(ctx)
+	if conn == nil || conn.LocalAddr() == nil {
+		return false
+	}
+	_, port, err := net.SplitHostPort(conn.LocalAddr().String())
+	if err != nil {
+		return false
+	}
+	return port == fmt.Sprint(uint32(m))
+}
+
+func newPermission(cfg *v2.RBACPermission) (matcher, error) {
+	stringVariable := func(name string, c *v2.StringMatcher) (matcher, error) {
+		match, err := newStringMatcher(*c)
+		if err != nil {
+			return nil, err
+		}
+		return &variableMatcher{name: name, match: match}, nil
+	}
+	switch {
+	case cfg.Any:
+		return anyMatcher{}, nil
+	case len(cfg.AndRules) > 0:
+		m := make(andMatcher, 0, len(cfg.AndRules))
+		for i := range cfg.AndRules {
+			sub, err := newPermission(&cfg.AndRules[i])
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, sub)
+		}
+		return m, nil
+	case len(cfg.OrRules) > 0:
+		m := make(orMatcher, 0, len(cfg.OrRules))
+		for i := range cfg.OrRules {
+			sub, err := newPermission(&cfg.OrRules[i])
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, sub)
+		}
+		return m, nil
+	case cfg.NotRule != nil:
+		sub, err := newPermission(cfg.NotRule)
+		if err != nil {
+			return nil, err
+		}
+		return &notMatcher{matcher: sub}, nil
+	case cfg.Header != nil:
+		name, match, err := newValueMatcher(cfg.Header)
+		if err != nil {
+			return nil, err
+		}
+		return &headerMatcher{name: name, match: match}, nil
+	case cfg.Path != nil:
+		return stringVariable(types.VarPath, cfg.Path)
+	case cfg.Method != nil:
+		return stringVariable(types.VarMethod, cfg.Method)
+	case cfg.RPCService != nil:
+		return stringVariable(types.VarHeaderRPCService, cfg.RPCService)
+	case cfg.RPCMethod != nil:
+		return stringVariable(types.VarHeaderRPCMethod, cfg.RPCMethod)
+	case cfg.DestinationPort != 0:
+		return destinationPortMatcher(cfg.DestinationPort), nil
+	default:
+		return nil, errors.New("permission is empty")
+	}
+}
+
+func newPrincipal(cfg *v2.RBACPrincipal) (matcher, error) {
+	switch {
+	case cfg.Any:
+		return anyMatcher{}, nil
+	case len(cfg.AndIDs) > 0:
+		m := make(andMatcher, 0, len(cfg.AndIDs))
+		for i := range cfg.AndIDs {
+			sub, err := newPrincipal(&cfg.AndIDs[i])
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, sub)
+		}
+		return m, nil
+	case len(cfg.OrIDs) > 0:
+		m := make(orMatcher, 0, len(cfg.OrIDs))
+		for i := range cfg.OrIDs {
+			sub, err := newPrincipal(&cfg.OrIDs[i])
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, sub)
+		}
+		return m, nil
+	case cfg.NotID != nil:
+		sub, err := newPrincipal(cfg.NotID)
+		if err != nil {
+			return nil, err
+		}
+		return &notMatcher{matcher: sub}, nil
+	case cfg.Authenticated != nil:
+		// an empty matcher matches any authenticated peer
+		if *cfg.Authenticated == (v2.StringMatcher{}) {
+			return &variableMatcher{name: types.VarDownstreamPeerIdentity}, nil
+		}
+		match, err := newStringMatcher(*cfg.Authenticated)
+		if err != nil {
+			return nil, err
+		}
+		return &variableMatcher{name: types.VarDownstreamPeerIdentity, match: match}, nil
+	case cfg.SourceIP != "":
+		return newSourceIPMatcher(cfg.SourceIP)
+	case cfg.Header != nil:
+		name, match, err := newValueMatcher(cfg.Header)
+		if err != nil {
+			return nil, err
+		}
+		return &headerMatcher{name: name, match: match}, nil
+	case cfg.Variable != nil:
+		name, match, err := newValueMatcher(cfg.Variable)
+		if err != nil {
+			return nil, err
+		}
+		return &variableMatcher{name: name, match: match}, nil
+	default:
+		return nil, errors.New("principal is empty")
+	}
+}
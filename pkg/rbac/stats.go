@@ -0,0 +1,50 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rbac
+
+import (
+	"sync"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"mosn.io/mosn/pkg/metrics"
+)
+
+type rbacStats struct {
+	Allowed       gometrics.Counter
+	Denied        gometrics.Counter
+	Logged        gometrics.Counter
+	ShadowAllowed gometrics.Counter
+	ShadowDenied  gometrics.Counter
+}
+
+var statsCache sync.Map
+
+func getStats(statPrefix string) *rbacStats {
+	if v, ok := statsCache.Load(statPrefix); ok {
+		return v.(*rbacStats)
+	}
+	s := metrics.NewRBACStats(statPrefix)
+	v, _ := statsCache.LoadOrStore(statPrefix, &rbacStats{
+		Allowed:       s.Counter(metrics.RBACAllowed),
+		Denied:        s.Counter(metrics.RBACDenied),
+		Logged:        s.Counter(metrics.RBACLogged),
+		ShadowAllowed: s.Counter(metrics.RBACShadowAllowed),
+		ShadowDenied:  s.Counter(metrics.RBACShadowDenied),
+	})
+	return v.(*rbacStats)
+}
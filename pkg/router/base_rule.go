@@ -41,6 +41,7 @@ var (
 )
 
 type RouteRuleImplBase struct {
+	name string
 	// match
 	vHost       api.VirtualHost
 	routerMatch v2.RouterMatch
@@ -73,6 +74,7 @@ type RouteRuleImplBase struct {
 
 func NewRouteRuleImplBase(vHost api.VirtualHost, route *v2.Router) (*RouteRuleImplBase, error) {
 	base := &RouteRuleImplBase{
+		name:                  route.Name,
 		vHost:                 vHost,
 		routerMatch:           route.Match,
 		prefixRewrite:         route.Route.PrefixRewrite,
@@ -130,6 +132,11 @@ func NewRouteRuleImplBase(vHost api.VirtualHost, route *v2.Router) (*RouteRuleIm
 				ttl:  hp.Cookie.TTL,
 			}
 		}
+		if hp.Variable != nil {
+			base.policy.hashPolicy = &variableHashPolicyImpl{
+				name: hp.Variable.Name,
+			}
+		}
 	}
 	// use source ip hash policy as default hash policy
 	if base.policy.hashPolicy == nil {
@@ -188,6 +195,11 @@ func NewRouteRuleImplBase(vHost api.VirtualHost, route *v2.Router) (*RouteRuleIm
 	return base, nil
 }
 
+// Name returns the configured route name, used in route level metrics
+func (rri *RouteRuleImplBase) Name() string {
+	return rri.name
+}
+
 func (rri *RouteRuleImplBase) VirtualHost() api.VirtualHost {
 	return rri.vHost
 }
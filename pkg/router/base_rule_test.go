@@ -820,6 +820,20 @@ func TestParseHashPolicy(t *testing.T) {
 			"httpCookieHashPolicyImpl key should be '5s'")
 	}
 
+	// variable
+	routerMock1.Route.HashPolicy = []v2.HashPolicy{
+		{
+			Variable: &v2.VariableHashPolicy{Name: "dubbo_request_arg_0"},
+		},
+	}
+	rb, err = NewRouteRuleImplBase(nil, routerMock1)
+	assert.NoErrorf(t, err, "new routerule impl failed %+v", err)
+	variableHp, ok := rb.policy.hashPolicy.(*variableHashPolicyImpl)
+	assert.Truef(t, ok, "hash policy should be variableHashPolicyImpl type")
+	if ok {
+		assert.Equalf(t, "dubbo_request_arg_0", variableHp.name,
+			"variableHashPolicyImpl name should be 'dubbo_request_arg_0'")
+	}
 }
 
 func TestHashPolicy(t *testing.T) {
@@ -863,6 +877,19 @@ func TestHashPolicy(t *testing.T) {
 	sourceIPHp := sourceIPHashPolicyImpl{}
 	hash = sourceIPHp.GenerateHash(ctx)
 	assert.Equalf(t, uint64(2130706433), hash, "source ip hash not match")
+
+	// test variable
+	argGetter := func(ctx context.Context, value *variable.IndexedValue, data interface{}) (string, error) {
+		return "test_arg_value", nil
+	}
+	variable.RegisterPrefix("SomeProtocol_request_arg_", variable.NewStringVariable("SomeProtocol_request_arg_", nil, argGetter, nil, 0))
+	variableHp := variableHashPolicyImpl{
+		name: "SomeProtocol_request_arg_0",
+	}
+	hash = variableHp.GenerateHash(ctx)
+	assert.Equalf(t, getHashByString("SomeProtocol_request_arg_0:test_arg_value"), hash, "variable value hash not match")
+	variableHp.name = "not_exists_variable"
+	assert.Equalf(t, uint64(0), variableHp.GenerateHash(ctx), "hash of the variable not found should be 0")
 }
 
 // TestDefaultHashPolicy tests use sourceIPHashPolicy as default hash policy
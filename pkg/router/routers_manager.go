@@ -47,6 +47,27 @@ func (rw *RoutersWrapper) GetRoutersConfig() v2.RouterConfiguration {
 	return *rw.routersConfig
 }
 
+var (
+	updateCallbacksMutex sync.RWMutex
+	updateCallbacks      []func(routerConfig *v2.RouterConfiguration)
+)
+
+// RegisterRoutersUpdateCallback registers a callback called with the new config after the routers of a router
+// config are changed. The callback may be called with the lock of the routers held, it should not call the manager.
+func RegisterRoutersUpdateCallback(cb func(routerConfig *v2.RouterConfiguration)) {
+	updateCallbacksMutex.Lock()
+	defer updateCallbacksMutex.Unlock()
+	updateCallbacks = append(updateCallbacks, cb)
+}
+
+func onRoutersUpdated(routerConfig *v2.RouterConfiguration) {
+	updateCallbacksMutex.RLock()
+	defer updateCallbacksMutex.RUnlock()
+	for _, cb := range updateCallbacks {
+		cb(routerConfig)
+	}
+}
+
 // RoutersManager implementation
 type routersManagerImpl struct {
 	routersWrapperMap sync.Map
@@ -93,6 +114,7 @@ func (rm *routersManagerImpl) AddOrUpdateRouters(routerConfig *v2.RouterConfigur
 	}
 	// update admin stored config for admin api dump
 	configmanager.SetRouter(*routerConfig)
+	onRoutersUpdated(routerConfig)
 	return nil
 }
 
@@ -140,6 +162,7 @@ func (rm *routersManagerImpl) AddRoute(routerConfigName, domain string, route *v
 		cfg.VirtualHosts[index].Routers = routersCfg
 		rw.routersConfig = cfg
 		configmanager.SetRouter(*cfg)
+		onRoutersUpdated(cfg)
 	}
 	return nil
 }
@@ -172,6 +195,134 @@ func (rm *routersManagerImpl) RemoveAllRoutes(routerConfigName, domain string) e
 		cfg.VirtualHosts[index].Routers = []v2.Router{}
 		rw.routersConfig = cfg
 		configmanager.SetRouter(*cfg)
+		onRoutersUpdated(cfg)
+	}
+	return nil
+}
+
+// AddOrUpdateVirtualHost adds a virtual host, or replaces the virtual host with the same name
+func (rm *routersManagerImpl) AddOrUpdateVirtualHost(routerConfigName string, virtualHost *v2.VirtualHost) error {
+	if virtualHost == nil {
+		return ErrNoVirtualHost
+	}
+	return rm.updateRoutersConfig(routerConfigName, "AddOrUpdateVirtualHost", func(cfg *v2.RouterConfiguration) error {
+		for i := range cfg.VirtualHosts {
+			if cfg.VirtualHosts[i].Name == virtualHost.Name {
+				cfg.VirtualHosts[i] = *virtualHost
+				return nil
+			}
+		}
+		cfg.VirtualHosts = append(cfg.VirtualHosts, *virtualHost)
+		return nil
+	})
+}
+
+// RemoveVirtualHost removes the virtual host by name
+func (rm *routersManagerImpl) RemoveVirtualHost(routerConfigName, virtualHostName string) error {
+	return rm.updateRoutersConfig(routerConfigName, "RemoveVirtualHost", func(cfg *v2.RouterConfiguration) error {
+		for i := range cfg.VirtualHosts {
+			if cfg.VirtualHosts[i].Name == virtualHostName {
+				cfg.VirtualHosts = append(cfg.VirtualHosts[:i], cfg.VirtualHosts[i+1:]...)
+				return nil
+			}
+		}
+		return ErrNoVirtualHostName
+	})
+}
+
+// RemoveRoute removes the routes with the name in the virtual host
+func (rm *routersManagerImpl) RemoveRoute(routerConfigName, virtualHostName, routeName string) error {
+	return rm.updateRoutersConfig(routerConfigName, "RemoveRoute", func(cfg *v2.RouterConfiguration) error {
+		for i := range cfg.VirtualHosts {
+			vh := &cfg.VirtualHosts[i]
+			if vh.Name != virtualHostName {
+				continue
+			}
+			routers := make([]v2.Router, 0, len(vh.Routers))
+			for _, r := range vh.Routers {
+				if r.Name != routeName {
+					routers = append(routers, r)
+				}
+			}
+			if len(routers) == len(vh.Routers) {
+				return ErrNoRouteName
+			}
+			vh.Routers = routers
+			return nil
+		}
+		return ErrNoVirtualHostName
+	})
+}
+
+// UpdateRoute replaces the routes with the same name in the virtual host
+func (rm *routersManagerImpl) UpdateRoute(routerConfigName, virtualHostName string, route *v2.Router) error {
+	if route == nil || route.Name == "" {
+		return ErrNoRouteName
+	}
+	return rm.updateRoutersConfig(routerConfigName, "UpdateRoute", func(cfg *v2.RouterConfiguration) error {
+		for i := range cfg.VirtualHosts {
+			vh := &cfg.VirtualHosts[i]
+			if vh.Name != virtualHostName {
+				continue
+			}
+			routers := make([]v2.Router, len(vh.Routers))
+			found := false
+			for j, r := range vh.Routers {
+				if r.Name == route.Name {
+					routers[j] = *route
+					found = true
+				} else {
+					routers[j] = r
+				}
+			}
+			if !found {
+				return ErrNoRouteName
+			}
+			vh.Routers = routers
+			return nil
+		}
+		return ErrNoVirtualHostName
+	})
+}
+
+// updateRoutersConfig modifies a copy of the stored config, and rebuilds the routers with it.
+// the stored routers and config are not changed if any error occurs.
+func (rm *routersManagerImpl) updateRoutersConfig(routerConfigName, function string, modify func(cfg *v2.RouterConfiguration) error) error {
+	v, ok := rm.routersWrapperMap.Load(routerConfigName)
+	if !ok {
+		log.DefaultLogger.Errorf(RouterLogFormat, "routers_manager", function, "error: "+ErrNoRouterConfig.Error()+", name: "+routerConfigName)
+		return ErrNoRouterConfig
+	}
+	rw, ok := v.(*RoutersWrapper)
+	if !ok {
+		log.DefaultLogger.Errorf(RouterLogFormat, "routers_manager", function, "unexpected object in routers map")
+		return ErrUnexpected
+	}
+	rw.mux.Lock()
+	defer rw.mux.Unlock()
+	// make a new one to avoid the slice is referenced outside the lock
+	cfg := *rw.routersConfig
+	cfg.VirtualHosts = make([]v2.VirtualHost, len(rw.routersConfig.VirtualHosts))
+	copy(cfg.VirtualHosts, rw.routersConfig.VirtualHosts)
+	if err := modify(&cfg); err != nil {
+		log.DefaultLogger.Errorf(RouterLogFormat, "routers_manager", function, fmt.Sprintf("router: %s, error: %v", routerConfigName, err))
+		return err
+	}
+	var routers types.Routers
+	// a router config without virtual hosts is valid, the routers is nil
+	if len(cfg.VirtualHosts) > 0 {
+		var err error
+		if routers, err = NewRouters(&cfg); err != nil {
+			log.DefaultLogger.Errorf(RouterLogFormat, "routers_manager", function, fmt.Sprintf("router: %s, error: %v", routerConfigName, err))
+			return err
+		}
+	}
+	rw.routers = routers
+	rw.routersConfig = &cfg
+	configmanager.SetRouter(cfg)
+	onRoutersUpdated(&cfg)
+	if log.DefaultLogger.GetLogLevel() >= log.INFO {
+		log.DefaultLogger.Infof(RouterLogFormat, "routers_manager", function, "update router: "+routerConfigName)
 	}
 	return nil
 }
@@ -455,3 +455,117 @@ func Test_routersManager_RemoveAllRouter(t *testing.T) {
 		t.Fatal("remove route, but still can matched")
 	}
 }
+
+func Test_routersManager_VirtualHostAndRoute(t *testing.T) {
+	ctx := variable.NewVariableContext(context.Background())
+	routerManager := NewRouterManager()
+	newRoute := func(name, value string) v2.Router {
+		return v2.Router{
+			RouterConfig: v2.RouterConfig{
+				Name: name,
+				Match: v2.RouterMatch{
+					Headers: []v2.HeaderMatcher{
+						{
+							Name:  "service",
+							Value: value,
+						},
+					},
+				},
+			},
+		}
+	}
+	routerCfg := &v2.RouterConfiguration{
+		RouterConfigurationConfig: v2.RouterConfigurationConfig{
+			RouterConfigName: "test_virtual_host_route",
+		},
+		VirtualHosts: []v2.VirtualHost{
+			{
+				Name:    "test_vh",
+				Domains: []string{"www.test.com"},
+				Routers: []v2.Router{newRoute("r1", "test1"), newRoute("r2", "test2")},
+			},
+		},
+	}
+	if err := routerManager.AddOrUpdateRouters(routerCfg); err != nil {
+		t.Fatal("init router config failed")
+	}
+	rw := routerManager.GetRouterWrapperByName("test_virtual_host_route")
+	// add a virtual host
+	if err := routerManager.AddOrUpdateVirtualHost("test_virtual_host_route", &v2.VirtualHost{
+		Name:    "test_vh2",
+		Domains: []string{"www.test.net"},
+		Routers: []v2.Router{newRoute("r3", "test3")},
+	}); err != nil {
+		t.Fatal("add virtual host failed", err)
+	}
+	if cfg := rw.GetRoutersConfig(); len(cfg.VirtualHosts) != 2 {
+		t.Fatalf("virtual host is not added: %+v", cfg.VirtualHosts)
+	}
+	variable.SetString(ctx, types.VarHost, "www.test.net")
+	if r := rw.GetRouters().MatchRouteFromHeaderKV(ctx, nil, "service", "test3"); r == nil {
+		t.Fatal("added virtual host, but can not find the route")
+	}
+	// update the virtual host by name
+	if err := routerManager.AddOrUpdateVirtualHost("test_virtual_host_route", &v2.VirtualHost{
+		Name:    "test_vh2",
+		Domains: []string{"www.test.net"},
+		Routers: []v2.Router{newRoute("r4", "test4")},
+	}); err != nil {
+		t.Fatal("update virtual host failed", err)
+	}
+	if r := rw.GetRouters().MatchRouteFromHeaderKV(ctx, nil, "service", "test3"); r != nil {
+		t.Fatal("updated virtual host, but still find the old route")
+	}
+	if r := rw.GetRouters().MatchRouteFromHeaderKV(ctx, nil, "service", "test4"); r == nil {
+		t.Fatal("updated virtual host, but can not find the new route")
+	}
+	// update a route by name
+	if err := routerManager.UpdateRoute("test_virtual_host_route", "test_vh", func() *v2.Router {
+		r := newRoute("r2", "test5")
+		return &r
+	}()); err != nil {
+		t.Fatal("update route failed", err)
+	}
+	variable.SetString(ctx, types.VarHost, "www.test.com")
+	if r := rw.GetRouters().MatchRouteFromHeaderKV(ctx, nil, "service", "test2"); r != nil {
+		t.Fatal("updated route, but still find the old one")
+	}
+	if r := rw.GetRouters().MatchRouteFromHeaderKV(ctx, nil, "service", "test5"); r == nil {
+		t.Fatal("updated route, but can not find the new one")
+	}
+	r6 := newRoute("r6", "test6")
+	if err := routerManager.UpdateRoute("test_virtual_host_route", "test_vh", &r6); err != ErrNoRouteName {
+		t.Fatalf("update a route not exists, expected error: %v, but got: %v", ErrNoRouteName, err)
+	}
+	// remove a route
+	if err := routerManager.RemoveRoute("test_virtual_host_route", "test_vh", "r1"); err != nil {
+		t.Fatal("remove route failed", err)
+	}
+	variable.SetString(ctx, types.VarHost, "www.test.com")
+	if r := rw.GetRouters().MatchRouteFromHeaderKV(ctx, nil, "service", "test1"); r != nil {
+		t.Fatal("removed route, but still find it")
+	}
+	if r := rw.GetRouters().MatchRouteFromHeaderKV(ctx, nil, "service", "test5"); r == nil {
+		t.Fatal("route not removed, but can not find it")
+	}
+	if err := routerManager.RemoveRoute("test_virtual_host_route", "test_vh", "r1"); err != ErrNoRouteName {
+		t.Fatalf("remove a removed route, expected error: %v, but got: %v", ErrNoRouteName, err)
+	}
+	// remove a virtual host
+	if err := routerManager.RemoveVirtualHost("test_virtual_host_route", "test_vh"); err != nil {
+		t.Fatal("remove virtual host failed", err)
+	}
+	if r := rw.GetRouters().MatchRouteFromHeaderKV(ctx, nil, "service", "test5"); r != nil {
+		t.Fatal("removed virtual host, but still find the route")
+	}
+	if cfg := rw.GetRoutersConfig(); len(cfg.VirtualHosts) != 1 || cfg.VirtualHosts[0].Name != "test_vh2" {
+		t.Fatalf("virtual host is not removed: %+v", cfg.VirtualHosts)
+	}
+	if err := routerManager.RemoveVirtualHost("test_virtual_host_route", "test_vh"); err != ErrNoVirtualHostName {
+		t.Fatalf("remove a removed virtual host, expected error: %v, but got: %v", ErrNoVirtualHostName, err)
+	}
+	// the router config is not exists
+	if err := routerManager.RemoveVirtualHost("not_exists", "test_vh"); err != ErrNoRouterConfig {
+		t.Fatalf("expected error: %v, but got: %v", ErrNoRouterConfig, err)
+	}
+}
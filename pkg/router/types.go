@@ -45,6 +45,9 @@ var (
 	ErrDuplicateHostPort    = errors.New("duplicate virtual host port")
 	ErrNoVirtualHostPort    = errors.New("virtual host port is invalid")
 	ErrUnexpected           = errors.New("an unexpected error occurs")
+	ErrNoRouterConfig       = errors.New("router config is not found")
+	ErrNoVirtualHostName    = errors.New("virtual host is not found")
+	ErrNoRouteName          = errors.New("route is not found")
 )
 
 var defaultRouteHandlerName = types.DefaultRouteHandler
@@ -226,6 +229,18 @@ func (hp *cookieHashPolicyImpl) GenerateHash(ctx context.Context) uint64 {
 	return 0
 }
 
+type variableHashPolicyImpl struct {
+	name string
+}
+
+func (hp *variableHashPolicyImpl) GenerateHash(ctx context.Context) uint64 {
+	value, err := variable.GetString(ctx, hp.name)
+	if err == nil {
+		return getHashByString(fmt.Sprintf("%s:%s", hp.name, value))
+	}
+	return 0
+}
+
 type sourceIPHashPolicyImpl struct{}
 
 func (hp *sourceIPHashPolicyImpl) GenerateHash(ctx context.Context) uint64 {
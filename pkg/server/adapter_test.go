@@ -18,12 +18,14 @@
 package server
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"io"
 	"net"
 	"reflect"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -421,3 +423,76 @@ func TestListenerMetrics(t *testing.T) {
 		t.Fatalf("mosn listener metrics is not expected, got %d", lnCount)
 	}
 }
+
+type testAccessLogSink struct {
+	closed int32
+}
+
+func (s *testAccessLogSink) Write(ctx context.Context, entry []byte, reqHeaders api.HeaderMap, respHeaders api.HeaderMap, requestInfo api.RequestInfo) {
+}
+
+func (s *testAccessLogSink) Close() error {
+	atomic.StoreInt32(&s.closed, 1)
+	return nil
+}
+
+func TestUpdateListenerAccessLogs(t *testing.T) {
+	setup()
+	defer tearDown()
+
+	var sinks []*testAccessLogSink
+	log.RegisterAccessLogSink("test_listener_sink", func(cfg map[string]interface{}) (log.AccessLogSink, error) {
+		s := &testAccessLogSink{}
+		sinks = append(sinks, s)
+		return s, nil
+	})
+	accessLogs := func(format string) []v2.AccessLog {
+		return []v2.AccessLog{{
+			Format: format,
+			Sink:   &v2.AccessLogSink{Type: "test_listener_sink"},
+		}}
+	}
+	addrStr := "127.0.0.1:8090"
+	name := "listener_access_logs"
+	cfg := baseListenerConfig(addrStr, name)
+	cfg.AccessLogs = accessLogs("%start_time%")
+	if err := GetListenerAdapterInstance().AddOrUpdateListener(testServerName, cfg); err != nil {
+		t.Fatalf("add listener failed, %v", err)
+	}
+	// the access logs are not changed
+	cfg = baseListenerConfig(addrStr, name)
+	cfg.AccessLogs = accessLogs("%start_time%")
+	if err := GetListenerAdapterInstance().AddOrUpdateListener(testServerName, cfg); err != nil {
+		t.Fatalf("update listener failed, %v", err)
+	}
+	if len(sinks) != 1 {
+		t.Fatalf("expected 1 sink, but got %d", len(sinks))
+	}
+	// the replaced access logs are closed
+	cfg = baseListenerConfig(addrStr, name)
+	cfg.AccessLogs = accessLogs("%response_code%")
+	if err := GetListenerAdapterInstance().AddOrUpdateListener(testServerName, cfg); err != nil {
+		t.Fatalf("update listener failed, %v", err)
+	}
+	if len(sinks) != 2 {
+		t.Fatalf("expected 2 sinks, but got %d", len(sinks))
+	}
+	waitClosed := func(s *testAccessLogSink) bool {
+		for i := 0; i < 100; i++ {
+			if atomic.LoadInt32(&s.closed) == 1 {
+				return true
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return false
+	}
+	if !waitClosed(sinks[0]) || atomic.LoadInt32(&sinks[1].closed) == 1 {
+		t.Fatal("the replaced access log sink is not closed")
+	}
+	// the access logs of the removed listener are closed
+	handler := listenerAdapterInstance.connHandlerMap[testServerName]
+	handler.RemoveListeners(name)
+	if !waitClosed(sinks[1]) {
+		t.Fatal("the access log sink of the removed listener is not closed")
+	}
+}
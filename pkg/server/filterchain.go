@@ -0,0 +1,304 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/configmanager"
+	"mosn.io/mosn/pkg/mtls"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/variable"
+)
+
+// filterChain is the runtime of a listener's filter chain,
+// the connections selected by the match use the chain's network filters and tls context.
+type filterChain struct {
+	match                   *filterChainMatch
+	networkFiltersFactories []api.NetworkFilterChainFactory
+	tlsMng                  types.TLSContextManager
+}
+
+// newFilterChains creates the filter chains of the listener.
+// the network filters factories of the first filter chain is created by the caller.
+func newFilterChains(lc *v2.Listener, networkFiltersFactories []api.NetworkFilterChainFactory) ([]*filterChain, error) {
+	chains := make([]*filterChain, 0, len(lc.FilterChains))
+	// rules are the match rules of the chains, two chains can not match a connection equally
+	rules := map[string]int{}
+	for i := range lc.FilterChains {
+		fc := &lc.FilterChains[i]
+		match, err := newFilterChainMatch(fc.Match)
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range match.rules() {
+			if j, ok := rules[rule]; ok {
+				return nil, fmt.Errorf("filter chain %d and %d have the same match rule: %s", j, i, rule)
+			}
+			rules[rule] = i
+		}
+		chain := &filterChain{
+			match:                   match,
+			networkFiltersFactories: networkFiltersFactories,
+		}
+		cfg := lc
+		if len(lc.FilterChains) > 1 {
+			// each filter chain has its own tls context
+			chainCfg := *lc
+			chainCfg.FilterChains = lc.FilterChains[i : i+1]
+			if i > 0 {
+				chainCfg.Name = fmt.Sprintf("%s_%d", lc.Name, i)
+				chain.networkFiltersFactories = configmanager.CreateNetworkFilterFactories(lc, fc)
+			}
+			cfg = &chainCfg
+		}
+		chain.tlsMng, err = mtls.NewTLSServerContextManager(cfg)
+		if err != nil {
+			return nil, err
+		}
+		chains = append(chains, chain)
+	}
+	return chains, nil
+}
+
+// selectFilterChain returns the most specific filter chain that matches the connection like envoy.
+// The criteria are compared in order: destination port, server name, transport protocol, application
+// protocols and source address. For each criterion, the chains that match it with a configured value are
+// preferred to the chains without it, and the exact server name and the longest source prefix are preferred.
+func selectFilterChain(ctx context.Context, chains []*filterChain, conn net.Conn) *filterChain {
+	var selected *filterChain
+	var best []int
+	for _, chain := range chains {
+		scores, ok := chain.match.scores(ctx, conn)
+		if !ok {
+			continue
+		}
+		if selected == nil || compareScores(scores, best) > 0 {
+			selected, best = chain, scores
+		}
+	}
+	return selected
+}
+
+func compareScores(a, b []int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] - b[i]
+		}
+	}
+	return 0
+}
+
+// filterChainMatch is the runtime of v2.FilterChainMatchConfig
+type filterChainMatch struct {
+	destinationPort      int
+	sourcePrefixRanges   []*net.IPNet
+	serverNames          []string
+	transportProtocol    string
+	applicationProtocols []string
+}
+
+func newFilterChainMatch(cfg *v2.FilterChainMatchConfig) (*filterChainMatch, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	m := &filterChainMatch{
+		destinationPort:      int(cfg.DestinationPort),
+		transportProtocol:    cfg.TransportProtocol,
+		applicationProtocols: cfg.ApplicationProtocols,
+	}
+	for _, r := range cfg.SourcePrefixRanges {
+		if !strings.Contains(r, "/") {
+			// a single address
+			if strings.Contains(r, ":") {
+				r += "/128"
+			} else {
+				r += "/32"
+			}
+		}
+		_, ipnet, err := net.ParseCIDR(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid source prefix range %s: %v", r, err)
+		}
+		m.sourcePrefixRanges = append(m.sourcePrefixRanges, ipnet)
+	}
+	for _, name := range cfg.ServerNames {
+		m.serverNames = append(m.serverNames, strings.ToLower(name))
+	}
+	return m, nil
+}
+
+// rules returns the combinations of the criteria values, the connections matched by
+// the same combination are matched by the chains equally.
+func (m *filterChainMatch) rules() []string {
+	var port, transport string
+	serverNames, protocols, sources := []string{""}, []string{""}, []string{""}
+	if m != nil {
+		if m.destinationPort != 0 {
+			port = strconv.Itoa(m.destinationPort)
+		}
+		transport = m.transportProtocol
+		if len(m.serverNames) > 0 {
+			serverNames = m.serverNames
+		}
+		if len(m.applicationProtocols) > 0 {
+			protocols = m.applicationProtocols
+		}
+		if len(m.sourcePrefixRanges) > 0 {
+			sources = sources[:0]
+			for _, r := range m.sourcePrefixRanges {
+				sources = append(sources, r.String())
+			}
+		}
+	}
+	var rules []string
+	for _, name := range serverNames {
+		for _, proto := range protocols {
+			for _, source := range sources {
+				rules = append(rules, fmt.Sprintf("port=%s,server_name=%s,transport_protocol=%s,application_protocol=%s,source=%s",
+					port, name, transport, proto, source))
+			}
+		}
+	}
+	return rules
+}
+
+// Match checks the connection with the match criteria, a nil match matches any connection.
+func (m *filterChainMatch) Match(ctx context.Context, conn net.Conn) bool {
+	_, ok := m.scores(ctx, conn)
+	return ok
+}
+
+// scores checks the connection with the match criteria, and returns how specific each criterion is matched,
+// in the order of selectFilterChain. The score of a criterion without configured value is zero.
+func (m *filterChainMatch) scores(ctx context.Context, conn net.Conn) ([]int, bool) {
+	scores := make([]int, 5)
+	if m == nil {
+		return scores, true
+	}
+	if m.destinationPort != 0 {
+		addr, ok := destinationAddr(ctx, conn).(*net.TCPAddr)
+		if !ok || addr.Port != m.destinationPort {
+			return nil, false
+		}
+		scores[0] = 1
+	}
+	if len(m.serverNames) > 0 {
+		serverName, err := variable.GetString(ctx, types.VarRequestedServerName)
+		if err != nil {
+			return nil, false
+		}
+		if scores[1] = m.matchServerName(serverName); scores[1] == 0 {
+			return nil, false
+		}
+	}
+	if m.transportProtocol != "" {
+		transport, err := variable.GetString(ctx, types.VarTransportProtocol)
+		if err != nil || transport == "" {
+			// the transport protocol is raw buffer if no listener filter detects it
+			transport = v2.TransportProtocolRaw
+		}
+		if transport != m.transportProtocol {
+			return nil, false
+		}
+		scores[2] = 1
+	}
+	if len(m.applicationProtocols) > 0 {
+		v, err := variable.Get(ctx, types.VariableApplicationProtocols)
+		if err != nil {
+			return nil, false
+		}
+		protos, _ := v.([]string)
+		if !m.matchApplicationProtocols(protos) {
+			return nil, false
+		}
+		scores[3] = 1
+	}
+	if len(m.sourcePrefixRanges) > 0 {
+		if scores[4] = m.matchSource(conn.RemoteAddr()); scores[4] == 0 {
+			return nil, false
+		}
+	}
+	return scores, true
+}
+
+// destinationAddr returns the original destination restored by the original dst listener filter,
+// or the local address of the connection
+func destinationAddr(ctx context.Context, conn net.Conn) net.Addr {
+	if v, err := variable.Get(ctx, types.VariableOriRemoteAddr); err == nil {
+		if addr, ok := v.(net.Addr); ok && addr != nil {
+			return addr
+		}
+	}
+	return conn.LocalAddr()
+}
+
+// matchSource returns the prefix length of the longest matched range plus one, or zero if not matched
+func (m *filterChainMatch) matchSource(addr net.Addr) int {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return 0
+	}
+	longest := 0
+	for _, r := range m.sourcePrefixRanges {
+		if r.Contains(tcpAddr.IP) {
+			if ones, _ := r.Mask.Size(); ones+1 > longest {
+				longest = ones + 1
+			}
+		}
+	}
+	return longest
+}
+
+// matchServerName supports exact names and wildcard names such as *.example.com, it returns
+// how specific the name is matched: the exact name is preferred to the longest wildcard name.
+// zero is returned if not matched.
+func (m *filterChainMatch) matchServerName(serverName string) int {
+	if serverName == "" {
+		return 0
+	}
+	serverName = strings.ToLower(serverName)
+	score := 0
+	for _, name := range m.serverNames {
+		if name == serverName {
+			return math.MaxInt32
+		}
+		if strings.HasPrefix(name, "*.") && strings.HasSuffix(serverName, name[1:]) && len(name) > score {
+			score = len(name)
+		}
+	}
+	return score
+}
+
+func (m *filterChainMatch) matchApplicationProtocols(protos []string) bool {
+	for _, proto := range protos {
+		for _, p := range m.applicationProtocols {
+			if p == proto {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,294 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/variable"
+)
+
+type mockAddrConn struct {
+	net.Conn
+	local  net.Addr
+	remote net.Addr
+}
+
+func (c *mockAddrConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+func (c *mockAddrConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func newMockAddrConn(local, remote string) net.Conn {
+	l, _ := net.ResolveTCPAddr("tcp", local)
+	r, _ := net.ResolveTCPAddr("tcp", remote)
+	return &mockAddrConn{local: l, remote: r}
+}
+
+func newInspectedContext(transport, serverName string, protos []string) context.Context {
+	ctx := variable.NewVariableContext(context.Background())
+	if transport != "" {
+		_ = variable.SetString(ctx, types.VarTransportProtocol, transport)
+	}
+	if serverName != "" {
+		_ = variable.SetString(ctx, types.VarRequestedServerName, serverName)
+	}
+	if len(protos) > 0 {
+		_ = variable.Set(ctx, types.VariableApplicationProtocols, protos)
+	}
+	return ctx
+}
+
+func TestFilterChainMatch(t *testing.T) {
+	conn := newMockAddrConn("127.0.0.1:443", "10.1.2.3:50000")
+	testCases := []struct {
+		name   string
+		config *v2.FilterChainMatchConfig
+		ctx    context.Context
+		match  bool
+	}{
+		{
+			name:  "nil match",
+			ctx:   newInspectedContext("", "", nil),
+			match: true,
+		},
+		{
+			name:   "destination port",
+			config: &v2.FilterChainMatchConfig{DestinationPort: 443},
+			ctx:    newInspectedContext("", "", nil),
+			match:  true,
+		},
+		{
+			name:   "destination port not matched",
+			config: &v2.FilterChainMatchConfig{DestinationPort: 80},
+			ctx:    newInspectedContext("", "", nil),
+			match:  false,
+		},
+		{
+			name:   "source prefix ranges",
+			config: &v2.FilterChainMatchConfig{SourcePrefixRanges: []string{"192.168.0.0/16", "10.0.0.0/8"}},
+			ctx:    newInspectedContext("", "", nil),
+			match:  true,
+		},
+		{
+			name:   "source address",
+			config: &v2.FilterChainMatchConfig{SourcePrefixRanges: []string{"10.1.2.4"}},
+			ctx:    newInspectedContext("", "", nil),
+			match:  false,
+		},
+		{
+			name:   "transport protocol is raw buffer without inspector",
+			config: &v2.FilterChainMatchConfig{TransportProtocol: v2.TransportProtocolRaw},
+			ctx:    newInspectedContext("", "", nil),
+			match:  true,
+		},
+		{
+			name:   "transport protocol tls",
+			config: &v2.FilterChainMatchConfig{TransportProtocol: v2.TransportProtocolTLS},
+			ctx:    newInspectedContext(v2.TransportProtocolTLS, "", nil),
+			match:  true,
+		},
+		{
+			name:   "server name",
+			config: &v2.FilterChainMatchConfig{ServerNames: []string{"www.example.com"}},
+			ctx:    newInspectedContext(v2.TransportProtocolTLS, "www.example.com", nil),
+			match:  true,
+		},
+		{
+			name:   "wildcard server name",
+			config: &v2.FilterChainMatchConfig{ServerNames: []string{"*.Example.com"}},
+			ctx:    newInspectedContext(v2.TransportProtocolTLS, "api.example.com", nil),
+			match:  true,
+		},
+		{
+			name:   "wildcard server name does not match the parent domain",
+			config: &v2.FilterChainMatchConfig{ServerNames: []string{"*.example.com"}},
+			ctx:    newInspectedContext(v2.TransportProtocolTLS, "example.com", nil),
+			match:  false,
+		},
+		{
+			name:   "server name without sni",
+			config: &v2.FilterChainMatchConfig{ServerNames: []string{"www.example.com"}},
+			ctx:    newInspectedContext(v2.TransportProtocolTLS, "", nil),
+			match:  false,
+		},
+		{
+			name:   "application protocols",
+			config: &v2.FilterChainMatchConfig{ApplicationProtocols: []string{"h2"}},
+			ctx:    newInspectedContext(v2.TransportProtocolTLS, "", []string{"h2", "http/1.1"}),
+			match:  true,
+		},
+		{
+			name:   "application protocols not matched",
+			config: &v2.FilterChainMatchConfig{ApplicationProtocols: []string{"h2"}},
+			ctx:    newInspectedContext(v2.TransportProtocolTLS, "", []string{"http/1.1"}),
+			match:  false,
+		},
+		{
+			name: "all conditions",
+			config: &v2.FilterChainMatchConfig{
+				DestinationPort:      443,
+				SourcePrefixRanges:   []string{"10.0.0.0/8"},
+				ServerNames:          []string{"www.example.com"},
+				TransportProtocol:    v2.TransportProtocolTLS,
+				ApplicationProtocols: []string{"h2"},
+			},
+			ctx:   newInspectedContext(v2.TransportProtocolTLS, "www.example.com", []string{"h2"}),
+			match: true,
+		},
+	}
+	for _, tc := range testCases {
+		m, err := newFilterChainMatch(tc.config)
+		require.Nil(t, err, tc.name)
+		assert.Equal(t, tc.match, m.Match(tc.ctx, conn), tc.name)
+	}
+}
+
+func TestNewFilterChainMatchInvalid(t *testing.T) {
+	_, err := newFilterChainMatch(&v2.FilterChainMatchConfig{SourcePrefixRanges: []string{"10.0.0.0/33"}})
+	assert.NotNil(t, err)
+}
+
+func TestSelectFilterChain(t *testing.T) {
+	lc := &v2.Listener{
+		ListenerConfig: v2.ListenerConfig{
+			Name: "test_select_filter_chain",
+			FilterChains: []v2.FilterChain{
+				{
+					FilterChainConfig: v2.FilterChainConfig{
+						Match: &v2.FilterChainMatchConfig{ServerNames: []string{"www.example.com"}},
+					},
+				},
+				{
+					FilterChainConfig: v2.FilterChainConfig{
+						Match: &v2.FilterChainMatchConfig{TransportProtocol: v2.TransportProtocolTLS},
+					},
+				},
+				{
+					FilterChainConfig: v2.FilterChainConfig{
+						Match: &v2.FilterChainMatchConfig{TransportProtocol: v2.TransportProtocolRaw},
+					},
+				},
+			},
+		},
+	}
+	chains, err := newFilterChains(lc, nil)
+	require.Nil(t, err)
+	require.Len(t, chains, 3)
+	conn := newMockAddrConn("127.0.0.1:443", "10.1.2.3:50000")
+
+	assert.Equal(t, chains[0], selectFilterChain(newInspectedContext(v2.TransportProtocolTLS, "www.example.com", nil), chains, conn))
+	assert.Equal(t, chains[1], selectFilterChain(newInspectedContext(v2.TransportProtocolTLS, "api.example.com", nil), chains, conn))
+	assert.Equal(t, chains[2], selectFilterChain(newInspectedContext(v2.TransportProtocolRaw, "", nil), chains, conn))
+	assert.Nil(t, selectFilterChain(newInspectedContext("quic", "", nil), chains, conn))
+}
+
+func newTestFilterChains(t *testing.T, matches ...*v2.FilterChainMatchConfig) []*filterChain {
+	lc := &v2.Listener{ListenerConfig: v2.ListenerConfig{Name: "test_specific_filter_chain"}}
+	for _, m := range matches {
+		lc.FilterChains = append(lc.FilterChains, v2.FilterChain{
+			FilterChainConfig: v2.FilterChainConfig{Match: m},
+		})
+	}
+	chains, err := newFilterChains(lc, nil)
+	require.Nil(t, err)
+	return chains
+}
+
+func TestSelectMostSpecificFilterChain(t *testing.T) {
+	conn := newMockAddrConn("127.0.0.1:443", "10.1.2.3:50000")
+	tlsCtx := newInspectedContext(v2.TransportProtocolTLS, "www.example.com", []string{"h2"})
+
+	// the order of the chains does not matter
+	chains := newTestFilterChains(t,
+		nil,
+		&v2.FilterChainMatchConfig{TransportProtocol: v2.TransportProtocolTLS},
+		&v2.FilterChainMatchConfig{ServerNames: []string{"*.com"}},
+		&v2.FilterChainMatchConfig{ServerNames: []string{"*.example.com"}},
+	)
+	assert.Equal(t, chains[3], selectFilterChain(tlsCtx, chains, conn))
+	assert.Equal(t, chains[2], selectFilterChain(newInspectedContext(v2.TransportProtocolTLS, "www.test.com", nil), chains, conn))
+	assert.Equal(t, chains[1], selectFilterChain(newInspectedContext(v2.TransportProtocolTLS, "", nil), chains, conn))
+	assert.Equal(t, chains[0], selectFilterChain(newInspectedContext(v2.TransportProtocolRaw, "", nil), chains, conn))
+
+	// the exact server name is preferred, and the server name is compared before the transport protocol
+	chains = newTestFilterChains(t,
+		&v2.FilterChainMatchConfig{ServerNames: []string{"*.example.com"}, TransportProtocol: v2.TransportProtocolTLS},
+		&v2.FilterChainMatchConfig{ServerNames: []string{"www.example.com"}},
+	)
+	assert.Equal(t, chains[1], selectFilterChain(tlsCtx, chains, conn))
+
+	// the destination port is compared first
+	chains = newTestFilterChains(t,
+		&v2.FilterChainMatchConfig{ServerNames: []string{"www.example.com"}, ApplicationProtocols: []string{"h2"}},
+		&v2.FilterChainMatchConfig{DestinationPort: 443},
+	)
+	assert.Equal(t, chains[1], selectFilterChain(tlsCtx, chains, conn))
+
+	// the longest source prefix is preferred
+	chains = newTestFilterChains(t,
+		&v2.FilterChainMatchConfig{SourcePrefixRanges: []string{"10.0.0.0/8", "192.168.0.0/16"}},
+		&v2.FilterChainMatchConfig{SourcePrefixRanges: []string{"10.1.0.0/16"}},
+		&v2.FilterChainMatchConfig{SourcePrefixRanges: []string{"0.0.0.0/0"}, ApplicationProtocols: []string{"h2"}},
+	)
+	assert.Equal(t, chains[2], selectFilterChain(tlsCtx, chains, conn))
+	assert.Equal(t, chains[1], selectFilterChain(newInspectedContext("", "", nil), chains, conn))
+	assert.Equal(t, chains[0], selectFilterChain(newInspectedContext("", "", nil), chains, newMockAddrConn("127.0.0.1:443", "10.2.0.1:50000")))
+}
+
+func TestFilterChainOriginalDestination(t *testing.T) {
+	chains := newTestFilterChains(t,
+		&v2.FilterChainMatchConfig{DestinationPort: 8080},
+		&v2.FilterChainMatchConfig{DestinationPort: 15001},
+	)
+	// the connection is redirected to 15001 by iptables
+	conn := newMockAddrConn("127.0.0.1:15001", "10.1.2.3:50000")
+	ctx := newInspectedContext("", "", nil)
+	assert.Equal(t, chains[1], selectFilterChain(ctx, chains, conn))
+	dst, _ := net.ResolveTCPAddr("tcp", "10.0.0.1:8080")
+	_ = variable.Set(ctx, types.VariableOriRemoteAddr, dst)
+	assert.Equal(t, chains[0], selectFilterChain(ctx, chains, conn))
+}
+
+func TestFilterChainsSameRule(t *testing.T) {
+	for _, matches := range [][]*v2.FilterChainMatchConfig{
+		{nil, nil},
+		{nil, {}},
+		{{ServerNames: []string{"a.com", "b.com"}}, {ServerNames: []string{"B.com"}}},
+		{{SourcePrefixRanges: []string{"10.0.0.1"}}, {SourcePrefixRanges: []string{"10.0.0.1/32"}}},
+		{{DestinationPort: 443, ApplicationProtocols: []string{"h2", "http/1.1"}}, {DestinationPort: 443, ApplicationProtocols: []string{"http/1.1"}}},
+	} {
+		lc := &v2.Listener{ListenerConfig: v2.ListenerConfig{Name: "test_same_rule"}}
+		for _, m := range matches {
+			lc.FilterChains = append(lc.FilterChains, v2.FilterChain{
+				FilterChainConfig: v2.FilterChainConfig{Match: m},
+			})
+		}
+		_, err := newFilterChains(lc, nil)
+		assert.NotNil(t, err)
+	}
+}
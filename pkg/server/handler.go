@@ -25,6 +25,7 @@ import (
 	"io"
 	"net"
 	"os"
+	"reflect"
 	"strconv"
 	"strings"
 	"sync"
@@ -41,7 +42,6 @@ import (
 	"mosn.io/mosn/pkg/filter/listener/originaldst"
 	"mosn.io/mosn/pkg/log"
 	"mosn.io/mosn/pkg/metrics"
-	"mosn.io/mosn/pkg/mtls"
 	"mosn.io/mosn/pkg/network"
 	"mosn.io/mosn/pkg/streamfilter"
 	"mosn.io/mosn/pkg/types"
@@ -109,9 +109,8 @@ func (ch *connHandler) AddOrUpdateListener(lc *v2.Listener) (types.ListenerEvent
 	} else {
 		listenerName = lc.Name
 	}
-	// currently, we just support one filter chain
-	if len(lc.FilterChains) != 1 {
-		return nil, errors.New("error updating listener, listener have filter chains count is not 1")
+	if len(lc.FilterChains) == 0 {
+		return nil, errors.New("error updating listener, listener have no filter chains")
 	}
 	// set listener filter , network filter and stream filter
 	var listenerFiltersFactories []api.ListenerFilterChainFactory
@@ -135,25 +134,19 @@ func (ch *connHandler) AddOrUpdateListener(lc *v2.Listener) (types.ListenerEvent
 
 		al.listenerFiltersFactories = listenerFiltersFactories
 		rawConfig.ListenerFilters = lc.ListenerFilters
-		al.networkFiltersFactories = networkFiltersFactories
-		rawConfig.FilterChains[0].FilterChainMatch = lc.FilterChains[0].FilterChainMatch
-		rawConfig.FilterChains[0].Filters = lc.FilterChains[0].Filters
-
 		rawConfig.StreamFilters = lc.StreamFilters
 
-		// tls update only take effects on new connections
+		// filter chains and tls update only take effects on new connections
 		// config changed
-		rawConfig.FilterChains[0].TLSContexts = lc.FilterChains[0].TLSContexts
-		rawConfig.FilterChains[0].TLSConfig = lc.FilterChains[0].TLSConfig
-		rawConfig.FilterChains[0].TLSConfigs = lc.FilterChains[0].TLSConfigs
+		rawConfig.FilterChains = lc.FilterChains
 		rawConfig.Inspector = lc.Inspector
-		mgr, err := mtls.NewTLSServerContextManager(rawConfig)
+		chains, err := newFilterChains(rawConfig, networkFiltersFactories)
 		if err != nil {
-			log.DefaultLogger.Errorf("[server] [conn handler] [update listener] create tls context manager failed, %v", err)
+			log.DefaultLogger.Errorf("[server] [conn handler] [update listener] create filter chains failed, %v", err)
 			return nil, err
 		}
 		// object changed
-		al.tlsMng = mgr
+		al.filterChains = chains
 		// some simle config update
 		rawConfig.PerConnBufferLimitBytes = lc.PerConnBufferLimitBytes
 		al.listener.SetPerConnBufferLimitBytes(lc.PerConnBufferLimitBytes)
@@ -162,6 +155,17 @@ func (ch *connHandler) AddOrUpdateListener(lc *v2.Listener) (types.ListenerEvent
 		rawConfig.OriginalDst = lc.OriginalDst
 		al.listener.SetOriginalDstType(lc.OriginalDst)
 		al.idleTimeout = lc.ConnectionIdleTimeout
+		// the access logs are recreated only if changed, because the sinks hold the remote connections
+		if !reflect.DeepEqual(rawConfig.AccessLogs, lc.AccessLogs) {
+			als, err := createAccessLogs(lc)
+			if err != nil {
+				log.DefaultLogger.Errorf("[server] [conn handler] [update listener] create access logs failed, %v", err)
+				return nil, err
+			}
+			rawConfig.AccessLogs = lc.AccessLogs
+			closeAccessLogs(al.accessLogs)
+			al.accessLogs = als
+		}
 		al.listener.SetConfig(rawConfig)
 
 		// set update label to true, do not start the listener again
@@ -176,25 +180,13 @@ func (ch *connHandler) AddOrUpdateListener(lc *v2.Listener) (types.ListenerEvent
 		listenerStopChan := make(chan struct{})
 
 		//initialize access log
-		var als []api.AccessLog
-
-		for _, alConfig := range lc.AccessLogs {
-
-			//use default listener access log path
-			if alConfig.Path == "" {
-				alConfig.Path = types.MosnLogBasePath + string(os.PathSeparator) + lc.Name + "_access.log"
-			}
-
-			if al, err := log.NewAccessLog(alConfig.Path, alConfig.Format); err == nil {
-				als = append(als, al)
-			} else {
-				return nil, fmt.Errorf("initialize listener access logger %s failed: %v", alConfig.Path, err.Error())
-			}
+		als, err := createAccessLogs(lc)
+		if err != nil {
+			return nil, err
 		}
 
 		l := network.GetListenerFactory()(lc)
 
-		var err error
 		al, err = newActiveListener(l, lc, als, listenerFiltersFactories, networkFiltersFactories, ch, listenerStopChan)
 		if err != nil {
 			return al, err
@@ -211,6 +203,41 @@ func (ch *connHandler) AddOrUpdateListener(lc *v2.Listener) (types.ListenerEvent
 	return al, nil
 }
 
+// createAccessLogs creates the access logs of the listener, the default path is used if the path is empty
+func createAccessLogs(lc *v2.Listener) ([]api.AccessLog, error) {
+	var als []api.AccessLog
+	for _, alConfig := range lc.AccessLogs {
+
+		//use default listener access log path
+		if alConfig.Path == "" {
+			alConfig.Path = types.MosnLogBasePath + string(os.PathSeparator) + lc.Name + "_access.log"
+		}
+
+		if al, err := log.CreateAccessLog(&alConfig); err == nil {
+			als = append(als, al)
+		} else {
+			closeAccessLogs(als)
+			return nil, fmt.Errorf("initialize listener access logger %s failed: %v", alConfig.Path, err.Error())
+		}
+	}
+	return als, nil
+}
+
+// closeAccessLogs closes the replaced access logs in background, because the sinks
+// export the buffered entries before closed.
+func closeAccessLogs(als []api.AccessLog) {
+	if len(als) == 0 {
+		return
+	}
+	utils.GoWithRecover(func() {
+		for _, al := range als {
+			if err := log.CloseAccessLog(al); err != nil {
+				log.DefaultLogger.Errorf("[server] [conn handler] close access log failed: %v", err)
+			}
+		}
+	}, nil)
+}
+
 func (ch *connHandler) StartListener(lctx context.Context, listenerTag uint64) {
 	for _, l := range ch.listeners {
 		if l.listener.ListenerTag() == listenerTag {
@@ -252,6 +279,7 @@ func (ch *connHandler) RemoveListeners(name string) {
 		if l.listener.Name() == name {
 			log.DefaultLogger.Infof("[server] [conn handler] remove listener name: %s", name)
 			ch.listeners = append(ch.listeners[:i], ch.listeners[i+1:]...)
+			closeAccessLogs(l.accessLogs)
 		}
 	}
 }
@@ -371,7 +399,7 @@ func (ch *connHandler) StopConnection() {
 type activeListener struct {
 	listener                 types.Listener
 	listenerFiltersFactories []api.ListenerFilterChainFactory
-	networkFiltersFactories  []api.NetworkFilterChainFactory
+	filterChains             []*filterChain
 	listenIP                 string
 	listenPort               int
 	defaultReadBufferSize    int
@@ -382,7 +410,6 @@ type activeListener struct {
 	accessLogs               []api.AccessLog
 	updatedLabel             bool
 	idleTimeout              *api.DurationConfig
-	tlsMng                   types.TLSContextManager
 }
 
 func newActiveListener(listener types.Listener, lc *v2.Listener, accessLoggers []api.AccessLog,
@@ -398,7 +425,6 @@ func newActiveListener(listener types.Listener, lc *v2.Listener, accessLoggers [
 		accessLogs:               accessLoggers,
 		updatedLabel:             false,
 		idleTimeout:              lc.ConnectionIdleTimeout,
-		networkFiltersFactories:  networkFiltersFactories,
 		listenerFiltersFactories: listenerFiltersFactories,
 	}
 
@@ -415,12 +441,12 @@ func newActiveListener(listener types.Listener, lc *v2.Listener, accessLoggers [
 	al.listenPort = listenPort
 	al.stats = newListenerStats(al.listener.Name())
 
-	mgr, err := mtls.NewTLSServerContextManager(lc)
+	chains, err := newFilterChains(lc, networkFiltersFactories)
 	if err != nil {
-		log.DefaultLogger.Errorf("[server] [new listener] create tls context manager failed, %v", err)
+		log.DefaultLogger.Errorf("[server] [new listener] create filter chains failed, %v", err)
 		return nil, err
 	}
-	al.tlsMng = mgr
+	al.filterChains = chains
 
 	return al, nil
 }
@@ -439,7 +465,7 @@ func (al *activeListener) GoStart(lctx context.Context) {
 func (al *activeListener) OnAccept(rawc net.Conn, useOriginalDst bool, oriRemoteAddr net.Addr, ch chan api.Connection, buf []byte, listeners []api.ConnectionEventListener) {
 	var rawf *os.File
 
-	// only store fd and tls conn handshake in final working listener
+	// only store fd in final working listener
 	if !useOriginalDst {
 		if network.UseNetpollMode {
 			// store fd for further usage
@@ -459,21 +485,12 @@ func (al *activeListener) OnAccept(rawc net.Conn, useOriginalDst bool, oriRemote
 				}
 			}
 		}
-		// if ch is not nil, the conn has been initialized in func transferNewConn
-		if al.tlsMng != nil && ch == nil {
-			conn, err := al.tlsMng.Conn(rawc)
-			if err != nil {
-				if log.DefaultLogger.GetLogLevel() >= log.INFO {
-					log.DefaultLogger.Infof("[server] [listener] accept connection failed, error: %v", err)
-				}
-				rawc.Close()
-				return
-			}
-			rawc = conn
-		}
 	}
 
 	arc := newActiveRawConn(rawc, al)
+	// tls conn handshake in final working listener after the listener filters.
+	// if ch is not nil, the conn has been initialized in func transferNewConn
+	arc.handshake = !useOriginalDst && ch == nil
 
 	// listener filter chain.
 	for _, lfcf := range al.listenerFiltersFactories {
@@ -492,11 +509,15 @@ func (al *activeListener) OnAccept(rawc net.Conn, useOriginalDst bool, oriRemote
 	_ = variable.Set(ctx, types.VariableListenerType, al.listener.Config().Type)
 	_ = variable.Set(ctx, types.VariableListenerName, al.listener.Name())
 	_ = variable.Set(ctx, types.VariableConnDefaultReadBufferSize, al.defaultReadBufferSize)
-	_ = variable.Set(ctx, types.VariableNetworkFilterChainFactories, al.networkFiltersFactories)
 	_ = variable.Set(ctx, types.VariableAccessLogs, al.accessLogs)
 	if rawf != nil {
 		_ = variable.Set(ctx, types.VariableConnectionFd, rawf)
 	}
+	// udp connections are not wrapped by the tls context manager,
+	// the stream codec such as quic runs the tls handshake by itself
+	if len(al.filterChains) > 0 && rawc.LocalAddr().Network() == "udp" {
+		_ = variable.Set(ctx, types.VariableListenerTLSManager, al.filterChains[0].tlsMng)
+	}
 	if ch != nil {
 		_ = variable.Set(ctx, types.VariableAcceptChan, ch)
 		_ = variable.Set(ctx, types.VariableAcceptBuffer, buf)
@@ -520,8 +541,10 @@ func (al *activeListener) OnAccept(rawc net.Conn, useOriginalDst bool, oriRemote
 func (al *activeListener) OnNewConnection(ctx context.Context, conn api.Connection) {
 	//Register Proxy's Filter
 	filterManager := conn.FilterManager()
-	for _, nfcf := range al.networkFiltersFactories {
-		nfcf.CreateFilterChain(ctx, filterManager)
+	if v, err := variable.Get(ctx, types.VariableNetworkFilterChainFactories); err == nil && v != nil {
+		for _, nfcf := range v.([]api.NetworkFilterChainFactory) {
+			nfcf.CreateFilterChain(ctx, filterManager)
+		}
 	}
 
 	ac := newActiveConnection(al, conn)
@@ -682,6 +705,7 @@ type activeRawConn struct {
 	activeListener      *activeListener
 	acceptedFilters     []api.ListenerFilterChainFactory
 	acceptedFilterIndex int
+	handshake           bool
 }
 
 func newActiveRawConn(rawc net.Conn, activeListener *activeListener) *activeRawConn {
@@ -774,8 +798,37 @@ func (arc *activeRawConn) ContinueFilterChain(ctx context.Context, success bool)
 		}
 	}
 
-	arc.activeListener.newConnection(ctx, arc.rawc)
+	al := arc.activeListener
+	chain := selectFilterChain(ctx, al.filterChains, arc.rawc)
+	if chain == nil {
+		if log.DefaultLogger.GetLogLevel() >= log.INFO {
+			log.DefaultLogger.Infof("[server] [listener] no filter chain matched for connection from %s, listener: %s", arc.rawc.RemoteAddr(), al.listener.Name())
+		}
+		arc.rawc.Close()
+		return
+	}
+	_ = variable.Set(ctx, types.VariableNetworkFilterChainFactories, chain.networkFiltersFactories)
+
+	rawc := arc.rawc
+	if arc.handshake && chain.tlsMng != nil {
+		conn, err := chain.tlsMng.Conn(rawc)
+		if err != nil {
+			if log.DefaultLogger.GetLogLevel() >= log.INFO {
+				log.DefaultLogger.Infof("[server] [listener] accept connection failed, error: %v", err)
+			}
+			rawc.Close()
+			return
+		}
+		rawc = conn
+	}
+
+	al.newConnection(ctx, rawc)
+}
 
+// SetConn replaces the accepted connection, it is used by the listener filters
+// that need to peek the data of the connection, such as the tls inspector.
+func (arc *activeRawConn) SetConn(c net.Conn) {
+	arc.rawc = c
 }
 
 func (arc *activeRawConn) Conn() net.Conn {
@@ -62,6 +62,7 @@ var (
 
 	HKConnection = []byte("Connection") // header key 'Connection'
 	HVKeepAlive  = []byte("keep-alive") // header value 'keep-alive'
+	HKAltSvc     = []byte("Alt-Svc")    // header key 'Alt-Svc'
 
 	minMethodLengh = len("GET")
 	maxMethodLengh = len("CONNECT")
@@ -100,6 +101,10 @@ func (f *StreamConnFactory) CreateBiDirectStream(context context.Context, connec
 }
 
 func (f *StreamConnFactory) ProtocolMatch(context context.Context, prot string, magic []byte) error {
+	// the application protocol is negotiated by tls alpn
+	if prot == "http/1.1" || prot == "http/1.0" {
+		return nil
+	}
 	if len(magic) < minMethodLengh {
 		return str.EAGAIN
 	}
@@ -377,6 +382,9 @@ func (conn *clientStreamConnection) CheckReasonError(connected bool, event api.C
 type StreamConfig struct {
 	MaxHeaderSize      int `json:"max_header_size,omitempty"`
 	MaxRequestBodySize int `json:"max_request_body_size,omitempty"`
+	// AltSvc is the Alt-Svc header added to the responses, which advertises the HTTP/3
+	// listener, such as 'h3=":443"; ma=86400'
+	AltSvc string `json:"alt_svc,omitempty"`
 }
 
 var defaultStreamConfig = StreamConfig{
@@ -393,6 +401,7 @@ var defaultStreamConfig = StreamConfig{
 func SetDefaultStreamConfig(c StreamConfig) {
 	defaultStreamConfig.MaxHeaderSize = c.MaxHeaderSize
 	defaultStreamConfig.MaxRequestBodySize = c.MaxRequestBodySize
+	defaultStreamConfig.AltSvc = c.AltSvc
 }
 
 func streamConfigHandler(v interface{}) interface{} {
@@ -852,6 +861,11 @@ func (s *serverStream) endStream() {
 		s.response.SkipBody = true
 	}
 
+	// advertise the alternative service, the header of the upstream is kept
+	if altSvc := s.connection.config.AltSvc; altSvc != "" && len(s.response.Header.PeekBytes(HKAltSvc)) == 0 {
+		s.response.Header.SetCanonical(HKAltSvc, []byte(altSvc))
+	}
+
 	// check if we need close connection
 	if s.connection.close || s.request.Header.ConnectionClose() {
 		// should delete 'Connection:keepalive' header
@@ -27,9 +27,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	"github.com/valyala/fasthttp"
 	"mosn.io/api"
+	"mosn.io/mosn/pkg/mock"
 	"mosn.io/mosn/pkg/network"
 	"mosn.io/mosn/pkg/protocol"
 	"mosn.io/mosn/pkg/protocol/http"
@@ -505,6 +507,50 @@ func TestAppendData(t *testing.T) {
 	}
 }
 
+func TestAltSvc(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	altSvc := `h3=":443"; ma=86400`
+	for _, tc := range []struct {
+		upstream string
+		expected string
+	}{
+		{"", altSvc},
+		{`h3=":8443"`, `h3=":8443"`},
+	} {
+		var written bytes.Buffer
+		conn := mock.NewMockConnection(ctrl)
+		conn.EXPECT().Write(gomock.Any()).DoAndReturn(func(bufs ...buffer.IoBuffer) error {
+			for _, b := range bufs {
+				written.Write(b.Bytes())
+			}
+			return nil
+		})
+
+		var hb httpBuffers
+		s := &serverStream{
+			connection: &serverStreamConnection{
+				streamConnection: streamConnection{conn: conn},
+				config:           StreamConfig{AltSvc: altSvc},
+			},
+			responseDoneChan: make(chan bool, 1),
+		}
+		s.stream = stream{
+			request:  &hb.serverRequest,
+			response: &hb.serverResponse,
+		}
+		if tc.upstream != "" {
+			s.response.Header.Set("Alt-Svc", tc.upstream)
+		}
+		s.endStream()
+
+		var rsp fasthttp.Response
+		assert.Nil(t, rsp.Read(bufio.NewReader(&written)))
+		assert.Equal(t, tc.expected, string(rsp.Header.Peek("Alt-Svc")))
+	}
+}
+
 func convertHeader(payload protocol.CommonHeader) http.RequestHeader {
 	header := http.RequestHeader{&fasthttp.RequestHeader{}}
 
@@ -68,6 +68,10 @@ func (f *StreamConnFactory) CreateBiDirectStream(context context.Context, connec
 }
 
 func (f *StreamConnFactory) ProtocolMatch(context context.Context, prot string, magic []byte) error {
+	// the application protocol is negotiated by tls alpn
+	if prot == http2.NextProtoTLS {
+		return nil
+	}
 	var size int
 	var again bool
 	if len(magic) >= len(http2.ClientPreface) {
@@ -144,6 +148,9 @@ func (s *stream) GetStream() types.Stream {
 
 type StreamConfig struct {
 	Http2UseStream bool `json:"http2_use_stream,omitempty"`
+	// AltSvc is the Alt-Svc header added to the responses, which advertises the HTTP/3
+	// listener, such as 'h3=":443"; ma=86400'
+	AltSvc string `json:"alt_svc,omitempty"`
 }
 
 var defaultStreamConfig = StreamConfig{
@@ -607,6 +614,11 @@ func (s *serverStream) endStream() {
 		}
 	}
 
+	// advertise the alternative service, the header of the upstream is kept
+	if altSvc := s.sc.config.AltSvc; altSvc != "" && s.h2s.Response != nil && s.h2s.Response.Header.Get("Alt-Svc") == "" {
+		s.h2s.Response.Header.Set("Alt-Svc", altSvc)
+	}
+
 	_, err := s.sc.protocol.Encode(s.ctx, s.h2s)
 
 	s.sc.mutex.Lock()
@@ -125,6 +125,57 @@ func TestDirectResponse(t *testing.T) {
 	}
 }
 
+func TestAltSvc(t *testing.T) {
+	testAddr := "127.0.0.1:11346"
+	l, err := net.Listen("tcp", testAddr)
+	if err != nil {
+		t.Logf("listen error %v", err)
+		return
+	}
+	defer l.Close()
+
+	rawc, err := net.Dial("tcp", testAddr)
+	if err != nil {
+		t.Errorf("net.Dial error %v", err)
+		return
+	}
+
+	connection := network.NewServerConnection(context.Background(), rawc, nil)
+	ctx := variable.NewVariableContext(context.Background())
+
+	mh := &mhttp2.MetaHeadersFrame{
+		HeadersFrame: &mhttp2.HeadersFrame{
+			FrameHeader: mhttp2.FrameHeader{
+				Type:     mhttp2.FrameHeaders,
+				Flags:    mhttp2.FlagHeadersEndStream,
+				Length:   1,
+				StreamID: 1,
+			},
+		},
+		Fields: []mhpack.HeaderField{
+			{Name: ":method", Value: "GET"},
+			{Name: ":path", Value: "/"},
+			{Name: ":scheme", Value: "http"},
+		},
+	}
+
+	sc := newServerStreamConnection(ctx, connection, nil).(*serverStreamConnection)
+	sc.config.AltSvc = `h3=":443"; ma=86400`
+	h2s, _, _, _, err := sc.sc.HandleFrame(ctx, mh)
+	if err != nil {
+		t.Fatalf("handleFrame failed: %v", err)
+	}
+
+	s := &serverStream{
+		h2s:    h2s,
+		sc:     sc,
+		stream: stream{ctx: ctx},
+	}
+	s.AppendHeaders(ctx, phttp2.NewHeaderMap(http.Header{}), true)
+
+	assert.Equal(t, sc.config.AltSvc, h2s.Response.Header.Get("Alt-Svc"))
+}
+
 func TestStreamConfigHandler(t *testing.T) {
 	t.Run("test stream config", func(t *testing.T) {
 		v := map[string]interface{}{
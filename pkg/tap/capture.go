@@ -0,0 +1,134 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tap
+
+import (
+	"net"
+	"time"
+
+	"mosn.io/api"
+)
+
+// Trace is a captured request and response
+type Trace struct {
+	StartTime     time.Time `json:"start_time"`
+	Listener      string    `json:"listener"`
+	Route         string    `json:"route,omitempty"`
+	Protocol      string    `json:"protocol,omitempty"`
+	RemoteAddress string    `json:"remote_address,omitempty"`
+	UpstreamHost  string    `json:"upstream_host,omitempty"`
+	ResponseCode  int       `json:"response_code"`
+	DurationMs    float64   `json:"duration_ms"`
+
+	RequestHeaders        map[string]string `json:"request_headers,omitempty"`
+	RequestBody           string            `json:"request_body,omitempty"`
+	RequestBodyTruncated  bool              `json:"request_body_truncated,omitempty"`
+	ResponseHeaders       map[string]string `json:"response_headers,omitempty"`
+	ResponseBody          string            `json:"response_body,omitempty"`
+	ResponseBodyTruncated bool              `json:"response_body_truncated,omitempty"`
+}
+
+// Capture keeps the data of a stream for the matched sessions. The headers and
+// bodies are copied when they are captured, because they are reused after the stream ends.
+type Capture struct {
+	sessions     []*Session
+	maxBodyBytes int
+	trace        Trace
+}
+
+// NewCapture is called when the request is received, returns nil if no session is matched.
+// The route is matched at Finish, since it is unknown yet.
+func NewCapture(listener string, remote net.Addr, headers api.HeaderMap, body []byte) *Capture {
+	var matched []*Session
+	maxBodyBytes := 0
+	for _, s := range activeSessions() {
+		if s.matchRequest(listener, remote, headers) {
+			matched = append(matched, s)
+			if s.config.MaxBodyBytes > maxBodyBytes {
+				maxBodyBytes = s.config.MaxBodyBytes
+			}
+		}
+	}
+	if len(matched) == 0 {
+		return nil
+	}
+	c := &Capture{
+		sessions:     matched,
+		maxBodyBytes: maxBodyBytes,
+	}
+	c.trace.Listener = listener
+	if remote != nil {
+		c.trace.RemoteAddress = remote.String()
+	}
+	c.trace.RequestHeaders = copyHeaders(headers)
+	c.trace.RequestBody, c.trace.RequestBodyTruncated = copyBody(body, maxBodyBytes)
+	return c
+}
+
+// SetResponse is called before the response is sent to the downstream
+func (c *Capture) SetResponse(headers api.HeaderMap, body []byte) {
+	c.trace.ResponseHeaders = copyHeaders(headers)
+	c.trace.ResponseBody, c.trace.ResponseBodyTruncated = copyBody(body, c.maxBodyBytes)
+}
+
+// Finish is called when the stream ends, the trace is delivered to the sessions matched the route
+func (c *Capture) Finish(route string, requestInfo api.RequestInfo) {
+	c.trace.Route = route
+	if requestInfo != nil {
+		c.trace.StartTime = requestInfo.StartTime()
+		c.trace.Protocol = string(requestInfo.Protocol())
+		c.trace.ResponseCode = requestInfo.ResponseCode()
+		c.trace.DurationMs = float64(requestInfo.RequestFinishedDuration()) / float64(time.Millisecond)
+		if host := requestInfo.UpstreamHost(); host != nil {
+			c.trace.UpstreamHost = host.AddressString()
+		}
+	}
+	for _, s := range c.sessions {
+		if s.config.Match.Route != "" && s.config.Match.Route != route {
+			continue
+		}
+		trace := c.trace
+		// each session gets the body limited by its own config
+		if len(trace.RequestBody) > s.config.MaxBodyBytes {
+			trace.RequestBody, trace.RequestBodyTruncated = trace.RequestBody[:s.config.MaxBodyBytes], true
+		}
+		if len(trace.ResponseBody) > s.config.MaxBodyBytes {
+			trace.ResponseBody, trace.ResponseBodyTruncated = trace.ResponseBody[:s.config.MaxBodyBytes], true
+		}
+		s.deliver(&trace)
+	}
+}
+
+func copyHeaders(headers api.HeaderMap) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	copied := map[string]string{}
+	headers.Range(func(k, v string) bool {
+		copied[k] = v
+		return true
+	})
+	return copied
+}
+
+func copyBody(body []byte, max int) (string, bool) {
+	if len(body) > max {
+		return string(body[:max]), true
+	}
+	return string(body), false
+}
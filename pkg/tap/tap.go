@@ -0,0 +1,266 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package tap captures the requests and responses matched by the active tap sessions.
+// The proxy checks Active before capturing anything, so there is no cost when no session is active.
+package tap
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mosn.io/api"
+)
+
+const (
+	DefaultTimeout = time.Minute
+	MaxTimeout     = 10 * time.Minute
+	// traceBufferSize is the max traces waiting for the consumer, the new traces are dropped if the buffer is full
+	traceBufferSize = 1024
+)
+
+var ErrInvalidTimeout = fmt.Errorf("tap timeout should not be greater than %s", MaxTimeout)
+
+// Match selects the streams to tap, the empty fields match all the streams
+type Match struct {
+	Listener string `json:"listener,omitempty"`
+	Route    string `json:"route,omitempty"`
+	// Headers are the request headers with the exact values
+	Headers map[string]string `json:"headers,omitempty"`
+	// RemoteIP is an ip address or a cidr of the downstream remote address
+	RemoteIP string `json:"remote_ip,omitempty"`
+}
+
+// Config is the config of a tap session
+type Config struct {
+	Match Match `json:"match"`
+	// MaxBodyBytes limits the captured request and response bodies, the bodies are not captured if it is zero
+	MaxBodyBytes int `json:"max_body_bytes,omitempty"`
+	// MaxTraces stops the session after the traces are captured, zero means no limit
+	MaxTraces int `json:"max_traces,omitempty"`
+	// Timeout stops the session automatically, the default is one minute
+	Timeout api.DurationConfig `json:"timeout,omitempty"`
+}
+
+// Session is an active tap, the matched traces are received by Consume until the session stopped
+type Session struct {
+	config   Config
+	remoteIP *net.IPNet
+	traces   chan *Trace
+	done     chan struct{}
+	once     sync.Once
+	timer    *time.Timer
+	captured int64
+	dropped  int64
+}
+
+var (
+	sessionsMutex sync.Mutex
+	// sessions is a []*Session, which is replaced when a session starts or stops
+	sessions atomic.Value
+	active   int32
+)
+
+func init() {
+	sessions.Store([]*Session{})
+}
+
+// Active returns true if there is any active session
+func Active() bool {
+	return atomic.LoadInt32(&active) > 0
+}
+
+func activeSessions() []*Session {
+	return sessions.Load().([]*Session)
+}
+
+// Start starts a tap session, the session stops when it is timeout or Stop is called
+func Start(config Config) (*Session, error) {
+	if config.MaxBodyBytes < 0 || config.MaxTraces < 0 {
+		return nil, errors.New("tap max_body_bytes and max_traces should not be negative")
+	}
+	if config.Timeout.Duration <= 0 {
+		config.Timeout.Duration = DefaultTimeout
+	}
+	if config.Timeout.Duration > MaxTimeout {
+		return nil, ErrInvalidTimeout
+	}
+	s := &Session{
+		config: config,
+		traces: make(chan *Trace, traceBufferSize),
+		done:   make(chan struct{}),
+	}
+	if ip := config.Match.RemoteIP; ip != "" {
+		ipNet, err := parseIPNet(ip)
+		if err != nil {
+			return nil, err
+		}
+		s.remoteIP = ipNet
+	}
+
+	sessionsMutex.Lock()
+	current := activeSessions()
+	updated := make([]*Session, 0, len(current)+1)
+	updated = append(updated, current...)
+	sessions.Store(append(updated, s))
+	atomic.AddInt32(&active, 1)
+	// the timer is set in the lock, Stop uses it in the lock too
+	s.timer = time.AfterFunc(config.Timeout.Duration, s.Stop)
+	sessionsMutex.Unlock()
+	return s, nil
+}
+
+func parseIPNet(s string) (*net.IPNet, error) {
+	if _, ipNet, err := net.ParseCIDR(s); err == nil {
+		return ipNet, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid tap remote ip: %s", s)
+	}
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 8*net.IPv4len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// Stop stops the session, the traces are not received after Stop
+func (s *Session) Stop() {
+	s.once.Do(func() {
+		sessionsMutex.Lock()
+		s.timer.Stop()
+		current := activeSessions()
+		updated := make([]*Session, 0, len(current))
+		for _, session := range current {
+			if session != s {
+				updated = append(updated, session)
+			}
+		}
+		sessions.Store(updated)
+		atomic.AddInt32(&active, -1)
+		sessionsMutex.Unlock()
+		close(s.done)
+	})
+}
+
+// Done is closed when the session stopped
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Dropped returns the number of the traces dropped because the consumer is slow
+func (s *Session) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// Config returns the config of the session, the default values are set
+func (s *Session) Config() Config {
+	return s.config
+}
+
+// matchRequest matches the properties known when the request is received
+func (s *Session) matchRequest(listener string, remote net.Addr, headers api.HeaderMap) bool {
+	m := &s.config.Match
+	if m.Listener != "" && m.Listener != listener {
+		return false
+	}
+	if s.remoteIP != nil {
+		ip := addrIP(remote)
+		if ip == nil || !s.remoteIP.Contains(ip) {
+			return false
+		}
+	}
+	for k, v := range m.Headers {
+		if headers == nil {
+			return false
+		}
+		if hv, ok := headers.Get(k); !ok || hv != v {
+			return false
+		}
+	}
+	return true
+}
+
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	case nil:
+		return nil
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
+
+// Consume calls fn with each trace until the session stopped or fn returns an error,
+// the traces buffered before the session stopped are consumed too.
+func (s *Session) Consume(fn func(trace *Trace) error) error {
+	for {
+		select {
+		case trace := <-s.traces:
+			if err := fn(trace); err != nil {
+				return err
+			}
+		case <-s.done:
+			for {
+				select {
+				case trace := <-s.traces:
+					if err := fn(trace); err != nil {
+						return err
+					}
+				default:
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// deliver sends the trace to the consumer, the trace is dropped if the consumer is slow
+func (s *Session) deliver(trace *Trace) {
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+	if max := int64(s.config.MaxTraces); max > 0 {
+		n := atomic.AddInt64(&s.captured, 1)
+		if n > max {
+			return
+		}
+		defer func() {
+			if n == max {
+				s.Stop()
+			}
+		}()
+	}
+	select {
+	case s.traces <- trace:
+	default:
+		atomic.AddInt64(&s.dropped, 1)
+	}
+}
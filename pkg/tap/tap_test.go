@@ -0,0 +1,237 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/protocol"
+)
+
+func collect(s *Session) []*Trace {
+	var traces []*Trace
+	s.Consume(func(trace *Trace) error {
+		traces = append(traces, trace)
+		return nil
+	})
+	return traces
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	require.False(t, Active())
+	s1, err := Start(Config{})
+	require.NoError(t, err)
+	require.Equal(t, DefaultTimeout, s1.Config().Timeout.Duration)
+	s2, err := Start(Config{Timeout: api.DurationConfig{Duration: 50 * time.Millisecond}})
+	require.NoError(t, err)
+	require.True(t, Active())
+	require.Len(t, activeSessions(), 2)
+
+	// timeout
+	select {
+	case <-s2.Done():
+	case <-time.After(time.Second):
+		t.Fatal("session is not stopped when timeout")
+	}
+	require.Len(t, activeSessions(), 1)
+
+	s1.Stop()
+	s1.Stop()
+	require.False(t, Active())
+	require.Len(t, activeSessions(), 0)
+	require.Nil(t, NewCapture("listener", nil, nil, nil))
+
+	for _, cfg := range []Config{
+		{MaxBodyBytes: -1},
+		{Timeout: api.DurationConfig{Duration: time.Hour}},
+		{Match: Match{RemoteIP: "invalid"}},
+	} {
+		_, err := Start(cfg)
+		require.Error(t, err)
+	}
+	require.False(t, Active())
+}
+
+func TestCaptureMatch(t *testing.T) {
+	remote := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 12345}
+	headers := protocol.CommonHeader{"service": "test"}
+	newSession := func(match Match) *Session {
+		s, err := Start(Config{Match: match, MaxBodyBytes: 4})
+		require.NoError(t, err)
+		return s
+	}
+	matched := []*Session{
+		newSession(Match{}),
+		newSession(Match{Listener: "listener"}),
+		newSession(Match{RemoteIP: "10.0.0.0/8"}),
+		newSession(Match{RemoteIP: "10.0.0.1"}),
+		newSession(Match{Headers: map[string]string{"service": "test"}}),
+		newSession(Match{Route: "route"}),
+	}
+	unmatched := []*Session{
+		newSession(Match{Listener: "other"}),
+		newSession(Match{RemoteIP: "192.168.0.0/16"}),
+		newSession(Match{Headers: map[string]string{"service": "other"}}),
+		newSession(Match{Route: "other"}),
+	}
+
+	defer func() {
+		for _, s := range append(matched, unmatched...) {
+			s.Stop()
+		}
+	}()
+
+	c := NewCapture("listener", remote, headers, []byte("request body"))
+	require.NotNil(t, c)
+	// the route is not matched until the stream finished
+	require.Len(t, c.sessions, 7)
+	// the captured data is copied
+	headers.Set("service", "changed")
+	c.SetResponse(protocol.CommonHeader{"status": "200"}, []byte("ok"))
+	c.Finish("route", nil)
+
+	for _, s := range append(matched, unmatched...) {
+		s.Stop()
+	}
+	for _, s := range matched {
+		traces := collect(s)
+		require.Len(t, traces, 1)
+		trace := traces[0]
+		require.Equal(t, "listener", trace.Listener)
+		require.Equal(t, "route", trace.Route)
+		require.Equal(t, "10.0.0.1:12345", trace.RemoteAddress)
+		require.Equal(t, map[string]string{"service": "test"}, trace.RequestHeaders)
+		require.Equal(t, "requ", trace.RequestBody)
+		require.True(t, trace.RequestBodyTruncated)
+		require.Equal(t, map[string]string{"status": "200"}, trace.ResponseHeaders)
+		require.Equal(t, "ok", trace.ResponseBody)
+		require.False(t, trace.ResponseBodyTruncated)
+	}
+	for _, s := range unmatched {
+		require.Len(t, collect(s), 0)
+	}
+}
+
+func TestCaptureBodyLimit(t *testing.T) {
+	noBody, err := Start(Config{})
+	require.NoError(t, err)
+	withBody, err := Start(Config{MaxBodyBytes: 1024})
+	require.NoError(t, err)
+	c := NewCapture("listener", nil, nil, []byte("request body"))
+	c.Finish("", nil)
+	noBody.Stop()
+	withBody.Stop()
+
+	traces := collect(noBody)
+	require.Len(t, traces, 1)
+	require.Equal(t, "", traces[0].RequestBody)
+	require.True(t, traces[0].RequestBodyTruncated)
+	traces = collect(withBody)
+	require.Len(t, traces, 1)
+	require.Equal(t, "request body", traces[0].RequestBody)
+	require.False(t, traces[0].RequestBodyTruncated)
+}
+
+func TestMaxTraces(t *testing.T) {
+	s, err := Start(Config{MaxTraces: 2})
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		if c := NewCapture("listener", nil, nil, nil); c != nil {
+			c.Finish("", nil)
+		}
+	}
+	select {
+	case <-s.Done():
+	default:
+		t.Fatal("session is not stopped after max traces captured")
+	}
+	require.False(t, Active())
+	require.Len(t, collect(s), 2)
+}
+
+func TestWriter(t *testing.T) {
+	trace := &Trace{
+		StartTime: time.Unix(1600000000, 123456000),
+		Listener:  "listener",
+	}
+	buf := &bytes.Buffer{}
+	w, err := NewWriter(buf, FormatJSON)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(trace))
+	require.NoError(t, w.Write(trace))
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+	decoded := &Trace{}
+	require.NoError(t, json.Unmarshal(lines[0], decoded))
+	require.Equal(t, "listener", decoded.Listener)
+
+	buf.Reset()
+	w, err = NewWriter(buf, FormatPcap)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(trace))
+	require.NoError(t, w.Close())
+	data := buf.Bytes()
+	require.Equal(t, uint32(pcapMagic), binary.LittleEndian.Uint32(data[0:]))
+	require.Equal(t, uint32(pcapLinkTypeUser), binary.LittleEndian.Uint32(data[20:]))
+	record := data[24:]
+	require.Equal(t, uint32(1600000000), binary.LittleEndian.Uint32(record[0:]))
+	require.Equal(t, uint32(123456), binary.LittleEndian.Uint32(record[4:]))
+	length := binary.LittleEndian.Uint32(record[8:])
+	require.Equal(t, int(length), len(record)-16)
+	require.NoError(t, json.Unmarshal(record[16:], decoded))
+
+	_, err = NewWriter(buf, "unknown")
+	require.Error(t, err)
+}
+
+func TestFileWriter(t *testing.T) {
+	SetOutputDir("")
+	_, err := NewFileWriter("tap.json", FormatJSON)
+	require.Equal(t, ErrNoOutputDir, err)
+
+	dir := t.TempDir()
+	SetOutputDir(dir)
+	defer SetOutputDir("")
+	for _, name := range []string{"", "/etc/tap.json", "../tap.json", "sub/../../tap.json", `..\tap.json`} {
+		_, err := OutputPath(name)
+		require.Equal(t, ErrInvalidOutputPath, err, name)
+	}
+	path, err := OutputPath("sub/tap.json")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "sub", "tap.json"), path)
+
+	w, err := NewFileWriter("tap.json", FormatJSON)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(&Trace{Listener: "listener"}))
+	require.NoError(t, w.Close())
+	// the existing file is not overwritten
+	_, err = NewFileWriter("tap.json", FormatJSON)
+	require.True(t, os.IsExist(err))
+	data, err := os.ReadFile(filepath.Join(dir, "tap.json"))
+	require.NoError(t, err)
+	require.Contains(t, string(data), "listener")
+}
@@ -0,0 +1,200 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// the formats of the tap files
+const (
+	// FormatJSON writes a trace as a json line
+	FormatJSON = "json"
+	// FormatPcap writes a trace as a packet in the libpcap file, the packet data is the json trace,
+	// and the link type is LINKTYPE_USER0, so the file can be opened by the pcap tools.
+	FormatPcap = "pcap"
+)
+
+const (
+	pcapMagic        = 0xa1b2c3d4
+	pcapVersionMajor = 2
+	pcapVersionMinor = 4
+	pcapSnapLen      = 0x40000
+	pcapLinkTypeUser = 147
+)
+
+var (
+	ErrNoOutputDir       = errors.New("tap output directory is not configured")
+	ErrInvalidOutputPath = errors.New("tap output path should be a relative path in the tap output directory")
+)
+
+// outputDir is the directory of the tap files, the tap files can not be written if it is empty
+var outputDir atomic.Value
+
+// SetOutputDir sets the directory of the tap files
+func SetOutputDir(dir string) {
+	outputDir.Store(dir)
+}
+
+// OutputPath resolves the file name in the tap output directory,
+// the absolute paths and the paths contain ".." are rejected.
+func OutputPath(name string) (string, error) {
+	dir, _ := outputDir.Load().(string)
+	if dir == "" {
+		return "", ErrNoOutputDir
+	}
+	if name == "" || filepath.IsAbs(name) || strings.HasPrefix(name, "/") || strings.HasPrefix(name, "\\") {
+		return "", ErrInvalidOutputPath
+	}
+	for _, seg := range strings.FieldsFunc(name, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if seg == ".." {
+			return "", ErrInvalidOutputPath
+		}
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// Writer writes the traces
+type Writer interface {
+	Write(trace *Trace) error
+	Close() error
+}
+
+// NewWriter creates a Writer with the format
+func NewWriter(w io.Writer, format string) (Writer, error) {
+	switch format {
+	case "", FormatJSON:
+		return &jsonWriter{w: w}, nil
+	case FormatPcap:
+		pw := &pcapWriter{w: bufio.NewWriter(w)}
+		if err := pw.writeHeader(); err != nil {
+			return nil, err
+		}
+		return pw, nil
+	default:
+		return nil, fmt.Errorf("unknown tap format: %s", format)
+	}
+}
+
+// NewFileWriter creates the file in the tap output directory and a Writer with the format,
+// the existing file is not overwritten. The file is closed by the Writer.
+func NewFileWriter(name string, format string) (Writer, error) {
+	path, err := OutputPath(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewWriter(f, format)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &fileWriter{Writer: w, file: f}, nil
+}
+
+type jsonWriter struct {
+	w io.Writer
+}
+
+func (w *jsonWriter) Write(trace *Trace) error {
+	data, err := json.Marshal(trace)
+	if err != nil {
+		return err
+	}
+	_, err = w.w.Write(append(data, '\n'))
+	return err
+}
+
+func (w *jsonWriter) Close() error {
+	return nil
+}
+
+type pcapWriter struct {
+	w *bufio.Writer
+}
+
+func (w *pcapWriter) writeHeader() error {
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint32(header[0:], pcapMagic)
+	binary.LittleEndian.PutUint16(header[4:], pcapVersionMajor)
+	binary.LittleEndian.PutUint16(header[6:], pcapVersionMinor)
+	// thiszone and sigfigs are zero
+	binary.LittleEndian.PutUint32(header[16:], pcapSnapLen)
+	binary.LittleEndian.PutUint32(header[20:], pcapLinkTypeUser)
+	if _, err := w.w.Write(header); err != nil {
+		return err
+	}
+	return w.w.Flush()
+}
+
+func (w *pcapWriter) Write(trace *Trace) error {
+	data, err := json.Marshal(trace)
+	if err != nil {
+		return err
+	}
+	ts := trace.StartTime
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	origLen := len(data)
+	if len(data) > pcapSnapLen {
+		data = data[:pcapSnapLen]
+	}
+	header := make([]byte, 16)
+	binary.LittleEndian.PutUint32(header[0:], uint32(ts.Unix()))
+	binary.LittleEndian.PutUint32(header[4:], uint32(ts.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(header[8:], uint32(len(data)))
+	binary.LittleEndian.PutUint32(header[12:], uint32(origLen))
+	if _, err := w.w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.w.Write(data); err != nil {
+		return err
+	}
+	return w.w.Flush()
+}
+
+func (w *pcapWriter) Close() error {
+	return w.w.Flush()
+}
+
+type fileWriter struct {
+	Writer
+	file *os.File
+}
+
+func (w *fileWriter) Close() error {
+	err := w.Writer.Close()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
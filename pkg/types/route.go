@@ -63,6 +63,14 @@ type RouterManager interface {
 	AddRoute(routerConfigName, domain string, route *v2.Router) error
 	// RemoveAllRoutes clear all the specified virtualhost's routes
 	RemoveAllRoutes(routerConfigName, domain string) error
+	// AddOrUpdateVirtualHost adds a virtual host, or replaces the virtual host with the same name
+	AddOrUpdateVirtualHost(routerConfigName string, virtualHost *v2.VirtualHost) error
+	// RemoveVirtualHost removes the virtual host by name
+	RemoveVirtualHost(routerConfigName, virtualHostName string) error
+	// RemoveRoute removes the routes with the name in the virtual host
+	RemoveRoute(routerConfigName, virtualHostName, routeName string) error
+	// UpdateRoute replaces the routes with the same name in the virtual host
+	UpdateRoute(routerConfigName, virtualHostName string, route *v2.Router) error
 }
 
 // HandlerStatus returns the Handler's available status
@@ -84,6 +92,12 @@ type RouteHandler interface {
 	// Route returns handler's route
 	Route() api.Route
 }
+
+// NamedRouteRule is a route rule that has a configured name
+type NamedRouteRule interface {
+	Name() string
+}
+
 type RouterWrapper interface {
 	// GetRouters returns the routers in the wrapper
 	GetRouters() Routers
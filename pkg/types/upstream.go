@@ -78,6 +78,9 @@ type ClusterManager interface {
 	// ConnPoolForCluster used to get protocol related conn pool
 	ConnPoolForCluster(balancerContext LoadBalancerContext, snapshot ClusterSnapshot, protocol api.ProtocolName) (ConnectionPool, Host)
 
+	// ConnPoolForHost returns the connection pool of the host chosen by the caller
+	ConnPoolForHost(ctx context.Context, snapshot ClusterSnapshot, host Host, protocol api.ProtocolName) (ConnectionPool, error)
+
 	// RemovePrimaryCluster used to remove cluster from set
 	RemovePrimaryCluster(clusters ...string) error
 
@@ -46,6 +46,7 @@ const (
 	VarUpstreamTransportFailureReason string = "upstream_transport_failure_reason"
 	VarUpstreamCluster                string = "upstream_cluster"
 	VarRequestedServerName            string = "requested_server_name"
+	VarDownstreamPeerIdentity         string = "downstream_peer_identity"
 	VarRouteName                      string = "route_name"
 	VarProtocolConfig                 string = "protocol_config"
 
@@ -141,6 +142,15 @@ const (
 	VarPrefixHttp2Cookie        = http2ProtocolName + "_" + VarProtocolCookie
 )
 
+// [Protocol]: http3
+const (
+	// the http3ProtocolName value is protocol.HTTP3
+	http3ProtocolName     = "Http3"
+	VarHttp3RequestScheme = http3ProtocolName + "_" + VarProtocolRequestScheme
+	VarPrefixHttp3Header  = http3ProtocolName + "_" + VarProtocolRequestHeader
+	VarPrefixHttp3Cookie  = http3ProtocolName + "_" + VarProtocolCookie
+)
+
 // [MOSN]: mosn built-invariables name
 const (
 	VarStreamID                    = "stream_id"
@@ -156,6 +166,7 @@ const (
 	VarAcceptChan                  = "accept_chan"
 	VarAcceptBuffer                = "accept_buffer"
 	VarConnectionFd                = "connection_fd"
+	VarListenerTLSManager          = "listener_tls_manager"
 	VarTraceSpanKey                = "span_key"
 	VarTraceId                     = "trace_id"
 	VarProxyGeneralConfig          = "proxy_general_config"
@@ -167,6 +178,8 @@ const (
 	VarDownStreamReqHeaders        = "downstream_req_headers"
 	VarDownStreamRespHeaders       = "downstream_resp_headers"
 	VarTraceSpan                   = "trace_span"
+	VarTransportProtocol           = "transport_protocol"
+	VarApplicationProtocols        = "application_protocols"
 )
 
 var (
@@ -183,6 +196,7 @@ var (
 	VariableAcceptChan                  = variable.NewVariable(VarAcceptChan, nil, nil, variable.DefaultSetter, 0)
 	VariableAcceptBuffer                = variable.NewVariable(VarAcceptBuffer, nil, nil, variable.DefaultSetter, 0)
 	VariableConnectionFd                = variable.NewVariable(VarConnectionFd, nil, nil, variable.DefaultSetter, 0)
+	VariableListenerTLSManager          = variable.NewVariable(VarListenerTLSManager, nil, nil, variable.DefaultSetter, 0)
 	VariableTraceId                     = variable.NewVariable(VarTraceId, nil, nil, variable.DefaultSetter, 0)
 	VariableProxyGeneralConfig          = variable.NewVariable(VarProxyGeneralConfig, nil, nil, variable.DefaultSetter, 0)
 	VariableConnectionEventListeners    = variable.NewVariable(VarConnectionEventListeners, nil, nil, variable.DefaultSetter, 0)
@@ -194,16 +208,20 @@ var (
 	VariableDownStreamReqHeaders        = variable.NewVariable(VarDownStreamReqHeaders, nil, nil, variable.DefaultSetter, 0)
 	VariableDownStreamRespHeaders       = variable.NewVariable(VarDownStreamRespHeaders, nil, nil, variable.DefaultSetter, 0)
 	VariableTraceSpan                   = variable.NewVariable(VarTraceSpan, nil, nil, variable.DefaultSetter, 0)
+	VariableRequestedServerName         = variable.NewStringVariable(VarRequestedServerName, nil, nil, variable.DefaultStringSetter, 0)
+	VariableTransportProtocol           = variable.NewStringVariable(VarTransportProtocol, nil, nil, variable.DefaultStringSetter, 0)
+	VariableApplicationProtocols        = variable.NewVariable(VarApplicationProtocols, nil, nil, variable.DefaultSetter, 0)
 )
 
 func init() {
 	builtinVariables := []variable.Variable{
 		VariableStreamID, VariableConnection, VariableConnectionID, VariableConnectionPoolIndex,
 		VariableListenerPort, VariableListenerName, VariableListenerType, VariableConnDefaultReadBufferSize, VariableNetworkFilterChainFactories,
-		VariableAccessLogs, VariableAcceptChan, VariableAcceptBuffer, VariableConnectionFd,
+		VariableAccessLogs, VariableAcceptChan, VariableAcceptBuffer, VariableConnectionFd, VariableListenerTLSManager,
 		VariableTraceSpankey, VariableTraceId, VariableProxyGeneralConfig, VariableConnectionEventListeners,
 		VariableUpstreamConnectionID, VariableOriRemoteAddr,
 		VariableDownStreamProtocol, VariableUpstreamProtocol, VariableDownStreamReqHeaders, VariableDownStreamRespHeaders, VariableTraceSpan,
+		VariableRequestedServerName, VariableTransportProtocol, VariableApplicationProtocols,
 	}
 	for _, v := range builtinVariables {
 		variable.Register(v)
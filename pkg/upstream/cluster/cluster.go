@@ -129,6 +129,7 @@ type simpleCluster struct {
 	info          types.ClusterInfo
 	mutex         sync.Mutex
 	healthChecker types.HealthChecker
+	hcResults     *healthCheckResults
 	lbInstance    types.LoadBalancer // load balancer used for this cluster
 	hostSet       types.HostSet
 	snapshot      atomic.Value
@@ -155,6 +156,8 @@ func newSimpleCluster(clusterConfig v2.Cluster) types.Cluster {
 			log.DefaultLogger.Infof("[upstream] [cluster] [new cluster] cluster %s have health check", clusterConfig.Name)
 		}
 		cluster.healthChecker = healthcheck.CreateHealthCheck(clusterConfig.HealthCheck)
+		cluster.hcResults = newHealthCheckResults()
+		cluster.healthChecker.AddHostCheckCompleteCb(cluster.hcResults.record)
 	}
 	return cluster
 }
@@ -183,6 +186,7 @@ func (sc *simpleCluster) UpdateHosts(hostSet types.HostSet) {
 	})
 	if sc.healthChecker != nil {
 		sc.healthChecker.SetHealthCheckerHostSet(hostSet)
+		sc.hcResults.prune(hostSet)
 	}
 }
 
@@ -202,6 +206,10 @@ func (sc *simpleCluster) AddHealthCheckCallbacks(cb types.HealthCheckCb) {
 	}
 }
 
+func (sc *simpleCluster) healthCheckResults() *healthCheckResults {
+	return sc.hcResults
+}
+
 func (sc *simpleCluster) StopHealthChecking() {
 	sc.mutex.Lock()
 	defer sc.mutex.Unlock()
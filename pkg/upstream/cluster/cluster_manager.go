@@ -173,7 +173,7 @@ func NewClusterManagerSingleton(clusters []v2.Cluster, clusterMap map[string][]v
 	clusterManagerInstance.clusterManager.UpdateTLSManager(&config.TLSContext)
 	// add conn pool
 	clusterManagerInstance.protocolConnPool = newConnPool(config.ClusterPoolEnable)
-	protocol.RangeAllRegisteredProtocol(func(k api.ProtocolName) {
+	protocol.RangeAllRegisteredConnPool(func(k api.ProtocolName) {
 		clusterManagerInstance.protocolConnPool.store(k)
 	})
 
@@ -286,6 +286,9 @@ func (cm *clusterManager) AddOrUpdateClusterAndHost(cluster v2.Cluster, hostConf
 		if cluster.SlowStart.Mode != "" {
 			TransferClusterHostStatesHandler(oc, nc)
 		}
+		if oc != nil {
+			transferAdminHealthFlags(oc.Snapshot().HostSet(), nc.Snapshot().HostSet())
+		}
 	})
 }
 
@@ -375,6 +378,7 @@ func NewSimpleHostHandler(c types.Cluster, hostConfigs []v2.Host) {
 	if snap.ClusterInfo().SlowStart().Mode != "" {
 		transferHostSetStates(snap.HostSet(), ns)
 	}
+	transferAdminHealthFlags(snap.HostSet(), ns)
 
 	c.UpdateHosts(ns)
 
@@ -496,6 +500,17 @@ func (cm *clusterManager) ConnPoolForCluster(balancerContext types.LoadBalancerC
 	return pool, host
 }
 
+func (cm *clusterManager) ConnPoolForHost(ctx context.Context, snapshot types.ClusterSnapshot, host types.Host, proto types.ProtocolName) (types.ConnectionPool, error) {
+	if snapshot == nil || reflect.ValueOf(snapshot).IsNil() || host == nil {
+		return nil, errNilHostChoose
+	}
+	factory, ok := protocol.GetNewPoolFactory(proto)
+	if !ok {
+		return nil, fmt.Errorf("protocol %v is not registered in pool factory", proto)
+	}
+	return cm.loadConnPool(ctx, snapshot, host, proto, factory)
+}
+
 func (cm *clusterManager) GetTLSManager() types.TLSClientContextManager {
 	v := cm.tlsMng.Load()
 	tlsmng, _ := v.(types.TLSClientContextManager)
@@ -575,52 +590,9 @@ func (cm *clusterManager) getActiveConnectionPool(balancerContext types.LoadBala
 		if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
 			log.DefaultLogger.Debugf("[upstream] [cluster manager] clusterSnapshot.loadbalancer.ChooseHost result is %s, cluster name = %s", addr, clusterSnapshot.ClusterInfo().Name())
 		}
-		connectionPool, ok := cm.protocolConnPool.load(proto, clusterSnapshot)
-		if !ok {
-			return nil, nil, errUnknownProtocol
-		}
-		// we cannot use sync.Map.LoadOrStore directly, because we do not want to new a connpool every time
-		loadOrStoreConnPool := func() (types.ConnectionPool, bool) {
-			// avoid locking if it is already exists
-			if connPool, ok := connectionPool.Load(addr); ok {
-				pool := connPool.(types.ConnectionPool)
-				return pool, true
-			}
-			cm.mux.Lock()
-			defer cm.mux.Unlock()
-			if connPool, ok := connectionPool.Load(addr); ok {
-				pool := connPool.(types.ConnectionPool)
-				return pool, true
-			}
-			pool := factory(balancerContext.DownstreamContext(), host)
-			connectionPool.Store(addr, pool)
-			return pool, false
-		}
-		pool, loaded := loadOrStoreConnPool()
-		if loaded {
-			if !pool.TLSHashValue().Equal(host.TLSHashValue()) {
-				if log.DefaultLogger.GetLogLevel() >= log.INFO {
-					log.DefaultLogger.Infof("[upstream] [cluster manager] %s tls state changed", addr)
-				}
-				func() {
-					// lock the load and delete
-					cm.mux.Lock()
-					defer cm.mux.Unlock()
-					// recheck whether the pool is changed
-					if connPool, ok := connectionPool.Load(addr); ok {
-						pool = connPool.(types.ConnectionPool)
-						if pool.TLSHashValue().Equal(host.TLSHashValue()) {
-							return
-						}
-						connectionPool.Delete(addr)
-						pool.Shutdown()
-						pool = factory(balancerContext.DownstreamContext(), host)
-						connectionPool.Store(addr, pool)
-						cm.tlsMetrics.TLSConnpoolChanged.Inc(1)
-					}
-				}()
-
-			}
+		pool, err := cm.loadConnPool(balancerContext.DownstreamContext(), clusterSnapshot, host, proto, factory)
+		if err != nil {
+			return nil, nil, err
 		}
 		if pool.CheckAndInit(balancerContext.DownstreamContext()) {
 			return pool, host, nil
@@ -645,6 +617,60 @@ func (cm *clusterManager) getActiveConnectionPool(balancerContext types.LoadBala
 	return nil, nil, errNoHealthyHost
 }
 
+// loadConnPool returns the connection pool of the host, the pool is created if it does not exist,
+// or the tls config of the host is changed
+func (cm *clusterManager) loadConnPool(ctx context.Context, clusterSnapshot types.ClusterSnapshot, host types.Host, proto types.ProtocolName, factory types.NewConnPool) (types.ConnectionPool, error) {
+	addr := host.AddressString()
+	connectionPool, ok := cm.protocolConnPool.load(proto, clusterSnapshot)
+	if !ok {
+		return nil, errUnknownProtocol
+	}
+	// we cannot use sync.Map.LoadOrStore directly, because we do not want to new a connpool every time
+	loadOrStoreConnPool := func() (types.ConnectionPool, bool) {
+		// avoid locking if it is already exists
+		if connPool, ok := connectionPool.Load(addr); ok {
+			pool := connPool.(types.ConnectionPool)
+			return pool, true
+		}
+		cm.mux.Lock()
+		defer cm.mux.Unlock()
+		if connPool, ok := connectionPool.Load(addr); ok {
+			pool := connPool.(types.ConnectionPool)
+			return pool, true
+		}
+		pool := factory(ctx, host)
+		connectionPool.Store(addr, pool)
+		return pool, false
+	}
+	pool, loaded := loadOrStoreConnPool()
+	if loaded {
+		if !pool.TLSHashValue().Equal(host.TLSHashValue()) {
+			if log.DefaultLogger.GetLogLevel() >= log.INFO {
+				log.DefaultLogger.Infof("[upstream] [cluster manager] %s tls state changed", addr)
+			}
+			func() {
+				// lock the load and delete
+				cm.mux.Lock()
+				defer cm.mux.Unlock()
+				// recheck whether the pool is changed
+				if connPool, ok := connectionPool.Load(addr); ok {
+					pool = connPool.(types.ConnectionPool)
+					if pool.TLSHashValue().Equal(host.TLSHashValue()) {
+						return
+					}
+					connectionPool.Delete(addr)
+					pool.Shutdown()
+					pool = factory(ctx, host)
+					connectionPool.Store(addr, pool)
+					cm.tlsMetrics.TLSConnpoolChanged.Inc(1)
+				}
+			}()
+
+		}
+	}
+	return pool, nil
+}
+
 func (cm *clusterManager) ShutdownConnectionPool(proto types.ProtocolName, addr string) {
 	cm.protocolConnPool.shutdown(proto, addr)
 }
@@ -18,12 +18,64 @@
 package cluster
 
 import (
+	"fmt"
+	"strings"
 	"sync"
 	"sync/atomic"
 
 	"mosn.io/api"
+	"mosn.io/mosn/pkg/types"
 )
 
+// health flags set by the admin api, they are not changed by the health check.
+// a host with any health flag is not chosen by the load balancers.
+const (
+	// HostDraining means the host is going to be removed, no new requests are sent to it
+	HostDraining api.HealthFlag = 0x100
+	// HostAdminUnhealthy means the host is marked as unhealthy manually
+	HostAdminUnhealthy api.HealthFlag = 0x200
+
+	adminHealthFlags = HostDraining | HostAdminUnhealthy
+)
+
+// adminHealthFlagByName returns the admin health flag of the name in host config
+func adminHealthFlagByName(name string) (api.HealthFlag, bool) {
+	for _, f := range healthFlagNames {
+		if f.flag&adminHealthFlags != 0 && f.name == name {
+			return f.flag, true
+		}
+	}
+	return 0, false
+}
+
+// adminHealthFlagNames returns the names of the admin health flags set in flags
+func adminHealthFlagNames(flags api.HealthFlag) []string {
+	var names []string
+	for _, f := range healthFlagNames {
+		if f.flag&adminHealthFlags != 0 && flags&f.flag != 0 {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+// transferAdminHealthFlags keeps the admin health flags of the hosts with the same address after the hosts are updated
+func transferAdminHealthFlags(os, ns types.HostSet) {
+	oldHosts := make(map[string]types.Host, os.Size())
+	os.Range(func(host types.Host) bool {
+		oldHosts[host.AddressString()] = host
+		return true
+	})
+	ns.Range(func(host types.Host) bool {
+		if h, ok := oldHosts[host.AddressString()]; ok {
+			if flags := h.HealthFlag() & adminHealthFlags; flags != 0 {
+				host.SetHealthFlag(flags)
+			}
+		}
+		return true
+	})
+}
+
 // health flag reuse for same address
 // TODO: use one map for all reuse data
 var healthStore = sync.Map{}
@@ -54,3 +106,43 @@ func ClearHealthFlag(p *uint64, flag api.HealthFlag) {
 	f &= ^uint64(flag)
 	atomic.StoreUint64(p, f)
 }
+
+// SetHostsHealthFlag sets or clears the admin health flag of the hosts in the cluster,
+// the flags are stored in the hosts of the cluster only, and kept when the hosts are updated.
+func SetHostsHealthFlag(clusterName string, addrs []string, flag api.HealthFlag, set bool) error {
+	cm := getClusterManager()
+	if cm == nil {
+		return errNilClusterManager
+	}
+	if flag&^adminHealthFlags != 0 {
+		return fmt.Errorf("health flag %d can not be set by admin", flag)
+	}
+	v, ok := cm.clustersMap.Load(clusterName)
+	if !ok {
+		return fmt.Errorf("cluster %s is not exists", clusterName)
+	}
+	c := v.(types.Cluster)
+	hosts := map[string]types.Host{}
+	c.Snapshot().HostSet().Range(func(host types.Host) bool {
+		hosts[host.AddressString()] = host
+		return true
+	})
+	var notFound []string
+	for _, addr := range addrs {
+		if _, ok := hosts[addr]; !ok {
+			notFound = append(notFound, addr)
+		}
+	}
+	if len(notFound) > 0 {
+		return fmt.Errorf("hosts %s are not exists in cluster %s", strings.Join(notFound, ","), clusterName)
+	}
+	for _, addr := range addrs {
+		if set {
+			hosts[addr].SetHealthFlag(flag)
+		} else {
+			hosts[addr].ClearHealthFlag(flag)
+		}
+	}
+	refreshHostsConfig(c)
+	return nil
+}
@@ -43,6 +43,7 @@ type simpleHost struct {
 	tlsDisable              bool
 	weight                  uint32
 	healthFlags             *uint64
+	adminHealthFlags        uint64 // the health flags set by the admin api, not shared by address
 	lastHealthCheckPassTime time.Time
 }
 
@@ -59,6 +60,11 @@ func NewSimpleHost(config v2.Host, clusterInfo types.ClusterInfo) types.Host {
 		weight:        config.Weight,
 		healthFlags:   GetHealthFlagPointer(config.Address),
 	}
+	for _, name := range config.HealthFlags {
+		if flag, ok := adminHealthFlagByName(name); ok {
+			h.adminHealthFlags |= uint64(flag)
+		}
+	}
 	h.clusterInfo.Store(clusterInfo)
 	return h
 }
@@ -106,10 +112,11 @@ func (sh *simpleHost) Weight() uint32 {
 func (sh *simpleHost) Config() v2.Host {
 	return v2.Host{
 		HostConfig: v2.HostConfig{
-			Address:    sh.addressString,
-			Hostname:   sh.hostname,
-			TLSDisable: sh.tlsDisable,
-			Weight:     sh.weight,
+			Address:     sh.addressString,
+			Hostname:    sh.hostname,
+			TLSDisable:  sh.tlsDisable,
+			Weight:      sh.weight,
+			HealthFlags: adminHealthFlagNames(api.HealthFlag(atomic.LoadUint64(&sh.adminHealthFlags))),
 		},
 		MetaData: sh.metaData,
 	}
@@ -162,7 +169,15 @@ func (sh *simpleHost) CreateUDPConnection(context context.Context) types.CreateC
 	}
 }
 
+// ClearHealthFlag clears the health flag, the admin health flags are stored in the host only,
+// and clearing them does not change the last health check pass time, so no slow start is triggered.
 func (sh *simpleHost) ClearHealthFlag(flag api.HealthFlag) {
+	if admin := flag & adminHealthFlags; admin != 0 {
+		ClearHealthFlag(&sh.adminHealthFlags, admin)
+	}
+	if flag &^= adminHealthFlags; flag == 0 {
+		return
+	}
 	ClearHealthFlag(sh.healthFlags, flag)
 	if atomic.LoadUint64(sh.healthFlags) == 0 {
 		sh.SetLastHealthCheckPassTime(time.Now())
@@ -170,10 +185,16 @@ func (sh *simpleHost) ClearHealthFlag(flag api.HealthFlag) {
 }
 
 func (sh *simpleHost) ContainHealthFlag(flag api.HealthFlag) bool {
-	return atomic.LoadUint64(sh.healthFlags)&uint64(flag) > 0
+	return uint64(sh.HealthFlag())&uint64(flag) > 0
 }
 
 func (sh *simpleHost) SetHealthFlag(flag api.HealthFlag) {
+	if admin := flag & adminHealthFlags; admin != 0 {
+		SetHealthFlag(&sh.adminHealthFlags, admin)
+	}
+	if flag &^= adminHealthFlags; flag == 0 {
+		return
+	}
 	SetHealthFlag(sh.healthFlags, flag)
 	if atomic.LoadUint64(sh.healthFlags) == 0 {
 		sh.SetLastHealthCheckPassTime(time.Now())
@@ -181,11 +202,11 @@ func (sh *simpleHost) SetHealthFlag(flag api.HealthFlag) {
 }
 
 func (sh *simpleHost) HealthFlag() api.HealthFlag {
-	return api.HealthFlag(atomic.LoadUint64(sh.healthFlags))
+	return api.HealthFlag(atomic.LoadUint64(sh.healthFlags) | atomic.LoadUint64(&sh.adminHealthFlags))
 }
 
 func (sh *simpleHost) Health() bool {
-	return atomic.LoadUint64(sh.healthFlags) == 0
+	return sh.HealthFlag() == 0
 }
 
 func (sh *simpleHost) LastHealthCheckPassTime() time.Time {
@@ -0,0 +1,252 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/types"
+)
+
+// maxHealthCheckResults is the max recent health check results kept for each host
+const maxHealthCheckResults = 5
+
+// ClusterStatus is the runtime status of a cluster
+type ClusterStatus struct {
+	Name            string          `json:"name"`
+	Type            string          `json:"type"`
+	LbType          string          `json:"lb_type"`
+	HealthyHosts    int             `json:"healthy_hosts"`
+	CircuitBreakers CircuitBreakers `json:"circuit_breakers"`
+	Hosts           []HostStatus    `json:"hosts"`
+}
+
+// CircuitBreakers is the status of the cluster resource manager
+type CircuitBreakers struct {
+	Connections     ResourceStatus `json:"connections"`
+	PendingRequests ResourceStatus `json:"pending_requests"`
+	Requests        ResourceStatus `json:"requests"`
+	Retries         ResourceStatus `json:"retries"`
+}
+
+// ResourceStatus is the usage of a resource
+type ResourceStatus struct {
+	Max       uint64 `json:"max"`
+	Current   int64  `json:"current"`
+	Remaining uint64 `json:"remaining"`
+}
+
+// HostStatus is the runtime status of a host
+type HostStatus struct {
+	Address                 string              `json:"address"`
+	Hostname                string              `json:"hostname,omitempty"`
+	Weight                  uint32              `json:"weight"`
+	Healthy                 bool                `json:"healthy"`
+	HealthFlags             []string            `json:"health_flags,omitempty"`
+	ActiveConnections       int64               `json:"active_connections"`
+	ActiveRequests          int64               `json:"active_requests"`
+	TotalRequests           int64               `json:"total_requests"`
+	LastHealthCheckPassTime time.Time           `json:"last_health_check_pass_time"`
+	HealthCheckResults      []HealthCheckResult `json:"health_check_results,omitempty"`
+}
+
+// HealthCheckResult is a health check result of a host
+type HealthCheckResult struct {
+	Time    time.Time `json:"time"`
+	Healthy bool      `json:"healthy"`
+}
+
+// healthFlagNames maps the health flags to the names in host status
+var healthFlagNames = []struct {
+	flag api.HealthFlag
+	name string
+}{
+	{api.FAILED_ACTIVE_HC, "failed_active_hc"},
+	{api.FAILED_OUTLIER_CHECK, "failed_outlier_check"},
+	{HostDraining, "draining"},
+	{HostAdminUnhealthy, "admin_unhealthy"},
+}
+
+// GetClustersStatus returns the status of the clusters sorted by name,
+// returns all the clusters if no name specified, the clusters not found are ignored.
+func GetClustersStatus(names ...string) []*ClusterStatus {
+	cm := getClusterManager()
+	if cm == nil {
+		return nil
+	}
+
+	var clusters []types.Cluster
+	if len(names) == 0 {
+		cm.clustersMap.Range(func(_, v interface{}) bool {
+			clusters = append(clusters, v.(types.Cluster))
+			return true
+		})
+	} else {
+		for _, name := range names {
+			if v, ok := cm.clustersMap.Load(name); ok {
+				clusters = append(clusters, v.(types.Cluster))
+			}
+		}
+	}
+
+	status := make([]*ClusterStatus, 0, len(clusters))
+	for _, c := range clusters {
+		status = append(status, newClusterStatus(c))
+	}
+	sort.Slice(status, func(i, j int) bool {
+		return status[i].Name < status[j].Name
+	})
+	return status
+}
+
+var errNilClusterManager = errors.New("cluster manager is not created")
+
+func getClusterManager() *clusterManager {
+	clusterManagerInstance.instanceMutex.Lock()
+	defer clusterManagerInstance.instanceMutex.Unlock()
+	return clusterManagerInstance.clusterManager
+}
+
+func newClusterStatus(c types.Cluster) *ClusterStatus {
+	snap := c.Snapshot()
+	info := snap.ClusterInfo()
+	status := &ClusterStatus{
+		Name:   info.Name(),
+		Type:   string(info.ClusterType()),
+		LbType: string(info.LbType()),
+		Hosts:  []HostStatus{},
+	}
+	if rm := info.ResourceManager(); rm != nil {
+		status.CircuitBreakers = CircuitBreakers{
+			Connections:     newResourceStatus(rm.Connections()),
+			PendingRequests: newResourceStatus(rm.PendingRequests()),
+			Requests:        newResourceStatus(rm.Requests()),
+			Retries:         newResourceStatus(rm.Retries()),
+		}
+	}
+	var results *healthCheckResults
+	if r, ok := c.(interface{ healthCheckResults() *healthCheckResults }); ok {
+		results = r.healthCheckResults()
+	}
+	snap.HostSet().Range(func(host types.Host) bool {
+		hs := newHostStatus(host)
+		if results != nil {
+			hs.HealthCheckResults = results.get(host.AddressString())
+		}
+		if hs.Healthy {
+			status.HealthyHosts++
+		}
+		status.Hosts = append(status.Hosts, hs)
+		return true
+	})
+	return status
+}
+
+func newResourceStatus(r types.Resource) ResourceStatus {
+	s := ResourceStatus{
+		Max:     r.Max(),
+		Current: r.Cur(),
+	}
+	if s.Current < 0 {
+		s.Remaining = s.Max
+	} else if uint64(s.Current) < s.Max {
+		s.Remaining = s.Max - uint64(s.Current)
+	}
+	return s
+}
+
+func newHostStatus(host types.Host) HostStatus {
+	hs := HostStatus{
+		Address:                 host.AddressString(),
+		Hostname:                host.Hostname(),
+		Weight:                  host.Weight(),
+		Healthy:                 host.Health(),
+		LastHealthCheckPassTime: host.LastHealthCheckPassTime(),
+	}
+	for _, f := range healthFlagNames {
+		if host.ContainHealthFlag(f.flag) {
+			hs.HealthFlags = append(hs.HealthFlags, f.name)
+		}
+	}
+	if stats := host.HostStats(); stats != nil {
+		hs.ActiveConnections = stats.UpstreamConnectionActive.Count()
+		hs.ActiveRequests = stats.UpstreamRequestActive.Count()
+		hs.TotalRequests = stats.UpstreamRequestTotal.Count()
+	}
+	return hs
+}
+
+// healthCheckResults records the recent health check results of each host
+type healthCheckResults struct {
+	mutex   sync.RWMutex
+	results map[string][]HealthCheckResult
+}
+
+func newHealthCheckResults() *healthCheckResults {
+	return &healthCheckResults{
+		results: map[string][]HealthCheckResult{},
+	}
+}
+
+// record is a types.HealthCheckCb
+func (r *healthCheckResults) record(host types.Host, changed bool, isHealthy bool) {
+	addr := host.AddressString()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	results := append(r.results[addr], HealthCheckResult{
+		Time:    time.Now(),
+		Healthy: isHealthy,
+	})
+	if len(results) > maxHealthCheckResults {
+		results = results[len(results)-maxHealthCheckResults:]
+	}
+	r.results[addr] = results
+}
+
+// get returns the results of the host, the latest is the last
+func (r *healthCheckResults) get(addr string) []HealthCheckResult {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	results := r.results[addr]
+	if len(results) == 0 {
+		return nil
+	}
+	copied := make([]HealthCheckResult, len(results))
+	copy(copied, results)
+	return copied
+}
+
+// prune removes the results of the hosts not in the host set
+func (r *healthCheckResults) prune(hostSet types.HostSet) {
+	addrs := make(map[string]struct{}, hostSet.Size())
+	hostSet.Range(func(host types.Host) bool {
+		addrs[host.AddressString()] = struct{}{}
+		return true
+	})
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for addr := range r.results {
+		if _, ok := addrs[addr]; !ok {
+			delete(r.results, addr)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/types"
+)
+
+func TestGetClustersStatus(t *testing.T) {
+	_createClusterManager()
+	clusterManagerInstance.AddOrUpdateClusterAndHost(v2.Cluster{
+		Name:        "status_test",
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_RANDOM,
+		CirBreThresholds: v2.CircuitBreakers{
+			Thresholds: []v2.Thresholds{
+				{
+					MaxConnections: 100,
+				},
+			},
+		},
+	}, []v2.Host{
+		{HostConfig: v2.HostConfig{Address: "127.0.0.1:10000", Hostname: "h1", Weight: 10}},
+		{HostConfig: v2.HostConfig{Address: "127.0.0.1:10001"}},
+	})
+	snap := clusterManagerInstance.GetClusterSnapshot(context.Background(), "status_test")
+	snap.ClusterInfo().ResourceManager().Connections().Increase()
+	snap.HostSet().Range(func(host types.Host) bool {
+		if host.AddressString() == "127.0.0.1:10001" {
+			host.SetHealthFlag(api.FAILED_ACTIVE_HC)
+		} else {
+			host.HostStats().UpstreamConnectionActive.Inc(2)
+		}
+		return true
+	})
+	defer snap.HostSet().Range(func(host types.Host) bool {
+		host.ClearHealthFlag(api.FAILED_ACTIVE_HC)
+		return true
+	})
+
+	all := GetClustersStatus()
+	require.Len(t, all, 2)
+	require.Equal(t, "status_test", all[0].Name)
+	require.Equal(t, "test1", all[1].Name)
+
+	status := GetClustersStatus("status_test", "not_exists")
+	require.Len(t, status, 1)
+	cs := status[0]
+	require.Equal(t, string(v2.SIMPLE_CLUSTER), cs.Type)
+	require.Equal(t, string(types.Random), cs.LbType)
+	require.Equal(t, 1, cs.HealthyHosts)
+	require.Equal(t, ResourceStatus{Max: 100, Current: 1, Remaining: 99}, cs.CircuitBreakers.Connections)
+	require.Len(t, cs.Hosts, 2)
+	for _, h := range cs.Hosts {
+		switch h.Address {
+		case "127.0.0.1:10000":
+			require.Equal(t, "h1", h.Hostname)
+			require.Equal(t, uint32(10), h.Weight)
+			require.True(t, h.Healthy)
+			require.Equal(t, int64(2), h.ActiveConnections)
+		case "127.0.0.1:10001":
+			require.False(t, h.Healthy)
+			require.Equal(t, []string{"failed_active_hc"}, h.HealthFlags)
+		default:
+			t.Fatalf("unexpected host %s", h.Address)
+		}
+	}
+}
+
+func TestHealthCheckResults(t *testing.T) {
+	results := newHealthCheckResults()
+	host := &mockHost{addr: "127.0.0.1:10000"}
+	for i := 0; i < maxHealthCheckResults+2; i++ {
+		results.record(host, false, i%2 == 0)
+	}
+	got := results.get(host.addr)
+	require.Len(t, got, maxHealthCheckResults)
+	// the latest is the last
+	require.True(t, got[len(got)-1].Healthy)
+	require.False(t, got[len(got)-2].Healthy)
+
+	results.prune(&hostSet{})
+	require.Nil(t, results.get(host.addr))
+}
+
+func TestSetHostsHealthFlag(t *testing.T) {
+	_createClusterManager()
+	clusterManagerInstance.AddOrUpdateClusterAndHost(v2.Cluster{
+		Name:        "health_flag_test",
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_RANDOM,
+	}, []v2.Host{
+		{HostConfig: v2.HostConfig{Address: "127.0.0.1:10010"}},
+		{HostConfig: v2.HostConfig{Address: "127.0.0.1:10011"}},
+	})
+	addrs := []string{"127.0.0.1:10010"}
+	defer SetHostsHealthFlag("health_flag_test", addrs, HostDraining|HostAdminUnhealthy, false)
+
+	require.Error(t, SetHostsHealthFlag("not_exists", addrs, HostDraining, true))
+	require.Error(t, SetHostsHealthFlag("health_flag_test", []string{"127.0.0.1:10010", "127.0.0.1:20000"}, HostDraining, true))
+	// nothing changed if any host is not exists
+	require.Equal(t, 2, GetClustersStatus("health_flag_test")[0].HealthyHosts)
+
+	require.NoError(t, SetHostsHealthFlag("health_flag_test", addrs, HostDraining, true))
+	cs := GetClustersStatus("health_flag_test")[0]
+	require.Equal(t, 1, cs.HealthyHosts)
+	for _, h := range cs.Hosts {
+		if h.Address == "127.0.0.1:10010" {
+			require.False(t, h.Healthy)
+			require.Equal(t, []string{"draining"}, h.HealthFlags)
+		}
+	}
+	// the drained host is not chosen by the load balancer
+	snap := clusterManagerInstance.GetClusterSnapshot(context.Background(), "health_flag_test")
+	for i := 0; i < 10; i++ {
+		host := snap.LoadBalancer().ChooseHost(nil)
+		require.Equal(t, "127.0.0.1:10011", host.AddressString())
+	}
+
+	// the flags are dumped in the host config, and kept after the hosts are updated
+	hostConfigs := []v2.Host{
+		{HostConfig: v2.HostConfig{Address: "127.0.0.1:10010"}},
+		{HostConfig: v2.HostConfig{Address: "127.0.0.1:10012"}},
+	}
+	require.NoError(t, clusterManagerInstance.UpdateClusterHosts("health_flag_test", hostConfigs))
+	snap = clusterManagerInstance.GetClusterSnapshot(context.Background(), "health_flag_test")
+	snap.HostSet().Range(func(host types.Host) bool {
+		if host.AddressString() == "127.0.0.1:10010" {
+			require.Equal(t, []string{"draining"}, host.Config().HealthFlags)
+			require.False(t, host.Health())
+		} else {
+			require.Nil(t, host.Config().HealthFlags)
+			require.True(t, host.Health())
+		}
+		return true
+	})
+
+	// the flags are not shared by the hosts with the same address in other clusters
+	clusterManagerInstance.AddOrUpdateClusterAndHost(v2.Cluster{
+		Name:        "health_flag_other",
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_RANDOM,
+	}, []v2.Host{
+		{HostConfig: v2.HostConfig{Address: "127.0.0.1:10010"}},
+	})
+	require.Equal(t, 1, GetClustersStatus("health_flag_other")[0].HealthyHosts)
+
+	// clearing the flags does not trigger slow start
+	require.NoError(t, SetHostsHealthFlag("health_flag_test", addrs, HostDraining, false))
+	require.Equal(t, 2, GetClustersStatus("health_flag_test")[0].HealthyHosts)
+	snap.HostSet().Range(func(host types.Host) bool {
+		require.True(t, host.LastHealthCheckPassTime().IsZero())
+		return true
+	})
+
+	// only the admin flags can be set
+	require.Error(t, SetHostsHealthFlag("health_flag_test", addrs, api.FAILED_ACTIVE_HC, true))
+}
+
+func TestHostAdminHealthFlagsConfig(t *testing.T) {
+	host := NewSimpleHost(v2.Host{
+		HostConfig: v2.HostConfig{
+			Address:     "127.0.0.1:10020",
+			HealthFlags: []string{"admin_unhealthy", "failed_active_hc", "unknown"},
+		},
+	}, &clusterInfo{name: "test"})
+	require.False(t, host.Health())
+	require.True(t, host.ContainHealthFlag(HostAdminUnhealthy))
+	require.False(t, host.ContainHealthFlag(api.FAILED_ACTIVE_HC))
+	require.Equal(t, []string{"admin_unhealthy"}, host.Config().HealthFlags)
+	// the admin flags are not shared by address
+	require.Equal(t, uint64(0), *GetHealthFlagPointer("127.0.0.1:10020"))
+}